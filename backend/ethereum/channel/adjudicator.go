@@ -0,0 +1,459 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel // import "perun.network/go-perun/backend/ethereum/channel"
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	perunwallet "perun.network/go-perun/wallet"
+)
+
+// Dispute phases of the Adjudicator contract.
+const (
+	phaseDispute   = uint8(0)
+	phaseForceExec = uint8(1)
+)
+
+// adjudicatorABI is the parsed Adjudicator ABI, used to decode the calldata of
+// adjudicator transactions and to filter its event logs.
+var adjudicatorABI = parseAdjudicatorABI()
+
+func parseAdjudicatorABI() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(adjudicator.AdjudicatorABI))
+	if err != nil {
+		log.Panicf("parsing adjudicator ABI: %v", err)
+	}
+	return parsed
+}
+
+// Adjudicator implements the channel.Adjudicator interface for Ethereum.
+type Adjudicator struct {
+	ContractBackend
+	contract *adjudicator.Adjudicator
+	address  common.Address // address of the Adjudicator contract
+	// receiver is the on-chain address that receives withdrawn funds.
+	receiver common.Address
+	mu       sync.Mutex // protects nonce usage of the transactor
+	log      log.Logger // structured logger
+}
+
+// dispute is a state that is registered on the Adjudicator contract.
+type dispute struct {
+	state   adjudicator.ChannelState
+	timeout *big.Int
+	phase   uint8
+}
+
+// compile time check that we implement the perun adjudicator interface
+var (
+	_ channel.ProgressingAdjudicator = (*Adjudicator)(nil)
+	_ channel.EventSubscriber        = (*Adjudicator)(nil)
+)
+
+// NewAdjudicator creates a new ethereum adjudicator that interacts with the
+// Adjudicator contract at the given address. Withdrawn funds are sent to
+// receiver.
+func NewAdjudicator(backend ContractBackend, contract common.Address, receiver common.Address) *Adjudicator {
+	ctr, err := adjudicator.NewAdjudicator(contract, backend)
+	if err != nil {
+		log.Panicf("connecting to adjudicator: %v", err)
+	}
+	return &Adjudicator{
+		ContractBackend: backend,
+		contract:        ctr,
+		address:         contract,
+		receiver:        receiver,
+		log:             log.WithField("account", backend.account.Address),
+	}
+}
+
+// Register registers the state of the request on the Adjudicator contract.
+// A final state is concluded directly, so its funds can be withdrawn
+// immediately. A non-final state is registered, or refutes an older state that
+// a peer already registered. If the same or a newer state is registered
+// already, no transaction is sent.
+func (a *Adjudicator) Register(ctx context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	if req.Tx.IsFinal {
+		if err := a.concludeFinal(ctx, req); err != nil {
+			return nil, err
+		}
+		return &channel.Registered{
+			ID:      req.Params.ID(),
+			Idx:     req.Idx,
+			Version: req.Tx.Version,
+			Timeout: time.Now(),
+		}, nil
+	}
+
+	d, err := a.dispute(ctx, req.Params.ID())
+	if err != nil {
+		return nil, err
+	}
+	if d == nil || d.state.Version < req.Tx.Version {
+		params, state := channelParamsToEthParams(req.Params), channelStateToEthState(req.Tx.State)
+		sigs := sigsToBytes(req.Tx.Sigs)
+		err := a.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			if d == nil {
+				return a.contract.Register(opts, params, state, sigs)
+			}
+			return a.contract.Refute(opts, params, d.state, d.timeout, state, sigs)
+		})
+		// A peer might have registered concurrently, so the registered
+		// dispute is checked again before reporting an error.
+		var derr error
+		if d, derr = a.dispute(ctx, req.Params.ID()); derr != nil {
+			return nil, derr
+		} else if d == nil || d.state.Version < req.Tx.Version {
+			return nil, errors.WithMessage(err, "registering state")
+		}
+	}
+
+	return &channel.Registered{
+		ID:      req.Params.ID(),
+		Idx:     req.Idx,
+		Version: d.state.Version,
+		Timeout: time.Unix(d.timeout.Int64(), 0),
+	}, nil
+}
+
+// Progress progresses the registered state of the channel to the new state of
+// the request, which is signed by the actor req.Idx. The contract only accepts
+// the progression after the timeout of the registration and checks the
+// transition with the channel's app contract. If the new state is progressed
+// already, e.g., by a concurrent call, no transaction is sent.
+func (a *Adjudicator) Progress(ctx context.Context, req channel.ProgressReq) (*channel.Registered, error) {
+	if channel.IsAppUpgraded(req.Params, req.NewState) {
+		// The contract checks the progression with the app of the parameters.
+		return nil, errors.New("channels with upgraded app cannot be progressed")
+	}
+	d, err := a.dispute(ctx, req.Params.ID())
+	if err != nil {
+		return nil, err
+	} else if d == nil {
+		return nil, errors.New("no registered state to progress")
+	}
+	if d.state.Version < req.NewState.Version {
+		params, state := channelParamsToEthParams(req.Params), channelStateToEthState(req.NewState)
+		actorIdx := big.NewInt(int64(req.Idx))
+		if err := a.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+			return a.contract.Progress(opts, params, d.state, d.timeout, d.phase, state, actorIdx, req.Sig)
+		}); err != nil {
+			return nil, errors.WithMessage(err, "progressing state")
+		}
+		if d, err = a.dispute(ctx, req.Params.ID()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &channel.Registered{
+		ID:         req.Params.ID(),
+		Idx:        req.Idx,
+		Version:    d.state.Version,
+		Timeout:    time.Unix(d.timeout.Int64(), 0),
+		Progressed: d.phase == phaseForceExec,
+	}, nil
+}
+
+// Withdraw concludes the registered state of the request, if it isn't
+// concluded yet, and withdraws the funds of participant req.Idx from all asset
+// holders to the receiver.
+func (a *Adjudicator) Withdraw(ctx context.Context, req channel.AdjudicatorReq) error {
+	if req.Tx.IsFinal {
+		if err := a.concludeFinal(ctx, req); err != nil {
+			return err
+		}
+	} else if err := a.conclude(ctx, req.Params); err != nil {
+		return err
+	}
+
+	for i, asset := range req.Tx.Allocation.Assets {
+		if err := a.withdrawAsset(ctx, req, asset.(*Asset).Address); err != nil {
+			return errors.WithMessagef(err, "withdrawing asset %d", i)
+		}
+	}
+	return nil
+}
+
+// SubscribeRegistered returns a subscription of the newest past and all future
+// registrations of the channel with the given parameters.
+// The participant who registered the state is not known from the on-chain
+// events, so the Idx of the returned events is always zero.
+func (a *Adjudicator) SubscribeRegistered(ctx context.Context, params *channel.Params) (channel.RegisteredSubscription, error) {
+	return newRegisteredSub(ctx, a, params.ID())
+}
+
+// SubscribeEvents returns a subscription of the newest past and all future
+// Registered, Progressed and Concluded events of the channel with the given
+// parameters. Logs that are removed by a chain reorganization are handled as
+// described by channel.AdjudicatorSubscription.
+func (a *Adjudicator) SubscribeEvents(ctx context.Context, params *channel.Params) (channel.AdjudicatorSubscription, error) {
+	return newEventSub(ctx, a, params.ID())
+}
+
+// concludeFinal concludes the final state of the request, unless the channel
+// is already concluded.
+func (a *Adjudicator) concludeFinal(ctx context.Context, req channel.AdjudicatorReq) error {
+	if concluded, err := a.isConcluded(ctx, req.Params.ID()); err != nil || concluded {
+		return err
+	}
+	params, state := channelParamsToEthParams(req.Params), channelStateToEthState(req.Tx.State)
+	sigs := sigsToBytes(req.Tx.Sigs)
+	err := a.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return a.contract.ConcludeFinal(opts, params, state, sigs)
+	})
+	return errors.WithMessage(a.concludedConcurrently(ctx, req.Params.ID(), err), "concluding final state")
+}
+
+// conclude concludes the registered dispute of the channel, unless the
+// channel is already concluded. The timeout of the dispute must have passed.
+func (a *Adjudicator) conclude(ctx context.Context, p *channel.Params) error {
+	if concluded, err := a.isConcluded(ctx, p.ID()); err != nil || concluded {
+		return err
+	}
+	d, err := a.dispute(ctx, p.ID())
+	if err != nil {
+		return err
+	} else if d == nil {
+		return errors.New("no registered state to conclude")
+	}
+	params := channelParamsToEthParams(p)
+	err = a.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return a.contract.Conclude(opts, params, d.state, d.timeout, d.phase)
+	})
+	return errors.WithMessage(a.concludedConcurrently(ctx, p.ID(), err), "concluding dispute")
+}
+
+// concludedConcurrently returns nil if the conclusion failed with err because
+// a peer concluded the channel in the meantime. Otherwise, err is returned.
+func (a *Adjudicator) concludedConcurrently(ctx context.Context, id channel.ID, err error) error {
+	if err == nil {
+		return nil
+	}
+	if concluded, cerr := a.isConcluded(ctx, id); cerr != nil {
+		return cerr
+	} else if concluded {
+		return nil
+	}
+	return err
+}
+
+// isConcluded returns whether the channel was concluded on the Adjudicator
+// contract, with or without a dispute.
+func (a *Adjudicator) isConcluded(ctx context.Context, id channel.ID) (bool, error) {
+	opts := &bind.FilterOpts{Start: uint64(1), Context: ctx}
+	concluded, err := a.contract.FilterConcluded(opts, [][32]byte{id})
+	if err != nil {
+		return false, errors.Wrap(err, "filtering Concluded events")
+	}
+	defer concluded.Close()
+	if concluded.Next() {
+		return true, nil
+	}
+	final, err := a.contract.FilterFinalConcluded(opts, [][32]byte{id})
+	if err != nil {
+		return false, errors.Wrap(err, "filtering FinalConcluded events")
+	}
+	defer final.Close()
+	return final.Next(), nil
+}
+
+// dispute returns the latest dispute of the channel or nil, if there is none.
+// The contract only stores a hash of the dispute, so the registered state is
+// decoded from the calldata of the transaction that stored the dispute.
+func (a *Adjudicator) dispute(ctx context.Context, id channel.ID) (*dispute, error) {
+	opts := &bind.FilterOpts{Start: uint64(1), Context: ctx}
+	iter, err := a.contract.FilterStored(opts, [][32]byte{id})
+	if err != nil {
+		return nil, errors.Wrap(err, "filtering Stored events")
+	}
+	defer iter.Close()
+	var stored *adjudicator.AdjudicatorStored
+	for iter.Next() {
+		stored = iter.Event
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrap(err, "iterating Stored events")
+	}
+	if stored == nil {
+		return nil, nil
+	}
+	return a.decodeDispute(ctx, stored)
+}
+
+// decodeDispute decodes the stored state from the transaction that emitted
+// the Stored event.
+func (a *Adjudicator) decodeDispute(ctx context.Context, stored *adjudicator.AdjudicatorStored) (*dispute, error) {
+	call, err := a.decodeCall(ctx, stored.Raw.TxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &dispute{state: call.state, timeout: stored.Timeout, phase: phaseDispute}
+	switch call.method {
+	case "register", "refute":
+	case "progress":
+		d.phase = phaseForceExec
+	default:
+		return nil, errors.Errorf("unexpected dispute method %s", call.method)
+	}
+	return d, nil
+}
+
+// adjudicatorCall is a decoded call of an Adjudicator contract method.
+type adjudicatorCall struct {
+	method string
+	params adjudicator.ChannelParams
+	state  adjudicator.ChannelState // new state of the call
+	sigs   [][]byte                 // signatures of all participants, if any
+}
+
+// decodeCall decodes the adjudicator call of the transaction with the given
+// hash.
+func (a *Adjudicator) decodeCall(ctx context.Context, txHash common.Hash) (*adjudicatorCall, error) {
+	tx, _, err := a.TransactionByHash(ctx, txHash)
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching adjudicator transaction")
+	}
+	data := tx.Data()
+	if len(data) < 4 {
+		return nil, errors.New("adjudicator transaction has no calldata")
+	}
+	method, err := adjudicatorABI.MethodById(data[:4])
+	if err != nil {
+		return nil, errors.Wrap(err, "transaction is no adjudicator call")
+	}
+
+	// args holds the inputs of all adjudicator methods.
+	var args struct {
+		Params       adjudicator.ChannelParams
+		StateOld     adjudicator.ChannelState
+		Timeout      *big.Int
+		DisputePhase uint8
+		State        adjudicator.ChannelState
+		ActorIdx     *big.Int
+		Sig          []byte
+		Sigs         [][]byte
+	}
+	if err := method.Inputs.Unpack(&args, data[4:]); err != nil {
+		return nil, errors.Wrapf(err, "decoding %s calldata", method.Name)
+	}
+	return &adjudicatorCall{method: method.Name, params: args.Params, state: args.State, sigs: args.Sigs}, nil
+}
+
+// withdrawAsset withdraws the holdings of participant req.Idx from the asset
+// holder at the given address. Nothing is withdrawn if the holdings are empty,
+// e.g., because they were withdrawn already. Afterwards, it verifies that the
+// holdings are empty.
+func (a *Adjudicator) withdrawAsset(ctx context.Context, req channel.AdjudicatorReq, assetAddr common.Address) error {
+	asset, err := assets.NewAssetHolder(assetAddr, a)
+	if err != nil {
+		return errors.Wrap(err, "connecting to assetholder")
+	}
+	fundingID := calcFundingIDs([]perunwallet.Address{req.Acc.Address()}, req.Params.ID())[0]
+	bal, err := asset.Holdings(&bind.CallOpts{Context: ctx}, fundingID)
+	if err != nil {
+		return errors.Wrap(err, "querying holdings")
+	}
+	if bal.Sign() == 0 {
+		a.log.WithField("channel", req.Params.ID()).Debug("Nothing to withdraw.")
+		return nil
+	}
+
+	auth := assets.AssetHolderWithdrawalAuth{
+		ChannelID:   req.Params.ID(),
+		Participant: req.Acc.Address().(*wallet.Address).Address,
+		Receiver:    a.receiver,
+		Amount:      bal,
+	}
+	enc, err := encodeWithdrawalAuth(&auth)
+	if err != nil {
+		return errors.WithMessage(err, "encoding withdrawal authorization")
+	}
+	sig, err := req.Acc.SignData(enc)
+	if err != nil {
+		return errors.WithMessage(err, "signing withdrawal authorization")
+	}
+	if err := a.transact(ctx, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return asset.Withdraw(opts, auth, sig)
+	}); err != nil {
+		return err
+	}
+
+	// The asset holder must have paid out the full holdings, independent of
+	// the kind of asset.
+	if bal, err = asset.Holdings(&bind.CallOpts{Context: ctx}, fundingID); err != nil {
+		return errors.Wrap(err, "querying holdings after withdrawal")
+	} else if bal.Sign() != 0 {
+		return errors.Errorf("holdings of %v left after withdrawal", bal)
+	}
+	return nil
+}
+
+// transact sends the transaction created by send and waits until it is mined.
+func (a *Adjudicator) transact(ctx context.Context, send func(*bind.TransactOpts) (*types.Transaction, error)) error {
+	tx, err := func() (*types.Transaction, error) {
+		// Lock the adjudicator for correct nonce usage.
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		opts, err := a.newTransactor(ctx, big.NewInt(0), GasLimit)
+		if err != nil {
+			return nil, errors.WithMessage(err, "creating transactor")
+		}
+		tx, err := send(opts)
+		return tx, errors.WithStack(err)
+	}()
+	if err != nil {
+		return err
+	}
+	if err := execSuccessful(ctx, a.ContractBackend, tx); err != nil {
+		return errors.WithMessage(err, "mining transaction")
+	}
+	a.log.Debugf("Transaction with txHash: [%v] executed successful", tx.Hash().Hex())
+	return nil
+}
+
+// encodeWithdrawalAuth encodes the withdrawal authorization as with
+// abi.encode() in the smart contracts.
+func encodeWithdrawalAuth(auth *assets.AssetHolderWithdrawalAuth) ([]byte, error) {
+	args := abi.Arguments{
+		{Type: abiBytes32},
+		{Type: abiAddress},
+		{Type: abiAddress},
+		{Type: abiUint256},
+	}
+	enc, err := args.Pack(
+		auth.ChannelID,
+		auth.Participant,
+		auth.Receiver,
+		auth.Amount,
+	)
+	return enc, errors.WithStack(err)
+}
+
+// sigsToBytes converts signatures to the byte slices expected by the
+// contracts.
+func sigsToBytes(sigs []perunwallet.Sig) [][]byte {
+	bs := make([][]byte, len(sigs))
+	for i, sig := range sigs {
+		bs[i] = sig
+	}
+	return bs
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/channel/test"
+	"perun.network/go-perun/backend/ethereum/wallet"
+	ethwallettest "perun.network/go-perun/backend/ethereum/wallet/test"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	perunwallet "perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// adjudicatorSetup is a funded two-party channel with an Adjudicator for
+// each participant.
+type adjudicatorSetup struct {
+	sim       *test.SimulatedBackend
+	params    *channel.Params
+	accs      []*wallet.Account
+	adjs      []*Adjudicator
+	receivers []common.Address
+	funders   []*Funder
+	funded    *channel.Allocation
+}
+
+func newAdjudicatorSetup(ctx context.Context, t *testing.T, rng *rand.Rand, challengeDuration uint64) *adjudicatorSetup {
+	s := newUnfundedAdjudicatorSetup(ctx, t, rng, challengeDuration)
+	errs := make(chan error, len(s.funders))
+	for i, funder := range s.funders {
+		go func(i int, funder *Funder) {
+			errs <- funder.Fund(ctx, s.fundingReq(i))
+		}(i, funder)
+	}
+	for range s.funders {
+		require.NoError(t, <-errs)
+	}
+	return s
+}
+
+// newUnfundedAdjudicatorSetup creates an adjudicatorSetup whose channel is not
+// funded yet.
+func newUnfundedAdjudicatorSetup(ctx context.Context, t *testing.T, rng *rand.Rand, challengeDuration uint64) *adjudicatorSetup {
+	s := &adjudicatorSetup{sim: test.NewSimulatedBackend()}
+	ks := ethwallettest.GetKeystore()
+	deployAccount := wallettest.NewRandomAccount(rng).(*wallet.Account).Account
+	s.sim.FundAddress(ctx, deployAccount.Address)
+	cb := NewContractBackend(s.sim, ks, deployAccount)
+	adjAddr, err := DeployAdjudicator(ctx, cb)
+	require.NoError(t, err)
+	assetETH, err := DeployETHAssetholder(ctx, cb, adjAddr)
+	require.NoError(t, err)
+
+	const n = 2
+	parts := make([]perunwallet.Address, n)
+	for i := 0; i < n; i++ {
+		acc := wallettest.NewRandomAccount(rng).(*wallet.Account)
+		s.sim.FundAddress(ctx, acc.Account.Address)
+		cb := NewContractBackend(s.sim, ks, acc.Account)
+		receiver := wallettest.NewRandomAddress(rng).(*wallet.Address).Address
+		parts[i] = acc.Address()
+		s.accs = append(s.accs, acc)
+		s.adjs = append(s.adjs, NewAdjudicator(cb, adjAddr, receiver))
+		s.receivers = append(s.receivers, receiver)
+		s.funders = append(s.funders, NewETHFunder(cb, assetETH))
+	}
+
+	app := channeltest.NewRandomApp(rng)
+	s.params = channel.NewParamsUnsafe(challengeDuration, parts, app.Def(), big.NewInt(rng.Int63()))
+	s.funded = newValidAllocation(parts, assetETH)
+	return s
+}
+
+func (s *adjudicatorSetup) fundingReq(idx int) channel.FundingReq {
+	return channel.FundingReq{Params: s.params, Allocation: s.funded, Idx: channel.Index(idx)}
+}
+
+// tx returns a transaction on the funded allocation, signed by all
+// participants.
+func (s *adjudicatorSetup) tx(t *testing.T, version uint64, isFinal bool) channel.Transaction {
+	state := &channel.State{
+		ID:         s.params.ID(),
+		Version:    version,
+		App:        s.params.App,
+		Allocation: s.funded.Clone(),
+		Data:       channeltest.NewRandomData(rand.New(rand.NewSource(int64(version)))),
+		IsFinal:    isFinal,
+	}
+	tx := channel.Transaction{State: state, Sigs: make([]perunwallet.Sig, len(s.accs))}
+	for i, acc := range s.accs {
+		sig, err := Sign(acc, s.params, state)
+		require.NoError(t, err)
+		tx.Sigs[i] = sig
+	}
+	return tx
+}
+
+func (s *adjudicatorSetup) req(idx int, tx channel.Transaction) channel.AdjudicatorReq {
+	return channel.AdjudicatorReq{Params: s.params, Acc: s.accs[idx], Tx: tx, Idx: channel.Index(idx)}
+}
+
+// assertWithdrawn asserts that every receiver got the funded balance of its
+// participant.
+func (s *adjudicatorSetup) assertWithdrawn(ctx context.Context, t *testing.T) {
+	for i, receiver := range s.receivers {
+		bal, err := s.sim.BalanceAt(ctx, receiver, nil)
+		require.NoError(t, err)
+		assert.Equal(t, s.funded.OfParts[i][0], bal, "balance of receiver %d", i)
+	}
+}
+
+func TestAdjudicator_Final(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad1))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	tx := s.tx(t, 3, true)
+	reg, err := s.adjs[0].Register(ctx, s.req(0, tx))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), reg.Version)
+	// The channel is concluded already, so this is a no-op.
+	_, err = s.adjs[1].Register(ctx, s.req(1, tx))
+	require.NoError(t, err)
+
+	for i, adj := range s.adjs {
+		require.NoError(t, adj.Withdraw(ctx, s.req(i, tx)))
+		require.NoError(t, adj.Withdraw(ctx, s.req(i, tx)), "withdrawing twice")
+	}
+	s.assertWithdrawn(ctx, t)
+}
+
+func TestAdjudicator_Dispute(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad2))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	sub, err := s.adjs[1].SubscribeRegistered(ctx, s.params)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	old, tx := s.tx(t, 1, false), s.tx(t, 2, false)
+	reg, err := s.adjs[0].Register(ctx, s.req(0, old))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), reg.Version)
+	ev := sub.Next()
+	require.NotNil(t, ev, sub.Err())
+	assert.Equal(t, uint64(1), ev.Version)
+	assert.Equal(t, reg.Timeout, ev.Timeout)
+
+	// Bob refutes with the newer state.
+	reg, err = s.adjs[1].Register(ctx, s.req(1, tx))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), reg.Version)
+	ev = sub.Next()
+	require.NotNil(t, ev, sub.Err())
+	assert.Equal(t, uint64(2), ev.Version)
+
+	// Registering the old state again reports the newer registered state.
+	reg, err = s.adjs[0].Register(ctx, s.req(0, old))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), reg.Version)
+
+	// The subscription also returns the newest past event.
+	pastSub, err := s.adjs[0].SubscribeRegistered(ctx, s.params)
+	require.NoError(t, err)
+	defer pastSub.Close()
+	ev = pastSub.Next()
+	require.NotNil(t, ev, pastSub.Err())
+	assert.Equal(t, uint64(2), ev.Version)
+
+	// The dispute can only be concluded after the challenge duration.
+	assert.Error(t, s.adjs[0].Withdraw(ctx, s.req(0, tx)), "withdrawing before timeout")
+	require.NoError(t, s.sim.AdjustTime(2*time.Minute))
+	s.sim.Commit()
+	for i, adj := range s.adjs {
+		require.NoError(t, adj.Withdraw(ctx, s.req(i, tx)))
+	}
+	s.assertWithdrawn(ctx, t)
+
+	require.NoError(t, sub.Close())
+	assert.Nil(t, sub.Next())
+	assert.NoError(t, sub.Err())
+}
+
+func TestAdjudicator_SubscribeEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad5))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	sub, err := s.adjs[1].SubscribeEvents(ctx, s.params)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	old, tx := s.tx(t, 1, false), s.tx(t, 2, false)
+	reg, err := s.adjs[0].Register(ctx, s.req(0, old))
+	require.NoError(t, err)
+	ev := sub.Next()
+	require.NotNil(t, ev, sub.Err())
+	assert.Equal(t, &channel.RegisteredEvent{ID: s.params.ID(), Version: 1, Timeout: reg.Timeout}, ev)
+
+	_, err = s.adjs[1].Register(ctx, s.req(1, tx))
+	require.NoError(t, err)
+	ev = sub.Next()
+	require.NotNil(t, ev, sub.Err())
+	assert.Equal(t, uint64(2), ev.(*channel.RegisteredEvent).Version)
+
+	require.NoError(t, s.sim.AdjustTime(2*time.Minute))
+	s.sim.Commit()
+	require.NoError(t, s.adjs[0].Withdraw(ctx, s.req(0, tx)))
+	ev = sub.Next()
+	require.NotNil(t, ev, sub.Err())
+	assert.Equal(t, &channel.ConcludedEvent{ID: s.params.ID(), Version: 2}, ev)
+
+	// The subscription also returns the newest past event.
+	pastSub, err := s.adjs[0].SubscribeEvents(ctx, s.params)
+	require.NoError(t, err)
+	defer pastSub.Close()
+	assert.Equal(t, &channel.ConcludedEvent{ID: s.params.ID(), Version: 2}, pastSub.Next())
+
+	require.NoError(t, sub.Close())
+	assert.Nil(t, sub.Next())
+	assert.NoError(t, sub.Err())
+}
+
+func TestAdjudicator_Progress(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad3))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	tx := s.tx(t, 1, false)
+	next := tx.State.Clone()
+	next.Version++
+	sig, err := Sign(s.accs[0], s.params, next)
+	require.NoError(t, err)
+	req := channel.ProgressReq{AdjudicatorReq: s.req(0, tx), NewState: next, Sig: sig}
+
+	_, err = s.adjs[0].Progress(ctx, req)
+	assert.Error(t, err, "progressing without registered state")
+
+	_, err = s.adjs[0].Register(ctx, s.req(0, tx))
+	require.NoError(t, err)
+	_, err = s.adjs[0].Progress(ctx, req)
+	assert.Error(t, err, "progressing before timeout")
+}
+
+func TestAdjudicator_RecoverDeposit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad4))
+	s := newUnfundedAdjudicatorSetup(ctx, t, rng, 60)
+
+	// Only Alice funds the channel.
+	fundCtx, fundCancel := context.WithTimeout(ctx, time.Second)
+	defer fundCancel()
+	err := s.funders[0].Fund(fundCtx, s.fundingReq(0))
+	require.True(t, channel.IsFundingTimeoutError(err), "funding should time out: %v", err)
+
+	// Alice recovers her deposit with the initial state.
+	initial := s.tx(t, 0, false)
+	_, err = s.adjs[0].Register(ctx, s.req(0, initial))
+	require.NoError(t, err)
+	require.NoError(t, s.sim.AdjustTime(2*time.Minute))
+	s.sim.Commit()
+	require.NoError(t, s.adjs[0].Withdraw(ctx, s.req(0, initial)))
+
+	bal, err := s.sim.BalanceAt(ctx, s.receivers[0], nil)
+	require.NoError(t, err)
+	assert.Equal(t, s.funded.OfParts[0][0], bal, "recovered deposit")
+}
+
+func TestAdjudicator_Generic(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xad5))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	accs := make([]perunwallet.Account, len(s.accs))
+	for i, acc := range s.accs {
+		accs[i] = acc
+	}
+	// The app of the channel has no contract, so it cannot be progressed.
+	channeltest.GenericDisputeTest(t, rng, &channeltest.DisputeSetup{
+		Adjudicator: s.adjs[0],
+		Accounts:    accs,
+		Params:      s.params,
+		State:       s.tx(t, 0, false).State,
+		Timeout:     timeout,
+		Wait: func(context.Context, time.Time) error {
+			if err := s.sim.AdjustTime(2 * time.Minute); err != nil {
+				return err
+			}
+			s.sim.Commit()
+			return nil
+		},
+	})
+}
@@ -215,6 +215,57 @@ func encodeSubAlloc(sub *adjudicator.ChannelSubAlloc) ([]byte, error) {
 }
 
 // assetToCommonAddresses converts an array of io.Encoder's to common.Address's.
+// ethParamsToChannelParams converts a ChannelParams struct to a
+// channel.Params. The app is looked up by its definition.
+func ethParamsToChannelParams(p adjudicator.ChannelParams) (*channel.Params, error) {
+	if !p.ChallengeDuration.IsUint64() {
+		return nil, errors.New("challenge duration overflows uint64")
+	}
+	parts := make([]perunwallet.Address, len(p.Participants))
+	for i, part := range p.Participants {
+		parts[i] = &wallet.Address{Address: part}
+	}
+	return channel.NewParams(p.ChallengeDuration.Uint64(), parts, &wallet.Address{Address: p.App}, p.Nonce)
+}
+
+// ethStateToChannelState converts a ChannelState struct of the channel with
+// the given parameters to a channel.State.
+func ethStateToChannelState(params *channel.Params, s adjudicator.ChannelState) (*channel.State, error) {
+	assets := make([]channel.Asset, len(s.Outcome.Assets))
+	for i, asset := range s.Outcome.Assets {
+		assets[i] = &Asset{Address: asset}
+	}
+	if len(s.Outcome.Balances) != len(assets) {
+		return nil, errors.New("invalid allocation dimensions")
+	}
+	ofParts := make([][]channel.Bal, len(params.Parts))
+	for i := range ofParts {
+		ofParts[i] = make([]channel.Bal, len(assets))
+		for k, bals := range s.Outcome.Balances {
+			if len(bals) != len(params.Parts) {
+				return nil, errors.New("invalid allocation dimensions")
+			}
+			ofParts[i][k] = bals[i]
+		}
+	}
+	locked := make([]channel.SubAlloc, len(s.Outcome.Locked))
+	for i, sub := range s.Outcome.Locked {
+		locked[i] = channel.SubAlloc{ID: sub.ID, Bals: sub.Balances}
+	}
+	data, err := params.App.DecodeData(bytes.NewReader(s.AppData))
+	if err != nil {
+		return nil, errors.WithMessage(err, "decoding app data")
+	}
+	return &channel.State{
+		ID:         s.ChannelID,
+		Version:    s.Version,
+		App:        params.App,
+		Allocation: channel.Allocation{Assets: assets, OfParts: ofParts, Locked: locked},
+		Data:       data,
+		IsFinal:    s.IsFinal,
+	}, nil
+}
+
 func assetToCommonAddresses(addr []channel.Asset) []common.Address {
 	cAddrs := make([]common.Address, len(addr))
 	for i, part := range addr {
@@ -7,11 +7,11 @@ package channel
 
 import (
 	"context"
-	"log"
 	"math/big"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
@@ -29,12 +29,18 @@ const startBlockOffset = 100
 // GasLimit is the max amount of gas we want to send per transaction.
 const GasLimit = 500000
 
+// confirmationPollInterval is the interval in which the newest block is
+// queried while waiting for the confirmations of an event.
+var confirmationPollInterval = 200 * time.Millisecond
+
 // ContractInterface provides all functions needed by an ethereum backend.
 // Both test.SimulatedBackend and ethclient.Client implement this interface.
 type ContractInterface interface {
 	bind.ContractBackend
 	BlockByNumber(context.Context, *big.Int) (*types.Block, error)
 	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, txHash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
 }
 
 // ContractBackend adds a keystore and an on-chain account to the ContractInterface.
@@ -43,6 +49,10 @@ type ContractBackend struct {
 	ContractInterface
 	ks      *keystore.KeyStore
 	account *accounts.Account
+	// confirmations is the number of blocks, including the block of an event,
+	// after which the event is regarded as final.
+	confirmations uint64
+	txm           *TxManager // optional
 }
 
 // NewContractBackend creates a new ContractBackend with the given parameters.
@@ -54,6 +64,79 @@ func NewContractBackend(cf ContractInterface, ks *keystore.KeyStore, acc *accoun
 	}
 }
 
+// SetConfirmations sets the confirmation depth, i.e., the number of blocks,
+// including the block of an event, that must be mined before the Funder and
+// the Adjudicator regard the event as final. Then, it is checked again that
+// the event is still on the chain. Deposits and adjudicator events that are
+// removed by a chain reorganization before they are final are dropped, see
+// Funder.Fund and Adjudicator.SubscribeEvents. The default of 0 or 1 regards
+// events as final as soon as they are mined.
+//
+// SetConfirmations has to be called before the ContractBackend is passed to
+// NewFunder or NewAdjudicator.
+func (c *ContractBackend) SetConfirmations(n uint64) {
+	c.confirmations = n
+}
+
+// SetTxManager sets the TxManager that determines the gas prices of the
+// transactions of the Funder, the Adjudicator and the Depositors, and
+// resubmits them if they are not mined in time. Without a TxManager, the gas
+// price that the node suggests is used and transactions are never
+// resubmitted.
+//
+// SetTxManager has to be called before the ContractBackend is passed to
+// NewFunder or NewAdjudicator.
+func (c *ContractBackend) SetTxManager(m *TxManager) {
+	c.txm = m
+}
+
+// waitMined waits until the transaction, or a resubmission of it by the
+// TxManager, is mined and returns its receipt.
+func (c *ContractBackend) waitMined(ctx context.Context, tx *types.Transaction) (*types.Receipt, error) {
+	if c.txm == nil {
+		return bind.WaitMined(ctx, c, tx)
+	}
+	return c.txm.waitMined(ctx, c, c.account.Address, func(tx *types.Transaction) (*types.Transaction, error) {
+		return c.ks.SignTx(*c.account, tx, nil) // legacy signer, like the bindings
+	}, tx)
+}
+
+// confirmed waits until the log l has the configured number of confirmations
+// and returns whether it is still on the chain. Removed logs are never
+// confirmed.
+func (c *ContractBackend) confirmed(ctx context.Context, l *types.Log) (bool, error) {
+	if l.Removed {
+		return false, nil
+	}
+	if c.confirmations <= 1 {
+		return true, nil
+	}
+
+	final := l.BlockNumber + c.confirmations - 1
+	for {
+		head, err := c.BlockByNumber(ctx, nil)
+		if err != nil {
+			return false, errors.Wrap(err, "querying newest block")
+		}
+		if head.NumberU64() >= final {
+			break
+		}
+		select {
+		case <-time.After(confirmationPollInterval):
+		case <-ctx.Done():
+			return false, errors.Wrap(ctx.Err(), "waiting for confirmations")
+		}
+	}
+
+	receipt, err := c.TransactionReceipt(ctx, l.TxHash)
+	if err == ethereum.NotFound || (err == nil && receipt == nil) {
+		return false, nil // transaction was reorged out
+	} else if err != nil {
+		return false, errors.Wrap(err, "querying transaction receipt")
+	}
+	return receipt.BlockHash == l.BlockHash, nil
+}
+
 func (c *ContractBackend) newWatchOpts(ctx context.Context) (*bind.WatchOpts, error) {
 	latestBlock, err := c.BlockByNumber(ctx, nil)
 	if err != nil {
@@ -73,13 +156,22 @@ func (c *ContractBackend) newWatchOpts(ctx context.Context) (*bind.WatchOpts, er
 	}, nil
 }
 
+// gasPrice returns the gas price of new transactions, which the TxManager
+// determines, if there is one.
+func (c *ContractBackend) gasPrice(ctx context.Context) (*big.Int, error) {
+	if c.txm != nil {
+		return c.txm.pricer.GasPrice(ctx)
+	}
+	return c.SuggestGasPrice(ctx)
+}
+
 func (c *ContractBackend) newTransactor(ctx context.Context, valueWei *big.Int, gasLimit uint64) (*bind.TransactOpts, error) {
 	nonce, err := c.PendingNonceAt(ctx, c.account.Address)
 	if err != nil {
 		return nil, err
 	}
 
-	gasPrice, err := c.SuggestGasPrice(ctx)
+	gasPrice, err := c.gasPrice(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -97,16 +189,13 @@ func (c *ContractBackend) newTransactor(ctx context.Context, valueWei *big.Int,
 	return auth, nil
 }
 
+// calcFundingIDs calculates the funding IDs of the participants like the asset
+// holder contracts do, as keccak256(abi.encodePacked(channelID, participant)).
 func calcFundingIDs(participants []perunwallet.Address, channelID channel.ID) [][32]byte {
 	partIDs := make([][32]byte, len(participants))
-	args := abi.Arguments{{Type: abibytes32}, {Type: abiaddress}}
 	for idx, pID := range participants {
 		address := pID.(*wallet.Address)
-		bytes, err := args.Pack(channelID, address.Address)
-		if err != nil {
-			log.Panicf("error packing values: %v", err)
-		}
-		partIDs[idx] = crypto.Keccak256Hash(bytes)
+		partIDs[idx] = crypto.Keccak256Hash(channelID[:], address.Address.Bytes())
 	}
 	return partIDs
 }
@@ -8,10 +8,15 @@ package channel
 import (
 	"context"
 	"math/big"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"perun.network/go-perun/backend/ethereum/wallet"
 	perunwallet "perun.network/go-perun/wallet"
 )
@@ -28,11 +33,11 @@ func Test_calcFundingIDs(t *testing.T) {
 		{"Test empty array, non-empty channelID", []perunwallet.Address{}, [32]byte{1}, make([][32]byte, 0)},
 		// Tests based on actual data from contracts.
 		{"Test non-empty array, empty channelID", []perunwallet.Address{&wallet.Address{}},
-			[32]byte{}, [][32]byte{{173, 50, 40, 182, 118, 247, 211, 205, 66, 132, 165, 68, 63, 23, 241, 150, 43, 54, 228, 145, 179, 10, 64, 178, 64, 88, 73, 229, 151, 186, 95, 181}}},
+			[32]byte{}, [][32]byte{{168, 109, 84, 233, 170, 180, 26, 229, 229, 32, 255, 0, 98, 255, 27, 76, 189, 11, 33, 146, 187, 1, 8, 10, 5, 139, 177, 112, 216, 78, 100, 87}}},
 		{"Test non-empty array, non-empty channelID", []perunwallet.Address{&wallet.Address{}},
-			[32]byte{1}, [][32]byte{{130, 172, 39, 157, 178, 106, 32, 109, 155, 165, 169, 76, 7, 255, 148, 10, 234, 75, 59, 253, 232, 130, 14, 201, 95, 78, 250, 10, 207, 208, 213, 188}}},
+			[32]byte{1}, [][32]byte{{197, 235, 110, 136, 77, 87, 149, 211, 32, 2, 235, 174, 133, 239, 122, 90, 129, 250, 136, 168, 20, 213, 223, 151, 82, 82, 248, 206, 148, 192, 251, 150}}},
 		{"Test non-empty array, non-empty channelID", []perunwallet.Address{&wallet.Address{Address: common.BytesToAddress([]byte{})}},
-			[32]byte{1}, [][32]byte{{130, 172, 39, 157, 178, 106, 32, 109, 155, 165, 169, 76, 7, 255, 148, 10, 234, 75, 59, 253, 232, 130, 14, 201, 95, 78, 250, 10, 207, 208, 213, 188}}},
+			[32]byte{1}, [][32]byte{{197, 235, 110, 136, 77, 87, 149, 211, 32, 2, 235, 174, 133, 239, 122, 90, 129, 250, 136, 168, 20, 213, 223, 151, 82, 82, 248, 206, 148, 192, 251, 150}}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -48,7 +53,7 @@ func Test_NewTransactor(t *testing.T) {
 		func() { f.newTransactor(context.Background(), big.NewInt(0), uint64(0)) },
 		"Creating transactor on invalid backend should fail")
 	// Test on valid contract backend
-	sf := newSimulatedFunder(t)
+	sf, _ := newSimulatedFunder(t)
 	f = &sf.ContractBackend
 	tests := []struct {
 		name     string
@@ -75,8 +80,9 @@ func Test_NewTransactor(t *testing.T) {
 func Test_NewWatchOpts(t *testing.T) {
 	f := &ContractBackend{}
 	assert.Panics(t, func() { f.newWatchOpts(context.Background()) }, "Creating watchopts on invalid backend should panic")
-	sf := newSimulatedFunder(t)
-	f = &ContractBackend{sf.ContractBackend, sf.ks, sf.account}
+	sf, _ := newSimulatedFunder(t)
+	cb := NewContractBackend(sf.ContractBackend, sf.ks, sf.account)
+	f = &cb
 	watchOpts, err := f.newWatchOpts(context.Background())
 	assert.NoError(t, err, "Creating watchopts on valid ContractBackend should succeed")
 	assert.Equal(t, context.Background(), watchOpts.Context, "context should be set")
@@ -88,3 +94,88 @@ func Test_NewWatchOpts(t *testing.T) {
 	assert.Equal(t, context.WithValue(context.Background(), &key, "bar"), watchOpts.Context, "context should be set")
 	assert.Equal(t, uint64(1), *watchOpts.Start, "startblock should be 1")
 }
+
+// stubChain is a ContractInterface with a settable newest block and
+// transaction receipts.
+type stubChain struct {
+	ContractInterface // nil, not used
+	mtx               sync.Mutex
+	head              uint64
+	receipts          map[common.Hash]*types.Receipt
+}
+
+func (c *stubChain) BlockByNumber(context.Context, *big.Int) (*types.Block, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(c.head)}), nil
+}
+
+func (c *stubChain) TransactionReceipt(_ context.Context, tx common.Hash) (*types.Receipt, error) {
+	if r, ok := c.receipts[tx]; ok {
+		return r, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+func (c *stubChain) mine() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.head++
+}
+
+func TestContractBackend_confirmed(t *testing.T) {
+	defer func(old time.Duration) { confirmationPollInterval = old }(confirmationPollInterval)
+	confirmationPollInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	tx, block := common.Hash{1}, common.Hash{2}
+	chain := &stubChain{
+		head:     10,
+		receipts: map[common.Hash]*types.Receipt{tx: {BlockHash: block}},
+	}
+	cb := NewContractBackend(chain, nil, nil)
+	l := types.Log{TxHash: tx, BlockHash: block, BlockNumber: 10}
+
+	ok, err := cb.confirmed(ctx, &l)
+	require.NoError(t, err)
+	assert.True(t, ok, "without confirmation depth")
+	removed := l
+	removed.Removed = true
+	ok, err = cb.confirmed(ctx, &removed)
+	require.NoError(t, err)
+	assert.False(t, ok, "removed log")
+
+	cb.SetConfirmations(3)
+	done := make(chan bool, 1)
+	go func() {
+		ok, err := cb.confirmed(ctx, &l)
+		assert.NoError(t, err)
+		done <- ok
+	}()
+	chain.mine()
+	select {
+	case <-done:
+		t.Fatal("confirmed after two blocks")
+	case <-time.After(50 * time.Millisecond):
+	}
+	chain.mine()
+	assert.True(t, <-done, "confirmed after three blocks")
+
+	reorged := l
+	reorged.BlockHash = common.Hash{3}
+	ok, err = cb.confirmed(ctx, &reorged)
+	require.NoError(t, err)
+	assert.False(t, ok, "transaction mined in another block")
+	dropped := l
+	dropped.TxHash = common.Hash{4}
+	ok, err = cb.confirmed(ctx, &dropped)
+	require.NoError(t, err)
+	assert.False(t, ok, "transaction not mined")
+
+	pending := l
+	pending.BlockNumber = 100
+	cancel()
+	_, err = cb.confirmed(ctx, &pending)
+	assert.Error(t, err, "context done")
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+var _ channel.CostEstimator = (*Adjudicator)(nil)
+
+// EstimateDisputeCosts estimates the costs in wei of a dispute of the channel
+// of the request, see channel.CostEstimator, at the current gas price.
+//
+// The registration, or the conclusion of a final state, is simulated with the
+// node's gas estimation. A progression, the conclusion of a registered state
+// and the withdrawals can only be simulated after the registration timed out,
+// so they are estimated by the GasLimit of the transactions, which bounds
+// their costs. Required accounts for the GasLimit of every transaction of the
+// registration and withdrawal, since the node reserves it upfront.
+func (a *Adjudicator) EstimateDisputeCosts(ctx context.Context, req channel.AdjudicatorReq) (*channel.DisputeCosts, error) {
+	price, err := a.gasPrice(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "querying gas price")
+	}
+
+	registerGas, err := a.estimateRegisterGas(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	// A registered state has to be concluded before the withdrawals.
+	numWithdrawals := uint64(len(req.Tx.Allocation.Assets))
+	if !req.Tx.IsFinal {
+		numWithdrawals++
+	}
+
+	balance, err := a.BalanceAt(ctx, a.account.Address, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying balance")
+	}
+	wei := func(gas uint64) *big.Int {
+		return new(big.Int).Mul(new(big.Int).SetUint64(gas), price)
+	}
+	return &channel.DisputeCosts{
+		Register: wei(registerGas),
+		Progress: wei(GasLimit),
+		Withdraw: wei(numWithdrawals * GasLimit),
+		Required: wei((1 + numWithdrawals) * GasLimit),
+		Balance:  balance,
+	}, nil
+}
+
+// estimateRegisterGas estimates the gas of registering the state of the
+// request, or of concluding it if it is final. If the call cannot be simulated,
+// e.g., because the channel was registered already, the GasLimit is returned.
+func (a *Adjudicator) estimateRegisterGas(ctx context.Context, req channel.AdjudicatorReq) (uint64, error) {
+	params, state := channelParamsToEthParams(req.Params), channelStateToEthState(req.Tx.State)
+	sigs := sigsToBytes(req.Tx.Sigs)
+	method := "register"
+	if req.Tx.IsFinal {
+		method = "concludeFinal"
+	}
+	data, err := adjudicatorABI.Pack(method, params, state, sigs)
+	if err != nil {
+		return 0, errors.Wrapf(err, "packing %s call", method)
+	}
+
+	gas, err := a.EstimateGas(ctx, ethereum.CallMsg{From: a.account.Address, To: &a.address, Data: data})
+	if err != nil {
+		a.log.WithField("channel", req.Params.ID()).Debugf("Simulating %s failed, using gas limit: %v", method, err)
+		return GasLimit, nil
+	}
+	return gas, nil
+}
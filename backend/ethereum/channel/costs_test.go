@@ -0,0 +1,51 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdjudicator_EstimateDisputeCosts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0xc057))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+	price, err := s.adjs[0].gasPrice(ctx)
+	require.NoError(t, err)
+	gasLimitCosts := new(big.Int).Mul(big.NewInt(GasLimit), price)
+
+	req := s.req(0, s.tx(t, 1, false))
+	costs, err := s.adjs[0].EstimateDisputeCosts(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, costs.Register.Sign() > 0, "registration costs")
+	assert.True(t, costs.Register.Cmp(gasLimitCosts) < 0, "simulated registration")
+	assert.Equal(t, gasLimitCosts, costs.Progress)
+	assert.Equal(t, new(big.Int).Mul(gasLimitCosts, big.NewInt(2)), costs.Withdraw, "conclusion and one withdrawal")
+	assert.Equal(t, new(big.Int).Mul(gasLimitCosts, big.NewInt(3)), costs.Required)
+	bal, err := s.sim.BalanceAt(ctx, s.adjs[0].account.Address, nil)
+	require.NoError(t, err)
+	assert.Equal(t, bal, costs.Balance)
+	assert.True(t, costs.Sufficient())
+
+	// Final states are concluded directly.
+	costs, err = s.adjs[0].EstimateDisputeCosts(ctx, s.req(0, s.tx(t, 2, true)))
+	require.NoError(t, err)
+	assert.Equal(t, gasLimitCosts, costs.Withdraw, "one withdrawal")
+
+	// A registration that cannot be simulated is bounded by the gas limit.
+	_, err = s.adjs[0].Register(ctx, req)
+	require.NoError(t, err)
+	costs, err = s.adjs[0].EstimateDisputeCosts(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, gasLimitCosts, costs.Register)
+}
@@ -60,7 +60,7 @@ func deployedSuccessful(ctx context.Context, backend ContractBackend, tx *types.
 }
 
 func execSuccessful(ctx context.Context, backend ContractBackend, tx *types.Transaction) error {
-	receipt, err := bind.WaitMined(ctx, backend, tx)
+	receipt, err := backend.waitMined(ctx, tx)
 	if err != nil {
 		return errors.Wrap(err, "could not execute transaction")
 	}
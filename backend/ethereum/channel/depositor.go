@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/bindings/assets"
+	"perun.network/go-perun/log"
+)
+
+type (
+	// A Depositor sends the transactions that deposit funds of a single asset
+	// into its asset holder. The Funder selects the Depositor by asset, so that
+	// assets of different kinds, e.g., ETH and ERC-20 tokens, can be mixed in a
+	// channel.
+	Depositor interface {
+		// Deposit should send all transactions that are needed to deposit the
+		// requested balance, in order. It should not wait for them to be mined
+		// but return them, so that the Funder can wait for them.
+		Deposit(context.Context, DepositReq) (types.Transactions, error)
+	}
+
+	// A DepositReq collects all necessary information for a Depositor to
+	// deposit funds.
+	DepositReq struct {
+		Balance   *big.Int         // Amount to deposit.
+		CB        *ContractBackend // Used to send the transactions.
+		Asset     Asset            // Address of the asset holder.
+		FundingID [32]byte         // Funding ID of the participant.
+	}
+
+	// ETHDepositor deposits ether into an ETH asset holder.
+	ETHDepositor struct{}
+
+	// ERC20Depositor deposits ERC-20 tokens into an ERC-20 asset holder. It
+	// first approves the asset holder to transfer the tokens and then deposits
+	// them.
+	ERC20Depositor struct {
+		Token common.Address // Address of the ERC-20 token contract.
+	}
+)
+
+// erc20ABI is the subset of the ERC-20 ABI that is needed for deposits.
+const erc20ABI = `[{"constant":false,"inputs":[{"name":"spender","type":"address"},{"name":"value","type":"uint256"}],"name":"approve","outputs":[{"name":"","type":"bool"}],"payable":false,"stateMutability":"nonpayable","type":"function"}]`
+
+// parsedERC20ABI is the parsed erc20ABI.
+var parsedERC20ABI abi.ABI
+
+func init() {
+	var err error
+	if parsedERC20ABI, err = abi.JSON(strings.NewReader(erc20ABI)); err != nil {
+		log.Panicf("parsing ERC-20 ABI: %v", err)
+	}
+}
+
+// Deposit sends a single deposit transaction that carries the balance as
+// value.
+func (ETHDepositor) Deposit(ctx context.Context, req DepositReq) (types.Transactions, error) {
+	contract, err := assets.NewAssetHolder(req.Asset.Address, req.CB)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to assetholder")
+	}
+	opts, err := req.CB.newTransactor(ctx, req.Balance, GasLimit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating transactor")
+	}
+	tx, err := contract.Deposit(opts, req.FundingID, req.Balance)
+	if err != nil {
+		return nil, errors.Wrap(err, "depositing")
+	}
+	return types.Transactions{tx}, nil
+}
+
+// Deposit sends an approval transaction on the token contract and a deposit
+// transaction on the asset holder. The deposit uses the nonce following the
+// approval, so that it is executed after the approval.
+func (d ERC20Depositor) Deposit(ctx context.Context, req DepositReq) (types.Transactions, error) {
+	contract, err := assets.NewAssetHolder(req.Asset.Address, req.CB)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to assetholder")
+	}
+	token := bind.NewBoundContract(d.Token, parsedERC20ABI, req.CB, req.CB, req.CB)
+
+	opts, err := req.CB.newTransactor(ctx, big.NewInt(0), GasLimit)
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating transactor")
+	}
+	approval, err := token.Transact(opts, "approve", req.Asset.Address, req.Balance)
+	if err != nil {
+		return nil, errors.Wrap(err, "approving")
+	}
+
+	opts.Nonce = new(big.Int).Add(opts.Nonce, big.NewInt(1))
+	deposit, err := contract.Deposit(opts, req.FundingID, req.Balance)
+	if err != nil {
+		return nil, errors.Wrap(err, "depositing")
+	}
+	return types.Transactions{approval, deposit}, nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+// Topics of the Adjudicator events that are returned by an eventSub.
+var (
+	storedTopic         = adjudicatorABI.Events["Stored"].Id()
+	concludedTopic      = adjudicatorABI.Events["Concluded"].Id()
+	finalConcludedTopic = adjudicatorABI.Events["FinalConcluded"].Id()
+)
+
+// eventSub is a subscription of the Stored, Concluded and FinalConcluded events
+// of a channel. Stored events are returned as Registered or Progressed events,
+// depending on the method that emitted them.
+type eventSub struct {
+	ctx       context.Context
+	adj       *Adjudicator
+	query     ethereum.FilterQuery
+	sub       ethereum.Subscription
+	past      *types.Log // newest past log, returned first
+	logs      chan types.Log
+	concluded bool // whether the last returned event was a ConcludedEvent
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+var _ channel.AdjudicatorSubscription = (*eventSub)(nil)
+
+func newEventSub(ctx context.Context, adj *Adjudicator, id channel.ID) (*eventSub, error) {
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{adj.address},
+		Topics: [][]common.Hash{
+			{storedTopic, concludedTopic, finalConcludedTopic},
+			{common.Hash(id)},
+		},
+	}
+	logs := make(chan types.Log)
+	sub, err := adj.SubscribeFilterLogs(ctx, query, logs)
+	if err != nil {
+		return nil, errors.Wrap(err, "subscribing to adjudicator logs")
+	}
+
+	r := &eventSub{
+		ctx:    ctx,
+		adj:    adj,
+		query:  query,
+		sub:    sub,
+		logs:   logs,
+		closed: make(chan struct{}),
+	}
+	// Query the newest past log after subscribing, so that no event is missed
+	// in between.
+	if r.past, err = r.newestLog(); err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Next returns the newest past or next future event. It returns nil if the
+// subscription is closed, its context is done or an error occurred.
+//
+// Logs are returned once they have the confirmation depth of the
+// ContractBackend, see ContractBackend.SetConfirmations. If a log is removed
+// by a chain reorganization, before or after it was returned, the newest log
+// that is still on the chain is queried and its event is returned again, as a
+// correction. Further Concluded
+// events of an already concluded channel are skipped, since a final conclusion
+// may emit more than one.
+func (r *eventSub) Next() channel.AdjudicatorEvent {
+	for {
+		l := r.nextLog()
+		if l == nil {
+			return nil
+		}
+		l, reorged, err := r.final(l)
+		if err != nil {
+			r.err = err
+			return nil
+		} else if l == nil {
+			continue
+		}
+
+		ev, err := r.decode(*l)
+		if err != nil {
+			r.err = err
+			return nil
+		}
+		_, concluded := ev.(*channel.ConcludedEvent)
+		if concluded && r.concluded && !reorged {
+			continue
+		}
+		r.concluded = concluded
+		return ev
+	}
+}
+
+// final waits until the log l is final. If l is removed by a chain
+// reorganization, the newest log that is still on the chain is waited for
+// instead and reorged is set. It returns a nil log if there is none.
+func (r *eventSub) final(l *types.Log) (_ *types.Log, reorged bool, err error) {
+	for l != nil {
+		if ok, err := r.adj.confirmed(r.ctx, l); err != nil {
+			return nil, reorged, err
+		} else if ok {
+			return l, reorged, nil
+		}
+		reorged = true
+		if l, err = r.newestLog(); err != nil {
+			return nil, reorged, err
+		}
+	}
+	return nil, reorged, nil
+}
+
+// nextLog returns the newest past log or waits for the next log. It returns
+// nil if the subscription is closed, its context is done or an error occurred.
+func (r *eventSub) nextLog() *types.Log {
+	if past := r.past; past != nil {
+		r.past = nil
+		return past
+	}
+
+	select {
+	case l := <-r.logs:
+		return &l
+	case err := <-r.sub.Err():
+		r.err = errors.Wrap(err, "adjudicator log subscription")
+	case <-r.ctx.Done():
+		r.err = r.ctx.Err()
+	case <-r.closed:
+	}
+	return nil
+}
+
+// newestLog returns the newest log of the subscribed events that is on the
+// chain, or nil, if there is none.
+func (r *eventSub) newestLog() (*types.Log, error) {
+	query := r.query
+	query.FromBlock = big.NewInt(1)
+	logs, err := r.adj.FilterLogs(r.ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "filtering adjudicator logs")
+	}
+	if len(logs) == 0 {
+		return nil, nil
+	}
+	return &logs[len(logs)-1], nil
+}
+
+// decode decodes the log into the corresponding event.
+func (r *eventSub) decode(l types.Log) (channel.AdjudicatorEvent, error) {
+	id := channel.ID(l.Topics[1])
+	switch l.Topics[0] {
+	case storedTopic:
+		stored, err := r.adj.contract.ParseStored(l)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing Stored event")
+		}
+		stored.Raw = l // not set by the generated binding
+		d, err := r.adj.decodeDispute(r.ctx, stored)
+		if err != nil {
+			return nil, err
+		}
+		timeout := time.Unix(d.timeout.Int64(), 0)
+		if d.phase == phaseForceExec {
+			return &channel.ProgressedEvent{ID: id, Version: d.state.Version, Timeout: timeout}, nil
+		}
+		return &channel.RegisteredEvent{ID: id, Version: d.state.Version, Timeout: timeout}, nil
+	case concludedTopic, finalConcludedTopic:
+		call, err := r.adj.decodeCall(r.ctx, l.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		return &channel.ConcludedEvent{ID: id, Version: call.state.Version}, nil
+	default:
+		return nil, errors.Errorf("unexpected adjudicator log topic %x", l.Topics[0])
+	}
+}
+
+// Err returns the error of the subscription, if any.
+func (r *eventSub) Err() error {
+	return r.err
+}
+
+// Close closes the subscription.
+func (r *eventSub) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		r.sub.Unsubscribe()
+	})
+	return nil
+}
@@ -6,12 +6,10 @@
 package channel // import "perun.network/go-perun/backend/ethereum/channel"
 
 import (
-	"bytes"
 	"context"
 	"math/big"
 	"sync"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -22,12 +20,6 @@ import (
 	"perun.network/go-perun/log"
 )
 
-var (
-	// Declaration for abi-encoding.
-	abibytes32, _ = abi.NewType("bytes32", nil)
-	abiaddress, _ = abi.NewType("address", nil)
-)
-
 type assetHolder struct {
 	*assets.AssetHolder
 	*common.Address
@@ -35,29 +27,70 @@ type assetHolder struct {
 }
 
 // Funder implements the channel.Funder interface for Ethereum.
+//
+// The deposits of each asset are sent by the Depositor that was registered for
+// the asset with RegisterAsset.
 type Funder struct {
 	ContractBackend
-	mu  sync.Mutex
+	mu  sync.Mutex // protects nonce usage of the transactor
 	log log.Logger // structured logger
-	// ETHAssetHolder is the on-chain address of the ETH asset holder.
-	// This is needed to distinguish between ETH and ERC-20 transactions.
-	ethAssetHolder common.Address
+
+	depMtx     sync.RWMutex // protects depositors
+	depositors map[common.Address]Depositor
 }
 
 // compile time check that we implement the perun funder interface
 var _ channel.Funder = (*Funder)(nil)
 
-// NewETHFunder creates a new ethereum funder.
-func NewETHFunder(backend ContractBackend, ethAssetHolder common.Address) *Funder {
+// NewFunder creates a new ethereum funder without any assets. Use
+// RegisterAsset to add the Depositors of all supported assets.
+func NewFunder(backend ContractBackend) *Funder {
 	return &Funder{
 		ContractBackend: backend,
-		ethAssetHolder:  ethAssetHolder,
 		log:             log.WithField("account", backend.account.Address),
+		depositors:      make(map[common.Address]Depositor),
 	}
 }
 
+// NewETHFunder creates a new ethereum funder that supports the ETH asset
+// holder at the given address.
+func NewETHFunder(backend ContractBackend, ethAssetHolder common.Address) *Funder {
+	f := NewFunder(backend)
+	f.RegisterAsset(Asset{Address: ethAssetHolder}, new(ETHDepositor))
+	return f
+}
+
+// RegisterAsset sets the Depositor of the given asset, which is the address of
+// its asset holder. An already registered Depositor for the same asset is
+// replaced.
+func (f *Funder) RegisterAsset(asset Asset, d Depositor) {
+	if d == nil {
+		log.Panic("depositor must not be nil")
+	}
+
+	f.depMtx.Lock()
+	defer f.depMtx.Unlock()
+	f.depositors[asset.Address] = d
+}
+
+// depositor returns the Depositor of the given asset holder.
+func (f *Funder) depositor(asset common.Address) (Depositor, error) {
+	f.depMtx.RLock()
+	defer f.depMtx.RUnlock()
+	d, ok := f.depositors[asset]
+	if !ok {
+		return nil, errors.Errorf("no depositor for asset %v", asset.Hex())
+	}
+	return d, nil
+}
+
 // Fund implements the funder interface.
-// It can be used to fund state channels on the ethereum blockchain.
+// It can be used to fund state channels on the ethereum blockchain. All assets
+// are funded concurrently and the progress is reported per asset and
+// participant to the request's Progress callback. If some participants don't
+// fund in time, a channel.FundingTimeoutError listing all of them is returned.
+// Deposits only count once they have the confirmation depth of the
+// ContractBackend, see ContractBackend.SetConfirmations.
 func (f *Funder) Fund(ctx context.Context, request channel.FundingReq) error {
 	var channelID = request.Params.ID()
 	f.log.WithField("channel", channelID).Debug("Funding Channel.")
@@ -125,39 +158,45 @@ func (f *Funder) connectToContract(asset channel.Asset, assetIndex int) (assetHo
 }
 
 func (f *Funder) sendFundingTransaction(ctx context.Context, request channel.FundingReq, asset assetHolder, partIDs [][32]byte) error {
-	tx, err := f.createFundingTx(ctx, request, asset, partIDs)
+	txs, err := f.createFundingTxs(ctx, request, asset, partIDs)
 	if err != nil {
 		return errors.WithMessagef(err, "depositing asset %d", asset.assetIndex)
 	}
-	if err := execSuccessful(ctx, f.ContractBackend, tx); err != nil {
-		return errors.WithMessage(err, "mining transaction")
+	for _, tx := range txs {
+		if err := execSuccessful(ctx, f.ContractBackend, tx); err != nil {
+			return errors.WithMessage(err, "mining transaction")
+		}
+		f.log.Debugf("peer[%d] Transaction with txHash: [%v] executed successful", request.Idx, tx.Hash().Hex())
 	}
-	f.log.Debugf("peer[%d] Transaction with txHash: [%v] executed successful", request.Idx, tx.Hash().Hex())
 	return nil
 }
 
-func (f *Funder) createFundingTx(ctx context.Context, request channel.FundingReq, asset assetHolder, partIDs [][32]byte) (*types.Transaction, error) {
-	// Create a new transaction (needs to be cloned because of go-ethereum bug).
+func (f *Funder) createFundingTxs(ctx context.Context, request channel.FundingReq, asset assetHolder, partIDs [][32]byte) (types.Transactions, error) {
+	depositor, err := f.depositor(*asset.Address)
+	if err != nil {
+		return nil, err
+	}
+	// Create a new balance (needs to be cloned because of go-ethereum bug).
 	// See https://github.com/ethereum/go-ethereum/pull/20412
-	balance := new(big.Int).Set(request.Allocation.OfParts[request.Idx][asset.assetIndex])
+	balance := new(big.Int).Set(request.Deposits()[request.Idx][asset.assetIndex])
+	if balance.Sign() == 0 {
+		f.log.Debugf("peer[%d] Nothing to deposit for asset %d", request.Idx, asset.assetIndex)
+		return nil, nil
+	}
 	// Lock the funder for correct nonce usage.
 	f.mu.Lock()
 	defer f.mu.Unlock()
-	var auth *bind.TransactOpts
-	var errI error
-	if bytes.Equal(asset.Bytes(), f.ethAssetHolder.Bytes()) {
-		// If we want to fund the channel with ether, send eth in transaction.
-		auth, errI = f.newTransactor(ctx, balance, GasLimit)
-	} else {
-		auth, errI = f.newTransactor(ctx, big.NewInt(0), GasLimit)
-	}
-	if errI != nil {
-		return nil, errors.Wrapf(errI, "creating transactor for asset %d", asset.assetIndex)
-	}
-	// Call the asset holder contract.
-	tx, err := asset.Deposit(auth, partIDs[request.Idx], balance)
-	f.log.Debugf("peer[%d] Created funding transaction with txHash: %v, amount %d", request.Idx, tx.Hash().Hex(), balance)
-	return tx, errors.WithStack(err)
+	txs, err := depositor.Deposit(ctx, DepositReq{
+		Balance:   balance,
+		CB:        &f.ContractBackend,
+		Asset:     Asset{Address: *asset.Address},
+		FundingID: partIDs[request.Idx],
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.log.Debugf("peer[%d] Created %d funding transactions, amount %d", request.Idx, len(txs), balance)
+	return txs, nil
 }
 
 func filterOldEvents(ctx context.Context, asset assetHolder, deposited chan *assets.AssetHolderDeposited, partIDs [][32]byte) error {
@@ -205,8 +244,15 @@ func (f *Funder) waitForFundingConfirmation(ctx context.Context, request channel
 		}
 	}()
 
-	allocation := request.Allocation.Clone()
-	N := len(request.Params.Parts)
+	// Only wait for participants that deposit anything of this asset.
+	deposits := make([][]channel.Bal, len(request.Deposits()))
+	N := 0
+	for i, bals := range request.Deposits() {
+		deposits[i] = channel.CloneBals(bals)
+		if bals[asset.assetIndex].Sign() == 1 {
+			N++
+		}
+	}
 	for N > 0 {
 		select {
 		case event := <-deposited:
@@ -222,29 +268,40 @@ func (f *Funder) waitForFundingConfirmation(ctx context.Context, request channel
 				}
 			}
 
-			amount := allocation.OfParts[idx][asset.assetIndex]
+			amount := deposits[idx][asset.assetIndex]
 			if amount.Sign() == 0 {
 				continue // ignore double events
 			}
 
+			if ok, err := f.confirmed(ctx, &event.Raw); err != nil {
+				if ctx.Err() != nil {
+					continue // timeout is reported below
+				}
+				return err
+			} else if !ok {
+				log.Warnf("Deposited event for asset %d and participant %d removed by reorg", asset.assetIndex, idx)
+				continue
+			}
+
 			log.Debugf("Deposited event received for asset %d and participant %d", asset.assetIndex, idx)
 
 			amount.Sub(amount, event.Amount)
 			if amount.Sign() != 1 {
 				// participant funded successfully
 				N--
-				allocation.OfParts[idx][asset.assetIndex] = big.NewInt(0)
+				deposits[idx][asset.assetIndex] = big.NewInt(0)
+				request.ReportProgress(channel.FundingProgress{Asset: asset.assetIndex, Idx: channel.Index(idx)})
 			}
 
 		case <-ctx.Done():
 			var indices []channel.Index
-			for k := 0; k < len(allocation.OfParts[asset.assetIndex]); k++ {
-				if allocation.OfParts[asset.assetIndex][k].Sign() == 1 {
+			for k, bals := range deposits {
+				if bals[asset.assetIndex].Sign() == 1 {
 					indices = append(indices, channel.Index(k))
 				}
 			}
 			if indices != nil {
-				return channel.AssetFundingError{Asset: asset.assetIndex, TimedOutPeers: indices}
+				return &channel.AssetFundingError{Asset: asset.assetIndex, TimedOutPeers: indices}
 			}
 			return nil
 		case err := <-errChan:
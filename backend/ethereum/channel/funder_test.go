@@ -36,7 +36,7 @@ const timeout = 20 * time.Second
 func TestFunder_Fund(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
-	f := newSimulatedFunder(t)
+	f, assetETH := newSimulatedFunder(t)
 	assert.Panics(t, func() { f.Fund(ctx, channel.FundingReq{}) }, "Funding with invalid funding req should fail")
 	req := channel.FundingReq{
 		Params:     &channel.Params{},
@@ -50,7 +50,7 @@ func TestFunder_Fund(t *testing.T) {
 	rng := rand.New(rand.NewSource(1337))
 	app := channeltest.NewRandomApp(rng)
 	params := channel.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
-	allocation := newValidAllocation(parts, f.ethAssetHolder)
+	allocation := newValidAllocation(parts, assetETH)
 	req = channel.FundingReq{
 		Params:     params,
 		Allocation: allocation,
@@ -69,9 +69,20 @@ func TestFunder_Fund_multi(t *testing.T) {
 	t.Run("2-party funding", func(t *testing.T) { testFunderFunding(t, 2) })
 	t.Run("3-party funding", func(t *testing.T) { testFunderFunding(t, 3) })
 	t.Run("10-party funding", func(t *testing.T) { testFunderFunding(t, 10) })
+	t.Run("2-party single funder", func(t *testing.T) { testFunderFundingAgreement(t, 2) })
 }
 
 func testFunderFunding(t *testing.T, n int) {
+	testFunderFundingReq(t, n, false)
+}
+
+// testFunderFundingAgreement tests a funding agreement in which the first
+// participant funds the whole channel.
+func testFunderFundingAgreement(t *testing.T, n int) {
+	testFunderFundingReq(t, n, true)
+}
+
+func testFunderFundingReq(t *testing.T, n int, singleFunder bool) {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	simBackend := test.NewSimulatedBackend()
@@ -101,9 +112,19 @@ func testFunderFunding(t *testing.T, n int) {
 	app := channeltest.NewRandomApp(rng)
 	params := channel.NewParamsUnsafe(uint64(0), parts, app.Def(), big.NewInt(rng.Int63()))
 	allocation := newValidAllocation(parts, assetETH)
+	var agreement [][]channel.Bal
+	if singleFunder {
+		agreement = make([][]channel.Bal, n)
+		for i := range agreement {
+			agreement[i] = []channel.Bal{big.NewInt(0)}
+		}
+		agreement[0] = allocation.Sum()
+	}
 	var wg sync.WaitGroup
 	wg.Add(n)
+	progress := make([]chan channel.FundingProgress, n)
 	for i, funder := range funders {
+		progress[i] = make(chan channel.FundingProgress, n)
 		sleepTime := time.Duration(rng.Int63n(10) + 1)
 		go func(i int, funder *Funder) {
 			defer wg.Done()
@@ -112,15 +133,50 @@ func testFunderFunding(t *testing.T, n int) {
 				Params:     params,
 				Allocation: allocation,
 				Idx:        uint16(i),
+				Agreement:  agreement,
+				Progress:   func(p channel.FundingProgress) { progress[i] <- p },
 			}
 			err := funder.Fund(ctx, req)
 			assert.NoError(t, err, "funding should succeed")
+			close(progress[i])
 		}(i, funder)
 	}
 	wg.Wait()
+
+	// Every funder reports the deposits of all depositing participants.
+	for i := range funders {
+		var funded []channel.Index
+		for p := range progress[i] {
+			assert.Equal(t, 0, p.Asset)
+			funded = append(funded, p.Idx)
+		}
+		if singleFunder {
+			assert.Equal(t, []channel.Index{0}, funded)
+		} else {
+			assert.Len(t, funded, n)
+		}
+	}
 }
 
-func newSimulatedFunder(t *testing.T) *Funder {
+func TestFunder_RegisterAsset(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	f, _ := newSimulatedFunder(t)
+	assert.Panics(t, func() { f.RegisterAsset(Asset{}, nil) })
+
+	// Funding fails for assets without a Depositor.
+	rng := rand.New(rand.NewSource(0xde9))
+	parts := []perunwallet.Address{&wallet.Address{Address: f.account.Address}}
+	params := channel.NewParamsUnsafe(uint64(0), parts, channeltest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	req := channel.FundingReq{
+		Params:     params,
+		Allocation: newValidAllocation(parts, NewRandomAsset(rng).Address),
+		Idx:        0,
+	}
+	assert.Error(t, f.Fund(ctx, req))
+}
+
+func newSimulatedFunder(t *testing.T) (*Funder, common.Address) {
 	// Set KeyStore
 	wall := new(wallet.Wallet)
 	require.NoError(t, wall.Connect(keyDir, password))
@@ -129,13 +185,13 @@ func newSimulatedFunder(t *testing.T) *Funder {
 	ks := wall.Ks
 	simBackend := test.NewSimulatedBackend()
 	simBackend.FundAddress(context.Background(), acc.Account.Address)
-	cb := ContractBackend{simBackend, ks, acc.Account}
+	cb := NewContractBackend(simBackend, ks, acc.Account)
 	// Deploy Assetholder
 	assetETH, err := DeployETHAssetholder(context.Background(), cb, acc.Account.Address)
 	if err != nil {
 		panic(err)
 	}
-	return NewETHFunder(cb, assetETH)
+	return NewETHFunder(cb, assetETH), assetETH
 }
 
 func newValidAllocation(parts []perunwallet.Address, assetETH common.Address) *channel.Allocation {
@@ -157,3 +213,42 @@ func newValidAllocation(parts []perunwallet.Address, assetETH common.Address) *c
 		OfParts: ofparts,
 	}
 }
+
+func TestFunder_Fund_confirmations(t *testing.T) {
+	defer func(old time.Duration) { confirmationPollInterval = old }(confirmationPollInterval)
+	confirmationPollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	f, assetETH := newSimulatedFunder(t)
+	f.SetConfirmations(3)
+	sim := f.ContractInterface.(*test.SimulatedBackend)
+
+	parts := []perunwallet.Address{&wallet.Address{Address: f.account.Address}}
+	rng := rand.New(rand.NewSource(0xc0f))
+	params := channel.NewParamsUnsafe(uint64(0), parts, channeltest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	req := channel.FundingReq{
+		Params:     params,
+		Allocation: newValidAllocation(parts, assetETH),
+		Idx:        0,
+	}
+
+	funded := make(chan error, 1)
+	go func() { funded <- f.Fund(ctx, req) }()
+
+	// The deposit is mined in one block and needs two more.
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-funded:
+			t.Fatalf("funding completed after %d confirmations: %v", i+1, err)
+		case <-time.After(200 * time.Millisecond):
+		}
+		sim.Commit()
+	}
+	select {
+	case err := <-funded:
+		assert.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("funding not completed after 3 confirmations")
+	}
+}
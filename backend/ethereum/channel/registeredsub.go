@@ -0,0 +1,132 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/bindings/adjudicator"
+	"perun.network/go-perun/channel"
+)
+
+// registeredSub is a subscription of the Stored events of a channel, which are
+// emitted whenever a state is registered, refuted or progressed.
+type registeredSub struct {
+	ctx    context.Context
+	adj    *Adjudicator
+	sub    event.Subscription
+	past   *adjudicator.AdjudicatorStored // newest past event, returned first
+	stored chan *adjudicator.AdjudicatorStored
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+var _ channel.RegisteredSubscription = (*registeredSub)(nil)
+
+func newRegisteredSub(ctx context.Context, adj *Adjudicator, id channel.ID) (*registeredSub, error) {
+	watchOpts, err := adj.newWatchOpts(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating watchopts")
+	}
+	stored := make(chan *adjudicator.AdjudicatorStored)
+	sub, err := adj.contract.WatchStored(watchOpts, stored, [][32]byte{id})
+	if err != nil {
+		return nil, errors.Wrap(err, "watching Stored events")
+	}
+
+	// Query the newest past event after subscribing, so that no event is
+	// missed in between.
+	iter, err := adj.contract.FilterStored(&bind.FilterOpts{Start: uint64(1), Context: ctx}, [][32]byte{id})
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, errors.Wrap(err, "filtering Stored events")
+	}
+	defer iter.Close()
+	var past *adjudicator.AdjudicatorStored
+	for iter.Next() {
+		past = iter.Event
+	}
+	if err := iter.Error(); err != nil {
+		sub.Unsubscribe()
+		return nil, errors.Wrap(err, "iterating Stored events")
+	}
+
+	return &registeredSub{
+		ctx:    ctx,
+		adj:    adj,
+		sub:    sub,
+		past:   past,
+		stored: stored,
+		closed: make(chan struct{}),
+	}, nil
+}
+
+// Next returns the newest past or next future Registered event. It returns nil
+// if the subscription is closed, its context is done or an error occurred.
+// Events are returned once they have the confirmation depth of the
+// ContractBackend, events that are removed by a chain reorganization before
+// are skipped.
+func (r *registeredSub) Next() *channel.Registered {
+	var stored *adjudicator.AdjudicatorStored
+	for stored == nil {
+		stored = r.past
+		r.past = nil
+		if stored == nil {
+			select {
+			case stored = <-r.stored:
+			case err := <-r.sub.Err():
+				r.err = errors.Wrap(err, "Stored event subscription")
+				return nil
+			case <-r.ctx.Done():
+				r.err = r.ctx.Err()
+				return nil
+			case <-r.closed:
+				return nil
+			}
+		}
+
+		if ok, err := r.adj.confirmed(r.ctx, &stored.Raw); err != nil {
+			r.err = err
+			return nil
+		} else if !ok {
+			stored = nil
+		}
+	}
+
+	d, err := r.adj.decodeDispute(r.ctx, stored)
+	if err != nil {
+		r.err = err
+		return nil
+	}
+	return &channel.Registered{
+		ID:         stored.ChannelID,
+		Version:    d.state.Version,
+		Timeout:    time.Unix(d.timeout.Int64(), 0),
+		Progressed: d.phase == phaseForceExec,
+	}
+}
+
+// Err returns the error of the subscription, if any.
+func (r *registeredSub) Err() error {
+	return r.err
+}
+
+// Close closes the subscription.
+func (r *registeredSub) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		r.sub.Unsubscribe()
+	})
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	perunwallet "perun.network/go-perun/wallet"
+)
+
+var _ channel.ChainScanner = (*Adjudicator)(nil)
+
+// ScanChannels returns all channels of the Adjudicator contract with a
+// registered or concluded state in which part is a participant.
+//
+// The contract only emits the channel IDs, so the parameters and states are
+// decoded from the calldata of the transactions of all Stored and
+// FinalConcluded events of the contract. This is expensive on busy contracts.
+// Channels whose app is unknown to the app backend cannot be reconstructed and
+// are skipped. Progressed states only carry the actor's signature, so their
+// Sigs are nil.
+func (a *Adjudicator) ScanChannels(ctx context.Context, part perunwallet.Address) ([]*channel.ScannedChannel, error) {
+	addr, ok := part.(*wallet.Address)
+	if !ok {
+		return nil, errors.New("participant is no Ethereum address")
+	}
+
+	var ids []channel.ID // in order of their first event
+	chans := make(map[channel.ID]*channel.ScannedChannel)
+	// add decodes the call of the transaction and records its state, if part
+	// participates in the channel.
+	add := func(txHash common.Hash, id channel.ID) (*channel.ScannedChannel, error) {
+		call, err := a.decodeCall(ctx, txHash)
+		if err != nil {
+			return nil, err
+		}
+		if !containsAddr(call.params.Participants, addr.Address) {
+			return nil, nil
+		}
+		params, err := ethParamsToChannelParams(call.params)
+		if err != nil {
+			a.log.Warnf("Skipping channel %x with unknown parameters: %v", id, err)
+			return nil, nil
+		}
+		state, err := ethStateToChannelState(params, call.state)
+		if err != nil {
+			a.log.Warnf("Skipping channel %x with undecodable state: %v", id, err)
+			return nil, nil
+		}
+		sc, ok := chans[id]
+		if !ok {
+			sc = new(channel.ScannedChannel)
+			chans[id] = sc
+			ids = append(ids, id)
+		}
+		sc.Params = params
+		sc.Tx = channel.Transaction{State: state, Sigs: bytesToSigs(call.sigs)}
+		return sc, nil
+	}
+
+	opts := &bind.FilterOpts{Start: uint64(1), Context: ctx}
+	stored, err := a.contract.FilterStored(opts, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "filtering Stored events")
+	}
+	defer stored.Close()
+	for stored.Next() {
+		sc, err := add(stored.Event.Raw.TxHash, stored.Event.ChannelID)
+		if err != nil {
+			return nil, err
+		} else if sc != nil {
+			sc.Timeout = time.Unix(stored.Event.Timeout.Int64(), 0)
+		}
+	}
+	if err := stored.Error(); err != nil {
+		return nil, errors.Wrap(err, "iterating Stored events")
+	}
+
+	final, err := a.contract.FilterFinalConcluded(opts, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "filtering FinalConcluded events")
+	}
+	defer final.Close()
+	for final.Next() {
+		sc, err := add(final.Event.Raw.TxHash, final.Event.ChannelID)
+		if err != nil {
+			return nil, err
+		} else if sc != nil {
+			sc.Concluded = true
+		}
+	}
+	if err := final.Error(); err != nil {
+		return nil, errors.Wrap(err, "iterating FinalConcluded events")
+	}
+
+	// A dispute is concluded with the stored state, which is known already.
+	concluded, err := a.contract.FilterConcluded(opts, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "filtering Concluded events")
+	}
+	defer concluded.Close()
+	for concluded.Next() {
+		if sc, ok := chans[concluded.Event.ChannelID]; ok {
+			sc.Concluded = true
+		}
+	}
+	if err := concluded.Error(); err != nil {
+		return nil, errors.Wrap(err, "iterating Concluded events")
+	}
+
+	scanned := make([]*channel.ScannedChannel, len(ids))
+	for i, id := range ids {
+		scanned[i] = chans[id]
+	}
+	return scanned, nil
+}
+
+func containsAddr(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// bytesToSigs converts the signatures of a call. It returns nil if there are
+// none.
+func bytesToSigs(sigs [][]byte) []perunwallet.Sig {
+	if len(sigs) == 0 {
+		return nil
+	}
+	psigs := make([]perunwallet.Sig, len(sigs))
+	for i, sig := range sigs {
+		psigs[i] = sig
+	}
+	return psigs
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestAdjudicator_ScanChannels(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rng := rand.New(rand.NewSource(0x5ca))
+	s := newAdjudicatorSetup(ctx, t, rng, 60)
+
+	scanned, err := s.adjs[1].ScanChannels(ctx, s.accs[1].Address())
+	require.NoError(t, err)
+	assert.Empty(t, scanned, "channel not registered yet")
+
+	tx := s.tx(t, 2, false)
+	reg, err := s.adjs[0].Register(ctx, s.req(0, tx))
+	require.NoError(t, err)
+
+	scanned, err = s.adjs[1].ScanChannels(ctx, s.accs[1].Address())
+	require.NoError(t, err)
+	require.Len(t, scanned, 1)
+	sc := scanned[0]
+	assert.Equal(t, s.params.ID(), sc.Params.ID(), "reconstructed params")
+	assert.Equal(t, tx.State.Version, sc.Tx.Version)
+	assert.True(t, tx.State.Allocation.Equal(sc.Tx.Allocation), "reconstructed allocation")
+	assert.Equal(t, tx.State.Data, sc.Tx.Data)
+	assert.Equal(t, tx.Sigs, sc.Tx.Sigs)
+	assert.False(t, sc.Concluded)
+	assert.Equal(t, reg.Timeout, sc.Timeout)
+
+	scanned, err = s.adjs[1].ScanChannels(ctx, wallettest.NewRandomAddress(rng))
+	require.NoError(t, err)
+	assert.Empty(t, scanned, "no participant")
+
+	// The scanned channel can be withdrawn after the timeout.
+	require.NoError(t, s.sim.AdjustTime(2*time.Minute))
+	s.sim.Commit()
+	for i, adj := range s.adjs {
+		require.NoError(t, adj.Withdraw(ctx, channel.AdjudicatorReq{
+			Params: sc.Params,
+			Acc:    s.accs[i],
+			Tx:     sc.Tx,
+			Idx:    channel.Index(i),
+		}))
+	}
+	s.assertWithdrawn(ctx, t)
+
+	scanned, err = s.adjs[0].ScanChannels(ctx, s.accs[0].Address())
+	require.NoError(t, err)
+	require.Len(t, scanned, 1)
+	assert.True(t, scanned[0].Concluded)
+}
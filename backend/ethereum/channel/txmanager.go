@@ -0,0 +1,286 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+)
+
+// fillerGasLimit is the gas limit of the empty transactions that fill nonce
+// gaps.
+const fillerGasLimit = 21000
+
+// txPollInterval is the interval in which the receipts of pending transactions
+// are queried.
+var txPollInterval = time.Second
+
+type (
+	// A GasPricer determines the gas prices of the transactions of a
+	// TxManager.
+	//
+	// Only legacy transactions are supported by the used go-ethereum version.
+	// They stay valid after EIP-1559, where their gas price is both the
+	// maximum fee and the maximum priority fee per gas. So on such chains, a
+	// GasPricer should return at least the base fee of the next block plus a
+	// tip.
+	GasPricer interface {
+		// GasPrice returns the gas price of a new transaction.
+		GasPrice(context.Context) (*big.Int, error)
+		// Bump returns the gas price of the resubmission of a transaction
+		// with the given gas price. Nodes only replace pending transactions if
+		// the gas price is increased by at least 10%.
+		Bump(*big.Int) *big.Int
+	}
+
+	// SuggestedGasPricer uses the gas price that the node suggests and bumps
+	// gas prices by BumpPercent, at least by 10%. If Max is set, gas prices
+	// are capped at Max.
+	SuggestedGasPricer struct {
+		Backend interface {
+			SuggestGasPrice(context.Context) (*big.Int, error)
+		}
+		BumpPercent uint64
+		Max         *big.Int
+	}
+
+	// A TxManager resubmits transactions of a ContractBackend that are not
+	// mined in time with bumped gas prices, see ContractBackend.SetTxManager.
+	//
+	// Before a transaction is resubmitted, nonce gaps below it are repaired:
+	// pending transactions with lower nonces that were sent by the TxManager
+	// are resubmitted too, unknown nonces are filled with empty transactions.
+	TxManager struct {
+		pricer          GasPricer
+		resubmitTimeout time.Duration
+		maxResubmits    int
+
+		mtx     sync.Mutex
+		sent    map[uint64][]*types.Transaction // all versions of pending txs by nonce
+		fillers map[common.Hash]bool            // empty txs that fill nonce gaps
+	}
+
+	// txBackend is the part of the ContractInterface that the TxManager uses.
+	// NonceAt is optional, nonce gaps are only repaired if the backend
+	// implements nonceAter.
+	txBackend interface {
+		SendTransaction(context.Context, *types.Transaction) error
+		TransactionReceipt(context.Context, common.Hash) (*types.Receipt, error)
+	}
+
+	nonceAter interface {
+		NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	}
+
+	// txSigner signs a transaction of the account of the ContractBackend.
+	txSigner func(*types.Transaction) (*types.Transaction, error)
+)
+
+// minBumpPercent is the minimal gas price increase that nodes accept for
+// replacing a pending transaction.
+const minBumpPercent = 10
+
+// NewTxManager creates a TxManager that uses pricer for the gas prices of new
+// transactions and resubmits transactions that are not mined after
+// resubmitTimeout, at most maxResubmits times.
+//
+// If pricer is nil or resubmitTimeout is not positive, NewTxManager panics.
+func NewTxManager(pricer GasPricer, resubmitTimeout time.Duration, maxResubmits int) *TxManager {
+	if pricer == nil {
+		log.Panic("gas pricer must not be nil")
+	}
+	if resubmitTimeout <= 0 {
+		log.Panic("resubmit timeout must be positive")
+	}
+	return &TxManager{
+		pricer:          pricer,
+		resubmitTimeout: resubmitTimeout,
+		maxResubmits:    maxResubmits,
+		sent:            make(map[uint64][]*types.Transaction),
+		fillers:         make(map[common.Hash]bool),
+	}
+}
+
+// GasPrice returns the gas price that the node suggests, capped at Max.
+func (p SuggestedGasPricer) GasPrice(ctx context.Context) (*big.Int, error) {
+	price, err := p.Backend.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying suggested gas price")
+	}
+	return p.capped(price), nil
+}
+
+// Bump returns the gas price increased by BumpPercent, capped at Max.
+func (p SuggestedGasPricer) Bump(price *big.Int) *big.Int {
+	percent := p.BumpPercent
+	if percent < minBumpPercent {
+		percent = minBumpPercent
+	}
+	bumped := new(big.Int).Mul(price, new(big.Int).SetUint64(100+percent))
+	bumped.Add(bumped, big.NewInt(99)) // round up, so that small prices increase
+	return p.capped(bumped.Div(bumped, big.NewInt(100)))
+}
+
+func (p SuggestedGasPricer) capped(price *big.Int) *big.Int {
+	if p.Max != nil && price.Cmp(p.Max) > 0 {
+		return new(big.Int).Set(p.Max)
+	}
+	return price
+}
+
+// waitMined waits until tx, or one of its resubmissions, is mined and returns
+// its receipt. If it is not mined after the resubmit timeout, nonce gaps below
+// it are repaired and it is resubmitted with a bumped gas price.
+func (m *TxManager) waitMined(
+	ctx context.Context,
+	backend txBackend,
+	from common.Address,
+	sign txSigner,
+	tx *types.Transaction,
+) (*types.Receipt, error) {
+	nonce := tx.Nonce()
+	m.record(tx)
+	latest, submitted, resubmits := tx, time.Now(), 0
+	for {
+		for _, t := range m.versions(nonce) {
+			receipt, err := backend.TransactionReceipt(ctx, t.Hash())
+			if err != nil || receipt == nil {
+				continue // not mined yet
+			}
+			if m.forget(nonce, t.Hash()) {
+				return nil, errors.Errorf("transaction with nonce %d was replaced by a nonce gap filler", nonce)
+			}
+			return receipt, nil
+		}
+
+		if resubmits < m.maxResubmits && time.Since(submitted) >= m.resubmitTimeout {
+			m.repairNonceGap(ctx, backend, from, sign, nonce)
+			if next, err := m.resubmit(ctx, backend, sign, latest); err != nil {
+				log.Warnf("Resubmitting transaction with nonce %d: %v", nonce, err)
+			} else {
+				latest = next
+			}
+			submitted = time.Now()
+			resubmits++
+		}
+
+		select {
+		case <-time.After(txPollInterval):
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "waiting for transaction")
+		}
+	}
+}
+
+// resubmit sends tx again with a bumped gas price and returns the new version.
+func (m *TxManager) resubmit(ctx context.Context, backend txBackend, sign txSigner, tx *types.Transaction) (*types.Transaction, error) {
+	price := m.pricer.Bump(tx.GasPrice())
+	var next *types.Transaction
+	if tx.To() == nil {
+		next = types.NewContractCreation(tx.Nonce(), tx.Value(), tx.Gas(), price, tx.Data())
+	} else {
+		next = types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), price, tx.Data())
+	}
+	return m.send(ctx, backend, sign, next)
+}
+
+// repairNonceGap resubmits or fills all pending nonces of from below nonce. It
+// does nothing if the backend doesn't implement nonceAter.
+func (m *TxManager) repairNonceGap(ctx context.Context, backend txBackend, from common.Address, sign txSigner, nonce uint64) {
+	n, ok := backend.(nonceAter)
+	if !ok {
+		return
+	}
+	confirmed, err := n.NonceAt(ctx, from, nil)
+	if err != nil {
+		log.Warnf("Querying nonce for repairing nonce gap: %v", err)
+		return
+	}
+	m.forgetBelow(confirmed)
+
+	for gap := confirmed; gap < nonce; gap++ {
+		var err error
+		if versions := m.versions(gap); len(versions) > 0 {
+			_, err = m.resubmit(ctx, backend, sign, versions[len(versions)-1])
+		} else {
+			var price *big.Int
+			if price, err = m.pricer.GasPrice(ctx); err == nil {
+				filler := types.NewTransaction(gap, from, new(big.Int), fillerGasLimit, price, nil)
+				if filler, err = m.send(ctx, backend, sign, filler); err == nil {
+					m.mtx.Lock()
+					m.fillers[filler.Hash()] = true
+					m.mtx.Unlock()
+				}
+			}
+		}
+		if err != nil {
+			log.Warnf("Repairing nonce gap at nonce %d: %v", gap, err)
+		}
+	}
+}
+
+// send signs, records and sends the transaction.
+func (m *TxManager) send(ctx context.Context, backend txBackend, sign txSigner, tx *types.Transaction) (*types.Transaction, error) {
+	signed, err := sign(tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "signing transaction")
+	}
+	if err := backend.SendTransaction(ctx, signed); err != nil {
+		return nil, errors.Wrap(err, "sending transaction")
+	}
+	m.record(signed)
+	return signed, nil
+}
+
+func (m *TxManager) record(tx *types.Transaction) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.sent[tx.Nonce()] = append(m.sent[tx.Nonce()], tx)
+}
+
+func (m *TxManager) versions(nonce uint64) []*types.Transaction {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return append([]*types.Transaction(nil), m.sent[nonce]...)
+}
+
+// forget forgets all versions of the transaction with the given nonce, of
+// which the version with hash mined was mined. It returns whether the mined
+// transaction was a nonce gap filler.
+func (m *TxManager) forget(nonce uint64, mined common.Hash) (filler bool) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	filler = m.fillers[mined]
+	m.forgetLocked(nonce)
+	return filler
+}
+
+// forgetBelow forgets all transactions with nonces below nonce, which are
+// mined already.
+func (m *TxManager) forgetBelow(nonce uint64) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for n := range m.sent {
+		if n < nonce {
+			m.forgetLocked(n)
+		}
+	}
+}
+
+func (m *TxManager) forgetLocked(nonce uint64) {
+	for _, tx := range m.sent[nonce] {
+		delete(m.fillers, tx.Hash())
+	}
+	delete(m.sent, nonce)
+}
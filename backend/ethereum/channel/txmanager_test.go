@@ -0,0 +1,210 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/ethereum/wallet"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	perunwallet "perun.network/go-perun/wallet"
+)
+
+// stubTxBackend is a txBackend that records sent transactions and only mines
+// them on request.
+type stubTxBackend struct {
+	mtx      sync.Mutex
+	nonce    uint64 // confirmed nonce
+	sent     []*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+}
+
+func newStubTxBackend(nonce uint64) *stubTxBackend {
+	return &stubTxBackend{nonce: nonce, receipts: make(map[common.Hash]*types.Receipt)}
+}
+
+func (b *stubTxBackend) SendTransaction(_ context.Context, tx *types.Transaction) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.sent = append(b.sent, tx)
+	return nil
+}
+
+func (b *stubTxBackend) TransactionReceipt(_ context.Context, tx common.Hash) (*types.Receipt, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.receipts[tx], nil
+}
+
+func (b *stubTxBackend) NonceAt(context.Context, common.Address, *big.Int) (uint64, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.nonce, nil
+}
+
+func (b *stubTxBackend) mine(tx *types.Transaction) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.receipts[tx.Hash()] = &types.Receipt{TxHash: tx.Hash(), Status: types.ReceiptStatusSuccessful}
+}
+
+// waitSent waits until n transactions were sent and returns them.
+func (b *stubTxBackend) waitSent(t *testing.T, n int) []*types.Transaction {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		b.mtx.Lock()
+		sent := append([]*types.Transaction(nil), b.sent...)
+		b.mtx.Unlock()
+		if len(sent) >= n {
+			return sent
+		}
+	}
+	require.FailNow(t, "transactions not sent", "expected %d", n)
+	return nil
+}
+
+type constGasPricer struct{}
+
+func (constGasPricer) GasPrice(context.Context) (*big.Int, error) { return big.NewInt(100), nil }
+func (constGasPricer) Bump(p *big.Int) *big.Int                   { return new(big.Int).Add(p, big.NewInt(10)) }
+
+func TestTxManager_waitMined(t *testing.T) {
+	defer func(old time.Duration) { txPollInterval = old }(txPollInterval)
+	txPollInterval = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	sign := func(tx *types.Transaction) (*types.Transaction, error) {
+		return types.SignTx(tx, types.HomesteadSigner{}, key)
+	}
+	to := common.Address{1}
+	newTx := func(nonce uint64) *types.Transaction {
+		tx, err := sign(types.NewTransaction(nonce, to, big.NewInt(1), GasLimit, big.NewInt(100), []byte{2}))
+		require.NoError(t, err)
+		return tx
+	}
+
+	t.Run("mined", func(t *testing.T) {
+		m := NewTxManager(constGasPricer{}, time.Hour, 3)
+		b := newStubTxBackend(0)
+		tx := newTx(0)
+		b.mine(tx)
+		receipt, err := m.waitMined(ctx, b, from, sign, tx)
+		require.NoError(t, err)
+		assert.Equal(t, tx.Hash(), receipt.TxHash)
+		assert.Empty(t, b.sent)
+	})
+
+	t.Run("resubmitted", func(t *testing.T) {
+		m := NewTxManager(constGasPricer{}, 10*time.Millisecond, 3)
+		b := newStubTxBackend(5)
+		tx := newTx(5)
+		done := make(chan *types.Receipt, 1)
+		go func() {
+			receipt, err := m.waitMined(ctx, b, from, sign, tx)
+			assert.NoError(t, err)
+			done <- receipt
+		}()
+
+		sent := b.waitSent(t, 2)
+		for i, bumped := range sent[:2] {
+			assert.Equal(t, tx.Nonce(), bumped.Nonce())
+			assert.Equal(t, tx.Data(), bumped.Data())
+			assert.Equal(t, tx.Value(), bumped.Value())
+			assert.Equal(t, big.NewInt(int64(110+10*i)), bumped.GasPrice())
+		}
+		b.mine(sent[0])
+		assert.Equal(t, sent[0].Hash(), (<-done).TxHash)
+		assert.True(t, len(b.waitSent(t, 0)) <= 3, "at most 3 resubmissions")
+	})
+
+	t.Run("nonce gap", func(t *testing.T) {
+		m := NewTxManager(constGasPricer{}, 10*time.Millisecond, 1)
+		b := newStubTxBackend(3)
+		pending, tx := newTx(3), newTx(5)
+		m.record(pending)
+		done := make(chan *types.Receipt, 1)
+		go func() {
+			receipt, err := m.waitMined(ctx, b, from, sign, tx)
+			assert.NoError(t, err)
+			done <- receipt
+		}()
+
+		sent := b.waitSent(t, 3)
+		assert.Equal(t, uint64(3), sent[0].Nonce(), "pending transaction resubmitted")
+		assert.Equal(t, pending.Data(), sent[0].Data())
+		assert.Equal(t, uint64(4), sent[1].Nonce(), "gap filled")
+		assert.Equal(t, from, *sent[1].To())
+		assert.Zero(t, sent[1].Value().Sign())
+		assert.Equal(t, uint64(5), sent[2].Nonce(), "transaction resubmitted")
+		b.mine(sent[2])
+		assert.Equal(t, sent[2].Hash(), (<-done).TxHash)
+	})
+
+	t.Run("replaced by filler", func(t *testing.T) {
+		m := NewTxManager(constGasPricer{}, 10*time.Millisecond, 1)
+		b := newStubTxBackend(0)
+		done := make(chan error, 1)
+		go func() {
+			_, err := m.waitMined(ctx, b, from, sign, newTx(1))
+			done <- err
+		}()
+		filler := b.waitSent(t, 1)[0]
+		require.Equal(t, uint64(0), filler.Nonce())
+
+		// A transaction with the filled nonce is only mined if the filler is
+		// not.
+		b.mine(filler)
+		_, err := m.waitMined(ctx, b, from, sign, newTx(0))
+		assert.Error(t, err)
+		b.mine(b.waitSent(t, 2)[1])
+		assert.NoError(t, <-done)
+	})
+}
+
+func TestSuggestedGasPricer(t *testing.T) {
+	p := SuggestedGasPricer{BumpPercent: 5}
+	assert.Equal(t, big.NewInt(110), p.Bump(big.NewInt(100)), "at least 10%")
+	assert.Equal(t, big.NewInt(2), p.Bump(big.NewInt(1)), "rounded up")
+	p.BumpPercent = 50
+	assert.Equal(t, big.NewInt(150), p.Bump(big.NewInt(100)))
+	p.Max = big.NewInt(120)
+	assert.Equal(t, big.NewInt(120), p.Bump(big.NewInt(100)), "capped")
+}
+
+func TestFunder_Fund_txManager(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	f, assetETH := newSimulatedFunder(t)
+	f.SetTxManager(NewTxManager(constGasPricer{}, time.Hour, 1))
+
+	opts, err := f.newTransactor(ctx, big.NewInt(0), GasLimit)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(100), opts.GasPrice, "gas price of the GasPricer")
+
+	parts := []perunwallet.Address{&wallet.Address{Address: f.account.Address}}
+	rng := rand.New(rand.NewSource(0x7a))
+	params := channel.NewParamsUnsafe(uint64(0), parts, channeltest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	assert.NoError(t, f.Fund(ctx, channel.FundingReq{
+		Params:     params,
+		Allocation: newValidAllocation(parts, assetETH),
+		Idx:        0,
+	}))
+}
@@ -19,7 +19,6 @@ import (
 	"perun.network/go-perun/backend/ethereum/channel/test"
 	"perun.network/go-perun/backend/ethereum/wallet"
 	ethwallettest "perun.network/go-perun/backend/ethereum/wallet/test"
-	perunchannel "perun.network/go-perun/channel"
 	clienttest "perun.network/go-perun/client/test"
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/peer"
@@ -60,8 +59,8 @@ func TestHappyAliceBobETH(t *testing.T) {
 	funderAlice := channel.NewETHFunder(cbAlice, assetAddr)
 	funderBob := channel.NewETHFunder(cbBob, assetAddr)
 	// Create the settlers
-	adjudicatorAlice := &DummyAdjudicator{t}
-	adjudicatorBob := &DummyAdjudicator{t}
+	adjudicatorAlice := channel.NewAdjudicator(cbAlice, adjAddr, aliceAccETH.Address)
+	adjudicatorBob := channel.NewAdjudicator(cbBob, adjAddr, bobAccETH.Address)
 
 	setupAlice := clienttest.RoleSetup{
 		Name:        "Alice",
@@ -116,20 +115,3 @@ func TestHappyAliceBobETH(t *testing.T) {
 	wg.Wait()
 	log.Info("Happy test done")
 }
-
-// DummyAdjudicator is a temporary dummy implementation of the Adjudicator interface until the Ethereum Adjudicator is merged
-type DummyAdjudicator struct {
-	t *testing.T
-}
-
-func (d *DummyAdjudicator) Register(ctx context.Context, req perunchannel.AdjudicatorReq) (*perunchannel.Registered, error) {
-	return &perunchannel.Registered{ID: req.Params.ID(), Timeout: time.Now(), Version: req.Tx.Version}, nil
-}
-
-func (d *DummyAdjudicator) Withdraw(context.Context, perunchannel.AdjudicatorReq) error {
-	return nil
-}
-
-func (d *DummyAdjudicator) SubscribeRegistered(context.Context, *perunchannel.Params) (perunchannel.RegisteredSubscription, error) {
-	return nil, nil
-}
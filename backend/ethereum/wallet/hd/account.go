@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package hd
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+
+	ethwallet "perun.network/go-perun/backend/ethereum/wallet"
+	perun "perun.network/go-perun/wallet"
+)
+
+// Account is an ethereum account of a hierarchical deterministic wallet. Its
+// signatures can be verified by the ethereum wallet backend.
+type Account struct {
+	address ethwallet.Address
+	key     *ecdsa.PrivateKey
+	path    accounts.DerivationPath
+}
+
+var _ perun.Account = (*Account)(nil)
+
+func newAccount(key *ecdsa.PrivateKey, path accounts.DerivationPath) *Account {
+	return &Account{
+		address: ethwallet.Address{Address: crypto.PubkeyToAddress(key.PublicKey)},
+		key:     key,
+		path:    path,
+	}
+}
+
+// Address returns the ethereum address of this account.
+func (a *Account) Address() perun.Address {
+	return &a.address
+}
+
+// Path returns the derivation path of this account.
+func (a *Account) Path() accounts.DerivationPath {
+	return a.path
+}
+
+// SignData is used to sign data with this account. Like the ethereum wallet,
+// it signs the prefixed hash of the data.
+func (a *Account) SignData(data []byte) ([]byte, error) {
+	hash := crypto.Keccak256([]byte("\x19Ethereum Signed Message:\n32"), crypto.Keccak256(data))
+	sig, err := crypto.Sign(hash, a.key)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not sign data")
+	}
+	sig[64] += 27
+	return sig, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package hd
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// hardened is the offset of hardened child indices, see BIP-32.
+const hardened = 0x80000000
+
+// masterKeySalt is the HMAC key for the derivation of the master key.
+var masterKeySalt = []byte("Bitcoin seed")
+
+// extendedKey is a BIP-32 extended private key.
+type extendedKey struct {
+	key       []byte // 32 byte private key
+	chainCode []byte // 32 byte chain code
+}
+
+// newMasterKey derives the master key from the given seed.
+func newMasterKey(seed []byte) (*extendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.Errorf("invalid seed length %d", len(seed))
+	}
+	mac := hmac.New(sha512.New, masterKeySalt)
+	mac.Write(seed) // hash writes never fail
+	sum := mac.Sum(nil)
+
+	k := &extendedKey{key: sum[:32], chainCode: sum[32:]}
+	if !validKey(k.key) {
+		return nil, errors.New("seed results in invalid master key")
+	}
+	return k, nil
+}
+
+// derivePath derives the extended key at the given path, relative to k.
+func (k *extendedKey) derivePath(path accounts.DerivationPath) (*extendedKey, error) {
+	var err error
+	for _, idx := range path {
+		if k, err = k.child(idx); err != nil {
+			return nil, errors.WithMessagef(err, "deriving path %v", path)
+		}
+	}
+	return k, nil
+}
+
+// child derives the child key with the given index. Indices of at least
+// hardened result in hardened keys.
+func (k *extendedKey) child(idx uint32) (*extendedKey, error) {
+	data := make([]byte, 0, 37)
+	if idx >= hardened {
+		data = append(append(data, 0), k.key...)
+	} else {
+		data = append(data, crypto.CompressPubkey(&k.privateKey().PublicKey)...)
+	}
+	var idxBytes [4]byte
+	binary.BigEndian.PutUint32(idxBytes[:], idx)
+	data = append(data, idxBytes[:]...)
+
+	mac := hmac.New(sha512.New, k.chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	if !validKey(sum[:32]) {
+		return nil, errors.Errorf("invalid child key %d", idx)
+	}
+	childKey := new(big.Int).SetBytes(sum[:32])
+	childKey.Add(childKey, new(big.Int).SetBytes(k.key))
+	childKey.Mod(childKey, crypto.S256().Params().N)
+	if childKey.Sign() == 0 {
+		return nil, errors.Errorf("invalid child key %d", idx)
+	}
+
+	key := make([]byte, 32)
+	b := childKey.Bytes()
+	copy(key[32-len(b):], b)
+	return &extendedKey{key: key, chainCode: sum[32:]}, nil
+}
+
+// privateKey returns the private key of k.
+func (k *extendedKey) privateKey() *ecdsa.PrivateKey {
+	return crypto.ToECDSAUnsafe(k.key)
+}
+
+// validKey returns whether key is a valid private key, i.e., not zero and
+// less than the curve order.
+func validKey(key []byte) bool {
+	i := new(big.Int).SetBytes(key)
+	return i.Sign() != 0 && i.Cmp(crypto.S256().Params().N) < 0
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package hd defines a hierarchical deterministic ethereum wallet, as
+// specified in BIP-32 and BIP-44. All accounts are derived from a single seed,
+// which can be created from a BIP-39 mnemonic. This way, a fresh account can
+// be used for every channel while only the mnemonic needs to be backed up.
+package hd // import "perun.network/go-perun/backend/ethereum/wallet/hd"
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/pkg/errors"
+	"github.com/tyler-smith/go-bip39"
+
+	perun "perun.network/go-perun/wallet"
+)
+
+// mnemonicEntropyBits is the entropy of newly created mnemonics, resulting in
+// 24 words.
+const mnemonicEntropyBits = 256
+
+// Wallet is a hierarchical deterministic wallet. Its accounts are derived at
+// the successive indices below its base path. Accessing the wallet is
+// threadsafe.
+type Wallet struct {
+	mnemonic string // empty if the wallet was not created from a mnemonic
+	base     *extendedKey
+	basePath accounts.DerivationPath
+
+	mu       sync.RWMutex
+	accounts []*Account
+}
+
+// NewMnemonic creates a new random BIP-39 mnemonic of 24 words.
+func NewMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropyBits)
+	if err != nil {
+		return "", errors.Wrap(err, "creating entropy")
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	return mnemonic, errors.Wrap(err, "creating mnemonic")
+}
+
+// NewWallet creates a new wallet from the given seed, whose accounts are
+// derived below basePath, e.g., accounts.DefaultRootDerivationPath for
+// BIP-44 ethereum accounts.
+func NewWallet(seed []byte, basePath accounts.DerivationPath) (*Wallet, error) {
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	base, err := master.derivePath(basePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{
+		base:     base,
+		basePath: append(accounts.DerivationPath(nil), basePath...),
+	}, nil
+}
+
+// NewWalletFromMnemonic creates a new wallet from the seed of the given BIP-39
+// mnemonic and password, see NewWallet.
+func NewWalletFromMnemonic(mnemonic, password string, basePath accounts.DerivationPath) (*Wallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid mnemonic")
+	}
+	w, err := NewWallet(seed, basePath)
+	if err != nil {
+		return nil, err
+	}
+	w.mnemonic = mnemonic
+	return w, nil
+}
+
+// Mnemonic returns the mnemonic that the wallet was created from. It returns
+// an error if the wallet was created from a seed.
+func (w *Wallet) Mnemonic() (string, error) {
+	if w.mnemonic == "" {
+		return "", errors.New("wallet was not created from a mnemonic")
+	}
+	return w.mnemonic, nil
+}
+
+// NewAccount derives the account at the next unused index. Since the
+// derivation is deterministic, a wallet that is recreated from the same seed
+// derives the same accounts in the same order.
+func (w *Wallet) NewAccount() (*Account, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key, err := w.base.child(uint32(len(w.accounts)))
+	if err != nil {
+		return nil, err
+	}
+	acc := newAccount(key.privateKey(), w.path(uint32(len(w.accounts))))
+	w.accounts = append(w.accounts, acc)
+	return acc, nil
+}
+
+// path returns the derivation path of the account at the given index.
+func (w *Wallet) path(idx uint32) accounts.DerivationPath {
+	return append(append(accounts.DerivationPath(nil), w.basePath...), idx)
+}
+
+// Accounts returns all accounts that were derived so far, in the order of
+// their derivation.
+func (w *Wallet) Accounts() []perun.Account {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accs := make([]perun.Account, len(w.accounts))
+	for i, acc := range w.accounts {
+		accs[i] = acc
+	}
+	return accs
+}
+
+// Contains checks whether the account was derived by this wallet.
+func (w *Wallet) Contains(a perun.Account) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if a == nil {
+		return false
+	}
+	for _, acc := range w.accounts {
+		if acc.Address().Equals(a.Address()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package hd
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ethwallet "perun.network/go-perun/backend/ethereum/wallet"
+	perun "perun.network/go-perun/wallet"
+	"perun.network/go-perun/wallet/test"
+)
+
+// testMnemonic is a well-known development mnemonic, whose first BIP-44
+// account is testAddr.
+const (
+	testMnemonic = "test test test test test test test test test test test junk"
+	testAddr     = "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266"
+)
+
+func TestGenericSignatureTests(t *testing.T) {
+	w := newTestWallet(t)
+	setup := &test.Setup{
+		UnlockedAccount: func() (perun.Account, error) { return w.NewAccount() },
+		AddressBytes:    common.HexToAddress("1234560000000000000000000000000000000000").Bytes(),
+		Backend:         new(ethwallet.Backend),
+		DataToSign:      []byte("SomeLongDataThatShouldBeSignedPlease"),
+	}
+	test.GenericSignatureTest(t, setup)
+	test.GenericSignatureSizeTest(t, setup)
+}
+
+func TestBIP32(t *testing.T) {
+	// Test vector 1 of BIP-32.
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := newMasterKey(seed)
+	require.NoError(t, err)
+	assert.Equal(t, "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35", hex.EncodeToString(master.key))
+	assert.Equal(t, "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508", hex.EncodeToString(master.chainCode))
+
+	key, err := master.derivePath(accounts.DerivationPath{hardened})
+	require.NoError(t, err)
+	assert.Equal(t, "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea", hex.EncodeToString(key.key))
+
+	key, err = master.derivePath(accounts.DerivationPath{hardened, 1, hardened + 2, 2, 1000000000})
+	require.NoError(t, err)
+	assert.Equal(t, "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8", hex.EncodeToString(key.key))
+
+	_, err = newMasterKey(seed[:15])
+	assert.Error(t, err, "short seed")
+}
+
+func TestWallet_Mnemonic(t *testing.T) {
+	w := newTestWallet(t)
+	acc, err := w.NewAccount()
+	require.NoError(t, err)
+	assert.Equal(t, common.HexToAddress(testAddr).Bytes(), acc.Address().Bytes())
+	assert.Equal(t, "m/44'/60'/0'/0/0", acc.Path().String())
+	mnemonic, err := w.Mnemonic()
+	require.NoError(t, err)
+	assert.Equal(t, testMnemonic, mnemonic)
+
+	_, err = NewWalletFromMnemonic("test test", "", accounts.DefaultRootDerivationPath)
+	assert.Error(t, err, "invalid mnemonic")
+
+	mnemonic, err = NewMnemonic()
+	require.NoError(t, err)
+	w, err = NewWalletFromMnemonic(mnemonic, "secret", accounts.DefaultRootDerivationPath)
+	require.NoError(t, err)
+	exported, err := w.Mnemonic()
+	require.NoError(t, err)
+	assert.Equal(t, mnemonic, exported)
+
+	w, err = NewWallet(make([]byte, 32), accounts.DefaultRootDerivationPath)
+	require.NoError(t, err)
+	_, err = w.Mnemonic()
+	assert.Error(t, err, "wallet from seed")
+}
+
+func TestWallet_NewAccount(t *testing.T) {
+	w, restored := newTestWallet(t), newTestWallet(t)
+	for i := 0; i < 3; i++ {
+		acc, err := w.NewAccount()
+		require.NoError(t, err)
+		racc, err := restored.NewAccount()
+		require.NoError(t, err)
+		assert.True(t, acc.Address().Equals(racc.Address()), "deterministic derivation")
+		for _, other := range w.Accounts()[:i] {
+			assert.False(t, acc.Address().Equals(other.Address()), "fresh address")
+		}
+		assert.True(t, w.Contains(acc))
+	}
+	assert.Len(t, w.Accounts(), 3)
+
+	other, err := NewWallet(make([]byte, 32), accounts.DefaultRootDerivationPath)
+	require.NoError(t, err)
+	acc, err := other.NewAccount()
+	require.NoError(t, err)
+	assert.False(t, w.Contains(acc))
+	assert.False(t, w.Contains(nil))
+}
+
+func newTestWallet(t *testing.T) *Wallet {
+	w, err := NewWalletFromMnemonic(testMnemonic, "", accounts.DefaultRootDerivationPath)
+	require.NoError(t, err)
+	return w
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"perun.network/go-perun/log"
 	perun "perun.network/go-perun/wallet"
 )
 
@@ -25,10 +26,14 @@ import (
 type Wallet struct {
 	Ks        *keystore.KeyStore
 	directory string
+	password  string // used to unlock accounts
 	accounts  map[string]*Account
+	usages    map[string]int // usage counters of the accounts
 	mu        sync.RWMutex
 }
 
+var _ perun.Wallet = (*Wallet)(nil)
+
 // NewWallet creates a new Wallet from a keystore and directory.
 func NewWallet(ks *keystore.KeyStore, dir string) *Wallet {
 	return &Wallet{
@@ -65,7 +70,9 @@ func (w *Wallet) Connect(keyDir, password string) error {
 	}
 	w.Ks = keystore.NewKeyStore(keyDir, keystore.StandardScryptN, keystore.StandardScryptP)
 	w.accounts = make(map[string]*Account)
+	w.usages = make(map[string]int)
 	w.directory = keyDir
+	w.password = password
 
 	w.refreshAccounts()
 
@@ -87,7 +94,9 @@ func (w *Wallet) Disconnect() error {
 
 	w.Ks = nil
 	w.accounts = make(map[string]*Account)
+	w.usages = make(map[string]int)
 	w.directory = ""
+	w.password = ""
 	return nil
 }
 
@@ -155,3 +164,56 @@ func (w *Wallet) Lock() error {
 	}
 	return nil
 }
+
+// Unlock unlocks the account with the given address with the password that
+// the wallet was connected with.
+func (w *Wallet) Unlock(addr perun.Address) (perun.Account, error) {
+	w.refreshAccounts()
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	acc, ok := w.accounts[addr.String()]
+	if !ok {
+		return nil, errors.Errorf("unknown account %v", addr)
+	}
+	if err := acc.Unlock(w.password); err != nil {
+		return nil, errors.Wrap(err, "unlocking account")
+	}
+	return acc, nil
+}
+
+// LockAll locks all accounts, see Lock.
+func (w *Wallet) LockAll() error {
+	return w.Lock()
+}
+
+// IncrementUsage increments the usage counter of the account with the given
+// address.
+func (w *Wallet) IncrementUsage(addr perun.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.usages[addr.String()]++
+}
+
+// DecrementUsage decrements the usage counter of the account with the given
+// address and locks the account if it is not used anymore.
+func (w *Wallet) DecrementUsage(addr perun.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := addr.String()
+	if w.usages[key] == 0 {
+		log.Panicf("account %v not in use", addr)
+	}
+	if w.usages[key]--; w.usages[key] > 0 {
+		return
+	}
+	delete(w.usages, key)
+	if acc, ok := w.accounts[key]; ok {
+		if err := acc.Lock(); err != nil {
+			log.Errorf("locking unused account %v: %v", addr, err)
+		}
+	}
+}
@@ -103,6 +103,30 @@ func TestLocking(t *testing.T) {
 	})
 }
 
+func TestWallet_Usage(t *testing.T) {
+	w := connectTmpKeystore(t)
+	addr := w.Accounts()[0].Address()
+	_, err := w.Unlock(&Address{common.HexToAddress(sampleAddr)})
+	assert.Error(t, err, "Unlock of unknown account should fail")
+
+	acc, err := w.Unlock(addr)
+	require.NoError(t, err)
+	assert.False(t, acc.(*Account).IsLocked(), "Account should be unlocked")
+
+	w.IncrementUsage(addr)
+	w.IncrementUsage(addr)
+	w.DecrementUsage(addr)
+	assert.False(t, acc.(*Account).IsLocked(), "Used account should be unlocked")
+	w.DecrementUsage(addr)
+	assert.True(t, acc.(*Account).IsLocked(), "Unused account should be locked")
+	assert.Panics(t, func() { w.DecrementUsage(addr) })
+
+	_, err = w.Unlock(addr)
+	require.NoError(t, err)
+	assert.NoError(t, w.LockAll())
+	assert.True(t, acc.(*Account).IsLocked(), "Account should be locked")
+}
+
 func TestSignatures(t *testing.T) {
 	w := connectTmpKeystore(t)
 	acc := w.Accounts()[0].(*Account)
@@ -0,0 +1,221 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+	"math/big"
+	"sync"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// Adjudicator is a channel.Adjudicator that registers, progresses and
+	// withdraws channels instantly on a simulated Ledger. Its behavior can be
+	// programmed with a Script, e.g., to let calls fail or block.
+	//
+	// Disputes are simulated by Register calls of any Adjudicator of the
+	// ledger with a stale, but fully signed state, like a malicious peer would
+	// do.
+	Adjudicator struct {
+		ledger *Ledger
+		script Script
+	}
+
+	// A Script programs the behavior of an Adjudicator. Every hook is optional
+	// and is called before the corresponding operation. If it returns an
+	// error, the call fails with it without changing the ledger. A hook may
+	// block, e.g., to simulate a slow blockchain, but should respect the
+	// context.
+	Script struct {
+		Register func(context.Context, channel.AdjudicatorReq) error
+		Progress func(context.Context, channel.ProgressReq) error
+		Withdraw func(context.Context, channel.AdjudicatorReq) error
+	}
+)
+
+var _ channel.ProgressingAdjudicator = (*Adjudicator)(nil)
+var _ channel.EventSubscriber = (*Adjudicator)(nil)
+var _ channel.ChainScanner = (*Adjudicator)(nil)
+var _ channel.CostEstimator = (*Adjudicator)(nil)
+
+// NewAdjudicator creates a new Adjudicator on the given Ledger.
+//
+// If the ledger is nil, NewAdjudicator panics.
+func NewAdjudicator(ledger *Ledger) *Adjudicator {
+	return NewScriptedAdjudicator(ledger, Script{})
+}
+
+// NewScriptedAdjudicator creates a new Adjudicator on the given Ledger whose
+// behavior is programmed by the script.
+//
+// If the ledger is nil, NewScriptedAdjudicator panics.
+func NewScriptedAdjudicator(ledger *Ledger, script Script) *Adjudicator {
+	if ledger == nil {
+		log.Panic("ledger must not be nil")
+	}
+	return &Adjudicator{ledger: ledger, script: script}
+}
+
+// Register registers the transaction of the request. A registered state can
+// be refuted by a newer state until its timeout. A final state concludes the
+// channel immediately. If the state cannot be registered because the channel
+// is already concluded, progressed or the registered state is newer or timed
+// out, the current registration is returned.
+func (a *Adjudicator) Register(ctx context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	if hook := a.script.Register; hook != nil {
+		if err := hook(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return a.ledger.register(req)
+}
+
+// Progress progresses the registered state of the channel to the new state of
+// the request. The registered state must be timed out.
+func (a *Adjudicator) Progress(ctx context.Context, req channel.ProgressReq) (*channel.Registered, error) {
+	if hook := a.script.Progress; hook != nil {
+		if err := hook(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+	return a.ledger.progress(req)
+}
+
+// Withdraw concludes the registered state, if it is timed out, and withdraws
+// the outcome of the participant. If the channel is underfunded, the
+// participant's deposit is withdrawn instead. Repeated calls are no-ops.
+func (a *Adjudicator) Withdraw(ctx context.Context, req channel.AdjudicatorReq) error {
+	if hook := a.script.Withdraw; hook != nil {
+		if err := hook(ctx, req); err != nil {
+			return err
+		}
+	}
+	return a.ledger.withdraw(req)
+}
+
+// ScanChannels returns all channels on the ledger with a registered state in
+// which part is a participant. The signatures of the states are not stored, so
+// their Sigs are nil.
+func (a *Adjudicator) ScanChannels(_ context.Context, part wallet.Address) ([]*channel.ScannedChannel, error) {
+	return a.ledger.scan(part), nil
+}
+
+// EstimateDisputeCosts returns zero costs and balance, since transactions on
+// the simulated ledger have no fees.
+func (a *Adjudicator) EstimateDisputeCosts(context.Context, channel.AdjudicatorReq) (*channel.DisputeCosts, error) {
+	return &channel.DisputeCosts{
+		Register: new(big.Int),
+		Progress: new(big.Int),
+		Withdraw: new(big.Int),
+		Required: new(big.Int),
+		Balance:  new(big.Int),
+	}, nil
+}
+
+// SubscribeRegistered returns a subscription of the registrations and
+// progressions of the channel.
+func (a *Adjudicator) SubscribeRegistered(ctx context.Context, params *channel.Params) (channel.RegisteredSubscription, error) {
+	isReg := func(ev ledgerEvent) bool { return ev.reg != nil }
+	return &registeredSub{eventSub: newEventSub(ctx, a.ledger, params.ID(), isReg)}, nil
+}
+
+// SubscribeEvents returns a subscription of all events of the channel.
+func (a *Adjudicator) SubscribeEvents(ctx context.Context, params *channel.Params) (channel.AdjudicatorSubscription, error) {
+	all := func(ledgerEvent) bool { return true }
+	return newEventSub(ctx, a.ledger, params.ID(), all), nil
+}
+
+// eventSub is a subscription of the events of a channel on a Ledger.
+type eventSub struct {
+	ctx    context.Context
+	ledger *Ledger
+	id     channel.ID
+	next   int // index of the next event
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+var _ channel.AdjudicatorSubscription = (*eventSub)(nil)
+
+// newEventSub creates a subscription that starts with the newest past event
+// that matches the filter.
+func newEventSub(ctx context.Context, ledger *Ledger, id channel.ID, filter func(ledgerEvent) bool) *eventSub {
+	return &eventSub{
+		ctx:    ctx,
+		ledger: ledger,
+		id:     id,
+		next:   ledger.newestEvent(id, filter),
+		closed: make(chan struct{}),
+	}
+}
+
+// Next returns the newest past or next future event. It returns nil if the
+// subscription is closed or its context is done.
+func (s *eventSub) Next() channel.AdjudicatorEvent {
+	if ev := s.nextEvent(); ev != nil {
+		return ev.ev
+	}
+	return nil
+}
+
+// nextEvent returns the next event, or nil if the subscription is closed or
+// its context is done.
+func (s *eventSub) nextEvent() *ledgerEvent {
+	for {
+		events, changed := s.ledger.events(s.id, s.next)
+		if len(events) > 0 {
+			s.next++
+			return &events[0]
+		}
+
+		select {
+		case <-changed:
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			return nil
+		case <-s.closed:
+			return nil
+		}
+	}
+}
+
+// Err returns the error of the subscription, if any.
+func (s *eventSub) Err() error {
+	return s.err
+}
+
+// Close closes the subscription.
+func (s *eventSub) Close() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+// registeredSub is a subscription of the registrations and progressions of a
+// channel on a Ledger.
+type registeredSub struct {
+	*eventSub
+}
+
+var _ channel.RegisteredSubscription = (*registeredSub)(nil)
+
+// Next returns the newest past or next future Registered event. It returns nil
+// if the subscription is closed or its context is done.
+func (s *registeredSub) Next() *channel.Registered {
+	for {
+		ev := s.nextEvent()
+		if ev == nil {
+			return nil
+		} else if ev.reg != nil {
+			return ev.reg
+		}
+	}
+}
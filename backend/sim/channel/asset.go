@@ -13,24 +13,41 @@ import (
 	"perun.network/go-perun/wire"
 )
 
-// Asset simulates a `channel.Asset` by only containing an `ID`
+// Asset simulates a `channel.Asset` by only containing an `ID` and the
+// `Ledger` on which it lives.
 type Asset struct {
-	ID int64
+	ID     int64
+	Ledger channel.LedgerID
 }
 
-var _ channel.Asset = new(Asset)
+var _ channel.LedgerAsset = new(Asset)
 
-// NewRandomAsset returns a new random sim Asset
+// NewRandomAsset returns a new random sim Asset on the default ledger
 func NewRandomAsset(rng *rand.Rand) *Asset {
 	return &Asset{ID: rng.Int63()}
 }
 
+// NewRandomLedgerAsset returns a new random sim Asset on the given ledger
+func NewRandomLedgerAsset(rng *rand.Rand, ledger channel.LedgerID) *Asset {
+	return &Asset{ID: rng.Int63(), Ledger: ledger}
+}
+
+// LedgerID returns the ledger of the sim Asset
+func (a Asset) LedgerID() channel.LedgerID {
+	return a.Ledger
+}
+
 // Encode encodes a sim Asset into the io.Writer `w`
 func (a Asset) Encode(w io.Writer) error {
-	return wire.Encode(w, a.ID)
+	return wire.Encode(w, a.ID, string(a.Ledger))
 }
 
 // Decode decodes a sim Asset from the io.Reader `r`
 func (a *Asset) Decode(r io.Reader) error {
-	return wire.Decode(r, &a.ID)
+	var ledger string
+	if err := wire.Decode(r, &a.ID, &ledger); err != nil {
+		return err
+	}
+	a.Ledger = channel.LedgerID(ledger)
+	return nil
 }
@@ -0,0 +1,30 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel"
+	iotest "perun.network/go-perun/pkg/io/test"
+)
+
+func TestAssetSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa55e7))
+	iotest.GenericSerializerTest(t, NewRandomAsset(rng))
+	iotest.GenericSerializerTest(t, NewRandomLedgerAsset(rng, "ledger"))
+}
+
+func TestAsset_LedgerID(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1ed9e7))
+	if l := channel.LedgerOf(NewRandomAsset(rng)); l != channel.DefaultLedger {
+		t.Errorf("ledger of random asset should be the default ledger, got %q", l)
+	}
+	if l := channel.LedgerOf(NewRandomLedgerAsset(rng, "A")); l != "A" {
+		t.Errorf("ledger of ledger asset should be \"A\", got %q", l)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+)
+
+// Funder is a channel.Funder that deposits instantly on a simulated Ledger.
+type Funder struct {
+	ledger *Ledger
+}
+
+var _ channel.Funder = (*Funder)(nil)
+
+// NewFunder creates a new Funder that deposits on the given Ledger.
+//
+// If the ledger is nil, NewFunder panics.
+func NewFunder(ledger *Ledger) *Funder {
+	if ledger == nil {
+		log.Panic("ledger must not be nil")
+	}
+	return &Funder{ledger: ledger}
+}
+
+// Fund deposits the own share of the request on the ledger and waits until
+// all other participants deposited theirs. If the context is done before, it
+// returns a FundingTimeoutError that lists the missing deposits.
+func (f *Funder) Fund(ctx context.Context, req channel.FundingReq) error {
+	if req.Params == nil || req.Allocation == nil {
+		return errors.New("invalid funding request")
+	}
+	f.ledger.deposit(req)
+
+	reported := make([][]bool, len(req.Allocation.Assets))
+	for a := range reported {
+		reported[a] = make([]bool, len(req.Params.Parts))
+	}
+	for {
+		funded, changed := f.ledger.funded(req)
+		complete := true
+		for a := range funded {
+			for i, ok := range funded[a] {
+				if ok && !reported[a][i] {
+					reported[a][i] = true
+					req.ReportProgress(channel.FundingProgress{Asset: a, Idx: channel.Index(i)})
+				}
+				complete = complete && ok
+			}
+		}
+		if complete {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return timeoutError(funded)
+		}
+	}
+}
+
+// timeoutError returns the FundingTimeoutError of the participants who did not
+// complete their deposits.
+func timeoutError(funded [][]bool) error {
+	var errs []*channel.AssetFundingError
+	for a := range funded {
+		var missing []channel.Index
+		for i, ok := range funded[a] {
+			if !ok {
+				missing = append(missing, channel.Index(i))
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, &channel.AssetFundingError{Asset: a, TimedOutPeers: missing})
+		}
+	}
+	return channel.NewFundingTimeoutError(errs)
+}
@@ -0,0 +1,401 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// A Ledger is an in-memory blockchain that holds the deposits and disputes
+	// of channels. The Funders and Adjudicators of all clients of a test share
+	// one Ledger, so that they observe each other's deposits and disputes
+	// without a blockchain node. Every operation is executed instantly.
+	//
+	// A Ledger only handles the assets of the channels that live on it, see
+	// channel.LedgerAsset. Assets that are no LedgerAssets live on the Ledger
+	// with the empty ID.
+	Ledger struct {
+		id   channel.LedgerID
+		unit time.Duration // duration of one unit of the challenge duration
+
+		mu       sync.Mutex
+		channels map[channel.ID]*ledgerChannel
+		changed  chan struct{} // closed and replaced on every change
+	}
+
+	// ledgerChannel is the on-chain state of a channel.
+	ledgerChannel struct {
+		params    *channel.Params
+		deposits  [][]channel.Bal // indexed like Allocation.OfParts
+		state     *channel.State  // registered state
+		reg       *channel.Registered
+		concluded bool
+		withdrawn [][]channel.Bal // withdrawn amounts, nil if not withdrawn
+		events    []ledgerEvent
+	}
+
+	// ledgerEvent is an event of a channel on the ledger. Registrations and
+	// progressions, also final registrations, additionally carry the
+	// Registered event.
+	ledgerEvent struct {
+		ev  channel.AdjudicatorEvent
+		reg *channel.Registered
+	}
+)
+
+// NewLedger creates a new empty Ledger with the given ID. The challenge
+// durations of channels are measured in seconds, see SetChallengeUnit.
+func NewLedger(id channel.LedgerID) *Ledger {
+	return &Ledger{
+		id:       id,
+		unit:     time.Second,
+		channels: make(map[channel.ID]*ledgerChannel),
+		changed:  make(chan struct{}),
+	}
+}
+
+// SetChallengeUnit sets the duration of one unit of the challenge duration of
+// channels. Tests can set it to a short duration, so that disputes time out
+// quickly.
+func (l *Ledger) SetChallengeUnit(unit time.Duration) {
+	if unit <= 0 {
+		log.Panic("challenge unit must be positive")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.unit = unit
+}
+
+// Registered returns the newest registration of the channel with the given
+// ID, or nil if no state is registered.
+func (l *Ledger) Registered(id channel.ID) *channel.Registered {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.channels[id]
+	if !ok || ch.reg == nil {
+		return nil
+	}
+	reg := *ch.reg
+	return &reg
+}
+
+// Withdrawn returns the amounts of the assets on this ledger that the
+// participant withdrew from the channel with the given ID, or nil, if it
+// didn't withdraw yet. Assets of other ledgers are zero.
+func (l *Ledger) Withdrawn(id channel.ID, idx channel.Index) []channel.Bal {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.channels[id]
+	if !ok || ch.withdrawn == nil || ch.withdrawn[idx] == nil {
+		return nil
+	}
+	return cloneBals(ch.withdrawn[idx])
+}
+
+// onLedger returns whether the asset lives on this ledger.
+func (l *Ledger) onLedger(asset channel.Asset) bool {
+	if la, ok := asset.(channel.LedgerAsset); ok {
+		return la.LedgerID() == l.id
+	}
+	return l.id == ""
+}
+
+// channel returns the on-chain state of the channel, which is created if it
+// does not exist yet. The ledger must be locked by the caller.
+func (l *Ledger) channel(params *channel.Params, numAssets int) *ledgerChannel {
+	ch, ok := l.channels[params.ID()]
+	if !ok {
+		ch = &ledgerChannel{params: params, deposits: make([][]channel.Bal, len(params.Parts))}
+		for i := range ch.deposits {
+			ch.deposits[i] = zeroBals(numAssets)
+		}
+		l.channels[params.ID()] = ch
+	}
+	return ch
+}
+
+// notify wakes up all waiting Funders and subscriptions. The ledger must be
+// locked by the caller.
+func (l *Ledger) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// emit appends the event and the current registration to the events of the
+// channel and notifies waiters. The ledger must be locked by the caller.
+func (l *Ledger) emit(ch *ledgerChannel, ev channel.AdjudicatorEvent) {
+	reg := *ch.reg
+	ch.events = append(ch.events, ledgerEvent{ev: ev, reg: &reg})
+	l.notify()
+}
+
+// deposit adds the participant's deposits of the assets on this ledger to
+// the channel.
+func (l *Ledger) deposit(req channel.FundingReq) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := l.channel(req.Params, len(req.Allocation.Assets))
+	for a, asset := range req.Allocation.Assets {
+		if l.onLedger(asset) {
+			ch.deposits[req.Idx][a].Add(ch.deposits[req.Idx][a], req.Deposits()[req.Idx][a])
+		}
+	}
+	l.notify()
+}
+
+// funded returns for every asset whether each participant completed its
+// deposit of the request, and a channel that is closed on the next change.
+// Assets of other ledgers are always funded.
+func (l *Ledger) funded(req channel.FundingReq) ([][]bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := l.channel(req.Params, len(req.Allocation.Assets))
+	funded := make([][]bool, len(req.Allocation.Assets))
+	for a, asset := range req.Allocation.Assets {
+		funded[a] = make([]bool, len(req.Params.Parts))
+		for i := range req.Params.Parts {
+			funded[a][i] = !l.onLedger(asset) || ch.deposits[i][a].Cmp(req.Deposits()[i][a]) >= 0
+		}
+	}
+	return funded, l.changed
+}
+
+// register registers the transaction of the request, see Adjudicator.Register.
+func (l *Ledger) register(req channel.AdjudicatorReq) (*channel.Registered, error) {
+	if err := verifyTx(req.Params, req.Tx); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := l.channel(req.Params, len(req.Tx.Allocation.Assets))
+	now := time.Now()
+	switch {
+	case ch.concluded, ch.reg != nil && ch.reg.Progressed:
+		// A concluded or progressed state cannot be refuted any more.
+	case ch.reg != nil && (ch.reg.Version >= req.Tx.Version || !now.Before(ch.reg.Timeout)):
+		// Only newer states can refute the registration until its timeout.
+	case req.Tx.IsFinal:
+		ch.state = req.Tx.State.Clone()
+		ch.reg = &channel.Registered{ID: req.Params.ID(), Idx: req.Idx, Version: req.Tx.Version, Timeout: now}
+		ch.concluded = true
+		l.emit(ch, &channel.ConcludedEvent{ID: req.Params.ID(), Version: req.Tx.Version})
+	default:
+		ch.state = req.Tx.State.Clone()
+		ch.reg = &channel.Registered{
+			ID:      req.Params.ID(),
+			Idx:     req.Idx,
+			Version: req.Tx.Version,
+			Timeout: now.Add(l.challengeDuration(req.Params)),
+		}
+		l.emit(ch, &channel.RegisteredEvent{ID: ch.reg.ID, Version: ch.reg.Version, Timeout: ch.reg.Timeout})
+	}
+	reg := *ch.reg
+	return &reg, nil
+}
+
+// progress progresses the registered state, see Adjudicator.Progress.
+func (l *Ledger) progress(req channel.ProgressReq) (*channel.Registered, error) {
+	app, ok := req.Params.App.(channel.StateApp)
+	if !ok {
+		return nil, errors.New("app of channel does not support progression")
+	}
+	if channel.IsAppUpgraded(req.Params, req.NewState) {
+		return nil, errors.New("channels with upgraded app cannot be progressed")
+	}
+	if ok, err := channel.Verify(req.Params.Parts[req.Idx], req.Params, req.NewState, req.Sig); err != nil {
+		return nil, errors.WithMessage(err, "verifying signature of actor")
+	} else if !ok {
+		return nil, errors.New("invalid signature of actor")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.channels[req.Params.ID()]
+	if !ok || ch.reg == nil {
+		return nil, errors.New("channel not registered")
+	} else if ch.concluded {
+		return nil, errors.New("channel already concluded")
+	}
+	now := time.Now()
+	if now.Before(ch.reg.Timeout) {
+		return nil, channel.NewChallengeTimeoutError(ch.reg.ID, ch.reg.Timeout)
+	}
+	if err := app.ValidTransition(req.Params, ch.state, req.NewState, req.Idx); err != nil {
+		return nil, errors.WithMessage(err, "invalid progression")
+	}
+
+	ch.state = req.NewState.Clone()
+	ch.reg = &channel.Registered{
+		ID:         req.Params.ID(),
+		Idx:        req.Idx,
+		Version:    req.NewState.Version,
+		Timeout:    now.Add(l.challengeDuration(req.Params)),
+		Progressed: true,
+	}
+	l.emit(ch, &channel.ProgressedEvent{ID: ch.reg.ID, Version: ch.reg.Version, Timeout: ch.reg.Timeout})
+	reg := *ch.reg
+	return &reg, nil
+}
+
+// scan returns all registered channels in which part is a participant, see
+// Adjudicator.ScanChannels.
+func (l *Ledger) scan(part wallet.Address) []*channel.ScannedChannel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var chans []*channel.ScannedChannel
+	for _, ch := range l.channels {
+		if ch.reg == nil || wallet.IndexOfAddr(ch.params.Parts, part) == -1 {
+			continue
+		}
+		chans = append(chans, &channel.ScannedChannel{
+			Params:    ch.params,
+			Tx:        channel.Transaction{State: ch.state.Clone()},
+			Timeout:   ch.reg.Timeout,
+			Concluded: ch.concluded,
+		})
+	}
+	return chans
+}
+
+// withdraw concludes the registered state, if necessary, and withdraws the
+// participant's outcome, see Adjudicator.Withdraw.
+func (l *Ledger) withdraw(req channel.AdjudicatorReq) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.channels[req.Params.ID()]
+	if !ok || ch.reg == nil {
+		return errors.New("channel not registered")
+	}
+	if !ch.concluded {
+		if time.Now().Before(ch.reg.Timeout) {
+			return channel.NewChallengeTimeoutError(ch.reg.ID, ch.reg.Timeout)
+		}
+		ch.concluded = true
+		ch.events = append(ch.events, ledgerEvent{ev: &channel.ConcludedEvent{ID: ch.reg.ID, Version: ch.reg.Version}})
+	}
+
+	if ch.withdrawn == nil {
+		ch.withdrawn = make([][]channel.Bal, len(req.Params.Parts))
+	}
+	if ch.withdrawn[req.Idx] != nil {
+		return nil // already withdrawn
+	}
+	outcome := ch.state.OfParts[req.Idx]
+	if l.underfunded(ch) {
+		outcome = ch.deposits[req.Idx]
+	}
+	ch.withdrawn[req.Idx] = zeroBals(len(ch.state.Assets))
+	for a, asset := range ch.state.Assets {
+		if l.onLedger(asset) {
+			ch.withdrawn[req.Idx][a].Set(outcome[a])
+		}
+	}
+	l.notify()
+	return nil
+}
+
+// underfunded returns whether the deposits of any asset on this ledger are
+// less than the registered outcome. The ledger must be locked by the caller.
+func (l *Ledger) underfunded(ch *ledgerChannel) bool {
+	for a, asset := range ch.state.Assets {
+		if !l.onLedger(asset) {
+			continue
+		}
+		deposits, outcome := new(big.Int), new(big.Int)
+		for i := range ch.deposits {
+			deposits.Add(deposits, ch.deposits[i][a])
+			outcome.Add(outcome, ch.state.OfParts[i][a])
+		}
+		if deposits.Cmp(outcome) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// events returns the events of the channel from index i on, and a channel
+// that is closed on the next change.
+func (l *Ledger) events(id channel.ID, i int) ([]ledgerEvent, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch, ok := l.channels[id]
+	if !ok || i >= len(ch.events) {
+		return nil, l.changed
+	}
+	return ch.events[i:len(ch.events):len(ch.events)], l.changed
+}
+
+// newestEvent returns the index of the newest event of the channel that
+// matches the filter, or 0 if there is none.
+func (l *Ledger) newestEvent(id channel.ID, filter func(ledgerEvent) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if ch, ok := l.channels[id]; ok {
+		for i := len(ch.events) - 1; i > 0; i-- {
+			if filter(ch.events[i]) {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// challengeDuration returns the challenge duration of the channel. The ledger
+// must be locked by the caller.
+func (l *Ledger) challengeDuration(params *channel.Params) time.Duration {
+	return time.Duration(params.ChallengeDuration) * l.unit
+}
+
+// verifyTx checks that the transaction is signed by all participants.
+func verifyTx(params *channel.Params, tx channel.Transaction) error {
+	if len(tx.Sigs) != len(params.Parts) {
+		return errors.Errorf("expected %d signatures, got %d", len(params.Parts), len(tx.Sigs))
+	}
+	for i, part := range params.Parts {
+		if ok, err := channel.Verify(part, params, tx.State, tx.Sigs[i]); err != nil {
+			return errors.WithMessagef(err, "verifying signature %d", i)
+		} else if !ok {
+			return errors.Errorf("invalid signature %d", i)
+		}
+	}
+	return nil
+}
+
+func zeroBals(n int) []channel.Bal {
+	bals := make([]channel.Bal, n)
+	for i := range bals {
+		bals[i] = new(big.Int)
+	}
+	return bals
+}
+
+func cloneBals(bals []channel.Bal) []channel.Bal {
+	clone := make([]channel.Bal, len(bals))
+	for i, b := range bals {
+		clone[i] = new(big.Int).Set(b)
+	}
+	return clone
+}
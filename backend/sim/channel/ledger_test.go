@@ -0,0 +1,259 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel // import "perun.network/go-perun/backend/sim/channel"
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/channel"
+	chtest "perun.network/go-perun/channel/test"
+	perun "perun.network/go-perun/wallet"
+)
+
+const challengeUnit = 20 * time.Millisecond
+
+func TestFunder_Fund(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0d))
+	ledger := NewLedger("")
+	accs, params, state := newLedgerChannel(rng)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	progress := make(chan channel.FundingProgress, 2)
+	errs := make(chan error, 2)
+	for i := range accs {
+		req := channel.FundingReq{Params: params, Allocation: &state.Allocation, Idx: channel.Index(i)}
+		if i == 0 {
+			req.Progress = func(p channel.FundingProgress) { progress <- p }
+		}
+		go func() { errs <- NewFunder(ledger).Fund(ctx, req) }()
+	}
+	for range accs {
+		assert.NoError(t, <-errs)
+	}
+	assert.ElementsMatch(t,
+		[]channel.FundingProgress{{Asset: 0, Idx: 0}, {Asset: 0, Idx: 1}},
+		[]channel.FundingProgress{<-progress, <-progress})
+
+	// The peer of another channel does not fund.
+	_, params, state = newLedgerChannel(rng)
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := NewFunder(ledger).Fund(short, channel.FundingReq{Params: params, Allocation: &state.Allocation, Idx: 0})
+	require.True(t, channel.IsFundingTimeoutError(err))
+	timeouts := errors.Cause(err).(*channel.FundingTimeoutError).Errors
+	require.Len(t, timeouts, 1)
+	assert.Equal(t, []channel.Index{1}, timeouts[0].TimedOutPeers)
+
+	assert.Panics(t, func() { NewFunder(nil) })
+}
+
+func TestAdjudicator_Dispute(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xd15b))
+	ledger := NewLedger("")
+	ledger.SetChallengeUnit(challengeUnit)
+	accs, params, state := newLedgerChannel(rng)
+	fund(ledger, params, state)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	adj := NewAdjudicator(ledger)
+	sub, err := adj.SubscribeEvents(ctx, params)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// Bob registers a stale state, which Alice refutes.
+	stale := signedTx(t, accs, params, state)
+	reg, err := adj.Register(ctx, channel.AdjudicatorReq{Params: params, Acc: accs[1], Tx: stale, Idx: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), reg.Version)
+	assert.IsType(t, &channel.RegisteredEvent{}, sub.Next())
+
+	state.Version = 1
+	state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+	state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+	tx := signedTx(t, accs, params, state)
+	req := channel.AdjudicatorReq{Params: params, Acc: accs[0], Tx: tx, Idx: 0}
+	reg, err = adj.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), reg.Version)
+	assert.Equal(t, reg, ledger.Registered(params.ID()))
+	ev, ok := sub.Next().(*channel.RegisteredEvent)
+	require.True(t, ok)
+	assert.Equal(t, uint64(1), ev.Version)
+
+	// The stale state cannot be registered again.
+	reg, err = adj.Register(ctx, channel.AdjudicatorReq{Params: params, Acc: accs[1], Tx: stale, Idx: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), reg.Version)
+
+	// After the timeout, the refuted state is concluded and withdrawn.
+	err = adj.Withdraw(ctx, req)
+	assert.True(t, channel.IsChallengeTimeoutError(err), "withdrawal before timeout")
+	time.Sleep(time.Until(reg.Timeout))
+	require.NoError(t, adj.Withdraw(ctx, req))
+	require.NoError(t, adj.Withdraw(ctx, req), "repeated withdrawal")
+	assert.IsType(t, &channel.ConcludedEvent{}, sub.Next())
+	assertWithdrawn(t, ledger, params, 0, 90)
+	assert.Nil(t, ledger.Withdrawn(params.ID(), 1))
+
+	// A new subscription returns the newest past registration.
+	regSub, err := adj.SubscribeRegistered(ctx, params)
+	require.NoError(t, err)
+	defer regSub.Close()
+	assert.Equal(t, reg, regSub.Next())
+}
+
+func TestAdjudicator_Final(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf17a1))
+	ledger := NewLedger("")
+	accs, params, state := newLedgerChannel(rng)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// Only Alice funded, so Bob's withdrawal of the final state is refunded.
+	aliceReq := channel.FundingReq{Params: params, Allocation: &state.Allocation, Idx: 0}
+	ledger.deposit(aliceReq)
+	state.IsFinal = true
+	state.Version = 2
+	adj := NewAdjudicator(ledger)
+	req := channel.AdjudicatorReq{Params: params, Acc: accs[1], Tx: signedTx(t, accs, params, state), Idx: 1}
+	reg, err := adj.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), reg.Version)
+	require.NoError(t, adj.Withdraw(ctx, req))
+	assertWithdrawn(t, ledger, params, 1, 0)
+}
+
+func TestAdjudicator_Progress(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x960))
+	ledger := NewLedger("")
+	ledger.SetChallengeUnit(challengeUnit)
+	accs, params, state := newLedgerChannel(rng)
+	fund(ledger, params, state)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	adj := NewAdjudicator(ledger)
+	req := channel.AdjudicatorReq{Params: params, Acc: accs[0], Tx: signedTx(t, accs, params, state), Idx: 0}
+	reg, err := adj.Register(ctx, req)
+	require.NoError(t, err)
+
+	next := state.Clone()
+	next.Version++
+	sig, err := channel.Sign(accs[0], params, next)
+	require.NoError(t, err)
+	progReq := channel.ProgressReq{AdjudicatorReq: req, NewState: next, Sig: sig}
+	_, err = adj.Progress(ctx, progReq)
+	assert.Error(t, err, "progression before timeout")
+
+	time.Sleep(time.Until(reg.Timeout))
+	upgraded := next.Clone()
+	upgraded.App = chtest.NewRandomApp(rng)
+	_, err = adj.Progress(ctx, channel.ProgressReq{AdjudicatorReq: req, NewState: upgraded, Sig: sig})
+	assert.Error(t, err, "progression of upgraded app")
+	reg, err = adj.Progress(ctx, progReq)
+	require.NoError(t, err)
+	assert.True(t, reg.Progressed)
+	assert.Equal(t, next.Version, reg.Version)
+
+	// A progressed state cannot be refuted.
+	reg, err = adj.Register(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, next.Version, reg.Version)
+}
+
+func TestAdjudicator_Script(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5c1))
+	ledger := NewLedger("")
+	accs, params, state := newLedgerChannel(rng)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	failure := errors.New("script failure")
+	adj := NewScriptedAdjudicator(ledger, Script{
+		Register: func(context.Context, channel.AdjudicatorReq) error { return failure },
+	})
+	req := channel.AdjudicatorReq{Params: params, Acc: accs[0], Tx: signedTx(t, accs, params, state), Idx: 0}
+	_, err := adj.Register(ctx, req)
+	assert.Equal(t, failure, err)
+	assert.Nil(t, ledger.Registered(params.ID()), "failed call must not change ledger")
+
+	assert.Panics(t, func() { NewAdjudicator(nil) })
+}
+
+func TestAdjudicator_Generic(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9e4e))
+	for _, progress := range []bool{false, true} {
+		ledger := NewLedger("")
+		ledger.SetChallengeUnit(challengeUnit)
+		accs, params, state := newLedgerChannel(rng)
+		fund(ledger, params, state)
+		chtest.GenericDisputeTest(t, rng, &chtest.DisputeSetup{
+			Adjudicator: NewAdjudicator(ledger),
+			Accounts:    accs,
+			Params:      params,
+			State:       state,
+			Progress:    progress,
+			Timeout:     time.Second,
+			Withdrawn:   ledger.Withdrawn,
+		})
+	}
+}
+
+// newLedgerChannel creates the accounts, parameters and initial state of a
+// two-party channel with balances 100/100 and a short challenge duration.
+func newLedgerChannel(rng *rand.Rand) ([]perun.Account, *channel.Params, *channel.State) {
+	accs := []perun.Account{wallet.NewRandomAccount(rng), wallet.NewRandomAccount(rng)}
+	parts := []perun.Address{accs[0].Address(), accs[1].Address()}
+	params := channel.NewParamsUnsafe(1, parts, chtest.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	state := &channel.State{
+		ID:  params.ID(),
+		App: params.App,
+		Allocation: channel.Allocation{
+			Assets:  []channel.Asset{NewRandomAsset(rng)},
+			OfParts: [][]channel.Bal{{big.NewInt(100)}, {big.NewInt(100)}},
+		},
+		Data: channel.NewMockOp(channel.OpValid),
+	}
+	return accs, params, state
+}
+
+// fund deposits the initial balances of all participants on the ledger.
+func fund(ledger *Ledger, params *channel.Params, state *channel.State) {
+	alloc := state.Allocation.Clone()
+	for i := range params.Parts {
+		ledger.deposit(channel.FundingReq{Params: params, Allocation: &alloc, Idx: channel.Index(i)})
+	}
+}
+
+// assertWithdrawn asserts that the participant withdrew the amount of the
+// single asset of the channel.
+func assertWithdrawn(t *testing.T, ledger *Ledger, params *channel.Params, idx channel.Index, amount int64) {
+	withdrawn := ledger.Withdrawn(params.ID(), idx)
+	require.Len(t, withdrawn, 1)
+	assert.Zero(t, withdrawn[0].Cmp(big.NewInt(amount)), "withdrawn %v != %v", withdrawn[0], amount)
+}
+
+// signedTx returns a transaction of the state signed by all accounts.
+func signedTx(t *testing.T, accs []perun.Account, params *channel.Params, state *channel.State) channel.Transaction {
+	tx := channel.Transaction{State: state.Clone(), Sigs: make([]perun.Sig, len(accs))}
+	for i, acc := range accs {
+		var err error
+		tx.Sigs[i], err = channel.Sign(acc, params, tx.State)
+		require.NoError(t, err)
+	}
+	return tx
+}
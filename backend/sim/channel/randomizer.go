@@ -17,3 +17,7 @@ type randomizer struct {
 func (randomizer) NewRandomAsset(rng *rand.Rand) channel.Asset {
 	return NewRandomAsset(rng)
 }
+
+func (randomizer) NewRandomLedgerAsset(rng *rand.Rand, ledger channel.LedgerID) channel.LedgerAsset {
+	return NewRandomLedgerAsset(rng, ledger)
+}
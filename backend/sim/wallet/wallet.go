@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet // import "perun.network/go-perun/backend/sim/wallet"
+
+import (
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+// Wallet is an in-memory wallet of simulated accounts. Simulated accounts
+// can always sign, so the Wallet only keeps track of which accounts are
+// unlocked and of their usage counters, which tests can inspect.
+type Wallet struct {
+	mu       sync.Mutex
+	accounts map[string]Account
+	unlocked map[string]bool
+	usages   map[string]int
+}
+
+var _ wallet.Wallet = (*Wallet)(nil)
+
+// NewWallet creates a new empty Wallet.
+func NewWallet() *Wallet {
+	return &Wallet{
+		accounts: make(map[string]Account),
+		unlocked: make(map[string]bool),
+		usages:   make(map[string]int),
+	}
+}
+
+// NewRandomAccount creates a new random account using the randomness
+// provided by rng and adds it to the wallet.
+func (w *Wallet) NewRandomAccount(rng io.Reader) Account {
+	acc := NewRandomAccount(rng)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.accounts[acc.Address().String()] = acc
+	return acc
+}
+
+// Unlock unlocks the account with the given address and returns it.
+func (w *Wallet) Unlock(addr wallet.Address) (wallet.Account, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := addr.String()
+	acc, ok := w.accounts[key]
+	if !ok {
+		return nil, errors.Errorf("unknown account %v", addr)
+	}
+	w.unlocked[key] = true
+	return acc, nil
+}
+
+// LockAll locks all accounts, regardless of their usage.
+func (w *Wallet) LockAll() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.unlocked = make(map[string]bool)
+	return nil
+}
+
+// IncrementUsage increments the usage counter of the account with the given
+// address.
+func (w *Wallet) IncrementUsage(addr wallet.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.usages[addr.String()]++
+}
+
+// DecrementUsage decrements the usage counter of the account with the given
+// address and locks the account if it is not used anymore.
+func (w *Wallet) DecrementUsage(addr wallet.Address) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := addr.String()
+	if w.usages[key] == 0 {
+		log.Panicf("account %v not in use", addr)
+	}
+	if w.usages[key]--; w.usages[key] > 0 {
+		return
+	}
+	delete(w.usages, key)
+	delete(w.unlocked, key)
+}
+
+// IsUnlocked returns whether the account with the given address is unlocked.
+func (w *Wallet) IsUnlocked(addr wallet.Address) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.unlocked[addr.String()]
+}
+
+// Usage returns the usage counter of the account with the given address.
+func (w *Wallet) Usage(addr wallet.Address) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.usages[addr.String()]
+}
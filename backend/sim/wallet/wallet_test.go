@@ -90,3 +90,30 @@ func newWalletSetup() *test.Setup {
 		AddressBytes:    accountB.Address().Bytes(),
 	}
 }
+
+func TestWallet_Usage(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a11e7))
+	w := NewWallet()
+	addr := w.NewRandomAccount(rng).Address()
+	_, err := w.Unlock(NewRandomAddress(rng))
+	assert.Error(t, err, "Unlock of unknown account should fail")
+
+	acc, err := w.Unlock(addr)
+	assert.NoError(t, err)
+	assert.True(t, acc.Address().Equals(addr))
+	assert.True(t, w.IsUnlocked(addr), "Account should be unlocked")
+
+	w.IncrementUsage(addr)
+	w.IncrementUsage(addr)
+	w.DecrementUsage(addr)
+	assert.Equal(t, 1, w.Usage(addr))
+	assert.True(t, w.IsUnlocked(addr), "Used account should be unlocked")
+	w.DecrementUsage(addr)
+	assert.False(t, w.IsUnlocked(addr), "Unused account should be locked")
+	assert.Panics(t, func() { w.DecrementUsage(addr) })
+
+	_, err = w.Unlock(addr)
+	assert.NoError(t, err)
+	assert.NoError(t, w.LockAll())
+	assert.False(t, w.IsUnlocked(addr), "Account should be locked")
+}
@@ -7,6 +7,7 @@ package channel
 
 import (
 	"context"
+	"math/big"
 	"time"
 
 	"perun.network/go-perun/wallet"
@@ -33,6 +34,13 @@ type (
 		// final outcome is set on the asset holders and funds are withdrawn
 		// (dependent on the architecture of the contracts). It must be taken into
 		// account that a peer might already have concluded the same channel.
+		// If the channel is underfunded, e.g., because a peer did not fund it,
+		// the participant's own deposit should be withdrawn instead.
+		//
+		// If the state contains assets of multiple ledgers, see LedgerAsset, the
+		// request is passed to the Adjudicators of all these ledgers unchanged,
+		// since the signatures are on the full state. An Adjudicator must then
+		// only withdraw the assets that live on its own ledger.
 		Withdraw(context.Context, AdjudicatorReq) error
 
 		// SubscribeRegistered returns a Registered event subscription. The
@@ -43,6 +51,134 @@ type (
 		SubscribeRegistered(context.Context, *Params) (RegisteredSubscription, error)
 	}
 
+	// A ProgressingAdjudicator is an Adjudicator that additionally supports the
+	// on-chain progression of app channels (force-execution). If a registered
+	// state timed out, any participant can advance it by valid state
+	// transitions of the channel's app, each signed only by the acting
+	// participant. Every progression starts a new challenge period.
+	ProgressingAdjudicator interface {
+		Adjudicator
+
+		// Progress should progress the registered state of the channel to the
+		// new state of the request. The first progression of a dispute is only
+		// possible after the timeout of the registration. If successful, it
+		// should return the Registered event of the progressed state.
+		Progress(context.Context, ProgressReq) (*Registered, error)
+	}
+
+	// An EventSubscriber is an Adjudicator that additionally supports
+	// subscribing to all on-chain events of a channel, not only Registered
+	// events. Watchers should prefer it over SubscribeRegistered, if available.
+	EventSubscriber interface {
+		Adjudicator
+
+		// SubscribeEvents returns an AdjudicatorEvent subscription of the channel
+		// with the given parameters. Like SubscribeRegistered, the subscription
+		// should return the newest past event first and should only be valid
+		// within the given context.
+		SubscribeEvents(context.Context, *Params) (AdjudicatorSubscription, error)
+	}
+
+	// A ChainScanner is an Adjudicator that can additionally find the channels
+	// of a participant from the on-chain state alone, e.g., to recover the
+	// funds of a client that lost its persisted channels, see
+	// client.RestoreFromChain.
+	//
+	// Only channels whose state was registered or concluded on-chain can be
+	// found, since the parameters and states of channels are not stored
+	// on-chain before.
+	ChainScanner interface {
+		Adjudicator
+
+		// ScanChannels should return all channels with on-chain state in which
+		// part is a participant, each one with its newest registered or concluded
+		// state.
+		ScanChannels(ctx context.Context, part wallet.Address) ([]*ScannedChannel, error)
+	}
+
+	// A ScannedChannel is a channel that was found on-chain by a ChainScanner.
+	ScannedChannel struct {
+		Params *Params
+		// Tx is the newest registered or concluded transaction. Sigs is nil if
+		// the backend doesn't store the signatures.
+		Tx        Transaction
+		Timeout   time.Time // Timeout when the state can be concluded or progressed.
+		Concluded bool      // Whether the channel is concluded already.
+	}
+
+	// A CostEstimator is an Adjudicator that can estimate the on-chain costs of
+	// a dispute, so that users can check that they are able to defend a channel
+	// before they enter it.
+	CostEstimator interface {
+		Adjudicator
+
+		// EstimateDisputeCosts should estimate the costs of registering the
+		// state of the request, progressing it, and withdrawing the funds of
+		// participant req.Idx, and query the balance of the on-chain account
+		// that pays them.
+		EstimateDisputeCosts(ctx context.Context, req AdjudicatorReq) (*DisputeCosts, error)
+	}
+
+	// DisputeCosts are the estimated on-chain costs of a dispute in the
+	// smallest unit of the chain's native currency, e.g., wei.
+	DisputeCosts struct {
+		Register, Progress, Withdraw *big.Int
+		// Required is the balance that is needed to register and withdraw. It
+		// can exceed the costs if transactions reserve more than they use.
+		Required *big.Int
+		Balance  *big.Int // balance of the account that pays the costs
+	}
+
+	// An AdjudicatorSubscription is a subscription to the AdjudicatorEvents of
+	// a specific channel. Its usage is the same as that of a
+	// RegisteredSubscription.
+	//
+	// If the backend observes a chain reorganization that removed an already
+	// returned event, it should return the newest event that is still on the
+	// chain again. So the same event may be returned more than once.
+	AdjudicatorSubscription interface {
+		// Next returns the newest past or next future event. If the subscription is
+		// closed or any other error occurs, it should return nil.
+		Next() AdjudicatorEvent
+
+		// Err returns the error status of the subscription. After Next returns nil,
+		// Err should be checked for an error.
+		Err() error
+
+		// Close closes the subscription. Any call to Next should immediately return
+		// nil.
+		Close() error
+	}
+
+	// An AdjudicatorEvent is an on-chain event of a channel. It is one of
+	// *RegisteredEvent, *ProgressedEvent and *ConcludedEvent.
+	AdjudicatorEvent interface {
+		adjudicatorEvent()
+	}
+
+	// RegisteredEvent signals that a state was registered on-chain, or that it
+	// refuted an older registered state. It can be refuted until Timeout.
+	RegisteredEvent struct {
+		ID      ID        // Channel ID
+		Version uint64    // Registered version.
+		Timeout time.Time // Timeout when the state can be concluded or progressed.
+	}
+
+	// ProgressedEvent signals that the registered state was progressed on-chain.
+	// Each progression starts a new challenge period, which ends at Timeout.
+	ProgressedEvent struct {
+		ID      ID        // Channel ID
+		Version uint64    // Progressed version.
+		Timeout time.Time // Timeout when the state can be concluded or progressed.
+	}
+
+	// ConcludedEvent signals that the channel was concluded on-chain, so that
+	// its funds can be withdrawn. No further events follow it.
+	ConcludedEvent struct {
+		ID      ID     // Channel ID
+		Version uint64 // Concluded version.
+	}
+
 	// An AdjudicatorReq collects all necessary information to make calls to the
 	// adjudicator.
 	AdjudicatorReq struct {
@@ -52,6 +188,15 @@ type (
 		Idx    Index
 	}
 
+	// A ProgressReq is a request to progress the registered state of a channel
+	// on-chain. The embedded AdjudicatorReq holds the registered transaction,
+	// or the last progressed state, and Idx is the index of the actor.
+	ProgressReq struct {
+		AdjudicatorReq
+		NewState *State     // New state, a valid transition of the app.
+		Sig      wallet.Sig // Signature of the actor on the new state.
+	}
+
 	// Registered is the abstract event that signals a successful state
 	// registration on the blockchain.
 	Registered struct {
@@ -59,6 +204,10 @@ type (
 		Idx     Index     // Index of the participant who registered the event.
 		Version uint64    // Registered version.
 		Timeout time.Time // Timeout when the event can be concluded or progressed
+
+		// Progressed is set if the state was progressed on-chain. It cannot be
+		// refuted any more, but only be progressed further.
+		Progressed bool
 	}
 
 	// A RegisteredSubscription is a subscription to Registered events for a
@@ -83,3 +232,12 @@ type (
 		Close() error
 	}
 )
+
+func (*RegisteredEvent) adjudicatorEvent() {}
+func (*ProgressedEvent) adjudicatorEvent() {}
+func (*ConcludedEvent) adjudicatorEvent()  {}
+
+// Sufficient returns whether the balance suffices to register and withdraw.
+func (c *DisputeCosts) Sufficient() bool {
+	return c.Balance.Cmp(c.Required) >= 0
+}
@@ -6,6 +6,7 @@
 package channel
 
 import (
+	"bytes"
 	"io"
 	"math/big"
 
@@ -245,6 +246,61 @@ func (a Allocation) Valid() error {
 	return nil
 }
 
+// ValidFor checks that the allocation is valid and that it has balances for
+// exactly numParts participants.
+func (a Allocation) ValidFor(numParts int) error {
+	if len(a.OfParts) != numParts {
+		return errors.Errorf("expected balances of %d participants, got %d", numParts, len(a.OfParts))
+	}
+	return a.Valid()
+}
+
+// Equal returns whether both allocations have equal assets, participant
+// balances and sub-allocations.
+func (a Allocation) Equal(b Allocation) bool {
+	if !EqualAssets(a.Assets, b.Assets) ||
+		len(a.OfParts) != len(b.OfParts) ||
+		len(a.Locked) != len(b.Locked) {
+		return false
+	}
+	for i := range a.OfParts {
+		if !equalBals(a.OfParts[i], b.OfParts[i]) {
+			return false
+		}
+	}
+	for i := range a.Locked {
+		if a.Locked[i].ID != b.Locked[i].ID || !equalBals(a.Locked[i].Bals, b.Locked[i].Bals) {
+			return false
+		}
+	}
+	return true
+}
+
+// Transfer moves amount of the asset with index asset from participant from
+// to participant to. It fails without modifying the allocation if an index is
+// out of range, if amount is negative or if the balance of from is smaller
+// than amount.
+func (a Allocation) Transfer(from, to Index, asset int, amount Bal) error {
+	if int(from) >= len(a.OfParts) || int(to) >= len(a.OfParts) {
+		return errors.Errorf("participant index out of range [0, %d)", len(a.OfParts))
+	}
+	if asset < 0 || asset >= len(a.OfParts[from]) || asset >= len(a.OfParts[to]) {
+		return errors.Errorf("asset index %d out of range", asset)
+	}
+	if amount.Sign() == -1 {
+		return errors.Errorf("amount is negative: %v", amount)
+	}
+	bal := a.OfParts[from][asset]
+	if bal.Cmp(amount) < 0 {
+		return errors.Errorf("balance[%d][%d] of %v is smaller than amount %v", from, asset, bal, amount)
+	}
+
+	amount = new(big.Int).Set(amount) // amount may alias one of the balances
+	bal.Sub(bal, amount)
+	a.OfParts[to][asset].Add(a.OfParts[to][asset], amount)
+	return nil
+}
+
 // Sum returns the sum of each asset over all participants and locked
 // allocations.
 func (a Allocation) Sum() []Bal {
@@ -271,6 +327,19 @@ func (a Allocation) Sum() []Bal {
 	return totals
 }
 
+// equalBals returns whether both balance slices are equal.
+func equalBals(a, b []Bal) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Cmp(b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // summer returns sums of balances
 type summer interface {
 	Sum() []Bal
@@ -291,6 +360,27 @@ func equalSum(b0, b1 summer) (bool, error) {
 	return true, nil
 }
 
+// EqualAssets returns whether both asset slices have equal encodings. An
+// encoding error causes false to be returned.
+func EqualAssets(a, b []Asset) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		var bufA, bufB bytes.Buffer
+		if err := a[i].Encode(&bufA); err != nil {
+			return false
+		}
+		if err := b[i].Encode(&bufB); err != nil {
+			return false
+		}
+		if !bytes.Equal(bufA.Bytes(), bufB.Bytes()) {
+			return false
+		}
+	}
+	return true
+}
+
 var _ perunio.Serializer = new(SubAlloc)
 
 // Valid checks if this suballocation is valid.
@@ -391,6 +391,68 @@ func TestAllocation_Valid(t *testing.T) {
 }
 
 // suballocation serialization
+func TestAllocation_ValidFor(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa110c))
+	alloc := *test.NewRandomAllocation(rng, 3)
+	assert.NoError(t, alloc.ValidFor(3))
+	assert.Error(t, alloc.ValidFor(2), "wrong number of participants")
+	alloc.OfParts[1] = alloc.OfParts[1][1:]
+	assert.Error(t, alloc.ValidFor(3), "dimension mismatch")
+}
+
+func TestAllocation_Equal(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa110c))
+	alloc := *test.NewRandomAllocation(rng, 2)
+	assert.True(t, alloc.Equal(alloc))
+	assert.True(t, alloc.Equal(alloc.Clone()))
+
+	modified := alloc.Clone()
+	modified.OfParts[0][0].Add(modified.OfParts[0][0], big.NewInt(1))
+	assert.False(t, alloc.Equal(modified), "participant balance")
+	modified = alloc.Clone()
+	modified.Locked[1].Bals[0].Add(modified.Locked[1].Bals[0], big.NewInt(1))
+	assert.False(t, alloc.Equal(modified), "sub-allocation balance")
+	modified = alloc.Clone()
+	modified.Locked[1].ID[0] ^= 1
+	assert.False(t, alloc.Equal(modified), "sub-allocation ID")
+	modified = alloc.Clone()
+	modified.Locked = modified.Locked[1:]
+	assert.False(t, alloc.Equal(modified), "number of sub-allocations")
+	modified = alloc.Clone()
+	modified.Assets[0] = test.NewRandomAsset(rng)
+	assert.False(t, alloc.Equal(modified), "assets")
+}
+
+func TestAllocation_Transfer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa110c))
+	alloc := channel.Allocation{
+		Assets: assets(rng, 2),
+		OfParts: [][]channel.Bal{
+			{big.NewInt(10), big.NewInt(20)},
+			{big.NewInt(30), big.NewInt(40)},
+		},
+	}
+
+	assert.NoError(t, alloc.Transfer(0, 1, 1, big.NewInt(5)))
+	assert.Equal(t, [][]channel.Bal{
+		{big.NewInt(10), big.NewInt(15)},
+		{big.NewInt(30), big.NewInt(45)},
+	}, alloc.OfParts)
+	// Transferring a balance to its owner aliases the amount.
+	assert.NoError(t, alloc.Transfer(1, 1, 0, alloc.OfParts[1][0]))
+	assert.Equal(t, big.NewInt(30), alloc.OfParts[1][0])
+	assert.NoError(t, alloc.Transfer(1, 0, 0, alloc.OfParts[1][0]))
+	assert.Equal(t, big.NewInt(40), alloc.OfParts[0][0])
+	assert.Zero(t, alloc.OfParts[1][0].Sign())
+
+	unchanged := alloc.Clone()
+	assert.Error(t, alloc.Transfer(1, 0, 0, big.NewInt(1)), "underflow")
+	assert.Error(t, alloc.Transfer(0, 2, 0, big.NewInt(1)), "participant out of range")
+	assert.Error(t, alloc.Transfer(0, 1, 2, big.NewInt(1)), "asset out of range")
+	assert.Error(t, alloc.Transfer(0, 1, 0, big.NewInt(-1)), "negative amount")
+	assert.True(t, alloc.Equal(unchanged))
+}
+
 func TestSuballocSerialization(t *testing.T) {
 	ss := []perunio.Serializer{
 		&channel.SubAlloc{channel.ID{2}, []channel.Bal{}},
@@ -44,7 +44,9 @@ type (
 
 		// ValidInit should perform app-specific checks for a valid initial state.
 		// The framework guarantees to only pass initial states with version == 0,
-		// correct channel ID and valid initial allocation.
+		// correct channel ID and valid initial allocation. It is also called on
+		// the first state after the app of a channel was upgraded to this app,
+		// whose version is not 0 and whose parameters have the previous app.
 		ValidInit(*Params, *State) error
 	}
 
@@ -97,7 +99,7 @@ type (
 		// possible that multiple apps are in use, which is why creation happens
 		// over a central AppFromDefinition function.  One possible implementation
 		// is that the app is just read from an app registry, mapping addresses to
-		// apps.
+		// apps, see AppRegistry.
 		AppFromDefinition(wallet.Address) (App, error)
 	}
 )
@@ -114,6 +116,13 @@ func IsActionApp(app App) bool {
 	return ok
 }
 
+// IsAppUpgraded returns whether the app of the state differs from the app of
+// the channel parameters, i.e., whether the channel's app was upgraded by an
+// off-chain update. Adjudicators only know the app of the parameters.
+func IsAppUpgraded(params *Params, state *State) bool {
+	return !params.App.Def().Equals(state.App.Def())
+}
+
 // appBackend stores the AppBackend globally for the channel package.
 var appBackend AppBackend = &MockAppBackend{}
 
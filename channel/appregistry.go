@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+// AppRegistry is an AppBackend that looks up apps by their app definition.
+// Multiple apps can be plugged in by registering them with Register. App
+// definitions that are not registered are resolved by the fallback backend,
+// if any.
+//
+// An AppRegistry can be used as the global app backend with SetAppBackend.
+type AppRegistry struct {
+	mtx      sync.RWMutex
+	apps     map[string]App // keyed by the app definition's bytes
+	fallback AppBackend
+}
+
+var _ AppBackend = (*AppRegistry)(nil)
+
+// NewAppRegistry creates a new empty AppRegistry. fallback is used to resolve
+// unregistered app definitions and may be nil.
+func NewAppRegistry(fallback AppBackend) *AppRegistry {
+	return &AppRegistry{
+		apps:     make(map[string]App),
+		fallback: fallback,
+	}
+}
+
+// Register registers the app under its app definition. It returns an error if
+// an app with the same definition is already registered. Panics if the app is
+// nil.
+func (r *AppRegistry) Register(app App) error {
+	if app == nil {
+		log.Panic("app must not be nil")
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	key := string(app.Def().Bytes())
+	if _, ok := r.apps[key]; ok {
+		return errors.Errorf("app %v already registered", app.Def())
+	}
+	r.apps[key] = app
+	return nil
+}
+
+// AppFromDefinition returns the app that is registered under the given app
+// definition. If there is none, the fallback backend is asked. If there is no
+// fallback backend either, an error is returned.
+func (r *AppRegistry) AppFromDefinition(def wallet.Address) (App, error) {
+	r.mtx.RLock()
+	app, ok := r.apps[string(def.Bytes())]
+	r.mtx.RUnlock()
+
+	if ok {
+		return app, nil
+	} else if r.fallback != nil {
+		return r.fallback.AppFromDefinition(def)
+	}
+	return nil, errors.Errorf("no app registered for definition %v", def)
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestAppRegistry(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa99))
+	app := NewMockApp(wallettest.NewRandomAddress(rng))
+	unregistered := wallettest.NewRandomAddress(rng)
+
+	r := NewAppRegistry(nil)
+	require.NoError(t, r.Register(app))
+	assert.Error(t, r.Register(NewMockApp(app.Def())), "registering twice")
+	assert.Panics(t, func() { r.Register(nil) })
+
+	got, err := r.AppFromDefinition(app.Def())
+	require.NoError(t, err)
+	assert.Same(t, app, got)
+	_, err = r.AppFromDefinition(unregistered)
+	assert.Error(t, err, "unregistered app without fallback")
+
+	// With a fallback, unregistered definitions are resolved by it.
+	r = NewAppRegistry(new(MockAppBackend))
+	require.NoError(t, r.Register(app))
+	got, err = r.AppFromDefinition(app.Def())
+	require.NoError(t, err)
+	assert.Same(t, app, got)
+	got, err = r.AppFromDefinition(unregistered)
+	require.NoError(t, err)
+	assert.True(t, got.Def().Equals(unregistered))
+}
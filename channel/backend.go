@@ -20,6 +20,9 @@ var backend Backend
 type Backend interface {
 	// CalcID infers the channel id of a channel from its parameters. Usually,
 	// this should be a hash digest of some or all fields of the parameters.
+	// It must be deterministic in the challenge duration, participants, app
+	// and nonce, so that all participants derive the same ID from the
+	// proposal and its acceptance.
 	// In order to guarantee non-malleability of States, any parameters omitted
 	// from the CalcID digest need to be signed together with the State in
 	// Sign().
@@ -50,7 +53,7 @@ func SetBackend(b Backend) {
 	backend = b
 }
 
-// CalcID calculates the CalcID.
+// CalcID calculates the channel ID of the parameters p.
 func CalcID(p *Params) ID {
 	return backend.CalcID(p)
 }
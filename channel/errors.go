@@ -7,6 +7,7 @@ package channel
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -29,6 +30,13 @@ type (
 		current Phase
 		PhaseTransition
 	}
+
+	// ChallengeTimeoutError happens if the registered state of a channel is
+	// concluded or progressed before its challenge period ends at Timeout
+	ChallengeTimeoutError struct {
+		ID      ID
+		Timeout time.Time
+	}
 )
 
 func (e *StateTransitionError) Error() string {
@@ -46,6 +54,10 @@ func (e *PhaseTransitionError) Error() string {
 	)
 }
 
+func (e *ChallengeTimeoutError) Error() string {
+	return fmt.Sprintf("challenge period not over until %v (ID: %x)", e.Timeout, e.ID)
+}
+
 // NewStateTransitionError creates a new StateTransitionError.
 func NewStateTransitionError(id ID, msg string) error {
 	return errors.Wrap(&StateTransitionError{
@@ -60,6 +72,14 @@ func NewActionError(id ID, msg string) error {
 	}, msg)
 }
 
+// NewChallengeTimeoutError creates a new ChallengeTimeoutError.
+func NewChallengeTimeoutError(id ID, timeout time.Time) error {
+	return errors.WithStack(&ChallengeTimeoutError{
+		ID:      id,
+		Timeout: timeout,
+	})
+}
+
 func newPhaseTransitionError(id ID, current Phase, expected PhaseTransition, msg string) error {
 	return errors.Wrap(&PhaseTransitionError{
 		ID:              id,
@@ -102,3 +122,11 @@ func IsPhaseTransitionError(err error) bool {
 	_, ok := cause.(*PhaseTransitionError)
 	return ok
 }
+
+// IsChallengeTimeoutError returns true if the error was a
+// ChallengeTimeoutError.
+func IsChallengeTimeoutError(err error) bool {
+	cause := errors.Cause(err)
+	_, ok := cause.(*ChallengeTimeoutError)
+	return ok
+}
@@ -8,6 +8,7 @@ package channel
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,4 +25,7 @@ func TestTransitionErrors(t *testing.T) {
 		Zero, InitActing, PhaseTransition{InitActing, InitActing}, "A PhaseTransitionError")))
 	assert.True(t, IsPhaseTransitionError(newPhaseTransitionErrorf(
 		Zero, InitActing, PhaseTransition{InitActing, InitActing}, "A %s", "PhaseTransitionError")))
+
+	assert.False(t, IsChallengeTimeoutError(errors.New("No ChallengeTimeoutError")))
+	assert.True(t, IsChallengeTimeoutError(NewChallengeTimeoutError(Zero, time.Now())))
 }
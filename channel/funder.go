@@ -25,6 +25,9 @@ type (
 		// return a PeerTimedOutFundingError containing the index of the peer who
 		// did not fund in time. The framework will then initiate the dispute
 		// process.
+		// Fund should also stop waiting for the deposits of other participants
+		// when the context is cancelled, so that a stuck funding can be
+		// aborted, and return a FundingTimeoutError then.
 		Fund(context.Context, FundingReq) error
 	}
 
@@ -33,6 +36,22 @@ type (
 		Params     *Params
 		Allocation *Allocation
 		Idx        Index // our index
+		// Agreement is the amount of each asset that each participant deposits,
+		// indexed like Allocation.OfParts. If it is nil, every participant
+		// deposits its initial balances.
+		Agreement [][]Bal
+		// Progress is an optional callback that the Funder calls whenever a
+		// participant completed its deposit of an asset, see ReportProgress.
+		// It may be called concurrently for different assets. A Funder that
+		// wraps another Funder can set it to observe the progress of funding.
+		Progress func(FundingProgress)
+	}
+
+	// FundingProgress reports that a participant completed its deposit of an
+	// asset.
+	FundingProgress struct {
+		Asset int   // index of the asset in the allocation
+		Idx   Index // index of the participant
 	}
 
 	// A FundingTimeoutError indicates that some peers failed funding some assets in time.
@@ -47,6 +66,23 @@ type (
 	}
 )
 
+// Deposits returns the amount of each asset that each participant deposits,
+// indexed like Allocation.OfParts. It is the Agreement, if set, or the initial
+// balances otherwise.
+func (r FundingReq) Deposits() [][]Bal {
+	if r.Agreement != nil {
+		return r.Agreement
+	}
+	return r.Allocation.OfParts
+}
+
+// ReportProgress calls the Progress callback, if it is set.
+func (r FundingReq) ReportProgress(p FundingProgress) {
+	if r.Progress != nil {
+		r.Progress(p)
+	}
+}
+
 // NewFundingTimeoutError creates a new FundingTimeoutError.
 func NewFundingTimeoutError(fundingErrs []*AssetFundingError) error {
 	if len(fundingErrs) == 0 {
@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package channel_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	perunio "perun.network/go-perun/pkg/io"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// The fuzz targets check that decoding arbitrary input does not panic and that
+// successfully decoded values can be encoded again. They are seeded with
+// encodings of random values. Run them with, e.g.,
+//
+//	go test -fuzz FuzzAllocation_Decode ./channel
+
+func FuzzAllocation_Decode(f *testing.F) {
+	rng := rand.New(rand.NewSource(0xf022))
+	for i := 0; i < 4; i++ {
+		addSeed(f, test.NewRandomAllocation(rng, 2+i))
+	}
+	fuzzDecode(f, func() perunio.Serializer { return new(channel.Allocation) })
+}
+
+func FuzzParams_Decode(f *testing.F) {
+	rng := rand.New(rand.NewSource(0xf023))
+	for i := 0; i < 4; i++ {
+		addSeed(f, test.NewRandomParams(rng, wallettest.NewRandomAddress(rng)))
+	}
+	fuzzDecode(f, func() perunio.Serializer { return new(channel.Params) })
+}
+
+func FuzzState_Decode(f *testing.F) {
+	rng := rand.New(rand.NewSource(0xf024))
+	for i := 0; i < 4; i++ {
+		params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+		addSeed(f, test.NewRandomState(rng, params))
+	}
+	fuzzDecode(f, func() perunio.Serializer { return new(channel.State) })
+}
+
+// addSeed adds the encoding of v to the seed corpus.
+func addSeed(f *testing.F, v perunio.Encoder) {
+	var buf bytes.Buffer
+	if err := v.Encode(&buf); err != nil {
+		f.Fatal(err)
+	}
+	f.Add(buf.Bytes())
+}
+
+// fuzzDecode decodes the fuzzed data into new values and encodes them again
+// if decoding succeeded.
+func fuzzDecode(f *testing.F, newValue func() perunio.Serializer) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v := newValue()
+		if err := v.Decode(bytes.NewReader(data)); err != nil {
+			return
+		}
+		if err := v.Encode(new(bytes.Buffer)); err != nil {
+			t.Errorf("encoding decoded value: %v", err)
+		}
+	})
+}
@@ -0,0 +1,351 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+
+	"github.com/pkg/errors"
+
+	perunio "perun.network/go-perun/pkg/io"
+	"perun.network/go-perun/wallet"
+)
+
+// The JSON encodings of the channel types are meant for external tooling, like
+// REST APIs, CLIs and dashboards. Balances and nonces are decimal strings, IDs
+// and signatures are 0x-prefixed hex strings. Backend-specific objects, i.e.,
+// addresses, assets, app definitions and app data, are the hex strings of their
+// binary encodings, so that decoding uses the backends like the wire format.
+
+type (
+	jsonAllocation struct {
+		Assets   []string       `json:"assets"`
+		Balances [][]string     `json:"balances"`
+		Locked   []jsonSubAlloc `json:"locked,omitempty"`
+	}
+
+	jsonSubAlloc struct {
+		ID       string   `json:"id"`
+		Balances []string `json:"balances"`
+	}
+
+	jsonParams struct {
+		ID                string   `json:"id"`
+		ChallengeDuration uint64   `json:"challengeDuration"`
+		Parts             []string `json:"parts"`
+		App               string   `json:"app"`
+		Nonce             string   `json:"nonce"`
+	}
+
+	jsonState struct {
+		ID         string          `json:"id"`
+		Version    uint64          `json:"version"`
+		App        string          `json:"app"`
+		Allocation json.RawMessage `json:"allocation"`
+		Data       string          `json:"data"`
+		IsFinal    bool            `json:"isFinal"`
+	}
+
+	jsonTransaction struct {
+		State *State   `json:"state"`
+		Sigs  []string `json:"sigs"`
+	}
+)
+
+var (
+	_ json.Marshaler   = Allocation{}
+	_ json.Unmarshaler = (*Allocation)(nil)
+	_ json.Marshaler   = (*Params)(nil)
+	_ json.Unmarshaler = (*Params)(nil)
+	_ json.Marshaler   = State{}
+	_ json.Unmarshaler = (*State)(nil)
+	_ json.Marshaler   = Transaction{}
+	_ json.Unmarshaler = (*Transaction)(nil)
+)
+
+// MarshalJSON encodes the allocation as JSON.
+func (a Allocation) MarshalJSON() ([]byte, error) {
+	ja := jsonAllocation{
+		Assets:   make([]string, len(a.Assets)),
+		Balances: make([][]string, len(a.OfParts)),
+	}
+	for i, asset := range a.Assets {
+		var err error
+		if ja.Assets[i], err = perunio.EncodeHex(asset); err != nil {
+			return nil, errors.WithMessagef(err, "encoding asset %d", i)
+		}
+	}
+	for i, bals := range a.OfParts {
+		ja.Balances[i] = BalsToJSON(bals)
+	}
+	for _, sub := range a.Locked {
+		ja.Locked = append(ja.Locked, jsonSubAlloc{
+			ID:       perunio.HexBytes(sub.ID[:]),
+			Balances: BalsToJSON(sub.Bals),
+		})
+	}
+	return json.Marshal(ja)
+}
+
+// UnmarshalJSON decodes an allocation from JSON, see MarshalJSON. The assets
+// are decoded with the AppBackend. The allocation is checked with Valid.
+func (a *Allocation) UnmarshalJSON(data []byte) (err error) {
+	var ja jsonAllocation
+	if err := json.Unmarshal(data, &ja); err != nil {
+		return errors.Wrap(err, "decoding allocation")
+	}
+	if len(ja.Assets) > MaxNumAssets || len(ja.Balances) > MaxNumParts || len(ja.Locked) > MaxNumSubAllocations {
+		return errors.New("allocation too large")
+	}
+
+	alloc := Allocation{
+		Assets:  make([]Asset, len(ja.Assets)),
+		OfParts: make([][]Bal, len(ja.Balances)),
+	}
+	for i, s := range ja.Assets {
+		if err := perunio.DecodeHex(s, func(r io.Reader) (err error) {
+			alloc.Assets[i], err = DecodeAsset(r)
+			return err
+		}); err != nil {
+			return errors.WithMessagef(err, "decoding asset %d", i)
+		}
+	}
+	for i, bals := range ja.Balances {
+		if alloc.OfParts[i], err = BalsFromJSON(bals); err != nil {
+			return errors.WithMessagef(err, "decoding balances of participant %d", i)
+		}
+	}
+	for i, sub := range ja.Locked {
+		var sa SubAlloc
+		if sa.ID, err = parseID(sub.ID); err != nil {
+			return errors.WithMessagef(err, "decoding ID of sub-allocation %d", i)
+		}
+		if sa.Bals, err = BalsFromJSON(sub.Balances); err != nil {
+			return errors.WithMessagef(err, "decoding balances of sub-allocation %d", i)
+		}
+		alloc.Locked = append(alloc.Locked, sa)
+	}
+	if err := alloc.Valid(); err != nil {
+		return err
+	}
+	*a = alloc
+	return nil
+}
+
+// MarshalJSON encodes the parameters as JSON, including their ID.
+func (p *Params) MarshalJSON() ([]byte, error) {
+	jp := jsonParams{
+		ID:                perunio.HexBytes(p.id[:]),
+		ChallengeDuration: p.ChallengeDuration,
+		Nonce:             p.Nonce.String(),
+	}
+	var err error
+	if jp.Parts, err = addrsToJSON(p.Parts); err != nil {
+		return nil, err
+	}
+	if jp.App, err = perunio.EncodeHex(p.App.Def()); err != nil {
+		return nil, errors.WithMessage(err, "encoding app definition")
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON decodes parameters from JSON, see MarshalJSON. The decoded
+// parameters are checked with ValidateParameters and the ID is calculated. If
+// an ID is given, it must match the calculated ID.
+func (p *Params) UnmarshalJSON(data []byte) error {
+	var jp jsonParams
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return errors.Wrap(err, "decoding params")
+	}
+	if len(jp.Parts) > MaxNumParts {
+		return errors.Errorf("too many participants, got: %d max: %d", len(jp.Parts), MaxNumParts)
+	}
+	parts, err := addrsFromJSON(jp.Parts)
+	if err != nil {
+		return err
+	}
+	appDef, err := addrFromJSON(jp.App)
+	if err != nil {
+		return errors.WithMessage(err, "decoding app definition")
+	}
+	nonce, ok := new(big.Int).SetString(jp.Nonce, 10)
+	if !ok {
+		return errors.Errorf("invalid nonce %q", jp.Nonce)
+	}
+
+	params, err := NewParams(jp.ChallengeDuration, parts, appDef, nonce)
+	if err != nil {
+		return err
+	}
+	if jp.ID != "" {
+		if id, err := parseID(jp.ID); err != nil {
+			return errors.WithMessage(err, "decoding ID")
+		} else if id != params.ID() {
+			return errors.New("ID does not match params")
+		}
+	}
+	*p = *params
+	return nil
+}
+
+// MarshalJSON encodes the state as JSON.
+func (s State) MarshalJSON() ([]byte, error) {
+	js := jsonState{
+		ID:      perunio.HexBytes(s.ID[:]),
+		Version: s.Version,
+		IsFinal: s.IsFinal,
+	}
+	var err error
+	if js.App, err = perunio.EncodeHex(s.App.Def()); err != nil {
+		return nil, errors.WithMessage(err, "encoding app definition")
+	}
+	if js.Allocation, err = json.Marshal(s.Allocation); err != nil {
+		return nil, errors.WithMessage(err, "encoding allocation")
+	}
+	if js.Data, err = perunio.EncodeHex(s.Data); err != nil {
+		return nil, errors.WithMessage(err, "encoding app data")
+	}
+	return json.Marshal(js)
+}
+
+// UnmarshalJSON decodes a state from JSON, see MarshalJSON. The app is
+// resolved with AppFromDefinition and decodes the data.
+func (s *State) UnmarshalJSON(data []byte) (err error) {
+	var js jsonState
+	if err := json.Unmarshal(data, &js); err != nil {
+		return errors.Wrap(err, "decoding state")
+	}
+	state := State{Version: js.Version, IsFinal: js.IsFinal}
+	if state.ID, err = parseID(js.ID); err != nil {
+		return errors.WithMessage(err, "decoding ID")
+	}
+	def, err := addrFromJSON(js.App)
+	if err != nil {
+		return errors.WithMessage(err, "decoding app definition")
+	}
+	if state.App, err = AppFromDefinition(def); err != nil {
+		return errors.WithMessage(err, "app from definition")
+	}
+	if err := json.Unmarshal(js.Allocation, &state.Allocation); err != nil {
+		return errors.WithMessage(err, "decoding allocation")
+	}
+	if err := perunio.DecodeHex(js.Data, func(r io.Reader) (err error) {
+		state.Data, err = state.App.DecodeData(r)
+		return err
+	}); err != nil {
+		return errors.WithMessage(err, "decoding app data")
+	}
+	*s = state
+	return nil
+}
+
+// MarshalJSON encodes the transaction as JSON. Missing signatures are null.
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	jt := jsonTransaction{State: t.State, Sigs: make([]string, len(t.Sigs))}
+	for i, sig := range t.Sigs {
+		if sig != nil {
+			jt.Sigs[i] = perunio.HexBytes(sig)
+		}
+	}
+	return json.Marshal(jt)
+}
+
+// UnmarshalJSON decodes a transaction from JSON, see MarshalJSON. The
+// signatures are not verified.
+func (t *Transaction) UnmarshalJSON(data []byte) error {
+	var jt jsonTransaction
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return errors.WithMessage(err, "decoding transaction")
+	}
+	if len(jt.Sigs) > MaxNumParts {
+		return errors.Errorf("too many signatures, got: %d max: %d", len(jt.Sigs), MaxNumParts)
+	}
+	tx := Transaction{State: jt.State, Sigs: make([]wallet.Sig, len(jt.Sigs))}
+	for i, s := range jt.Sigs {
+		if s == "" {
+			continue
+		}
+		sig, err := perunio.ParseHexBytes(s)
+		if err != nil {
+			return errors.WithMessagef(err, "decoding signature %d", i)
+		}
+		tx.Sigs[i] = sig
+	}
+	*t = tx
+	return nil
+}
+
+// BalsToJSON returns the balances as decimal strings, as used by the JSON
+// encodings of the channel types.
+func BalsToJSON(bals []Bal) []string {
+	strs := make([]string, len(bals))
+	for i, bal := range bals {
+		strs[i] = bal.String()
+	}
+	return strs
+}
+
+// BalsFromJSON parses balances from decimal strings, see BalsToJSON.
+func BalsFromJSON(strs []string) ([]Bal, error) {
+	if len(strs) > MaxNumAssets {
+		return nil, errors.Errorf("too many balances, got: %d max: %d", len(strs), MaxNumAssets)
+	}
+	bals := make([]Bal, len(strs))
+	for i, s := range strs {
+		var ok bool
+		if bals[i], ok = new(big.Int).SetString(s, 10); !ok {
+			return nil, errors.Errorf("invalid balance %q", s)
+		}
+	}
+	return bals, nil
+}
+
+// addrsToJSON returns the hex encodings of the addresses.
+func addrsToJSON(addrs []wallet.Address) ([]string, error) {
+	strs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		var err error
+		if strs[i], err = perunio.EncodeHex(addr); err != nil {
+			return nil, errors.WithMessagef(err, "encoding address %d", i)
+		}
+	}
+	return strs, nil
+}
+
+// addrsFromJSON decodes addresses from their hex encodings.
+func addrsFromJSON(strs []string) ([]wallet.Address, error) {
+	addrs := make([]wallet.Address, len(strs))
+	for i, s := range strs {
+		var err error
+		if addrs[i], err = addrFromJSON(s); err != nil {
+			return nil, errors.WithMessagef(err, "decoding address %d", i)
+		}
+	}
+	return addrs, nil
+}
+
+// addrFromJSON decodes an address from its hex encoding.
+func addrFromJSON(s string) (addr wallet.Address, err error) {
+	err = perunio.DecodeHex(s, func(r io.Reader) error {
+		addr, err = wallet.DecodeAddress(r)
+		return err
+	})
+	return addr, err
+}
+
+// parseID parses a channel ID from its hex encoding.
+func parseID(s string) (id ID, err error) {
+	b, err := perunio.ParseHexBytes(s)
+	if err != nil {
+		return id, err
+	} else if len(b) != len(id) {
+		return id, errors.Errorf("invalid ID length %d", len(b))
+	}
+	copy(id[:], b)
+	return id, nil
+}
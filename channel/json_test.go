@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	perunio "perun.network/go-perun/pkg/io"
+	iotest "perun.network/go-perun/pkg/io/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+)
+
+// jsonRoundTrip marshals v, unmarshals it into w and checks that both have the
+// same binary encoding.
+func jsonRoundTrip(t *testing.T, v, w perunio.Encoder) []byte {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, w))
+	eq, err := iotest.EqualEncoding(v, w)
+	require.NoError(t, err)
+	assert.True(t, eq, "JSON round trip changed %T", v)
+	return data
+}
+
+func TestParams_JSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x150))
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	var decoded channel.Params
+	data := jsonRoundTrip(t, params, &decoded)
+	assert.Equal(t, params.ID(), decoded.ID())
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &fields))
+	assert.Equal(t, params.Nonce.String(), fields["nonce"])
+
+	// A wrong ID is rejected.
+	id, other := params.ID(), test.NewRandomChannelID(rng)
+	data = []byte(strings.Replace(string(data), perunio.HexBytes(id[:]), perunio.HexBytes(other[:]), 1))
+	assert.Error(t, json.Unmarshal(data, &decoded))
+}
+
+func TestAllocation_JSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa110c))
+	for i := 0; i < 8; i++ {
+		alloc := test.NewRandomAllocation(rng, 2+i%3)
+		jsonRoundTrip(t, alloc, new(channel.Allocation))
+	}
+
+	var alloc channel.Allocation
+	assert.Error(t, json.Unmarshal([]byte(`{"assets":[],"balances":[["x"]]}`), &alloc), "invalid balance")
+	assert.Error(t, json.Unmarshal([]byte(`{"assets":["0x00"],"balances":[]}`), &alloc), "invalid asset")
+}
+
+func TestState_JSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x57a7e))
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	state := test.NewRandomState(rng, params)
+	jsonRoundTrip(t, state, new(channel.State))
+
+	tx := channel.Transaction{State: state, Sigs: make([]wallet.Sig, len(params.Parts))}
+	sig, err := wallettest.NewRandomAccount(rng).SignData([]byte("state"))
+	require.NoError(t, err)
+	tx.Sigs[0] = sig
+	data, err := json.Marshal(tx)
+	require.NoError(t, err)
+	var decoded channel.Transaction
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, tx.Sigs, decoded.Sigs)
+	eq, err := iotest.EqualEncoding(tx.State, decoded.State)
+	require.NoError(t, err)
+	assert.True(t, eq)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel
+
+import "math/big"
+
+type (
+	// LedgerID identifies a ledger, e.g., a blockchain, on which assets live.
+	// An example is the chain ID of an EVM chain.
+	LedgerID string
+
+	// A LedgerAsset is an Asset that knows the ledger on which it lives. Assets
+	// that don't implement LedgerAsset live on the DefaultLedger.
+	LedgerAsset interface {
+		Asset
+		// LedgerID returns the ID of the ledger the asset lives on.
+		LedgerID() LedgerID
+	}
+)
+
+// DefaultLedger is the ledger of all assets that don't implement LedgerAsset.
+// It is used for single-ledger setups.
+const DefaultLedger LedgerID = ""
+
+// LedgerOf returns the ledger on which the given asset lives.
+func LedgerOf(asset Asset) LedgerID {
+	if la, ok := asset.(LedgerAsset); ok {
+		return la.LedgerID()
+	}
+	return DefaultLedger
+}
+
+// Ledgers returns the IDs of all ledgers on which the assets of the
+// allocation live, in the order of their first occurrence.
+func (a Allocation) Ledgers() []LedgerID {
+	var ledgers []LedgerID
+	seen := make(map[LedgerID]bool)
+	for _, asset := range a.Assets {
+		if l := LedgerOf(asset); !seen[l] {
+			seen[l] = true
+			ledgers = append(ledgers, l)
+		}
+	}
+	return ledgers
+}
+
+// AssetsOnLedger returns the indices of all assets of the allocation that live
+// on the given ledger.
+func (a Allocation) AssetsOnLedger(ledger LedgerID) (idxs []Index) {
+	for i, asset := range a.Assets {
+		if LedgerOf(asset) == ledger {
+			idxs = append(idxs, Index(i))
+		}
+	}
+	return
+}
+
+// FilterAssets returns a copy of the allocation that only contains the assets
+// with the given indices, in the given order. The balances of the
+// participants and sub-allocations are filtered accordingly. This way, an
+// allocation can be split up into the parts of the different ledgers.
+func (a Allocation) FilterAssets(idxs []Index) Allocation {
+	filter := func(bals []Bal) []Bal {
+		filtered := make([]Bal, len(idxs))
+		for i, idx := range idxs {
+			filtered[i] = new(big.Int).Set(bals[idx])
+		}
+		return filtered
+	}
+
+	filtered := Allocation{
+		Assets:  make([]Asset, len(idxs)),
+		OfParts: make([][]Bal, len(a.OfParts)),
+	}
+	for i, idx := range idxs {
+		filtered.Assets[i] = a.Assets[idx]
+	}
+	for i, bals := range a.OfParts {
+		filtered.OfParts[i] = filter(bals)
+	}
+	if a.Locked != nil {
+		filtered.Locked = make([]SubAlloc, len(a.Locked))
+		for i, sub := range a.Locked {
+			filtered.Locked[i] = SubAlloc{ID: sub.ID, Bals: filter(sub.Bals)}
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+)
+
+func TestAllocation_Ledgers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1ed9e4))
+	alloc := channel.Allocation{
+		Assets: []channel.Asset{
+			test.NewRandomLedgerAsset(rng, "A"),
+			test.NewRandomAsset(rng),
+			test.NewRandomLedgerAsset(rng, "B"),
+			test.NewRandomLedgerAsset(rng, "A"),
+		},
+		OfParts: [][]channel.Bal{
+			{big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(3)},
+			{big.NewInt(4), big.NewInt(5), big.NewInt(6), big.NewInt(7)},
+		},
+		Locked: []channel.SubAlloc{{
+			ID:   test.NewRandomChannelID(rng),
+			Bals: []channel.Bal{big.NewInt(8), big.NewInt(9), big.NewInt(10), big.NewInt(11)},
+		}},
+	}
+
+	assert.Equal(t, []channel.LedgerID{"A", channel.DefaultLedger, "B"}, alloc.Ledgers())
+	assert.Equal(t, channel.DefaultLedger, channel.LedgerOf(alloc.Assets[1]))
+	assert.Equal(t, []channel.Index{0, 3}, alloc.AssetsOnLedger("A"))
+	assert.Equal(t, []channel.Index{1}, alloc.AssetsOnLedger(channel.DefaultLedger))
+	assert.Empty(t, alloc.AssetsOnLedger("C"))
+
+	filtered := alloc.FilterAssets(alloc.AssetsOnLedger("A"))
+	require.NoError(t, filtered.Valid())
+	assert.Equal(t, []channel.Asset{alloc.Assets[0], alloc.Assets[3]}, filtered.Assets)
+	assert.Equal(t, [][]channel.Bal{
+		{big.NewInt(0), big.NewInt(3)},
+		{big.NewInt(4), big.NewInt(7)},
+	}, filtered.OfParts)
+	require.Len(t, filtered.Locked, 1)
+	assert.Equal(t, alloc.Locked[0].ID, filtered.Locked[0].ID)
+	assert.Equal(t, []channel.Bal{big.NewInt(8), big.NewInt(11)}, filtered.Locked[0].Bals)
+
+	// the filtered allocation is a copy
+	filtered.OfParts[0][0].SetInt64(42)
+	assert.Zero(t, alloc.OfParts[0][0].Sign())
+}
@@ -6,6 +6,7 @@
 package channel
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/pkg/errors"
@@ -48,6 +49,20 @@ func (t PhaseTransition) String() string {
 
 var signingPhases = []Phase{InitSigning, Signing}
 
+// Source is a source of channel data. It allows access to all information
+// needed for persistence. The ID, Idx and Params only need to be persisted
+// once per channel as they stay constant during a channel's lifetime.
+type Source interface {
+	ID() ID                 // ID is the channel ID of this source.
+	Idx() Index             // Idx is the own index in the channel.
+	Params() *Params        // Params are the channel parameters.
+	StagingTX() Transaction // StagingTX is the staged transaction (State+incomplete list of sigs).
+	CurrentTX() Transaction // CurrentTX is the current transaction (State+complete list of sigs).
+	Phase() Phase           // Phase is the phase in which the channel is currently in.
+}
+
+var _ Source = (*machine)(nil)
+
 // A machine is the channel pushdown automaton that handles phase transitions.
 // It checks for correct signatures and valid state transitions.
 // machine only contains implementations for the state transitions common to
@@ -80,11 +95,26 @@ func newMachine(acc wallet.Account, params Params) (*machine, error) {
 		acc:    acc,
 		idx:    Index(idx),
 		params: params,
-		log:    log.WithField("ID", params.id),
+		log:    log.WithField(log.ChannelField, params.id),
 	}, nil
 
 }
 
+// restoreMachine restores a machine to the data given by the source.
+func restoreMachine(acc wallet.Account, source Source) (*machine, error) {
+	m, err := newMachine(acc, *source.Params())
+	if err != nil {
+		return nil, err
+	}
+	if m.idx != source.Idx() {
+		return nil, errors.New("account index doesn't match source index")
+	}
+	m.phase = source.Phase()
+	m.currentTX = source.CurrentTX()
+	m.stagingTX = source.StagingTX()
+	return m, nil
+}
+
 // ID returns the channel id
 func (m *machine) ID() ID {
 	return m.params.ID()
@@ -117,7 +147,7 @@ func (m *machine) Phase() Phase {
 
 // setPhase is internally used to set the phase.
 func (m *machine) setPhase(p Phase) {
-	m.log.Tracef("phase transition: %v", PhaseTransition{m.phase, p})
+	m.log.WithField(log.PhaseField, p).Tracef("phase transition: %v", PhaseTransition{m.phase, p})
 	m.phase = p
 }
 
@@ -158,6 +188,18 @@ func (m *machine) State() *State {
 	return m.currentTX.State
 }
 
+// CurrentTX returns the current transaction, i.e., the current state together
+// with all signatures on it.
+func (m *machine) CurrentTX() Transaction {
+	return m.currentTX
+}
+
+// StagingTX returns the staging transaction. Its signature slice may be
+// incomplete. If there is no staged state, the returned transaction is empty.
+func (m *machine) StagingTX() Transaction {
+	return m.stagingTX
+}
+
 // SettleReq returns the settlement request for the current channel transaction
 // (the current state together with all participants' signatures on it).
 func (m *machine) AdjudicatorReq() AdjudicatorReq {
@@ -213,6 +255,70 @@ func (m *machine) setStaging(phase Phase, state *State) {
 	m.setPhase(phase)
 }
 
+// UpdateLocked makes the provided state the staging state, similar to
+// StateMachine.Update. It is meant for sub-protocols that only move funds
+// between the participants' balances and locked sub-allocations, like the
+// funding and settlement of virtual channels. The app's transition rules are
+// not consulted, instead it is checked that the app data stays the same.
+// The caller is responsible for checking the reallocation of funds.
+func (m *machine) UpdateLocked(stagingState *State, actor Index) error {
+	if err := m.expect(PhaseTransition{Acting, Signing}); err != nil {
+		return err
+	}
+
+	if err := m.validLockedTransition(stagingState, actor); err != nil {
+		return err
+	}
+
+	m.setStaging(Signing, stagingState)
+	return nil
+}
+
+// CheckUpdateLocked is the read-only counterpart of UpdateLocked. It checks
+// that the given state is a valid locked transition from the current state and
+// that the given signature is valid.
+func (m *machine) CheckUpdateLocked(
+	state *State, actor Index,
+	sig wallet.Sig, sigIdx Index,
+) error {
+	if err := m.validLockedTransition(state, actor); err != nil {
+		return err
+	}
+
+	if ok, err := Verify(m.params.Parts[sigIdx], &m.params, state, sig); err != nil {
+		return errors.WithMessagef(err, "verifying signature[%d]", sigIdx)
+	} else if !ok {
+		return errors.Errorf("invalid signature[%d]", sigIdx)
+	}
+	return nil
+}
+
+// validLockedTransition runs the default transition checks and checks that the
+// app and its data are unchanged.
+func (m *machine) validLockedTransition(to *State, actor Index) error {
+	if actor >= m.N() {
+		return errors.New("actor index is out of range")
+	}
+	if err := m.validTransition(to); err != nil {
+		return err
+	}
+	if !m.currentTX.App.Def().Equals(to.App.Def()) {
+		return NewStateTransitionError(m.params.id, "app must not change")
+	}
+
+	var cur, next bytes.Buffer
+	if err := m.currentTX.Data.Encode(&cur); err != nil {
+		return errors.WithMessage(err, "encoding current data")
+	}
+	if err := to.Data.Encode(&next); err != nil {
+		return errors.WithMessage(err, "encoding new data")
+	}
+	if !bytes.Equal(cur.Bytes(), next.Bytes()) {
+		return NewStateTransitionError(m.params.id, "app data must not change")
+	}
+	return nil
+}
+
 // DiscardUpdate discards the current staging transaction and sets the machine's
 // phase back to Acting. This method is useful in the case where a valid update
 // request is rejected.
@@ -283,9 +389,17 @@ func (m *machine) SetFunded() error {
 }
 
 // SetSettled tells the state machine that the final state was settled on the
-// blockchain or funding channel and progresses to the Settled state.
+// blockchain or funding channel and progresses to the Settled state. A channel
+// in the Acting phase can be settled if its current state was registered and
+// withdrawn in a dispute. A channel in the Funding phase can be settled if its
+// funding failed and the deposits were recovered by withdrawing the initial
+// state.
 func (m *machine) SetSettled() error {
-	if err := m.expect(PhaseTransition{Final, Settled}); err != nil {
+	from := Final
+	if m.phase == Acting || m.phase == Funding {
+		from = m.phase
+	}
+	if err := m.expect(PhaseTransition{from, Settled}); err != nil {
 		return err
 	}
 
@@ -297,9 +411,11 @@ var validPhaseTransitions = map[PhaseTransition]bool{
 	{InitActing, InitSigning}: true,
 	{InitSigning, Funding}:    true,
 	{Funding, Acting}:         true,
+	{Funding, Settled}:        true,
 	{Acting, Signing}:         true,
 	{Signing, Acting}:         true,
 	{Signing, Final}:          true,
+	{Acting, Settled}:         true,
 	{Final, Settled}:          true,
 }
 
@@ -320,15 +436,12 @@ func (m *machine) expect(tr PhaseTransition) error {
 // * version increase by 1
 // * preservation of balances
 // A StateMachine will additionally check the validity of the app-specific
-// transition whereas an ActionMachine checks each Action as being valid.
+// transition or app upgrade whereas an ActionMachine checks each Action as
+// being valid.
 func (m *machine) validTransition(to *State) error {
 	if to.ID != m.params.id {
 		return errors.New("new state's ID doesn't match")
 	}
-	if !m.params.App.Def().Equals(to.App.Def()) {
-		return errors.New("new state's App dosen't match")
-	}
-
 	newError := func(s string) error { return NewStateTransitionError(m.params.id, s) }
 
 	if m.currentTX.IsFinal {
@@ -343,6 +456,14 @@ func (m *machine) validTransition(to *State) error {
 		return newError(fmt.Sprintf("invalid allocation: %v", err))
 	}
 
+	if len(to.OfParts) != len(m.params.Parts) {
+		return newError("number of participants must not change")
+	}
+
+	if !EqualAssets(m.currentTX.Assets, to.Assets) {
+		return newError("assets must not change")
+	}
+
 	if eq, err := equalSum(m.currentTX.Allocation, to.Allocation); err != nil {
 		return err
 	} else if !eq {
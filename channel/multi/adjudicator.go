@@ -0,0 +1,243 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package multi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+)
+
+// Adjudicator is a channel.Adjudicator that dispatches all requests to the
+// Adjudicators of the ledgers on which the channel's assets live. The state
+// is registered on every ledger since each ledger's adjudicator needs to
+// verify the full signed state. Withdrawal then happens on every ledger for
+// its respective assets, as defined by the contract of
+// channel.Adjudicator.Withdraw.
+type Adjudicator struct {
+	mtx  sync.RWMutex
+	adjs map[channel.LedgerID]channel.Adjudicator
+}
+
+var _ channel.ProgressingAdjudicator = (*Adjudicator)(nil)
+
+// NewAdjudicator creates a new multi-ledger Adjudicator without any ledgers.
+// Use RegisterAdjudicator to add the Adjudicators of all supported ledgers.
+func NewAdjudicator() *Adjudicator {
+	return &Adjudicator{adjs: make(map[channel.LedgerID]channel.Adjudicator)}
+}
+
+// RegisterAdjudicator sets the Adjudicator for the given ledger. An already
+// registered Adjudicator for the same ledger is replaced.
+func (a *Adjudicator) RegisterAdjudicator(ledger channel.LedgerID, adj channel.Adjudicator) {
+	if adj == nil {
+		log.Panic("adjudicator must not be nil")
+	}
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.adjs[ledger] = adj
+}
+
+// Register registers the state on all ledgers of the state's assets. It
+// returns the Registered event with the latest timeout, so that all ledgers
+// are ready for withdrawal after it.
+func (a *Adjudicator) Register(ctx context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	var (
+		mtx    sync.Mutex
+		latest *channel.Registered
+	)
+	err := a.forEachLedger(req, func(l channel.LedgerID, adj channel.Adjudicator) error {
+		reg, err := adj.Register(ctx, req)
+		if err != nil {
+			return err
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		if latest == nil || reg.Timeout.After(latest.Timeout) {
+			latest = reg
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// Withdraw concludes and withdraws the registered state on all ledgers of the
+// state's assets. The unfiltered request is passed to every ledger's
+// Adjudicator, which only withdraws the assets of its own ledger.
+func (a *Adjudicator) Withdraw(ctx context.Context, req channel.AdjudicatorReq) error {
+	return a.forEachLedger(req, func(_ channel.LedgerID, adj channel.Adjudicator) error {
+		return adj.Withdraw(ctx, req)
+	})
+}
+
+// Progress progresses the registered state on all ledgers of the state's
+// assets, analogously to Register. All ledgers' Adjudicators must be
+// ProgressingAdjudicators.
+func (a *Adjudicator) Progress(ctx context.Context, req channel.ProgressReq) (*channel.Registered, error) {
+	var (
+		mtx    sync.Mutex
+		latest *channel.Registered
+	)
+	err := a.forEachLedger(req.AdjudicatorReq, func(l channel.LedgerID, adj channel.Adjudicator) error {
+		padj, ok := adj.(channel.ProgressingAdjudicator)
+		if !ok {
+			return errors.New("adjudicator does not support progression")
+		}
+		reg, err := padj.Progress(ctx, req)
+		if err != nil {
+			return err
+		}
+		mtx.Lock()
+		defer mtx.Unlock()
+		if latest == nil || reg.Timeout.After(latest.Timeout) {
+			latest = reg
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// SubscribeRegistered subscribes to the Registered events of the channel on
+// all registered ledgers, since the parameters don't tell the ledgers of the
+// channel's assets. The events of all ledgers are merged into the returned
+// subscription.
+func (a *Adjudicator) SubscribeRegistered(ctx context.Context, params *channel.Params) (channel.RegisteredSubscription, error) {
+	a.mtx.RLock()
+	defer a.mtx.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &registeredSub{
+		events: make(chan *channel.Registered),
+		closed: make(chan struct{}),
+		cancel: cancel,
+	}
+	for l, adj := range a.adjs {
+		lsub, err := adj.SubscribeRegistered(ctx, params)
+		if err != nil {
+			sub.Close()
+			return nil, errors.WithMessagef(err, "subscribing on ledger %q", l)
+		}
+		sub.subs = append(sub.subs, lsub)
+	}
+	sub.wg.Add(len(sub.subs))
+	for _, lsub := range sub.subs {
+		go sub.forward(ctx, lsub)
+	}
+	go func() {
+		sub.wg.Wait()
+		close(sub.closed)
+	}()
+	return sub, nil
+}
+
+// forEachLedger calls fn concurrently for the Adjudicator of each ledger of
+// the request's state. It returns the first error.
+func (a *Adjudicator) forEachLedger(
+	req channel.AdjudicatorReq,
+	fn func(channel.LedgerID, channel.Adjudicator) error,
+) error {
+	ledgers := req.Tx.State.Allocation.Ledgers()
+	adjs := make([]channel.Adjudicator, len(ledgers))
+	a.mtx.RLock()
+	for i, l := range ledgers {
+		adjs[i] = a.adjs[l]
+	}
+	a.mtx.RUnlock()
+	for i, adj := range adjs {
+		if adj == nil {
+			return errors.Errorf("no adjudicator for ledger %q", ledgers[i])
+		}
+	}
+
+	errs := make(chan error, len(ledgers))
+	for i, l := range ledgers {
+		go func(l channel.LedgerID, adj channel.Adjudicator) {
+			errs <- errors.WithMessagef(fn(l, adj), "ledger %q", l)
+		}(l, adjs[i])
+	}
+
+	var err error
+	for range ledgers {
+		if lerr := <-errs; lerr != nil && err == nil {
+			err = lerr
+		}
+	}
+	return err
+}
+
+// registeredSub merges the Registered subscriptions of multiple ledgers.
+type registeredSub struct {
+	subs   []channel.RegisteredSubscription
+	events chan *channel.Registered
+	closed chan struct{} // closed when all forwarders returned
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mtx sync.Mutex // protects err
+	err error
+}
+
+// forward forwards all events of the ledger subscription until it is closed
+// or the context is done.
+func (s *registeredSub) forward(ctx context.Context, sub channel.RegisteredSubscription) {
+	defer s.wg.Done()
+	for reg := sub.Next(); reg != nil; reg = sub.Next() {
+		select {
+		case s.events <- reg:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if err := sub.Err(); err != nil && ctx.Err() == nil {
+		s.mtx.Lock()
+		if s.err == nil {
+			s.err = err
+		}
+		s.mtx.Unlock()
+		s.cancel() // one failing ledger fails the whole subscription
+	}
+}
+
+// Next returns the next event of any ledger. It returns nil if the
+// subscription is closed or one of the ledger subscriptions failed.
+func (s *registeredSub) Next() *channel.Registered {
+	select {
+	case reg := <-s.events:
+		return reg
+	case <-s.closed:
+		return nil
+	}
+}
+
+// Err returns the first error of the ledger subscriptions.
+func (s *registeredSub) Err() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.err
+}
+
+// Close closes all ledger subscriptions.
+func (s *registeredSub) Close() error {
+	s.cancel()
+	var err error
+	for _, sub := range s.subs {
+		if cerr := sub.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package multi_test
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/multi"
+	"perun.network/go-perun/channel/test"
+)
+
+func TestAdjudicator(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0xad1))
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	state := test.NewRandomState(rng, params)
+	state.Allocation = *newMultiLedgerAlloc(rng)
+	req := channel.AdjudicatorReq{Params: params, Tx: channel.Transaction{State: state}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	now := time.Now()
+	adjs := map[channel.LedgerID]*ledgerAdjudicator{
+		"A": newLedgerAdjudicator(now.Add(time.Minute)),
+		"B": newLedgerAdjudicator(now.Add(time.Hour)),
+		"C": newLedgerAdjudicator(now), // unused
+	}
+	a := multi.NewAdjudicator()
+	for l, adj := range adjs {
+		a.RegisterAdjudicator(l, adj)
+	}
+
+	reg, err := a.Register(ctx, req)
+	require.NoError(err)
+	assert.Equal(t, now.Add(time.Hour), reg.Timeout, "latest timeout")
+	preq := channel.ProgressReq{AdjudicatorReq: req, NewState: state.Clone()}
+	preq.NewState.Version++
+	reg, err = a.Progress(ctx, preq)
+	require.NoError(err)
+	assert.True(t, reg.Progressed)
+	assert.Equal(t, state.Version+1, reg.Version)
+	assert.Equal(t, now.Add(time.Hour), reg.Timeout, "latest timeout")
+	require.NoError(a.Withdraw(ctx, req))
+	for l, adj := range adjs {
+		calls := 1
+		if l == "C" {
+			calls = 0
+		}
+		assert.Equal(t, calls, adj.registered, "Register calls on ledger %s", l)
+		assert.Equal(t, calls, adj.progressed, "Progress calls on ledger %s", l)
+		assert.Equal(t, calls, adj.withdrawn, "Withdraw calls on ledger %s", l)
+	}
+
+	t.Run("missing adjudicator", func(t *testing.T) {
+		a := multi.NewAdjudicator()
+		a.RegisterAdjudicator("A", newLedgerAdjudicator(now))
+		_, err := a.Register(ctx, req)
+		assert.Error(t, err)
+		assert.Error(t, a.Withdraw(ctx, req))
+	})
+
+	t.Run("progression unsupported", func(t *testing.T) {
+		a := multi.NewAdjudicator()
+		a.RegisterAdjudicator("A", newLedgerAdjudicator(now))
+		a.RegisterAdjudicator("B", struct{ channel.Adjudicator }{newLedgerAdjudicator(now)})
+		_, err := a.Progress(ctx, preq)
+		assert.Error(t, err)
+	})
+
+	t.Run("subscription", func(t *testing.T) {
+		sub, err := a.SubscribeRegistered(ctx, params)
+		require.NoError(err)
+		adjs["A"].events <- &channel.Registered{Version: 1}
+		assert.Equal(t, uint64(1), sub.Next().Version)
+		adjs["C"].events <- &channel.Registered{Version: 2}
+		assert.Equal(t, uint64(2), sub.Next().Version)
+		assert.NoError(t, sub.Close())
+		assert.Nil(t, sub.Next())
+		assert.NoError(t, sub.Err())
+	})
+}
+
+// ledgerAdjudicator is an Adjudicator of a single ledger. It counts the calls
+// to Register, Progress and Withdraw and emits the Registered events sent on events.
+type ledgerAdjudicator struct {
+	timeout time.Time
+	events  chan *channel.Registered
+
+	mtx                               sync.Mutex
+	registered, progressed, withdrawn int
+}
+
+func newLedgerAdjudicator(timeout time.Time) *ledgerAdjudicator {
+	return &ledgerAdjudicator{timeout: timeout, events: make(chan *channel.Registered)}
+}
+
+func (a *ledgerAdjudicator) Register(_ context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.registered++
+	return &channel.Registered{ID: req.Params.ID(), Version: req.Tx.Version, Timeout: a.timeout}, nil
+}
+
+func (a *ledgerAdjudicator) Progress(_ context.Context, req channel.ProgressReq) (*channel.Registered, error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.progressed++
+	return &channel.Registered{
+		ID:         req.Params.ID(),
+		Version:    req.NewState.Version,
+		Timeout:    a.timeout,
+		Progressed: true,
+	}, nil
+}
+
+func (a *ledgerAdjudicator) Withdraw(context.Context, channel.AdjudicatorReq) error {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.withdrawn++
+	return nil
+}
+
+func (a *ledgerAdjudicator) SubscribeRegistered(ctx context.Context, _ *channel.Params) (channel.RegisteredSubscription, error) {
+	return &ledgerSub{ctx: ctx, events: a.events}, nil
+}
+
+type ledgerSub struct {
+	ctx    context.Context
+	events <-chan *channel.Registered
+}
+
+func (s *ledgerSub) Next() *channel.Registered {
+	select {
+	case reg := <-s.events:
+		return reg
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+func (s *ledgerSub) Err() error   { return nil }
+func (s *ledgerSub) Close() error { return nil }
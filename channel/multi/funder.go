@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package multi contains a Funder and Adjudicator for channels whose assets
+// live on multiple ledgers. They dispatch all requests to the Funder and
+// Adjudicator of the ledger on which the respective assets live, see
+// channel.LedgerAsset.
+package multi // import "perun.network/go-perun/channel/multi"
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+)
+
+// Funder is a channel.Funder that routes the funding of each asset to the
+// Funder of the asset's ledger.
+type Funder struct {
+	mtx     sync.RWMutex
+	funders map[channel.LedgerID]channel.Funder
+}
+
+var _ channel.Funder = (*Funder)(nil)
+
+// NewFunder creates a new multi-ledger Funder without any ledgers. Use
+// RegisterFunder to add the Funders of all supported ledgers.
+func NewFunder() *Funder {
+	return &Funder{funders: make(map[channel.LedgerID]channel.Funder)}
+}
+
+// RegisterFunder sets the Funder for the given ledger. An already registered
+// Funder for the same ledger is replaced.
+func (f *Funder) RegisterFunder(ledger channel.LedgerID, funder channel.Funder) {
+	if funder == nil {
+		log.Panic("funder must not be nil")
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.funders[ledger] = funder
+}
+
+// Fund splits the funding request into one request per ledger, only
+// containing the assets of that ledger, and funds them concurrently using the
+// registered Funders. If any ledger's funding times out, a
+// channel.FundingTimeoutError is returned whose asset indices refer to the
+// original allocation. Other errors take precedence over timeout errors. The
+// asset indices of the reported progress also refer to the original
+// allocation.
+func (f *Funder) Fund(ctx context.Context, req channel.FundingReq) error {
+	ledgers := req.Allocation.Ledgers()
+	funders := make([]channel.Funder, len(ledgers))
+	f.mtx.RLock()
+	for i, l := range ledgers {
+		funders[i] = f.funders[l]
+	}
+	f.mtx.RUnlock()
+	for i, funder := range funders {
+		if funder == nil {
+			return errors.Errorf("no funder for ledger %q", ledgers[i])
+		}
+	}
+
+	var (
+		wg          sync.WaitGroup
+		mtx         sync.Mutex
+		err         error
+		timeoutErrs []*channel.AssetFundingError
+	)
+	wg.Add(len(ledgers))
+	for i, l := range ledgers {
+		go func(l channel.LedgerID, funder channel.Funder) {
+			defer wg.Done()
+			idxs := req.Allocation.AssetsOnLedger(l)
+			alloc := req.Allocation.FilterAssets(idxs)
+			ferr := funder.Fund(ctx, channel.FundingReq{
+				Params:     req.Params,
+				Allocation: &alloc,
+				Idx:        req.Idx,
+				Agreement:  filterBals(req.Agreement, idxs),
+				Progress:   filterProgress(req, idxs),
+			})
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if timeout, ok := errors.Cause(ferr).(*channel.FundingTimeoutError); ok {
+				for _, aerr := range timeout.Errors {
+					if aerr.Asset < 0 || aerr.Asset >= len(idxs) {
+						if err == nil {
+							err = errors.Errorf("funder of ledger %q reported timeout for invalid asset %d", l, aerr.Asset)
+						}
+						continue
+					}
+					timeoutErrs = append(timeoutErrs, &channel.AssetFundingError{
+						Asset:         int(idxs[aerr.Asset]),
+						TimedOutPeers: aerr.TimedOutPeers,
+					})
+				}
+			} else if ferr != nil && err == nil {
+				err = errors.WithMessagef(ferr, "funding on ledger %q", l)
+			}
+		}(l, funders[i])
+	}
+	wg.Wait()
+
+	if err != nil {
+		return err
+	}
+	return channel.NewFundingTimeoutError(timeoutErrs)
+}
+
+// filterBals returns the balances of the assets with the given indices, for
+// each participant. A nil agreement stays nil.
+func filterBals(agreement [][]channel.Bal, idxs []channel.Index) [][]channel.Bal {
+	if agreement == nil {
+		return nil
+	}
+	filtered := make([][]channel.Bal, len(agreement))
+	for i, bals := range agreement {
+		filtered[i] = make([]channel.Bal, len(idxs))
+		for j, idx := range idxs {
+			filtered[i][j] = bals[idx]
+		}
+	}
+	return filtered
+}
+
+// filterProgress returns a Progress callback that reports the progress of the
+// assets with the given indices to the original request. It is nil if the
+// original request has no Progress callback.
+func filterProgress(req channel.FundingReq, idxs []channel.Index) func(channel.FundingProgress) {
+	if req.Progress == nil {
+		return nil
+	}
+	return func(p channel.FundingProgress) {
+		if p.Asset < 0 || p.Asset >= len(idxs) {
+			log.Warnf("multi: funder reported progress of invalid asset %d", p.Asset)
+			return
+		}
+		p.Asset = int(idxs[p.Asset])
+		req.Progress(p)
+	}
+}
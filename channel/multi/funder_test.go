@@ -0,0 +1,143 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package multi_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/multi"
+	"perun.network/go-perun/channel/test"
+)
+
+func TestFunder(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf00d))
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	alloc := newMultiLedgerAlloc(rng)
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		f := multi.NewFunder()
+		funders := map[channel.LedgerID]*recordingFunder{"A": {}, "B": {}}
+		for l, lf := range funders {
+			f.RegisterFunder(l, lf)
+		}
+		agreement := [][]channel.Bal{alloc.OfParts[1], alloc.OfParts[0]}
+		require.NoError(t, f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc, Idx: 1, Agreement: agreement}))
+
+		require.Len(t, funders["A"].reqs, 1)
+		reqA := funders["A"].reqs[0]
+		assert.Equal(t, []channel.Asset{alloc.Assets[0], alloc.Assets[2]}, reqA.Allocation.Assets)
+		assert.Equal(t, channel.Index(1), reqA.Idx)
+		assert.Same(t, params, reqA.Params)
+		assert.Equal(t, [][]channel.Bal{{agreement[0][0], agreement[0][2]}, {agreement[1][0], agreement[1][2]}}, reqA.Agreement)
+		require.Len(t, funders["B"].reqs, 1)
+		assert.Equal(t, []channel.Asset{alloc.Assets[1]}, funders["B"].reqs[0].Allocation.Assets)
+		assert.Equal(t, [][]channel.Bal{{agreement[0][1]}, {agreement[1][1]}}, funders["B"].reqs[0].Agreement)
+	})
+
+	t.Run("missing funder", func(t *testing.T) {
+		f := multi.NewFunder()
+		f.RegisterFunder("A", &recordingFunder{})
+		assert.Error(t, f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc}))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		f := multi.NewFunder()
+		f.RegisterFunder("A", &recordingFunder{err: channel.NewFundingTimeoutError(
+			[]*channel.AssetFundingError{{Asset: 1, TimedOutPeers: []channel.Index{0}}})})
+		f.RegisterFunder("B", &recordingFunder{})
+		err := f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc})
+		require.True(t, channel.IsFundingTimeoutError(err))
+		timeout := errors.Cause(err).(*channel.FundingTimeoutError)
+		require.Len(t, timeout.Errors, 1)
+		assert.Equal(t, 2, timeout.Errors[0].Asset, "asset index of original allocation")
+		assert.Equal(t, []channel.Index{0}, timeout.Errors[0].TimedOutPeers)
+	})
+
+	t.Run("invalid timeout asset", func(t *testing.T) {
+		f := multi.NewFunder()
+		// ledger B only has a single asset
+		f.RegisterFunder("A", &recordingFunder{})
+		f.RegisterFunder("B", &recordingFunder{err: channel.NewFundingTimeoutError(
+			[]*channel.AssetFundingError{{Asset: 1}})})
+		err := f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc})
+		assert.Error(t, err)
+		assert.False(t, channel.IsFundingTimeoutError(err))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		f := multi.NewFunder()
+		f.RegisterFunder("A", &recordingFunder{err: channel.NewFundingTimeoutError(
+			[]*channel.AssetFundingError{{Asset: 0}})})
+		f.RegisterFunder("B", &recordingFunder{err: errors.New("funding failed")})
+		err := f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc})
+		assert.Error(t, err)
+		assert.False(t, channel.IsFundingTimeoutError(err))
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		f := multi.NewFunder()
+		f.RegisterFunder("A", &recordingFunder{})
+		f.RegisterFunder("B", &recordingFunder{})
+		var (
+			mtx      sync.Mutex
+			progress []channel.FundingProgress
+		)
+		require.NoError(t, f.Fund(ctx, channel.FundingReq{Params: params, Allocation: alloc, Idx: 1,
+			Progress: func(p channel.FundingProgress) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				progress = append(progress, p)
+			}}))
+		assert.ElementsMatch(t, []channel.FundingProgress{
+			{Asset: 0, Idx: 1}, {Asset: 1, Idx: 1}, {Asset: 2, Idx: 1},
+		}, progress, "asset indices of original allocation")
+	})
+}
+
+// newMultiLedgerAlloc creates a random two-party allocation with assets on
+// ledgers A, B and A.
+func newMultiLedgerAlloc(rng *rand.Rand) *channel.Allocation {
+	bals := func() []channel.Bal {
+		return []channel.Bal{big.NewInt(rng.Int63()), big.NewInt(rng.Int63()), big.NewInt(rng.Int63())}
+	}
+	return &channel.Allocation{
+		Assets: []channel.Asset{
+			test.NewRandomLedgerAsset(rng, "A"),
+			test.NewRandomLedgerAsset(rng, "B"),
+			test.NewRandomLedgerAsset(rng, "A"),
+		},
+		OfParts: [][]channel.Bal{bals(), bals()},
+	}
+}
+
+type recordingFunder struct {
+	mtx  sync.Mutex
+	reqs []channel.FundingReq
+	err  error
+}
+
+func (f *recordingFunder) Fund(_ context.Context, req channel.FundingReq) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.reqs = append(f.reqs, req)
+	if f.err == nil {
+		for i := range req.Allocation.Assets {
+			req.ReportProgress(channel.FundingProgress{Asset: i, Idx: req.Idx})
+		}
+	}
+	return f.err
+}
@@ -6,12 +6,15 @@
 package channel
 
 import (
+	"io"
 	"log"
 	"math/big"
 
 	"github.com/pkg/errors"
 
+	perunio "perun.network/go-perun/pkg/io"
 	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
 )
 
 // IDLen the length of a channelID.
@@ -101,3 +104,56 @@ func NewParamsUnsafe(challengeDuration uint64, parts []wallet.Address, appDef wa
 	p.id = CalcID(p)
 	return p
 }
+
+var _ perunio.Serializer = (*Params)(nil)
+
+// Encode encodes the Params into an io.Writer. The ID is not encoded as it is
+// recalculated during decoding.
+func (p *Params) Encode(w io.Writer) error {
+	if len(p.Parts) > MaxNumParts {
+		return errors.Errorf("too many participants, got: %d max: %d", len(p.Parts), MaxNumParts)
+	}
+	if err := wire.Encode(w, p.ChallengeDuration, p.Nonce, Index(len(p.Parts))); err != nil {
+		return errors.WithMessage(err, "encoding challenge duration, nonce or number of participants")
+	}
+	for i, part := range p.Parts {
+		if err := part.Encode(w); err != nil {
+			return errors.WithMessagef(err, "encoding participant %d", i)
+		}
+	}
+	return errors.WithMessage(p.App.Def().Encode(w), "encoding app definition")
+}
+
+// Decode decodes Params from an io.Reader. The decoded parameters are checked
+// with ValidateParameters and the ID is calculated.
+func (p *Params) Decode(r io.Reader) error {
+	var (
+		challengeDuration uint64
+		nonce             *big.Int
+		numParts          Index
+	)
+	if err := wire.Decode(r, &challengeDuration, &nonce, &numParts); err != nil {
+		return errors.WithMessage(err, "decoding challenge duration, nonce or number of participants")
+	}
+	if numParts > MaxNumParts {
+		return errors.Errorf("too many participants, got: %d max: %d", numParts, MaxNumParts)
+	}
+	parts := make([]wallet.Address, numParts)
+	for i := range parts {
+		var err error
+		if parts[i], err = wallet.DecodeAddress(r); err != nil {
+			return errors.WithMessagef(err, "decoding participant %d", i)
+		}
+	}
+	appDef, err := wallet.DecodeAddress(r)
+	if err != nil {
+		return errors.WithMessage(err, "decoding app definition")
+	}
+
+	params, err := NewParams(challengeDuration, parts, appDef, nonce)
+	if err != nil {
+		return err
+	}
+	*p = *params
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel/test"
+	iotest "perun.network/go-perun/pkg/io/test"
+)
+
+func TestParamsSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xc0ff33))
+	for i := 0; i < 4; i++ {
+		app := test.NewRandomApp(rng)
+		params := test.NewRandomParams(rng, app.Def())
+		iotest.GenericSerializerTest(t, params)
+	}
+}
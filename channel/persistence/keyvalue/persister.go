@@ -0,0 +1,335 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package keyvalue
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+)
+
+// ChannelCreated persists all data of a newly created channel, including its
+// funding agreement, and adds the channel to the index of each peer.
+func (pr *PersistRestorer) ChannelCreated(_ context.Context, s channel.Source, peers []wallet.Address, parent *channel.ID, agreement [][]channel.Bal) error {
+	id := s.ID()
+	if has, err := pr.db.Has(channelPrefix(id) + keyParams); err != nil {
+		return errors.WithMessage(err, "checking for existing channel")
+	} else if has {
+		return errors.Errorf("channel %x already persisted", id)
+	}
+
+	b := pr.db.NewBatch()
+	ch := newChannelBatch(b, id)
+	ch.put(keyIdx, s.Idx())
+	ch.put(keyParams, s.Params())
+	ch.put(keyPhase, uint8(s.Phase()))
+	ch.put(keyPeers, addrsEncoder(peers))
+	ch.put(keyParent, optChannelIDEncoder{parent})
+	ch.put(keyCurrent, txEncoder(s.CurrentTX()))
+	ch.put(keyStaging, txEncoder(s.StagingTX()))
+	ch.put(keyAgreement, agreementEncoder(agreement))
+	if ch.err != nil {
+		return ch.err
+	}
+
+	for _, peer := range peers {
+		if err := b.Put(peerChannelKey(peer, id), ""); err != nil {
+			return errors.WithMessage(err, "putting peer channel index")
+		}
+	}
+	return errors.WithMessage(b.Apply(), "applying batch")
+}
+
+// ChannelRemoved deletes all data of the channel and removes it from the
+// peers' channel indices.
+func (pr *PersistRestorer) ChannelRemoved(_ context.Context, id channel.ID) error {
+	peers, err := pr.channelPeers(id)
+	if err != nil {
+		return errors.WithMessage(err, "retrieving peers")
+	}
+
+	b := pr.db.NewBatch()
+	for _, peer := range peers {
+		if err := b.Delete(peerChannelKey(peer, id)); err != nil {
+			return errors.WithMessage(err, "deleting peer channel index")
+		}
+	}
+
+	it := pr.db.NewIteratorWithPrefix(channelPrefix(id))
+	for it.Next() {
+		if err := b.Delete(it.Key()); err != nil {
+			it.Close()
+			return errors.WithMessage(err, "deleting channel field")
+		}
+	}
+	if err := it.Close(); err != nil {
+		return errors.WithMessage(err, "iterating channel fields")
+	}
+	return errors.WithMessage(b.Apply(), "applying batch")
+}
+
+// Staged persists the staging transaction and phase.
+func (pr *PersistRestorer) Staged(_ context.Context, s channel.Source) error {
+	return pr.putFields(s.ID(), map[string]encoder{
+		keyStaging: txEncoder(s.StagingTX()),
+		keyPhase:   uint8(s.Phase()),
+	})
+}
+
+// SigAdded persists the staging transaction with the new signature. The whole
+// staging transaction is rewritten since it is stored as a single value.
+func (pr *PersistRestorer) SigAdded(_ context.Context, s channel.Source, _ channel.Index) error {
+	return pr.putFields(s.ID(), map[string]encoder{
+		keyStaging: txEncoder(s.StagingTX()),
+	})
+}
+
+// Enabled persists the current and (cleared) staging transaction and phase.
+func (pr *PersistRestorer) Enabled(_ context.Context, s channel.Source) error {
+	return pr.putFields(s.ID(), map[string]encoder{
+		keyCurrent: txEncoder(s.CurrentTX()),
+		keyStaging: txEncoder(s.StagingTX()),
+		keyPhase:   uint8(s.Phase()),
+	})
+}
+
+// PhaseChanged persists the phase.
+func (pr *PersistRestorer) PhaseChanged(_ context.Context, s channel.Source) error {
+	return pr.putFields(s.ID(), map[string]encoder{
+		keyPhase: uint8(s.Phase()),
+	})
+}
+
+// putFields writes all given fields of the channel in a single batch. The
+// channel must already exist.
+func (pr *PersistRestorer) putFields(id channel.ID, fields map[string]encoder) error {
+	if has, err := pr.db.Has(channelPrefix(id) + keyParams); err != nil {
+		return errors.WithMessage(err, "checking for channel")
+	} else if !has {
+		return errors.Errorf("channel %x not persisted", id)
+	}
+
+	b := pr.db.NewBatch()
+	ch := newChannelBatch(b, id)
+	for key, v := range fields {
+		ch.put(key, v)
+	}
+	if ch.err != nil {
+		return ch.err
+	}
+	return errors.WithMessage(b.Apply(), "applying batch")
+}
+
+type (
+	// encoder is anything that can be passed to wire.Encode.
+	encoder = interface{}
+
+	// channelBatch puts encoded fields of a channel into a batch and remembers
+	// the first error.
+	channelBatch struct {
+		b   db.Batch
+		id  channel.ID
+		err error
+	}
+)
+
+func newChannelBatch(b db.Batch, id channel.ID) *channelBatch {
+	return &channelBatch{b: b, id: id}
+}
+
+func (c *channelBatch) put(key string, v encoder) {
+	if c.err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := wire.Encode(&buf, v); err != nil {
+		c.err = errors.WithMessagef(err, "encoding %s", key)
+		return
+	}
+	c.err = errors.WithMessagef(c.b.PutBytes(channelPrefix(c.id)+key, buf.Bytes()), "putting %s", key)
+}
+
+type (
+	// txEncoder encodes a Transaction whose state and signatures may be nil.
+	txEncoder channel.Transaction
+	// txDecoder decodes a Transaction encoded by txEncoder.
+	txDecoder channel.Transaction
+	// addrsEncoder encodes a slice of addresses with length prefix.
+	addrsEncoder []wallet.Address
+	// addrsDecoder decodes a slice of addresses encoded by addrsEncoder.
+	addrsDecoder []wallet.Address
+	// optChannelIDEncoder encodes an optional channel ID.
+	optChannelIDEncoder struct{ ID *channel.ID }
+	// optChannelIDDecoder decodes an optional channel ID.
+	optChannelIDDecoder struct{ ID *channel.ID }
+	// agreementEncoder encodes an optional funding agreement with its
+	// dimensions.
+	agreementEncoder [][]channel.Bal
+	// agreementDecoder decodes a funding agreement encoded by
+	// agreementEncoder.
+	agreementDecoder [][]channel.Bal
+)
+
+func (tx txEncoder) Encode(w io.Writer) error {
+	if err := wire.Encode(w, tx.State != nil); err != nil {
+		return err
+	}
+	if tx.State == nil {
+		return nil
+	}
+	if err := wire.Encode(w, tx.State, channel.Index(len(tx.Sigs))); err != nil {
+		return err
+	}
+	for i, sig := range tx.Sigs {
+		if err := wire.Encode(w, sig != nil); err != nil {
+			return errors.WithMessagef(err, "encoding signature %d flag", i)
+		}
+		if sig == nil {
+			continue
+		}
+		if err := wire.Encode(w, sig); err != nil {
+			return errors.WithMessagef(err, "encoding signature %d", i)
+		}
+	}
+	return nil
+}
+
+func (tx *txDecoder) Decode(r io.Reader) (err error) {
+	var hasState bool
+	if err := wire.Decode(r, &hasState); err != nil {
+		return err
+	}
+	if !hasState {
+		*tx = txDecoder{}
+		return nil
+	}
+
+	var n channel.Index
+	tx.State = new(channel.State)
+	if err := wire.Decode(r, tx.State, &n); err != nil {
+		return err
+	}
+	if n > channel.MaxNumParts {
+		return errors.Errorf("too many signatures, got: %d max: %d", n, channel.MaxNumParts)
+	}
+	tx.Sigs = make([]wallet.Sig, n)
+	for i := range tx.Sigs {
+		var hasSig bool
+		if err := wire.Decode(r, &hasSig); err != nil {
+			return errors.WithMessagef(err, "decoding signature %d flag", i)
+		}
+		if !hasSig {
+			continue
+		}
+		if tx.Sigs[i], err = wallet.DecodeSig(r); err != nil {
+			return errors.WithMessagef(err, "decoding signature %d", i)
+		}
+	}
+	return nil
+}
+
+func (a addrsEncoder) Encode(w io.Writer) error {
+	if err := wire.Encode(w, channel.Index(len(a))); err != nil {
+		return err
+	}
+	for i, addr := range a {
+		if err := addr.Encode(w); err != nil {
+			return errors.WithMessagef(err, "encoding address %d", i)
+		}
+	}
+	return nil
+}
+
+func (a *addrsDecoder) Decode(r io.Reader) (err error) {
+	var n channel.Index
+	if err := wire.Decode(r, &n); err != nil {
+		return err
+	}
+	if n > channel.MaxNumParts {
+		return errors.Errorf("too many addresses, got: %d max: %d", n, channel.MaxNumParts)
+	}
+	*a = make(addrsDecoder, n)
+	for i := range *a {
+		if (*a)[i], err = wallet.DecodeAddress(r); err != nil {
+			return errors.WithMessagef(err, "decoding address %d", i)
+		}
+	}
+	return nil
+}
+
+func (id optChannelIDEncoder) Encode(w io.Writer) error {
+	if id.ID == nil {
+		return wire.Encode(w, false)
+	}
+	return wire.Encode(w, true, *id.ID)
+}
+
+func (id *optChannelIDDecoder) Decode(r io.Reader) error {
+	var has bool
+	if err := wire.Decode(r, &has); err != nil || !has {
+		return err
+	}
+	id.ID = new(channel.ID)
+	return wire.Decode(r, id.ID)
+}
+
+func (a agreementEncoder) Encode(w io.Writer) error {
+	if a == nil {
+		return wire.Encode(w, false)
+	}
+	numAssets := 0
+	if len(a) > 0 {
+		numAssets = len(a[0])
+	}
+	if err := wire.Encode(w, true, channel.Index(len(a)), channel.Index(numAssets)); err != nil {
+		return err
+	}
+	for i, bals := range a {
+		if len(bals) != numAssets {
+			return errors.Errorf("agreement of participant %d has %d assets, expected %d", i, len(bals), numAssets)
+		}
+		for j, bal := range bals {
+			if err := wire.Encode(w, bal); err != nil {
+				return errors.WithMessagef(err, "encoding balance %d of participant %d", j, i)
+			}
+		}
+	}
+	return nil
+}
+
+func (a *agreementDecoder) Decode(r io.Reader) error {
+	var (
+		has                 bool
+		numParts, numAssets channel.Index
+	)
+	if err := wire.Decode(r, &has); err != nil || !has {
+		*a = nil
+		return err
+	}
+	if err := wire.Decode(r, &numParts, &numAssets); err != nil {
+		return err
+	}
+	if numParts > channel.MaxNumParts || numAssets > channel.MaxNumAssets {
+		return errors.Errorf("agreement too large, got %d participants and %d assets", numParts, numAssets)
+	}
+	*a = make(agreementDecoder, numParts)
+	for i := range *a {
+		(*a)[i] = make([]channel.Bal, numAssets)
+		for j := range (*a)[i] {
+			if err := wire.Decode(r, &(*a)[i][j]); err != nil {
+				return errors.WithMessagef(err, "decoding balance %d of participant %d", j, i)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package keyvalue implements a persistence.PersistRestorer on top of a
+// db.Database key-value store, e.g., a LevelDB.
+//
+// The channel data is stored under the following keys, where binary values
+// are hex-encoded within keys:
+//
+//	Chan:<ChannelID>:<Field>  -> encoded field of the channel
+//	Peer:<PeerAddress>:<ChannelID> -> "" (index of channels per peer)
+package keyvalue // import "perun.network/go-perun/channel/persistence/keyvalue"
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+// PersistRestorer implements both, the persistence.Persister and
+// persistence.Restorer interface, using a db.Database key-value store as
+// backend. It is thread-safe as long as the underlying database is.
+type PersistRestorer struct {
+	db db.Database
+}
+
+var _ persistence.PersistRestorer = (*PersistRestorer)(nil)
+
+const (
+	prefixChannel = "Chan:"
+	prefixPeer    = "Peer:"
+	sep           = ":"
+
+	keyIdx       = "Idx"
+	keyParams    = "Params"
+	keyPhase     = "Phase"
+	keyPeers     = "Peers"
+	keyParent    = "Parent"
+	keyCurrent   = "Current"
+	keyStaging   = "Staging"
+	keyAgreement = "Agreement"
+)
+
+// NewPersistRestorer creates a new PersistRestorer for the supplied database.
+func NewPersistRestorer(db db.Database) *PersistRestorer {
+	if db == nil {
+		log.Panic("database must not be nil")
+	}
+	return &PersistRestorer{db: db}
+}
+
+// Close is a no-op implementation of io.Closer. The database is not closed as
+// it is owned by the caller.
+func (pr *PersistRestorer) Close() error {
+	return nil
+}
+
+func channelPrefix(id channel.ID) string {
+	return prefixChannel + hex.EncodeToString(id[:]) + sep
+}
+
+func peerPrefix(addr wallet.Address) string {
+	return prefixPeer + hex.EncodeToString(encodeAddr(addr)) + sep
+}
+
+func peerChannelKey(addr wallet.Address, id channel.ID) string {
+	return peerPrefix(addr) + hex.EncodeToString(id[:])
+}
+
+func encodeAddr(addr wallet.Address) []byte {
+	var buf bytes.Buffer
+	if err := addr.Encode(&buf); err != nil {
+		log.Panicf("encoding address: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package keyvalue_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/channel/persistence/keyvalue"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestPersistRestorer(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0xDDDDD))
+	ctx := context.Background()
+	pr := keyvalue.NewPersistRestorer(memorydb.NewDatabase())
+
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []wallet.Address{accs[0].Address(), accs[1].Address()}
+	peers := []wallet.Address{wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)}
+	app := test.NewRandomApp(rng)
+	params := channel.NewParamsUnsafe(60, parts, app.Def(), big.NewInt(rng.Int63()))
+
+	m0, err := channel.NewStateMachine(accs[0], *params)
+	require.NoError(err)
+	m1, err := channel.NewStateMachine(accs[1], *params)
+	require.NoError(err)
+	parent := test.NewRandomChannelID(rng)
+	agreement := [][]channel.Bal{{big.NewInt(3), big.NewInt(0)}, {big.NewInt(1), big.NewInt(2)}}
+	require.NoError(pr.ChannelCreated(ctx, m0, peers, &parent, agreement))
+	assert.Error(t, pr.ChannelCreated(ctx, m0, peers, nil, nil), "channel created twice")
+	sm := persistence.FromStateMachine(m0, pr)
+
+	assertRestored := func() {
+		t.Helper()
+		ch, err := pr.RestoreChannel(ctx, params.ID())
+		require.NoError(err)
+		assertEqualSource(t, m0, ch)
+		assert.Equal(t, peers, ch.PeersV)
+		require.NotNil(ch.Parent)
+		assert.Equal(t, parent, *ch.Parent)
+		assert.Equal(t, agreement, ch.Agreement)
+	}
+	assertRestored()
+
+	// initial state
+	initBals := test.NewRandomAllocation(rng, 2)
+	initData := channel.NewMockOp(channel.OpValid)
+	require.NoError(sm.Init(ctx, *initBals, initData))
+	require.NoError(m1.Init(*initBals, initData))
+	assertRestored()
+	_, err = sm.Sig(ctx)
+	require.NoError(err)
+	assertRestored()
+	sig1, err := m1.Sig()
+	require.NoError(err)
+	require.NoError(sm.AddSig(ctx, 1, sig1))
+	assertRestored()
+	require.NoError(sm.EnableInit(ctx))
+	assertRestored()
+	require.NoError(sm.SetFunded(ctx))
+	assertRestored()
+
+	// discarded update
+	state := m0.State().Clone()
+	state.Version++
+	require.NoError(sm.Update(ctx, state, 0))
+	assertRestored()
+	require.NoError(sm.DiscardUpdate(ctx))
+	assertRestored()
+
+	// restore over the peer index
+	for _, peer := range peers {
+		it, err := pr.RestorePeer(peer)
+		require.NoError(err)
+		require.True(it.Next(ctx))
+		assertEqualSource(t, m0, it.Channel())
+		assert.False(t, it.Next(ctx))
+		assert.NoError(t, it.Close())
+	}
+	active, err := pr.ActivePeers(ctx)
+	require.NoError(err)
+	assert.ElementsMatch(t, peers, active)
+
+	// removal
+	require.NoError(pr.ChannelRemoved(ctx, params.ID()))
+	_, err = pr.RestoreChannel(ctx, params.ID())
+	assert.Error(t, err)
+	active, err = pr.ActivePeers(ctx)
+	require.NoError(err)
+	assert.Empty(t, active)
+	assert.Error(t, pr.PhaseChanged(ctx, m0), "updating removed channel")
+}
+
+func assertEqualSource(t *testing.T, expected, actual channel.Source) {
+	t.Helper()
+	assert.Equal(t, expected.ID(), actual.ID())
+	assert.Equal(t, expected.Idx(), actual.Idx())
+	assert.Equal(t, expected.Phase(), actual.Phase())
+	assert.Equal(t, expected.Params().Parts, actual.Params().Parts)
+	assert.Equal(t, expected.CurrentTX(), actual.CurrentTX(), "current tx")
+	assert.Equal(t, expected.StagingTX(), actual.StagingTX(), "staging tx")
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package keyvalue
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+)
+
+// ActivePeers returns a list of all peers with which a channel is persisted.
+func (pr *PersistRestorer) ActivePeers(ctx context.Context) ([]wallet.Address, error) {
+	it := pr.db.NewIteratorWithPrefix(prefixPeer)
+	var peers []wallet.Address
+	var last string
+	for it.Next() {
+		if ctx.Err() != nil {
+			it.Close()
+			return nil, ctx.Err()
+		}
+
+		addrHex := strings.SplitN(strings.TrimPrefix(it.Key(), prefixPeer), sep, 2)[0]
+		if addrHex == last { // keys are sorted, so duplicates are adjacent
+			continue
+		}
+		last = addrHex
+
+		addr, err := decodeAddrHex(addrHex)
+		if err != nil {
+			it.Close()
+			return nil, errors.WithMessagef(err, "decoding peer key %s", it.Key())
+		}
+		peers = append(peers, addr)
+	}
+	return peers, errors.WithMessage(it.Close(), "iterating peers")
+}
+
+// RestorePeer returns an iterator over all persisted channels with the given
+// peer.
+func (pr *PersistRestorer) RestorePeer(addr wallet.Address) (persistence.ChannelIterator, error) {
+	prefix := peerPrefix(addr)
+	it := pr.db.NewIteratorWithPrefix(prefix)
+	var ids []channel.ID
+	for it.Next() {
+		id, err := decodeIDHex(strings.TrimPrefix(it.Key(), prefix))
+		if err != nil {
+			it.Close()
+			return nil, errors.WithMessagef(err, "decoding peer key %s", it.Key())
+		}
+		ids = append(ids, id)
+	}
+	if err := it.Close(); err != nil {
+		return nil, errors.WithMessage(err, "iterating peer channels")
+	}
+	return &chanIterator{pr: pr, ids: ids}, nil
+}
+
+// RestoreChannel restores the channel with the given ID.
+func (pr *PersistRestorer) RestoreChannel(ctx context.Context, id channel.ID) (*persistence.Channel, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ch := persistence.NewChannel()
+	var (
+		phase   uint8
+		peers   addrsDecoder
+		parent  optChannelIDDecoder
+		current txDecoder
+		staging txDecoder
+		agree   agreementDecoder
+	)
+	for _, f := range []struct {
+		key string
+		v   interface{}
+	}{
+		{keyIdx, &ch.IdxV},
+		{keyParams, ch.ParamsV},
+		{keyPhase, &phase},
+		{keyPeers, &peers},
+		{keyParent, &parent},
+		{keyCurrent, &current},
+		{keyStaging, &staging},
+		{keyAgreement, &agree},
+	} {
+		val, err := pr.db.GetBytes(channelPrefix(id) + f.key)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "getting %s", f.key)
+		}
+		if err := wire.Decode(bytes.NewReader(val), f.v); err != nil {
+			return nil, errors.WithMessagef(err, "decoding %s", f.key)
+		}
+	}
+	ch.PhaseV = channel.Phase(phase)
+	ch.PeersV = peers
+	ch.Parent = parent.ID
+	ch.CurrentTXV = channel.Transaction(current)
+	ch.StagingTXV = channel.Transaction(staging)
+	ch.Agreement = agree
+	return ch, nil
+}
+
+// channelPeers returns the persisted peers of the channel.
+func (pr *PersistRestorer) channelPeers(id channel.ID) ([]wallet.Address, error) {
+	val, err := pr.db.GetBytes(channelPrefix(id) + keyPeers)
+	if err != nil {
+		return nil, err
+	}
+	var peers addrsDecoder
+	return peers, wire.Decode(bytes.NewReader(val), &peers)
+}
+
+// chanIterator iterates over the channels of a peer. The channel IDs are
+// collected upfront and the channels are loaded lazily.
+type chanIterator struct {
+	pr  *PersistRestorer
+	ids []channel.ID
+	ch  *persistence.Channel
+	err error
+}
+
+// Next loads the next channel. It returns false if there are no more channels
+// or an error occurred.
+func (i *chanIterator) Next(ctx context.Context) bool {
+	if i.err != nil || len(i.ids) == 0 {
+		i.ch = nil
+		return false
+	}
+	i.ch, i.err = i.pr.RestoreChannel(ctx, i.ids[0])
+	i.ids = i.ids[1:]
+	return i.err == nil
+}
+
+// Channel returns the current channel.
+func (i *chanIterator) Channel() *persistence.Channel {
+	return i.ch
+}
+
+// Close returns the last iteration error.
+func (i *chanIterator) Close() error {
+	i.ids = nil
+	return i.err
+}
+
+func decodeAddrHex(s string) (wallet.Address, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return wallet.DecodeAddress(bytes.NewReader(b))
+}
+
+func decodeIDHex(s string) (id channel.ID, err error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return id, err
+	}
+	if len(b) != len(id) {
+		return id, errors.Errorf("invalid channel ID length %d", len(b))
+	}
+	copy(id[:], b)
+	return id, nil
+}
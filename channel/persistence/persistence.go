@@ -0,0 +1,198 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package persistence specifies how the framework interacts with a persistence
+// backend. It contains the Persister interface, which is used by the channel
+// controllers to journal every change of a channel's state machine, and the
+// Restorer interface, which is used to restore all open channels after a
+// restart.
+package persistence // import "perun.network/go-perun/channel/persistence"
+
+import (
+	"context"
+	"io"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// A Persister is used by the framework to persist channel data during
+	// different steps of a channel's lifetime. It is guaranteed by the framework
+	// that, per channel, only one of those methods is called at a time. However,
+	// multiple channels' data may be persisted concurrently, so the
+	// implementation must be thread-safe.
+	Persister interface {
+		// ChannelCreated is called by the client when a new channel is created,
+		// before the initial state is signed. peers are the channel network peer
+		// addresses of all participants, including our own. parent is the ID of
+		// the parent ledger channel of a virtual channel or nil otherwise.
+		// agreement is the funding agreement of the channel, see
+		// channel.FundingReq, or nil if every participant deposits its initial
+		// balances. It is needed to fund the channel again after a restart.
+		ChannelCreated(ctx context.Context, source channel.Source, peers []wallet.Address, parent *channel.ID, agreement [][]channel.Bal) error
+
+		// ChannelRemoved is called by the client when a channel is removed
+		// because it has been successfully settled and its data is no longer
+		// needed.
+		ChannelRemoved(ctx context.Context, id channel.ID) error
+
+		// Staged is called when a new valid state got set as the new staging
+		// state. It may already contain one valid signature, either by a remote
+		// peer or us locally. Hence, it only needs to be persisted once per
+		// state and not for every added signature. It is also called with an
+		// empty staging transaction when the staged update got discarded.
+		// Our own update proposals are staged before they are sent, so that a
+		// restored channel can resume them with its peers.
+		Staged(context.Context, channel.Source) error
+
+		// SigAdded is called when a new signature is added to the current staging
+		// state. Only the new signature at the given index needs to be persisted.
+		// Our own signature is added before it is sent to the peers.
+		SigAdded(context.Context, channel.Source, channel.Index) error
+
+		// Enabled is called when the current staging state is promoted to the
+		// current state. The staging state is cleared.
+		Enabled(context.Context, channel.Source) error
+
+		// PhaseChanged is called when a phase change occurred that did not change
+		// the current or staging transaction. Only the phase needs to be
+		// persisted.
+		PhaseChanged(context.Context, channel.Source) error
+
+		// Close is called by the client when it shuts down. No more persistence
+		// requests will be made after the call.
+		io.Closer
+	}
+
+	// A Restorer allows a Client to restore channel machines. It has methods
+	// that return iterators over channel data.
+	Restorer interface {
+		// ActivePeers should return a list of all peers with which any channel is
+		// persisted.
+		ActivePeers(context.Context) ([]wallet.Address, error)
+
+		// RestorePeer should return an iterator over all persisted channels which
+		// the given peer is a part of.
+		RestorePeer(wallet.Address) (ChannelIterator, error)
+
+		// RestoreChannel should return the channel with the requested ID.
+		RestoreChannel(context.Context, channel.ID) (*Channel, error)
+	}
+
+	// PersistRestorer is a Persister and Restorer on the same data source and
+	// format.
+	PersistRestorer interface {
+		Persister
+		Restorer
+	}
+
+	// ChannelIterator is an iterator over Channels, i.e., channel machine's
+	// data.
+	//
+	// Usage:
+	//  for it.Next(ctx) {
+	//      ch := it.Channel()
+	//      // do something with ch
+	//  }
+	//  if err := it.Close(); err != nil {
+	//      panic(err)
+	//  }
+	ChannelIterator interface {
+		// Next advances the iterator to the next channel. It returns false if the
+		// iteration is done, the context expired or an error occurred. Use Close
+		// to check for an error.
+		Next(context.Context) bool
+
+		// Channel returns the current channel data.
+		Channel() *Channel
+
+		// Close closes the iterator and releases its resources. It returns the
+		// last iteration or closing error.
+		io.Closer
+	}
+
+	// Channel holds all data that is necessary to restore a channel controller.
+	Channel struct {
+		chSource
+		// PeersV are the channel network peer addresses of all participants.
+		PeersV []wallet.Address
+		// Parent is the ID of the parent ledger channel if this is a virtual
+		// channel and nil otherwise.
+		Parent *channel.ID
+		// Agreement is the funding agreement of the channel, or nil if every
+		// participant deposits its initial balances.
+		Agreement [][]channel.Bal
+	}
+
+	// chSource implements channel.Source.
+	chSource struct {
+		IdxV       channel.Index       // IdxV is the own index in the channel.
+		ParamsV    *channel.Params     // ParamsV are the channel parameters.
+		StagingTXV channel.Transaction // StagingTXV is the staging transaction.
+		CurrentTXV channel.Transaction // CurrentTXV is the current transaction.
+		PhaseV     channel.Phase       // PhaseV is the current channel phase.
+	}
+)
+
+var _ channel.Source = (*Channel)(nil)
+
+// NewChannel creates a new Channel object whose fields are initialized.
+func NewChannel() *Channel {
+	return &Channel{
+		chSource: chSource{
+			ParamsV: new(channel.Params),
+		},
+	}
+}
+
+// CloneSource creates a new Channel object whose fields are clones of the data
+// coming from Source s.
+func CloneSource(s channel.Source) *Channel {
+	return &Channel{
+		chSource: chSource{
+			IdxV:       s.Idx(),
+			ParamsV:    s.Params(),
+			StagingTXV: CloneTX(s.StagingTX()),
+			CurrentTXV: CloneTX(s.CurrentTX()),
+			PhaseV:     s.Phase(),
+		},
+	}
+}
+
+// CloneTX returns a deep copy of the transaction. The signatures are copied.
+func CloneTX(tx channel.Transaction) channel.Transaction {
+	var sigs []wallet.Sig
+	if tx.Sigs != nil {
+		sigs = make([]wallet.Sig, len(tx.Sigs))
+		for i, sig := range tx.Sigs {
+			if sig != nil {
+				sigs[i] = append(wallet.Sig(nil), sig...)
+			}
+		}
+	}
+	return channel.Transaction{
+		State: tx.State.Clone(),
+		Sigs:  sigs,
+	}
+}
+
+// ID is the channel ID of this source. It is the same as Params().ID().
+func (c *chSource) ID() channel.ID { return c.ParamsV.ID() }
+
+// Idx is the own index in the channel.
+func (c *chSource) Idx() channel.Index { return c.IdxV }
+
+// Params are the channel parameters.
+func (c *chSource) Params() *channel.Params { return c.ParamsV }
+
+// StagingTX is the staged transaction (State+incomplete list of sigs).
+func (c *chSource) StagingTX() channel.Transaction { return c.StagingTXV }
+
+// CurrentTX is the current transaction (State+complete list of sigs).
+func (c *chSource) CurrentTX() channel.Transaction { return c.CurrentTXV }
+
+// Phase is the phase in which the channel is currently in.
+func (c *chSource) Phase() channel.Phase { return c.PhaseV }
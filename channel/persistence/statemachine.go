@@ -0,0 +1,133 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package persistence
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+// A StateMachine is a channel.StateMachine that sends all changes to the
+// contained Persister. Every state-changing method is first applied to the
+// underlying channel.StateMachine and, on success, journaled via the
+// Persister. Persistence errors are returned to the caller, the state machine
+// transition is not reverted.
+type StateMachine struct {
+	*channel.StateMachine
+	pr Persister
+}
+
+// FromStateMachine creates a persisting StateMachine wrapper around the passed
+// StateMachine using the Persister pr.
+func FromStateMachine(m *channel.StateMachine, pr Persister) StateMachine {
+	return StateMachine{
+		StateMachine: m,
+		pr:           pr,
+	}
+}
+
+// Init calls Init on the channel.StateMachine and then persists the changed
+// staging state.
+func (m *StateMachine) Init(ctx context.Context, initBals channel.Allocation, initData channel.Data) error {
+	if err := m.StateMachine.Init(initBals, initData); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Staged(ctx, m.StateMachine), "Persister.Staged")
+}
+
+// Update calls Update on the channel.StateMachine and then persists the changed
+// staging state.
+func (m *StateMachine) Update(ctx context.Context, stagingState *channel.State, actor channel.Index) error {
+	if err := m.StateMachine.Update(stagingState, actor); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Staged(ctx, m.StateMachine), "Persister.Staged")
+}
+
+// UpdateLocked calls UpdateLocked on the channel.StateMachine and then
+// persists the changed staging state.
+func (m *StateMachine) UpdateLocked(ctx context.Context, stagingState *channel.State, actor channel.Index) error {
+	if err := m.StateMachine.UpdateLocked(stagingState, actor); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Staged(ctx, m.StateMachine), "Persister.Staged")
+}
+
+// Sig calls Sig on the channel.StateMachine and then persists the added
+// signature.
+func (m *StateMachine) Sig(ctx context.Context) (sig wallet.Sig, err error) {
+	if sig, err = m.StateMachine.Sig(); err != nil {
+		return
+	}
+	return sig, errors.WithMessage(m.pr.SigAdded(ctx, m.StateMachine, m.StateMachine.Idx()), "Persister.SigAdded")
+}
+
+// AddSig calls AddSig on the channel.StateMachine and then persists the added
+// signature.
+func (m *StateMachine) AddSig(ctx context.Context, idx channel.Index, sig wallet.Sig) error {
+	if err := m.StateMachine.AddSig(idx, sig); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.SigAdded(ctx, m.StateMachine, idx), "Persister.SigAdded")
+}
+
+// EnableInit calls EnableInit on the channel.StateMachine and then persists
+// the enabled transaction.
+func (m *StateMachine) EnableInit(ctx context.Context) error {
+	if err := m.StateMachine.EnableInit(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Enabled(ctx, m.StateMachine), "Persister.Enabled")
+}
+
+// EnableUpdate calls EnableUpdate on the channel.StateMachine and then
+// persists the enabled transaction.
+func (m *StateMachine) EnableUpdate(ctx context.Context) error {
+	if err := m.StateMachine.EnableUpdate(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Enabled(ctx, m.StateMachine), "Persister.Enabled")
+}
+
+// EnableFinal calls EnableFinal on the channel.StateMachine and then persists
+// the enabled transaction.
+func (m *StateMachine) EnableFinal(ctx context.Context) error {
+	if err := m.StateMachine.EnableFinal(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Enabled(ctx, m.StateMachine), "Persister.Enabled")
+}
+
+// DiscardUpdate calls DiscardUpdate on the channel.StateMachine and then
+// persists the cleared staging state.
+func (m *StateMachine) DiscardUpdate(ctx context.Context) error {
+	if err := m.StateMachine.DiscardUpdate(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.Staged(ctx, m.StateMachine), "Persister.Staged")
+}
+
+// SetFunded calls SetFunded on the channel.StateMachine and then persists the
+// changed phase.
+func (m *StateMachine) SetFunded(ctx context.Context) error {
+	if err := m.StateMachine.SetFunded(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.PhaseChanged(ctx, m.StateMachine), "Persister.PhaseChanged")
+}
+
+// SetSettled calls SetSettled on the channel.StateMachine and then persists
+// the changed phase.
+func (m *StateMachine) SetSettled(ctx context.Context) error {
+	if err := m.StateMachine.SetSettled(); err != nil {
+		return err
+	}
+	return errors.WithMessage(m.pr.PhaseChanged(ctx, m.StateMachine), "Persister.PhaseChanged")
+}
@@ -69,11 +69,7 @@ var _ perunio.Serializer = new(State)
 // initial state from the machine instead.
 func newState(params *Params, initBals Allocation, initData Data) (*State, error) {
 	// sanity checks
-	n := len(params.Parts)
-	if n != len(initBals.OfParts) {
-		return nil, errors.New("number of participants in parameters and initial balances don't match")
-	}
-	if err := initBals.Valid(); err != nil {
+	if err := initBals.ValidFor(len(params.Parts)); err != nil {
 		return nil, err
 	}
 
@@ -37,6 +37,24 @@ func NewStateMachine(acc wallet.Account, params Params) (*StateMachine, error) {
 	}, nil
 }
 
+// RestoreStateMachine restores a state machine to the data given by Source.
+func RestoreStateMachine(acc wallet.Account, source Source) (*StateMachine, error) {
+	app, ok := source.Params().App.(StateApp)
+	if !ok {
+		return nil, errors.New("app must be StateApp")
+	}
+
+	m, err := restoreMachine(acc, source)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StateMachine{
+		machine: m,
+		app:     app,
+	}, nil
+}
+
 // Init sets the initial staging state to the given balance and data.
 // It returns the initial state and own signature on it.
 func (m *StateMachine) Init(initBals Allocation, initData Data) error {
@@ -104,9 +122,34 @@ func (m *StateMachine) validTransition(to *State, actor Index) (err error) {
 	if err := m.machine.validTransition(to); err != nil {
 		return err
 	}
+	if !m.currentTX.App.Def().Equals(to.App.Def()) {
+		return m.validUpgrade(to)
+	}
 
-	if err = m.app.ValidTransition(&m.params, m.currentTX.State, to, actor); IsStateTransitionError(err) {
+	app, ok := m.currentTX.App.(StateApp)
+	if !ok {
+		return errors.New("app of current state must be StateApp")
+	}
+	if err = app.ValidTransition(&m.params, m.currentTX.State, to, actor); IsStateTransitionError(err) {
 		return err
 	}
 	return errors.WithMessagef(err, "runtime error in application's ValidTransition()")
 }
+
+// validUpgrade checks an upgrade of the channel's app, i.e., a transition to a
+// state of another app. The new app must be a StateApp and its ValidInit must
+// accept the new state. The app-specific rules of the current app are not
+// checked. Since all participants sign every state, an upgrade requires their
+// unanimous consent.
+func (m *StateMachine) validUpgrade(to *State) error {
+	app, ok := to.App.(StateApp)
+	if !ok {
+		return NewStateTransitionError(m.params.id, "upgraded app must be StateApp")
+	}
+	if err := app.ValidInit(&m.params, to); IsStateTransitionError(err) {
+		return err
+	} else if err != nil {
+		return errors.WithMessage(err, "upgraded app rejects state")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package channel_test
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestStateMachine_Update(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5747e))
+	m := newFundedStateMachine(t, rng)
+
+	tests := []struct {
+		name   string
+		modify func(*channel.State)
+	}{
+		{"version", func(s *channel.State) { s.Version++ }},
+		{"minting", func(s *channel.State) { s.OfParts[0][0].Add(s.OfParts[0][0], big.NewInt(1)) }},
+		{"assets", func(s *channel.State) { s.Assets = []channel.Asset{test.NewRandomAsset(rng)} }},
+		{"participants", func(s *channel.State) { s.OfParts = s.OfParts[:1] }},
+	}
+	for _, tt := range tests {
+		state := nextState(m)
+		tt.modify(state)
+		err := m.Update(state, 0)
+		assert.Error(t, err, tt.name)
+		assert.Equal(t, channel.Acting, m.Phase(), tt.name)
+	}
+
+	assert.Error(t, m.Update(nextState(m), 2), "actor out of range")
+	state := nextState(m)
+	state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(1))
+	state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(1))
+	require.NoError(t, m.Update(state, 0))
+	assert.Equal(t, channel.Signing, m.Phase())
+}
+
+func TestStateMachine_UpgradeApp(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa99))
+	m := newFundedStateMachine(t, rng)
+	assert.False(t, channel.IsAppUpgraded(m.Params(), m.State()))
+
+	upgrade := func(op channel.MockOp) *channel.State {
+		state := nextState(m)
+		state.App = test.NewRandomApp(rng)
+		state.Data = channel.NewMockOp(op)
+		return state
+	}
+	err := m.Update(upgrade(channel.OpTransitionErr), 0)
+	assert.True(t, channel.IsStateTransitionError(err), "new app rejects state")
+	assert.Error(t, m.Update(upgrade(channel.OpErr), 0), "runtime error of new app")
+	assert.Equal(t, channel.Acting, m.Phase())
+
+	state := upgrade(channel.OpValid)
+	assert.True(t, channel.IsAppUpgraded(m.Params(), state))
+	require.NoError(t, m.Update(state, 0))
+	assert.Equal(t, channel.Signing, m.Phase())
+}
+
+func TestEqualAssets(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa55e7))
+	a, b := test.NewRandomAsset(rng), test.NewRandomAsset(rng)
+	assert.True(t, channel.EqualAssets(nil, nil))
+	assert.True(t, channel.EqualAssets([]channel.Asset{a, b}, []channel.Asset{a, b}))
+	assert.False(t, channel.EqualAssets([]channel.Asset{a, b}, []channel.Asset{b, a}))
+	assert.False(t, channel.EqualAssets([]channel.Asset{a}, []channel.Asset{a, b}))
+}
+
+// newFundedStateMachine creates the state machine of the first participant of
+// a two-party channel of the mock app and sets it to the Acting phase.
+func newFundedStateMachine(t *testing.T, rng *rand.Rand) *channel.StateMachine {
+	accs := []wallet.Account{wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)}
+	parts := []wallet.Address{accs[0].Address(), accs[1].Address()}
+	params := channel.NewParamsUnsafe(10, parts, test.NewRandomApp(rng).Def(), big.NewInt(rng.Int63()))
+	alloc := channel.Allocation{
+		Assets:  []channel.Asset{test.NewRandomAsset(rng)},
+		OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(10)}},
+	}
+
+	ms := make([]*channel.StateMachine, len(accs))
+	for i, acc := range accs {
+		m, err := channel.NewStateMachine(acc, *params)
+		require.NoError(t, err)
+		require.NoError(t, m.Init(alloc.Clone(), channel.NewMockOp(channel.OpValid)))
+		ms[i] = m
+	}
+	sig, err := ms[1].Sig()
+	require.NoError(t, err)
+	_, err = ms[0].Sig()
+	require.NoError(t, err)
+	require.NoError(t, ms[0].AddSig(1, sig))
+	require.NoError(t, ms[0].EnableInit())
+	require.NoError(t, ms[0].SetFunded())
+	return ms[0]
+}
+
+// nextState returns a copy of the current state with increased version.
+func nextState(m *channel.StateMachine) *channel.State {
+	state := m.State().Clone()
+	state.Version++
+	return state
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+// DisputeSetup is the setup of a GenericDisputeTest.
+type DisputeSetup struct {
+	// Adjudicator is the adjudicator under test.
+	Adjudicator channel.Adjudicator
+
+	// Accounts are the accounts of the participants of the channel, in
+	// participant order.
+	Accounts []wallet.Account
+
+	// Params are the parameters of the channel. The challenge duration should
+	// be short, since the test waits for the timeouts of the registrations.
+	Params *channel.Params
+
+	// State is the funded initial state of the channel. The app must accept
+	// transitions that only redistribute the balances, see
+	// NewRandomTransition.
+	State *channel.State
+
+	// Progress enables the on-chain progression step. It requires the
+	// Adjudicator to be a channel.ProgressingAdjudicator.
+	Progress bool
+
+	// Timeout is the timeout of the whole dispute.
+	Timeout time.Duration
+
+	// Wait waits until the time of the adjudicator's ledger reached timeout,
+	// e.g., by mining blocks on a simulated blockchain. If it is nil, the
+	// test sleeps instead.
+	Wait func(ctx context.Context, timeout time.Time) error
+
+	// Withdrawn returns the balances that participant idx withdrew from the
+	// channel. If it is nil, the withdrawn balances are not checked.
+	Withdrawn func(id channel.ID, idx channel.Index) []channel.Bal
+}
+
+// GenericDisputeTest drives a dispute of the channel of the setup through the
+// adjudicator: The last participant registers a stale state, which the first
+// participant refutes with a newer state. If enabled, the refuted state is
+// progressed after its timeout. Finally, all participants withdraw the
+// outcome. Registrations of stale states and calls before the timeouts must
+// not change the registered state. If the adjudicator is a
+// channel.EventSubscriber, the emitted events are checked, too.
+func GenericDisputeTest(t *testing.T, rng *rand.Rand, s *DisputeSetup) {
+	require.Len(t, s.Accounts, len(s.Params.Parts), "one account per participant")
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	adj := s.Adjudicator
+	last := channel.Index(len(s.Accounts) - 1)
+
+	regSub, err := adj.SubscribeRegistered(ctx, s.Params)
+	require.NoError(t, err)
+	defer regSub.Close()
+	var events channel.AdjudicatorSubscription
+	if evSub, ok := adj.(channel.EventSubscriber); ok {
+		events, err = evSub.SubscribeEvents(ctx, s.Params)
+		require.NoError(t, err)
+		defer events.Close()
+	}
+
+	stale := NewRandomTransition(rng, s.State)
+	refuting := NewRandomTransition(rng, stale)
+	staleReq := s.req(t, last, stale)
+	req := s.req(t, 0, refuting)
+
+	// register old state
+	reg, err := adj.Register(ctx, staleReq)
+	require.NoError(t, err, "registering stale state")
+	assert.Equal(t, stale.Version, reg.Version)
+	assertRegistered(t, regSub.Next(), stale.Version, false)
+	assertEvent(t, events, &channel.RegisteredEvent{}, stale.Version)
+
+	// refute
+	reg, err = adj.Register(ctx, req)
+	require.NoError(t, err, "refuting")
+	assert.Equal(t, refuting.Version, reg.Version)
+	assertRegistered(t, regSub.Next(), refuting.Version, false)
+	assertEvent(t, events, &channel.RegisteredEvent{}, refuting.Version)
+
+	reg, err = adj.Register(ctx, staleReq)
+	require.NoError(t, err, "registering stale state again")
+	assert.Equal(t, refuting.Version, reg.Version, "stale state must not be registered")
+	assert.Error(t, adj.Withdraw(ctx, req), "withdrawal before timeout")
+
+	// progress
+	outcome := refuting
+	if s.Progress {
+		padj, ok := adj.(channel.ProgressingAdjudicator)
+		require.True(t, ok, "adjudicator must support progression")
+		progReq := s.progressReq(t, req, NewRandomTransition(rng, refuting))
+		_, err := padj.Progress(ctx, progReq)
+		assert.Error(t, err, "progression before timeout")
+
+		require.NoError(t, s.wait(ctx, reg.Timeout))
+		reg, err = padj.Progress(ctx, progReq)
+		require.NoError(t, err, "progressing")
+		assert.Equal(t, progReq.NewState.Version, reg.Version)
+		assert.True(t, reg.Progressed)
+		assertRegistered(t, regSub.Next(), progReq.NewState.Version, true)
+		assertEvent(t, events, &channel.ProgressedEvent{}, progReq.NewState.Version)
+
+		reg, err = adj.Register(ctx, req)
+		require.NoError(t, err, "refuting progressed state")
+		assert.Equal(t, progReq.NewState.Version, reg.Version, "progressed state must not be refuted")
+		outcome = progReq.NewState
+		req.Tx.State = outcome // withdrawal of the progressed state
+	}
+
+	// conclude
+	require.NoError(t, s.wait(ctx, reg.Timeout))
+	for i, acc := range s.Accounts {
+		wreq := req
+		wreq.Acc, wreq.Idx = acc, channel.Index(i)
+		require.NoError(t, adj.Withdraw(ctx, wreq), "withdrawing participant %d", i)
+		require.NoError(t, adj.Withdraw(ctx, wreq), "repeated withdrawal of participant %d", i)
+	}
+	assertEvent(t, events, &channel.ConcludedEvent{}, outcome.Version)
+
+	if s.Withdrawn == nil {
+		return
+	}
+	for i := range s.Accounts {
+		withdrawn := s.Withdrawn(s.Params.ID(), channel.Index(i))
+		require.Len(t, withdrawn, len(outcome.Assets), "withdrawn assets of participant %d", i)
+		for a, bal := range outcome.OfParts[i] {
+			assert.Zerof(t, bal.Cmp(withdrawn[a]), "withdrawn asset %d of participant %d: %v != %v",
+				a, i, withdrawn[a], bal)
+		}
+	}
+}
+
+// req returns the request of participant idx for the state signed by all
+// participants.
+func (s *DisputeSetup) req(t *testing.T, idx channel.Index, state *channel.State) channel.AdjudicatorReq {
+	tx := channel.Transaction{State: state, Sigs: make([]wallet.Sig, len(s.Accounts))}
+	for i, acc := range s.Accounts {
+		var err error
+		tx.Sigs[i], err = channel.Sign(acc, s.Params, state)
+		require.NoError(t, err)
+	}
+	return channel.AdjudicatorReq{Params: s.Params, Acc: s.Accounts[idx], Tx: tx, Idx: idx}
+}
+
+// progressReq returns the request to progress the registered transaction of
+// req to the new state, signed by the actor req.Idx.
+func (s *DisputeSetup) progressReq(t *testing.T, req channel.AdjudicatorReq, state *channel.State) channel.ProgressReq {
+	sig, err := channel.Sign(req.Acc, s.Params, state)
+	require.NoError(t, err)
+	return channel.ProgressReq{AdjudicatorReq: req, NewState: state, Sig: sig}
+}
+
+// wait waits until the timeout using Wait, if set.
+func (s *DisputeSetup) wait(ctx context.Context, timeout time.Time) error {
+	if s.Wait != nil {
+		return s.Wait(ctx, timeout)
+	}
+	select {
+	case <-time.After(time.Until(timeout)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// assertRegistered asserts that the Registered event has the given version
+// and progression flag.
+func assertRegistered(t *testing.T, reg *channel.Registered, version uint64, progressed bool) {
+	if !assert.NotNil(t, reg, "missing Registered event of version %d", version) {
+		return
+	}
+	assert.Equal(t, version, reg.Version)
+	assert.Equal(t, progressed, reg.Progressed)
+}
+
+// assertEvent asserts that the next event of the subscription has the type of
+// expected and the given version. If the subscription is nil, because the
+// adjudicator is no channel.EventSubscriber, nothing is checked.
+func assertEvent(t *testing.T, sub channel.AdjudicatorSubscription, expected channel.AdjudicatorEvent, version uint64) {
+	if sub == nil {
+		return
+	}
+	ev := sub.Next()
+	if !assert.IsType(t, expected, ev, "subscription error: %v", sub.Err()) {
+		return
+	}
+	switch ev := ev.(type) {
+	case *channel.RegisteredEvent:
+		assert.Equal(t, version, ev.Version)
+	case *channel.ProgressedEvent:
+		assert.Equal(t, version, ev.Version)
+	case *channel.ConcludedEvent:
+		assert.Equal(t, version, ev.Version)
+	}
+}
@@ -6,6 +6,7 @@
 package test // import "perun.network/go-perun/channel/test"
 
 import (
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -62,6 +63,12 @@ func genericChannelIDTest(t *testing.T, s *Setup) {
 	require.NotNil(t, s.Params.Parts, "params.Parts can not be nil")
 	assert.Panics(t, func() { channel.CalcID(nil) }, "ChannelID(nil) should panic")
 
+	// Check that the id is deterministic in the parameters
+	clone := *s.Params
+	clone.Parts = append([]wallet.Address(nil), s.Params.Parts...)
+	clone.Nonce = new(big.Int).Set(s.Params.Nonce)
+	assert.Equal(t, s.State.ID, channel.CalcID(&clone), "Channel ids of equal params should match")
+
 	// Check that modifying the state changes the id
 	for _, modParams := range buildModifiedParams(s.Params, s.Params2, s) {
 		ID := channel.CalcID(&modParams)
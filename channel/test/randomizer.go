@@ -22,6 +22,13 @@ type Randomizer interface {
 	NewRandomAsset(*rand.Rand) channel.Asset
 }
 
+// A LedgerRandomizer is a Randomizer that can also create random assets that
+// live on a given ledger. It is needed for testing multi-ledger channels.
+type LedgerRandomizer interface {
+	Randomizer
+	NewRandomLedgerAsset(*rand.Rand, channel.LedgerID) channel.LedgerAsset
+}
+
 var randomizer Randomizer
 
 // SetRandomizer sets the global Randomizer variable.
@@ -37,27 +44,52 @@ func NewRandomAsset(rng *rand.Rand) channel.Asset {
 	return randomizer.NewRandomAsset(rng)
 }
 
-// NewRandomAllocation creates a new random allocation.
-func NewRandomAllocation(rng *rand.Rand, numParts int) *channel.Allocation {
+// NewRandomLedgerAsset creates a new random channel.LedgerAsset that lives on
+// the given ledger. It panics if the Randomizer is no LedgerRandomizer.
+func NewRandomLedgerAsset(rng *rand.Rand, ledger channel.LedgerID) channel.LedgerAsset {
+	r, ok := randomizer.(LedgerRandomizer)
+	if !ok {
+		log.Panic("channel/test randomizer doesn't support ledgers")
+	}
+	return r.NewRandomLedgerAsset(rng, ledger)
+}
+
+// NewRandomAllocation creates a new random allocation for numParts
+// participants. The number of assets and sub-allocations and the range of the
+// balances can be constrained with WithNumAssets, WithNumLocked and
+// WithMaxBal.
+func NewRandomAllocation(rng *rand.Rand, numParts int, opts ...RandomOpt) *channel.Allocation {
 	if numParts > channel.MaxNumParts {
 		panic(fmt.Sprintf(
 			"Expected at most %d participants, got %d",
 			channel.MaxNumParts, numParts))
 	}
+	o := makeRandomOpts(opts)
 
-	assets := make([]channel.Asset, rng.Int31n(9)+2)
+	numAssets := o.numAssets
+	if numAssets == 0 {
+		numAssets = int(rng.Int31n(9)) + 2
+	}
+	assets := make([]channel.Asset, numAssets)
 	for i := 0; i < len(assets); i++ {
 		assets[i] = NewRandomAsset(rng)
 	}
 
 	ofparts := make([][]channel.Bal, numParts)
 	for i := 0; i < len(ofparts); i++ {
-		ofparts[i] = NewRandomBals(rng, len(assets))
+		ofparts[i] = newRandomBals(rng, len(assets), o.maxBal)
 	}
 
-	locked := make([]channel.SubAlloc, rng.Int31n(9)+2)
+	numLocked := o.numLocked
+	if !o.hasNumLocked {
+		numLocked = int(rng.Int31n(9)) + 2
+	}
+	locked := make([]channel.SubAlloc, numLocked)
 	for i := 0; i < len(locked); i++ {
-		locked[i] = *NewRandomSubAlloc(rng, len(assets))
+		locked[i] = channel.SubAlloc{
+			ID:   NewRandomChannelID(rng),
+			Bals: newRandomBals(rng, len(assets), o.maxBal),
+		}
 	}
 
 	return &channel.Allocation{Assets: assets, OfParts: ofparts, Locked: locked}
@@ -68,12 +100,22 @@ func NewRandomSubAlloc(rng *rand.Rand, size int) *channel.SubAlloc {
 	return &channel.SubAlloc{ID: NewRandomChannelID(rng), Bals: NewRandomBals(rng, size)}
 }
 
-// NewRandomParams creates new random channel.Params.
-func NewRandomParams(rng *rand.Rand, appDef wallet.Address) *channel.Params {
+// NewRandomParams creates new random channel.Params. The participants and
+// challenge duration can be constrained with WithNumParts, WithParts and
+// WithChallengeDuration.
+func NewRandomParams(rng *rand.Rand, appDef wallet.Address, opts ...RandomOpt) *channel.Params {
+	o := makeRandomOpts(opts)
 	var challengeDuration = rng.Uint64()
-	parts := make([]wallet.Address, rng.Int31n(5)+2)
-	for i := 0; i < len(parts); i++ {
-		parts[i] = wallettest.NewRandomAddress(rng)
+	if o.challengeDuration != nil {
+		challengeDuration = *o.challengeDuration
+	}
+	parts := o.parts
+	if parts == nil {
+		numParts := o.numParts
+		if numParts == 0 {
+			numParts = int(rng.Int31n(5)) + 2
+		}
+		parts = wallettest.NewRandomAddresses(rng, numParts)
 	}
 	nonce := big.NewInt(int64(rng.Uint32()))
 
@@ -84,15 +126,28 @@ func NewRandomParams(rng *rand.Rand, appDef wallet.Address) *channel.Params {
 	return params
 }
 
-// NewRandomState creates a new random state.
-func NewRandomState(rng *rand.Rand, p *channel.Params) *channel.State {
+// NewRandomState creates a new random state. Its version and final flag can
+// be set with WithVersion and WithIsFinal, the options of its allocation are
+// passed to NewRandomAllocation.
+func NewRandomState(rng *rand.Rand, p *channel.Params, opts ...RandomOpt) *channel.State {
+	o := makeRandomOpts(opts)
+	version := rng.Uint64()
+	if o.version != nil {
+		version = *o.version
+	}
+	alloc := *NewRandomAllocation(rng, len(p.Parts), opts...)
+	data := NewRandomData(rng)
+	isFinal := rng.Int31n(2) == 0
+	if o.isFinal != nil {
+		isFinal = *o.isFinal
+	}
 	return &channel.State{
 		ID:         p.ID(),
-		Version:    rng.Uint64(),
+		Version:    version,
 		App:        p.App,
-		Allocation: *NewRandomAllocation(rng, len(p.Parts)),
-		Data:       NewRandomData(rng),
-		IsFinal:    (rng.Int31n(2) == 0),
+		Allocation: alloc,
+		Data:       data,
+		IsFinal:    isFinal,
 	}
 }
 
@@ -111,9 +166,41 @@ func NewRandomBal(rng *rand.Rand) channel.Bal {
 
 // NewRandomBals creates new random balances.
 func NewRandomBals(rng *rand.Rand, size int) []channel.Bal {
+	return newRandomBals(rng, size, nil)
+}
+
+// newRandomBals creates new random balances below max. If max is nil, the
+// balances are random non-negative int64s.
+func newRandomBals(rng *rand.Rand, size int, max *big.Int) []channel.Bal {
 	bals := make([]channel.Bal, size)
 	for i := 0; i < size; i++ {
-		bals[i] = NewRandomBal(rng)
+		if max == nil {
+			bals[i] = NewRandomBal(rng)
+		} else {
+			bals[i] = new(big.Int).Rand(rng, max)
+		}
 	}
 	return bals
 }
+
+// NewRandomTransition creates a random successor of the state s. Its version
+// is incremented and a random amount of every asset is moved between two
+// random participants, so that the sum of each asset is preserved. Sub-
+// allocations and app data are kept.
+func NewRandomTransition(rng *rand.Rand, s *channel.State) *channel.State {
+	next := s.Clone()
+	next.Version++
+	numParts := len(next.OfParts)
+	for a := range next.Assets {
+		from, to := rng.Intn(numParts), rng.Intn(numParts)
+		bal := next.OfParts[from][a]
+		if bal.Sign() <= 0 {
+			continue
+		}
+		amount := new(big.Int).Rand(rng, bal)
+		if err := next.Transfer(channel.Index(from), channel.Index(to), a, amount); err != nil {
+			panic(err) // amount is at most the balance
+		}
+	}
+	return next
+}
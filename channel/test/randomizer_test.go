@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test_test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel/test"
+	pkgtest "perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestNewRandomState_Opts(t *testing.T) {
+	rng := pkgtest.Prng(t)
+	parts := wallettest.NewRandomAddresses(rng, 3)
+	params := test.NewRandomParams(rng, wallettest.NewRandomAddress(rng),
+		test.WithParts(parts...), test.WithChallengeDuration(60))
+	assert.Equal(t, parts, params.Parts)
+	assert.Equal(t, uint64(60), params.ChallengeDuration)
+
+	max := big.NewInt(100)
+	state := test.NewRandomState(rng, params,
+		test.WithVersion(7), test.WithIsFinal(false),
+		test.WithNumAssets(2), test.WithNumLocked(0), test.WithMaxBal(max))
+	assert.Equal(t, uint64(7), state.Version)
+	assert.False(t, state.IsFinal)
+	require.NoError(t, state.Allocation.ValidFor(3))
+	assert.Len(t, state.Assets, 2)
+	assert.Len(t, state.Locked, 0)
+	for _, bals := range state.OfParts {
+		for _, bal := range bals {
+			assert.True(t, bal.Cmp(max) < 0)
+		}
+	}
+
+	params = test.NewRandomParams(rng, wallettest.NewRandomAddress(rng), test.WithNumParts(4))
+	assert.Len(t, params.Parts, 4)
+}
+
+func TestNewRandomAllocation_Reproducible(t *testing.T) {
+	// Note that addresses of the sim backend are not reproducible, since
+	// crypto/ecdsa may not use the given rng.
+	newAlloc := func() interface{} {
+		return test.NewRandomAllocation(pkgtest.Prng(t), 3)
+	}
+	assert.Equal(t, newAlloc(), newAlloc())
+}
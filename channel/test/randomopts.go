@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test
+
+import (
+	"math/big"
+
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// A RandomOpt constrains the data that is created by the random
+	// generators of this package, e.g., NewRandomAllocation. Options that do
+	// not apply to a generator are ignored by it.
+	RandomOpt func(*randomOpts)
+
+	randomOpts struct {
+		numParts          int
+		parts             []wallet.Address
+		numAssets         int
+		numLocked         int
+		hasNumLocked      bool
+		maxBal            *big.Int
+		challengeDuration *uint64
+		version           *uint64
+		isFinal           *bool
+	}
+)
+
+// WithNumParts sets the number of participants of random params.
+func WithNumParts(n int) RandomOpt {
+	return func(o *randomOpts) { o.numParts = n }
+}
+
+// WithParts sets the participants of random params.
+func WithParts(parts ...wallet.Address) RandomOpt {
+	return func(o *randomOpts) { o.parts = parts }
+}
+
+// WithNumAssets sets the number of assets of random allocations.
+func WithNumAssets(n int) RandomOpt {
+	return func(o *randomOpts) { o.numAssets = n }
+}
+
+// WithNumLocked sets the number of sub-allocations of random allocations.
+// It may be 0.
+func WithNumLocked(n int) RandomOpt {
+	return func(o *randomOpts) { o.numLocked, o.hasNumLocked = n, true }
+}
+
+// WithMaxBal sets the upper bound, exclusive, of random balances.
+func WithMaxBal(max *big.Int) RandomOpt {
+	return func(o *randomOpts) { o.maxBal = max }
+}
+
+// WithChallengeDuration sets the challenge duration of random params.
+func WithChallengeDuration(d uint64) RandomOpt {
+	return func(o *randomOpts) { o.challengeDuration = &d }
+}
+
+// WithVersion sets the version of random states.
+func WithVersion(v uint64) RandomOpt {
+	return func(o *randomOpts) { o.version = &v }
+}
+
+// WithIsFinal sets the final flag of random states.
+func WithIsFinal(final bool) RandomOpt {
+	return func(o *randomOpts) { o.isFinal = &final }
+}
+
+func makeRandomOpts(opts []RandomOpt) (o randomOpts) {
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return
+}
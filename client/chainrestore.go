@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+// A ChainRestoredChannel is a channel that RestoreFromChain found on-chain.
+type ChainRestoredChannel struct {
+	Params *channel.Params
+	Tx     channel.Transaction // newest registered or concluded transaction
+	Idx    channel.Index       // index of our participant
+	// Withdrawn is set if our outcome of Tx was withdrawn, now or before.
+	// Otherwise, the registered state can still be refuted or progressed until
+	// Timeout and RestoreFromChain should be called again afterwards.
+	Withdrawn bool
+	Timeout   time.Time
+}
+
+// RestoreFromChain recovers the funds of channels whose persisted data was
+// lost, using only the on-chain state. The client's adjudicator has to be a
+// channel.ChainScanner. It scans for all channels in which one of the given
+// participant accounts participates, or one of the client's identities if
+// none is given, and withdraws the outcome of all channels that are concluded
+// or whose registered state timed out.
+//
+// Only channels whose state was registered or concluded on-chain are found,
+// e.g., because a peer disputed or settled them. Since the newest off-chain
+// states are lost, a registered state cannot be refuted and the channels are
+// not restored as Channels. Channels that are known to the client are skipped,
+// they should be settled with Channel.Settle instead.
+//
+// The found channels are returned, also if withdrawing the outcome of one of
+// them fails. Repeated calls are safe.
+func (c *Client) RestoreFromChain(ctx context.Context, accs ...wallet.Account) ([]*ChainRestoredChannel, error) {
+	scanner, ok := c.adjudicator.(channel.ChainScanner)
+	if !ok {
+		return nil, errors.New("adjudicator cannot scan the chain for channels")
+	}
+	if len(accs) == 0 {
+		accs = []wallet.Account{c.id}
+		c.idents.mutex.RLock()
+		for _, ident := range c.idents.ids {
+			accs = append(accs, ident.id)
+		}
+		c.idents.mutex.RUnlock()
+	}
+
+	var restored []*ChainRestoredChannel
+	for _, acc := range accs {
+		scanned, err := scanner.ScanChannels(ctx, acc.Address())
+		if err != nil {
+			return restored, errors.WithMessagef(err, "scanning channels of %v", acc.Address())
+		}
+		for _, sc := range scanned {
+			idx := wallet.IndexOfAddr(sc.Params.Parts, acc.Address())
+			if idx < 0 || c.channels.Has(sc.Params.ID()) {
+				continue
+			}
+			rc := &ChainRestoredChannel{
+				Params:  sc.Params,
+				Tx:      sc.Tx,
+				Idx:     channel.Index(idx),
+				Timeout: sc.Timeout,
+			}
+			restored = append(restored, rc)
+			if !sc.Concluded && time.Now().Before(sc.Timeout) {
+				c.logChan(sc.Params.ID()).Infof("Registered state times out at %v", sc.Timeout)
+				continue
+			}
+
+			req := channel.AdjudicatorReq{Params: sc.Params, Acc: acc, Tx: sc.Tx, Idx: rc.Idx}
+			if err := c.adjudicator.Withdraw(ctx, req); err != nil {
+				return restored, errors.WithMessagef(err, "withdrawing channel %x", sc.Params.ID())
+			}
+			rc.Withdrawn = true
+			c.logChan(sc.Params.ID()).Info("Withdrew outcome from chain")
+		}
+	}
+	return restored, nil
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simchannel "perun.network/go-perun/backend/sim/channel"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_RestoreFromChain(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xc01d))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	ledger := simchannel.NewLedger("")
+
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		simchannel.NewFunder(ledger), simchannel.NewAdjudicator(ledger))
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		simchannel.NewFunder(ledger), simchannel.NewAdjudicator(ledger))
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, simchannel.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	aliceCh, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	bobCh := <-bobHandler.chans
+	require.NoError(t, aliceCh.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+		return nil
+	}))
+	require.NoError(t, aliceCh.Settle(ctx))
+	// Bob loses his channels before he settles. He is closed only after he
+	// completed accepting the final state, so that the update handler doesn't
+	// fail.
+	for !bobCh.State().IsFinal {
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("final state not enabled at Bob")
+		}
+	}
+	require.NoError(t, bob.Close())
+	require.Nil(t, ledger.Withdrawn(aliceCh.ID(), 1))
+
+	t.Run("no scanner", func(t *testing.T) {
+		c := client.New(bobID, hub.NewDialer(), &virtualPropHandler{t: t},
+			simchannel.NewFunder(ledger), &logAdjudicator{log.Get()})
+		defer c.Close()
+		_, err := c.RestoreFromChain(ctx, bobHandler.acc)
+		assert.Error(t, err)
+	})
+
+	restarted := client.New(bobID, hub.NewDialer(), &virtualPropHandler{t: t},
+		simchannel.NewFunder(ledger), simchannel.NewAdjudicator(ledger))
+	defer restarted.Close()
+	restored, err := restarted.RestoreFromChain(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, restored, "identity is no participant")
+
+	for i := 0; i < 2; i++ { // repeated calls are safe
+		restored, err = restarted.RestoreFromChain(ctx, bobHandler.acc)
+		require.NoError(t, err)
+		require.Len(t, restored, 1)
+		assert.Equal(t, aliceCh.ID(), restored[0].Params.ID())
+		assert.Equal(t, channel.Index(1), restored[0].Idx)
+		assert.Equal(t, aliceCh.State().Version, restored[0].Tx.Version)
+		assert.True(t, restored[0].Withdrawn)
+	}
+	withdrawn := ledger.Withdrawn(aliceCh.ID(), 1)
+	require.Len(t, withdrawn, 1)
+	assert.Zero(t, withdrawn[0].Cmp(big.NewInt(110)), "withdrawn: %v != 110", withdrawn[0])
+}
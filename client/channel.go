@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/peer"
 	perunsync "perun.network/go-perun/pkg/sync"
@@ -28,10 +29,37 @@ type Channel struct {
 	log log.Logger
 
 	conn        *channelConn
-	machine     channel.StateMachine
+	machine     persistence.StateMachine
 	machMtx     sync.RWMutex
+	pr          persistence.Persister
 	updateSub   chan<- *channel.State
 	adjudicator channel.Adjudicator
+	// peerAddrs are the network addresses of all participants, including ours.
+	peerAddrs []wallet.Address
+	// agreement is the funding agreement of a ledger channel, or nil if every
+	// participant deposits its initial balances.
+	agreement [][]channel.Bal
+
+	// parent is the ledger channel funding this channel if it is a virtual
+	// channel or a sub-channel, nil otherwise. sub is set for sub-channels.
+	parent *Channel
+	sub    bool
+	// subs contains the sub-channels that are funded by this channel and
+	// subFunding the sub-channels whose funding we expect. Both are protected
+	// by machMtx.
+	subs       map[channel.ID]*Channel
+	subFunding map[channel.ID]chan struct{}
+	// forced is the last state that we progressed on-chain with ForceUpdate,
+	// nil otherwise. It is protected by machMtx.
+	forced *forcedState
+	// pipe contains the pending updates proposed with UpdateByPipelined. It
+	// is protected by machMtx.
+	pipe updatePipeline
+	// vFunding is used if we act as an intermediary for virtual channels that
+	// are funded by this channel.
+	vFunding *virtualFundingMatcher
+	// events is the event bus of the Client.
+	events *eventBus
 }
 
 // newChannel is internally used by the Client to create a new channel
@@ -41,11 +69,25 @@ func newChannel(
 	peers []*peer.Peer,
 	params channel.Params,
 	adjudicator channel.Adjudicator,
+	pr persistence.Persister,
 ) (*Channel, error) {
 	machine, err := channel.NewStateMachine(acc, params)
 	if err != nil {
 		return nil, errors.WithMessage(err, "creating state machine")
 	}
+	return newChannelFromMachine(machine, peers, adjudicator, pr)
+}
+
+// newChannelFromMachine creates a new channel controller around the given
+// state machine. It is used for new as well as restored channels.
+func newChannelFromMachine(
+	machine *channel.StateMachine,
+	peers []*peer.Peer,
+	adjudicator channel.Adjudicator,
+	pr persistence.Persister,
+) (*Channel, error) {
+	params := machine.Params()
+	acc := machine.Account()
 
 	// bundle peers into channel connection
 	conn, err := newChannelConn(params.ID(), peers, machine.Idx())
@@ -53,12 +95,13 @@ func newChannel(
 		return nil, errors.WithMessagef(err, "setting up channel connection")
 	}
 
-	logger := log.WithFields(log.Fields{"channel": params.ID(), "id": acc.Address()})
+	logger := log.WithFields(log.Fields{log.ChannelField: params.ID(), log.IDField: acc.Address()})
 	conn.SetLogger(logger)
 	return &Channel{
 		log:         logger,
 		conn:        conn,
-		machine:     *machine,
+		machine:     persistence.FromStateMachine(machine, pr),
+		pr:          pr,
 		adjudicator: adjudicator,
 	}, nil
 }
@@ -68,6 +111,9 @@ func (c *Channel) Close() error {
 	if err := c.Closer.Close(); err != nil {
 		return err
 	}
+	if c.events != nil {
+		c.events.emit(ChannelClosed{ID: c.ID()})
+	}
 	return c.conn.Close()
 }
 
@@ -79,6 +125,12 @@ func (c *Channel) logPeer(idx channel.Index) log.Logger {
 	return c.log.WithField("peerIdx", idx)
 }
 
+// logPhase returns the logger of the channel with the current phase of the
+// machine. The machine must be locked by the caller.
+func (c *Channel) logPhase() log.Logger {
+	return c.log.WithField(log.PhaseField, c.machine.Phase())
+}
+
 // ID returns the channel ID.
 func (c *Channel) ID() channel.ID {
 	return c.machine.ID()
@@ -94,6 +146,24 @@ func (c *Channel) Params() *channel.Params {
 	return c.machine.Params()
 }
 
+// IsVirtual returns whether this is a virtual channel, i.e., whether it is
+// funded by a ledger channel with an intermediary.
+func (c *Channel) IsVirtual() bool {
+	return c.parent != nil && !c.sub
+}
+
+// IsSubChannel returns whether this is a sub-channel, i.e., whether it is
+// funded by a ledger channel with the same peer.
+func (c *Channel) IsSubChannel() bool {
+	return c.sub
+}
+
+// Parent returns the ledger channel funding this virtual channel or
+// sub-channel or nil if this is a ledger channel.
+func (c *Channel) Parent() *Channel {
+	return c.parent
+}
+
 // State returns the current state.
 // Clone it if you want to modify it.
 func (c *Channel) State() *channel.State {
@@ -111,19 +181,27 @@ func (c *Channel) Phase() channel.Phase {
 	return c.machine.Phase()
 }
 
+// adjudicatorReq returns the adjudicator request for the current transaction.
+func (c *Channel) adjudicatorReq() channel.AdjudicatorReq {
+	c.machMtx.RLock()
+	defer c.machMtx.RUnlock()
+
+	return c.machine.AdjudicatorReq()
+}
+
 // init brings the state machine into the InitSigning phase. It is not callable
 // by the user since the Client initializes the channel controller.
 // The state machine is not locked as this function is expected to be called
 // during the initialization phase of the channel controller.
-func (c *Channel) init(initBals *channel.Allocation, initData channel.Data) error {
-	return c.machine.Init(*initBals, initData)
+func (c *Channel) init(ctx context.Context, initBals *channel.Allocation, initData channel.Data) error {
+	return c.machine.Init(ctx, *initBals, initData)
 }
 
 // initExchangeSigsAndEnable exchanges signatures on the initial state.
 // The state machine is not locked as this function is expected to be called
 // during the initialization phase of the channel controller.
 func (c *Channel) initExchangeSigsAndEnable(ctx context.Context) error {
-	sig, err := c.machine.Sig()
+	sig, err := c.machine.Sig(ctx)
 	if err != nil {
 		return err
 	}
@@ -143,57 +221,185 @@ func (c *Channel) initExchangeSigsAndEnable(ctx context.Context) error {
 		})
 	}()
 
-	pidx, cm := resRecv.Next(ctx)
-	acc, ok := cm.(*msgChannelUpdateAcc)
-	if !ok {
-		return errors.Errorf(
-			"received unexpected message of type (%T) from peer[%d]: %v",
-			cm, pidx, cm)
-	}
+	// Receive the signatures of all peers, each exactly once.
+	received := make([]bool, len(c.Params().Parts))
+	received[c.machine.Idx()] = true
+	for i := 1; i < len(c.Params().Parts); i++ {
+		pidx, cm := resRecv.Next(ctx)
+		acc, ok := cm.(*msgChannelUpdateAcc)
+		if !ok {
+			return errors.Errorf(
+				"received unexpected message of type (%T) from peer[%d]: %v",
+				cm, pidx, cm)
+		}
+		if int(pidx) >= len(received) || received[pidx] {
+			return errors.Errorf("received duplicate initial signature from peer[%d]", pidx)
+		}
+		received[pidx] = true
 
-	if err := c.machine.AddSig(pidx, acc.Sig); err != nil {
-		return err
+		if err := c.machine.AddSig(ctx, pidx, acc.Sig); err != nil {
+			return err
+		}
 	}
-	if err := c.machine.EnableInit(); err != nil {
+	if err := c.machine.EnableInit(ctx); err != nil {
 		return err
 	}
 
 	return errors.WithMessage(<-send, "sending initial signature")
 }
 
-// Settle settles the channel using the Settler. The channel must be in a
-// final state.
+// Settle settles the channel on the adjudicator, or in the parent ledger
+// channel if it is a virtual channel or a sub-channel.
+//
+// If the channel is not final yet, Settle first tries to finalize it
+// cooperatively by proposing the current state as final state to all peers.
+// If they accept, the final state is registered and withdrawn immediately
+// (cooperative path). Otherwise, the current state is registered in a dispute
+// and withdrawn after the challenge duration has passed (dispute path).
+// Virtual channels and sub-channels can only be settled cooperatively.
+//
+// A sub-channel only needs to be settled in the parent channel by one of its
+// participants. If the peer already did so, Settle only sets the sub-channel to
+// the Settled phase. Both participants settling concurrently can fail.
+//
+// A channel that was force-updated is settled by withdrawing the last forced
+// state after the timeout of its progression.
 func (c *Channel) Settle(ctx context.Context) error {
 	c.machMtx.Lock()
 	defer c.machMtx.Unlock()
 
+	if c.forced != nil {
+		return c.settleForced(ctx)
+	}
+
+	if c.machine.Phase() == channel.Acting {
+		if err := c.finalize(ctx); err != nil {
+			if c.parent != nil {
+				return errors.WithMessage(err, "finalizing channel funded by parent")
+			}
+			c.logPhase().Warnf("Cooperative finalization failed, settling in dispute: %v", err)
+			return c.settleDispute(ctx)
+		}
+	}
+
 	// check final state
 	if c.machine.Phase() != channel.Final || !c.machine.State().IsFinal {
-		return errors.New("currently, only channels in a final state can be settled")
+		return errors.Errorf("cannot settle channel in phase %v", c.machine.Phase())
+	}
+
+	if c.IsSubChannel() {
+		if err := c.parent.settleSubChannel(ctx, c); err != nil {
+			return errors.WithMessage(err, "settling sub-channel in parent")
+		}
+		return c.setSettled(ctx)
+	} else if c.IsVirtual() {
+		if err := c.parent.settleVirtualChannel(ctx, c); err != nil {
+			return errors.WithMessage(err, "settling virtual channel in parent")
+		}
+		return c.setSettled(ctx)
 	}
 
 	req := c.machine.AdjudicatorReq()
+	event, err := c.register(ctx, req)
+	if err != nil {
+		return err
+	}
+	if event.Timeout.Unix() > time.Now().Unix() {
+		c.logPhase().Warnf("Unexpected withdrawal timeout during Settle(). Waiting until %v", event.Timeout)
+		if err := waitUntil(ctx, event.Timeout); err != nil {
+			return err
+		}
+	}
+	return c.withdraw(ctx, req)
+}
+
+// finalize proposes the current state as final state to all peers. If they
+// accept, the channel is in the Final phase afterwards.
+// The machine must be locked by the caller.
+func (c *Channel) finalize(ctx context.Context) error {
+	final := c.machine.State().Clone()
+	final.Version++
+	final.IsFinal = true
+	return c.update(ctx, ChannelUpdate{State: final, ActorIdx: c.machine.Idx()})
+}
 
+// settleDispute registers the current non-final state and withdraws it once
+// the registration timed out, i.e., the challenge duration passed.
+// The machine must be locked by the caller.
+func (c *Channel) settleDispute(ctx context.Context) error {
+	req := c.machine.AdjudicatorReq()
+	event, err := c.register(ctx, req)
+	if err != nil {
+		return err
+	}
+	c.logPhase().Infof("Registered state in dispute. Waiting until %v", event.Timeout)
+	if err := waitUntil(ctx, event.Timeout); err != nil {
+		return err
+	}
+	return c.withdraw(ctx, req)
+}
+
+// RecoverDeposit reclaims our deposit of a channel whose funding failed, e.g.,
+// because a peer did not fund it in time, see channel.FundingTimeoutError. The
+// initial state, which is signed by all participants, is registered and
+// withdrawn once the challenge duration passed. Since the channel is
+// underfunded, the deposits are paid out instead of the initial balances.
+// Afterwards, the channel is in the Settled phase.
+func (c *Channel) RecoverDeposit(ctx context.Context) error {
+	if c.parent != nil {
+		return errors.New("channels funded by a parent have no deposit to recover")
+	}
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if c.machine.Phase() != channel.Funding {
+		return errors.Errorf("cannot recover deposit of channel in phase %v", c.machine.Phase())
+	}
+	return c.settleDispute(ctx)
+}
+
+// register registers the transaction of req on the adjudicator and checks that
+// the registered version is the requested one.
+func (c *Channel) register(ctx context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
 	event, err := c.adjudicator.Register(ctx, req)
 	if err != nil {
-		return errors.WithMessage(err, "calling Register")
+		return nil, errors.WithMessage(err, "calling Register")
 	}
 	if event.Version != req.Tx.Version {
-		return errors.Errorf("Invalid version registered want %v, got %v", req.Tx.Version, event.Version)
-	}
-	if event.Timeout.Unix() > time.Now().Unix() {
-		c.log.Warnf("Unexpected withdrawal timeout during Settle(). Waiting until %v", event.Timeout)
-		ticker := time.NewTicker(time.Until(event.Timeout))
-		select {
-		case <-ticker.C:
-		case <-ctx.Done():
-			return errors.New("Canceled during waiting time")
-		}
+		return nil, errors.Errorf("Invalid version registered want %v, got %v", req.Tx.Version, event.Version)
 	}
+	c.events.emit(DisputeRegistered{Channel: c, Registered: event})
+	return event, nil
+}
 
+// withdraw withdraws the registered transaction of req and sets the channel to
+// the Settled phase.
+// The machine must be locked by the caller.
+func (c *Channel) withdraw(ctx context.Context, req channel.AdjudicatorReq) error {
 	if err := c.adjudicator.Withdraw(ctx, req); err != nil {
 		return errors.WithMessage(err, "calling Withdraw")
 	}
+	return c.setSettled(ctx)
+}
+
+// waitUntil waits until the given time is reached or the context is done.
+func waitUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return errors.New("Canceled during waiting time")
+	}
+}
 
-	return c.machine.SetSettled()
+// setSettled sets the machine to the Settled phase and removes the channel
+// from the persistence since its data is not needed any more.
+func (c *Channel) setSettled(ctx context.Context) error {
+	if err := c.machine.SetSettled(ctx); err != nil {
+		return err
+	}
+	return errors.WithMessage(c.pr.ChannelRemoved(ctx, c.ID()), "removing channel from persistence")
 }
@@ -23,6 +23,7 @@ type channelConn struct {
 	b         *peer.Broadcaster
 	r         *peer.Relay
 	upReqRecv *channelMsgRecv
+	syncRecv  *channelMsgRecv
 	peerIdx   map[*peer.Peer]channel.Index
 
 	log log.Logger
@@ -37,6 +38,7 @@ func newChannelConn(id channel.ID, peers []*peer.Peer, idx channel.Index) (_ *ch
 	// setup receiving infrastructure:
 	// 1. one relay to combine all channel messages from all peers
 	// 2. two receivers for update requests and update responses
+	// 3. one receiver for sync messages
 	relay := peer.NewRelay()
 	// we cache all channel messsages for the lifetime of the relay
 	relay.Cache(context.Background(), func(wire.Msg) bool { return true })
@@ -69,22 +71,32 @@ func newChannelConn(id channel.ID, peers []*peer.Peer, idx channel.Index) (_ *ch
 		}
 	}
 
-	logger := log.WithField("channel", id)
+	logger := log.WithField(log.ChannelField, id)
 	upReqRecv := &channelMsgRecv{
 		Receiver: peer.NewReceiver(),
 		peerIdx:  peerIdx,
 		log:      logger,
 	}
 	if err = relay.Subscribe(upReqRecv, func(m wire.Msg) bool {
-		return m.Type() == wire.ChannelUpdate
+		_, ok := m.(channelUpdateReqMsg)
+		return ok
 	}); err != nil {
 		return nil, errors.WithMessagef(err, "subscribing update request receiver")
 	}
+	syncRecv := &channelMsgRecv{
+		Receiver: peer.NewReceiver(),
+		peerIdx:  peerIdx,
+		log:      logger,
+	}
+	if err = relay.Subscribe(syncRecv, wire.OfType(wire.ChannelSync)); err != nil {
+		return nil, errors.WithMessagef(err, "subscribing sync receiver")
+	}
 
 	return &channelConn{
 		b:         peer.NewBroadcaster(peers),
 		r:         relay,
 		upReqRecv: upReqRecv,
+		syncRecv:  syncRecv,
 		peerIdx:   peerIdx,
 		log:       logger,
 	}, nil
@@ -94,31 +106,82 @@ func newChannelConn(id channel.ID, peers []*peer.Peer, idx channel.Index) (_ *ch
 // called once before usage of the connection, so it isn't thread-safe.
 func (c *channelConn) SetLogger(l log.Logger) {
 	c.upReqRecv.log = l
+	c.syncRecv.log = l
 	c.log = l
 }
 
-// Close closes the broadcaster and update request receiver.
+// Close closes the broadcaster, update request and sync receiver.
 func (c *channelConn) Close() error {
 	err := c.r.Close()
 	if rerr := c.upReqRecv.Close(); err == nil && rerr != nil {
 		err = rerr
 	}
+	if rerr := c.syncRecv.Close(); err == nil && rerr != nil {
+		err = rerr
+	}
 	return err
 }
 
 // send broadcasts the message to all channel participants.
 func (c *channelConn) Send(ctx context.Context, msg wire.Msg) error {
-	return c.b.Send(ctx, msg)
+	return c.b.SendReliable(ctx, msg)
+}
+
+// peerClosed returns whether any peer of the channel connection is closed.
+func (c *channelConn) peerClosed() bool {
+	for p := range c.peerIdx {
+		if p.IsClosed() {
+			return true
+		}
+	}
+	return false
+}
+
+// withPeers returns a copy of the context that is also canceled when any peer
+// of the channel connection is closed.
+func (c *channelConn) withPeers(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	for p := range c.peerIdx {
+		go func(p *peer.Peer) {
+			select {
+			case <-p.Closed():
+				cancel()
+			case <-ctx.Done():
+			}
+		}(p)
+	}
+	return ctx, cancel
+}
+
+// HasPeer returns whether the peer with the given address is part of this
+// channel connection.
+func (c *channelConn) HasPeer(addr peer.Address) bool {
+	for p := range c.peerIdx {
+		if p.PerunAddress.Equals(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 // NextUpdateReq returns the next channel update request that the channel
 // connection receives.
-func (c *channelConn) NextUpdateReq(ctx context.Context) (channel.Index, *msgChannelUpdate) {
+func (c *channelConn) NextUpdateReq(ctx context.Context) (channel.Index, channelUpdateReqMsg) {
 	idx, m := c.upReqRecv.Next(ctx)
 	if m == nil {
 		return idx, nil // nil conversion doesn't work...
 	}
-	return idx, m.(*msgChannelUpdate) // safe by the predicate
+	return idx, m.(channelUpdateReqMsg) // safe by the predicate
+}
+
+// NextSync returns the next sync message that the channel connection
+// receives.
+func (c *channelConn) NextSync(ctx context.Context) (channel.Index, *msgChannelSync) {
+	idx, m := c.syncRecv.Next(ctx)
+	if m == nil {
+		return idx, nil // nil conversion doesn't work...
+	}
+	return idx, m.(*msgChannelSync) // safe by the predicate
 }
 
 // newUpdateResRecv creates a new update response receiver for the given version.
@@ -136,7 +199,7 @@ func (c *channelConn) NewUpdateResRecv(version uint64) (*channelMsgRecv, error)
 	return &channelMsgRecv{
 		Receiver: recv,
 		peerIdx:  c.peerIdx,
-		log:      c.log.WithField("version", version),
+		log:      c.log.WithField(log.VersionField, version),
 	}, nil
 }
 
@@ -9,43 +9,72 @@ import (
 	"sync"
 
 	"perun.network/go-perun/channel"
+	"perun.network/go-perun/metrics"
 	psync "perun.network/go-perun/pkg/sync"
 )
 
-// ChanRegistry is a registry for channels.
-// You can safely look up channels via their ID and concurrently modify the
-// registry. Always initialize instances of this type with MakeChanRegistry().
-type chanRegistry struct {
-	mutex  sync.RWMutex
-	values map[channel.ID]*Channel
-}
+// numChanShards is the number of shards of a chanRegistry. Channel IDs are
+// hashes, so their first byte distributes the channels evenly over the shards.
+const numChanShards = 64
+
+type (
+	// ChanRegistry is a registry for channels.
+	// You can safely look up channels via their ID and concurrently modify the
+	// registry. Always initialize instances of this type with MakeChanRegistry().
+	//
+	// The channels are distributed over shards with separate locks, so that
+	// operations on different channels rarely contend, even with thousands of
+	// channels.
+	chanRegistry struct {
+		shards []chanShard
+	}
+
+	// chanShard is a shard of a chanRegistry.
+	chanShard struct {
+		mutex  sync.RWMutex
+		values map[channel.ID]*Channel
+	}
+)
 
 // makeChanRegistry creates a new empty channel registry.
 func makeChanRegistry() chanRegistry {
-	return chanRegistry{values: make(map[channel.ID]*Channel)}
+	shards := make([]chanShard, numChanShards)
+	for i := range shards {
+		shards[i].values = make(map[channel.ID]*Channel)
+	}
+	return chanRegistry{shards: shards}
+}
+
+// shard returns the shard of the channel with the given ID.
+func (r *chanRegistry) shard(id channel.ID) *chanShard {
+	return &r.shards[int(id[0])%numChanShards]
 }
 
 // Put puts a new channel into the registry.
 // If an entry with the same ID already existed, this call does nothing and
 // returns false. Otherwise, it adds the new channel into the registry and
-// returns true.
+// returns true. Added channels are counted as open until they are closed.
 func (r *chanRegistry) Put(id channel.ID, value *Channel) bool {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	s := r.shard(id)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if _, ok := r.values[id]; ok {
+	if _, ok := s.values[id]; ok {
 		return false
 	}
-	r.values[id] = value
+	s.values[id] = value
+	metrics.ChannelOpened()
+	value.OnCloseAlways(metrics.ChannelClosed)
 	return true
 }
 
 // Has checks whether a channel with the requested ID is registered.
 func (r *chanRegistry) Has(id channel.ID) bool {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	s := r.shard(id)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	_, ok := r.values[id]
+	_, ok := s.values[id]
 	return ok
 }
 
@@ -53,10 +82,11 @@ func (r *chanRegistry) Has(id channel.ID) bool {
 // If the channel exists, returns the channel, and true. Otherwise, returns nil,
 // false.
 func (r *chanRegistry) Get(id channel.ID) (*Channel, bool) {
-	r.mutex.RLock()
-	defer r.mutex.RUnlock()
+	s := r.shard(id)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	v, ok := r.values[id]
+	v, ok := s.values[id]
 	return v, ok
 }
 
@@ -64,20 +94,46 @@ func (r *chanRegistry) Get(id channel.ID) (*Channel, bool) {
 // If the channel did not exist, does nothing. Returns whether the channel
 // existed.
 func (r *chanRegistry) Delete(id channel.ID) (deleted bool) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+	s := r.shard(id)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if _, deleted = r.values[id]; deleted {
-		delete(r.values, id)
+	if _, deleted = s.values[id]; deleted {
+		delete(s.values, id)
 	}
 	return
 }
 
-func (r *chanRegistry) CloseAll() (err error) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// Len returns the number of registered channels.
+func (r *chanRegistry) Len() (n int) {
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mutex.RLock()
+		n += len(s.values)
+		s.mutex.RUnlock()
+	}
+	return n
+}
+
+// Snapshot returns all registered channels. The registry is locked shard by
+// shard, so channels that are added or deleted concurrently may or may not be
+// contained, but the registry can be modified while the snapshot is iterated.
+func (r *chanRegistry) Snapshot() []*Channel {
+	chans := make([]*Channel, 0, r.Len())
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mutex.RLock()
+		for _, ch := range s.values {
+			chans = append(chans, ch)
+		}
+		s.mutex.RUnlock()
+	}
+	return chans
+}
 
-	for _, c := range r.values {
+// CloseAll closes all registered channels and returns the first error.
+func (r *chanRegistry) CloseAll() (err error) {
+	for _, c := range r.Snapshot() {
 		if cerr := c.Close(); err == nil && !psync.IsAlreadyClosedError(cerr) {
 			err = cerr
 		}
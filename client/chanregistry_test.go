@@ -7,11 +7,13 @@ package client
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"perun.network/go-perun/channel"
 	"perun.network/go-perun/channel/test"
 )
 
@@ -112,3 +114,34 @@ func TestChanRegistry_CloseAll(t *testing.T) {
 	reg.CloseAll()
 	assert.True(t, ch.IsClosed())
 }
+
+func TestChanRegistry_Snapshot(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xDDDDdede))
+	r := makeChanRegistry()
+	assert.Zero(t, r.Len())
+	assert.Empty(t, r.Snapshot())
+
+	const n = 200
+	ids := make([]channel.ID, n)
+	var wg sync.WaitGroup
+	for i := range ids {
+		ids[i] = test.NewRandomChannelID(rng)
+		wg.Add(1)
+		go func(id channel.ID) {
+			defer wg.Done()
+			assert.True(t, r.Put(id, &Channel{}))
+			r.Snapshot()
+		}(ids[i])
+	}
+	wg.Wait()
+	assert.Equal(t, n, r.Len())
+	assert.Len(t, r.Snapshot(), n)
+
+	for _, id := range ids[:n/2] {
+		require.True(t, r.Delete(id))
+	}
+	assert.Equal(t, n/2, r.Len())
+	for _, id := range ids[n/2:] {
+		assert.True(t, r.Has(id))
+	}
+}
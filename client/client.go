@@ -7,10 +7,12 @@ package client // import "perun.network/go-perun/client"
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/peer"
 	"perun.network/go-perun/pkg/sync"
@@ -18,18 +20,34 @@ import (
 	wire "perun.network/go-perun/wire/msg"
 )
 
+// capabilities are the optional wire protocol features that the client
+// supports.
+const capabilities = wire.CapProposalAbort | wire.CapMultiParty
+
 // Client is a state channel client. It is the central controller to interact
 // with a state channel network. It can be used to propose channels to other
 // channel network peers.
 //
-// Currently, only the two-party protocol is fully implemented.
+// Channels of more than two participants can be proposed and funded, but
+// only the two-party update protocol is implemented.
+//
+// A Client can serve multiple network identities, see AddIdentity.
 type Client struct {
 	id          peer.Identity
-	peers       *peer.Registry
+	peers       *peer.Registry // registry of the primary identity id
+	idents      identities
 	channels    chanRegistry
 	propHandler ProposalHandler
 	funder      channel.Funder
 	adjudicator channel.Adjudicator
+	vFunding    *virtualFundingMatcher
+	events      *eventBus
+	propLocks   peerLocks       // serializes outgoing proposals per peer
+	limiter     proposalLimiter // limits incoming proposals
+	mpTimeout   int64           // multi-party timeout in nanoseconds, accessed atomically
+	shutdown    shutdown        // tracks listeners and proposals for Shutdown
+	earlyMsgs   context.Context // done when the early messages are dropped
+	pr          persistence.PersistRestorer
 	log         log.Logger // structured logger for this client
 
 	sync.Closer
@@ -49,6 +67,10 @@ type Client struct {
 //
 // funder and settler are used to fund and settle a ledger channel, respectively.
 //
+// The wire format of the peer connections, e.g., msg.ProtoSerializer or
+// msg.FramedSerializer, can be selected with msg.SetSerializer before calling
+// New.
+//
 // If any argument is nil, New panics.
 func New(
 	id peer.Identity,
@@ -78,15 +100,26 @@ func New(
 		propHandler: propHandler,
 		funder:      funder,
 		adjudicator: adjudicator,
-		log:         log.WithField("id", id.Address()),
+		log:         log.WithField(log.IDField, id.Address()),
 		channels:    makeChanRegistry(),
+		vFunding:    newVirtualFundingMatcher(),
+		events:      newEventBus(),
+		propLocks:   makePeerLocks(),
+		mpTimeout:   int64(defaultMultiPartyTimeout),
+		pr:          persistence.NonPersistRestorer,
 	}
-	c.peers = peer.NewRegistry(id, c.subscribePeer, dialer)
+	var cancel context.CancelFunc
+	c.earlyMsgs, cancel = context.WithCancel(context.Background())
+	c.peers = peer.NewRegistry(id, func(p *peer.Peer) { c.subscribePeer(c.primary(), p) }, dialer)
+	c.peers.SetCapabilities(capabilities)
+	c.OnCloseAlways(c.events.close)
+	c.OnCloseAlways(cancel)
 	return c
 }
 
 // Close closes this state channel client.
-// It also closes the peer registry.
+// It also closes the peer registry. Running protocols are aborted, see
+// Shutdown for a graceful shutdown.
 func (c *Client) Close() error {
 	if err := c.Closer.Close(); err != nil {
 		return err
@@ -96,9 +129,71 @@ func (c *Client) Close() error {
 	if cerr := c.peers.Close(); err == nil {
 		err = errors.WithMessage(cerr, "closing registry")
 	}
+	if cerr := c.idents.close(); err == nil {
+		err = cerr
+	}
+	if cerr := c.pr.Close(); err == nil {
+		err = errors.WithMessage(cerr, "closing persister")
+	}
 	return err
 }
 
+// EnablePersistence sets the PersistRestorer that the client is going to use
+// for channel persistence. All channel state changes are persisted from then
+// on and the channels can be restored with Restore after a restart. It must be
+// called before any channel is opened, as the client doesn't retroactively
+// persist already existing channels.
+func (c *Client) EnablePersistence(pr persistence.PersistRestorer) {
+	if pr == nil {
+		c.log.Panic("PersistRestorer must not be nil")
+	}
+	c.pr = pr
+}
+
+// EnableKeepAlive enables the keepalive of new peer connections. Peers are
+// pinged every interval and are disconnected as unreachable if they miss
+// maxMissed pongs in a row. Updates that wait for the response of a
+// disconnected peer fail immediately. It should be called before any peer is
+// connected.
+//
+// If interval or maxMissed is not positive, EnableKeepAlive panics.
+func (c *Client) EnableKeepAlive(interval time.Duration, maxMissed int) {
+	if interval <= 0 {
+		c.log.Panic("keepalive interval must be positive")
+	}
+	c.peers.SetKeepAlive(interval, maxMissed)
+	c.idents.setKeepAlive(interval, maxMissed)
+}
+
+// SetTracer sets the tracer that records all messages that are sent to or
+// received from peers of all identities, e.g., a peer.JSONTranscript for
+// protocol debugging. It should be set before the client connects to peers,
+// since only the messages of new peers are traced.
+func (c *Client) SetTracer(t peer.Tracer) {
+	c.peers.SetTracer(t)
+	c.idents.setTracer(t)
+}
+
+// SetOutbox sets the peer.Outbox of the client's identity with address ident,
+// so that channel messages to its peers are retransmitted until they are
+// acknowledged, also after a restart. Retransmitted messages that were already
+// received are dropped by peers that also use an outbox. An outbox must only be
+// used by a single identity. It should be set before the identity connects to
+// peers.
+//
+// If o is nil, SetOutbox panics.
+func (c *Client) SetOutbox(ident wallet.Address, o *peer.Outbox) error {
+	if o == nil {
+		c.log.Panic("outbox must not be nil")
+	}
+	id := c.identity(ident)
+	if id == nil {
+		return errors.Errorf("unknown identity %v", ident)
+	}
+	id.peers.SetOutbox(o)
+	return nil
+}
+
 // Channel queries a channel by its ID.
 func (c *Client) Channel(id channel.ID) (*Channel, error) {
 	if ch, ok := c.channels.Get(id); ok {
@@ -107,24 +202,47 @@ func (c *Client) Channel(id channel.ID) (*Channel, error) {
 	return nil, errors.New("unknown channel ID")
 }
 
+// Channels returns a snapshot of all channels of the client, including sub-
+// and virtual channels, e.g., to watch them all with Watcher.WatchAll. Channels
+// that are opened or closed concurrently may or may not be contained.
+func (c *Client) Channels() []*Channel {
+	return c.channels.Snapshot()
+}
+
 // Listen starts listening for incoming connections on the provided listener and
 // currently just automatically accepts them after successful authentication.
 // This function does not start go routines but instead should
 // be started by the user as `go client.Listen()`. The client takes ownership of
-// the listener and will close it when the client is closed.
+// the listener and will close it when the client is closed or shut down.
 func (c *Client) Listen(listener peer.Listener) {
+	c.listen(c.peers, listener)
+}
+
+// listen lets the registry listen for incoming connections on the listener.
+func (c *Client) listen(peers *peer.Registry, listener peer.Listener) {
 	if listener == nil {
 		c.log.Panic("listener must not be nil")
 	}
+	if !c.shutdown.addListener(listener) {
+		listener.Close()
+		return
+	}
 
-	c.peers.Listen(listener)
+	peers.Listen(listener)
 }
 
-func (c *Client) subscribePeer(p *peer.Peer) {
+// subscribePeer sets up the subscriptions of the new peer p of our identity
+// ident.
+func (c *Client) subscribePeer(ident *identity, p *peer.Peer) {
 	c.logPeer(p).Debugf("setting up default subscriptions")
 
 	// handle incoming channel proposals
-	c.subChannelProposals(p)
+	c.subChannelProposals(ident, p)
+	// cache messages that arrive before their channel or proposal is set up
+	c.cacheEarlyMsgs(p)
+
+	addr := p.PerunAddress
+	p.OnCloseAlways(func() { c.events.emit(PeerDisconnected{Peer: addr, Unreachable: p.Unreachable()}) })
 
 	log := c.logPeer(p)
 	p.SetDefaultMsgHandler(func(m wire.Msg) {
@@ -138,20 +256,20 @@ func (c *Client) Log() log.Logger {
 }
 
 func (c *Client) logPeer(p *peer.Peer) log.Logger {
-	return c.log.WithField("peer", p.PerunAddress)
+	return c.log.WithField(log.PeerField, p.PerunAddress)
 }
 
 func (c *Client) logChan(id channel.ID) log.Logger {
-	return c.log.WithField("channel", id)
+	return c.log.WithField(log.ChannelField, id)
 }
 
-// getPeers gets all peers from the registry for the provided addresses,
-// skipping the own peer, if present in the list.
+// getPeers gets all peers for the provided addresses from the registry of our
+// identity in the list, skipping our own peer, if present in the list.
 func (c *Client) getPeers(
 	ctx context.Context,
 	addrs []peer.Address,
 ) (peers []*peer.Peer, err error) {
-	idx := wallet.IndexOfAddr(addrs, c.id.Address())
+	ident, idx := c.ourIdentity(addrs)
 	l := len(addrs)
 	if idx != -1 {
 		l--
@@ -160,9 +278,9 @@ func (c *Client) getPeers(
 	peers = make([]*peer.Peer, l)
 	for i, a := range addrs {
 		if idx == -1 || i < idx {
-			peers[i], err = c.peers.Get(ctx, a)
+			peers[i], err = ident.peers.Get(ctx, a)
 		} else if i > idx {
-			peers[i-1], err = c.peers.Get(ctx, a)
+			peers[i-1], err = ident.peers.Get(ctx, a)
 		}
 		if err != nil {
 			return
@@ -207,14 +207,16 @@ func TestClient_NewAndListen(t *testing.T) {
 		defer cancel()
 		conn, err := dialer.Dial(ctx, c.id.Address())
 		ass.NoError(err, "Dialing the Client instance failed")
-		ass.NoError(conn.Send(peer.NewAuthResponseMsg(peerID)))
+		authMsg, err := peer.NewAuthResponseMsg(peerID)
+		ass.NoError(err)
+		ass.NoError(conn.Send(authMsg))
 
 		msg, err := conn.Recv()
 		ass.NoError(err)
 		ass.Equal(wire.AuthResponse, msg.Type())
-		authMsg, ok := msg.(*peer.AuthResponseMsg)
+		authResp, ok := msg.(*peer.AuthResponseMsg)
 		ass.True(ok, "Have a message with type AuthResponse but cast failed")
-		ass.Equal(c.id.Address(), authMsg.Address)
+		ass.Equal(c.id.Address(), authResp.Address)
 
 		ass.NoError(dialer.Close())
 	}()
@@ -0,0 +1,46 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+// EstimateDisputeCosts estimates the on-chain costs of disputing the channel
+// with its current state and returns them together with the balance of the
+// on-chain account that pays them. The channel's adjudicator must be a
+// channel.CostEstimator.
+func (c *Channel) EstimateDisputeCosts(ctx context.Context) (*channel.DisputeCosts, error) {
+	est, ok := c.adjudicator.(channel.CostEstimator)
+	if !ok {
+		return nil, errors.New("adjudicator cannot estimate dispute costs")
+	}
+	return est.EstimateDisputeCosts(ctx, c.adjudicatorReq())
+}
+
+// preflightDisputeCosts checks whether our on-chain account can afford a
+// dispute of the new ledger channel before it is funded. If not, or if the
+// estimation fails, a warning is logged and emitted as DisputeCostsWarning.
+// The channel setup continues in either case. Channels whose adjudicator is no
+// channel.CostEstimator are not checked.
+func (c *Client) preflightDisputeCosts(ctx context.Context, ch *Channel) {
+	if _, ok := c.adjudicator.(channel.CostEstimator); !ok {
+		return
+	}
+	costs, err := ch.EstimateDisputeCosts(ctx)
+	if err != nil {
+		ch.log.Warnf("Estimating dispute costs: %v", err)
+	} else if !costs.Sufficient() {
+		ch.log.Warnf("Balance %v is insufficient for a dispute, requires %v", costs.Balance, costs.Required)
+	} else {
+		return
+	}
+	c.events.emit(DisputeCostsWarning{ID: ch.ID(), Costs: costs, Err: err})
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// costAdjudicator is a logAdjudicator that estimates fixed dispute costs.
+type costAdjudicator struct {
+	logAdjudicator
+	costs channel.DisputeCosts
+}
+
+func (a *costAdjudicator) EstimateDisputeCosts(context.Context, channel.AdjudicatorReq) (*channel.DisputeCosts, error) {
+	costs := a.costs
+	return &costs, nil
+}
+
+func TestClient_DisputeCosts(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xc05))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+
+	adj := &costAdjudicator{
+		logAdjudicator: logAdjudicator{log.WithField("role", "Alice")},
+		costs: channel.DisputeCosts{
+			Register: big.NewInt(10), Progress: big.NewInt(10), Withdraw: big.NewInt(20),
+			Required: big.NewInt(40), Balance: big.NewInt(39),
+		},
+	}
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t}, &logFunder{log.WithField("role", "Alice")}, adj)
+	defer alice.Close()
+	events := make(chan client.Event, 10)
+	alice.OnEvent(func(e client.Event) { events <- e })
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	ch, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	bobCh := <-bobHandler.chans
+
+	// The warning is emitted before the channel is funded and opened.
+	e := <-events
+	require.IsType(t, client.DisputeCostsWarning{}, e)
+	w := e.(client.DisputeCostsWarning)
+	assert.Equal(t, ch.ID(), w.ID)
+	assert.NoError(t, w.Err)
+	assert.False(t, w.Costs.Sufficient())
+	assert.IsType(t, client.ChannelOpened{}, <-events)
+
+	costs, err := ch.EstimateDisputeCosts(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, adj.costs, *costs)
+	_, err = bobCh.EstimateDisputeCosts(ctx)
+	assert.Error(t, err, "adjudicator is no CostEstimator")
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"time"
+
+	"perun.network/go-perun/peer"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+// earlyMsgTTL is the time that early messages are cached, see cacheEarlyMsgs.
+var earlyMsgTTL = 10 * time.Second
+
+// cacheEarlyMsgs enables the caching of protocol messages of peer p that
+// arrive before we set up the channel or proposal that they belong to, e.g.,
+// the initial signature of a peer that is faster than us in setting up a new
+// channel. They are replayed when the channel or proposal subscribes to them.
+// Early messages are dropped after earlyMsgTTL, when the client is closed, or
+// when there are already wire.MaxExpiringMsgs early messages of p.
+func (c *Client) cacheEarlyMsgs(p *peer.Peer) {
+	p.CacheFor(c.earlyMsgs, c.isEarlyMsg, earlyMsgTTL)
+}
+
+// isEarlyMsg returns whether the message, which was not handled by any
+// subscription, can belong to a channel or proposal that is not set up yet.
+func (c *Client) isEarlyMsg(m wire.Msg) bool {
+	switch m := m.(type) {
+	case ChannelMsg:
+		// messages of known channels without subscription are outdated
+		return !c.channels.Has(m.ID())
+	case *ChannelProposalAcc, *ChannelProposalRej, *ChannelProposalAbort, *ChannelProposalParts:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	channeltest "perun.network/go-perun/channel/test"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+func TestClient_isEarlyMsg(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xea21))
+	c := &Client{channels: makeChanRegistry()}
+
+	id := channeltest.NewRandomChannelID(rng)
+	acc := &msgChannelUpdateAcc{ChannelID: id}
+	assert.True(t, c.isEarlyMsg(acc), "message of unknown channel")
+	c.channels.Put(id, &Channel{})
+	assert.False(t, c.isEarlyMsg(acc), "message of known channel")
+
+	assert.True(t, c.isEarlyMsg(&ChannelProposalAcc{}))
+	assert.True(t, c.isEarlyMsg(&ChannelProposalParts{}))
+	assert.False(t, c.isEarlyMsg(&ChannelProposalReq{}), "proposals are always subscribed")
+	assert.False(t, c.isEarlyMsg(wire.NewPingMsg()))
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// A PeerRejectedError is returned if a peer rejected a proposal or an
+	// update. ItemType is the kind of the rejected request, e.g. "update".
+	PeerRejectedError struct {
+		ItemType string
+		Reason   string
+	}
+
+	// A RequestTimedOutError is returned if the response of a peer did not
+	// arrive before the context of the request was done. Request is the kind
+	// of the request, e.g. "update response".
+	RequestTimedOutError struct {
+		Request string
+	}
+)
+
+// The errors can be inspected with errors.As, or with IsPeerRejectedError and
+// IsRequestTimedOutError. Funding timeouts are channel.FundingTimeoutErrors and
+// challenge timeouts channel.ChallengeTimeoutErrors. Errors of other types are
+// runtime errors, e.g., of the chain backend.
+
+func (e *PeerRejectedError) Error() string {
+	return fmt.Sprintf("%s rejected by peer: %s", e.ItemType, e.Reason)
+}
+
+func newPeerRejectedError(itemType, reason string) error {
+	return errors.WithStack(&PeerRejectedError{ItemType: itemType, Reason: reason})
+}
+
+// IsPeerRejectedError returns true if the error was a PeerRejectedError.
+func IsPeerRejectedError(err error) bool {
+	_, ok := errors.Cause(err).(*PeerRejectedError)
+	return ok
+}
+
+func (e *RequestTimedOutError) Error() string {
+	return fmt.Sprintf("timeout when waiting for %s", e.Request)
+}
+
+func newRequestTimedOutError(request string) error {
+	return errors.WithStack(&RequestTimedOutError{Request: request})
+}
+
+// IsRequestTimedOutError returns true if the error was a RequestTimedOutError.
+func IsRequestTimedOutError(err error) bool {
+	_, ok := errors.Cause(err).(*RequestTimedOutError)
+	return ok
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"sync"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+)
+
+// maxQueuedEvents is the number of events that are queued for delivery. If the
+// handlers fall further behind, new events are dropped, see Client.OnEvent.
+const maxQueuedEvents = 1024
+
+type (
+	// An Event is a lifecycle event of the channels or peers of a Client. It is
+	// one of DisputeCostsWarning, FundingProgressed, ChannelOpened,
+	// UpdateReceived, DisputeRegistered, ChannelClosed and PeerDisconnected.
+	Event interface {
+		event()
+	}
+
+	// DisputeCostsWarning is emitted before a new ledger channel is funded if
+	// the adjudicator is a channel.CostEstimator and our on-chain account
+	// cannot afford a dispute of the channel. Costs is nil if the estimation
+	// failed with Err.
+	DisputeCostsWarning struct {
+		ID    channel.ID
+		Costs *channel.DisputeCosts
+		Err   error
+	}
+
+	// FundingProgressed is emitted while a new ledger channel is funded,
+	// whenever the funder reports that a participant completed its deposit of
+	// an asset. PartsFunded is the number of participants that completed the
+	// deposits of all assets, out of NumParts.
+	FundingProgressed struct {
+		ID          channel.ID
+		Progress    channel.FundingProgress
+		PartsFunded int
+		NumParts    int
+	}
+
+	// ChannelOpened is emitted when a new channel is funded and ready to use.
+	ChannelOpened struct {
+		Channel *Channel
+	}
+
+	// UpdateReceived is emitted when we accepted an update of a peer. State is
+	// the new state and must not be modified.
+	UpdateReceived struct {
+		Channel *Channel
+		State   *channel.State
+	}
+
+	// DisputeRegistered is emitted when we registered a state of the channel
+	// on-chain. Registrations by peers are reported by a Watcher, see
+	// WatchHandler.
+	DisputeRegistered struct {
+		Channel    *Channel
+		Registered *channel.Registered
+	}
+
+	// ChannelClosed is emitted when a channel is closed.
+	ChannelClosed struct {
+		ID channel.ID
+	}
+
+	// PeerDisconnected is emitted when the connection to a peer is closed.
+	PeerDisconnected struct {
+		Peer peer.Address
+		// Unreachable is set if the peer was closed because it did not answer
+		// the pings of the keepalive, see Client.EnableKeepAlive.
+		Unreachable bool
+	}
+
+	// An EventHandler is called for every Event of a Client.
+	EventHandler func(Event)
+
+	// eventBus delivers events to all handlers from a single go routine, in the
+	// order in which they were emitted. Emitting never blocks, so that events
+	// can be emitted while holding locks. At most maxQueuedEvents events are
+	// queued, further events are dropped.
+	eventBus struct {
+		mtx      sync.Mutex
+		handlers []EventHandler
+		queue    []Event
+		dropped  int // number of events dropped since the last delivery
+		closed   bool
+		notify   chan struct{}
+	}
+)
+
+func (DisputeCostsWarning) event() {}
+func (FundingProgressed) event()   {}
+func (ChannelOpened) event()       {}
+func (UpdateReceived) event()      {}
+func (DisputeRegistered) event()   {}
+func (ChannelClosed) event()       {}
+func (PeerDisconnected) event()    {}
+
+// OnEvent registers a handler that is called for every lifecycle event of the
+// Client's channels and peers. All handlers are called from a single go
+// routine, in the order of the events, so they should not block for too long:
+// If the handlers fall behind by more than 1024 events, new events are dropped
+// until the handlers catch up, and a warning is logged with the number of
+// dropped events. Handlers cannot be removed. Events that occur before the
+// first handler is registered are dropped.
+//
+// If the handler is nil, OnEvent panics.
+func (c *Client) OnEvent(handler EventHandler) {
+	if handler == nil {
+		c.log.Panic("event handler must not be nil")
+	}
+	c.events.subscribe(handler)
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{notify: make(chan struct{}, 1)}
+}
+
+// subscribe adds the handler. The delivery go routine is started with the
+// first handler, so that a Client without handlers doesn't run it.
+func (b *eventBus) subscribe(h EventHandler) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if len(b.handlers) == 0 && !b.closed {
+		go b.deliver()
+	}
+	b.handlers = append(b.handlers, h)
+}
+
+// emit queues the event for delivery. It is a no-op on a nil bus, so that
+// channels that are not set up by a Client don't need one.
+func (b *eventBus) emit(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.closed || len(b.handlers) == 0 {
+		return
+	}
+	if len(b.queue) >= maxQueuedEvents {
+		b.dropped++
+		return
+	}
+	b.queue = append(b.queue, e)
+	select {
+	case b.notify <- struct{}{}:
+	default:
+	}
+}
+
+// deliver delivers all queued events until the bus is closed.
+func (b *eventBus) deliver() {
+	for range b.notify {
+		b.mtx.Lock()
+		queue, handlers, dropped := b.queue, b.handlers, b.dropped
+		b.queue, b.dropped = nil, 0
+		b.mtx.Unlock()
+
+		if dropped > 0 {
+			log.Warnf("dropped %d events, since the event handlers are too slow", dropped)
+		}
+		for _, e := range queue {
+			for _, h := range handlers {
+				h(e)
+			}
+		}
+	}
+}
+
+// close stops the delivery of events. Queued events may be dropped.
+func (b *eventBus) close() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.notify)
+	}
+}
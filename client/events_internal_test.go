@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/pkg/test"
+)
+
+func TestEventBus_Bounded(t *testing.T) {
+	b := newEventBus()
+	defer b.close()
+
+	// The handler blocks on the first event until release is closed.
+	var delivered int64
+	started, release := make(chan struct{}), make(chan struct{})
+	b.subscribe(func(Event) {
+		if atomic.AddInt64(&delivered, 1) == 1 {
+			close(started)
+			<-release
+		}
+	})
+	b.emit(ChannelClosed{})
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("first event not delivered")
+	}
+
+	// Events beyond the bound are dropped while the handler is blocked.
+	for i := 0; i < maxQueuedEvents+5; i++ {
+		b.emit(ChannelClosed{})
+	}
+	b.mtx.Lock()
+	require.Len(t, b.queue, maxQueuedEvents)
+	assert.Equal(t, 5, b.dropped)
+	b.mtx.Unlock()
+
+	close(release)
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.Equal(t, int64(1+maxQueuedEvents), atomic.LoadInt64(&delivered))
+	})
+
+	// Once the handler caught up, new events are delivered again.
+	b.emit(ChannelClosed{})
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.Equal(t, int64(2+maxQueuedEvents), atomic.LoadInt64(&delivered))
+	})
+}
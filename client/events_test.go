@@ -0,0 +1,87 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_OnEvent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xe7e7))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	aliceEvents, bobEvents := make(chan client.Event, 10), make(chan client.Event, 10)
+	alice.OnEvent(func(e client.Event) { aliceEvents <- e })
+	bob.OnEvent(func(e client.Event) { bobEvents <- e })
+	assert.Panics(t, func() { alice.OnEvent(nil) })
+
+	next := func(events chan client.Event) client.Event {
+		select {
+		case e := <-events:
+			return e
+		case <-ctx.Done():
+			t.Fatal("expected event")
+			return nil
+		}
+	}
+
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	aliceCh, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	bobCh := <-bobHandler.chans
+	assert.Equal(t, client.ChannelOpened{Channel: aliceCh}, next(aliceEvents))
+	assert.Equal(t, client.ChannelOpened{Channel: bobCh}, next(bobEvents))
+
+	require.NoError(t, aliceCh.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+		return nil
+	}))
+	up, ok := next(bobEvents).(client.UpdateReceived)
+	require.True(t, ok)
+	assert.Same(t, bobCh, up.Channel)
+	assert.Equal(t, uint64(1), up.State.Version)
+
+	require.NoError(t, aliceCh.Settle(ctx))
+	reg, ok := next(aliceEvents).(client.DisputeRegistered)
+	require.True(t, ok)
+	assert.Same(t, aliceCh, reg.Channel)
+	assert.Equal(t, aliceCh.State().Version, reg.Registered.Version)
+	_, ok = next(bobEvents).(client.UpdateReceived)
+	assert.True(t, ok, "final update")
+
+	require.NoError(t, aliceCh.Close())
+	assert.Equal(t, client.ChannelClosed{ID: aliceCh.ID()}, next(aliceEvents))
+
+	require.NoError(t, bob.Close())
+	disc, ok := next(aliceEvents).(client.PeerDisconnected)
+	require.True(t, ok)
+	assert.True(t, disc.Peer.Equals(bobID.Address()))
+}
@@ -0,0 +1,14 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import "perun.network/go-perun/peer"
+
+// HasPeer returns whether the Client's peer registry contains the peer with the
+// given address. It lets the external tests wait for incoming connections.
+func (c *Client) HasPeer(addr peer.Address) bool {
+	return c.peers.Has(addr)
+}
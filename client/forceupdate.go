@@ -0,0 +1,120 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+)
+
+// forcedState is the latest state that we progressed on-chain and the timeout
+// of its progression.
+type forcedState struct {
+	tx      channel.Transaction
+	timeout time.Time
+}
+
+// ForceUpdate progresses the channel on-chain by a valid transition of the
+// channel's app. It can be used if the peers stall, so that the channel cannot
+// be updated off-chain any more. The channel's app must be a StateApp that was
+// not upgraded, see UpgradeApp, and the channel's adjudicator a
+// channel.ProgressingAdjudicator.
+//
+// update is called on a copy of the current state, whose version is already
+// increased, and should apply our move. On the first call, the current state
+// is registered and ForceUpdate waits until the challenge duration passed.
+// Subsequent calls progress the last forced state further.
+//
+// Once a channel was force-updated, it cannot be updated off-chain any more,
+// but only be settled with Settle after the timeout of the last progression.
+// The forced states are not persisted.
+func (c *Channel) ForceUpdate(ctx context.Context, update func(*channel.State) error) error {
+	adj, ok := c.adjudicator.(channel.ProgressingAdjudicator)
+	if !ok {
+		return errors.New("adjudicator does not support progression")
+	}
+	app, ok := c.Params().App.(channel.StateApp)
+	if !ok {
+		return errors.New("only channels with a StateApp can be force-updated")
+	}
+	if c.parent != nil {
+		return errors.New("channels funded by a parent cannot be force-updated")
+	}
+	if channel.IsAppUpgraded(c.Params(), c.State()) {
+		return errors.New("channels with upgraded app cannot be force-updated")
+	}
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if c.machine.Phase() != channel.Acting {
+		return errors.Errorf("cannot force-update channel in phase %v", c.machine.Phase())
+	}
+
+	req := c.machine.AdjudicatorReq()
+	if c.forced == nil {
+		reg, err := c.register(ctx, req)
+		if err != nil {
+			return err
+		}
+		c.logPhase().Infof("Registered state for force-execution. Waiting until %v", reg.Timeout)
+		if err := waitUntil(ctx, reg.Timeout); err != nil {
+			return err
+		}
+	} else {
+		req.Tx = c.forced.tx
+	}
+
+	state := req.Tx.State.Clone()
+	state.Version++
+	if err := update(state); err != nil {
+		return err
+	}
+	if state.IsFinal {
+		return errors.New("forced state must not be final")
+	}
+	if err := app.ValidTransition(req.Params, req.Tx.State, state, req.Idx); err != nil {
+		return errors.WithMessage(err, "invalid forced transition")
+	}
+
+	sig, err := channel.Sign(req.Acc, req.Params, state)
+	if err != nil {
+		return errors.WithMessage(err, "signing forced state")
+	}
+	reg, err := adj.Progress(ctx, channel.ProgressReq{AdjudicatorReq: req, NewState: state, Sig: sig})
+	if err != nil {
+		return errors.WithMessage(err, "calling Progress")
+	}
+	if reg.Version != state.Version {
+		return errors.Errorf("invalid version progressed want %v, got %v", state.Version, reg.Version)
+	}
+
+	sigs := make([]wallet.Sig, len(req.Params.Parts))
+	sigs[req.Idx] = sig
+	c.forced = &forcedState{
+		tx:      channel.Transaction{State: state, Sigs: sigs},
+		timeout: reg.Timeout,
+	}
+	return nil
+}
+
+// settleForced withdraws the last forced state once its progression timed
+// out.
+// The machine must be locked by the caller.
+func (c *Channel) settleForced(ctx context.Context) error {
+	c.logPhase().Infof("Settling forced state. Waiting until %v", c.forced.timeout)
+	if err := waitUntil(ctx, c.forced.timeout); err != nil {
+		return err
+	}
+	req := c.machine.AdjudicatorReq()
+	req.Tx = c.forced.tx
+	return c.withdraw(ctx, req)
+}
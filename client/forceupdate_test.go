@@ -0,0 +1,112 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_ForceUpdate(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0ce))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	adj := &progressAdjudicator{
+		settleAdjudicator: newSettleAdjudicator(),
+		progressed:        make(chan channel.ProgressReq, 2),
+	}
+	alice, bob, ch, _ := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, adj)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+	pay(ctx, t, ch, 10)
+
+	// Alice can only decrease her own balance in the payment app.
+	assert.Error(t, ch.ForceUpdate(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Add(state.OfParts[0][0], big.NewInt(5))
+		state.OfParts[1][0].Sub(state.OfParts[1][0], big.NewInt(5))
+		return nil
+	}), "invalid transition")
+	reg := <-adj.registered
+	assert.Equal(t, uint64(1), reg.Tx.Version)
+
+	forcePay := func(amount int64) error {
+		return ch.ForceUpdate(ctx, func(state *channel.State) error {
+			state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(amount))
+			state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(amount))
+			return nil
+		})
+	}
+	require.NoError(t, forcePay(5))
+	<-adj.registered
+	require.NoError(t, forcePay(5))
+	// The second progression starts from the forced state.
+	for v := uint64(2); v <= 3; v++ {
+		req := <-adj.progressed
+		assert.Equal(t, v, req.NewState.Version)
+		assert.Equal(t, v-1, req.Tx.Version)
+	}
+	assertLedgerBals(t, ch.State(), 90, 110, 0)
+
+	assert.Error(t, ch.UpdateBy(ctx, func(*channel.State) error { return nil }),
+		"off-chain update after force-update")
+
+	require.NoError(t, ch.Settle(ctx))
+	assert.Equal(t, channel.Settled, ch.Phase())
+	wd := <-adj.withdrawn
+	assert.Equal(t, uint64(3), wd.Tx.Version)
+	assertLedgerBals(t, wd.Tx.State, 80, 120, 0)
+}
+
+func TestChannel_ForceUpdate_NoProgression(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0cf))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice, bob, ch, _ := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+
+	assert.Error(t, ch.ForceUpdate(ctx, func(*channel.State) error { return nil }))
+	assert.Equal(t, channel.Acting, ch.Phase())
+}
+
+// progressAdjudicator is a settleAdjudicator that additionally reports
+// Progress calls. Progressed states time out after a short duration.
+type progressAdjudicator struct {
+	*settleAdjudicator
+	progressed chan channel.ProgressReq
+}
+
+func (a *progressAdjudicator) Progress(_ context.Context, req channel.ProgressReq) (*channel.Registered, error) {
+	a.progressed <- req
+	return &channel.Registered{
+		ID:         req.Params.ID(),
+		Idx:        req.Idx,
+		Version:    req.NewState.Version,
+		Timeout:    time.Now().Add(100 * time.Millisecond),
+		Progressed: true,
+	}, nil
+}
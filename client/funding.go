@@ -0,0 +1,71 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"sync"
+
+	"perun.network/go-perun/channel"
+)
+
+// fundingTracker turns the progress reports of the funder into
+// FundingProgressed events of a channel.
+type fundingTracker struct {
+	mtx    sync.Mutex
+	id     channel.ID
+	funded [][]bool // indexed by asset and participant
+	events *eventBus
+}
+
+// newFundingReq creates the funding request of the channel ch. The progress
+// of the funding is reported as FundingProgressed events.
+func (c *Client) newFundingReq(ch *Channel, alloc *channel.Allocation, agreement [][]channel.Bal) channel.FundingReq {
+	t := &fundingTracker{
+		id:     ch.ID(),
+		funded: make([][]bool, len(alloc.Assets)),
+		events: c.events,
+	}
+	for a := range t.funded {
+		t.funded[a] = make([]bool, len(ch.Params().Parts))
+	}
+	return channel.FundingReq{
+		Params:     ch.Params(),
+		Allocation: alloc,
+		Idx:        ch.machine.Idx(),
+		Agreement:  agreement,
+		Progress:   t.report,
+	}
+}
+
+// report records the progress and emits a FundingProgressed event. Duplicate
+// and out of range reports are ignored.
+func (t *fundingTracker) report(p channel.FundingProgress) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if p.Asset < 0 || p.Asset >= len(t.funded) || int(p.Idx) >= len(t.funded[p.Asset]) ||
+		t.funded[p.Asset][p.Idx] {
+		return
+	}
+	t.funded[p.Asset][p.Idx] = true
+
+	numParts := len(t.funded[p.Asset])
+	partsFunded := 0
+	for i := 0; i < numParts; i++ {
+		complete := true
+		for a := range t.funded {
+			complete = complete && t.funded[a][i]
+		}
+		if complete {
+			partsFunded++
+		}
+	}
+	t.events.emit(FundingProgressed{
+		ID:          t.id,
+		Progress:    p,
+		PartsFunded: partsFunded,
+		NumParts:    numParts,
+	})
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+)
+
+func TestFundingTracker(t *testing.T) {
+	events := make(chan Event, 10)
+	bus := newEventBus()
+	defer bus.close()
+	bus.subscribe(func(e Event) { events <- e })
+
+	// two assets, three participants
+	tracker := &fundingTracker{
+		funded: [][]bool{make([]bool, 3), make([]bool, 3)},
+		events: bus,
+	}
+	next := func() FundingProgressed {
+		select {
+		case e := <-events:
+			return e.(FundingProgressed)
+		case <-time.After(time.Second):
+			require.FailNow(t, "expected event")
+		}
+		panic("unreachable")
+	}
+
+	for _, tt := range []struct {
+		progress    channel.FundingProgress
+		partsFunded int
+	}{
+		{channel.FundingProgress{Asset: 0, Idx: 1}, 0},
+		{channel.FundingProgress{Asset: 1, Idx: 1}, 1},
+		{channel.FundingProgress{Asset: 1, Idx: 0}, 1},
+		{channel.FundingProgress{Asset: 0, Idx: 0}, 2},
+	} {
+		tracker.report(tt.progress)
+		p := next()
+		assert.Equal(t, tt.progress, p.Progress)
+		assert.Equal(t, tt.partsFunded, p.PartsFunded)
+		assert.Equal(t, 3, p.NumParts)
+	}
+
+	// duplicate and invalid reports are ignored
+	tracker.report(channel.FundingProgress{Asset: 0, Idx: 0})
+	tracker.report(channel.FundingProgress{Asset: 2, Idx: 0})
+	tracker.report(channel.FundingProgress{Asset: 0, Idx: 3})
+	tracker.report(channel.FundingProgress{Asset: 0, Idx: 2})
+	assert.Equal(t, channel.FundingProgress{Asset: 0, Idx: 2}, next().Progress)
+}
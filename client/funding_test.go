@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simchannel "perun.network/go-perun/backend/sim/channel"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// TestClient_FundingProgress checks that the funding progress is reported and
+// that a stuck funding can be aborted by cancelling the context.
+func TestClient_FundingProgress(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0d))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	ledger := simchannel.NewLedger("")
+
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &virtualPropHandler{
+		t:    t,
+		acc:  wallettest.NewRandomAccount(rng),
+		errs: make(chan error, 1),
+	}
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		simchannel.NewFunder(ledger), simchannel.NewAdjudicator(ledger))
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		stuckFunder{}, simchannel.NewAdjudicator(ledger))
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	progress := make(chan client.FundingProgressed, 1)
+	alice.OnEvent(func(e client.Event) {
+		if p, ok := e.(client.FundingProgressed); ok {
+			progress <- p
+		}
+	})
+
+	fundCtx, abort := context.WithCancel(ctx)
+	go func() {
+		defer abort()
+		select {
+		case p := <-progress:
+			assert.Equal(t, channel.FundingProgress{Asset: 0, Idx: 0}, p.Progress)
+			assert.Equal(t, 1, p.PartsFunded)
+			assert.Equal(t, 2, p.NumParts)
+		case <-ctx.Done():
+			t.Error("expected funding progress")
+		}
+	}()
+
+	prop := newTestProposal(rng, simchannel.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	ch, err := alice.ProposeChannel(fundCtx, prop)
+	require.True(t, channel.IsFundingTimeoutError(err), "funding should be aborted: %v", err)
+	assert.Contains(t, err.Error(), "[1]", "Bob's deposit is missing")
+	require.NotNil(t, ch)
+	assert.Equal(t, channel.Funding, ch.Phase())
+
+	// Bob's funding is aborted when his acceptance times out.
+	select {
+	case err := <-bobHandler.errs:
+		assert.Error(t, err)
+	case <-ctx.Done():
+		t.Error("expected Bob's funding to be aborted")
+	}
+}
+
+// stuckFunder is a Funder that never deposits and waits until the context is
+// done.
+type stuckFunder struct{}
+
+func (stuckFunder) Fund(ctx context.Context, req channel.FundingReq) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package client
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	wallettest "perun.network/go-perun/wallet/test"
+	"perun.network/go-perun/wire/msg"
+)
+
+// FuzzDecodeMsg checks that decoding arbitrary messages from peers does not
+// panic. It is seeded with encodings of the client's messages, also wrapped in
+// ReliableMsgs. Run it with
+//
+//	go test -fuzz FuzzDecodeMsg ./client
+func FuzzDecodeMsg(f *testing.F) {
+	rng := rand.New(rand.NewSource(0xf0220))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	up := newRandomMsgChannelUpdate(rng)
+	tx := newRandomVirtualChannelTx(rng)
+	seeds := []msg.Msg{
+		req,
+		&ChannelProposalAcc{SessID: req.SessID(), ParticipantAddr: wallettest.NewRandomAddress(rng)},
+		&ChannelProposalRej{SessID: req.SessID(), Reason: "reason"},
+		&VirtualChannelProposalReq{ChannelProposalReq: *req, Intermediary: wallettest.NewRandomAddress(rng)},
+		up,
+		&msgChannelUpdateAcc{ChannelID: up.State.ID, Version: up.State.Version, Sig: newRandomSig(rng)},
+		&msgVirtualChannelFundingProposal{msgChannelUpdate: *up, Initial: *tx},
+		&msgChannelSync{
+			ChannelID: up.State.ID,
+			CurrentTX: channel.Transaction{State: test.NewRandomState(rng, tx.Params), Sigs: tx.Tx.Sigs},
+		},
+		&msg.ReliableMsg{Seq: 1, Msg: up},
+	}
+	for _, m := range seeds {
+		var buf bytes.Buffer
+		if err := msg.Encode(m, &buf); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := msg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if err := msg.Encode(m, new(bytes.Buffer)); err != nil {
+			t.Errorf("encoding decoded %v message: %v", m.Type(), err)
+		}
+	})
+}
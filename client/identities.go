@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// identity is a network identity of the client with its own peer
+	// registry. Proposals to the identity are handled by its handler.
+	identity struct {
+		id      peer.Identity
+		peers   *peer.Registry
+		handler ProposalHandler
+	}
+
+	// identities are the additional identities of a client, see
+	// Client.AddIdentity. The client's primary identity is not part of them.
+	identities struct {
+		mutex sync.RWMutex
+		ids   []*identity
+
+		// keepalive of the registries, see Client.EnableKeepAlive
+		keepAliveInterval  time.Duration
+		keepAliveMaxMissed int
+		tracer             peer.Tracer // see Client.SetTracer
+	}
+)
+
+// AddIdentity adds the additional network identity id to the client, so that
+// a single client can serve multiple users, e.g., of a custodial service. The
+// identity has its own peer registry, which uses dialer to dial peers, and
+// should listen for incoming connections with ListenAs.
+//
+// Incoming channel proposals are routed by their recipient: Proposals to id
+// are handled by handler, or by the client's ProposalHandler if it is nil. A
+// channel is proposed from id by putting its address first in the PeerAddrs of
+// the ChannelProposal. All identities share the client's channels, funder,
+// adjudicator, persistence and events. Identities must be added before
+// channels with them are restored, see Restore.
+//
+// A channel cannot have more than one of the client's identities as peers.
+// If id or dialer is nil, AddIdentity panics.
+func (c *Client) AddIdentity(id peer.Identity, dialer peer.Dialer, handler ProposalHandler) error {
+	if id == nil {
+		c.log.Panic("identity must not be nil")
+	}
+	if dialer == nil {
+		c.log.Panic("dialer must not be nil")
+	}
+	if handler == nil {
+		handler = c.propHandler
+	}
+
+	c.idents.mutex.Lock()
+	defer c.idents.mutex.Unlock()
+	if c.identityLocked(id.Address()) != nil {
+		dialer.Close()
+		return errors.Errorf("identity %v already added", id.Address())
+	}
+	ident := &identity{id: id, handler: handler}
+	ident.peers = peer.NewRegistry(id, func(p *peer.Peer) { c.subscribePeer(ident, p) }, dialer)
+	ident.peers.SetCapabilities(capabilities)
+	if c.idents.keepAliveInterval > 0 {
+		ident.peers.SetKeepAlive(c.idents.keepAliveInterval, c.idents.keepAliveMaxMissed)
+	}
+	ident.peers.SetTracer(c.idents.tracer)
+	c.idents.ids = append(c.idents.ids, ident)
+	return nil
+}
+
+// ListenAs starts listening for incoming connections to the identity with the
+// given address, like Listen does for the primary identity. The identity must
+// have been added with AddIdentity before, otherwise ListenAs panics.
+func (c *Client) ListenAs(addr wallet.Address, listener peer.Listener) {
+	ident := c.identity(addr)
+	if ident == nil {
+		c.log.Panicf("unknown identity %v", addr)
+	}
+	c.listen(ident.peers, listener)
+}
+
+// Identities returns the addresses of all identities of the client, the
+// primary identity first.
+func (c *Client) Identities() []wallet.Address {
+	c.idents.mutex.RLock()
+	defer c.idents.mutex.RUnlock()
+	addrs := []wallet.Address{c.id.Address()}
+	for _, ident := range c.idents.ids {
+		addrs = append(addrs, ident.id.Address())
+	}
+	return addrs
+}
+
+// primary returns the client's primary identity.
+func (c *Client) primary() *identity {
+	return &identity{id: c.id, peers: c.peers, handler: c.propHandler}
+}
+
+// identity returns the identity of the client with the given address or nil
+// if there is none.
+func (c *Client) identity(addr wallet.Address) *identity {
+	c.idents.mutex.RLock()
+	defer c.idents.mutex.RUnlock()
+	return c.identityLocked(addr)
+}
+
+func (c *Client) identityLocked(addr wallet.Address) *identity {
+	if addr.Equals(c.id.Address()) {
+		return c.primary()
+	}
+	for _, ident := range c.idents.ids {
+		if ident.id.Address().Equals(addr) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// isIdentity returns whether addr is the address of one of the client's
+// identities.
+func (c *Client) isIdentity(addr wallet.Address) bool {
+	return c.identity(addr) != nil
+}
+
+// ourIdentity returns our first identity in addrs and its index. If none of
+// our identities is in addrs, the primary identity and -1 are returned.
+func (c *Client) ourIdentity(addrs []wallet.Address) (*identity, int) {
+	for i, addr := range addrs {
+		if ident := c.identity(addr); ident != nil {
+			return ident, i
+		}
+	}
+	return c.primary(), -1
+}
+
+// validIdentities checks that at most one of addrs is one of our identities.
+func (c *Client) validIdentities(addrs []wallet.Address) error {
+	n := 0
+	for _, addr := range addrs {
+		if c.isIdentity(addr) {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("more than one of our identities are peers")
+	}
+	return nil
+}
+
+// setKeepAlive sets the keepalive of the registries of all additional
+// identities, also of those that are added later.
+func (is *identities) setKeepAlive(interval time.Duration, maxMissed int) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+	is.keepAliveInterval, is.keepAliveMaxMissed = interval, maxMissed
+	for _, ident := range is.ids {
+		ident.peers.SetKeepAlive(interval, maxMissed)
+	}
+}
+
+// setTracer sets the tracer of the registries of all additional identities,
+// also of those that are added later.
+func (is *identities) setTracer(t peer.Tracer) {
+	is.mutex.Lock()
+	defer is.mutex.Unlock()
+	is.tracer = t
+	for _, ident := range is.ids {
+		ident.peers.SetTracer(t)
+	}
+}
+
+// close closes the registries of all additional identities.
+func (is *identities) close() (err error) {
+	is.mutex.RLock()
+	defer is.mutex.RUnlock()
+	for _, ident := range is.ids {
+		if cerr := ident.peers.Close(); err == nil {
+			err = errors.WithMessagef(cerr, "closing registry of %v", ident.id.Address())
+		}
+	}
+	return err
+}
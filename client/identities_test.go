@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_AddIdentity(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1d5))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+
+	newHandler := func() *virtualPropHandler {
+		return &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	}
+	aliceID, bobID, carolID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	aliceHandler, bobHandler, carolHandler := newHandler(), newHandler(), newHandler()
+	alice := client.New(aliceID, hub.NewDialer(), aliceHandler,
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+	go alice.Listen(hub.NewListener(aliceID.Address()))
+
+	// Bob's client also serves Carol.
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+	require.NoError(t, bob.AddIdentity(carolID, hub.NewDialer(), carolHandler))
+	assert.Error(t, bob.AddIdentity(carolID, hub.NewDialer(), nil), "adding identity twice")
+	assert.Equal(t, []wallet.Address{bobID.Address(), carolID.Address()}, bob.Identities())
+	go bob.Listen(hub.NewListener(bobID.Address()))
+	go bob.ListenAs(carolID.Address(), hub.NewListener(carolID.Address()))
+
+	t.Run("routed by recipient", func(t *testing.T) {
+		prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), carolID.Address(), 100, 100)
+		_, err := alice.ProposeChannel(ctx, prop)
+		require.NoError(t, err)
+		select {
+		case ch := <-carolHandler.chans:
+			assert.True(t, ch.Params().Parts[1].Equals(carolHandler.acc.Address()))
+		case <-ctx.Done():
+			t.Fatal("expected channel at Carol")
+		}
+		assert.Len(t, bobHandler.chans, 0, "proposal routed to Bob")
+	})
+
+	t.Run("propose as identity", func(t *testing.T) {
+		prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), carolID.Address(), aliceID.Address(), 100, 100)
+		ch, err := bob.ProposeChannel(ctx, prop)
+		require.NoError(t, err)
+		go ch.ListenUpdates(acceptAllUpdates{t})
+		select {
+		case ch := <-aliceHandler.chans:
+			assert.True(t, ch.Params().Parts[0].Equals(prop.Account.Address()))
+		case <-ctx.Done():
+			t.Fatal("expected channel at Alice")
+		}
+	})
+
+	t.Run("invalid identities", func(t *testing.T) {
+		asset := channeltest.NewRandomAsset(rng)
+		prop := newTestProposal(rng, asset, wallettest.NewRandomAddress(rng), aliceID.Address(), 100, 100)
+		_, err := bob.ProposeChannel(ctx, prop)
+		assert.Error(t, err, "unknown proposer")
+		prop = newTestProposal(rng, asset, carolID.Address(), bobID.Address(), 100, 100)
+		_, err = bob.ProposeChannel(ctx, prop)
+		assert.Error(t, err, "channel between own identities")
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/multi"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// TestMultiLedger tests that both the proposer and the proposee of a channel
+// with assets on two ledgers route the funding of each asset to the Funder of
+// its ledger.
+func TestMultiLedger(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0x1ed9e5))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ledgers := []channel.LedgerID{"A", "B"}
+	type party struct {
+		*client.Client
+		id      peer.Identity
+		funders map[channel.LedgerID]*ledgerFunder
+	}
+	newParty := func(name string, h client.ProposalHandler) *party {
+		id := wallettest.NewRandomAccount(rng)
+		p := &party{id: id, funders: make(map[channel.LedgerID]*ledgerFunder)}
+		funder, adj := multi.NewFunder(), multi.NewAdjudicator()
+		for _, l := range ledgers {
+			p.funders[l] = new(ledgerFunder)
+			funder.RegisterFunder(l, p.funders[l])
+			adj.RegisterAdjudicator(l, &logAdjudicator{log.WithField("role", name).WithField("ledger", l)})
+		}
+		p.Client = client.New(id, hub.NewDialer(), h, funder, adj)
+		go p.Listen(hub.NewListener(id.Address()))
+		return p
+	}
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice := newParty("Alice", &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)})
+	bob := newParty("Bob", bobHandler)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+
+	assets := []channel.Asset{
+		channeltest.NewRandomLedgerAsset(rng, ledgers[0]),
+		channeltest.NewRandomLedgerAsset(rng, ledgers[1]),
+	}
+	prop := newTestProposal(rng, assets[0], alice.id.Address(), bob.id.Address(), 100, 100)
+	prop.InitBals.Assets = assets
+	for i := range prop.InitBals.OfParts {
+		prop.InitBals.OfParts[i] = append(prop.InitBals.OfParts[i], big.NewInt(50))
+	}
+	ch, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(err)
+	select {
+	case <-bobHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected channel at Bob")
+	}
+
+	for _, p := range []*party{alice, bob} {
+		for _, l := range ledgers {
+			reqs := p.funders[l].requests()
+			require.Len(reqs, 1)
+			require.Len(reqs[0].Allocation.Assets, 1)
+			assert.Equal(t, l, channel.LedgerOf(reqs[0].Allocation.Assets[0]))
+			assert.Equal(t, ch.ID(), reqs[0].Params.ID())
+		}
+	}
+}
+
+// ledgerFunder records all funding requests.
+type ledgerFunder struct {
+	mtx  sync.Mutex
+	reqs []channel.FundingReq
+}
+
+func (f *ledgerFunder) Fund(_ context.Context, req channel.FundingReq) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.reqs = append(f.reqs, req)
+	return nil
+}
+
+func (f *ledgerFunder) requests() []channel.FundingReq {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]channel.FundingReq(nil), f.reqs...)
+}
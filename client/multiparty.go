@@ -0,0 +1,243 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/metrics"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+// defaultMultiPartyTimeout is the default time that the proposer of a channel
+// with more than two participants waits for the response of each peer, see
+// Client.SetMultiPartyTimeout.
+const defaultMultiPartyTimeout = 10 * time.Second
+
+// SetMultiPartyTimeout sets the time that the proposer of a channel with more
+// than two participants waits for the response of each peer. It defaults to
+// 10 seconds.
+//
+// If d is not positive, SetMultiPartyTimeout panics.
+func (c *Client) SetMultiPartyTimeout(d time.Duration) {
+	if d <= 0 {
+		c.log.Panic("multi-party timeout must be positive")
+	}
+	atomic.StoreInt64(&c.mpTimeout, int64(d))
+}
+
+// multiPartyTimeout returns the timeout set with SetMultiPartyTimeout.
+func (c *Client) multiPartyTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&c.mpTimeout))
+}
+
+// validMultiPartyProposal checks that the ledger channel proposal of more than
+// two participants is valid: The proposer has to have index 0 in the peer list
+// and we have to be one of the peers, every peer only once.
+func (c *Client) validMultiPartyProposal(proposal *ChannelProposalReq, proposer wallet.Address) error {
+	if err := proposal.Valid(); err != nil {
+		return err
+	}
+
+	if len(proposal.PeerAddrs) <= 2 {
+		return errors.Errorf("expected more than 2 peers, got %d", len(proposal.PeerAddrs))
+	}
+	if !proposal.PeerAddrs[0].Equals(proposer) {
+		return errors.New("proposer doesn't have peer index 0")
+	}
+	if _, idx := c.ourIdentity(proposal.PeerAddrs); idx == -1 {
+		return errors.New("we are not one of the peers")
+	} else if err := c.validIdentities(proposal.PeerAddrs); err != nil {
+		return err
+	}
+	for i, addr := range proposal.PeerAddrs {
+		if wallet.IndexOfAddr(proposal.PeerAddrs[:i], addr) != -1 {
+			return errors.Errorf("peer %d is listed twice", i)
+		}
+	}
+	return nil
+}
+
+// exchangeMultiPartyProposal implements the proposer's side of the MPCPP for
+// more than two participants and returns the parameters of the new channel.
+// The proposal is sent to all peers concurrently and each peer has to accept
+// it within the multi-party timeout, see SetMultiPartyTimeout. Once all peers accepted, the participants
+// and nonce shares of all peers are broadcast in a ChannelProposalParts.
+// Otherwise, the proposal is aborted at all peers.
+//
+// The funding is coordinated by the signatures on the initial state: No peer
+// funds the channel before all participants signed it, see setupChannel.
+func (c *Client) exchangeMultiPartyProposal(
+	ctx context.Context,
+	req *ChannelProposalReq,
+) (*channel.Params, error) {
+	ident, _ := c.ourIdentity(req.PeerAddrs[:1])
+	peerAddrs := req.PeerAddrs[1:]
+	unlock, err := c.propLocks.lockAll(ctx, peerAddrs)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	peers := make([]*peer.Peer, len(peerAddrs))
+	for i, addr := range peerAddrs {
+		p, err := ident.peers.Get(ctx, addr)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "failed to Get() participant[%d]", i+1)
+		}
+		if !p.Capabilities().Has(wire.CapMultiParty) {
+			return nil, errors.Errorf("peer %d does not support multi-party channels", i+1)
+		}
+		// enables caching of incoming version 0 signatures, see
+		// exchangeTwoPartyProposal.
+		enableVer0Cache(ctx, p)
+		peers[i] = p
+	}
+
+	sessID := req.SessID()
+	accs := make([]*ChannelProposalAcc, len(peers))
+	errs := make([]error, len(peers))
+	timeout := c.multiPartyTimeout()
+	var wg sync.WaitGroup
+	wg.Add(len(peers))
+	for i, p := range peers {
+		go func(i int, p *peer.Peer) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			accs[i], errs[i] = c.proposeToPeer(pctx, p, req)
+		}(i, p)
+	}
+	wg.Wait()
+
+	abort := func(reason string) {
+		for _, p := range peers {
+			c.abortProposal(p, sessID, reason)
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			abort("proposal failed at another peer")
+			return nil, errors.WithMessagef(err, "peer %d", i+1)
+		}
+	}
+
+	parts := []wallet.Address{req.ParticipantAddr}
+	shares := []NonceShare{req.NonceShare}
+	for i, acc := range accs {
+		if wallet.IndexOfAddr(parts, acc.ParticipantAddr) != -1 {
+			abort("duplicate participant")
+			return nil, errors.Errorf("participant of peer %d is not unique", i+1)
+		}
+		parts = append(parts, acc.ParticipantAddr)
+		shares = append(shares, acc.NonceShare)
+	}
+	params := req.partsParams(parts, shares)
+
+	msgParts := &ChannelProposalParts{
+		SessID:       sessID,
+		Participants: parts,
+		NonceShares:  shares,
+		ChannelID:    params.ID(),
+	}
+	if err := peer.NewBroadcaster(peers).Send(ctx, msgParts); err != nil {
+		abort("broadcasting participants failed")
+		return nil, errors.WithMessage(err, "broadcasting participants")
+	}
+	metrics.ProposalAccepted()
+	return params, nil
+}
+
+// getChannelPeers gets the peers of a new channel with more than two
+// participants from the registry of our identity, skipping us. The acceptors are not
+// connected to each other yet, and would close each other's connections if
+// they dialed each other at the same time. So we only dial the peers before
+// us in the peer list and wait for the peers after us to connect to us.
+// The proposer is connected to all peers already.
+func (c *Client) getChannelPeers(ctx context.Context, addrs []wallet.Address) ([]*peer.Peer, error) {
+	ident, idx := c.ourIdentity(addrs)
+	peers := make([]*peer.Peer, 0, len(addrs)-1)
+	for i, addr := range addrs {
+		var p *peer.Peer
+		var err error
+		if i < idx {
+			p, err = ident.peers.Get(ctx, addr)
+		} else if i > idx {
+			p, err = ident.peers.Await(ctx, addr)
+		} else {
+			continue
+		}
+		if err != nil {
+			return nil, errors.WithMessagef(err, "getting peer %d", i)
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// proposalPartsRecv receives the ChannelProposalParts of a proposal from its
+// proposer.
+type proposalPartsRecv struct {
+	*peer.Receiver
+}
+
+// newProposalPartsRecv subscribes to the ChannelProposalParts of the proposal
+// with the given session ID from proposer p. It has to be created before the
+// acceptance is sent, so that the message cannot be missed.
+func newProposalPartsRecv(p *peer.Peer, sessID SessionID) (*proposalPartsRecv, error) {
+	r := &proposalPartsRecv{peer.NewReceiver()}
+	if err := p.Subscribe(r, func(m wire.Msg) bool {
+		parts, ok := m.(*ChannelProposalParts)
+		return ok && parts.SessID == sessID
+	}); err != nil {
+		r.Close()
+		return nil, errors.WithMessagef(err, "subscribing peer %v", p)
+	}
+	return r, nil
+}
+
+// params waits for the ChannelProposalParts and returns the parameters of the
+// channel after checking that they match the proposal and our acceptance.
+// ourIdx is our index in the peer list.
+func (r *proposalPartsRecv) params(
+	ctx context.Context,
+	req *ChannelProposalReq,
+	acc *ChannelProposalAcc,
+	ourIdx int,
+) (*channel.Params, error) {
+	_, m := r.Next(ctx)
+	if m == nil {
+		return nil, newRequestTimedOutError("proposal participants")
+	}
+	parts := m.(*ChannelProposalParts) // safe by predicate
+
+	if len(parts.Participants) != len(req.PeerAddrs) || len(parts.NonceShares) != len(req.PeerAddrs) {
+		return nil, errors.New("wrong number of participants")
+	} else if !parts.Participants[0].Equals(req.ParticipantAddr) || parts.NonceShares[0] != req.NonceShare {
+		return nil, errors.New("proposer's participant or nonce share changed")
+	} else if !parts.Participants[ourIdx].Equals(acc.ParticipantAddr) || parts.NonceShares[ourIdx] != acc.NonceShare {
+		return nil, errors.New("our participant or nonce share changed")
+	}
+	for i, part := range parts.Participants {
+		if wallet.IndexOfAddr(parts.Participants[:i], part) != -1 {
+			return nil, errors.Errorf("participant %d is listed twice", i)
+		}
+	}
+
+	params := req.partsParams(parts.Participants, parts.NonceShares)
+	if params.ID() != parts.ChannelID {
+		return nil, newChannelIDMismatchError(params.ID(), parts.ChannelID)
+	}
+	return params, nil
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestMultiPartyProposal(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		reject bool // whether the last peer rejects
+	}{
+		{"accepted", false},
+		{"rejected", true},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(0x3a29))
+			var hub peertest.ConnHub
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			const n = 3
+			clients := make([]*client.Client, n)
+			handlers := make([]*virtualPropHandler, n)
+			addrs := make([]peer.Address, n)
+			for i := range clients {
+				id := wallettest.NewRandomAccount(rng)
+				addrs[i] = id.Address()
+				handlers[i] = &virtualPropHandler{
+					t:        t,
+					acc:      wallettest.NewRandomAccount(rng),
+					chans:    make(chan *client.Channel, 1),
+					noListen: true,
+					errs:     make(chan error, 1),
+				}
+				var handler client.ProposalHandler = handlers[i]
+				if tt.reject && i == n-1 {
+					handler = rejectingPropHandler{}
+				}
+				role := log.WithField("role", i)
+				clients[i] = client.New(id, hub.NewDialer(), handler, &logFunder{role}, &logAdjudicator{role})
+				defer clients[i].Close()
+				go clients[i].Listen(hub.NewListener(id.Address()))
+			}
+
+			prop := &client.ChannelProposal{
+				ChallengeDuration: 10,
+				NonceShare:        client.NewRandomNonceShare(),
+				Account:           wallettest.NewRandomAccount(rng),
+				AppDef:            payment.AppDef(),
+				InitData:          new(payment.NoData),
+				InitBals: &channel.Allocation{
+					Assets:  []channel.Asset{channeltest.NewRandomAsset(rng)},
+					OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(20)}, {big.NewInt(30)}},
+				},
+				PeerAddrs: addrs,
+			}
+			ch, err := clients[0].ProposeChannel(ctx, prop)
+			if tt.reject {
+				assert.Error(t, err)
+				// The peer that accepted stops setting up the channel.
+				select {
+				case err := <-handlers[1].errs:
+					assert.Error(t, err)
+				case <-ctx.Done():
+					t.Fatal("accepting peer did not stop")
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, channel.Acting, ch.Phase())
+			assert.Len(t, ch.Params().Parts, n)
+			assert.Equal(t, channel.Index(0), ch.Idx())
+
+			for i := 1; i < n; i++ {
+				select {
+				case peerCh := <-handlers[i].chans:
+					assert.Equal(t, ch.ID(), peerCh.ID())
+					assert.Equal(t, channel.Index(i), peerCh.Idx())
+					assert.Equal(t, channel.Acting, peerCh.Phase())
+				case err := <-handlers[i].errs:
+					t.Fatalf("peer %d failed: %v", i, err)
+				case <-ctx.Done():
+					t.Fatalf("expected channel at peer %d", i)
+				}
+			}
+		})
+	}
+}
+
+// rejectingPropHandler rejects all proposals.
+type rejectingPropHandler struct{}
+
+func (rejectingPropHandler) Handle(_ *client.ChannelProposalReq, res *client.ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	res.Reject(ctx, "not interested")
+}
+
+func TestMultiPartyProposal_Concurrent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a2a))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+
+	// Alice proposes two channels with Bob and Carol concurrently, listing them
+	// in opposite order.
+	const n = 3
+	clients := make([]*client.Client, n)
+	handlers := make([]*virtualPropHandler, n)
+	addrs := make([]peer.Address, n)
+	for i := range clients {
+		id := wallettest.NewRandomAccount(rng)
+		addrs[i] = id.Address()
+		handlers[i] = &virtualPropHandler{
+			t:        t,
+			acc:      wallettest.NewRandomAccount(rng),
+			chans:    make(chan *client.Channel, 2),
+			noListen: true,
+			errs:     make(chan error, 2),
+		}
+		role := log.WithField("role", i)
+		clients[i] = client.New(id, hub.NewDialer(), handlers[i], &logFunder{role}, &logAdjudicator{role})
+		defer clients[i].Close()
+		go clients[i].Listen(hub.NewListener(id.Address()))
+	}
+	assert.Panics(t, func() { clients[0].SetMultiPartyTimeout(0) })
+	clients[0].SetMultiPartyTimeout(2 * time.Second)
+
+	newProp := func(peers ...peer.Address) *client.ChannelProposal {
+		return &client.ChannelProposal{
+			ChallengeDuration: 10,
+			NonceShare:        client.NewRandomNonceShare(),
+			Account:           wallettest.NewRandomAccount(rng),
+			AppDef:            payment.AppDef(),
+			InitData:          new(payment.NoData),
+			InitBals: &channel.Allocation{
+				Assets:  []channel.Asset{channeltest.NewRandomAsset(rng)},
+				OfParts: [][]channel.Bal{{big.NewInt(10)}, {big.NewInt(20)}, {big.NewInt(30)}},
+			},
+			PeerAddrs: peers,
+		}
+	}
+	props := []*client.ChannelProposal{
+		newProp(addrs[0], addrs[1], addrs[2]),
+		newProp(addrs[0], addrs[2], addrs[1]),
+	}
+	errs := make(chan error, len(props))
+	for _, prop := range props {
+		go func(prop *client.ChannelProposal) {
+			_, err := clients[0].ProposeChannel(ctx, prop)
+			errs <- err
+		}(prop)
+	}
+	for range props {
+		select {
+		case err := <-errs:
+			assert.NoError(t, err)
+		case <-ctx.Done():
+			t.Fatal("concurrent proposals deadlocked")
+		}
+	}
+}
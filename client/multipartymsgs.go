@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+	"perun.network/go-perun/wire/msg"
+)
+
+func init() {
+	msg.RegisterDecoder(msg.ChannelProposalParts,
+		func(r io.Reader) (msg.Msg, error) {
+			var m ChannelProposalParts
+			return &m, m.Decode(r)
+		})
+}
+
+// ChannelProposalParts is broadcast by the proposer of a channel with more
+// than two participants once all peers accepted the proposal. It contains the
+// participants and nonce shares of all peers, in peer order, from which every
+// peer derives the channel parameters. ChannelID is the ID that the proposer
+// derived, so that the peers can check that they open the same channel.
+//
+// The message completes the Multi-Party Channel Proposal Protocol (MPCPP) for
+// more than two participants.
+type ChannelProposalParts struct {
+	SessID       SessionID
+	Participants []wallet.Address
+	NonceShares  []NonceShare
+	ChannelID    channel.ID
+}
+
+// Type returns msg.ChannelProposalParts.
+func (ChannelProposalParts) Type() msg.Type {
+	return msg.ChannelProposalParts
+}
+
+// Encode encodes the ChannelProposalParts into an io.Writer.
+func (m ChannelProposalParts) Encode(w io.Writer) error {
+	if len(m.Participants) != len(m.NonceShares) {
+		return errors.New("number of participants and nonce shares differ")
+	} else if len(m.Participants) > channel.MaxNumParts {
+		return errors.Errorf(
+			"expected maximum number of participants %d, got %d",
+			channel.MaxNumParts, len(m.Participants))
+	}
+
+	if err := wire.Encode(w, m.SessID, int32(len(m.Participants))); err != nil {
+		return err
+	}
+	for i := range m.Participants {
+		if err := m.Participants[i].Encode(w); err != nil {
+			return errors.WithMessagef(err, "encoding participant %d", i)
+		}
+		if err := wire.Encode(w, m.NonceShares[i]); err != nil {
+			return errors.WithMessagef(err, "encoding nonce share %d", i)
+		}
+	}
+	return errors.WithMessage(wire.Encode(w, m.ChannelID), "channel ID encoding")
+}
+
+// Decode decodes a ChannelProposalParts from an io.Reader.
+func (m *ChannelProposalParts) Decode(r io.Reader) (err error) {
+	var numParts int32
+	if err := wire.Decode(r, &m.SessID, &numParts); err != nil {
+		return err
+	}
+	if numParts < 2 || numParts > channel.MaxNumParts {
+		return errors.Errorf(
+			"expected between 2 and %d participants, got %d",
+			channel.MaxNumParts, numParts)
+	}
+
+	m.Participants = make([]wallet.Address, numParts)
+	m.NonceShares = make([]NonceShare, numParts)
+	for i := range m.Participants {
+		if m.Participants[i], err = wallet.DecodeAddress(r); err != nil {
+			return errors.WithMessagef(err, "decoding participant %d", i)
+		}
+		if err := wire.Decode(r, &m.NonceShares[i]); err != nil {
+			return errors.WithMessagef(err, "decoding nonce share %d", i)
+		}
+	}
+	return errors.WithMessage(wire.Decode(r, &m.ChannelID), "channel ID decoding")
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestChannelProposalPartsSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a27))
+	for i := 0; i < 4; i++ {
+		n := 3 + i
+		m := &ChannelProposalParts{
+			Participants: make([]wallet.Address, n),
+			NonceShares:  make([]NonceShare, n),
+			ChannelID:    test.NewRandomChannelID(rng),
+		}
+		rng.Read(m.SessID[:])
+		for j := range m.Participants {
+			m.Participants[j] = wallettest.NewRandomAddress(rng)
+			rng.Read(m.NonceShares[j][:])
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func TestChannelProposalParts_EncodeMismatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a28))
+	m := &ChannelProposalParts{
+		Participants: []wallet.Address{wallettest.NewRandomAddress(rng)},
+		NonceShares:  make([]NonceShare, 2),
+	}
+	assert.Error(t, m.Encode(ioutil.Discard))
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_SetOutbox(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x0b0c5))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	alice := client.New(aliceID, hub.NewDialer(),
+		&virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)},
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+
+	newOutbox := func() (*peer.Outbox, db.Database) {
+		database := memorydb.NewDatabase()
+		outbox, err := peer.NewOutbox(database)
+		require.NoError(t, err)
+		return outbox, database
+	}
+	aliceOutbox, aliceDB := newOutbox()
+	bobOutbox, bobDB := newOutbox()
+	assert.Panics(t, func() { alice.SetOutbox(aliceID.Address(), nil) })
+	assert.Error(t, alice.SetOutbox(bobID.Address(), aliceOutbox), "unknown identity")
+	require.NoError(t, alice.SetOutbox(aliceID.Address(), aliceOutbox))
+	require.NoError(t, bob.SetOutbox(bobID.Address(), bobOutbox))
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	ch, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	<-bobHandler.chans
+
+	// Updates are sent through the outboxes, which are empty once all messages
+	// are acknowledged.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, ch.UpdateBy(ctx, func(state *channel.State) error {
+			state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+			state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+			return nil
+		}))
+	}
+	assertLedgerBals(t, ch.State(), 70, 130, 0)
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.Zero(t, numOutboxMsgs(aliceDB), "Alice's outbox")
+		assert.Zero(t, numOutboxMsgs(bobDB), "Bob's outbox")
+	})
+	for _, database := range []db.Database{aliceDB, bobDB} {
+		used, err := database.Has("next")
+		require.NoError(t, err)
+		assert.True(t, used, "outbox not used")
+	}
+}
+
+// numOutboxMsgs returns the number of messages in the outbox database, which
+// are all keys except the next sequence number.
+func numOutboxMsgs(database db.Database) (n int) {
+	it := database.NewIterator()
+	defer it.Close()
+	for it.Next() {
+		if it.Key() != "next" {
+			n++
+		}
+	}
+	return n
+}
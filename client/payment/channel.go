@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package payment
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+)
+
+// Channel is a two-party payment channel of a single asset. It accepts all
+// incoming payments, which can be retrieved with Receive.
+type Channel struct {
+	*client.Channel
+
+	mtx      sync.Mutex
+	bal      *big.Int      // our balance in the last known state
+	received *big.Int      // received since the last call to Receive
+	notify   chan struct{} // signals new received payments
+	updates  chan *channel.State
+	done     chan struct{}
+}
+
+// newChannel wraps the channel and starts handling its updates, which have to
+// be accepted within the given timeout.
+func newChannel(ch *client.Channel, timeout time.Duration) *Channel {
+	c := &Channel{
+		Channel:  ch,
+		bal:      new(big.Int).Set(ch.State().OfParts[ch.Idx()][0]),
+		received: new(big.Int),
+		notify:   make(chan struct{}, 1),
+		updates:  make(chan *channel.State),
+		done:     make(chan struct{}),
+	}
+	ch.SubUpdates(c.updates)
+	go c.trackUpdates()
+	go ch.ListenUpdates(acceptAll(timeout))
+	return c
+}
+
+// acceptAll returns an UpdateHandler that accepts all incoming updates. The
+// payment app already guarantees that the peer can only pay us.
+func acceptAll(timeout time.Duration) client.UpdateHandler {
+	return client.UpdateHandlerFunc(func(_ client.ChannelUpdate, res *client.UpdateResponder) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := res.Accept(ctx); err != nil {
+			log.Warnf("accepting payment: %v", err)
+		}
+	})
+}
+
+// Balance returns our current balance.
+func (c *Channel) Balance() *big.Int {
+	return new(big.Int).Set(c.State().OfParts[c.Idx()][0])
+}
+
+// Send sends amount to the peer. It returns an error if amount is not positive,
+// exceeds our balance or the peer doesn't accept the payment.
+func (c *Channel) Send(ctx context.Context, amount *big.Int) error {
+	if amount == nil || amount.Sign() != 1 {
+		return errors.New("amount must be positive")
+	}
+	return c.UpdateBy(ctx, func(state *channel.State) error {
+		our, their := state.OfParts[c.Idx()][0], state.OfParts[1-c.Idx()][0]
+		if our.Cmp(amount) == -1 {
+			return errors.Errorf("insufficient balance %v", our)
+		}
+		our.Sub(our, amount)
+		their.Add(their, amount)
+		return nil
+	})
+}
+
+// Receive waits until a payment was received and returns the sum of all
+// payments received since the last call to Receive.
+func (c *Channel) Receive(ctx context.Context) (*big.Int, error) {
+	for {
+		c.mtx.Lock()
+		if c.received.Sign() == 1 {
+			received := c.received
+			c.received = new(big.Int)
+			c.mtx.Unlock()
+			return received, nil
+		}
+		c.mtx.Unlock()
+
+		select {
+		case <-c.notify:
+		case <-c.done:
+			return nil, errors.New("channel closed")
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "waiting for payment")
+		}
+	}
+}
+
+// Close settles the channel, withdrawing our final balance, and closes it. Both
+// participants should close the channel. Closing it concurrently may fail, see
+// client.Channel.Settle.
+func (c *Channel) Close(ctx context.Context) error {
+	if err := c.Settle(ctx); err != nil {
+		return errors.WithMessage(err, "settling")
+	}
+	close(c.done)
+	return c.Channel.Close()
+}
+
+// trackUpdates sums up the received payments of all enabled states until the
+// channel is closed.
+func (c *Channel) trackUpdates() {
+	for {
+		select {
+		case state := <-c.updates:
+			c.onUpdate(state)
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Channel) onUpdate(state *channel.State) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	bal := state.OfParts[c.Idx()][0]
+	if diff := new(big.Int).Sub(bal, c.bal); diff.Sign() == 1 {
+		c.received.Add(c.received, diff)
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+	}
+	c.bal.Set(bal)
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package payment is a convenience layer on top of package client for simple
+// two-party payment channels of a single asset. It takes care of constructing
+// the proposals, allocations and update handlers of the payment app.
+//
+// The payment app's address must be set with payment.SetAppDef of package
+// apps/payment before using this package.
+package payment // import "perun.network/go-perun/client/payment"
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// Client is a client.Client that opens and accepts two-party payment
+	// channels of a single asset. Incoming channel proposals are accepted
+	// automatically if they satisfy the Config and can be retrieved with
+	// NextChannel.
+	Client struct {
+		*client.Client
+		id       peer.Identity
+		cfg      Config
+		incoming chan *Channel
+	}
+
+	// Config configures the payment channels of a Client.
+	Config struct {
+		// Account is our participant account in all channels.
+		Account wallet.Account
+		// Asset is the single asset of all channels.
+		Asset channel.Asset
+		// ChallengeDuration is the challenge duration of proposed channels and
+		// the minimal challenge duration of accepted channels.
+		ChallengeDuration uint64
+		// MaxFunding is the maximal amount that we deposit into an accepted
+		// channel.
+		MaxFunding *big.Int
+		// Timeout is the timeout of accepting a channel proposal, including its
+		// funding, and of accepting a channel update.
+		Timeout time.Duration
+	}
+)
+
+// incomingBuffer is the number of accepted channels that are buffered until
+// they are retrieved with NextChannel.
+const incomingBuffer = 16
+
+// NewClient creates a new payment Client. The arguments id, dialer, funder and
+// adjudicator are those of client.New.
+//
+// If any argument or field of cfg is nil, or if the challenge duration or
+// timeout are not positive, NewClient panics.
+func NewClient(
+	id peer.Identity,
+	dialer peer.Dialer,
+	funder channel.Funder,
+	adjudicator channel.Adjudicator,
+	cfg Config,
+) *Client {
+	if cfg.Account == nil || cfg.Asset == nil || cfg.MaxFunding == nil {
+		log.Panic("invalid nil config")
+	}
+	if cfg.ChallengeDuration == 0 {
+		log.Panic("challenge duration must be positive")
+	}
+
+	c := &Client{id: id, cfg: cfg, incoming: make(chan *Channel, incomingBuffer)}
+	policy := client.AllPolicies(
+		client.AppPolicy(payment.AppDef()),
+		assetPolicy(cfg.Asset),
+		client.ChallengeDurationPolicy(cfg.ChallengeDuration, math.MaxUint64),
+		client.MaxFundingPolicy(cfg.Asset, cfg.MaxFunding),
+	)
+	handler := client.NewPolicyHandler(policy, cfg.Account, cfg.Timeout, c.onChannel)
+	c.Client = client.New(id, dialer, handler, funder, adjudicator)
+	return c
+}
+
+// OpenPaymentChannel proposes a payment channel to the given peer, in which we
+// deposit myBal and the peer deposits theirBal. It returns the funded channel.
+func (c *Client) OpenPaymentChannel(ctx context.Context, peerAddr peer.Address, myBal, theirBal *big.Int) (*Channel, error) {
+	if myBal == nil || theirBal == nil || myBal.Sign() == -1 || theirBal.Sign() == -1 {
+		return nil, errors.New("balances must be non-negative")
+	}
+	ch, err := c.ProposeChannel(ctx, &client.ChannelProposal{
+		ChallengeDuration: c.cfg.ChallengeDuration,
+		NonceShare:        client.NewRandomNonceShare(),
+		Account:           c.cfg.Account,
+		AppDef:            payment.AppDef(),
+		InitData:          new(payment.NoData),
+		InitBals: &channel.Allocation{
+			Assets:  []channel.Asset{c.cfg.Asset},
+			OfParts: [][]channel.Bal{{new(big.Int).Set(myBal)}, {new(big.Int).Set(theirBal)}},
+		},
+		PeerAddrs: []peer.Address{c.id.Address(), peerAddr},
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "proposing payment channel")
+	}
+	return newChannel(ch, c.cfg.Timeout), nil
+}
+
+// NextChannel waits for the next payment channel that a peer opened with us
+// and returns it.
+func (c *Client) NextChannel(ctx context.Context) (*Channel, error) {
+	select {
+	case ch := <-c.incoming:
+		return ch, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "waiting for channel")
+	}
+}
+
+// onChannel is called by the proposal handler for every accepted proposal.
+func (c *Client) onChannel(ch *client.Channel, err error) {
+	if err != nil {
+		c.Log().Warnf("accepting payment channel: %v", err)
+		return
+	}
+	c.incoming <- newChannel(ch, c.cfg.Timeout)
+}
+
+// assetPolicy returns a ProposalPolicy that only accepts proposals whose single
+// asset is the given asset.
+func assetPolicy(asset channel.Asset) client.ProposalPolicy {
+	return client.ProposalPolicyFunc(func(req *client.ChannelProposalReq, _ channel.Index) error {
+		if !channel.EqualAssets(req.InitBals.Assets, []channel.Asset{asset}) {
+			return errors.New("payment channels must have the single supported asset")
+		}
+		return nil
+	})
+}
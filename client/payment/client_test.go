@@ -0,0 +1,164 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package payment_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	paymentapp "perun.network/go-perun/apps/payment"
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client/payment"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+const timeout = 5 * time.Second
+
+func init() {
+	rng := rand.New(rand.NewSource(0x9a9e))
+	paymentapp.SetAppDef(wallettest.NewRandomAddress(rng))
+}
+
+func TestPaymentChannel(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9a9e17))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var hub peertest.ConnHub
+	asset := channeltest.NewRandomAsset(rng)
+	alice, _ := newClient(rng, &hub, asset)
+	defer alice.Close()
+	bob, bobID := newClient(rng, &hub, asset)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	aliceCh, err := alice.OpenPaymentChannel(ctx, bobID.Address(), big.NewInt(100), big.NewInt(50))
+	require.NoError(t, err)
+	bobCh, err := bob.NextChannel(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, aliceCh.ID(), bobCh.ID())
+
+	require.NoError(t, aliceCh.Send(ctx, big.NewInt(30)))
+	received, err := bobCh.Receive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(30), received)
+
+	require.NoError(t, bobCh.Send(ctx, big.NewInt(10)))
+	require.NoError(t, bobCh.Send(ctx, big.NewInt(5)))
+	// Both payments can be received at once or one after the other.
+	sum := new(big.Int)
+	for sum.Cmp(big.NewInt(15)) == -1 {
+		received, err = aliceCh.Receive(ctx)
+		require.NoError(t, err)
+		sum.Add(sum, received)
+	}
+	assert.Equal(t, big.NewInt(15), sum, "sum of all payments")
+
+	assert.Error(t, bobCh.Send(ctx, big.NewInt(100)), "insufficient balance")
+	assert.Error(t, bobCh.Send(ctx, big.NewInt(0)), "zero amount")
+	assert.Equal(t, big.NewInt(85), aliceCh.Balance())
+	assert.Equal(t, big.NewInt(65), bobCh.Balance())
+
+	shortCtx, shortCancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer shortCancel()
+	_, err = bobCh.Receive(shortCtx)
+	assert.Error(t, err, "no payment")
+
+	require.NoError(t, aliceCh.Close(ctx))
+	require.NoError(t, bobCh.Close(ctx))
+	assert.Equal(t, channel.Settled, aliceCh.Phase())
+	assert.Equal(t, channel.Settled, bobCh.Phase())
+}
+
+func TestClient_Policy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9a9e18))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var hub peertest.ConnHub
+	asset := channeltest.NewRandomAsset(rng)
+	alice, _ := newClient(rng, &hub, asset)
+	defer alice.Close()
+	bob, bobID := newClient(rng, &hub, asset)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+	carol, carolID := newClient(rng, &hub, channeltest.NewRandomAsset(rng))
+	defer carol.Close()
+	go carol.Listen(hub.NewListener(carolID.Address()))
+
+	_, err := alice.OpenPaymentChannel(ctx, bobID.Address(), big.NewInt(1), big.NewInt(101))
+	assert.Error(t, err, "excessive funding")
+	_, err = alice.OpenPaymentChannel(ctx, carolID.Address(), big.NewInt(1), big.NewInt(1))
+	assert.Error(t, err, "other asset")
+	_, err = alice.OpenPaymentChannel(ctx, bobID.Address(), big.NewInt(-1), big.NewInt(1))
+	assert.Error(t, err, "negative balance")
+}
+
+func TestNewClient(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9a9e19))
+	var hub peertest.ConnHub
+	cfg := payment.Config{
+		Account:           wallettest.NewRandomAccount(rng),
+		Asset:             channeltest.NewRandomAsset(rng),
+		ChallengeDuration: 10,
+		MaxFunding:        big.NewInt(100),
+		Timeout:           time.Second,
+	}
+	id := wallettest.NewRandomAccount(rng)
+
+	c := payment.NewClient(id, hub.NewDialer(), new(nopFunder), new(nopAdjudicator), cfg)
+	assert.NoError(t, c.Close())
+	for name, invalid := range map[string]func(*payment.Config){
+		"account":            func(c *payment.Config) { c.Account = nil },
+		"asset":              func(c *payment.Config) { c.Asset = nil },
+		"max funding":        func(c *payment.Config) { c.MaxFunding = nil },
+		"challenge duration": func(c *payment.Config) { c.ChallengeDuration = 0 },
+		"timeout":            func(c *payment.Config) { c.Timeout = 0 },
+	} {
+		cfg := cfg
+		invalid(&cfg)
+		assert.Panics(t, func() {
+			payment.NewClient(id, hub.NewDialer(), new(nopFunder), new(nopAdjudicator), cfg)
+		}, name)
+	}
+}
+
+// newClient creates a payment client of the given asset on the hub.
+func newClient(rng *rand.Rand, hub *peertest.ConnHub, asset channel.Asset) (*payment.Client, peer.Identity) {
+	id := wallettest.NewRandomAccount(rng)
+	return payment.NewClient(id, hub.NewDialer(), new(nopFunder), new(nopAdjudicator), payment.Config{
+		Account:           wallettest.NewRandomAccount(rng),
+		Asset:             asset,
+		ChallengeDuration: 10,
+		MaxFunding:        big.NewInt(100),
+		Timeout:           timeout,
+	}), id
+}
+
+type (
+	nopFunder      struct{}
+	nopAdjudicator struct{}
+)
+
+func (*nopFunder) Fund(context.Context, channel.FundingReq) error { return nil }
+
+func (*nopAdjudicator) Register(_ context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	return &channel.Registered{ID: req.Params.ID(), Timeout: time.Now(), Version: req.Tx.Version}, nil
+}
+
+func (*nopAdjudicator) Withdraw(context.Context, channel.AdjudicatorReq) error { return nil }
+
+func (*nopAdjudicator) SubscribeRegistered(context.Context, *channel.Params) (channel.RegisteredSubscription, error) {
+	return nil, nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/peer"
+	perunsync "perun.network/go-perun/pkg/sync"
+)
+
+type (
+	// peerLocks serializes the channel proposals to each peer, while proposals
+	// to different peers can be exchanged concurrently. Responses are
+	// correlated to their proposals by the session ID.
+	peerLocks struct {
+		mtx   sync.Mutex
+		locks map[string]*peerLock // keyed by peer address bytes
+	}
+
+	// peerLock is the lock of a single peer. refs counts the holders and
+	// waiters, so that the lock is removed when it is not needed any more.
+	peerLock struct {
+		perunsync.Mutex
+		refs int
+	}
+)
+
+func makePeerLocks() peerLocks {
+	return peerLocks{locks: make(map[string]*peerLock)}
+}
+
+// lock locks the peer with the given address. It returns an unlock function,
+// or an error if the context is done before the lock was acquired.
+func (l *peerLocks) lock(ctx context.Context, addr peer.Address) (func(), error) {
+	key := string(addr.Bytes())
+	l.mtx.Lock()
+	pl, ok := l.locks[key]
+	if !ok {
+		pl = new(peerLock)
+		l.locks[key] = pl
+	}
+	pl.refs++
+	l.mtx.Unlock()
+
+	if !pl.TryLockCtx(ctx) {
+		l.release(key, pl)
+		return nil, errors.Wrap(ctx.Err(), "waiting for previous proposal to peer")
+	}
+	return func() {
+		pl.Unlock()
+		l.release(key, pl)
+	}, nil
+}
+
+// lockAll locks all peers with the given addresses. The peers are locked in
+// the order of their address bytes, so that concurrent calls with overlapping
+// sets of peers cannot deadlock. It returns a function that unlocks all
+// peers, or an error if the context is done before all locks were acquired.
+func (l *peerLocks) lockAll(ctx context.Context, addrs []peer.Address) (func(), error) {
+	sorted := append([]peer.Address(nil), addrs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Bytes(), sorted[j].Bytes()) < 0
+	})
+
+	unlocks := make([]func(), 0, len(sorted))
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, addr := range sorted {
+		unlock, err := l.lock(ctx, addr)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// release removes a reference to the peer's lock and deletes it if it was the
+// last one.
+func (l *peerLocks) release(key string, pl *peerLock) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if pl.refs--; pl.refs == 0 {
+		delete(l.locks, key)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestPeerLocks(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x10c5))
+	alice, bob := wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)
+	locks := makePeerLocks()
+	ctx := context.Background()
+
+	unlockAlice, err := locks.lock(ctx, alice)
+	require.NoError(t, err)
+
+	// Other peers can be locked concurrently.
+	test.AssertTerminates(t, time.Second, func() {
+		unlockBob, err := locks.lock(ctx, bob)
+		require.NoError(t, err)
+		unlockBob()
+	})
+
+	// The same peer is locked until it is unlocked.
+	shortCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = locks.lock(shortCtx, alice)
+	assert.Error(t, err)
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := locks.lock(ctx, alice)
+		assert.NoError(t, err)
+		locked <- unlock
+	}()
+	select {
+	case <-locked:
+		t.Fatal("locked twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+	unlockAlice()
+	test.AssertTerminates(t, time.Second, func() { (<-locked)() })
+	assert.Empty(t, locks.locks, "unused locks are removed")
+}
+
+func TestPeerLocks_LockAll(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x10c6))
+	bob, carol := wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)
+	locks := makePeerLocks()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Concurrent proposals to the same peers in opposite order don't deadlock.
+	const n = 1000
+	done := make(chan error, 2)
+	for _, peers := range [][]peer.Address{{bob, carol}, {carol, bob}} {
+		go func(peers []peer.Address) {
+			for i := 0; i < n; i++ {
+				unlock, err := locks.lockAll(ctx, peers)
+				if err != nil {
+					done <- err
+					return
+				}
+				unlock()
+			}
+			done <- nil
+		}(peers)
+	}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, <-done)
+	}
+	assert.Empty(t, locks.locks, "unused locks are removed")
+
+	// Failing to lock a peer unlocks the already locked peers.
+	unlockCarol, err := locks.lock(ctx, carol)
+	require.NoError(t, err)
+	shortCtx, cancelShort := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancelShort()
+	_, err = locks.lockAll(shortCtx, []peer.Address{bob, carol})
+	assert.Error(t, err)
+	unlockCarol()
+	assert.Empty(t, locks.locks, "unused locks are removed")
+}
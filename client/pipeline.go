@@ -0,0 +1,298 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/metrics"
+)
+
+// MaxPipelinedUpdates is the maximum number of updates that can be pending at
+// the same time, see Channel.UpdateByPipelined.
+const MaxPipelinedUpdates = 64
+
+const (
+	// pipelineBusyReason is the rejection reason of updates that are received
+	// while pipelined updates are pending.
+	pipelineBusyReason = "pipelined update in progress"
+	// pipelineBusyTimeout is the timeout of sending such a rejection.
+	pipelineBusyTimeout = 5 * time.Second
+)
+
+type (
+	// A PendingUpdate is an update that was proposed with
+	// Channel.UpdateByPipelined and whose response is still outstanding.
+	PendingUpdate struct {
+		// State is the proposed state. It must not be modified.
+		State *channel.State
+
+		epoch   uint64
+		resRecv *channelMsgRecv
+		done    chan struct{}
+		err     error
+	}
+
+	// updatePipeline tracks the pending updates of a channel. It is protected
+	// by the channel's machMtx.
+	updatePipeline struct {
+		pending []*PendingUpdate // in version order
+		// epoch is increased on every rollback, so that the pending updates of
+		// the rolled back epoch fail.
+		epoch uint64
+	}
+
+	// pipelineSource is the Source of the machine that stages the next
+	// pipelined update on top of the last pending state.
+	pipelineSource struct {
+		channel.Source
+		tip *channel.State
+	}
+)
+
+// UpdateByPipelined proposes the next state that results from applying update
+// to a copy of the last proposed state, like UpdateBy. In contrast to UpdateBy,
+// it does not wait for the response of the peer, so that multiple updates can
+// be pending at the same time. This way, updates like micropayments are not
+// limited to one round trip each.
+//
+// The returned PendingUpdate completes when the peer's response arrived. The
+// updates are enabled in version order. If one update is rejected or fails,
+// all later pending updates are rolled back and the channel stays at the last
+// enabled state. ctx is used to send the proposal and to wait for the
+// response.
+//
+// While updates are pending, other updates of the channel fail, including
+// incoming update requests of the peer. At most MaxPipelinedUpdates updates
+// can be pending.
+//
+// Note that the peer has our signature on all pending states. If a pending
+// update fails after it was sent, e.g., because the response timed out, the
+// peer may still hold a valid transaction on it.
+func (c *Channel) UpdateByPipelined(
+	ctx context.Context,
+	update func(*channel.State) error,
+) (*PendingUpdate, error) {
+	if ctx == nil {
+		return nil, errors.New("context must not be nil")
+	}
+	if update == nil {
+		return nil, errors.New("update function must not be nil")
+	}
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if c.forced != nil {
+		return nil, errors.New("channel was force-updated on-chain")
+	}
+	if phase := c.machine.Phase(); phase != channel.Acting {
+		return nil, errors.Errorf("channel must be in phase Acting, is in %v", phase)
+	}
+	if len(c.pipe.pending) >= MaxPipelinedUpdates {
+		return nil, errors.Errorf("there are already %d pending updates", MaxPipelinedUpdates)
+	}
+
+	tip := c.pipe.tip(c.machine.State())
+	state := tip.Clone()
+	if err := update(state); err != nil {
+		return nil, errors.WithMessage(err, "applying update")
+	}
+	state.Version++
+	up := ChannelUpdate{State: state, ActorIdx: c.machine.Idx()}
+
+	msgUpdate, err := c.signPipelined(tip, up)
+	if err != nil {
+		return nil, err
+	}
+
+	resRecv, err := c.conn.NewUpdateResRecv(state.Version)
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating update response receiver")
+	}
+	sent := time.Now()
+	if err := c.conn.Send(ctx, msgUpdate); err != nil {
+		resRecv.Close()
+		return nil, errors.WithMessage(err, "sending update")
+	}
+
+	pu := &PendingUpdate{
+		State:   state,
+		epoch:   c.pipe.epoch,
+		resRecv: resRecv,
+		done:    make(chan struct{}),
+	}
+	var prev *PendingUpdate
+	if n := len(c.pipe.pending); n > 0 {
+		prev = c.pipe.pending[n-1]
+	}
+	c.pipe.pending = append(c.pipe.pending, pu)
+
+	go func() {
+		err := c.awaitPipelined(ctx, pu, prev, sent)
+		pu.err = err
+		close(pu.done)
+	}()
+	return pu, nil
+}
+
+// signPipelined checks that up is a valid transition from the state tip and
+// returns the update message with our signature on it. The update is checked
+// by a separate machine so that the channel's machine is not staged before the
+// peer responded.
+// The machine must be locked by the caller.
+func (c *Channel) signPipelined(tip *channel.State, up ChannelUpdate) (*msgChannelUpdate, error) {
+	if !equalLocked(tip.Locked, up.State.Locked) {
+		return nil, errors.New("locked sub-allocations must not change")
+	}
+
+	m, err := channel.RestoreStateMachine(c.machine.Account(),
+		pipelineSource{Source: c.machine.StateMachine, tip: tip})
+	if err != nil {
+		return nil, errors.WithMessage(err, "creating pipeline machine")
+	}
+	if err := m.Update(up.State, up.ActorIdx); err != nil {
+		return nil, errors.WithMessage(err, "updating machine")
+	}
+	sig, err := m.Sig()
+	if err != nil {
+		return nil, errors.WithMessage(err, "signing update")
+	}
+	return &msgChannelUpdate{ChannelUpdate: up, Sig: sig}, nil
+}
+
+// awaitPipelined waits for the response of the peer to the pending update pu
+// and, after the previous pending update prev completed, enables the update.
+// If the update fails, all later pending updates are rolled back.
+func (c *Channel) awaitPipelined(ctx context.Context, pu, prev *PendingUpdate, sent time.Time) (err error) {
+	defer pu.resRecv.Close()
+
+	waitCtx, cancel := c.conn.withPeers(ctx)
+	defer cancel()
+	pidx, res := pu.resRecv.Next(waitCtx)
+	if prev != nil {
+		<-prev.done
+	}
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+	if pu.epoch != c.pipe.epoch {
+		return errors.New("update rolled back because a previous update failed")
+	}
+	defer func() {
+		if err != nil {
+			c.pipe.rollback()
+		} else {
+			c.pipe.pending = c.pipe.pending[1:]
+		}
+	}()
+
+	if res == nil {
+		if c.conn.peerClosed() {
+			return errors.New("peer disconnected while waiting for update response")
+		}
+		return newRequestTimedOutError("update response")
+	}
+	metrics.UpdateRoundTrip(time.Since(sent))
+	if rej, ok := res.(*msgChannelUpdateRej); ok {
+		return newPeerRejectedError("update", rej.Reason)
+	}
+	acc := res.(*msgChannelUpdateAcc) // safe by predicate of the updateResRecv
+
+	if err := c.machine.Update(ctx, pu.State, c.machine.Idx()); err != nil {
+		return errors.WithMessage(err, "updating machine")
+	}
+	if err := c.enablePipelined(ctx, pidx, acc); err != nil {
+		if derr := c.machine.DiscardUpdate(ctx); derr != nil {
+			// discarding update should never fail
+			err = errors.WithMessagef(derr,
+				"enabling update failed: %v, then discarding update failed", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// enablePipelined signs the staged pipelined update, adds the peer's signature
+// and enables it.
+// The machine must be locked by the caller.
+func (c *Channel) enablePipelined(ctx context.Context, pidx channel.Index, acc *msgChannelUpdateAcc) error {
+	if _, err := c.machine.Sig(ctx); err != nil {
+		return errors.WithMessage(err, "signing update")
+	}
+	if err := c.machine.AddSig(ctx, pidx, acc.Sig); err != nil {
+		return errors.WithMessage(err, "adding peer signature")
+	}
+	return c.enableNotifyUpdate(ctx)
+}
+
+// Done returns a channel that is closed when the update completed.
+func (u *PendingUpdate) Done() <-chan struct{} {
+	return u.done
+}
+
+// Err returns nil if the update was accepted and enabled and the reason of the
+// failure otherwise. It must only be called after Done is closed.
+func (u *PendingUpdate) Err() error {
+	return u.err
+}
+
+// Wait waits until the update completed and returns its error, see Err. If ctx
+// is done before, the context's error is returned, but the update continues.
+func (u *PendingUpdate) Wait(ctx context.Context) error {
+	select {
+	case <-u.done:
+		return u.err
+	case <-ctx.Done():
+		return errors.WithMessage(ctx.Err(), "waiting for pending update")
+	}
+}
+
+// tip returns the state of the last pending update, or current if there is
+// none.
+func (p *updatePipeline) tip(current *channel.State) *channel.State {
+	if n := len(p.pending); n > 0 {
+		return p.pending[n-1].State
+	}
+	return current
+}
+
+// busy returns an error if there are pending updates.
+func (p *updatePipeline) busy() error {
+	if n := len(p.pending); n > 0 {
+		return errors.Errorf("%d pipelined updates pending", n)
+	}
+	return nil
+}
+
+// rollback fails all pending updates. Their response receivers are closed, so
+// that they do not wait for responses that the peer never sends.
+func (p *updatePipeline) rollback() {
+	for _, pu := range p.pending {
+		pu.resRecv.Close()
+	}
+	p.pending = nil
+	p.epoch++
+}
+
+// CurrentTX returns the last pending state as current transaction.
+func (s pipelineSource) CurrentTX() channel.Transaction {
+	return channel.Transaction{State: s.tip}
+}
+
+// StagingTX returns an empty transaction.
+func (s pipelineSource) StagingTX() channel.Transaction {
+	return channel.Transaction{}
+}
+
+// Phase returns channel.Acting.
+func (s pipelineSource) Phase() channel.Phase {
+	return channel.Acting
+}
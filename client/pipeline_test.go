@@ -0,0 +1,163 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_UpdateByPipelined(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x919e))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+
+	// Bob rejects version 8 once. He waits for gate before responding to
+	// version 6, so that all updates of the rollback test are pending. The
+	// versions that he accepted are sent on accepted.
+	rejected := false
+	gate := make(chan struct{})
+	accepted := make(chan uint64, 16)
+	go bobCh.ListenUpdates(client.UpdateHandlerFunc(func(up client.ChannelUpdate, res *client.UpdateResponder) {
+		if up.State.Version == 6 {
+			<-gate
+		}
+		if up.State.Version == 8 && !rejected {
+			rejected = true
+			assert.NoError(t, res.Reject(ctx, "not now"))
+			return
+		}
+		assert.NoError(t, res.Accept(ctx))
+		accepted <- up.State.Version
+	}))
+
+	transfer := func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(1))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(1))
+		return nil
+	}
+	pipeline := func(n int) []*client.PendingUpdate {
+		pus := make([]*client.PendingUpdate, n)
+		for i := range pus {
+			var err error
+			pus[i], err = ch.UpdateByPipelined(ctx, transfer)
+			require.NoError(t, err)
+		}
+		return pus
+	}
+	// waitAccepted waits until Bob accepted the given version.
+	waitAccepted := func(version uint64) {
+		for {
+			select {
+			case v := <-accepted:
+				if v == version {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatalf("Bob did not accept version %d", version)
+			}
+		}
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		pus := pipeline(5)
+		for i, pu := range pus {
+			assert.Equal(t, uint64(i+1), pu.State.Version)
+			require.NoError(t, pu.Wait(ctx))
+		}
+		assert.Equal(t, uint64(5), ch.State().Version)
+		assertLedgerBals(t, ch.State(), 95, 105, 0)
+		waitAccepted(5)
+		assert.Equal(t, uint64(5), bobCh.State().Version)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		pus := pipeline(5) // versions 6 to 10
+		close(gate)
+		require.NoError(t, pus[0].Wait(ctx))
+		require.NoError(t, pus[1].Wait(ctx))
+		assert.Error(t, pus[2].Wait(ctx), "rejected update")
+		for _, pu := range pus[3:] {
+			assert.Error(t, pu.Wait(ctx), "rolled back update")
+		}
+		assert.Equal(t, channel.Acting, ch.Phase())
+		assert.Equal(t, uint64(7), ch.State().Version)
+		assertLedgerBals(t, ch.State(), 93, 107, 0)
+
+		// The channel can be updated again after the rollback.
+		pu, err := ch.UpdateByPipelined(ctx, transfer)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(8), pu.State.Version)
+		require.NoError(t, pu.Wait(ctx))
+		require.NoError(t, ch.UpdateBy(ctx, transfer))
+		assert.Equal(t, uint64(9), ch.State().Version)
+		waitAccepted(9)
+	})
+}
+
+func TestChannel_UpdateWhilePipelined(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x919f))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Bob doesn't handle Alice's updates yet, so that they stay pending.
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+	go ch.ListenUpdates(acceptAllUpdates{t})
+
+	_, err := ch.UpdateByPipelined(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(1))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(1))
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Bob's update conflicts with Alice's pending update, so she rejects it.
+	err = bobCh.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[1][0].Sub(state.OfParts[1][0], big.NewInt(1))
+		state.OfParts[0][0].Add(state.OfParts[0][0], big.NewInt(1))
+		return nil
+	})
+	var rej *client.PeerRejectedError
+	require.True(t, errors.As(err, &rej), "rejected update: %v", err)
+	assert.Equal(t, "pipelined update in progress", rej.Reason)
+	assert.Equal(t, uint64(0), bobCh.State().Version)
+}
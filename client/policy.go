@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+type (
+	// A ProposalPolicy decides automatically whether an incoming channel
+	// proposal is acceptable.
+	ProposalPolicy interface {
+		// Check returns nil if the proposal is acceptable. Otherwise, it returns
+		// an error that is sent to the proposer as rejection reason. idx is our
+		// index in the proposal's participants.
+		Check(req *ChannelProposalReq, idx channel.Index) error
+	}
+
+	// ProposalPolicyFunc is an adapter to use an ordinary function as a
+	// ProposalPolicy.
+	ProposalPolicyFunc func(*ChannelProposalReq, channel.Index) error
+
+	// PolicyHandler is a ProposalHandler that accepts or rejects all incoming
+	// channel proposals according to a ProposalPolicy.
+	PolicyHandler struct {
+		policy    ProposalPolicy
+		acc       wallet.Account
+		timeout   time.Duration
+		onChannel func(*Channel, error)
+	}
+
+	// allPolicies is a ProposalPolicy that requires all of its policies to
+	// accept a proposal.
+	allPolicies []ProposalPolicy
+)
+
+// proposeeIdx is the index of the receiver of a proposal in the two-party
+// channel proposal protocol.
+const proposeeIdx channel.Index = 1
+
+var _ ProposalHandler = (*PolicyHandler)(nil)
+
+// Check calls f(req, idx).
+func (f ProposalPolicyFunc) Check(req *ChannelProposalReq, idx channel.Index) error {
+	return f(req, idx)
+}
+
+// NewPolicyHandler creates a new ProposalHandler that checks all incoming
+// proposals with the given policy. Acceptable proposals are accepted with
+// account acc, all others are rejected. Accepting a proposal includes funding
+// the channel, which has to finish within the given timeout. onChannel is
+// called with the resulting channel or the error of every accepted proposal.
+//
+// If any argument is nil or the timeout is not positive, NewPolicyHandler
+// panics.
+func NewPolicyHandler(
+	policy ProposalPolicy,
+	acc wallet.Account,
+	timeout time.Duration,
+	onChannel func(*Channel, error),
+) *PolicyHandler {
+	if policy == nil || acc == nil || onChannel == nil {
+		log.Panic("invalid nil argument")
+	}
+	if timeout <= 0 {
+		log.Panic("timeout must be positive")
+	}
+	return &PolicyHandler{
+		policy:    policy,
+		acc:       acc,
+		timeout:   timeout,
+		onChannel: onChannel,
+	}
+}
+
+// Handle checks the proposal with the handler's policy and accepts or rejects
+// it accordingly.
+func (h *PolicyHandler) Handle(req *ChannelProposalReq, res *ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if err := h.policy.Check(req, proposeeIdx); err != nil {
+		if err := res.Reject(ctx, err.Error()); err != nil {
+			log.Warnf("rejecting proposal: %v", err)
+		}
+		return
+	}
+	h.onChannel(res.Accept(ctx, ProposalAcc{Participant: h.acc}))
+}
+
+// AllPolicies returns a ProposalPolicy that accepts a proposal if all given
+// policies accept it. The rejection reason is that of the first rejecting
+// policy.
+func AllPolicies(policies ...ProposalPolicy) ProposalPolicy {
+	return allPolicies(policies)
+}
+
+// Check checks the proposal with all policies.
+func (ps allPolicies) Check(req *ChannelProposalReq, idx channel.Index) error {
+	for _, p := range ps {
+		if err := p.Check(req, idx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AppPolicy returns a ProposalPolicy that only accepts proposals with one of
+// the given app definitions.
+func AppPolicy(appDefs ...wallet.Address) ProposalPolicy {
+	return ProposalPolicyFunc(func(req *ChannelProposalReq, _ channel.Index) error {
+		for _, def := range appDefs {
+			if req.AppDef.Equals(def) {
+				return nil
+			}
+		}
+		return errors.Errorf("app %v not accepted", req.AppDef)
+	})
+}
+
+// PeerPolicy returns a ProposalPolicy that only accepts proposals whose
+// participants all are one of the given peers, except for ourselves.
+func PeerPolicy(peers ...wallet.Address) ProposalPolicy {
+	return ProposalPolicyFunc(func(req *ChannelProposalReq, idx channel.Index) error {
+	loop:
+		for i, addr := range req.PeerAddrs {
+			if channel.Index(i) == idx {
+				continue
+			}
+			for _, p := range peers {
+				if addr.Equals(p) {
+					continue loop
+				}
+			}
+			return errors.Errorf("peer %v not accepted", addr)
+		}
+		return nil
+	})
+}
+
+// ChallengeDurationPolicy returns a ProposalPolicy that only accepts proposals
+// whose challenge duration lies within [min, max].
+func ChallengeDurationPolicy(min, max uint64) ProposalPolicy {
+	return ProposalPolicyFunc(func(req *ChannelProposalReq, _ channel.Index) error {
+		if req.ChallengeDuration < min || req.ChallengeDuration > max {
+			return errors.Errorf("challenge duration %d not within [%d, %d]",
+				req.ChallengeDuration, min, max)
+		}
+		return nil
+	})
+}
+
+// MaxFundingPolicy returns a ProposalPolicy that only accepts proposals in
+// which we fund at most max of the given asset, respecting the funding
+// agreement, if any. Other assets are not checked.
+func MaxFundingPolicy(asset channel.Asset, max *big.Int) ProposalPolicy {
+	return ProposalPolicyFunc(func(req *ChannelProposalReq, idx channel.Index) error {
+		deposits := req.Deposits()
+		for i, a := range req.InitBals.Assets {
+			if equalEncoding(a, asset) && deposits[idx][i].Cmp(max) > 0 {
+				return errors.Errorf("funding of asset %d exceeds %v", i, max)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"perun.network/go-perun/channel"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestPolicies(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011c1))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	req.ChallengeDuration = 60
+	other := wallettest.NewRandomAddress(rng)
+	ourBal := req.InitBals.OfParts[proposeeIdx][0]
+
+	tests := []struct {
+		name   string
+		policy ProposalPolicy
+		accept bool
+	}{
+		{"app", AppPolicy(other, req.AppDef), true},
+		{"wrong app", AppPolicy(other), false},
+		// Our own address is not checked.
+		{"peer", PeerPolicy(req.PeerAddrs[0]), true},
+		{"wrong peer", PeerPolicy(other, req.PeerAddrs[1]), false},
+		{"challenge duration", ChallengeDurationPolicy(60, 60), true},
+		{"short challenge duration", ChallengeDurationPolicy(61, 100), false},
+		{"long challenge duration", ChallengeDurationPolicy(0, 59), false},
+		{"funding", MaxFundingPolicy(req.InitBals.Assets[0], ourBal), true},
+		{"excessive funding", MaxFundingPolicy(req.InitBals.Assets[0], new(big.Int).Sub(ourBal, big.NewInt(1))), false},
+		{"all", AllPolicies(AppPolicy(req.AppDef), ChallengeDurationPolicy(0, 60)), true},
+		{"not all", AllPolicies(AppPolicy(req.AppDef), ChallengeDurationPolicy(0, 59)), false},
+		{"none", AllPolicies(), true},
+	}
+
+	for _, tt := range tests {
+		err := tt.policy.Check(req, proposeeIdx)
+		if tt.accept {
+			assert.NoError(t, err, tt.name)
+		} else {
+			assert.Error(t, err, tt.name)
+		}
+	}
+}
+
+func TestNewPolicyHandler(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011c2))
+	acc := wallettest.NewRandomAccount(rng)
+	policy := AllPolicies()
+	onChannel := func(*Channel, error) {}
+
+	assert.NotPanics(t, func() { NewPolicyHandler(policy, acc, time.Second, onChannel) })
+	assert.Panics(t, func() { NewPolicyHandler(nil, acc, time.Second, onChannel) })
+	assert.Panics(t, func() { NewPolicyHandler(policy, nil, time.Second, onChannel) })
+	assert.Panics(t, func() { NewPolicyHandler(policy, acc, 0, onChannel) })
+	assert.Panics(t, func() { NewPolicyHandler(policy, acc, time.Second, nil) })
+}
+
+func TestMaxFundingPolicy_OtherAsset(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011c3))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	var asset channel.Asset = wallettest.NewRandomAddress(rng)
+	assert.NoError(t, MaxFundingPolicy(asset, big.NewInt(0)).Check(req, proposeeIdx))
+}
+
+func TestMaxFundingPolicy_FundingAgreement(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9011c4))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	req.FundingAgreement = singleFunderAgreement(req.InitBals, proposeeIdx)
+	asset, sum := req.InitBals.Assets[0], req.InitBals.Sum()[0]
+	assert.NoError(t, MaxFundingPolicy(asset, sum).Check(req, proposeeIdx))
+	assert.Error(t, MaxFundingPolicy(asset, new(big.Int).Sub(sum, big.NewInt(1))).Check(req, proposeeIdx))
+	assert.NoError(t, MaxFundingPolicy(asset, big.NewInt(0)).Check(req, 1-proposeeIdx))
+}
@@ -7,18 +7,23 @@ package client
 
 import (
 	"context"
-	"math/big"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/channel"
 	"perun.network/go-perun/log"
+	"perun.network/go-perun/metrics"
 	"perun.network/go-perun/peer"
 	"perun.network/go-perun/pkg/sync/atomic"
 	"perun.network/go-perun/wallet"
 	wire "perun.network/go-perun/wire/msg"
 )
 
+// proposalAbortTimeout is the timeout for sending a ChannelProposalAbort.
+const proposalAbortTimeout = 5 * time.Second
+
 type (
 	// ChannelProposal contains all data necessary to propose a new
 	// channel to a given set of peers.
@@ -27,12 +32,22 @@ type (
 	// the address of the proposer. ChannelProposal is not sent over the wire.
 	ChannelProposal struct {
 		ChallengeDuration uint64
-		Nonce             *big.Int
+		NonceShare        NonceShare     // proposer's share of the channel nonce, see NewRandomNonceShare
 		Account           wallet.Account // local account to use when creating this channel
 		AppDef            wallet.Address
 		InitData          channel.Data
 		InitBals          *channel.Allocation
 		PeerAddrs         []wallet.Address // Perun addresses of all peers, including the proposer's
+		// FundingAgreement optionally sets the amount of each asset that each
+		// participant deposits, indexed like InitBals.OfParts. This way, e.g., a
+		// merchant can fund the whole channel while the initial balances are
+		// split. The sums of all assets must match the initial balances. If it
+		// is nil, every participant deposits its initial balances. Channels
+		// funded by a parent cannot have a funding agreement.
+		//
+		// The agreement is not persisted, so that a channel with an agreement
+		// must not be restored in the Funding phase.
+		FundingAgreement [][]channel.Bal
 	}
 
 	// A ProposalHandler decides how to handle incoming channel proposals from
@@ -52,6 +67,25 @@ type (
 		peer   *peer.Peer
 		req    *ChannelProposalReq
 		called atomic.Bool
+
+		stopAbortCache context.CancelFunc // stops caching an early abort
+	}
+
+	// proposalMsg is a channel proposal wire message, i.e., a
+	// ChannelProposalReq, VirtualChannelProposalReq or SubChannelProposalReq.
+	proposalMsg interface {
+		wire.Msg
+		SessID() SessionID
+		base() *ChannelProposalReq
+	}
+
+	// proposalAbort watches for the ChannelProposalAbort of an accepted
+	// proposal and cancels ctx when it arrives.
+	proposalAbort struct {
+		ctx      context.Context
+		cancel   context.CancelFunc
+		receiver *peer.Receiver
+		aborted  atomic.Bool
 	}
 
 	// ProposalAcc is the proposal acceptance struct that the user passes to
@@ -77,6 +111,7 @@ func (r *ProposalResponder) Accept(ctx context.Context, acc ProposalAcc) (*Chann
 		log.Panic("nil context")
 	}
 
+	defer r.stopAbortCache()
 	return r.client.handleChannelProposalAcc(ctx, r.peer, r.req, acc)
 }
 
@@ -91,6 +126,7 @@ func (r *ProposalResponder) Reject(ctx context.Context, reason string) error {
 		log.Panic("nil context")
 	}
 
+	defer r.stopAbortCache()
 	return r.client.handleChannelProposalRej(ctx, r.peer, r.req, reason)
 }
 
@@ -101,37 +137,59 @@ func (r *ProposalResponder) Reject(ctx context.Context, reason string) error {
 // - the channel controller is returned.
 // The user is required to start the update handler with
 // Channel.ListenUpdates(UpdateHandler)
+//
+// If some peers fail to fund the channel in time, a channel.FundingTimeoutError
+// is returned together with the unfunded channel, whose deposits can be
+// recovered with Channel.RecoverDeposit.
+//
+// The progress of the funding is emitted as FundingProgressed events, see
+// OnEvent. A stuck funding can be aborted by cancelling ctx. The funder then
+// stops waiting for the missing deposits and the FundingTimeoutError lists
+// them.
 func (c *Client) ProposeChannel(ctx context.Context, prop *ChannelProposal) (*Channel, error) {
 	if ctx == nil || prop == nil {
 		c.log.Panic("invalid nil argument")
 	}
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
 
 	// 1. check valid proposal
 	req := prop.AsReq()
-	if err := c.validTwoPartyProposal(req, 0, req.PeerAddrs[1]); err != nil {
+	if len(req.PeerAddrs) == 0 || !c.isIdentity(req.PeerAddrs[0]) {
+		return nil, errors.New("invalid channel proposal: proposer is none of our identities")
+	}
+	if err := c.validLedgerProposal(req, req.PeerAddrs[0]); err != nil {
 		return nil, errors.WithMessage(err, "invalid channel proposal")
 	}
 
 	// 2. send proposal and wait for response
-	parts, err := c.exchangeTwoPartyProposal(ctx, req)
+	var params *channel.Params
+	var err error
+	if len(req.PeerAddrs) == 2 {
+		params, err = c.exchangeTwoPartyProposal(ctx, req)
+	} else {
+		params, err = c.exchangeMultiPartyProposal(ctx, req)
+	}
 	if err != nil {
 		return nil, errors.WithMessage(err, "sending proposal")
 	}
 
-	// 3. create params, channel machine from gathered participant addresses
+	// 3. create channel machine from the agreed parameters
 	// 4. fund channel
 	// 5. return controller on successful funding
-	return c.setupChannel(ctx, prop, parts)
+	return c.setupChannel(ctx, prop, params)
 }
 
-// This function is called during the setup of new peers by the registry. The
-// passed peer is not yet receiving any messages, thus, subscription is
-// race-free. After the function returns, the peer starts receiving messages.
-func (c *Client) subChannelProposals(p *peer.Peer) {
+// This function is called during the setup of new peers of our identity ident
+// by its registry. The passed peer is not yet receiving any messages, thus,
+// subscription is race-free. After the function returns, the peer starts
+// receiving messages.
+func (c *Client) subChannelProposals(ident *identity, p *peer.Peer) {
 	proposalReceiver := peer.NewReceiver()
 	if err := p.Subscribe(proposalReceiver,
-		func(m wire.Msg) bool { return m.Type() == wire.ChannelProposal },
-	); err != nil {
+		wire.OfType(wire.ChannelProposal, wire.VirtualChannelProposal, wire.SubChannelProposal)); err != nil {
 		c.logPeer(p).Errorf("failed to subscribe to channel proposals on new peer: %v", err)
 		proposalReceiver.Close()
 		return
@@ -152,30 +210,62 @@ func (c *Client) subChannelProposals(p *peer.Peer) {
 				c.logPeer(p).Debug("proposal subscription closed")
 				return
 			}
-			proposal := m.(*ChannelProposalReq) // safe because that's the predicate
-			go c.handleChannelProposal(p, proposal)
+			if !c.admitProposal(p, m.(proposalMsg)) {
+				continue
+			}
+			switch proposal := m.(type) { // safe because that's the predicate
+			case *ChannelProposalReq:
+				go c.handleChannelProposal(ident, p, proposal)
+			case *VirtualChannelProposalReq:
+				go c.handleVirtualChannelProposal(ident, p, proposal)
+			case *SubChannelProposalReq:
+				go c.handleSubChannelProposal(ident, p, proposal)
+			}
 		}
 	}()
 }
 
-// handleChannelProposal implements the receiving side of the (currently)
-// two-party channel proposal protocol.
+// handleChannelProposal implements the receiving side of the channel proposal
+// protocol for our identity ident, which received the proposal.
 // The proposer is expected to be the first peer in the participant list.
-func (c *Client) handleChannelProposal(p *peer.Peer, req *ChannelProposalReq) {
-	if err := c.validTwoPartyProposal(req, 1, p.PerunAddress); err != nil {
+func (c *Client) handleChannelProposal(ident *identity, p *peer.Peer, req *ChannelProposalReq) {
+	if c.shutdown.isStopping() {
+		c.rejectShutdown(p, req)
+		return
+	}
+	if err := c.validLedgerProposal(req, p.PerunAddress); err != nil {
 		c.logPeer(p).Debugf("received invalid channel proposal: %v", err)
 		return
 	}
+	if wallet.IndexOfAddr(req.PeerAddrs, ident.id.Address()) == -1 {
+		c.logPeer(p).Debugf("received channel proposal for another identity than %v", ident.id.Address())
+		return
+	}
+	if err := c.checkFunds(req); err != nil {
+		c.logPeer(p).Debugf("rejecting channel proposal: %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), proposalAbortTimeout)
+		defer cancel()
+		if err := c.handleChannelProposalRej(ctx, p, req, err.Error()); err != nil {
+			c.logPeer(p).Warnf("rejecting channel proposal: %v", err)
+		}
+		return
+	}
 
 	c.logPeer(p).Trace("calling proposal handler")
-	responder := &ProposalResponder{client: c, peer: p, req: req}
-	c.propHandler.Handle(req, responder)
+	responder := &ProposalResponder{client: c, peer: p, req: req,
+		stopAbortCache: cacheProposalAbort(p, req.SessID())}
+	ident.handler.Handle(req, responder)
 }
 
 func (c *Client) handleChannelProposalAcc(
 	ctx context.Context, p *peer.Peer,
 	req *ChannelProposalReq, acc ProposalAcc,
 ) (*Channel, error) {
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
+
 	if acc.Participant == nil {
 		c.logPeer(p).Error("user returned nil Participant in ProposalAcc")
 		return nil, errors.New("nil Participant in ProposalAcc")
@@ -186,22 +276,73 @@ func (c *Client) handleChannelProposalAcc(
 	// yet so the cache predicate is coarser than the later subscription.
 	enableVer0Cache(ctx, p)
 
-	msgAccept := &ChannelProposalAcc{
+	msgAccept := newProposalAcc(req, acc.Participant.Address())
+	var partsRecv *proposalPartsRecv
+	if len(req.PeerAddrs) > 2 {
+		var err error
+		if partsRecv, err = newProposalPartsRecv(p, msgAccept.SessID); err != nil {
+			return nil, err
+		}
+		defer partsRecv.Close()
+	}
+	abort, err := c.sendProposalAcc(ctx, p, msgAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer abort.stop()
+
+	params := req.params(msgAccept)
+	if partsRecv != nil {
+		_, ourIdx := c.ourIdentity(req.PeerAddrs)
+		if params, err = partsRecv.params(abort.ctx, req, msgAccept, ourIdx); err != nil {
+			return nil, abort.wrap(errors.WithMessage(err, "receiving participants"))
+		}
+	}
+
+	ch, err := c.setupChannel(abort.ctx, req.AsProp(acc.Participant), params)
+	return ch, abort.wrap(err)
+}
+
+// newProposalAcc creates the acceptance of the proposal with the given
+// participant and a random nonce share. For two-party channels, it contains
+// the ID of the channel that the acceptor derives, so that the proposer can
+// check it.
+func newProposalAcc(req proposalMsg, participant wallet.Address) *ChannelProposalAcc {
+	acc := &ChannelProposalAcc{
 		SessID:          req.SessID(),
-		ParticipantAddr: acc.Participant.Address(),
+		ParticipantAddr: participant,
+		NonceShare:      NewRandomNonceShare(),
+	}
+	if len(req.base().PeerAddrs) == 2 {
+		acc.ChannelID = req.base().params(acc).ID()
+	}
+	return acc
+}
+
+// sendProposalAcc sends the acceptance to the proposer p. Before, it starts
+// watching for an abort of the proposal, whose context should be used for the
+// channel setup.
+func (c *Client) sendProposalAcc(
+	ctx context.Context, p *peer.Peer,
+	msgAccept *ChannelProposalAcc,
+) (*proposalAbort, error) {
+	abort, err := c.watchProposalAbort(ctx, p, msgAccept.SessID)
+	if err != nil {
+		return nil, err
 	}
+
 	if err := p.Send(ctx, msgAccept); err != nil {
+		abort.stop()
 		c.logPeer(p).Errorf("error sending proposal acceptance: %v", err)
 		return nil, errors.WithMessage(err, "sending proposal acceptance")
 	}
-
-	parts := []wallet.Address{req.ParticipantAddr, acc.Participant.Address()}
-	return c.setupChannel(ctx, req.AsProp(acc.Participant), parts)
+	metrics.ProposalAccepted()
+	return abort, nil
 }
 
 func (c *Client) handleChannelProposalRej(
 	ctx context.Context, p *peer.Peer,
-	req *ChannelProposalReq, reason string,
+	req proposalMsg, reason string,
 ) error {
 	msgReject := &ChannelProposalRej{
 		SessID: req.SessID(),
@@ -211,16 +352,27 @@ func (c *Client) handleChannelProposalRej(
 		c.logPeer(p).Warn("error sending proposal rejection")
 		return err
 	}
+	metrics.ProposalRejected()
 	return nil
 }
 
 // exchangeTwoPartyProposal implements the multi-party channel proposal
-// protocol for the two-party case.
+// protocol for the two-party case and returns the parameters of the new
+// channel. Proposals to the same peer are exchanged one after another,
+// proposals to different peers concurrently.
 func (c *Client) exchangeTwoPartyProposal(
 	ctx context.Context,
-	proposal *ChannelProposalReq,
-) ([]wallet.Address, error) {
-	p, err := c.peers.Get(ctx, proposal.PeerAddrs[1])
+	proposal proposalMsg,
+) (*channel.Params, error) {
+	req := proposal.base()
+	unlock, err := c.propLocks.lock(ctx, req.PeerAddrs[1])
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	ident, _ := c.ourIdentity(req.PeerAddrs[:1])
+	p, err := ident.peers.Get(ctx, req.PeerAddrs[1])
 	if err != nil {
 		return nil, errors.WithMessage(err, "failed to Get() participant[1]")
 	}
@@ -230,6 +382,25 @@ func (c *Client) exchangeTwoPartyProposal(
 	// yet so the cache predicate is coarser than the later subscription.
 	enableVer0Cache(ctx, p)
 
+	acc, err := c.proposeToPeer(ctx, p, proposal)
+	if err != nil {
+		return nil, err
+	}
+	params := req.params(acc)
+	if params.ID() != acc.ChannelID {
+		c.abortProposal(p, proposal.SessID(), "channel ID mismatch")
+		return nil, newChannelIDMismatchError(params.ID(), acc.ChannelID)
+	}
+	metrics.ProposalAccepted()
+	return params, nil
+}
+
+// proposeToPeer sends the proposal to peer p and waits for its response. It
+// returns the verified acceptance or an error if the peer rejects the
+// proposal or does not respond in time. Invalid and late acceptances are
+// aborted.
+func (c *Client) proposeToPeer(ctx context.Context, p *peer.Peer, proposal proposalMsg) (*ChannelProposalAcc, error) {
+	req := proposal.base()
 	sessID := proposal.SessID()
 	isResponse := func(m wire.Msg) bool {
 		return (m.Type() == wire.ChannelProposalAcc &&
@@ -250,14 +421,143 @@ func (c *Client) exchangeTwoPartyProposal(
 
 	_, rawResponse := receiver.Next(ctx)
 	if rawResponse == nil {
-		return nil, errors.New("timeout when waiting for proposal response")
+		c.abortProposal(p, sessID, "timeout")
+		return nil, newRequestTimedOutError("proposal response")
 	}
 	if rej, ok := rawResponse.(*ChannelProposalRej); ok {
-		return nil, errors.Errorf("channel proposal rejected: %v", rej.Reason)
+		metrics.ProposalRejected()
+		return nil, newPeerRejectedError("channel proposal", rej.Reason)
 	}
 
 	acc := rawResponse.(*ChannelProposalAcc) // this is safe because of predicate isResponse
-	return []wallet.Address{proposal.ParticipantAddr, acc.ParticipantAddr}, nil
+	if err := verifyProposalAcc(req, sessID, acc); err != nil {
+		c.abortProposal(p, sessID, err.Error())
+		return nil, errors.WithMessage(err, "invalid proposal acceptance")
+	}
+	return acc, nil
+}
+
+// abortProposal sends a ChannelProposalAbort for the proposal with the given
+// session ID to the peer, so that it doesn't wait for the channel funding in
+// case it accepted the proposal. Since the context of the proposal is usually
+// done already, the abort message is sent with a fresh timeout. Peers that don't
+// support aborts aren't notified.
+func (c *Client) abortProposal(p *peer.Peer, sessID SessionID, reason string) {
+	if !p.Capabilities().Has(wire.CapProposalAbort) {
+		c.logPeer(p).Debug("peer does not support proposal aborts")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), proposalAbortTimeout)
+	defer cancel()
+	if err := p.Send(ctx, &ChannelProposalAbort{SessID: sessID, Reason: reason}); err != nil {
+		c.logPeer(p).Warnf("error sending proposal abort: %v", err)
+	}
+}
+
+// watchProposalAbort subscribes to the ChannelProposalAbort of the proposal
+// with the given session ID from peer p. The context of the returned
+// proposalAbort is derived from ctx and canceled if the proposer aborts. It
+// must be stopped once the channel is set up.
+func (c *Client) watchProposalAbort(ctx context.Context, p *peer.Peer, sessID SessionID) (*proposalAbort, error) {
+	a := &proposalAbort{receiver: peer.NewReceiver()}
+	a.ctx, a.cancel = context.WithCancel(ctx)
+	if err := p.Subscribe(a.receiver, isProposalAbort(sessID)); err != nil {
+		a.stop()
+		return nil, errors.WithMessagef(err, "subscribing peer %v", p)
+	}
+
+	go func() {
+		if _, m := a.receiver.Next(a.ctx); m != nil {
+			c.logPeer(p).Warnf("Proposal aborted by peer: %s", m.(*ChannelProposalAbort).Reason)
+			a.aborted.Set()
+			a.cancel()
+		}
+	}()
+	return a, nil
+}
+
+// cacheProposalAbort caches the ChannelProposalAbort of the proposal with the
+// given session ID from peer p, which might arrive before the user responds to
+// the proposal. The returned function stops the caching. Since it is called
+// when the user responds, the cache predicate leaks if the user never does.
+func cacheProposalAbort(p *peer.Peer, sessID SessionID) context.CancelFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.Cache(ctx, isProposalAbort(sessID))
+	return cancel
+}
+
+// isProposalAbort returns a predicate that matches the ChannelProposalAbort of
+// the proposal with the given session ID.
+func isProposalAbort(sessID SessionID) wire.Predicate {
+	return func(m wire.Msg) bool {
+		abort, ok := m.(*ChannelProposalAbort)
+		return ok && abort.SessID == sessID
+	}
+}
+
+// stop stops watching for the abort message.
+func (a *proposalAbort) stop() {
+	a.cancel()
+	a.receiver.Close() // only fails if already closed
+}
+
+// wrap adds the abortion by the peer to err, if it happened.
+func (a *proposalAbort) wrap(err error) error {
+	if err != nil && a.aborted.IsSet() {
+		return errors.WithMessage(err, "proposal aborted by peer")
+	}
+	return err
+}
+
+// verifyProposalAcc checks that the acceptance is bound to the proposal with
+// session ID sessID and that its participant is not one of the proposal's
+// addresses, which would be the case for a reflected acceptance.
+func verifyProposalAcc(req *ChannelProposalReq, sessID SessionID, acc *ChannelProposalAcc) error {
+	if acc.SessID != sessID {
+		return errors.New("session ID mismatch")
+	}
+	if acc.ParticipantAddr == nil {
+		return errors.New("missing participant")
+	}
+	if acc.ParticipantAddr.Equals(req.ParticipantAddr) {
+		return errors.New("participant is the proposer's participant")
+	}
+	return nil
+}
+
+// ChannelIDMismatchError is returned by a proposer if the channel ID that it
+// derives from the proposal and acceptance differs from the ID that the peer
+// derived. The proposal is aborted before funding.
+type ChannelIDMismatchError struct {
+	Own, Peer channel.ID
+}
+
+func (e *ChannelIDMismatchError) Error() string {
+	return fmt.Sprintf("channel ID mismatch (own: %x, peer: %x)", e.Own, e.Peer)
+}
+
+func newChannelIDMismatchError(own, peer channel.ID) error {
+	return errors.WithStack(&ChannelIDMismatchError{Own: own, Peer: peer})
+}
+
+// IsChannelIDMismatchError returns true if the error was a
+// ChannelIDMismatchError.
+func IsChannelIDMismatchError(err error) bool {
+	_, ok := errors.Cause(err).(*ChannelIDMismatchError)
+	return ok
+}
+
+// validLedgerProposal checks that the ledger channel proposal of the given
+// proposer is valid, see validTwoPartyProposal and validMultiPartyProposal.
+func (c *Client) validLedgerProposal(proposal *ChannelProposalReq, proposer wallet.Address) error {
+	if len(proposal.PeerAddrs) > 2 {
+		return c.validMultiPartyProposal(proposal, proposer)
+	} else if !c.isIdentity(proposer) {
+		return c.validTwoPartyProposal(proposal, 1, proposer)
+	} else if len(proposal.PeerAddrs) < 2 {
+		return errors.Errorf("exptected 2 peers, got %d", len(proposal.PeerAddrs))
+	}
+	return c.validTwoPartyProposal(proposal, 0, proposal.PeerAddrs[1])
 }
 
 // validTwoPartyProposal checks that the proposal is valid in the two-party
@@ -284,67 +584,112 @@ func (c *Client) validTwoPartyProposal(
 	}
 
 	// In the 2PCPP, the receiver is expected to have index 1
-	if !proposal.PeerAddrs[ourIdx].Equals(c.id.Address()) {
+	if !c.isIdentity(proposal.PeerAddrs[ourIdx]) {
 		return errors.Errorf("we don't have peer index %d", ourIdx)
 	}
 
-	return nil
+	return c.validIdentities(proposal.PeerAddrs)
 }
 
 // setupChannel sets up a new channel controller for the given proposal and
-// participant addresses, using the account for our participant. The parameters
-// are assembled and the channel controller is started. The channel will be
-// funded and if successful, the *Channel is returned. It does not perform a
-// validity check on the proposal, so make sure to only paste valid proposals.
+// parameters, using the account for our participant. The channel controller
+// is started. The channel will be funded and if successful, the *Channel is
+// returned. It does not perform a validity check on the proposal, so make sure
+// to only paste valid proposals.
 func (c *Client) setupChannel(
 	ctx context.Context,
 	prop *ChannelProposal,
-	parts []wallet.Address, // result of the MPCPP on prop
+	params *channel.Params, // result of the MPCPP on prop
+) (*Channel, error) {
+	ch, err := c.initChannel(ctx, prop, params, nil)
+	if err != nil {
+		return ch, err
+	}
+
+	c.preflightDisputeCosts(ctx, ch)
+	start := time.Now()
+	if err = c.funder.Fund(ctx,
+		c.newFundingReq(ch, prop.InitBals, prop.FundingAgreement)); channel.IsFundingTimeoutError(err) {
+		ch.log.Warnf("error while funding channel, deposits can be recovered: %v", err)
+		return ch, errors.WithMessage(err, "error while funding channel")
+	} else if err != nil { // other runtime error
+		ch.log.Warnf("error while funding channel: %v", err)
+		return ch, errors.WithMessage(err, "error while funding channel")
+	}
+	metrics.Funded(time.Since(start))
+
+	return ch, c.enableChannel(ctx, ch)
+}
+
+// initChannel creates a new channel controller for the given proposal and
+// parameters and exchanges the signatures on the initial state with all
+// peers. parent is the funding ledger channel of a virtual channel or
+// sub-channel or nil.
+// The new channel is persisted and the returned channel is in the Funding
+// phase.
+func (c *Client) initChannel(
+	ctx context.Context,
+	prop *ChannelProposal,
+	params *channel.Params, // result of the MPCPP on prop
+	parent *Channel,
 ) (*Channel, error) {
-	params := channel.NewParamsUnsafe(prop.ChallengeDuration, parts, prop.AppDef, prop.Nonce)
 	if c.channels.Has(params.ID()) {
 		return nil, errors.New("channel already exists")
 	}
 
-	peers, err := c.getPeers(ctx, prop.PeerAddrs)
+	var peers []*peer.Peer
+	var err error
+	if len(prop.PeerAddrs) > 2 && parent == nil {
+		peers, err = c.getChannelPeers(ctx, prop.PeerAddrs)
+	} else {
+		peers, err = c.getPeers(ctx, prop.PeerAddrs)
+	}
 	if err != nil {
 		return nil, errors.WithMessage(err, "getting peers from the registry")
 	}
 
-	ch, err := newChannel(prop.Account, peers, *params, c.adjudicator)
+	ch, err := newChannel(prop.Account, peers, *params, c.adjudicator, c.pr)
 	if err != nil {
 		return nil, err
 	}
 	ch.setLogger(c.logChan(params.ID()))
+	ch.vFunding = c.vFunding
+	ch.events = c.events
+	ch.parent = parent
+	ch.sub = c.isSubChannel(prop.PeerAddrs, parent)
+	ch.peerAddrs = prop.PeerAddrs
+	ch.agreement = prop.FundingAgreement
 
-	if err := ch.init(prop.InitBals, prop.InitData); err != nil {
+	var parentID *channel.ID
+	if parent != nil {
+		id := parent.ID()
+		parentID = &id
+	}
+	if err := c.pr.ChannelCreated(ctx, ch.machine.StateMachine, prop.PeerAddrs, parentID, prop.FundingAgreement); err != nil {
+		return ch, errors.WithMessage(err, "persisting new channel")
+	}
+
+	if err := ch.init(ctx, prop.InitBals, prop.InitData); err != nil {
 		return ch, errors.WithMessage(err, "setting initial bals and data")
 	}
 	if err := ch.initExchangeSigsAndEnable(ctx); err != nil {
 		return ch, errors.WithMessage(err, "exchanging initial sigs and enabling state")
 	}
+	return ch, nil
+}
 
-	if err = c.funder.Fund(ctx,
-		channel.FundingReq{
-			Params:     params,
-			Allocation: prop.InitBals,
-			Idx:        ch.machine.Idx(),
-		}); channel.IsFundingTimeoutError(err) {
-		// TODO: initiate dispute and withdrawal
-		ch.log.Warnf("error while funding channel: %v", err)
-		return ch, errors.WithMessage(err, "error while funding channel")
-	} else if err != nil { // other runtime error
-		ch.log.Warnf("error while funding channel: %v", err)
-		return ch, errors.WithMessage(err, "error while funding channel")
-	}
-
-	if err := ch.machine.SetFunded(); err != nil {
-		return ch, errors.WithMessage(err, "error in SetFunded()")
+// enableChannel sets the funded channel to the Acting phase, adds it to the
+// channel registry and starts handling sync messages.
+func (c *Client) enableChannel(ctx context.Context, ch *Channel) error {
+	if err := ch.machine.SetFunded(ctx); err != nil {
+		return errors.WithMessage(err, "error in SetFunded()")
 	}
-	if !c.channels.Put(params.ID(), ch) {
-		return ch, errors.New("channel already exists")
+	if !c.channels.Put(ch.ID(), ch) {
+		return errors.New("channel already exists")
 	}
-	return ch, nil
+	go ch.handleSyncs()
+	c.events.emit(ChannelOpened{Channel: ch})
+	return nil
 }
 
 // enableVer0Cache enables caching of incoming version 0 signatures
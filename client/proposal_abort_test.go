@@ -0,0 +1,134 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+// lateAcceptHandler accepts channel proposals only after proceed is closed.
+type lateAcceptHandler struct {
+	acc     *client.ProposalAcc
+	proceed chan struct{}
+	errs    chan error
+}
+
+func (h *lateAcceptHandler) Handle(_ *client.ChannelProposalReq, res *client.ProposalResponder) {
+	<-h.proceed
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := res.Accept(ctx, *h.acc)
+	h.errs <- err
+}
+
+func TestProposal_Abort(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5e55))
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &lateAcceptHandler{
+		acc:     &client.ProposalAcc{Participant: wallettest.NewRandomAccount(rng)},
+		proceed: make(chan struct{}),
+		errs:    make(chan error, 1),
+	}
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	_, err := alice.ProposeChannel(ctx, prop)
+	require.Error(t, err)
+	assert.True(t, client.IsRequestTimedOutError(err))
+
+	// Bob accepts after Alice aborted and must not wait for the funding.
+	close(bobHandler.proceed)
+	select {
+	case err := <-bobHandler.errs:
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "aborted")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Bob should notice the abort")
+	}
+}
+
+func TestProposal_ChannelIDMismatch(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1d1d))
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+
+	// Bob accepts with a wrong channel ID and expects Alice's abort.
+	aborts := make(chan *client.ChannelProposalAbort, 1)
+	bobPart, wrongID := wallettest.NewRandomAddress(rng), channeltest.NewRandomChannelID(rng)
+	bob := peer.NewRegistry(bobID, func(p *peer.Peer) {
+		recv := peer.NewReceiver()
+		isProp := func(m wire.Msg) bool { return m.Type() == wire.ChannelProposal }
+		isAbort := func(m wire.Msg) bool { return m.Type() == wire.ChannelProposalAbort }
+		if err := p.Subscribe(recv, func(m wire.Msg) bool { return isProp(m) || isAbort(m) }); err != nil {
+			t.Error(err)
+			return
+		}
+		go func() {
+			defer recv.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, m := recv.Next(ctx)
+			req, ok := m.(*client.ChannelProposalReq)
+			if !ok {
+				t.Errorf("expected proposal, got %v", m)
+				return
+			}
+			acc := &client.ChannelProposalAcc{
+				SessID:          req.SessID(),
+				ParticipantAddr: bobPart,
+				NonceShare:      client.NewRandomNonceShare(),
+				ChannelID:       wrongID,
+			}
+			assert.NoError(t, p.Send(ctx, acc))
+			if _, m := recv.Next(ctx); m != nil {
+				aborts <- m.(*client.ChannelProposalAbort)
+			}
+		}()
+	}, hub.NewDialer())
+	bob.SetCapabilities(wire.CapProposalAbort)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	_, err := alice.ProposeChannel(ctx, prop)
+	require.Error(t, err)
+	assert.True(t, client.IsChannelIDMismatchError(err))
+
+	select {
+	case abort := <-aborts:
+		assert.Contains(t, abort.Reason, "channel ID mismatch")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Alice should abort the proposal")
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"perun.network/go-perun/channel"
 	channeltest "perun.network/go-perun/channel/test"
 	"perun.network/go-perun/peer"
 	"perun.network/go-perun/wallet"
@@ -89,7 +91,7 @@ func newRandomValidChannelProposalReq(rng *rand.Rand, numPeers int) *ChannelProp
 	participantAddr := wallettest.NewRandomAddress(rng)
 	return &ChannelProposalReq{
 		ChallengeDuration: rng.Uint64(),
-		Nonce:             big.NewInt(rng.Int63()),
+		NonceShare:        newRandomNonceShare(rng),
 		ParticipantAddr:   participantAddr,
 		AppDef:            channeltest.NewRandomApp(rng).Def(),
 		InitData:          data,
@@ -97,3 +99,107 @@ func newRandomValidChannelProposalReq(rng *rand.Rand, numPeers int) *ChannelProp
 		PeerAddrs:         peerAddrs,
 	}
 }
+
+func TestVerifyProposalAcc(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5e55))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	sessID := req.SessID()
+
+	acc := &ChannelProposalAcc{SessID: sessID, ParticipantAddr: wallettest.NewRandomAddress(rng)}
+	require.NoError(t, verifyProposalAcc(req, sessID, acc))
+
+	mismatch := *acc
+	mismatch.SessID[0]++
+	require.Error(t, verifyProposalAcc(req, sessID, &mismatch), "session ID mismatch")
+
+	reflected := *acc
+	reflected.ParticipantAddr = req.ParticipantAddr
+	require.Error(t, verifyProposalAcc(req, sessID, &reflected), "reflected participant")
+
+	missing := *acc
+	missing.ParticipantAddr = nil
+	require.Error(t, verifyProposalAcc(req, sessID, &missing), "missing participant")
+}
+
+func TestChannelProposalReq_params(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x90ce))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	acc := &ChannelProposalAcc{
+		SessID:          req.SessID(),
+		ParticipantAddr: wallettest.NewRandomAddress(rng),
+		NonceShare:      newRandomNonceShare(rng),
+	}
+
+	params := req.params(acc)
+	assert.Equal(t, req.ChallengeDuration, params.ChallengeDuration)
+	assert.Equal(t, []wallet.Address{req.ParticipantAddr, acc.ParticipantAddr}, params.Parts)
+	assert.Equal(t, calcNonce(req.NonceShare, acc.NonceShare), params.Nonce)
+
+	// The nonce depends on the shares of both participants.
+	otherReq := *req
+	otherReq.NonceShare = newRandomNonceShare(rng)
+	assert.NotEqual(t, params.Nonce, otherReq.params(acc).Nonce)
+	otherAcc := *acc
+	otherAcc.NonceShare = newRandomNonceShare(rng)
+	assert.NotEqual(t, params.Nonce, req.params(&otherAcc).Nonce)
+}
+
+func TestNewProposalAcc(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xacc1d))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	acc := newProposalAcc(req, wallettest.NewRandomAddress(rng))
+	assert.Equal(t, req.SessID(), acc.SessID)
+	assert.Equal(t, req.params(acc).ID(), acc.ChannelID)
+}
+
+func TestChannelProposalReq_FundingAgreement(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa9ee))
+	req := newRandomValidChannelProposalReq(rng, 2)
+	sessID := req.SessID()
+	assert.Equal(t, req.InitBals.OfParts, req.Deposits())
+
+	req.FundingAgreement = singleFunderAgreement(req.InitBals, 0)
+	require.NoError(t, req.Valid())
+	assert.Equal(t, req.FundingAgreement, req.Deposits())
+	assert.NotEqual(t, sessID, req.SessID())
+
+	wrongParts := *req
+	wrongParts.FundingAgreement = req.FundingAgreement[:1]
+	assert.Error(t, wrongParts.Valid(), "wrong number of participants")
+
+	wrongAssets := *req
+	wrongAssets.FundingAgreement = [][]channel.Bal{req.FundingAgreement[0][1:], req.FundingAgreement[1]}
+	assert.Error(t, wrongAssets.Valid(), "wrong number of assets")
+
+	wrongSum := *req
+	wrongSum.FundingAgreement = singleFunderAgreement(req.InitBals, 0)
+	wrongSum.FundingAgreement[1][0] = big.NewInt(1)
+	assert.Error(t, wrongSum.Valid(), "wrong sum")
+
+	negative := *req
+	negative.FundingAgreement = singleFunderAgreement(req.InitBals, 0)
+	negative.FundingAgreement[0][0] = new(big.Int).Add(negative.FundingAgreement[0][0], big.NewInt(1))
+	negative.FundingAgreement[1][0] = big.NewInt(-1)
+	assert.Error(t, negative.Valid(), "negative balance")
+}
+
+// singleFunderAgreement returns a funding agreement in which participant idx
+// funds all assets of the allocation.
+func singleFunderAgreement(alloc *channel.Allocation, idx channel.Index) [][]channel.Bal {
+	agreement := make([][]channel.Bal, len(alloc.OfParts))
+	for i := range agreement {
+		agreement[i] = make([]channel.Bal, len(alloc.Assets))
+		for j := range agreement[i] {
+			agreement[i][j] = new(big.Int)
+		}
+	}
+	for j, sum := range alloc.Sum() {
+		agreement[idx][j] = sum
+	}
+	return agreement
+}
+
+func newRandomNonceShare(rng *rand.Rand) (share NonceShare) {
+	rng.Read(share[:])
+	return
+}
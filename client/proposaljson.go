@@ -0,0 +1,214 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	perunio "perun.network/go-perun/pkg/io"
+	"perun.network/go-perun/wallet"
+)
+
+// jsonProposal is the JSON encoding of all channel proposal requests. It uses
+// the conventions of the JSON encodings of the channel package. A
+// ChannelProposal is encoded as its ChannelProposalReq, see AsReq, since its
+// account cannot be encoded.
+type jsonProposal struct {
+	ChallengeDuration uint64              `json:"challengeDuration"`
+	NonceShare        string              `json:"nonceShare"`
+	Participant       string              `json:"participant"`
+	App               string              `json:"app"`
+	InitData          string              `json:"initData"`
+	InitBals          *channel.Allocation `json:"initBals"`
+	Peers             []string            `json:"peers"`
+	FundingAgreement  [][]string          `json:"fundingAgreement,omitempty"`
+	Parent            string              `json:"parent,omitempty"`       // sub-channels only
+	Intermediary      string              `json:"intermediary,omitempty"` // virtual channels only
+}
+
+var (
+	_ json.Marshaler   = ChannelProposalReq{}
+	_ json.Unmarshaler = (*ChannelProposalReq)(nil)
+	_ json.Marshaler   = SubChannelProposalReq{}
+	_ json.Unmarshaler = (*SubChannelProposalReq)(nil)
+	_ json.Marshaler   = VirtualChannelProposalReq{}
+	_ json.Unmarshaler = (*VirtualChannelProposalReq)(nil)
+)
+
+// MarshalJSON encodes the proposal as JSON.
+func (c ChannelProposalReq) MarshalJSON() ([]byte, error) {
+	jp, err := c.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON decodes a proposal from JSON, see MarshalJSON. The app is
+// resolved with channel.AppFromDefinition and decodes the initial data.
+func (c *ChannelProposalReq) UnmarshalJSON(data []byte) error {
+	var jp jsonProposal
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return errors.Wrap(err, "decoding proposal")
+	}
+	return c.fromJSON(&jp)
+}
+
+// MarshalJSON encodes the sub-channel proposal as JSON, which is the encoding
+// of the ChannelProposalReq with the additional field parent.
+func (s SubChannelProposalReq) MarshalJSON() ([]byte, error) {
+	jp, err := s.ChannelProposalReq.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	jp.Parent = perunio.HexBytes(s.Parent[:])
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON decodes a sub-channel proposal from JSON, see MarshalJSON.
+func (s *SubChannelProposalReq) UnmarshalJSON(data []byte) error {
+	var jp jsonProposal
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return errors.Wrap(err, "decoding sub-channel proposal")
+	}
+	parent, err := perunio.ParseHexBytes(jp.Parent)
+	if err != nil {
+		return errors.WithMessage(err, "decoding parent")
+	} else if len(parent) != len(s.Parent) {
+		return errors.Errorf("invalid parent ID length %d", len(parent))
+	}
+	copy(s.Parent[:], parent)
+	return s.ChannelProposalReq.fromJSON(&jp)
+}
+
+// MarshalJSON encodes the virtual channel proposal as JSON, which is the
+// encoding of the ChannelProposalReq with the additional field intermediary.
+func (v VirtualChannelProposalReq) MarshalJSON() ([]byte, error) {
+	jp, err := v.ChannelProposalReq.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	if jp.Intermediary, err = perunio.EncodeHex(v.Intermediary); err != nil {
+		return nil, errors.WithMessage(err, "encoding intermediary")
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON decodes a virtual channel proposal from JSON, see MarshalJSON.
+func (v *VirtualChannelProposalReq) UnmarshalJSON(data []byte) (err error) {
+	var jp jsonProposal
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return errors.Wrap(err, "decoding virtual channel proposal")
+	}
+	if v.Intermediary, err = addrFromJSON(jp.Intermediary); err != nil {
+		return errors.WithMessage(err, "decoding intermediary")
+	}
+	return v.ChannelProposalReq.fromJSON(&jp)
+}
+
+// toJSON returns the JSON encoding of the proposal.
+func (c *ChannelProposalReq) toJSON() (jp jsonProposal, err error) {
+	jp = jsonProposal{
+		ChallengeDuration: c.ChallengeDuration,
+		NonceShare:        perunio.HexBytes(c.NonceShare[:]),
+		InitBals:          c.InitBals,
+		Peers:             make([]string, len(c.PeerAddrs)),
+	}
+	if jp.Participant, err = perunio.EncodeHex(c.ParticipantAddr); err != nil {
+		return jp, errors.WithMessage(err, "encoding participant")
+	}
+	if jp.App, err = perunio.EncodeHex(c.AppDef); err != nil {
+		return jp, errors.WithMessage(err, "encoding app definition")
+	}
+	if jp.InitData, err = perunio.EncodeHex(c.InitData); err != nil {
+		return jp, errors.WithMessage(err, "encoding initial data")
+	}
+	for i, addr := range c.PeerAddrs {
+		if jp.Peers[i], err = perunio.EncodeHex(addr); err != nil {
+			return jp, errors.WithMessagef(err, "encoding peer %d", i)
+		}
+	}
+	for _, bals := range c.FundingAgreement {
+		jp.FundingAgreement = append(jp.FundingAgreement, channel.BalsToJSON(bals))
+	}
+	return jp, nil
+}
+
+// fromJSON sets the proposal to the decoded JSON encoding jp. It checks the
+// same constraints as Decode.
+func (c *ChannelProposalReq) fromJSON(jp *jsonProposal) (err error) {
+	if jp.InitBals == nil {
+		return errors.New("missing initial balances")
+	}
+	if len(jp.Peers) < 2 || len(jp.Peers) > channel.MaxNumParts {
+		return errors.Errorf("expected between 2 and %d participants, got %d",
+			channel.MaxNumParts, len(jp.Peers))
+	}
+	prop := ChannelProposalReq{
+		ChallengeDuration: jp.ChallengeDuration,
+		InitBals:          jp.InitBals,
+		PeerAddrs:         make([]wallet.Address, len(jp.Peers)),
+	}
+	share, err := perunio.ParseHexBytes(jp.NonceShare)
+	if err != nil {
+		return errors.WithMessage(err, "decoding nonce share")
+	} else if len(share) != len(prop.NonceShare) {
+		return errors.Errorf("invalid nonce share length %d", len(share))
+	}
+	copy(prop.NonceShare[:], share)
+	if prop.ParticipantAddr, err = addrFromJSON(jp.Participant); err != nil {
+		return errors.WithMessage(err, "decoding participant")
+	}
+	if prop.AppDef, err = addrFromJSON(jp.App); err != nil {
+		return errors.WithMessage(err, "decoding app definition")
+	}
+	app, err := channel.AppFromDefinition(prop.AppDef)
+	if err != nil {
+		return err
+	}
+	if err := perunio.DecodeHex(jp.InitData, func(r io.Reader) (err error) {
+		prop.InitData, err = app.DecodeData(r)
+		return err
+	}); err != nil {
+		return errors.WithMessage(err, "decoding initial data")
+	}
+	for i, s := range jp.Peers {
+		if prop.PeerAddrs[i], err = addrFromJSON(s); err != nil {
+			return errors.WithMessagef(err, "decoding peer %d", i)
+		}
+	}
+
+	if jp.FundingAgreement != nil {
+		if len(jp.FundingAgreement) != len(prop.InitBals.OfParts) {
+			return errors.New("funding agreement does not match initial balances")
+		}
+		prop.FundingAgreement = make([][]channel.Bal, len(jp.FundingAgreement))
+		for i, bals := range jp.FundingAgreement {
+			if len(bals) != len(prop.InitBals.Assets) {
+				return errors.New("funding agreement does not match initial balances")
+			}
+			if prop.FundingAgreement[i], err = channel.BalsFromJSON(bals); err != nil {
+				return errors.WithMessagef(err, "decoding funding agreement of participant %d", i)
+			}
+		}
+	}
+	*c = prop
+	return nil
+}
+
+// addrFromJSON decodes an address from the hex encoding of its binary
+// encoding.
+func addrFromJSON(s string) (addr wallet.Address, err error) {
+	err = perunio.DecodeHex(s, func(r io.Reader) error {
+		addr, err = wallet.DecodeAddress(r)
+		return err
+	})
+	return addr, err
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel/test"
+	perunio "perun.network/go-perun/pkg/io"
+	iotest "perun.network/go-perun/pkg/io/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// requireJSONRoundTrip marshals v, unmarshals it into w and checks that both
+// have the same wire encoding.
+func requireJSONRoundTrip(t *testing.T, v, w perunio.Encoder) {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, w))
+	eq, err := iotest.EqualEncoding(v, w)
+	require.NoError(t, err)
+	require.True(t, eq, "JSON round trip changed %T", v)
+}
+
+func TestChannelProposalReq_JSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1504))
+	for i := 0; i < 4; i++ {
+		req := newRandomValidChannelProposalReq(rng, 2)
+		req.InitBals = test.NewRandomAllocation(rng, 2)
+		if i%2 == 0 {
+			req.FundingAgreement = req.InitBals.Clone().OfParts
+		}
+		requireJSONRoundTrip(t, req, new(ChannelProposalReq))
+
+		sub := &SubChannelProposalReq{ChannelProposalReq: *req, Parent: test.NewRandomChannelID(rng)}
+		var decodedSub SubChannelProposalReq
+		requireJSONRoundTrip(t, sub, &decodedSub)
+		assert.Equal(t, sub.Parent, decodedSub.Parent)
+
+		virt := &VirtualChannelProposalReq{ChannelProposalReq: *req, Intermediary: wallettest.NewRandomAddress(rng)}
+		var decodedVirt VirtualChannelProposalReq
+		requireJSONRoundTrip(t, virt, &decodedVirt)
+		assert.True(t, virt.Intermediary.Equals(decodedVirt.Intermediary))
+	}
+
+	// Sub-channel proposals need a parent.
+	data, err := json.Marshal(newRandomValidChannelProposalReq(rng, 2))
+	require.NoError(t, err)
+	assert.Error(t, json.Unmarshal(data, new(SubChannelProposalReq)))
+}
@@ -6,6 +6,7 @@
 package client
 
 import (
+	"crypto/rand"
 	"golang.org/x/crypto/sha3"
 	"io"
 	"log"
@@ -36,12 +37,43 @@ func init() {
 			var m ChannelProposalRej
 			return &m, m.Decode(r)
 		})
+	msg.RegisterDecoder(msg.ChannelProposalAbort,
+		func(r io.Reader) (msg.Msg, error) {
+			var m ChannelProposalAbort
+			return &m, m.Decode(r)
+		})
 }
 
 // SessionID is a unique identifier generated for every instantiantiation of
 // a channel.
 type SessionID = [32]byte
 
+// NonceShare is the contribution of a participant to the nonce of a new
+// channel. The proposer sends its share in the proposal and the peer in its
+// acceptance. The channel nonce is the hash of all shares, so that no
+// participant, in particular not the proposer, can choose the channel ID on
+// its own.
+type NonceShare = [32]byte
+
+// NewRandomNonceShare returns a NonceShare read from crypto/rand.
+// If reading fails, NewRandomNonceShare panics.
+func NewRandomNonceShare() (share NonceShare) {
+	if _, err := rand.Read(share[:]); err != nil {
+		log.Panicf("reading nonce share: %v", err)
+	}
+	return
+}
+
+// calcNonce calculates the channel nonce from the nonce shares of all
+// participants, in participant order. It is the SHA3-256 hash of the shares.
+func calcNonce(shares ...NonceShare) *big.Int {
+	hasher := sha3.New256()
+	for _, share := range shares {
+		hasher.Write(share[:])
+	}
+	return new(big.Int).SetBytes(hasher.Sum(nil))
+}
+
 // ChannelProposalReq is the wire message that is derived from the
 // ChannelProposal.
 //
@@ -49,12 +81,13 @@ type SessionID = [32]byte
 // Multi-Party Channel Proposal Protocol (MPCPP).
 type ChannelProposalReq struct {
 	ChallengeDuration uint64
-	Nonce             *big.Int
+	NonceShare        NonceShare
 	ParticipantAddr   wallet.Address
 	AppDef            wallet.Address
 	InitData          channel.Data
 	InitBals          *channel.Allocation
 	PeerAddrs         []wallet.Address
+	FundingAgreement  [][]channel.Bal // optional, see ChannelProposal
 }
 
 // AsReq returns a shallow copy of the ChannelProposal as a ChannelProposalReq,
@@ -62,25 +95,33 @@ type ChannelProposalReq struct {
 func (c *ChannelProposal) AsReq() *ChannelProposalReq {
 	return &ChannelProposalReq{
 		ChallengeDuration: c.ChallengeDuration,
-		Nonce:             c.Nonce,
+		NonceShare:        c.NonceShare,
 		ParticipantAddr:   c.Account.Address(),
 		AppDef:            c.AppDef,
 		InitData:          c.InitData,
 		InitBals:          c.InitBals,
 		PeerAddrs:         c.PeerAddrs,
+		FundingAgreement:  c.FundingAgreement,
 	}
 }
 
+// SessID calculates the SessionID of the ChannelProposal. It is the SessionID
+// of the ChannelProposalReq that is sent for it, see ChannelProposalReq.SessID.
+func (c *ChannelProposal) SessID() SessionID {
+	return c.AsReq().SessID()
+}
+
 // AsProp returns a shallow copy of the ChannelProposalReq as a ChannelProposal.
 func (c *ChannelProposalReq) AsProp(acc wallet.Account) *ChannelProposal {
 	return &ChannelProposal{
 		ChallengeDuration: c.ChallengeDuration,
-		Nonce:             c.Nonce,
+		NonceShare:        c.NonceShare,
 		Account:           acc,
 		AppDef:            c.AppDef,
 		InitData:          c.InitData,
 		InitBals:          c.InitBals,
 		PeerAddrs:         c.PeerAddrs,
+		FundingAgreement:  c.FundingAgreement,
 	}
 }
 
@@ -95,7 +136,7 @@ func (c ChannelProposalReq) Encode(w io.Writer) error {
 		return errors.New("writer must not be nil")
 	}
 
-	if err := wire.Encode(w, c.ChallengeDuration, c.Nonce); err != nil {
+	if err := wire.Encode(w, c.ChallengeDuration, c.NonceShare); err != nil {
 		return err
 	}
 
@@ -119,16 +160,47 @@ func (c ChannelProposalReq) Encode(w io.Writer) error {
 		}
 	}
 
+	if err := wire.Encode(w, c.FundingAgreement != nil); err != nil {
+		return err
+	}
+	return errors.WithMessage(encodeBals(w, c.FundingAgreement), "encoding funding agreement")
+}
+
+// encodeBals encodes the balances of all participants. Their dimensions are
+// not encoded but are known from the initial balances.
+func encodeBals(w io.Writer, bals [][]channel.Bal) error {
+	for i := range bals {
+		for j := range bals[i] {
+			if err := wire.Encode(w, bals[i][j]); err != nil {
+				return errors.WithMessagef(err, "encoding balance %d of participant %d", j, i)
+			}
+		}
+	}
 	return nil
 }
 
+// decodeBals decodes the balances of numParts participants with numAssets
+// assets each.
+func decodeBals(r io.Reader, numParts, numAssets int) ([][]channel.Bal, error) {
+	bals := make([][]channel.Bal, numParts)
+	for i := range bals {
+		bals[i] = make([]channel.Bal, numAssets)
+		for j := range bals[i] {
+			if err := wire.Decode(r, &bals[i][j]); err != nil {
+				return nil, errors.WithMessagef(err, "decoding balance %d of participant %d", j, i)
+			}
+		}
+	}
+	return bals, nil
+}
+
 // Decode decodes a ChannelProposalRequest from an io.Reader.
 func (c *ChannelProposalReq) Decode(r io.Reader) (err error) {
 	if r == nil {
 		return errors.New("reader must not be nil")
 	}
 
-	if err := wire.Decode(r, &c.ChallengeDuration, &c.Nonce); err != nil {
+	if err := wire.Decode(r, &c.ChallengeDuration, &c.NonceShare); err != nil {
 		return err
 	}
 
@@ -175,14 +247,30 @@ func (c *ChannelProposalReq) Decode(r io.Reader) (err error) {
 		}
 	}
 
-	return nil
+	var hasAgreement bool
+	if err := wire.Decode(r, &hasAgreement); err != nil || !hasAgreement {
+		return err
+	}
+	c.FundingAgreement, err = decodeBals(r, len(c.InitBals.OfParts), len(c.InitBals.Assets))
+	return errors.WithMessage(err, "decoding funding agreement")
+}
+
+// base returns the ChannelProposalReq itself. For virtual channel proposals,
+// the embedded ChannelProposalReq is returned.
+func (c *ChannelProposalReq) base() *ChannelProposalReq {
+	return c
 }
 
-// SessID calculates the SessionID of a ChannelProposalReq.
+// SessID calculates the SessionID of a ChannelProposalReq. It is the SHA3-256
+// hash of the encodings of all proposal fields, in the order nonce share, proposer's
+// participant, peers, challenge duration, initial data, initial balances, app
+// definition and funding agreement, if any. Every acceptance or rejection of the proposal must carry
+// it. Since it commits to the nonce share, responses to other proposals, or replays
+// of earlier responses, don't match.
 func (c ChannelProposalReq) SessID() (sid SessionID) {
 	hasher := sha3.New256()
-	if err := wire.Encode(hasher, c.Nonce); err != nil {
-		log.Panicf("session ID nonce encoding: %v", err)
+	if err := wire.Encode(hasher, c.NonceShare, c.ParticipantAddr); err != nil {
+		log.Panicf("session ID nonce share and participant encoding: %v", err)
 	}
 
 	for _, p := range c.PeerAddrs {
@@ -200,6 +288,9 @@ func (c ChannelProposalReq) SessID() (sid SessionID) {
 	); err != nil {
 		log.Panicf("session ID data encoding error: %v", err)
 	}
+	if err := encodeBals(hasher, c.FundingAgreement); err != nil {
+		log.Panicf("session ID funding agreement encoding: %v", err)
+	}
 
 	copy(sid[:], hasher.Sum(nil))
 	return
@@ -212,11 +303,12 @@ func (c ChannelProposalReq) SessID() (sid SessionID) {
 // * No locked sub-allocations
 // * InitBals match the dimension of Parts
 // * non-zero ChallengeDuration
+// * FundingAgreement, if set, matches the dimensions and sums of InitBals
 func (c ChannelProposalReq) Valid() error {
 	if c.InitBals == nil || c.ParticipantAddr == nil {
 		return errors.New("invalid nil fields")
 	} else if err := channel.ValidateParameters(
-		c.ChallengeDuration, len(c.PeerAddrs), c.AppDef, c.Nonce); err != nil {
+		c.ChallengeDuration, len(c.PeerAddrs), c.AppDef, calcNonce(c.NonceShare)); err != nil {
 		return errors.WithMessage(err, "invalid channel parameters")
 	} else if err := c.InitBals.Valid(); err != nil {
 		return err
@@ -224,6 +316,61 @@ func (c ChannelProposalReq) Valid() error {
 		return errors.New("initial allocation cannot have locked funds")
 	} else if len(c.InitBals.OfParts) != len(c.PeerAddrs) {
 		return errors.New("wrong dimension of initial balances")
+	} else if c.FundingAgreement != nil {
+		return errors.WithMessage(validFundingAgreement(c.InitBals, c.FundingAgreement), "invalid funding agreement")
+	}
+	return nil
+}
+
+// params returns the parameters of the two-party channel that is opened if
+// the proposal is accepted with acc. The channel nonce is calculated from the
+// nonce shares of the proposal and the acceptance.
+func (c *ChannelProposalReq) params(acc *ChannelProposalAcc) *channel.Params {
+	return c.partsParams(
+		[]wallet.Address{c.ParticipantAddr, acc.ParticipantAddr},
+		[]NonceShare{c.NonceShare, acc.NonceShare})
+}
+
+// partsParams returns the parameters of the channel with the given
+// participants and nonce shares of all peers, in peer order.
+func (c *ChannelProposalReq) partsParams(parts []wallet.Address, shares []NonceShare) *channel.Params {
+	return channel.NewParamsUnsafe(c.ChallengeDuration, parts, c.AppDef, calcNonce(shares...))
+}
+
+// Deposits returns the balances that the participants deposit during funding.
+// These are the FundingAgreement or, if it is nil, the initial balances.
+func (c ChannelProposalReq) Deposits() [][]channel.Bal {
+	if c.FundingAgreement != nil {
+		return c.FundingAgreement
+	}
+	return c.InitBals.OfParts
+}
+
+// validFundingAgreement checks that the agreement has the dimensions of the
+// initial balances, no negative balances and the same sum of each asset.
+func validFundingAgreement(initBals *channel.Allocation, agreement [][]channel.Bal) error {
+	if len(agreement) != len(initBals.OfParts) {
+		return errors.New("wrong number of participants")
+	}
+	sums := make([]*big.Int, len(initBals.Assets))
+	for j := range sums {
+		sums[j] = new(big.Int)
+	}
+	for i, bals := range agreement {
+		if len(bals) != len(initBals.Assets) {
+			return errors.Errorf("wrong number of assets of participant %d", i)
+		}
+		for j, bal := range bals {
+			if bal == nil || bal.Sign() == -1 {
+				return errors.Errorf("balance %d of participant %d is nil or negative", j, i)
+			}
+			sums[j].Add(sums[j], bal)
+		}
+	}
+	for j, sum := range initBals.Sum() {
+		if sum.Cmp(sums[j]) != 0 {
+			return errors.Errorf("sum of asset %d does not match initial balances", j)
+		}
 	}
 	return nil
 }
@@ -231,13 +378,20 @@ func (c ChannelProposalReq) Valid() error {
 // ChannelProposalAcc contains all data for a response to a channel proposal
 // message. The SessID must be computed from the channel proposal messages one
 // wishes to respond to. ParticipantAddr should be a participant address just
-// for this channel instantiation.
+// for this channel instantiation. NonceShare is the acceptor's share of the
+// channel nonce. ChannelID is the ID of the channel that the acceptor derived
+// from the proposal and the acceptance. The proposer aborts the proposal if it
+// derives a different ID. In channels of more than two participants, the
+// acceptor cannot derive the ID yet and ChannelID is zero, see
+// ChannelProposalParts.
 //
 // The type implements the channel proposal response messages from the
 // Multi-Party Channel Proposal Protocol (MPCPP).
 type ChannelProposalAcc struct {
 	SessID          SessionID
 	ParticipantAddr wallet.Address
+	NonceShare      NonceShare
+	ChannelID       channel.ID
 }
 
 // Type returns msg.ChannelProposalAcc.
@@ -255,7 +409,11 @@ func (acc ChannelProposalAcc) Encode(w io.Writer) error {
 		return errors.WithMessage(err, "participant address encoding")
 	}
 
-	return nil
+	if err := wire.Encode(w, acc.NonceShare); err != nil {
+		return errors.WithMessage(err, "nonce share encoding")
+	}
+
+	return errors.WithMessage(wire.Encode(w, acc.ChannelID), "channel ID encoding")
 }
 
 // Decode decodes a ChannelProposalAcc from an io.Reader.
@@ -264,8 +422,15 @@ func (acc *ChannelProposalAcc) Decode(r io.Reader) (err error) {
 		return errors.WithMessage(err, "SID decoding")
 	}
 
-	acc.ParticipantAddr, err = wallet.DecodeAddress(r)
-	return errors.WithMessage(err, "participant address decoding")
+	if acc.ParticipantAddr, err = wallet.DecodeAddress(r); err != nil {
+		return errors.WithMessage(err, "participant address decoding")
+	}
+
+	if err = wire.Decode(r, &acc.NonceShare); err != nil {
+		return errors.WithMessage(err, "nonce share decoding")
+	}
+
+	return errors.WithMessage(wire.Decode(r, &acc.ChannelID), "channel ID decoding")
 }
 
 // ChannelProposalRej is used to reject a ChannelProposalReq.
@@ -292,3 +457,27 @@ func (rej ChannelProposalRej) Encode(w io.Writer) error {
 func (rej *ChannelProposalRej) Decode(r io.Reader) error {
 	return wire.Decode(r, &rej.SessID, &rej.Reason)
 }
+
+// ChannelProposalAbort is sent by the proposer of a ChannelProposalReq if it
+// gives up on the proposal, e.g., because its context expired before it
+// received a response. A peer that already accepted the proposal then stops
+// setting up the channel instead of waiting for the funding to time out.
+type ChannelProposalAbort struct {
+	SessID SessionID
+	Reason string
+}
+
+// Type returns msg.ChannelProposalAbort.
+func (ChannelProposalAbort) Type() msg.Type {
+	return msg.ChannelProposalAbort
+}
+
+// Encode encodes a ChannelProposalAbort into an io.Writer.
+func (a ChannelProposalAbort) Encode(w io.Writer) error {
+	return wire.Encode(w, a.SessID, a.Reason)
+}
+
+// Decode decodes a ChannelProposalAbort from an io.Reader.
+func (a *ChannelProposalAbort) Decode(r io.Reader) error {
+	return wire.Decode(r, &a.SessID, &a.Reason)
+}
@@ -7,7 +7,6 @@ package client_test
 
 import (
 	"bytes"
-	"math/big"
 	"math/rand"
 	"testing"
 
@@ -28,7 +27,7 @@ func TestChannelProposalReq_NilArgs(t *testing.T) {
 	rng := rand.New(rand.NewSource(2020 - 01 - 0x8))
 	c := &client.ChannelProposalReq{
 		ChallengeDuration: 1,
-		Nonce:             big.NewInt(2),
+		NonceShare:        client.NonceShare{2},
 		ParticipantAddr:   wallettest.NewRandomAddress(rng),
 		AppDef:            test.NewRandomApp(rng).Def(),
 		InitData:          test.NewRandomData(rng),
@@ -53,7 +52,7 @@ func TestChannelProposalReqSerialization(t *testing.T) {
 	for i := 0; i < 4; i++ {
 		m := &client.ChannelProposalReq{
 			ChallengeDuration: 0,
-			Nonce:             big.NewInt(rng.Int63()),
+			NonceShare:        newRandomNonceShare(rng),
 			ParticipantAddr:   wallettest.NewRandomAddress(rng),
 			AppDef:            test.NewRandomApp(rng).Def(),
 			InitData:          test.NewRandomData(rng),
@@ -63,6 +62,10 @@ func TestChannelProposalReqSerialization(t *testing.T) {
 				wallettest.NewRandomAddress(rng),
 			},
 		}
+		if i%2 == 1 {
+			// the proposer funds the proposee's balances and vice versa
+			m.FundingAgreement = [][]channel.Bal{m.InitBals.OfParts[1], m.InitBals.OfParts[0]}
+		}
 		msg.TestMsg(t, m)
 	}
 }
@@ -77,7 +80,7 @@ func TestChannelProposalReqDecode_CheckMaxNumParts(t *testing.T) {
 
 	// reimplementation of ChannelProposalReq.Encode modified to create the
 	// maximum number of participants possible with the encoding
-	require.NoError(wire.Encode(buffer, c.ChallengeDuration, c.Nonce))
+	require.NoError(wire.Encode(buffer, c.ChallengeDuration, c.NonceShare))
 	require.NoError(
 		io.Encode(buffer, c.ParticipantAddr, c.AppDef, c.InitData, c.InitBals))
 
@@ -101,7 +104,7 @@ func TestChannelProposalReqSessID(t *testing.T) {
 	fake := newRandomChannelProposalReq(rand.New(rand.NewSource(0xeeff0c)))
 
 	assert.NotEqual(t, original.ChallengeDuration, fake.ChallengeDuration)
-	assert.NotEqual(t, original.Nonce, fake.Nonce)
+	assert.NotEqual(t, original.NonceShare, fake.NonceShare)
 	assert.NotEqual(t, original.ParticipantAddr, fake.ParticipantAddr)
 	// TODO: while using the payment app in channel tests, they all have the same
 	// address. Fixed in #266
@@ -112,12 +115,12 @@ func TestChannelProposalReqSessID(t *testing.T) {
 	assert.NotEqual(t, s, c0.SessID())
 
 	c1 := original
-	c1.Nonce = fake.Nonce
+	c1.NonceShare = fake.NonceShare
 	assert.NotEqual(t, s, c1.SessID())
 
 	c2 := original
 	c2.ParticipantAddr = fake.ParticipantAddr
-	assert.Equal(t, s, c2.SessID())
+	assert.NotEqual(t, s, c2.SessID())
 
 	// TODO: #266
 	//c3 := original
@@ -135,6 +138,10 @@ func TestChannelProposalReqSessID(t *testing.T) {
 	c6 := original
 	c6.PeerAddrs = fake.PeerAddrs
 	assert.NotEqual(t, s, c6.SessID())
+
+	c7 := original
+	c7.FundingAgreement = [][]channel.Bal{original.InitBals.OfParts[1], original.InitBals.OfParts[0]}
+	assert.NotEqual(t, s, c7.SessID())
 }
 
 func TestChannelProposal_AsReqAsProp(t *testing.T) {
@@ -155,7 +162,7 @@ func newRandomChannelProposalReq(rng *rand.Rand) *client.ChannelProposalReq {
 	participantAddr := wallettest.NewRandomAddress(rng)
 	return &client.ChannelProposalReq{
 		ChallengeDuration: params.ChallengeDuration,
-		Nonce:             params.Nonce,
+		NonceShare:        newRandomNonceShare(rng),
 		ParticipantAddr:   participantAddr,
 		AppDef:            params.App.Def(),
 		InitData:          data,
@@ -170,6 +177,8 @@ func TestChannelProposalAccSerialization(t *testing.T) {
 		m := &client.ChannelProposalAcc{
 			SessID:          newRandomSessID(rng),
 			ParticipantAddr: wallettest.NewRandomAddress(rng),
+			NonceShare:      newRandomNonceShare(rng),
+			ChannelID:       test.NewRandomChannelID(rng),
 		}
 		msg.TestMsg(t, m)
 	}
@@ -186,11 +195,27 @@ func TestChannelProposalRejSerialization(t *testing.T) {
 	}
 }
 
+func TestChannelProposalAbortSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xab0e7))
+	for i := 0; i < 16; i++ {
+		m := &client.ChannelProposalAbort{
+			SessID: newRandomSessID(rng),
+			Reason: newRandomString(rng, 16, 16),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
 func newRandomSessID(rng *rand.Rand) (id client.SessionID) {
 	rng.Read(id[:])
 	return
 }
 
+func newRandomNonceShare(rng *rand.Rand) (share client.NonceShare) {
+	rng.Read(share[:])
+	return
+}
+
 // newRandomstring returns a random string of length between minLen and
 // minLen+maxLenDiff
 func newRandomString(rng *rand.Rand, minLen, maxLenDiff int) string {
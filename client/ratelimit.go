@@ -0,0 +1,238 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+)
+
+const (
+	// rateLimitedReason is the rejection reason of rate limited proposals.
+	rateLimitedReason = "too many proposals"
+	// fundsCheckTimeout is the timeout of a ProposalLimits.FundsCheck.
+	fundsCheckTimeout = 10 * time.Second
+	// maxLimitedPeers is the number of peers whose rate limits are tracked
+	// before the limits of idle peers are forgotten.
+	maxLimitedPeers = 1024
+	// maxPendingRejections is the number of rejections of limited proposals
+	// that are sent concurrently. Further limited proposals are dropped
+	// silently until a rejection completed, so that a flood of proposals
+	// cannot spawn an unbounded number of rejections.
+	maxPendingRejections = 16
+)
+
+type (
+	// A RateLimit limits the rate of events to Rate per second with bursts of
+	// at most Burst events. A Rate of 0 disables the limit.
+	RateLimit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// ProposalLimits protect a Client against floods of incoming channel
+	// proposals, see Client.SetProposalLimits. The limits apply to ledger,
+	// sub- and virtual channel proposals.
+	ProposalLimits struct {
+		PerPeer RateLimit // limit of the proposals of each peer
+		Global  RateLimit // limit of the proposals of all peers together
+		// Reject sends a rejection for proposals that exceed a limit. Otherwise,
+		// they are dropped silently, which is cheaper during floods. During
+		// floods, rejected proposals are also dropped silently once too many
+		// rejections are pending.
+		Reject bool
+		// FundsCheck optionally checks ledger channel proposals, e.g., that
+		// the proposer owns the funds that it has to deposit, see BalanceCheck.
+		// Failing proposals are rejected with the error as reason.
+		FundsCheck func(context.Context, *ChannelProposalReq) error
+	}
+
+	// proposalLimiter applies the ProposalLimits of a client.
+	proposalLimiter struct {
+		mutex  sync.Mutex
+		limits ProposalLimits
+		global tokenBucket
+		peers  map[string]*tokenBucket
+		// rejecting is the number of pending rejections, at most
+		// maxPendingRejections.
+		rejecting int
+	}
+
+	// tokenBucket implements a RateLimit.
+	tokenBucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+// SetProposalLimits sets the limits of incoming channel proposals. They are
+// applied when a proposal is received, before it is validated and passed to
+// the ProposalHandler. By default, proposals are not limited.
+//
+// A limit with a positive rate must have a positive burst, since it would drop
+// all proposals otherwise. If a rate or burst of a limit is negative,
+// SetProposalLimits panics.
+func (c *Client) SetProposalLimits(limits ProposalLimits) error {
+	for _, l := range []RateLimit{limits.PerPeer, limits.Global} {
+		if l.Rate < 0 || l.Burst < 0 {
+			c.log.Panic("rate limits must not be negative")
+		}
+		if l.Rate > 0 && l.Burst == 0 {
+			return errors.New("rate limit with zero burst would drop all proposals")
+		}
+	}
+	c.limiter.mutex.Lock()
+	defer c.limiter.mutex.Unlock()
+	c.limiter.limits = limits
+	c.limiter.global = tokenBucket{}
+	c.limiter.peers = nil
+	return nil
+}
+
+// BalanceCheck returns a ProposalLimits.FundsCheck that checks that the
+// proposer's participant has a balance of at least its deposit of each asset
+// of the proposal. balance returns the on-chain balance of an address.
+func BalanceCheck(balance func(context.Context, channel.Asset, wallet.Address) (*big.Int, error)) func(context.Context, *ChannelProposalReq) error {
+	return func(ctx context.Context, req *ChannelProposalReq) error {
+		deposits := req.Deposits()[0] // the proposer has index 0
+		for i, asset := range req.InitBals.Assets {
+			bal, err := balance(ctx, asset, req.ParticipantAddr)
+			if err != nil {
+				return errors.WithMessagef(err, "querying balance of asset %d", i)
+			}
+			if bal.Cmp(deposits[i]) < 0 {
+				return errors.Errorf("insufficient funds of asset %d", i)
+			}
+		}
+		return nil
+	}
+}
+
+// admitProposal returns whether the proposal of peer p is within the rate
+// limits. Limited proposals are rejected or dropped. At most
+// maxPendingRejections rejections are sent concurrently.
+func (c *Client) admitProposal(p *peer.Peer, req proposalMsg) bool {
+	admitted, reject := c.limiter.allow(p.PerunAddress, time.Now())
+	if admitted {
+		return true
+	}
+	c.logPeer(p).Debug("proposal exceeds rate limit")
+	if reject {
+		go func() {
+			defer c.limiter.doneRejecting()
+			ctx, cancel := context.WithTimeout(context.Background(), proposalAbortTimeout)
+			defer cancel()
+			if err := c.handleChannelProposalRej(ctx, p, req, rateLimitedReason); err != nil {
+				c.logPeer(p).Warnf("rejecting rate limited proposal: %v", err)
+			}
+		}()
+	}
+	return false
+}
+
+// checkFunds runs the FundsCheck of the limits, if any, on the ledger channel
+// proposal.
+func (c *Client) checkFunds(req *ChannelProposalReq) error {
+	c.limiter.mutex.Lock()
+	check := c.limiter.limits.FundsCheck
+	c.limiter.mutex.Unlock()
+	if check == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), fundsCheckTimeout)
+	defer cancel()
+	return check(ctx, req)
+}
+
+// allow returns whether a proposal of the peer with the given address at time
+// now is within the limits and takes its tokens. Otherwise, it also returns
+// whether the proposal should be rejected, which counts as a pending rejection
+// until doneRejecting is called.
+func (l *proposalLimiter) allow(addr wallet.Address, now time.Time) (allowed, reject bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if pl := l.limits.PerPeer; pl.Rate > 0 {
+		if l.peers == nil {
+			l.peers = make(map[string]*tokenBucket)
+		} else if len(l.peers) >= maxLimitedPeers {
+			l.pruneFull(now)
+		}
+		key := string(addr.Bytes())
+		b, ok := l.peers[key]
+		if !ok {
+			b = new(tokenBucket)
+			l.peers[key] = b
+		}
+		if !b.take(pl, now) {
+			return false, l.startRejecting()
+		}
+	}
+	if gl := l.limits.Global; gl.Rate > 0 && !l.global.take(gl, now) {
+		return false, l.startRejecting()
+	}
+	return true, false
+}
+
+// startRejecting returns whether a limited proposal should be rejected and
+// counts the rejection as pending. The limiter must be locked by the caller.
+func (l *proposalLimiter) startRejecting() bool {
+	if !l.limits.Reject || l.rejecting >= maxPendingRejections {
+		return false
+	}
+	l.rejecting++
+	return true
+}
+
+// doneRejecting marks a pending rejection as completed.
+func (l *proposalLimiter) doneRejecting() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.rejecting--
+}
+
+// pruneFull forgets the buckets of all peers that are full, which are the same
+// as new buckets. The limiter must be locked by the caller.
+func (l *proposalLimiter) pruneFull(now time.Time) {
+	for key, b := range l.peers {
+		if b.refill(l.limits.PerPeer, now) >= float64(l.limits.PerPeer.Burst) {
+			delete(l.peers, key)
+		}
+	}
+}
+
+// take takes a token from the bucket, if there is one. A new bucket is full.
+func (b *tokenBucket) take(l RateLimit, now time.Time) bool {
+	if b.refill(l, now) < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill adds the tokens that accrued since the last refill and returns the
+// number of tokens.
+func (b *tokenBucket) refill(l RateLimit, now time.Time) float64 {
+	if b.last.IsZero() {
+		b.tokens = float64(l.Burst)
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.Rate
+		if b.tokens > float64(l.Burst) {
+			b.tokens = float64(l.Burst)
+		}
+	}
+	b.last = now
+	return b.tokens
+}
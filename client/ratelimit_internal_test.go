@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestTokenBucket(t *testing.T) {
+	l := RateLimit{Rate: 2, Burst: 3}
+	now := time.Now()
+	var b tokenBucket
+
+	for i := 0; i < l.Burst; i++ {
+		assert.True(t, b.take(l, now), "burst")
+	}
+	assert.False(t, b.take(l, now), "empty bucket")
+	assert.True(t, b.take(l, now.Add(500*time.Millisecond)), "refilled token")
+	assert.False(t, b.take(l, now.Add(500*time.Millisecond)))
+	assert.Equal(t, float64(l.Burst), b.refill(l, now.Add(time.Hour)), "refilled up to burst")
+}
+
+func TestProposalLimiter(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1137))
+	alice, bob, carol := wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)
+	now := time.Now()
+
+	t.Run("disabled", func(t *testing.T) {
+		var l proposalLimiter
+		for i := 0; i < 10; i++ {
+			ok, _ := l.allow(alice, now)
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("per peer", func(t *testing.T) {
+		l := proposalLimiter{limits: ProposalLimits{PerPeer: RateLimit{Rate: 1, Burst: 1}, Reject: true}}
+		ok, _ := l.allow(alice, now)
+		assert.True(t, ok)
+		ok, reject := l.allow(alice, now)
+		assert.False(t, ok)
+		assert.True(t, reject)
+		ok, _ = l.allow(bob, now)
+		assert.True(t, ok, "other peers are not limited")
+		ok, _ = l.allow(alice, now.Add(time.Second))
+		assert.True(t, ok, "after refill")
+	})
+
+	t.Run("global", func(t *testing.T) {
+		l := proposalLimiter{limits: ProposalLimits{
+			PerPeer: RateLimit{Rate: 1, Burst: 1},
+			Global:  RateLimit{Rate: 1, Burst: 2},
+		}}
+		ok, _ := l.allow(alice, now)
+		assert.True(t, ok)
+		ok, _ = l.allow(bob, now)
+		assert.True(t, ok)
+		ok, reject := l.allow(carol, now)
+		assert.False(t, ok, "global limit")
+		assert.False(t, reject, "dropped")
+	})
+
+	t.Run("pending rejections", func(t *testing.T) {
+		l := proposalLimiter{limits: ProposalLimits{Global: RateLimit{Rate: 1, Burst: 1}, Reject: true}}
+		l.allow(alice, now)
+		for i := 0; i < maxPendingRejections; i++ {
+			_, reject := l.allow(alice, now)
+			assert.True(t, reject)
+		}
+		_, reject := l.allow(alice, now)
+		assert.False(t, reject, "dropped while too many rejections are pending")
+		l.doneRejecting()
+		_, reject = l.allow(alice, now)
+		assert.True(t, reject, "rejected after a pending rejection completed")
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		l := proposalLimiter{limits: ProposalLimits{PerPeer: RateLimit{Rate: 1, Burst: 1}}}
+		for i := 0; i < maxLimitedPeers; i++ {
+			l.allow(wallettest.NewRandomAddress(rng), now)
+		}
+		l.allow(alice, now.Add(time.Second))
+		assert.Len(t, l.peers, 1, "full buckets pruned")
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_SetProposalLimits(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1138))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		&logFunder{log.WithField("role", "Alice")}, &logAdjudicator{log.WithField("role", "Alice")})
+	defer alice.Close()
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	assert.Panics(t, func() { bob.SetProposalLimits(client.ProposalLimits{Global: client.RateLimit{Rate: -1}}) })
+	assert.Error(t, bob.SetProposalLimits(client.ProposalLimits{PerPeer: client.RateLimit{Rate: 1}}), "zero burst")
+
+	propose := func(asset channel.Asset) error {
+		prop := newTestProposal(rng, asset, aliceID.Address(), bobID.Address(), 100, 100)
+		_, err := alice.ProposeChannel(ctx, prop)
+		return err
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		require.NoError(t, bob.SetProposalLimits(client.ProposalLimits{
+			PerPeer: client.RateLimit{Rate: 0.001, Burst: 1},
+			Reject:  true,
+		}))
+		require.NoError(t, propose(channeltest.NewRandomAsset(rng)))
+		<-bobHandler.chans
+		assert.Error(t, propose(channeltest.NewRandomAsset(rng)), "rate limited proposal rejected")
+	})
+
+	t.Run("funds check", func(t *testing.T) {
+		balance := big.NewInt(99)
+		require.NoError(t, bob.SetProposalLimits(client.ProposalLimits{
+			FundsCheck: client.BalanceCheck(func(context.Context, channel.Asset, wallet.Address) (*big.Int, error) {
+				if balance == nil {
+					return nil, errors.New("balance unknown")
+				}
+				return balance, nil
+			}),
+		}))
+		assert.Error(t, propose(channeltest.NewRandomAsset(rng)), "insufficient funds")
+		balance = nil
+		assert.Error(t, propose(channeltest.NewRandomAsset(rng)), "balance query failed")
+		balance = big.NewInt(100)
+		require.NoError(t, propose(channeltest.NewRandomAsset(rng)))
+		<-bobHandler.chans
+	})
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/wallet"
+)
+
+// Restore restores all channels that were persisted by the client's
+// PersistRestorer, which must have been set with EnablePersistence before.
+// lookup is used to get the channel participant account for the own
+// participant address of each channel.
+//
+// Channels that didn't finish the initial signature exchange are removed from
+// the persistence. Interrupted updates are enabled if all signatures are
+// present. Otherwise, the restored channel stays in the Signing phase and the
+// update is resumed by exchanging sync messages with the peers: it is enabled
+// if a peer has the missing signatures and discarded otherwise. Ledger
+// channels that were still in the Funding phase are funded again.
+//
+// The restored channels are added to the client's channel registry and
+// returned. The user should start the update handler of each restored channel
+// with Channel.ListenUpdates. If the Client acts as intermediary, see
+// EnableVirtualChannelIntermediary, it must be enabled before calling Restore.
+func (c *Client) Restore(
+	ctx context.Context,
+	lookup func(wallet.Address) (wallet.Account, error),
+) ([]*Channel, error) {
+	if lookup == nil {
+		c.log.Panic("account lookup must not be nil")
+	}
+
+	persisted, err := c.restorePersisted(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ledger channels must be restored before the virtual and sub-channels they
+	// fund.
+	var chans []*Channel
+	restored := make(map[channel.ID]*Channel)
+	for _, virtual := range []bool{false, true} {
+		for _, pch := range persisted {
+			if (pch.Parent != nil) != virtual {
+				continue
+			}
+			ch, err := c.restoreChannel(ctx, pch, lookup, restored)
+			if err != nil {
+				return chans, errors.WithMessagef(err, "restoring channel %x", pch.ID())
+			} else if ch == nil {
+				continue // channel was dropped
+			}
+			restored[ch.ID()] = ch
+			chans = append(chans, ch)
+		}
+	}
+	return chans, nil
+}
+
+// restorePersisted collects the data of all persisted channels with all active
+// peers. Every channel is only returned once.
+func (c *Client) restorePersisted(ctx context.Context) ([]*persistence.Channel, error) {
+	peers, err := c.pr.ActivePeers(ctx)
+	if err != nil {
+		return nil, errors.WithMessage(err, "retrieving active peers")
+	}
+
+	var chans []*persistence.Channel
+	seen := make(map[channel.ID]bool)
+	for _, peer := range peers {
+		if c.isIdentity(peer) {
+			continue
+		}
+
+		it, err := c.pr.RestorePeer(peer)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "restoring channels of peer %v", peer)
+		}
+		for it.Next(ctx) {
+			ch := it.Channel()
+			if !seen[ch.ID()] {
+				seen[ch.ID()] = true
+				chans = append(chans, ch)
+			}
+		}
+		if err := it.Close(); err != nil {
+			return nil, errors.WithMessagef(err, "iterating channels of peer %v", peer)
+		}
+	}
+	return chans, nil
+}
+
+// restoreChannel restores a single channel controller from its persisted data
+// and adds it to the channel registry. If the channel is not worth restoring,
+// it is removed from the persistence and nil is returned. parents contains all
+// already restored ledger channels.
+func (c *Client) restoreChannel(
+	ctx context.Context,
+	pch *persistence.Channel,
+	lookup func(wallet.Address) (wallet.Account, error),
+	parents map[channel.ID]*Channel,
+) (*Channel, error) {
+	log := c.logChan(pch.ID())
+	switch pch.Phase() {
+	case channel.InitActing, channel.InitSigning, channel.Settled:
+		log.Debugf("Dropping channel in phase %v", pch.Phase())
+		return nil, errors.WithMessage(c.pr.ChannelRemoved(ctx, pch.ID()), "removing channel")
+	}
+	if c.channels.Has(pch.ID()) {
+		return nil, errors.New("channel already exists")
+	}
+
+	var parent *Channel
+	if pch.Parent != nil {
+		if parent = parents[*pch.Parent]; parent == nil {
+			return nil, errors.Errorf("parent channel %x not restored", *pch.Parent)
+		}
+	}
+
+	acc, err := lookup(pch.Params().Parts[pch.Idx()])
+	if err != nil {
+		return nil, errors.WithMessage(err, "looking up account")
+	}
+	machine, err := channel.RestoreStateMachine(acc, pch)
+	if err != nil {
+		return nil, errors.WithMessage(err, "restoring state machine")
+	}
+	peers, err := c.getPeers(ctx, pch.PeersV)
+	if err != nil {
+		return nil, errors.WithMessage(err, "getting peers from the registry")
+	}
+	ch, err := newChannelFromMachine(machine, peers, c.adjudicator, c.pr)
+	if err != nil {
+		return nil, err
+	}
+	ch.setLogger(log)
+	ch.vFunding = c.vFunding
+	ch.events = c.events
+	ch.parent = parent
+	ch.sub = c.isSubChannel(pch.PeersV, parent)
+	ch.peerAddrs = pch.PeersV
+	ch.agreement = pch.Agreement
+
+	funded := true
+	switch pch.Phase() {
+	case channel.Funding:
+		funded, err = c.restoreFunding(ctx, ch)
+	case channel.Signing:
+		err = ch.restoreStaged(ctx)
+	}
+	if err != nil || !funded {
+		ch.Close()
+		if err == nil {
+			log.Debug("Dropping unfunded channel")
+			err = errors.WithMessage(c.pr.ChannelRemoved(ctx, pch.ID()), "removing channel")
+		}
+		return nil, err
+	}
+
+	if !c.channels.Put(ch.ID(), ch) {
+		ch.Close()
+		return nil, errors.New("channel already exists")
+	}
+	if ch.sub {
+		parent.addSubChannel(ch)
+	}
+	ch.addFundedLocked()
+	go ch.handleSyncs()
+	ch.sync()
+	return ch, nil
+}
+
+// restoreFunding completes the funding of a channel that was interrupted in
+// the Funding phase. Ledger channels are funded again, with the persisted
+// funding agreement. Virtual and sub-channels
+// are only enabled if their funds are locked in the current state of the
+// parent, otherwise the funding didn't happen and false is returned.
+func (c *Client) restoreFunding(ctx context.Context, ch *Channel) (bool, error) {
+	if ch.parent != nil {
+		for _, sub := range ch.parent.State().Locked {
+			if sub.ID == ch.ID() {
+				return true, errors.WithMessage(ch.machine.SetFunded(ctx), "error in SetFunded()")
+			}
+		}
+		return false, nil
+	}
+
+	if err := c.funder.Fund(ctx,
+		c.newFundingReq(ch, &ch.machine.State().Allocation, ch.agreement)); err != nil {
+		return false, errors.WithMessage(err, "error while funding channel")
+	}
+	return true, errors.WithMessage(ch.machine.SetFunded(ctx), "error in SetFunded()")
+}
+
+// restoreStaged resolves an update that was interrupted in the Signing phase.
+// If all signatures on the staging state are present, it is enabled.
+// Otherwise, the channel stays in the Signing phase until the update is
+// resolved with the sync messages of the peers, see mergeSync. We might have
+// sent our signature already, so the update must not be discarded unilaterally.
+func (c *Channel) restoreStaged(ctx context.Context) error {
+	for _, sig := range c.machine.StagingTX().Sigs {
+		if sig == nil {
+			c.log.Debug("Waiting for sync of partially signed staging state")
+			return nil
+		}
+	}
+	return c.enableNotifyUpdate(ctx)
+}
@@ -0,0 +1,480 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/channel/persistence/keyvalue"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/pkg/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestRestore(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0x7e570e))
+	s := newRestoreSetup(t, rng)
+	asset := channeltest.NewRandomAsset(rng)
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	alice, bob := s.newParty("Alice"), s.newParty("Bob")
+
+	// open channel and make an update
+	aliceCl, _ := s.start(alice, nil)
+	bobCl, bobHandler := s.start(bob, nil)
+	prop := newTestProposal(rng, asset, alice.id.Address(), bob.id.Address(), 100, 100)
+	prop.Account = alice.acc
+	aliceCh, err := aliceCl.ProposeChannel(ctx, prop)
+	require.NoError(err)
+	var bobCh *client.Channel
+	select {
+	case bobCh = <-bobHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected channel at Bob")
+	}
+	// Bob's updates are awaited so that no update is in progress on restarts
+	bobUps := make(chan *channel.State, 1)
+	awaitBobUp := func() {
+		select {
+		case <-bobUps:
+		case <-ctx.Done():
+			t.Fatal("expected update at Bob")
+		}
+	}
+	bobCh.SubUpdates(bobUps)
+	pay(ctx, t, aliceCh, 10)
+	awaitBobUp()
+	state := aliceCh.State()
+
+	// restart both clients
+	require.NoError(aliceCl.Close())
+	require.NoError(bobCl.Close())
+	bobCl, _ = s.start(bob, nil)
+	aliceCl, _ = s.start(alice, nil)
+	defer func() {
+		assert.NoError(t, aliceCl.Close())
+		assert.NoError(t, bobCl.Close())
+	}()
+
+	aliceChs, bobChs := s.restoreBoth(ctx, aliceCl, bobCl, alice)
+	require.Len(aliceChs, 1)
+	require.Len(bobChs, 1)
+	for _, ch := range []*client.Channel{aliceChs[0], bobChs[0]} {
+		assert.Equal(t, channel.Acting, ch.Phase())
+		assert.Equal(t, state.Version, ch.State().Version)
+		assertLedgerBals(t, ch.State(), 90, 110, 0)
+	}
+	aliceCh, bobCh = aliceChs[0], bobChs[0]
+	bobCh.SubUpdates(bobUps)
+	go bobCh.ListenUpdates(acceptAllUpdates{t})
+
+	// continue using the restored channel
+	pay(ctx, t, aliceCh, 10)
+	awaitBobUp()
+	assertLedgerBals(t, bobCh.State(), 80, 120, 0)
+
+	// settled channels are removed from the persistence
+	final := aliceCh.State().Clone()
+	final.Version++
+	final.IsFinal = true
+	require.NoError(aliceCh.Update(ctx, client.ChannelUpdate{State: final, ActorIdx: aliceCh.Idx()}))
+	awaitBobUp()
+	require.NoError(aliceCh.Settle(ctx))
+	peers, err := alice.pr.ActivePeers(ctx)
+	require.NoError(err)
+	assert.Empty(t, peers)
+}
+
+func TestRestore_Signing(t *testing.T) {
+	// In all cases, Alice proposed an update to version 1, which pays 10 to
+	// Bob, and persisted her signature before sending it.
+	tests := []struct {
+		name string
+		// bobSigs are the signatures that Bob persisted, nil if he didn't
+		// receive the proposal.
+		bobSigs []int
+		// version and bob's balance that both restored channels must agree on
+		version uint64
+		bal1    int64
+	}{
+		{"Bob signed", []int{0, 1}, 1, 110},
+		{"Bob didn't sign", []int{0}, 0, 100},
+		{"Bob missed proposal", nil, 0, 100},
+	}
+
+	for i, tt := range tests {
+		tt := tt
+		rng := rand.New(rand.NewSource(int64(0x5191 + i)))
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+			defer cancel()
+			s := newRestoreSetup(t, rng)
+			alice, bob := s.newParty("Alice"), s.newParty("Bob")
+			pch := s.newPersistedChannel(ctx, [2]*restoreParty{alice, bob}, nil, 100, 100)
+			pch.initialize(ctx)
+			pch.fund(ctx)
+
+			up := pch.ms[0].State().Clone()
+			up.Version++
+			up.OfParts[0][0].Sub(up.OfParts[0][0], big.NewInt(10))
+			up.OfParts[1][0].Add(up.OfParts[1][0], big.NewInt(10))
+			pch.stage(ctx, 0, up)
+			pch.sign(ctx, 0, 0)
+			if tt.bobSigs != nil {
+				pch.stage(ctx, 1, up)
+				for _, idx := range tt.bobSigs {
+					pch.sign(ctx, idx, 1)
+				}
+			}
+
+			aliceCl, _ := s.start(alice, nil)
+			bobCl, _ := s.start(bob, nil)
+			defer func() {
+				assert.NoError(t, aliceCl.Close())
+				assert.NoError(t, bobCl.Close())
+			}()
+			aliceChs, bobChs := s.restoreBoth(ctx, aliceCl, bobCl, alice)
+			require.Len(t, aliceChs, 1)
+			require.Len(t, bobChs, 1)
+			// the interrupted update is resolved asynchronously with the peer
+			test.Eventually(t, func(t test.T) {
+				for _, ch := range []*client.Channel{aliceChs[0], bobChs[0]} {
+					assert.Equal(t, channel.Acting, ch.Phase())
+					assert.Equal(t, tt.version, ch.State().Version)
+				}
+			}, time.Second, 10*time.Millisecond)
+			for _, ch := range []*client.Channel{aliceChs[0], bobChs[0]} {
+				assertLedgerBals(t, ch.State(), 200-tt.bal1, tt.bal1, 0)
+			}
+		})
+	}
+}
+
+func TestRestore_Funding(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0d))
+
+	for _, tt := range []struct {
+		name      string
+		agreement [][]channel.Bal
+	}{
+		{"initial balances", nil},
+		{"funding agreement", [][]channel.Bal{{big.NewInt(200)}, {big.NewInt(0)}}},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+			defer cancel()
+			s := newRestoreSetup(t, rng)
+			alice, bob := s.newParty("Alice"), s.newParty("Bob")
+			pch := s.newPersistedChannelWithAgreement(ctx, [2]*restoreParty{alice, bob}, nil, 100, 100, tt.agreement)
+			pch.initialize(ctx)
+
+			funder := &recordingFunder{funded: make(chan channel.FundingReq, 1)}
+			aliceCl, _ := s.start(alice, funder)
+			bobCl, _ := s.start(bob, nil)
+			defer func() {
+				assert.NoError(t, aliceCl.Close())
+				assert.NoError(t, bobCl.Close())
+			}()
+
+			chs, err := aliceCl.Restore(ctx, s.lookup)
+			require.NoError(t, err)
+			require.Len(t, chs, 1)
+			assert.Equal(t, channel.Acting, chs[0].Phase())
+			select {
+			case req := <-funder.funded:
+				assert.Equal(t, pch.ms[0].ID(), req.Params.ID())
+				require.Len(t, req.Agreement, len(tt.agreement))
+				for i := range tt.agreement {
+					assert.Zero(t, tt.agreement[i][0].Cmp(req.Agreement[i][0]), "agreement of participant %d", i)
+				}
+			default:
+				t.Error("expected channel to be funded again")
+			}
+		})
+	}
+}
+
+func TestRestore_InitSigning(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1515))
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	s := newRestoreSetup(t, rng)
+	alice, bob := s.newParty("Alice"), s.newParty("Bob")
+	pch := s.newPersistedChannel(ctx, [2]*restoreParty{alice, bob}, nil, 100, 100)
+	// Alice only signed the initial state.
+	pch.sign(ctx, 0, 0)
+
+	aliceCl, _ := s.start(alice, nil)
+	defer func() { assert.NoError(t, aliceCl.Close()) }()
+
+	chs, err := aliceCl.Restore(ctx, s.lookup)
+	require.NoError(t, err)
+	assert.Empty(t, chs)
+	peers, err := alice.pr.ActivePeers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, peers, "dropped channel must be removed from the persistence")
+}
+
+func TestRestore_Virtual(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7e57a1))
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	s := newRestoreSetup(t, rng)
+	alice, bob, ingrid := s.newParty("Alice"), s.newParty("Bob"), s.newParty("Ingrid")
+
+	// The ledger channel with Ingrid locks the funds of the first virtual
+	// channel with Bob, but not of the second one, whose funding didn't happen.
+	ledger := s.newPersistedChannel(ctx, [2]*restoreParty{alice, ingrid}, nil, 100, 100)
+	ledgerID := ledger.ms[0].ID()
+	funded := s.newPersistedChannel(ctx, [2]*restoreParty{alice, bob}, &ledgerID, 10, 20)
+	unfunded := s.newPersistedChannel(ctx, [2]*restoreParty{alice, bob}, &ledgerID, 10, 20)
+	for _, pch := range []*persistedChannel{ledger, funded, unfunded} {
+		pch.initialize(ctx)
+	}
+	ledger.fund(ctx)
+
+	locked := ledger.ms[0].State().Clone()
+	locked.Version++
+	locked.OfParts[0][0].Sub(locked.OfParts[0][0], big.NewInt(10))
+	locked.OfParts[1][0].Sub(locked.OfParts[1][0], big.NewInt(20))
+	locked.Locked = []channel.SubAlloc{{ID: funded.ms[0].ID(), Bals: []channel.Bal{big.NewInt(30)}}}
+	for i := range ledger.ms {
+		require.NoError(t, ledger.ms[i].UpdateLocked(ctx, locked, 0))
+	}
+	ledger.exchangeSigs(ctx)
+	ledger.enable(ctx)
+
+	aliceCl, _ := s.start(alice, nil)
+	bobCl, _ := s.start(bob, nil)
+	ingridCl, _ := s.start(ingrid, nil)
+	defer func() {
+		for _, c := range []*client.Client{aliceCl, bobCl, ingridCl} {
+			assert.NoError(t, c.Close())
+		}
+	}()
+
+	chs, err := aliceCl.Restore(ctx, s.lookup)
+	require.NoError(t, err)
+	require.Len(t, chs, 2, "unfunded virtual channel must be dropped")
+	restored := make(map[channel.ID]*client.Channel)
+	for _, ch := range chs {
+		restored[ch.ID()] = ch
+		assert.Equal(t, channel.Acting, ch.Phase())
+	}
+	ledgerCh, virtualCh := restored[ledgerID], restored[funded.ms[0].ID()]
+	require.NotNil(t, ledgerCh)
+	require.NotNil(t, virtualCh)
+	assert.False(t, ledgerCh.IsVirtual())
+	assert.True(t, virtualCh.IsVirtual())
+	assert.Same(t, ledgerCh, virtualCh.Parent())
+	assertLedgerBals(t, ledgerCh.State(), 90, 80, 30)
+	_, err = alice.pr.RestoreChannel(ctx, unfunded.ms[0].ID())
+	assert.Error(t, err, "unfunded virtual channel must be removed from the persistence")
+}
+
+// restoreSetup creates parties whose channel data is persisted in memory, so
+// that their clients can be restarted on it.
+type restoreSetup struct {
+	t    *testing.T
+	rng  *rand.Rand
+	hub  peertest.ConnHub
+	accs map[string]wallet.Account
+}
+
+// restoreParty is a restartable channel participant.
+type restoreParty struct {
+	name string
+	id   peer.Identity
+	pr   *keyvalue.PersistRestorer
+	acc  wallet.Account
+}
+
+func newRestoreSetup(t *testing.T, rng *rand.Rand) *restoreSetup {
+	return &restoreSetup{t: t, rng: rng, accs: make(map[string]wallet.Account)}
+}
+
+func (s *restoreSetup) newParty(name string) *restoreParty {
+	acc := wallettest.NewRandomAccount(s.rng)
+	s.accs[string(acc.Address().Bytes())] = acc
+	return &restoreParty{
+		name: name,
+		id:   wallettest.NewRandomAccount(s.rng),
+		pr:   keyvalue.NewPersistRestorer(memorydb.NewDatabase()),
+		acc:  acc,
+	}
+}
+
+// lookup is the account lookup for Client.Restore.
+func (s *restoreSetup) lookup(addr wallet.Address) (wallet.Account, error) {
+	if acc, ok := s.accs[string(addr.Bytes())]; ok {
+		return acc, nil
+	}
+	return nil, errors.New("unknown account")
+}
+
+// start starts a listening client for p on its persistence. If funder is nil,
+// a logFunder is used.
+func (s *restoreSetup) start(p *restoreParty, funder channel.Funder) (*client.Client, *virtualPropHandler) {
+	if funder == nil {
+		funder = &logFunder{log.WithField("role", p.name)}
+	}
+	h := &virtualPropHandler{t: s.t, acc: p.acc, chans: make(chan *client.Channel, 1)}
+	c := client.New(p.id, s.hub.NewDialer(), h, funder,
+		&logAdjudicator{log.WithField("role", p.name)})
+	c.EnablePersistence(p.pr)
+	go c.Listen(s.hub.NewListener(p.id.Address()))
+	return c, h
+}
+
+// restoreBoth restores the channels of first and then of second. Before
+// second is restored, it waits until second accepted the connection that
+// first dialed while restoring, so that both don't dial each other.
+func (s *restoreSetup) restoreBoth(
+	ctx context.Context,
+	first, second *client.Client,
+	firstParty *restoreParty,
+) (firstChs, secondChs []*client.Channel) {
+	firstChs, err := first.Restore(ctx, s.lookup)
+	require.NoError(s.t, err)
+	test.Eventually(s.t, func(t test.T) {
+		assert.True(t, second.HasPeer(firstParty.id.Address()))
+	}, time.Second, 10*time.Millisecond)
+	secondChs, err = second.Restore(ctx, s.lookup)
+	require.NoError(s.t, err)
+	return firstChs, secondChs
+}
+
+// persistedChannel is a two-party channel whose state machines are driven
+// directly on the persistence of the parties, without running clients.
+type persistedChannel struct {
+	t  *testing.T
+	ms [2]persistence.StateMachine
+}
+
+// newPersistedChannel creates and persists the state machines of a new
+// channel between the parties with the given balances. The machines are in
+// the InitSigning phase.
+func (s *restoreSetup) newPersistedChannel(
+	ctx context.Context,
+	parties [2]*restoreParty,
+	parent *channel.ID,
+	bal0, bal1 int64,
+) *persistedChannel {
+	return s.newPersistedChannelWithAgreement(ctx, parties, parent, bal0, bal1, nil)
+}
+
+// newPersistedChannelWithAgreement is like newPersistedChannel, but also
+// persists the funding agreement.
+func (s *restoreSetup) newPersistedChannelWithAgreement(
+	ctx context.Context,
+	parties [2]*restoreParty,
+	parent *channel.ID,
+	bal0, bal1 int64,
+	agreement [][]channel.Bal,
+) *persistedChannel {
+	parts := []wallet.Address{parties[0].acc.Address(), parties[1].acc.Address()}
+	peers := []wallet.Address{parties[0].id.Address(), parties[1].id.Address()}
+	params := channel.NewParamsUnsafe(10, parts, payment.AppDef(), big.NewInt(s.rng.Int63()))
+	alloc := channel.Allocation{
+		Assets:  []channel.Asset{channeltest.NewRandomAsset(s.rng)},
+		OfParts: [][]channel.Bal{{big.NewInt(bal0)}, {big.NewInt(bal1)}},
+	}
+
+	pch := &persistedChannel{t: s.t}
+	for i, p := range parties {
+		m, err := channel.NewStateMachine(p.acc, *params)
+		require.NoError(s.t, err)
+		require.NoError(s.t, p.pr.ChannelCreated(ctx, m, peers, parent, agreement))
+		pch.ms[i] = persistence.FromStateMachine(m, p.pr)
+		require.NoError(s.t, pch.ms[i].Init(ctx, alloc.Clone(), new(payment.NoData)))
+	}
+	return pch
+}
+
+// sign adds the signature of participant signer on its staging state to the
+// staging state of participant to.
+func (c *persistedChannel) sign(ctx context.Context, signer, to int) {
+	sig, err := c.ms[signer].Sig(ctx)
+	require.NoError(c.t, err)
+	if signer != to {
+		require.NoError(c.t, c.ms[to].AddSig(ctx, channel.Index(signer), sig))
+	}
+}
+
+// exchangeSigs lets all participants sign the staging state.
+func (c *persistedChannel) exchangeSigs(ctx context.Context) {
+	for signer := range c.ms {
+		for to := range c.ms {
+			c.sign(ctx, signer, to)
+		}
+	}
+}
+
+// initialize exchanges the signatures on the initial state, which brings the
+// channel into the Funding phase.
+func (c *persistedChannel) initialize(ctx context.Context) {
+	c.exchangeSigs(ctx)
+	for i := range c.ms {
+		require.NoError(c.t, c.ms[i].EnableInit(ctx))
+	}
+}
+
+// fund brings the channel into the Acting phase.
+func (c *persistedChannel) fund(ctx context.Context) {
+	for i := range c.ms {
+		require.NoError(c.t, c.ms[i].SetFunded(ctx))
+	}
+}
+
+// stage stages the update of participant 0 at the machine of participant idx.
+func (c *persistedChannel) stage(ctx context.Context, idx int, state *channel.State) {
+	require.NoError(c.t, c.ms[idx].Update(ctx, state.Clone(), 0))
+}
+
+// enable enables the fully signed staging state.
+func (c *persistedChannel) enable(ctx context.Context) {
+	for i := range c.ms {
+		require.NoError(c.t, c.ms[i].EnableUpdate(ctx))
+	}
+}
+
+// recordingFunder sends the requests of all funded channels on funded.
+type recordingFunder struct {
+	funded chan channel.FundingReq
+}
+
+func (f *recordingFunder) Fund(_ context.Context, req channel.FundingReq) error {
+	f.funded <- req
+	return nil
+}
+
+// pay sends amount from participant 0 to 1 in the given channel.
+func pay(ctx context.Context, t *testing.T, ch *client.Channel, amount int64) {
+	require.NoError(t, ch.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(amount))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(amount))
+		return nil
+	}))
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_Settle(t *testing.T) {
+	tests := []struct {
+		name string
+		// bobAcceptsFinal determines whether Bob accepts the final state.
+		bobAcceptsFinal bool
+	}{
+		{"cooperative", true},
+		{"dispute", false},
+	}
+
+	for i, tt := range tests {
+		tt := tt
+		rng := rand.New(rand.NewSource(int64(0x5e771e + i)))
+		t.Run(tt.name, func(t *testing.T) {
+			var hub peertest.ConnHub
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			bobHandler := &virtualPropHandler{
+				t:        t,
+				acc:      wallettest.NewRandomAccount(rng),
+				chans:    make(chan *client.Channel, 1),
+				noListen: true,
+			}
+			adj := newSettleAdjudicator()
+			alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, adj)
+			defer func() {
+				assert.NoError(t, alice.Close())
+				assert.NoError(t, bob.Close())
+			}()
+
+			handled := make(chan struct{}, 1)
+			go bobCh.ListenUpdates(client.UpdateHandlerFunc(func(up client.ChannelUpdate, res *client.UpdateResponder) {
+				defer func() { handled <- struct{}{} }()
+				if up.State.IsFinal && !tt.bobAcceptsFinal {
+					assert.NoError(t, res.Reject(ctx, "no final state"))
+					return
+				}
+				assert.NoError(t, res.Accept(ctx))
+			}))
+			pay(ctx, t, ch, 10)
+			<-handled
+
+			require.NoError(t, ch.Settle(ctx))
+			<-handled
+			assert.Equal(t, channel.Settled, ch.Phase())
+
+			reg, wd := <-adj.registered, <-adj.withdrawn
+			assert.Equal(t, tt.bobAcceptsFinal, reg.Tx.IsFinal)
+			assert.Equal(t, reg.Tx.Version, wd.Tx.Version)
+			if tt.bobAcceptsFinal {
+				assert.Equal(t, uint64(2), reg.Tx.Version)
+			} else {
+				assert.Equal(t, uint64(1), reg.Tx.Version)
+			}
+			assertLedgerBals(t, reg.Tx.State, 90, 110, 0)
+
+			assert.Error(t, ch.Settle(ctx), "settling twice")
+		})
+	}
+}
+
+// settleAdjudicator is an Adjudicator that reports Register and Withdraw
+// calls. Final states can be withdrawn immediately, non-final states after a
+// short challenge duration.
+type settleAdjudicator struct {
+	registered, withdrawn chan channel.AdjudicatorReq
+}
+
+func newSettleAdjudicator() *settleAdjudicator {
+	return &settleAdjudicator{
+		registered: make(chan channel.AdjudicatorReq, 1),
+		withdrawn:  make(chan channel.AdjudicatorReq, 1),
+	}
+}
+
+func (a *settleAdjudicator) Register(_ context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	a.registered <- req
+	timeout := time.Now()
+	if !req.Tx.IsFinal {
+		timeout = timeout.Add(100 * time.Millisecond)
+	}
+	return &channel.Registered{ID: req.Params.ID(), Idx: req.Idx, Version: req.Tx.Version, Timeout: timeout}, nil
+}
+
+func (a *settleAdjudicator) Withdraw(_ context.Context, req channel.AdjudicatorReq) error {
+	a.withdrawn <- req
+	return nil
+}
+
+func (a *settleAdjudicator) SubscribeRegistered(context.Context, *channel.Params) (channel.RegisteredSubscription, error) {
+	return nil, nil
+}
+
+func TestChannel_RecoverDeposit(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x2ec0))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	adj := newSettleAdjudicator()
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t}, timeoutFunder{}, adj)
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	ch, err := alice.ProposeChannel(ctx, prop)
+	require.True(t, channel.IsFundingTimeoutError(err), "funding should time out: %v", err)
+	require.NotNil(t, ch)
+	assert.Equal(t, channel.Funding, ch.Phase())
+
+	require.NoError(t, ch.RecoverDeposit(ctx))
+	assert.Equal(t, channel.Settled, ch.Phase())
+	reg, wd := <-adj.registered, <-adj.withdrawn
+	assert.Equal(t, uint64(0), reg.Tx.Version)
+	assert.Equal(t, uint64(0), wd.Tx.Version)
+
+	assert.Error(t, ch.RecoverDeposit(ctx), "recovering twice")
+}
+
+// timeoutFunder is a Funder whose peers never fund in time.
+type timeoutFunder struct{}
+
+func (timeoutFunder) Fund(_ context.Context, req channel.FundingReq) error {
+	return channel.NewFundingTimeoutError([]*channel.AssetFundingError{
+		{Asset: 0, TimedOutPeers: []channel.Index{req.Idx ^ 1}},
+	})
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/peer"
+)
+
+type (
+	// ShutdownPolicy determines what Client.Shutdown does with the open
+	// channels.
+	ShutdownPolicy int
+
+	// A ShutdownError aggregates all errors that occurred during
+	// Client.Shutdown.
+	ShutdownError struct {
+		Errors []error
+	}
+
+	// shutdown tracks the listeners and running proposal protocols of a
+	// client, so that Shutdown can stop them.
+	shutdown struct {
+		mutex     sync.Mutex
+		policy    ShutdownPolicy
+		listeners []peer.Listener
+		running   int           // number of running proposal protocols
+		stopping  bool          // whether new protocols are rejected
+		idle      chan struct{} // closed when stopping and nothing is running
+	}
+)
+
+const (
+	// ShutdownPersist closes the open channels without settling them. If
+	// persistence is enabled, they can be restored with Client.Restore after
+	// a restart.
+	ShutdownPersist ShutdownPolicy = iota
+	// ShutdownSettle settles all open channels, see Channel.Settle.
+	// Sub-channels and virtual channels are settled before their parents.
+	ShutdownSettle
+)
+
+// shutdownReason is the reason of rejections of proposals during shutdown.
+const shutdownReason = "client shutting down"
+
+// SetShutdownPolicy sets what Shutdown does with the open channels. The
+// default is ShutdownPersist.
+func (c *Client) SetShutdownPolicy(p ShutdownPolicy) {
+	c.shutdown.mutex.Lock()
+	defer c.shutdown.mutex.Unlock()
+	c.shutdown.policy = p
+}
+
+// Shutdown gracefully shuts the client down, so that services can restart
+// cleanly. It closes all listeners that were passed to Listen and rejects new
+// channel proposals. It waits until the running proposal protocols and the
+// running updates of each channel are finished. Then it settles or persists
+// the open channels according to the ShutdownPolicy and closes the client,
+// see Close. Protocols that are still running when ctx is done are aborted by
+// closing the client.
+//
+// All errors are returned together in a ShutdownError.
+func (c *Client) Shutdown(ctx context.Context) error {
+	listeners, idle, policy := c.shutdown.stop()
+
+	var errs []error
+	for _, l := range listeners {
+		if err := l.Close(); err != nil {
+			errs = append(errs, errors.WithMessage(err, "closing listener"))
+		}
+	}
+
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		errs = append(errs, errors.WithMessage(ctx.Err(), "waiting for running proposals"))
+	}
+
+	for _, ch := range c.channels.shutdownOrder() {
+		if err := c.shutdownChannel(ctx, ch, policy); err != nil {
+			errs = append(errs, errors.WithMessagef(err, "shutting down channel %x", ch.ID()))
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return &ShutdownError{Errors: errs}
+	}
+	return nil
+}
+
+// shutdownChannel waits for the running update of the channel and settles it
+// if the policy says so. The channel is closed with the client.
+func (c *Client) shutdownChannel(ctx context.Context, ch *Channel, policy ShutdownPolicy) error {
+	var phase channel.Phase
+	idle := make(chan struct{})
+	go func() { // finishes at the latest when Close aborts the update
+		ch.machMtx.RLock()
+		phase = ch.machine.Phase()
+		ch.machMtx.RUnlock()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		return errors.WithMessage(ctx.Err(), "waiting for running update")
+	}
+
+	if policy != ShutdownSettle || (phase != channel.Acting && phase != channel.Final) {
+		return nil
+	}
+	return errors.WithMessage(ch.Settle(ctx), "settling")
+}
+
+// rejectShutdown rejects a proposal that was received during shutdown.
+func (c *Client) rejectShutdown(p *peer.Peer, req proposalMsg) {
+	ctx, cancel := context.WithTimeout(context.Background(), proposalAbortTimeout)
+	defer cancel()
+	if err := c.handleChannelProposalRej(ctx, p, req, shutdownReason); err != nil {
+		c.logPeer(p).Warnf("rejecting proposal during shutdown: %v", err)
+	}
+}
+
+// Error returns all errors of the shutdown.
+func (e *ShutdownError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("shutdown: [%s]", strings.Join(msgs, "; "))
+}
+
+// IsShutdownError checks whether an error is a ShutdownError.
+func IsShutdownError(err error) bool {
+	_, ok := errors.Cause(err).(*ShutdownError)
+	return ok
+}
+
+// addListener adds a listener that is closed on shutdown. It returns false if
+// the client is already shutting down.
+func (s *shutdown) addListener(l peer.Listener) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopping {
+		return false
+	}
+	s.listeners = append(s.listeners, l)
+	return true
+}
+
+// begin registers a running proposal protocol, which has to call end when it
+// is finished. It fails if the client is shutting down.
+func (s *shutdown) begin() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopping {
+		return errors.New(shutdownReason)
+	}
+	s.running++
+	return nil
+}
+
+// end marks a proposal protocol as finished.
+func (s *shutdown) end() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running--
+	if s.stopping && s.running == 0 {
+		close(s.idle)
+	}
+}
+
+// isStopping returns whether the client is shutting down.
+func (s *shutdown) isStopping() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopping
+}
+
+// stop starts the shutdown. It returns the listeners to close, a channel that
+// is closed when no proposal protocol runs anymore, and the policy.
+func (s *shutdown) stop() ([]peer.Listener, <-chan struct{}, ShutdownPolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.stopping {
+		s.stopping = true
+		s.idle = make(chan struct{})
+		if s.running == 0 {
+			close(s.idle)
+		}
+	}
+	listeners := s.listeners
+	s.listeners = nil
+	return listeners, s.idle, s.policy
+}
+
+// shutdownOrder returns all channels, sub-channels and virtual channels
+// before ledger channels.
+func (r *chanRegistry) shutdownOrder() []*Channel {
+	chans := r.Snapshot()
+	sort.SliceStable(chans, func(i, j int) bool {
+		return chans[i].parent != nil && chans[j].parent == nil
+	})
+	return chans
+}
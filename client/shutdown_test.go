@@ -0,0 +1,98 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestClient_Shutdown(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy client.ShutdownPolicy
+	}{
+		{"persist", client.ShutdownPersist},
+		{"settle", client.ShutdownSettle},
+	}
+
+	for i, tt := range tests {
+		tt := tt
+		rng := rand.New(rand.NewSource(int64(0x5407 + i)))
+		t.Run(tt.name, func(t *testing.T) {
+			var hub peertest.ConnHub
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			bobHandler := &virtualPropHandler{
+				t:        t,
+				acc:      wallettest.NewRandomAccount(rng),
+				chans:    make(chan *client.Channel, 1),
+				noListen: true,
+			}
+			adj := newSettleAdjudicator()
+			alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, adj)
+			defer func() { assert.NoError(t, bob.Shutdown(ctx)) }()
+			go bobCh.ListenUpdates(client.UpdateHandlerFunc(func(_ client.ChannelUpdate, res *client.UpdateResponder) {
+				assert.NoError(t, res.Accept(ctx))
+			}))
+
+			alice.SetShutdownPolicy(tt.policy)
+			require.NoError(t, alice.Shutdown(ctx))
+
+			if tt.policy == client.ShutdownSettle {
+				assert.Equal(t, channel.Settled, ch.Phase())
+				reg := <-adj.registered
+				assert.True(t, reg.Tx.IsFinal)
+			} else {
+				assert.Equal(t, channel.Acting, ch.Phase())
+				assert.Len(t, adj.registered, 0)
+			}
+
+			// No new proposals are accepted.
+			prop := newTestProposal(rng, channeltest.NewRandomAsset(rng),
+				ch.Params().Parts[0], ch.Params().Parts[1], 10, 10)
+			_, err := alice.ProposeChannel(ctx, prop)
+			assert.Error(t, err)
+			assert.Error(t, alice.Shutdown(ctx), "shutting down twice")
+		})
+	}
+}
+
+func TestClient_Shutdown_Timeout(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5408))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	alice, bob, _, _ := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, newSettleAdjudicator())
+	defer bob.Close()
+
+	// Bob does not respond to updates, so the final update cannot finish.
+	alice.SetShutdownPolicy(client.ShutdownSettle)
+	sctx, scancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer scancel()
+	err := alice.Shutdown(sctx)
+	require.Error(t, err)
+	assert.True(t, client.IsShutdownError(err))
+}
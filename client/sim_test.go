@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	simchannel "perun.network/go-perun/backend/sim/channel"
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+// TestClient_SimBackend runs two clients end-to-end on a simulated ledger.
+func TestClient_SimBackend(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5133))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	ledger := simchannel.NewLedger("")
+
+	// Alice's first withdrawal fails as if the transaction was dropped.
+	failWithdraw := true
+	aliceAdj := simchannel.NewScriptedAdjudicator(ledger, simchannel.Script{
+		Withdraw: func(context.Context, channel.AdjudicatorReq) error {
+			if failWithdraw {
+				failWithdraw = false
+				return errors.New("transaction dropped")
+			}
+			return nil
+		},
+	})
+
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice := client.New(aliceID, hub.NewDialer(), &virtualPropHandler{t: t},
+		simchannel.NewFunder(ledger), aliceAdj)
+	defer alice.Close()
+	bob := client.New(bobID, hub.NewDialer(), bobHandler,
+		simchannel.NewFunder(ledger), simchannel.NewAdjudicator(ledger))
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, simchannel.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	aliceCh, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	bobCh := <-bobHandler.chans
+
+	require.NoError(t, aliceCh.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+		return nil
+	}))
+
+	assert.Error(t, aliceCh.Settle(ctx), "scripted withdrawal failure")
+	require.NoError(t, aliceCh.Settle(ctx))
+	require.NoError(t, bobCh.Settle(ctx))
+
+	reg := ledger.Registered(aliceCh.ID())
+	require.NotNil(t, reg)
+	assert.Equal(t, aliceCh.State().Version, reg.Version)
+	for i, bal := range []int64{90, 110} {
+		withdrawn := ledger.Withdrawn(aliceCh.ID(), channel.Index(i))
+		require.Len(t, withdrawn, 1)
+		assert.Zero(t, withdrawn[0].Cmp(big.NewInt(bal)), "withdrawn[%d]: %v != %v", i, withdrawn[0], bal)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+)
+
+// snapshotVersion is the version of the snapshot encoding. It is increased
+// with every incompatible change of the encoding.
+const snapshotVersion uint8 = 2
+
+// snapshot is the content of a channel snapshot, see Channel.Snapshot. It is
+// encoded as the version, the body and the signature of our participant on
+// the version and body.
+type snapshot persistence.Channel
+
+// Snapshot returns a signed snapshot of the channel, which contains its
+// parameters, its current transaction, its funding agreement and its phase,
+// and thereby its funding status. The snapshot can be imported on another machine with
+// Client.ImportSnapshot to migrate the channel, or stored as a backup that is
+// independent of the persistence backend. The snapshot is signed by our
+// participant account and its encoding is versioned.
+//
+// After the snapshot is imported, the channel must not be used here anymore,
+// otherwise the imported channel falls behind. A snapshot cannot be taken
+// while the channel is being initialized, an update is in progress, or after
+// it was settled.
+func (c *Channel) Snapshot() ([]byte, error) {
+	c.machMtx.RLock()
+	defer c.machMtx.RUnlock()
+
+	switch phase := c.machine.Phase(); phase {
+	case channel.InitActing, channel.InitSigning, channel.Signing, channel.Settled:
+		return nil, errors.Errorf("cannot take snapshot in phase %v", phase)
+	}
+
+	s := &snapshot{PeersV: c.peerAddrs, Agreement: c.agreement}
+	s.IdxV = c.machine.Idx()
+	s.ParamsV = c.machine.Params()
+	s.CurrentTXV = c.machine.CurrentTX()
+	s.PhaseV = c.machine.Phase()
+	if c.parent != nil {
+		id := c.parent.ID()
+		s.Parent = &id
+	}
+
+	var buf bytes.Buffer
+	if err := wire.Encode(&buf, snapshotVersion, s); err != nil {
+		return nil, errors.WithMessage(err, "encoding snapshot")
+	}
+	sig, err := c.machine.Account().SignData(buf.Bytes())
+	if err != nil {
+		return nil, errors.WithMessage(err, "signing snapshot")
+	}
+	if err := wire.Encode(&buf, wallet.Sig(sig)); err != nil {
+		return nil, errors.WithMessage(err, "encoding snapshot signature")
+	}
+	return buf.Bytes(), nil
+}
+
+// ImportSnapshot imports a channel from a snapshot that was created with
+// Channel.Snapshot, e.g., on another machine. acc is the account of our
+// participant in the channel, which must have signed the snapshot. The
+// snapshot's network identity of our participant must be one of the client's
+// identities. The parent channel of a virtual or sub-channel must be imported
+// or restored first.
+//
+// The imported channel is persisted by the client's persister and added to
+// the channel registry like a restored channel, see Restore: It is funded
+// again if it was still in the Funding phase and synchronized with its peers.
+// The user should start the update handler with Channel.ListenUpdates.
+func (c *Client) ImportSnapshot(ctx context.Context, data []byte, acc wallet.Account) (*Channel, error) {
+	r := bytes.NewReader(data)
+	var version uint8
+	if err := wire.Decode(r, &version); err != nil {
+		return nil, errors.WithMessage(err, "decoding snapshot version")
+	} else if version != snapshotVersion {
+		return nil, errors.Errorf("unsupported snapshot version %d", version)
+	}
+	s := new(snapshot)
+	if err := wire.Decode(r, s); err != nil {
+		return nil, errors.WithMessage(err, "decoding snapshot")
+	}
+	signed := data[:len(data)-r.Len()]
+	sig, err := wallet.DecodeSig(r)
+	if err != nil {
+		return nil, errors.WithMessage(err, "decoding snapshot signature")
+	}
+
+	if err := c.validSnapshot(s, signed, sig, acc.Address()); err != nil {
+		return nil, errors.WithMessage(err, "invalid snapshot")
+	}
+	if c.channels.Has(s.ID()) {
+		return nil, errors.New("channel already exists")
+	}
+	parents := make(map[channel.ID]*Channel)
+	if s.Parent != nil {
+		parent, ok := c.channels.Get(*s.Parent)
+		if !ok {
+			return nil, errors.Errorf("parent channel %x unknown", *s.Parent)
+		}
+		parents[parent.ID()] = parent
+	}
+
+	pch := (*persistence.Channel)(s)
+	if err := c.pr.ChannelCreated(ctx, pch, pch.PeersV, pch.Parent, pch.Agreement); err != nil {
+		return nil, errors.WithMessage(err, "persisting imported channel")
+	}
+	ch, err := c.restoreChannel(ctx, pch,
+		func(wallet.Address) (wallet.Account, error) { return acc, nil }, parents)
+	if err != nil {
+		if rerr := c.pr.ChannelRemoved(ctx, pch.ID()); rerr != nil {
+			c.logChan(pch.ID()).Warnf("removing channel after failed import: %v", rerr)
+		}
+		return nil, err
+	} else if ch == nil {
+		return nil, errors.New("channel not worth importing")
+	}
+	return ch, nil
+}
+
+// validSnapshot checks that the decoded snapshot s was signed by part, which
+// is our participant, and that its current transaction is fully signed.
+func (c *Client) validSnapshot(s *snapshot, signed []byte, sig wallet.Sig, part wallet.Address) error {
+	params := s.ParamsV
+	if int(s.IdxV) >= len(params.Parts) || len(s.PeersV) != len(params.Parts) {
+		return errors.New("snapshot does not match channel parameters")
+	}
+	if !params.Parts[s.IdxV].Equals(part) {
+		return errors.New("account is not our participant")
+	}
+	if !c.isIdentity(s.PeersV[s.IdxV]) {
+		return errors.Errorf("network identity %v unknown", s.PeersV[s.IdxV])
+	}
+	if ok, err := wallet.VerifySignature(signed, sig, part); err != nil {
+		return errors.WithMessage(err, "verifying snapshot signature")
+	} else if !ok {
+		return errors.New("invalid snapshot signature")
+	}
+
+	tx := s.CurrentTXV
+	if tx.State == nil || tx.ID != params.ID() || len(tx.Sigs) != len(params.Parts) {
+		return errors.New("invalid current transaction")
+	}
+	for i, sig := range tx.Sigs {
+		if sig == nil {
+			return errors.Errorf("missing signature %d on current state", i)
+		}
+		if ok, err := channel.Verify(params.Parts[i], params, tx.State, sig); err != nil {
+			return errors.WithMessagef(err, "verifying signature %d on current state", i)
+		} else if !ok {
+			return errors.Errorf("invalid signature %d on current state", i)
+		}
+	}
+	return nil
+}
+
+// Encode encodes the snapshot body.
+func (s *snapshot) Encode(w io.Writer) error {
+	if err := wire.Encode(w, s.IdxV, s.ParamsV, uint8(s.PhaseV),
+		syncTxEncoder(s.CurrentTXV), s.Parent != nil); err != nil {
+		return err
+	}
+	if s.Parent != nil {
+		if err := wire.Encode(w, *s.Parent); err != nil {
+			return errors.WithMessage(err, "encoding parent")
+		}
+	}
+	if err := wire.Encode(w, channel.Index(len(s.PeersV))); err != nil {
+		return errors.WithMessage(err, "encoding number of peers")
+	}
+	for i, addr := range s.PeersV {
+		if err := addr.Encode(w); err != nil {
+			return errors.WithMessagef(err, "encoding peer %d", i)
+		}
+	}
+	if err := wire.Encode(w, s.Agreement != nil); err != nil {
+		return err
+	}
+	return errors.WithMessage(encodeBals(w, s.Agreement), "encoding funding agreement")
+}
+
+// Decode decodes a snapshot body.
+func (s *snapshot) Decode(r io.Reader) (err error) {
+	var phase uint8
+	var hasParent bool
+	s.ParamsV = new(channel.Params)
+	if err := wire.Decode(r, &s.IdxV, s.ParamsV, &phase,
+		(*syncTxDecoder)(&s.CurrentTXV), &hasParent); err != nil {
+		return err
+	}
+	s.PhaseV = channel.Phase(phase)
+	if hasParent {
+		s.Parent = new(channel.ID)
+		if err := wire.Decode(r, s.Parent); err != nil {
+			return errors.WithMessage(err, "decoding parent")
+		}
+	}
+
+	var n channel.Index
+	if err := wire.Decode(r, &n); err != nil {
+		return errors.WithMessage(err, "decoding number of peers")
+	} else if n > channel.MaxNumParts {
+		return errors.Errorf("too many peers, got: %d max: %d", n, channel.MaxNumParts)
+	}
+	s.PeersV = make([]wallet.Address, n)
+	for i := range s.PeersV {
+		if s.PeersV[i], err = wallet.DecodeAddress(r); err != nil {
+			return errors.WithMessagef(err, "decoding peer %d", i)
+		}
+	}
+
+	var hasAgreement bool
+	if err := wire.Decode(r, &hasAgreement); err != nil || !hasAgreement {
+		return err
+	}
+	state := s.CurrentTXV.State
+	if state == nil {
+		return errors.New("funding agreement without current state")
+	}
+	s.Agreement, err = decodeBals(r, len(state.OfParts), len(state.Assets))
+	return errors.WithMessage(err, "decoding funding agreement")
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/persistence/keyvalue"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_Snapshot(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5a95))
+	s := newRestoreSetup(t, rng)
+	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Second)
+	defer cancel()
+	alice, bob := s.newParty("Alice"), s.newParty("Bob")
+
+	aliceCl, _ := s.start(alice, nil)
+	bobCl, bobHandler := s.start(bob, nil)
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), alice.id.Address(), bob.id.Address(), 100, 100)
+	prop.Account = alice.acc
+	aliceCh, err := aliceCl.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	bobCh := <-bobHandler.chans
+	bobUps := make(chan *channel.State, 1)
+	bobCh.SubUpdates(bobUps)
+	pay(ctx, t, aliceCh, 10)
+	<-bobUps
+
+	snap, err := aliceCh.Snapshot()
+	require.NoError(t, err)
+	require.NoError(t, aliceCl.Close())
+	require.NoError(t, bobCl.Close())
+
+	// Alice migrates to a new machine without her persisted data.
+	migrated := &restoreParty{name: "Alice", id: alice.id, acc: alice.acc,
+		pr: keyvalue.NewPersistRestorer(memorydb.NewDatabase())}
+	bobCl, _ = s.start(bob, nil)
+	aliceCl, _ = s.start(migrated, nil)
+	defer func() {
+		assert.NoError(t, aliceCl.Close())
+		assert.NoError(t, bobCl.Close())
+	}()
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := aliceCl.ImportSnapshot(ctx, snap, wallettest.NewRandomAccount(rng))
+		assert.Error(t, err, "wrong account")
+		tampered := append([]byte(nil), snap...)
+		tampered[len(tampered)/2] ^= 1
+		_, err = aliceCl.ImportSnapshot(ctx, tampered, alice.acc)
+		assert.Error(t, err, "tampered snapshot")
+		_, err = aliceCl.ImportSnapshot(ctx, append([]byte{0xff}, snap[1:]...), alice.acc)
+		assert.Error(t, err, "unknown version")
+		_, err = bobCl.ImportSnapshot(ctx, snap, alice.acc)
+		assert.Error(t, err, "unknown identity")
+	})
+
+	aliceCh, err = aliceCl.ImportSnapshot(ctx, snap, alice.acc)
+	require.NoError(t, err)
+	assert.Equal(t, prop.Account.Address(), aliceCh.Params().Parts[aliceCh.Idx()])
+	assert.Equal(t, channel.Acting, aliceCh.Phase())
+	assertLedgerBals(t, aliceCh.State(), 90, 110, 0)
+	_, err = aliceCl.ImportSnapshot(ctx, snap, alice.acc)
+	assert.Error(t, err, "importing twice")
+	peers, err := migrated.pr.ActivePeers(ctx)
+	require.NoError(t, err)
+	assert.Len(t, peers, 2, "imported channel persisted")
+
+	// The imported channel continues with Bob's restored channel.
+	test.Eventually(t, func(t test.T) {
+		assert.True(t, bobCl.HasPeer(alice.id.Address()))
+	}, time.Second, 10*time.Millisecond)
+	bobChs, err := bobCl.Restore(ctx, s.lookup)
+	require.NoError(t, err)
+	require.Len(t, bobChs, 1)
+	bobChs[0].SubUpdates(bobUps)
+	go bobChs[0].ListenUpdates(acceptAllUpdates{t})
+	pay(ctx, t, aliceCh, 10)
+	<-bobUps
+	assertLedgerBals(t, bobChs[0].State(), 80, 120, 0)
+}
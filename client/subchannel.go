@@ -0,0 +1,427 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/pkg/sync/atomic"
+	"perun.network/go-perun/wallet"
+)
+
+// subChannelTimeout is the time that is used for handling sub-channel funding
+// and settlement requests in the parent channel. It is also used when a
+// sub-channel proposal is rejected automatically.
+var subChannelTimeout = 10 * time.Second
+
+type (
+	// SubChannelProposal contains all data necessary to propose a new
+	// sub-channel to the peer of a ledger channel. A sub-channel is funded
+	// off-chain by locking funds in its parent ledger channel, which has the
+	// same peers.
+	//
+	// The PeerAddrs of the embedded ChannelProposal must be the peers of the
+	// parent channel.
+	SubChannelProposal struct {
+		ChannelProposal
+		// Parent is the ledger channel that funds the sub-channel.
+		Parent *Channel
+	}
+
+	// A SubProposalHandler decides how to handle incoming sub-channel
+	// proposals. It is an optional extension of the ProposalHandler. If the
+	// ProposalHandler passed to the Client doesn't implement it, all
+	// sub-channel proposals are rejected.
+	SubProposalHandler interface {
+		// HandleSub is the user callback called by the Client on an incoming
+		// sub-channel proposal.
+		HandleSub(*SubChannelProposalReq, *SubProposalResponder)
+	}
+
+	// SubProposalResponder lets the user respond to a sub-channel proposal. If
+	// the user wants to accept the proposal, they should call Accept(),
+	// otherwise Reject(). Only a single function must be called and every
+	// further call causes a panic.
+	SubProposalResponder struct {
+		client *Client
+		peer   *peer.Peer
+		req    *SubChannelProposalReq
+		called atomic.Bool
+
+		stopAbortCache context.CancelFunc // stops caching an early abort
+	}
+
+	// SubProposalAcc is the proposal acceptance struct that the user passes to
+	// SubProposalResponder.Accept() when they want to accept an incoming
+	// sub-channel proposal. The parent channel is the one named in the
+	// proposal.
+	SubProposalAcc struct {
+		Participant wallet.Account
+	}
+)
+
+// Accept lets the user signal that they want to accept the sub-channel
+// proposal. Panics if the proposal was already accepted or rejected.
+func (r *SubProposalResponder) Accept(ctx context.Context, acc SubProposalAcc) (*Channel, error) {
+	if ctx == nil {
+		return nil, errors.New("context must not be nil")
+	}
+	if !r.called.TrySet() {
+		log.Panic("multiple calls on proposal responder")
+	}
+
+	defer r.stopAbortCache()
+	return r.client.handleSubChannelProposalAcc(ctx, r.peer, r.req, acc)
+}
+
+// Reject lets the user signal that they reject the sub-channel proposal.
+// Panics if the proposal was already accepted or rejected.
+func (r *SubProposalResponder) Reject(ctx context.Context, reason string) error {
+	if !r.called.TrySet() {
+		log.Panic("multiple calls on proposal responder")
+	}
+	if ctx == nil {
+		log.Panic("nil context")
+	}
+
+	defer r.stopAbortCache()
+	return r.client.handleChannelProposalRej(ctx, r.peer, r.req, reason)
+}
+
+// AsReq returns a shallow copy of the SubChannelProposal as a
+// SubChannelProposalReq, i.e., as a wire message.
+func (c *SubChannelProposal) AsReq() *SubChannelProposalReq {
+	return &SubChannelProposalReq{
+		ChannelProposalReq: *c.ChannelProposal.AsReq(),
+		Parent:             c.Parent.ID(),
+	}
+}
+
+// ProposeSubChannel attempts to open a sub-channel with the parameters and
+// peer from SubChannelProposal prop:
+// - the proposal is sent to the peer and if it accepts,
+// - the channel is funded by locking funds in the parent ledger channel. If
+//   the peer accepts the parent update,
+// - the channel controller is returned.
+// The user is required to start the update handler with
+// Channel.ListenUpdates(UpdateHandler)
+func (c *Client) ProposeSubChannel(ctx context.Context, prop *SubChannelProposal) (*Channel, error) {
+	if ctx == nil || prop == nil {
+		c.log.Panic("invalid nil argument")
+	}
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
+
+	// 1. check valid proposal
+	req := prop.AsReq()
+	if err := c.validTwoPartyProposal(&req.ChannelProposalReq, 0, req.PeerAddrs[1]); err != nil {
+		return nil, errors.WithMessage(err, "invalid channel proposal")
+	}
+	if err := validSubParent(prop.Parent, req.PeerAddrs[1], &req.ChannelProposalReq); err != nil {
+		return nil, errors.WithMessage(err, "invalid parent channel")
+	}
+
+	// 2. send proposal and wait for response
+	params, err := c.exchangeTwoPartyProposal(ctx, req)
+	if err != nil {
+		return nil, errors.WithMessage(err, "sending proposal")
+	}
+
+	// 3. create channel machine from the agreed parameters
+	ch, err := c.initChannel(ctx, &prop.ChannelProposal, params, prop.Parent)
+	if err != nil {
+		return ch, err
+	}
+	prop.Parent.addSubChannel(ch)
+
+	// 4. lock funds in parent channel
+	if err := prop.Parent.fundSubChannel(ctx, ch); err != nil {
+		ch.log.Warnf("error while funding sub-channel: %v", err)
+		return ch, errors.WithMessage(err, "error while funding sub-channel")
+	}
+
+	// 5. return controller on successful funding
+	return ch, c.enableChannel(ctx, ch)
+}
+
+// handleSubChannelProposal implements the receiving side of the two-party
+// sub-channel proposal protocol.
+func (c *Client) handleSubChannelProposal(ident *identity, p *peer.Peer, req *SubChannelProposalReq) {
+	if c.shutdown.isStopping() {
+		c.rejectShutdown(p, req)
+		return
+	}
+	if err := c.validTwoPartyProposal(&req.ChannelProposalReq, 1, p.PerunAddress); err != nil {
+		c.logPeer(p).Debugf("received invalid sub-channel proposal: %v", err)
+		return
+	}
+
+	responder := &SubProposalResponder{client: c, peer: p, req: req,
+		stopAbortCache: cacheProposalAbort(p, req.SessID())}
+	handler, ok := ident.handler.(SubProposalHandler)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), subChannelTimeout)
+		defer cancel()
+		if err := responder.Reject(ctx, "sub-channels not supported"); err != nil {
+			c.logPeer(p).Warnf("rejecting sub-channel proposal: %v", err)
+		}
+		return
+	}
+
+	c.logPeer(p).Trace("calling sub-channel proposal handler")
+	handler.HandleSub(req, responder)
+}
+
+func (c *Client) handleSubChannelProposalAcc(
+	ctx context.Context, p *peer.Peer,
+	req *SubChannelProposalReq, acc SubProposalAcc,
+) (*Channel, error) {
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
+
+	if acc.Participant == nil {
+		c.logPeer(p).Error("user returned nil Participant in SubProposalAcc")
+		return nil, errors.New("nil Participant in SubProposalAcc")
+	}
+	parent, ok := c.channels.Get(req.Parent)
+	if !ok {
+		return nil, errors.Errorf("unknown parent channel %x", req.Parent)
+	}
+	if err := validSubParent(parent, p.PerunAddress, &req.ChannelProposalReq); err != nil {
+		return nil, errors.WithMessage(err, "invalid parent channel")
+	}
+
+	// The funding request of the proposer might arrive before the initial
+	// signatures are exchanged, so it is expected before accepting.
+	msgAccept := newProposalAcc(req, acc.Participant.Address())
+	params := req.params(msgAccept)
+	id := params.ID()
+	funded := parent.expectSubFunding(id)
+	defer parent.forgetSubFunding(id)
+
+	// enables caching of incoming version 0 signatures before sending any message
+	// that might trigger a fast peer to send those.
+	enableVer0Cache(ctx, p)
+
+	abort, err := c.sendProposalAcc(ctx, p, msgAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer abort.stop()
+	ctx = abort.ctx
+
+	ch, err := c.initChannel(ctx, req.AsProp(acc.Participant), params, parent)
+	if err != nil {
+		return ch, abort.wrap(err)
+	}
+	parent.addSubChannel(ch)
+
+	select {
+	case <-funded:
+	case <-ctx.Done():
+		return ch, abort.wrap(errors.WithMessage(ctx.Err(), "waiting for sub-channel funding"))
+	}
+	return ch, abort.wrap(c.enableChannel(ctx, ch))
+}
+
+// isSubChannel returns whether a channel with the given peers and parent is a
+// sub-channel. All peers of a sub-channel are peers of its parent, whereas the
+// peer of a virtual channel is not a peer of the parent, but the intermediary
+// is.
+func (c *Client) isSubChannel(peers []wallet.Address, parent *Channel) bool {
+	if parent == nil {
+		return false
+	}
+	for _, p := range peers {
+		if !c.isIdentity(p) && !parent.conn.HasPeer(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// validSubParent checks that the parent channel can fund a sub-channel with
+// the given peer and proposal.
+func validSubParent(parent *Channel, peer peer.Address, req *ChannelProposalReq) error {
+	if parent == nil {
+		return errors.New("parent channel must not be nil")
+	}
+	if parent.Parent() != nil {
+		return errors.New("parent channel must be a ledger channel")
+	}
+	if len(parent.Params().Parts) != 2 {
+		return errors.New("parent channel must be a two-party channel")
+	}
+	if !parent.conn.HasPeer(peer) {
+		return errors.New("peer is not a peer of the parent channel")
+	}
+	if !channel.EqualAssets(parent.State().Assets, req.InitBals.Assets) {
+		return errors.New("assets of parent and sub-channel don't match")
+	}
+	if req.FundingAgreement != nil {
+		return errors.New("sub-channels cannot have a funding agreement")
+	}
+	return nil
+}
+
+// fundSubChannel proposes an update of this ledger channel that locks the
+// funds for the given sub-channel, which must be in the Funding phase.
+func (c *Channel) fundSubChannel(ctx context.Context, sub *Channel) error {
+	return c.lockChildFunds(ctx, sub, func(m *msgChannelUpdate, initial virtualChannelTx) channelUpdateReqMsg {
+		return &msgSubChannelFundingProposal{msgChannelUpdate: *m, Initial: initial}
+	})
+}
+
+// settleSubChannel proposes an update of this ledger channel that unlocks the
+// funds of the given sub-channel according to its final state. If the funds
+// are already unlocked, i.e., the peer settled the sub-channel, nothing is
+// done. The sub-channel must be in the Final phase and locked by the caller.
+func (c *Channel) settleSubChannel(ctx context.Context, sub *Channel) error {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if !c.hasLocked(sub.ID()) {
+		delete(c.subs, sub.ID())
+		return nil
+	}
+
+	final := virtualChannelTx{Params: sub.Params(), Tx: sub.machine.AdjudicatorReq().Tx, Idx: sub.Idx()}
+	if err := c.unlockChildFunds(ctx, final, func(m *msgChannelUpdate) channelUpdateReqMsg {
+		return &msgSubChannelSettlementProposal{msgChannelUpdate: *m, Final: final}
+	}); err != nil {
+		return err
+	}
+	delete(c.subs, sub.ID())
+	return nil
+}
+
+// handleSubChannelFundingReq is called on the parent channel on an incoming
+// sub-channel funding proposal. It is only accepted if we accepted the
+// sub-channel proposal before. The machine must be locked by the caller.
+func (c *Channel) handleSubChannelFundingReq(pidx channel.Index, req *msgSubChannelFundingProposal) {
+	ctx, cancel := context.WithTimeout(context.Background(), subChannelTimeout)
+	defer cancel()
+
+	if err := c.checkSubChannelFundingReq(pidx, req); err != nil {
+		c.logPeer(pidx).Warnf("rejecting sub-channel funding: %v", err)
+		if rerr := c.handleUpdateRej(ctx, pidx, &req.msgChannelUpdate, err.Error()); rerr != nil {
+			c.logPeer(pidx).Warnf("sending rejection: %v", rerr)
+		}
+		return
+	}
+
+	if err := c.acceptUpdate(ctx, pidx, &req.msgChannelUpdate, c.machine.UpdateLocked); err != nil {
+		c.logPeer(pidx).Errorf("accepting sub-channel funding: %v", err)
+		return
+	}
+	id := req.Initial.Tx.ID
+	close(c.subFunding[id])
+	delete(c.subFunding, id)
+}
+
+// handleSubChannelSettlementReq is called on the parent channel on an incoming
+// sub-channel settlement proposal. The machine must be locked by the caller.
+func (c *Channel) handleSubChannelSettlementReq(pidx channel.Index, req *msgSubChannelSettlementProposal) {
+	ctx, cancel := context.WithTimeout(context.Background(), subChannelTimeout)
+	defer cancel()
+
+	if err := c.checkSubChannelSettlementReq(pidx, req); err != nil {
+		c.logPeer(pidx).Warnf("rejecting sub-channel settlement: %v", err)
+		if rerr := c.handleUpdateRej(ctx, pidx, &req.msgChannelUpdate, err.Error()); rerr != nil {
+			c.logPeer(pidx).Warnf("sending rejection: %v", rerr)
+		}
+		return
+	}
+
+	if err := c.acceptUpdate(ctx, pidx, &req.msgChannelUpdate, c.machine.UpdateLocked); err != nil {
+		c.logPeer(pidx).Errorf("accepting sub-channel settlement: %v", err)
+		return
+	}
+	delete(c.subs, req.Final.Tx.ID)
+}
+
+// checkSubChannelFundingReq checks that we expect the funding of the
+// sub-channel and that the proposed state locks exactly the funds of its fully
+// signed initial state. The funding must be proposed by the proposer of the
+// sub-channel, who has index 0.
+func (c *Channel) checkSubChannelFundingReq(pidx channel.Index, req *msgSubChannelFundingProposal) error {
+	if _, ok := c.subFunding[req.Initial.Tx.ID]; !ok {
+		return errors.New("unexpected sub-channel funding")
+	}
+	if req.Initial.Idx != 0 {
+		return errors.New("funding must be proposed by the sub-channel proposer")
+	}
+	return c.checkChildFunding(pidx, &req.msgChannelUpdate, req.Initial)
+}
+
+// checkSubChannelSettlementReq checks that the proposed state unlocks the
+// funds of one of our sub-channels according to its fully signed final state.
+func (c *Channel) checkSubChannelSettlementReq(pidx channel.Index, req *msgSubChannelSettlementProposal) error {
+	sub, ok := c.subs[req.Final.Tx.ID]
+	if !ok {
+		return errors.New("unknown sub-channel")
+	}
+	if req.Final.Idx != sub.Idx()^1 {
+		return errors.New("settlement must be proposed by the peer's sub-channel participant")
+	}
+	return c.checkChildSettlement(pidx, &req.msgChannelUpdate, req.Final)
+}
+
+// expectSubFunding records that we accepted the proposal of the sub-channel
+// with the given ID, so that its funding request is accepted. The returned
+// channel is closed once the funds are locked.
+func (c *Channel) expectSubFunding(id channel.ID) <-chan struct{} {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if c.subFunding == nil {
+		c.subFunding = make(map[channel.ID]chan struct{})
+	}
+	funded := make(chan struct{})
+	c.subFunding[id] = funded
+	return funded
+}
+
+// forgetSubFunding removes the expected funding of the sub-channel with the
+// given ID, if it didn't happen yet.
+func (c *Channel) forgetSubFunding(id channel.ID) {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	delete(c.subFunding, id)
+}
+
+// addSubChannel records that this channel funds the given sub-channel.
+func (c *Channel) addSubChannel(sub *Channel) {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if c.subs == nil {
+		c.subs = make(map[channel.ID]*Channel)
+	}
+	c.subs[sub.ID()] = sub
+}
+
+// hasLocked returns whether funds are locked for the channel with the given ID
+// in the current state. The machine must be locked by the caller.
+func (c *Channel) hasLocked(id channel.ID) bool {
+	for _, sub := range c.machine.State().Locked {
+		if sub.ID == id {
+			return true
+		}
+	}
+	return false
+}
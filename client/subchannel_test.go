@@ -0,0 +1,78 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+)
+
+func TestSubChannel(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0x5ab))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Alice opens a sub-channel in her ledger channel with Ingrid.
+	s := setupVirtualTestLedgers(ctx, t, rng, false)
+	defer func() { assert.NoError(t, s.Close()) }()
+	alice, ingrid := s.alice, s.ingrid
+	aliceLedger, ingridLedger := s.aliceLedger, s.ingridAliceLedger
+
+	aliceSub, err := alice.ProposeSubChannel(ctx, &client.SubChannelProposal{
+		ChannelProposal: *newTestProposal(rng, s.asset, s.addrs[alice], s.addrs[ingrid], 10, 20),
+		Parent:          aliceLedger,
+	})
+	require.NoError(err)
+	var ingridSub *client.Channel
+	select {
+	case ingridSub = <-s.ingridHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected sub-channel at Ingrid")
+	}
+	assert.True(t, aliceSub.IsSubChannel())
+	assert.False(t, aliceSub.IsVirtual())
+	assert.True(t, ingridSub.IsSubChannel())
+	assert.Same(t, ingridLedger, ingridSub.Parent())
+	assertLedgerBals(t, aliceLedger.State(), 90, 80, 30)
+	assertLedgerBals(t, ingridLedger.State(), 90, 80, 30)
+
+	// Alice pays Ingrid 5 in the sub-channel and settles it. Ingrid's Settle
+	// only sets her sub-channel to Settled afterwards.
+	pay(ctx, t, aliceSub, 5)
+	require.NoError(aliceSub.Settle(ctx))
+	require.NoError(ingridSub.Settle(ctx))
+	assert.Equal(t, channel.Settled, aliceSub.Phase())
+	assert.Equal(t, channel.Settled, ingridSub.Phase())
+
+	assertLedgerBals(t, aliceLedger.State(), 95, 105, 0)
+	assertLedgerBals(t, ingridLedger.State(), 95, 105, 0)
+}
+
+func TestSubChannel_InvalidParent(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ac))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := setupVirtualTestLedgers(ctx, t, rng, false)
+	defer func() { assert.NoError(t, s.Close()) }()
+
+	// Bob is not a peer of Alice's ledger channel.
+	_, err := s.alice.ProposeSubChannel(ctx, &client.SubChannelProposal{
+		ChannelProposal: *newTestProposal(rng, s.asset, s.addrs[s.alice], s.addrs[s.bob], 10, 20),
+		Parent:          s.aliceLedger,
+	})
+	assert.Error(t, err)
+	assertLedgerBals(t, s.aliceLedger.State(), 100, 100, 0)
+}
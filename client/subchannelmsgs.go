@@ -0,0 +1,140 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"io"
+	"log"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wire"
+	"perun.network/go-perun/wire/msg"
+)
+
+func init() {
+	msg.RegisterDecoder(msg.SubChannelProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m SubChannelProposalReq
+			return &m, m.Decode(r)
+		})
+	msg.RegisterDecoder(msg.SubChannelFundingProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m msgSubChannelFundingProposal
+			return &m, m.Decode(r)
+		})
+	msg.RegisterDecoder(msg.SubChannelSettlementProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m msgSubChannelSettlementProposal
+			return &m, m.Decode(r)
+		})
+}
+
+type (
+	// SubChannelProposalReq is the wire message of a sub-channel proposal. It
+	// is a ChannelProposalReq that additionally names the parent channel that
+	// both peers share. It is answered with a ChannelProposalAcc or
+	// ChannelProposalRej.
+	SubChannelProposalReq struct {
+		ChannelProposalReq
+		// Parent is the ID of the parent channel that funds the sub-channel.
+		Parent channel.ID
+	}
+
+	// msgSubChannelFundingProposal is the update proposal of a parent channel
+	// that locks the funds of a sub-channel. It carries the fully signed
+	// initial state of the sub-channel.
+	msgSubChannelFundingProposal struct {
+		msgChannelUpdate
+		Initial virtualChannelTx
+	}
+
+	// msgSubChannelSettlementProposal is the update proposal of a parent
+	// channel that unlocks the funds of a sub-channel and distributes them
+	// according to the fully signed final state of the sub-channel.
+	msgSubChannelSettlementProposal struct {
+		msgChannelUpdate
+		Final virtualChannelTx
+	}
+)
+
+var (
+	_ channelUpdateReqMsg = (*msgSubChannelFundingProposal)(nil)
+	_ channelUpdateReqMsg = (*msgSubChannelSettlementProposal)(nil)
+)
+
+// Type returns msg.SubChannelProposal.
+func (SubChannelProposalReq) Type() msg.Type {
+	return msg.SubChannelProposal
+}
+
+// Encode encodes the SubChannelProposalReq into an io.Writer.
+func (c SubChannelProposalReq) Encode(w io.Writer) error {
+	if err := c.ChannelProposalReq.Encode(w); err != nil {
+		return err
+	}
+	return errors.WithMessage(wire.Encode(w, c.Parent), "parent encoding")
+}
+
+// Decode decodes a SubChannelProposalReq from an io.Reader.
+func (c *SubChannelProposalReq) Decode(r io.Reader) error {
+	if err := c.ChannelProposalReq.Decode(r); err != nil {
+		return err
+	}
+	return errors.WithMessage(wire.Decode(r, &c.Parent), "parent decoding")
+}
+
+// SessID calculates the SessionID of a SubChannelProposalReq. It commits to
+// the underlying ChannelProposalReq and the parent channel.
+func (c SubChannelProposalReq) SessID() (sid SessionID) {
+	hasher := sha3.New256()
+	if err := wire.Encode(hasher, c.ChannelProposalReq.SessID(), c.Parent); err != nil {
+		log.Panicf("session ID encoding: %v", err)
+	}
+
+	copy(sid[:], hasher.Sum(nil))
+	return
+}
+
+// Type returns this message's type: SubChannelFundingProposal
+func (*msgSubChannelFundingProposal) Type() msg.Type {
+	return msg.SubChannelFundingProposal
+}
+
+// Type returns this message's type: SubChannelSettlementProposal
+func (*msgSubChannelSettlementProposal) Type() msg.Type {
+	return msg.SubChannelSettlementProposal
+}
+
+func (m msgSubChannelFundingProposal) Encode(w io.Writer) error {
+	if err := m.msgChannelUpdate.Encode(w); err != nil {
+		return err
+	}
+	return m.Initial.Encode(w)
+}
+
+func (m *msgSubChannelFundingProposal) Decode(r io.Reader) error {
+	if err := m.msgChannelUpdate.Decode(r); err != nil {
+		return err
+	}
+	return m.Initial.Decode(r)
+}
+
+func (m msgSubChannelSettlementProposal) Encode(w io.Writer) error {
+	if err := m.msgChannelUpdate.Encode(w); err != nil {
+		return err
+	}
+	return m.Final.Encode(w)
+}
+
+func (m *msgSubChannelSettlementProposal) Decode(r io.Reader) error {
+	if err := m.msgChannelUpdate.Decode(r); err != nil {
+		return err
+	}
+	return m.Final.Decode(r)
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestSubChannelProposalReqSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ab1))
+	for i := 0; i < 4; i++ {
+		req := newRandomValidChannelProposalReq(rng, 2)
+		req.InitBals = test.NewRandomAllocation(rng, 2)
+		m := &SubChannelProposalReq{
+			ChannelProposalReq: *req,
+			Parent:             test.NewRandomChannelID(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func TestSubChannelFundingProposalSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ab2))
+	for i := 0; i < 4; i++ {
+		m := &msgSubChannelFundingProposal{
+			msgChannelUpdate: *newRandomMsgChannelUpdate(rng),
+			Initial:          *newRandomVirtualChannelTx(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func TestSubChannelSettlementProposalSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ab3))
+	for i := 0; i < 4; i++ {
+		m := &msgSubChannelSettlementProposal{
+			msgChannelUpdate: *newRandomMsgChannelUpdate(rng),
+			Final:            *newRandomVirtualChannelTx(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+)
+
+// syncTimeout is the time the channel controller waits for sending a sync
+// message.
+var syncTimeout = 10 * time.Second
+
+// syncMsg returns a sync message containing our phase and transactions.
+// The machine must be locked by the caller.
+func (c *Channel) syncMsg(response bool) *msgChannelSync {
+	return &msgChannelSync{
+		ChannelID: c.ID(),
+		Phase:     c.machine.Phase(),
+		CurrentTX: c.machine.CurrentTX(),
+		StagingTX: c.machine.StagingTX(),
+		Response:  response,
+	}
+}
+
+// sync sends a sync request to all peers. It is called by the Client after the
+// channel has been restored. If a peer is online, it answers with a sync
+// response. Otherwise, the peer sends its own sync request once it restored the
+// channel itself.
+func (c *Channel) sync() {
+	c.machMtx.RLock()
+	m := c.syncMsg(false)
+	c.machMtx.RUnlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+		defer cancel()
+		if err := c.conn.Send(ctx, m); err != nil {
+			c.log.Warnf("sending sync request: %v", err)
+		}
+	}()
+}
+
+// handleSyncs handles incoming sync messages until the channel connection is
+// closed. It is started by the Client once the channel is set up or restored.
+func (c *Channel) handleSyncs() {
+	for {
+		pidx, m := c.conn.NextSync(context.Background())
+		if m == nil {
+			c.log.Debug("sync receiver closed")
+			return
+		}
+		c.handleSync(pidx, m)
+	}
+}
+
+// handleSync answers a sync request with our own sync response and then merges
+// the peer's transactions.
+func (c *Channel) handleSync(pidx channel.Index, m *msgChannelSync) {
+	ctx, cancel := context.WithTimeout(context.Background(), syncTimeout)
+	defer cancel()
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	if !m.Response {
+		if err := c.conn.Send(ctx, c.syncMsg(true)); err != nil {
+			c.logPeer(pidx).Warnf("sending sync response: %v", err)
+		}
+	}
+	if err := c.mergeSync(ctx, pidx, m); err != nil {
+		c.logPeer(pidx).Errorf("syncing channel: %v", err)
+	}
+}
+
+// mergeSync resolves an update that was interrupted in the Signing phase with
+// the transactions of the peer. If the peer has the staging state, either as
+// its current or staging state, the peer's signatures are added. The update is
+// enabled if all signatures are present then. Otherwise, nobody holds all
+// signatures on the staging state and it is discarded.
+// The machine must be locked by the caller.
+func (c *Channel) mergeSync(ctx context.Context, pidx channel.Index, m *msgChannelSync) error {
+	if c.machine.Phase() != channel.Signing {
+		if cur := m.CurrentTX.State; cur != nil && cur.Version > c.machine.State().Version {
+			c.logPeer(pidx).Warnf(
+				"peer is at version %d, ahead of our version %d", cur.Version, c.machine.State().Version)
+		}
+		return nil
+	}
+
+	staging := c.machine.StagingTX()
+	var tx channel.Transaction
+	for _, ptx := range []channel.Transaction{m.CurrentTX, m.StagingTX} {
+		if ptx.State != nil && ptx.State.Version == staging.State.Version && len(ptx.Sigs) == len(staging.Sigs) {
+			tx = ptx
+			break
+		}
+	}
+
+	// AddSig verifies the signatures on our staging state, so they are only
+	// added if the peer has the same state.
+	for i, sig := range tx.Sigs {
+		if sig == nil || staging.Sigs[i] != nil {
+			continue
+		}
+		if err := c.machine.AddSig(ctx, channel.Index(i), sig); err != nil {
+			c.logPeer(pidx).Warnf("peer has a different staging state: %v", err)
+			break
+		}
+	}
+	for _, sig := range c.machine.StagingTX().Sigs {
+		if sig == nil {
+			c.logPhase().Debug("Discarding staging state that is not fully signed by anyone")
+			return errors.WithMessage(c.machine.DiscardUpdate(ctx), "discarding update")
+		}
+	}
+
+	if err := c.enableNotifyUpdate(ctx); err != nil {
+		return err
+	}
+	c.addFundedLocked()
+	return nil
+}
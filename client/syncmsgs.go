@@ -0,0 +1,135 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+	"perun.network/go-perun/wire/msg"
+)
+
+func init() {
+	msg.RegisterDecoder(msg.ChannelSync,
+		func(r io.Reader) (msg.Msg, error) {
+			var m msgChannelSync
+			return &m, m.Decode(r)
+		})
+}
+
+type (
+	// msgChannelSync is the wire message with which a restored channel shares
+	// its phase and transactions with its peers, so that an update that was
+	// interrupted in the Signing phase can be resumed. A sync request is
+	// answered with a sync response, which has Response set.
+	msgChannelSync struct {
+		// ChannelID is the channel ID.
+		ChannelID channel.ID
+		// Phase is the sender's phase of the channel.
+		Phase channel.Phase
+		// CurrentTX is the sender's current transaction.
+		CurrentTX channel.Transaction
+		// StagingTX is the sender's staging transaction. Its state is nil if
+		// there is none and its signatures may be partially nil.
+		StagingTX channel.Transaction
+		// Response is set if the message answers a sync request.
+		Response bool
+	}
+
+	// syncTxEncoder encodes a Transaction whose state and signatures may be nil.
+	syncTxEncoder channel.Transaction
+	// syncTxDecoder decodes a Transaction encoded by syncTxEncoder.
+	syncTxDecoder channel.Transaction
+)
+
+var _ ChannelMsg = (*msgChannelSync)(nil)
+
+// Type returns this message's type: ChannelSync
+func (*msgChannelSync) Type() msg.Type {
+	return msg.ChannelSync
+}
+
+func (m msgChannelSync) Encode(w io.Writer) error {
+	return wire.Encode(w, m.ChannelID, uint8(m.Phase), m.Response,
+		syncTxEncoder(m.CurrentTX), syncTxEncoder(m.StagingTX))
+}
+
+func (m *msgChannelSync) Decode(r io.Reader) error {
+	var phase uint8
+	if err := wire.Decode(r, &m.ChannelID, &phase, &m.Response,
+		(*syncTxDecoder)(&m.CurrentTX), (*syncTxDecoder)(&m.StagingTX)); err != nil {
+		return err
+	}
+	m.Phase = channel.Phase(phase)
+	return nil
+}
+
+// ID returns the id of the channel this sync message refers to.
+func (m *msgChannelSync) ID() channel.ID {
+	return m.ChannelID
+}
+
+func (tx syncTxEncoder) Encode(w io.Writer) error {
+	if err := wire.Encode(w, tx.State != nil); err != nil {
+		return err
+	}
+	if tx.State == nil {
+		return nil
+	}
+	if err := wire.Encode(w, tx.State, channel.Index(len(tx.Sigs))); err != nil {
+		return err
+	}
+	for i, sig := range tx.Sigs {
+		if err := wire.Encode(w, sig != nil); err != nil {
+			return errors.WithMessagef(err, "encoding signature %d flag", i)
+		}
+		if sig == nil {
+			continue
+		}
+		if err := wire.Encode(w, sig); err != nil {
+			return errors.WithMessagef(err, "encoding signature %d", i)
+		}
+	}
+	return nil
+}
+
+func (tx *syncTxDecoder) Decode(r io.Reader) (err error) {
+	var hasState bool
+	if err := wire.Decode(r, &hasState); err != nil {
+		return err
+	}
+	if !hasState {
+		*tx = syncTxDecoder{}
+		return nil
+	}
+
+	var n channel.Index
+	tx.State = new(channel.State)
+	if err := wire.Decode(r, tx.State, &n); err != nil {
+		return err
+	}
+	if n > channel.MaxNumParts {
+		return errors.Errorf("too many signatures, got: %d max: %d", n, channel.MaxNumParts)
+	}
+	tx.Sigs = make([]wallet.Sig, n)
+	for i := range tx.Sigs {
+		var hasSig bool
+		if err := wire.Decode(r, &hasSig); err != nil {
+			return errors.WithMessagef(err, "decoding signature %d flag", i)
+		}
+		if !hasSig {
+			continue
+		}
+		if tx.Sigs[i], err = wallet.DecodeSig(r); err != nil {
+			return errors.WithMessagef(err, "decoding signature %d", i)
+		}
+	}
+	return nil
+}
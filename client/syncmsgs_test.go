@@ -0,0 +1,43 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestChannelSyncSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5711c))
+	for i := 0; i < 4; i++ {
+		params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+		newTx := func(sigs int) channel.Transaction {
+			tx := channel.Transaction{
+				State: test.NewRandomState(rng, params),
+				Sigs:  make([]wallet.Sig, len(params.Parts)),
+			}
+			for j := 0; j < sigs; j++ {
+				tx.Sigs[j] = newRandomSig(rng)
+			}
+			return tx
+		}
+		m := &msgChannelSync{
+			ChannelID: params.ID(),
+			Phase:     channel.Phase(rng.Intn(int(channel.Settled) + 1)),
+			CurrentTX: newTx(len(params.Parts)),
+			Response:  i%2 == 0,
+		}
+		if i >= 2 {
+			m.StagingTX = newTx(1)
+		}
+		msg.TestMsg(t, m)
+	}
+}
@@ -52,8 +52,8 @@ func (r *Alice) Execute(cfg ExecConfig) {
 		},
 	}
 	prop := &client.ChannelProposal{
-		ChallengeDuration: 10,           // 10 sec
-		Nonce:             new(big.Int), // nonce 0
+		ChallengeDuration: 10, // 10 sec
+		NonceShare:        client.NewRandomNonceShare(),
 		Account:           wallettest.NewRandomAccount(r.rng),
 		AppDef:            payment.AppDef(),
 		InitData:          new(payment.NoData),
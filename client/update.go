@@ -7,11 +7,13 @@ package client
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/channel"
 	"perun.network/go-perun/log"
+	"perun.network/go-perun/metrics"
 	"perun.network/go-perun/pkg/sync/atomic"
 	"perun.network/go-perun/wallet"
 )
@@ -34,6 +36,10 @@ type (
 		Handle(ChannelUpdate, *UpdateResponder)
 	}
 
+	// UpdateHandlerFunc is an adapter to allow the use of ordinary functions as
+	// UpdateHandlers.
+	UpdateHandlerFunc func(ChannelUpdate, *UpdateResponder)
+
 	// The UpdateResponder allows the user to react to the incoming channel update
 	// request. If the user wants to accept the update, Accept() should be called,
 	// otherwise Reject(), possibly giving a reason for the rejection.
@@ -47,6 +53,11 @@ type (
 	}
 )
 
+// Handle calls f(up, res).
+func (f UpdateHandlerFunc) Handle(up ChannelUpdate, res *UpdateResponder) {
+	f(up, res)
+}
+
 // Accept lets the user signal that they want to accept the channel update.
 func (r *UpdateResponder) Accept(ctx context.Context) error {
 	if ctx == nil {
@@ -85,21 +96,90 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 	if ctx == nil {
 		return errors.New("context must not be nil")
 	}
-	if err := c.validTwoPartyUpdate(up, c.machine.Idx()); err != nil {
-		return err
+
+	c.machMtx.Lock() // lock machine while update is in progress
+	defer c.machMtx.Unlock()
+
+	return c.update(ctx, up)
+}
+
+// UpdateBy proposes the next state that results from applying update to a
+// copy of the current state. The version is increased and we are the actor of
+// the update. If update returns an error, the update is aborted and the error
+// is returned.
+//
+// It returns nil if all peers accept the update. If any runtime error occurs or
+// any peer rejects the update, an error is returned.
+func (c *Channel) UpdateBy(ctx context.Context, update func(*channel.State) error) error {
+	if ctx == nil {
+		return errors.New("context must not be nil")
+	}
+	if update == nil {
+		return errors.New("update function must not be nil")
 	}
 
 	c.machMtx.Lock() // lock machine while update is in progress
 	defer c.machMtx.Unlock()
 
-	if err = c.machine.Update(up.State, up.ActorIdx); err != nil {
+	state := c.machine.State().Clone()
+	if err := update(state); err != nil {
+		return errors.WithMessage(err, "applying update")
+	}
+	state.Version++
+
+	return c.update(ctx, ChannelUpdate{State: state, ActorIdx: c.machine.Idx()})
+}
+
+// UpgradeApp proposes the next state with the new app and app data and
+// unchanged balances. It upgrades the application logic of a long-lived
+// channel without settling it on-chain. Like every update, the upgrade needs
+// the consent of all peers, whose UpdateHandlers see a proposed state whose
+// App differs from the current state's App. The new app must be a StateApp that
+// accepts the new state with ValidInit.
+//
+// The adjudicator only knows the app of the channel parameters. An upgraded
+// channel can still be disputed and settled, but not be force-updated.
+func (c *Channel) UpgradeApp(ctx context.Context, app channel.App, data channel.Data) error {
+	if app == nil || data == nil {
+		return errors.New("app and data must not be nil")
+	}
+	return c.UpdateBy(ctx, func(state *channel.State) error {
+		state.App, state.Data = app, data
+		return nil
+	})
+}
+
+// update proposes the given channel update to all channel participants.
+// The machine must be locked by the caller.
+func (c *Channel) update(ctx context.Context, up ChannelUpdate) (err error) {
+	if c.forced != nil {
+		return errors.New("channel was force-updated on-chain")
+	}
+	if err := c.validTwoPartyUpdate(up, c.machine.Idx()); err != nil {
+		return err
+	}
+
+	if err = c.machine.Update(ctx, up.State, up.ActorIdx); err != nil {
 		return errors.WithMessage(err, "updating machine")
 	}
+
+	return c.proposeStaged(ctx, up, func(m *msgChannelUpdate) channelUpdateReqMsg { return m })
+}
+
+// proposeStaged sends the already staged update up to all peers, wrapped into
+// the wire message returned by wrap, and waits for their responses. If all
+// peers accept, the update is enabled, otherwise it is discarded.
+// The machine must be locked by the caller.
+func (c *Channel) proposeStaged(
+	ctx context.Context,
+	up ChannelUpdate,
+	wrap func(*msgChannelUpdate) channelUpdateReqMsg,
+) (err error) {
 	// if anything goes wrong from now on, we discard the update.
 	// TODO: this is insecure after we sent our signature.
 	defer func() {
 		if err != nil {
-			if derr := c.machine.DiscardUpdate(); derr != nil {
+			if derr := c.machine.DiscardUpdate(ctx); derr != nil {
 				// discarding update should never fail
 				err = errors.WithMessagef(derr,
 					"progressing update failed: %v, then discarding update failed", err)
@@ -107,7 +187,10 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 		}
 	}()
 
-	sig, err := c.machine.Sig()
+	if err := c.pipe.busy(); err != nil {
+		return err
+	}
+	sig, err := c.machine.Sig(ctx)
 	if err != nil {
 		return errors.WithMessage(err, "signing update")
 	}
@@ -118,30 +201,37 @@ func (c *Channel) Update(ctx context.Context, up ChannelUpdate) (err error) {
 	}
 	defer resRecv.Close()
 
-	msgUpdate := &msgChannelUpdate{
+	msgUpdate := wrap(&msgChannelUpdate{
 		ChannelUpdate: up,
 		Sig:           sig,
-	}
+	})
+	sent := time.Now()
 	if err = c.conn.Send(ctx, msgUpdate); err != nil {
 		return errors.WithMessage(err, "sending update")
 	}
 
-	pidx, res := resRecv.Next(ctx)
-	c.log.Tracef("Received update response (%T): %v", res, res)
+	waitCtx, cancel := c.conn.withPeers(ctx)
+	defer cancel()
+	pidx, res := resRecv.Next(waitCtx)
+	c.logPhase().Tracef("Received update response (%T): %v", res, res)
 	if res == nil {
-		return errors.WithMessage(err, "receiving update response")
+		if c.conn.peerClosed() {
+			return errors.New("peer disconnected while waiting for update response")
+		}
+		return newRequestTimedOutError("update response")
 	}
+	metrics.UpdateRoundTrip(time.Since(sent))
 
 	if rej, ok := res.(*msgChannelUpdateRej); ok {
-		return errors.Errorf("update rejected: %s", rej.Reason)
+		return newPeerRejectedError("update", rej.Reason)
 	}
 
 	acc := res.(*msgChannelUpdateAcc) // safe by predicate of the updateResRecv
-	if err := c.machine.AddSig(pidx, acc.Sig); err != nil {
+	if err := c.machine.AddSig(ctx, pidx, acc.Sig); err != nil {
 		return errors.WithMessage(err, "adding peer signature")
 	}
 
-	return c.enableNotifyUpdate()
+	return c.enableNotifyUpdate(ctx)
 }
 
 // ListenUpdates starts the handling of incoming channel update requests. It
@@ -163,34 +253,79 @@ func (c *Channel) ListenUpdates(uh UpdateHandler) {
 }
 
 // handleUpdateReq is called by the controller on incoming channel update
-// requests.
+// requests. Update requests of sub-protocols are dispatched to their
+// respective handlers.
 func (c *Channel) handleUpdateReq(
 	pidx channel.Index,
-	req *msgChannelUpdate,
+	req channelUpdateReqMsg,
 	uh UpdateHandler) {
-	if err := c.validTwoPartyUpdate(req.ChannelUpdate, pidx); err != nil {
-		// TODO: how to handle invalid updates? Just drop and ignore them?
-		c.logPeer(pidx).Warnf("invalid update received: %v", err)
+	if req, ok := req.(*msgVirtualChannelFundingProposal); ok {
+		// locks the machine itself since it waits for the matching proposal
+		c.handleVirtualChannelFundingReq(pidx, req)
 		return
 	}
 
 	c.machMtx.Lock() // lock machine while update is in progress
 	defer c.machMtx.Unlock()
 
-	if err := c.machine.CheckUpdate(req.State, req.ActorIdx, req.Sig, pidx); err != nil {
+	switch req := req.(type) {
+	case *msgVirtualChannelSettlementProposal:
+		c.handleVirtualChannelSettlementReq(pidx, req)
+		return
+	case *msgSubChannelFundingProposal:
+		c.handleSubChannelFundingReq(pidx, req)
+		return
+	case *msgSubChannelSettlementProposal:
+		c.handleSubChannelSettlementReq(pidx, req)
+		return
+	}
+
+	up := req.base()
+	if err := c.pipe.busy(); err != nil {
+		// The peer's update conflicts with our pending updates, so it is
+		// rejected and the peer can retry it later.
+		c.logPeer(pidx).Warnf("rejecting update received while %v", err)
+		ctx, cancel := context.WithTimeout(context.Background(), pipelineBusyTimeout)
+		defer cancel()
+		c.handleUpdateRej(ctx, pidx, up, pipelineBusyReason) // logs errors
+		return
+	}
+	if err := c.validTwoPartyUpdate(up.ChannelUpdate, pidx); err != nil {
 		// TODO: how to handle invalid updates? Just drop and ignore them?
 		c.logPeer(pidx).Warnf("invalid update received: %v", err)
 		return
 	}
 
-	responder := &UpdateResponder{channel: c, pidx: pidx, req: req}
-	uh.Handle(req.ChannelUpdate, responder)
+	if err := c.machine.CheckUpdate(up.State, up.ActorIdx, up.Sig, pidx); err != nil {
+		// TODO: how to handle invalid updates? Just drop and ignore them?
+		c.logPeer(pidx).Warnf("invalid update received: %v", err)
+		return
+	}
+
+	responder := &UpdateResponder{channel: c, pidx: pidx, req: up}
+	uh.Handle(up.ChannelUpdate, responder)
 }
 
 func (c *Channel) handleUpdateAcc(
 	ctx context.Context,
 	pidx channel.Index,
 	req *msgChannelUpdate,
+) (err error) {
+	if err := c.acceptUpdate(ctx, pidx, req, c.machine.Update); err != nil {
+		return err
+	}
+	c.events.emit(UpdateReceived{Channel: c, State: c.machine.State()})
+	return nil
+}
+
+// acceptUpdate stages the requested update using the provided update function
+// of the machine, adds the peer's signature and sends our signature back.
+// The machine must be locked by the caller.
+func (c *Channel) acceptUpdate(
+	ctx context.Context,
+	pidx channel.Index,
+	req *msgChannelUpdate,
+	update func(context.Context, *channel.State, channel.Index) error,
 ) (err error) {
 	defer func() {
 		if err != nil {
@@ -199,7 +334,7 @@ func (c *Channel) handleUpdateAcc(
 	}()
 
 	// machine.Update and AddSig should never fail after CheckUpdate...
-	if err = c.machine.Update(req.State, req.ActorIdx); err != nil {
+	if err = update(ctx, req.State, req.ActorIdx); err != nil {
 		return errors.WithMessage(err, "updating machine")
 	}
 	// if anything goes wrong from now on, we discard the update.
@@ -207,7 +342,7 @@ func (c *Channel) handleUpdateAcc(
 	defer func() {
 		if err != nil {
 			// we discard the update if anything went wrong
-			if derr := c.machine.DiscardUpdate(); derr != nil {
+			if derr := c.machine.DiscardUpdate(ctx); derr != nil {
 				// discarding update should never fail at this point
 				err = errors.WithMessagef(derr,
 					"sending accept message failed: %v, then discarding update failed", err)
@@ -215,11 +350,11 @@ func (c *Channel) handleUpdateAcc(
 		}
 	}()
 
-	if err = c.machine.AddSig(pidx, req.Sig); err != nil {
+	if err = c.machine.AddSig(ctx, pidx, req.Sig); err != nil {
 		return errors.WithMessage(err, "adding peer signature")
 	}
 	var sig wallet.Sig
-	sig, err = c.machine.Sig()
+	sig, err = c.machine.Sig(ctx)
 	if err != nil {
 		return errors.WithMessage(err, "signing updated state")
 	}
@@ -233,7 +368,7 @@ func (c *Channel) handleUpdateAcc(
 		return errors.WithMessage(err, "sending accept message")
 	}
 
-	return c.enableNotifyUpdate()
+	return c.enableNotifyUpdate(ctx)
 }
 
 func (c *Channel) handleUpdateRej(
@@ -259,16 +394,16 @@ func (c *Channel) handleUpdateRej(
 // enableNotifyUpdate enables the current staging state of the machine. If the
 // state is final, machine.EnableFinal is called. Finally, if there is a
 // notification on channel updates, the enabled state is sent on it.
-func (c *Channel) enableNotifyUpdate() error {
-	var updater func() error
+func (c *Channel) enableNotifyUpdate(ctx context.Context) error {
+	var updater func(context.Context) error
 	if c.machine.StagingState().IsFinal {
 		updater = c.machine.EnableFinal
 	} else {
 		updater = c.machine.EnableUpdate
 	}
 
-	if err := updater(); err != nil {
-		return errors.WithMessage(c.machine.EnableUpdate(), "enabling update")
+	if err := updater(ctx); err != nil {
+		return errors.WithMessage(c.machine.EnableUpdate(ctx), "enabling update")
 	}
 
 	if c.updateSub != nil {
@@ -285,20 +420,43 @@ func (c *Channel) enableNotifyUpdate() error {
 // State in the channel machine, so they must not be modified. If you need to
 // modify the State, .Clone() it first.
 func (c *Channel) SubUpdates(updateSub chan<- *channel.State) {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
 	c.updateSub = updateSub
 }
 
 // validTwoPartyUpdate performs additional protocol-dependent checks on the
 // proposed update that go beyond the machine's checks:
 // * actor and signer must be the same
-// * no locked sub-allocations
+// * locked sub-allocations must not change, they can only be changed by
+//   sub-protocols like the virtual channel funding and settlement.
+// The machine must be locked by the caller.
 func (c *Channel) validTwoPartyUpdate(up ChannelUpdate, sigIdx channel.Index) error {
 	if up.ActorIdx != sigIdx {
 		return errors.Errorf(
 			"Currently, only update proposals with the proposing peer as actor are allowed.")
 	}
-	if len(up.State.Locked) > 0 {
-		return errors.New("no locked sub-allocations allowed")
+	if !equalLocked(c.machine.State().Locked, up.State.Locked) {
+		return errors.New("locked sub-allocations must not change")
 	}
 	return nil
 }
+
+// equalLocked returns whether the two sub-allocation slices are equal.
+func equalLocked(a, b []channel.SubAlloc) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || len(a[i].Bals) != len(b[i].Bals) {
+			return false
+		}
+		for j := range a[i].Bals {
+			if a[i].Bals[j].Cmp(b[i].Bals[j]) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
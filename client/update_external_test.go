@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestChannel_UpdateBy(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x0bda7e))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Bob's update handler is set below, so the proposal handler must not start
+	// its own.
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+
+	// Bob only accepts transfers of at most 50. The channel is locked during
+	// the handler call, so his balance is tracked here.
+	bobBal := bobCh.State().OfParts[1][0]
+	handled := make(chan struct{}, 1)
+	go bobCh.ListenUpdates(client.UpdateHandlerFunc(func(up client.ChannelUpdate, res *client.UpdateResponder) {
+		defer func() { handled <- struct{}{} }()
+		if new(big.Int).Sub(up.State.OfParts[1][0], bobBal).Cmp(big.NewInt(50)) > 0 {
+			assert.NoError(t, res.Reject(ctx, "transfer too large"))
+			return
+		}
+		if assert.NoError(t, res.Accept(ctx)) {
+			bobBal = up.State.OfParts[1][0]
+		}
+	}))
+
+	transfer := func(amount int64) func(*channel.State) error {
+		return func(state *channel.State) error {
+			state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(amount))
+			state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(amount))
+			return nil
+		}
+	}
+
+	require.NoError(t, ch.UpdateBy(ctx, transfer(10)))
+	<-handled
+	assert.Equal(t, uint64(1), ch.State().Version)
+	assertLedgerBals(t, ch.State(), 90, 110, 0)
+
+	err := ch.UpdateBy(ctx, transfer(60))
+	var rej *client.PeerRejectedError
+	require.True(t, errors.As(err, &rej), "rejected update")
+	assert.Equal(t, "transfer too large", rej.Reason)
+	<-handled
+	assert.Equal(t, channel.Acting, ch.Phase())
+	assert.Equal(t, uint64(1), ch.State().Version)
+
+	assert.Error(t, ch.UpdateBy(ctx, func(*channel.State) error {
+		return errors.New("abort")
+	}))
+	assert.Equal(t, uint64(1), ch.State().Version)
+}
+
+func TestChannel_Update_PeerDisconnected(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xd15c0))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Bob never answers updates, but closes his client instead.
+	bobHandler := &virtualPropHandler{
+		t:        t,
+		acc:      wallettest.NewRandomAccount(rng),
+		chans:    make(chan *client.Channel, 1),
+		noListen: true,
+	}
+	alice, bob, ch, bobCh := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer alice.Close()
+	go bobCh.ListenUpdates(client.UpdateHandlerFunc(func(client.ChannelUpdate, *client.UpdateResponder) {
+		assert.NoError(t, bob.Close())
+	}))
+
+	start := time.Now()
+	err := ch.UpdateBy(ctx, func(state *channel.State) error {
+		state.OfParts[0][0].Sub(state.OfParts[0][0], big.NewInt(10))
+		state.OfParts[1][0].Add(state.OfParts[1][0], big.NewInt(10))
+		return nil
+	})
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < time.Second, "update should fail before its context")
+	assert.Equal(t, channel.Acting, ch.Phase())
+}
@@ -6,10 +6,13 @@
 package client
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
 )
 
 func TestUpdateResponder_Accept_NilArgs(t *testing.T) {
@@ -32,6 +35,15 @@ func TestChannel_Update_NilArgs(t *testing.T) {
 	assert.Contains(t, err.Error(), "context")
 }
 
+func TestChannel_UpdateBy_NilArgs(t *testing.T) {
+	err := new(Channel).UpdateBy(nil, func(*channel.State) error { return nil })
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "context")
+	err = new(Channel).UpdateBy(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "update function")
+}
+
 func TestChannel_ListenUpdates_NilArgs(t *testing.T) {
 	assert.Panics(t, func() { new(Channel).ListenUpdates(nil) })
 }
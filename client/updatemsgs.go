@@ -40,6 +40,14 @@ type (
 		ID() channel.ID
 	}
 
+	// channelUpdateReqMsg are all update requests that are handled by
+	// Channel.ListenUpdates. Besides plain channel updates, these are the
+	// updates of sub-protocols, like virtual channel funding.
+	channelUpdateReqMsg interface {
+		ChannelMsg
+		base() *msgChannelUpdate
+	}
+
 	channelUpdateResMsg interface {
 		ChannelMsg
 		Ver() uint64
@@ -80,7 +88,7 @@ type (
 )
 
 var (
-	_ ChannelMsg          = (*msgChannelUpdate)(nil)
+	_ channelUpdateReqMsg = (*msgChannelUpdate)(nil)
 	_ channelUpdateResMsg = (*msgChannelUpdateAcc)(nil)
 	_ channelUpdateResMsg = (*msgChannelUpdateRej)(nil)
 )
@@ -140,6 +148,11 @@ func (c *msgChannelUpdate) ID() channel.ID {
 	return c.State.ID
 }
 
+// base returns the plain channel update itself.
+func (c *msgChannelUpdate) base() *msgChannelUpdate {
+	return c
+}
+
 // ID returns the id of the channel this update acceptance refers to.
 func (c *msgChannelUpdateAcc) ID() channel.ID {
 	return c.ChannelID
@@ -0,0 +1,799 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	perunio "perun.network/go-perun/pkg/io"
+	"perun.network/go-perun/pkg/sync/atomic"
+	"perun.network/go-perun/wallet"
+)
+
+// virtualChannelTimeout is the time the intermediary waits for the matching
+// funding proposal of a virtual channel. The ledger channel is not locked while
+// waiting. It is also used when a virtual channel proposal is rejected
+// automatically.
+var virtualChannelTimeout = 10 * time.Second
+
+type (
+	// VirtualChannelProposal contains all data necessary to propose a new
+	// virtual channel to a peer. A virtual channel is funded off-chain by
+	// locking funds in the ledger channels that both peers have with a common
+	// intermediary.
+	//
+	// The PeerAddrs of the embedded ChannelProposal must only contain the
+	// proposer and the proposee, not the intermediary.
+	VirtualChannelProposal struct {
+		ChannelProposal
+		// Intermediary is the Perun address of the common intermediary.
+		Intermediary peer.Address
+		// Parent is our ledger channel with the intermediary.
+		Parent *Channel
+	}
+
+	// A VirtualProposalHandler decides how to handle incoming virtual channel
+	// proposals. It is an optional extension of the ProposalHandler. If the
+	// ProposalHandler passed to the Client doesn't implement it, all virtual
+	// channel proposals are rejected.
+	VirtualProposalHandler interface {
+		// HandleVirtual is the user callback called by the Client on an incoming
+		// virtual channel proposal.
+		HandleVirtual(*VirtualChannelProposalReq, *VirtualProposalResponder)
+	}
+
+	// VirtualProposalResponder lets the user respond to a virtual channel
+	// proposal. If the user wants to accept the proposal, they should call
+	// Accept(), otherwise Reject(). Only a single function must be called and
+	// every further call causes a panic.
+	VirtualProposalResponder struct {
+		client *Client
+		peer   *peer.Peer
+		req    *VirtualChannelProposalReq
+		called atomic.Bool
+
+		stopAbortCache context.CancelFunc // stops caching an early abort
+	}
+
+	// VirtualProposalAcc is the proposal acceptance struct that the user passes
+	// to VirtualProposalResponder.Accept() when they want to accept an incoming
+	// virtual channel proposal.
+	VirtualProposalAcc struct {
+		Participant wallet.Account
+		// Parent is our ledger channel with the intermediary.
+		Parent *Channel
+	}
+
+	// virtualFundingMatcher matches the funding proposals of both endpoints of
+	// a virtual channel if we are the intermediary. It also records the virtual
+	// channels that we funded, so that both endpoints settle them with the same
+	// final state.
+	virtualFundingMatcher struct {
+		enabled atomic.Bool
+
+		mtx     sync.Mutex // protects pending and funded
+		pending map[channel.ID]*virtualFundingReq
+		funded  map[channel.ID]*virtualChannelRecord
+	}
+
+	// virtualFundingReq is a pending funding proposal that waits for its match.
+	virtualFundingReq struct {
+		ledger *Channel
+		prop   *msgVirtualChannelFundingProposal
+		result chan virtualFundingResult
+	}
+
+	// virtualFundingResult is the result of matching two funding proposals.
+	virtualFundingResult struct {
+		match *virtualFundingMatch
+		err   error
+	}
+
+	// virtualFundingMatch lets the ledger channels of two matching funding
+	// proposals agree on accepting them. The votes are indexed by the virtual
+	// channel index of the proposing endpoint.
+	virtualFundingMatch struct {
+		votes [2]chan error
+	}
+
+	// virtualChannelRecord is a virtual channel that we funded as the
+	// intermediary. final is the encoding of the final state that was first
+	// proposed to settle the virtual channel. settled contains the ledger
+	// channels on which the virtual channel is already settled.
+	virtualChannelRecord struct {
+		final   []byte
+		settled map[*Channel]bool
+	}
+)
+
+// Accept lets the user signal that they want to accept the virtual channel
+// proposal. Panics if the proposal was already accepted or rejected.
+func (r *VirtualProposalResponder) Accept(ctx context.Context, acc VirtualProposalAcc) (*Channel, error) {
+	if ctx == nil {
+		return nil, errors.New("context must not be nil")
+	}
+	if !r.called.TrySet() {
+		log.Panic("multiple calls on proposal responder")
+	}
+
+	defer r.stopAbortCache()
+	return r.client.handleVirtualChannelProposalAcc(ctx, r.peer, r.req, acc)
+}
+
+// Reject lets the user signal that they reject the virtual channel proposal.
+// Panics if the proposal was already accepted or rejected.
+func (r *VirtualProposalResponder) Reject(ctx context.Context, reason string) error {
+	if !r.called.TrySet() {
+		log.Panic("multiple calls on proposal responder")
+	}
+	if ctx == nil {
+		log.Panic("nil context")
+	}
+
+	defer r.stopAbortCache()
+	return r.client.handleChannelProposalRej(ctx, r.peer, r.req, reason)
+}
+
+// AsReq returns a shallow copy of the VirtualChannelProposal as a
+// VirtualChannelProposalReq, i.e., as a wire message.
+func (c *VirtualChannelProposal) AsReq() *VirtualChannelProposalReq {
+	return &VirtualChannelProposalReq{
+		ChannelProposalReq: *c.ChannelProposal.AsReq(),
+		Intermediary:       c.Intermediary,
+	}
+}
+
+// EnableVirtualChannelIntermediary lets the Client act as an intermediary for
+// virtual channels. If enabled, the Client automatically locks its funds in
+// its ledger channels once both endpoints of a virtual channel sent matching
+// funding proposals. The funds are unlocked again when the endpoints settle
+// the virtual channel. The ledger channels' update handlers must be running
+// for this, see Channel.ListenUpdates.
+//
+// A funding proposal waits for its match for at most 10 seconds. The ledger
+// channel can still be used while waiting for the match, but further update
+// requests on it are only handled afterwards.
+func (c *Client) EnableVirtualChannelIntermediary() {
+	c.vFunding.enabled.Set()
+}
+
+// ProposeVirtualChannel attempts to open a virtual channel with the parameters
+// and peer from VirtualChannelProposal prop:
+// - the proposal is sent to the peer and if it accepts,
+// - the channel is funded by locking funds in the parent ledger channel. If
+//   the intermediary accepts,
+// - the channel controller is returned.
+// The user is required to start the update handler with
+// Channel.ListenUpdates(UpdateHandler)
+func (c *Client) ProposeVirtualChannel(ctx context.Context, prop *VirtualChannelProposal) (*Channel, error) {
+	if ctx == nil || prop == nil {
+		c.log.Panic("invalid nil argument")
+	}
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
+
+	// 1. check valid proposal
+	req := prop.AsReq()
+	if err := c.validTwoPartyProposal(&req.ChannelProposalReq, 0, req.PeerAddrs[1]); err != nil {
+		return nil, errors.WithMessage(err, "invalid channel proposal")
+	}
+	if err := validVirtualParent(prop.Parent, req.Intermediary, &req.ChannelProposalReq); err != nil {
+		return nil, errors.WithMessage(err, "invalid parent channel")
+	}
+
+	// 2. send proposal and wait for response
+	params, err := c.exchangeTwoPartyProposal(ctx, req)
+	if err != nil {
+		return nil, errors.WithMessage(err, "sending proposal")
+	}
+
+	// 3. create channel machine from the agreed parameters
+	// 4. lock funds in parent channel
+	// 5. return controller on successful funding
+	return c.setupVirtualChannel(ctx, &prop.ChannelProposal, params, prop.Parent)
+}
+
+// handleVirtualChannelProposal implements the receiving side of the two-party
+// virtual channel proposal protocol.
+func (c *Client) handleVirtualChannelProposal(ident *identity, p *peer.Peer, req *VirtualChannelProposalReq) {
+	if c.shutdown.isStopping() {
+		c.rejectShutdown(p, req)
+		return
+	}
+	if err := c.validTwoPartyProposal(&req.ChannelProposalReq, 1, p.PerunAddress); err != nil {
+		c.logPeer(p).Debugf("received invalid virtual channel proposal: %v", err)
+		return
+	}
+
+	responder := &VirtualProposalResponder{client: c, peer: p, req: req,
+		stopAbortCache: cacheProposalAbort(p, req.SessID())}
+	handler, ok := ident.handler.(VirtualProposalHandler)
+	if !ok {
+		ctx, cancel := context.WithTimeout(context.Background(), virtualChannelTimeout)
+		defer cancel()
+		if err := responder.Reject(ctx, "virtual channels not supported"); err != nil {
+			c.logPeer(p).Warnf("rejecting virtual channel proposal: %v", err)
+		}
+		return
+	}
+
+	c.logPeer(p).Trace("calling virtual proposal handler")
+	handler.HandleVirtual(req, responder)
+}
+
+func (c *Client) handleVirtualChannelProposalAcc(
+	ctx context.Context, p *peer.Peer,
+	req *VirtualChannelProposalReq, acc VirtualProposalAcc,
+) (*Channel, error) {
+	if err := c.shutdown.begin(); err != nil {
+		return nil, err
+	}
+	defer c.shutdown.end()
+
+	if acc.Participant == nil {
+		c.logPeer(p).Error("user returned nil Participant in VirtualProposalAcc")
+		return nil, errors.New("nil Participant in VirtualProposalAcc")
+	}
+	if err := validVirtualParent(acc.Parent, req.Intermediary, &req.ChannelProposalReq); err != nil {
+		return nil, errors.WithMessage(err, "invalid parent channel")
+	}
+
+	// enables caching of incoming version 0 signatures before sending any message
+	// that might trigger a fast peer to send those.
+	enableVer0Cache(ctx, p)
+
+	msgAccept := newProposalAcc(req, acc.Participant.Address())
+	abort, err := c.sendProposalAcc(ctx, p, msgAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer abort.stop()
+
+	ch, err := c.setupVirtualChannel(abort.ctx, req.AsProp(acc.Participant), req.params(msgAccept), acc.Parent)
+	return ch, abort.wrap(err)
+}
+
+// setupVirtualChannel sets up a new virtual channel controller, similar to
+// setupChannel. Instead of funding the channel on-chain, the funds are locked
+// in the parent ledger channel.
+func (c *Client) setupVirtualChannel(
+	ctx context.Context,
+	prop *ChannelProposal,
+	params *channel.Params, // result of the MPCPP on prop
+	parent *Channel,
+) (*Channel, error) {
+	ch, err := c.initChannel(ctx, prop, params, parent)
+	if err != nil {
+		return ch, err
+	}
+
+	if err := parent.fundVirtualChannel(ctx, ch); err != nil {
+		ch.log.Warnf("error while funding virtual channel: %v", err)
+		return ch, errors.WithMessage(err, "error while funding virtual channel")
+	}
+
+	return ch, c.enableChannel(ctx, ch)
+}
+
+// validVirtualParent checks that the parent channel can fund a virtual channel
+// with the given proposal through the intermediary.
+func validVirtualParent(parent *Channel, intermediary peer.Address, req *ChannelProposalReq) error {
+	if parent == nil {
+		return errors.New("parent channel must not be nil")
+	}
+	if parent.IsVirtual() {
+		return errors.New("parent channel must be a ledger channel")
+	}
+	if len(parent.Params().Parts) != 2 {
+		return errors.New("parent channel must be a two-party channel")
+	}
+	if intermediary == nil || !parent.conn.HasPeer(intermediary) {
+		return errors.New("intermediary is not a peer of the parent channel")
+	}
+	if !channel.EqualAssets(parent.State().Assets, req.InitBals.Assets) {
+		return errors.New("assets of parent and virtual channel don't match")
+	}
+	if req.FundingAgreement != nil {
+		return errors.New("virtual channels cannot have a funding agreement")
+	}
+	return nil
+}
+
+// fundVirtualChannel proposes an update of this ledger channel that locks the
+// funds for the given virtual channel, which must be in the Funding phase.
+func (c *Channel) fundVirtualChannel(ctx context.Context, virtual *Channel) error {
+	return c.lockChildFunds(ctx, virtual, func(m *msgChannelUpdate, initial virtualChannelTx) channelUpdateReqMsg {
+		return &msgVirtualChannelFundingProposal{msgChannelUpdate: *m, Initial: initial}
+	})
+}
+
+// lockChildFunds proposes an update of this channel that locks the funds for
+// the given virtual or sub-channel, which must be in the Funding phase. wrap
+// creates the update proposal message from the update and the initial
+// transaction of the child channel.
+func (c *Channel) lockChildFunds(
+	ctx context.Context,
+	child *Channel,
+	wrap func(*msgChannelUpdate, virtualChannelTx) channelUpdateReqMsg,
+) error {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	initial := child.machine.AdjudicatorReq().Tx
+	state := c.machine.State().Clone()
+	state.Version++
+	if err := lockVirtualFunds(state, c.machine.Idx(), initial.State, child.Idx()); err != nil {
+		return errors.WithMessage(err, "locking funds")
+	}
+
+	up := ChannelUpdate{State: state, ActorIdx: c.machine.Idx()}
+	if err := c.machine.UpdateLocked(ctx, up.State, up.ActorIdx); err != nil {
+		return errors.WithMessage(err, "updating machine")
+	}
+
+	return c.proposeStaged(ctx, up, func(m *msgChannelUpdate) channelUpdateReqMsg {
+		return wrap(m, virtualChannelTx{Params: child.Params(), Tx: initial, Idx: child.Idx()})
+	})
+}
+
+// settleVirtualChannel proposes an update of this ledger channel that unlocks
+// the funds of the given virtual channel according to its final state.
+// The virtual channel must be in the Final phase and locked by the caller.
+func (c *Channel) settleVirtualChannel(ctx context.Context, virtual *Channel) error {
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+
+	final := virtual.machine.AdjudicatorReq().Tx
+	return c.proposeVirtualSettlement(ctx, virtualChannelTx{Params: virtual.Params(), Tx: final, Idx: virtual.Idx()})
+}
+
+// proposeVirtualSettlement proposes an update of this ledger channel that
+// unlocks the funds of a virtual channel according to the given final
+// transaction. The machine must be locked by the caller.
+func (c *Channel) proposeVirtualSettlement(ctx context.Context, final virtualChannelTx) error {
+	return c.unlockChildFunds(ctx, final, func(m *msgChannelUpdate) channelUpdateReqMsg {
+		return &msgVirtualChannelSettlementProposal{msgChannelUpdate: *m, Final: final}
+	})
+}
+
+// unlockChildFunds proposes an update of this channel that unlocks the funds
+// of a virtual or sub-channel according to the given final transaction. wrap
+// creates the update proposal message. The machine must be locked by the
+// caller.
+func (c *Channel) unlockChildFunds(
+	ctx context.Context,
+	final virtualChannelTx,
+	wrap func(*msgChannelUpdate) channelUpdateReqMsg,
+) error {
+	state := c.machine.State().Clone()
+	state.Version++
+	if err := unlockVirtualFunds(state, c.machine.Idx(), final.Tx.State, final.Idx); err != nil {
+		return errors.WithMessage(err, "unlocking funds")
+	}
+
+	up := ChannelUpdate{State: state, ActorIdx: c.machine.Idx()}
+	if err := c.machine.UpdateLocked(ctx, up.State, up.ActorIdx); err != nil {
+		return errors.WithMessage(err, "updating machine")
+	}
+
+	return c.proposeStaged(ctx, up, wrap)
+}
+
+// handleVirtualChannelFundingReq is called on the intermediary's ledger
+// channel on an incoming virtual channel funding proposal. The proposal is
+// only accepted if the other endpoint sends a matching proposal on its ledger
+// channel with us. The machine is only locked while the proposal is checked and
+// accepted, not while waiting for the matching proposal.
+func (c *Channel) handleVirtualChannelFundingReq(pidx channel.Index, req *msgVirtualChannelFundingProposal) {
+	ctx, cancel := context.WithTimeout(context.Background(), virtualChannelTimeout)
+	defer cancel()
+
+	c.machMtx.Lock()
+	err := c.checkVirtualChannelFundingReq(pidx, req)
+	c.machMtx.Unlock()
+	var match *virtualFundingMatch
+	if err == nil {
+		match, err = c.vFunding.Match(ctx, c, req)
+	}
+
+	c.machMtx.Lock()
+	defer c.machMtx.Unlock()
+	if err == nil {
+		// The ledger state might have changed while waiting for the match, so
+		// the proposal is checked again before both ledger channels accept.
+		err = match.Vote(ctx, req.Initial.Idx, c.checkVirtualChannelFundingReq(pidx, req))
+	}
+	if err == nil {
+		c.vFunding.addFunded(req.Initial.Tx.ID)
+	}
+	if err != nil {
+		c.logPeer(pidx).Warnf("rejecting virtual channel funding: %v", err)
+		if rerr := c.handleUpdateRej(ctx, pidx, &req.msgChannelUpdate, err.Error()); rerr != nil {
+			c.logPeer(pidx).Warnf("sending rejection: %v", rerr)
+		}
+		return
+	}
+
+	if err := c.acceptUpdate(ctx, pidx, &req.msgChannelUpdate, c.machine.UpdateLocked); err != nil {
+		c.logPeer(pidx).Errorf("accepting virtual channel funding: %v", err)
+	}
+}
+
+// handleVirtualChannelSettlementReq is called on the intermediary's ledger
+// channel on an incoming virtual channel settlement proposal. The machine must
+// be locked by the caller.
+func (c *Channel) handleVirtualChannelSettlementReq(pidx channel.Index, req *msgVirtualChannelSettlementProposal) {
+	ctx, cancel := context.WithTimeout(context.Background(), virtualChannelTimeout)
+	defer cancel()
+
+	if err := c.checkVirtualChannelSettlementReq(pidx, req); err != nil {
+		c.logPeer(pidx).Warnf("rejecting virtual channel settlement: %v", err)
+		if rerr := c.handleUpdateRej(ctx, pidx, &req.msgChannelUpdate, err.Error()); rerr != nil {
+			c.logPeer(pidx).Warnf("sending rejection: %v", rerr)
+		}
+		return
+	}
+
+	if err := c.acceptUpdate(ctx, pidx, &req.msgChannelUpdate, c.machine.UpdateLocked); err != nil {
+		c.logPeer(pidx).Errorf("accepting virtual channel settlement: %v", err)
+		return
+	}
+	c.vFunding.removeSettled(c, req.Final.Tx.ID)
+}
+
+// addFundedLocked records all virtual channels whose funds are locked in the
+// current state as funded by us if we act as intermediary. It is used when the
+// funding of virtual channels happened without handleVirtualChannelFundingReq,
+// i.e., on restored channels.
+// TODO: the final state with which a virtual channel was already settled on
+// the other ledger channel before a restart is not known after the restart.
+// The machine must be locked by the caller.
+func (c *Channel) addFundedLocked() {
+	if c.vFunding == nil || !c.vFunding.enabled.IsSet() || c.IsVirtual() {
+		return
+	}
+	for _, sub := range c.machine.State().Locked {
+		c.vFunding.addFunded(sub.ID)
+	}
+}
+
+// checkVirtualChannelFundingReq checks that the proposed ledger state locks
+// exactly the funds of the fully signed initial virtual channel state.
+func (c *Channel) checkVirtualChannelFundingReq(pidx channel.Index, req *msgVirtualChannelFundingProposal) error {
+	if c.vFunding == nil || !c.vFunding.enabled.IsSet() {
+		return errors.New("not acting as intermediary")
+	}
+	return c.checkChildFunding(pidx, &req.msgChannelUpdate, req.Initial)
+}
+
+// checkVirtualChannelSettlementReq checks that the proposed ledger state
+// unlocks the funds of the virtual channel according to its fully signed final
+// state. The final state must be the same on both ledger channels of the
+// virtual channel.
+func (c *Channel) checkVirtualChannelSettlementReq(pidx channel.Index, req *msgVirtualChannelSettlementProposal) error {
+	if c.vFunding == nil {
+		return errors.New("not acting as intermediary")
+	}
+	if err := c.checkChildSettlement(pidx, &req.msgChannelUpdate, req.Final); err != nil {
+		return err
+	}
+	return c.vFunding.checkFinal(req.Final.Tx.State)
+}
+
+// checkChildFunding checks that the proposed state locks exactly the funds of
+// the fully signed initial state of a virtual or sub-channel.
+func (c *Channel) checkChildFunding(pidx channel.Index, up *msgChannelUpdate, initial virtualChannelTx) error {
+	if err := initial.valid(); err != nil {
+		return errors.WithMessage(err, "invalid initial virtual channel state")
+	}
+	if initial.Tx.Version != 0 || initial.Tx.IsFinal {
+		return errors.New("virtual channel state is not an initial state")
+	}
+
+	expected := c.machine.State().Clone()
+	expected.Version++
+	if err := lockVirtualFunds(expected, pidx, initial.Tx.State, initial.Idx); err != nil {
+		return err
+	}
+	return c.checkLockedUpdate(pidx, up, expected)
+}
+
+// checkChildSettlement checks that the proposed state unlocks the funds of a
+// virtual or sub-channel according to its fully signed final state.
+func (c *Channel) checkChildSettlement(pidx channel.Index, up *msgChannelUpdate, final virtualChannelTx) error {
+	if err := final.valid(); err != nil {
+		return errors.WithMessage(err, "invalid final virtual channel state")
+	}
+	if !final.Tx.IsFinal {
+		return errors.New("virtual channel state is not final")
+	}
+
+	expected := c.machine.State().Clone()
+	expected.Version++
+	if err := unlockVirtualFunds(expected, pidx, final.Tx.State, final.Idx); err != nil {
+		return err
+	}
+	return c.checkLockedUpdate(pidx, up, expected)
+}
+
+// checkLockedUpdate checks that the update request proposes the expected state
+// and is a valid locked update of the machine.
+func (c *Channel) checkLockedUpdate(pidx channel.Index, req *msgChannelUpdate, expected *channel.State) error {
+	if req.ActorIdx != pidx {
+		return errors.New("actor must be the proposing peer")
+	}
+	if !equalEncoding(expected, req.State) {
+		return errors.New("proposed state doesn't match expected state")
+	}
+	return c.machine.CheckUpdateLocked(req.State, req.ActorIdx, req.Sig, pidx)
+}
+
+// valid checks that the virtual channel transaction is signed by all
+// participants and matches the virtual channel parameters.
+func (t *virtualChannelTx) valid() error {
+	if len(t.Params.Parts) != 2 {
+		return errors.New("virtual channel must be a two-party channel")
+	}
+	if t.Idx >= 2 {
+		return errors.New("sender index out of range")
+	}
+	if t.Tx.ID != t.Params.ID() {
+		return errors.New("state doesn't match parameters")
+	}
+	if err := t.Tx.Allocation.Valid(); err != nil {
+		return err
+	}
+	if len(t.Tx.OfParts) != len(t.Params.Parts) || len(t.Tx.Locked) != 0 {
+		return errors.New("invalid allocation of virtual channel")
+	}
+	for i, sig := range t.Tx.Sigs {
+		if ok, err := channel.Verify(t.Params.Parts[i], t.Params, t.Tx.State, sig); err != nil {
+			return errors.WithMessagef(err, "verifying signature[%d]", i)
+		} else if !ok {
+			return errors.Errorf("invalid signature[%d]", i)
+		}
+	}
+	return nil
+}
+
+// lockVirtualFunds modifies the state of a two-party ledger channel such that
+// the funds of the virtual channel state are locked. The endpoint at index
+// endpointIdx in the ledger channel has index vIdx in the virtual channel. It
+// contributes its own virtual balance, whereas the intermediary contributes the
+// other endpoint's virtual balance.
+func lockVirtualFunds(ledger *channel.State, endpointIdx channel.Index, virtual *channel.State, vIdx channel.Index) error {
+	if err := checkVirtualDims(ledger, endpointIdx, virtual, vIdx); err != nil {
+		return err
+	}
+	for _, sub := range ledger.Locked {
+		if sub.ID == virtual.ID {
+			return errors.New("virtual channel funds already locked")
+		}
+	}
+
+	for i, idx := range []channel.Index{endpointIdx, endpointIdx ^ 1} {
+		bals := ledger.OfParts[idx]
+		for j := range bals {
+			bals[j].Sub(bals[j], virtual.OfParts[vIdx^channel.Index(i)][j])
+			if bals[j].Sign() == -1 {
+				return errors.Errorf("insufficient funds of ledger participant %d for asset %d", idx, j)
+			}
+		}
+	}
+	ledger.Locked = append(ledger.Locked, channel.SubAlloc{ID: virtual.ID, Bals: virtual.Sum()})
+	return nil
+}
+
+// unlockVirtualFunds is the inverse of lockVirtualFunds. It removes the locked
+// funds of the virtual channel from the ledger channel state and distributes
+// them according to the final virtual channel state.
+func unlockVirtualFunds(ledger *channel.State, endpointIdx channel.Index, virtual *channel.State, vIdx channel.Index) error {
+	if err := checkVirtualDims(ledger, endpointIdx, virtual, vIdx); err != nil {
+		return err
+	}
+
+	locked := -1
+	for i, sub := range ledger.Locked {
+		if sub.ID == virtual.ID {
+			locked = i
+			break
+		}
+	}
+	if locked == -1 {
+		return errors.New("no funds locked for virtual channel")
+	}
+	for j, sum := range virtual.Sum() {
+		if ledger.Locked[locked].Bals[j].Cmp(sum) != 0 {
+			return errors.Errorf("locked funds don't match virtual channel funds for asset %d", j)
+		}
+	}
+	remaining := make([]channel.SubAlloc, 0, len(ledger.Locked)-1)
+	remaining = append(remaining, ledger.Locked[:locked]...)
+	ledger.Locked = append(remaining, ledger.Locked[locked+1:]...)
+
+	for i, idx := range []channel.Index{endpointIdx, endpointIdx ^ 1} {
+		bals := ledger.OfParts[idx]
+		for j := range bals {
+			bals[j].Add(bals[j], virtual.OfParts[vIdx^channel.Index(i)][j])
+		}
+	}
+	return nil
+}
+
+func checkVirtualDims(ledger *channel.State, endpointIdx channel.Index, virtual *channel.State, vIdx channel.Index) error {
+	if len(ledger.OfParts) != 2 || len(virtual.OfParts) != 2 {
+		return errors.New("ledger and virtual channel must be two-party channels")
+	}
+	if endpointIdx >= 2 || vIdx >= 2 {
+		return errors.New("participant index out of range")
+	}
+	if !channel.EqualAssets(ledger.Assets, virtual.Assets) {
+		return errors.New("assets of ledger and virtual channel don't match")
+	}
+	return nil
+}
+
+func newVirtualFundingMatcher() *virtualFundingMatcher {
+	return &virtualFundingMatcher{
+		pending: make(map[channel.ID]*virtualFundingReq),
+		funded:  make(map[channel.ID]*virtualChannelRecord),
+	}
+}
+
+// Match waits until the funding proposal of the other endpoint of the virtual
+// channel arrives on another ledger channel or the context is done. If both
+// proposals match, the returned virtualFundingMatch is used to agree on
+// accepting them. The proposals are expected to be checked individually
+// before.
+func (m *virtualFundingMatcher) Match(
+	ctx context.Context,
+	ledger *Channel,
+	prop *msgVirtualChannelFundingProposal,
+) (*virtualFundingMatch, error) {
+	id := prop.Initial.Tx.ID
+	m.mtx.Lock()
+	if other, ok := m.pending[id]; ok {
+		delete(m.pending, id)
+		m.mtx.Unlock()
+		res := virtualFundingResult{err: matchVirtualFunding(other, ledger, prop)}
+		if res.err == nil {
+			res.match = newVirtualFundingMatch()
+		}
+		other.result <- res
+		return res.match, res.err
+	}
+	req := &virtualFundingReq{ledger: ledger, prop: prop, result: make(chan virtualFundingResult, 1)}
+	m.pending[id] = req
+	m.mtx.Unlock()
+
+	select {
+	case res := <-req.result:
+		return res.match, res.err
+	case <-ctx.Done():
+	}
+
+	m.mtx.Lock()
+	if m.pending[id] == req {
+		delete(m.pending, id)
+		m.mtx.Unlock()
+		return nil, errors.WithMessage(ctx.Err(), "waiting for matching funding proposal")
+	}
+	m.mtx.Unlock()
+	// The other proposal arrived in the meantime and the result is under way.
+	res := <-req.result
+	return res.match, res.err
+}
+
+// addFunded records that we funded the virtual channel with the given ID.
+func (m *virtualFundingMatcher) addFunded(id channel.ID) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if _, ok := m.funded[id]; !ok {
+		m.funded[id] = &virtualChannelRecord{settled: make(map[*Channel]bool)}
+	}
+}
+
+// checkFinal checks that the virtual channel of the given final state was
+// funded by us and that it is settled with the same final state on both ledger
+// channels. The first checked final state of a virtual channel is recorded and
+// all later final states must equal it.
+func (m *virtualFundingMatcher) checkFinal(final *channel.State) error {
+	var buf bytes.Buffer
+	if err := final.Encode(&buf); err != nil {
+		return errors.WithMessage(err, "encoding final state")
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	rec, ok := m.funded[final.ID]
+	if !ok {
+		return errors.New("virtual channel was not funded by us")
+	}
+	if rec.final == nil {
+		rec.final = buf.Bytes()
+	} else if !bytes.Equal(rec.final, buf.Bytes()) {
+		return errors.New("final state differs from the final state settled on the other ledger channel")
+	}
+	return nil
+}
+
+// removeSettled records that the virtual channel with the given ID is settled
+// on the given ledger channel. Once it is settled on both ledger channels, the
+// record is removed.
+func (m *virtualFundingMatcher) removeSettled(ledger *Channel, id channel.ID) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	rec, ok := m.funded[id]
+	if !ok {
+		return
+	}
+	rec.settled[ledger] = true
+	if len(rec.settled) == 2 {
+		delete(m.funded, id)
+	}
+}
+
+func newVirtualFundingMatch() *virtualFundingMatch {
+	return &virtualFundingMatch{votes: [2]chan error{make(chan error, 1), make(chan error, 1)}}
+}
+
+// Vote reports whether the funding proposal of the virtual channel participant
+// idx can be accepted on its ledger channel and waits for the vote of the
+// other ledger channel. It returns nil if both proposals can be accepted.
+func (m *virtualFundingMatch) Vote(ctx context.Context, idx channel.Index, err error) error {
+	m.votes[idx] <- err
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-m.votes[idx^1]:
+		return errors.WithMessage(err, "matching funding proposal failed")
+	case <-ctx.Done():
+		return errors.WithMessage(ctx.Err(), "waiting for matching funding proposal")
+	}
+}
+
+// matchVirtualFunding checks that the two funding proposals stem from both
+// endpoints of the same virtual channel on different ledger channels.
+func matchVirtualFunding(pending *virtualFundingReq, ledger *Channel, prop *msgVirtualChannelFundingProposal) error {
+	if pending.ledger == ledger {
+		return errors.New("both funding proposals received on the same ledger channel")
+	}
+	if pending.prop.Initial.Idx == prop.Initial.Idx {
+		return errors.New("both funding proposals from the same virtual channel participant")
+	}
+	if !equalEncoding(pending.prop.Initial.Tx.State, prop.Initial.Tx.State) {
+		return errors.New("initial states of funding proposals don't match")
+	}
+	return nil
+}
+
+// equalEncoding returns whether both Encoders encode to the same bytes. An
+// encoding error causes false to be returned.
+func equalEncoding(a, b perunio.Encoder) bool {
+	var bufA, bufB bytes.Buffer
+	if err := a.Encode(&bufA); err != nil {
+		return false
+	}
+	if err := b.Encode(&bufB); err != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
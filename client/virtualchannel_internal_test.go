@@ -0,0 +1,384 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestLockUnlockVirtualFunds(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x10c4))
+	asset := channeltest.NewRandomAsset(rng)
+	ledger := newTwoPartyState(rng, asset, 100, 50)
+	virtual := newTwoPartyState(rng, asset, 10, 20)
+
+	t.Run("lock", func(t *testing.T) {
+		state := ledger.Clone()
+		// endpoint has ledger index 1 and virtual index 0
+		require.NoError(t, lockVirtualFunds(state, 1, virtual, 0))
+		assertBals(t, state, 80, 40)
+		require.Len(t, state.Locked, 1)
+		assert.Equal(t, virtual.ID, state.Locked[0].ID)
+		assert.Zero(t, state.Locked[0].Bals[0].Cmp(big.NewInt(30)))
+
+		assert.Error(t, lockVirtualFunds(state, 1, virtual, 0), "double lock")
+	})
+
+	t.Run("unlock", func(t *testing.T) {
+		state := ledger.Clone()
+		require.NoError(t, lockVirtualFunds(state, 0, virtual, 1))
+		assertBals(t, state, 80, 40)
+
+		final := virtual.Clone()
+		final.OfParts[0][0].SetInt64(25)
+		final.OfParts[1][0].SetInt64(5)
+		require.NoError(t, unlockVirtualFunds(state, 0, final, 1))
+		assertBals(t, state, 85, 65)
+		assert.Len(t, state.Locked, 0)
+
+		assert.Error(t, unlockVirtualFunds(state, 0, final, 1), "double unlock")
+	})
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		rich := newTwoPartyState(rng, asset, 10, 60)
+		assert.Error(t, lockVirtualFunds(ledger.Clone(), 0, rich, 0))
+	})
+
+	t.Run("mismatching sum", func(t *testing.T) {
+		state := ledger.Clone()
+		require.NoError(t, lockVirtualFunds(state, 0, virtual, 0))
+		final := virtual.Clone()
+		final.OfParts[0][0].SetInt64(11)
+		assert.Error(t, unlockVirtualFunds(state, 0, final, 0))
+	})
+
+	t.Run("mismatching assets", func(t *testing.T) {
+		other := newTwoPartyState(rng, channeltest.NewRandomAsset(rng), 10, 20)
+		assert.Error(t, lockVirtualFunds(ledger.Clone(), 0, other, 0))
+	})
+}
+
+func TestEqualLocked(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xe10c))
+	a := []channel.SubAlloc{*channeltest.NewRandomSubAlloc(rng, 2)}
+	b := []channel.SubAlloc{{ID: a[0].ID, Bals: channel.CloneBals(a[0].Bals)}}
+
+	assert.True(t, equalLocked(nil, []channel.SubAlloc{}))
+	assert.True(t, equalLocked(a, b))
+	assert.False(t, equalLocked(a, nil))
+	b[0].Bals[1].Add(b[0].Bals[1], big.NewInt(1))
+	assert.False(t, equalLocked(a, b))
+}
+
+func TestVirtualFundingMatcher_Match(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a7c))
+	asset := channeltest.NewRandomAsset(rng)
+	initial := newTwoPartyState(rng, asset, 10, 20)
+	newProp := func(state *channel.State, idx channel.Index) *msgVirtualChannelFundingProposal {
+		return &msgVirtualChannelFundingProposal{
+			Initial: virtualChannelTx{Tx: channel.Transaction{State: state}, Idx: idx},
+		}
+	}
+	ledger0, ledger1 := new(Channel), new(Channel)
+
+	// match runs Match for both proposals concurrently and returns the results.
+	match := func(ctx context.Context, l0, l1 *Channel, p0, p1 *msgVirtualChannelFundingProposal) (m0, m1 *virtualFundingMatch, err0, err1 error) {
+		m := newVirtualFundingMatcher()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m0, err0 = m.Match(ctx, l0, p0)
+		}()
+		m1, err1 = m.Match(ctx, l1, p1)
+		<-done
+		return
+	}
+
+	t.Run("match", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		m0, m1, err0, err1 := match(ctx, ledger0, ledger1, newProp(initial, 0), newProp(initial.Clone(), 1))
+		require.NoError(t, err0)
+		require.NoError(t, err1)
+		require.NotNil(t, m0)
+		assert.Same(t, m0, m1)
+
+		go func() { assert.NoError(t, m0.Vote(ctx, 0, nil)) }()
+		assert.NoError(t, m1.Vote(ctx, 1, nil))
+	})
+
+	t.Run("failed vote", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		m, _, err, _ := match(ctx, ledger0, ledger1, newProp(initial, 0), newProp(initial, 1))
+		require.NoError(t, err)
+
+		go func() { assert.Error(t, m.Vote(ctx, 0, errors.New("state changed"))) }()
+		assert.Error(t, m.Vote(ctx, 1, nil))
+	})
+
+	t.Run("mismatching initial states", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		other := initial.Clone()
+		other.OfParts[0][0].SetInt64(11)
+		_, _, err0, err1 := match(ctx, ledger0, ledger1, newProp(initial, 0), newProp(other, 1))
+		assert.Error(t, err0)
+		assert.Error(t, err1)
+	})
+
+	t.Run("same participant", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _, err0, err1 := match(ctx, ledger0, ledger1, newProp(initial, 0), newProp(initial, 0))
+		assert.Error(t, err0)
+		assert.Error(t, err1)
+	})
+
+	t.Run("same ledger", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_, _, err0, err1 := match(ctx, ledger0, ledger0, newProp(initial, 0), newProp(initial, 1))
+		assert.Error(t, err0)
+		assert.Error(t, err1)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		m := newVirtualFundingMatcher()
+		_, err := m.Match(ctx, ledger0, newProp(initial, 0))
+		assert.Error(t, err)
+		assert.Empty(t, m.pending)
+	})
+}
+
+func TestVirtualFundingMatcher_checkFinal(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf1a1))
+	m := newVirtualFundingMatcher()
+	final := newTwoPartyState(rng, channeltest.NewRandomAsset(rng), 10, 20)
+	final.IsFinal = true
+	conflicting := final.Clone()
+	conflicting.OfParts[0][0], conflicting.OfParts[1][0] = conflicting.OfParts[1][0], conflicting.OfParts[0][0]
+
+	assert.Error(t, m.checkFinal(final), "not funded")
+	m.addFunded(final.ID)
+	require.NoError(t, m.checkFinal(final))
+	assert.NoError(t, m.checkFinal(final.Clone()))
+	assert.Error(t, m.checkFinal(conflicting))
+
+	ledger0, ledger1 := new(Channel), new(Channel)
+	m.removeSettled(ledger0, final.ID)
+	assert.Contains(t, m.funded, final.ID)
+	m.removeSettled(ledger1, final.ID)
+	assert.NotContains(t, m.funded, final.ID)
+}
+
+func TestChannel_checkVirtualChannelReq_NotIntermediary(t *testing.T) {
+	ch := &Channel{vFunding: newVirtualFundingMatcher()}
+	err := ch.checkVirtualChannelFundingReq(0, new(msgVirtualChannelFundingProposal))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "intermediary")
+
+	err = new(Channel).checkVirtualChannelSettlementReq(0, new(msgVirtualChannelSettlementProposal))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "intermediary")
+}
+
+// TestVirtualChannel_ConflictingFinals tests that the intermediary rejects
+// settling a virtual channel with a different final state than on the other
+// ledger channel. Both endpoints collude by signing a second final state.
+func TestVirtualChannel_ConflictingFinals(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0xc0f1))
+	var hub peertest.ConnHub
+	asset := channeltest.NewRandomAsset(rng)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	newClient := func() (*Client, *acceptAllHandler) {
+		id := wallettest.NewRandomAccount(rng)
+		h := &acceptAllHandler{
+			t:     t,
+			acc:   wallettest.NewRandomAccount(rng),
+			chans: make(chan *Channel, 2),
+		}
+		c := New(id, hub.NewDialer(), h, new(nopFunder), new(nopAdjudicator))
+		go c.Listen(hub.NewListener(id.Address()))
+		return c, h
+	}
+	alice, _ := newClient()
+	bob, bobHandler := newClient()
+	ingrid, ingridHandler := newClient()
+	ingrid.EnableVirtualChannelIntermediary()
+	defer func() {
+		for _, c := range []*Client{alice, bob, ingrid} {
+			assert.NoError(t, c.Close())
+		}
+	}()
+
+	openLedger := func(c *Client) *Channel {
+		ch, err := c.ProposeChannel(ctx, newPaymentProposal(rng, asset, c.id.Address(), ingrid.id.Address(), 100, 100))
+		require.NoError(err)
+		go ch.ListenUpdates(acceptAllUpdateHandler(t))
+		select {
+		case <-ingridHandler.chans:
+		case <-ctx.Done():
+			t.Fatal("expected ledger channel at Ingrid")
+		}
+		return ch
+	}
+	aliceLedger := openLedger(alice)
+	bobHandler.parent = openLedger(bob)
+
+	prop := newPaymentProposal(rng, asset, alice.id.Address(), bob.id.Address(), 10, 20)
+	aliceVirtual, err := alice.ProposeVirtualChannel(ctx, &VirtualChannelProposal{
+		ChannelProposal: *prop,
+		Intermediary:    ingrid.id.Address(),
+		Parent:          aliceLedger,
+	})
+	require.NoError(err)
+	var bobVirtual *Channel
+	select {
+	case bobVirtual = <-bobHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected virtual channel at Bob")
+	}
+
+	// Alice pays Bob 5 and finalizes the virtual channel.
+	final := aliceVirtual.State().Clone()
+	final.OfParts[0][0].SetInt64(5)
+	final.OfParts[1][0].SetInt64(25)
+	final.Version++
+	final.IsFinal = true
+	require.NoError(aliceVirtual.Update(ctx, ChannelUpdate{State: final, ActorIdx: aliceVirtual.Idx()}))
+	require.NoError(aliceVirtual.Settle(ctx))
+
+	// The endpoints sign a final state that pays all funds to Bob.
+	conflicting := final.Clone()
+	conflicting.OfParts[0][0].SetInt64(0)
+	conflicting.OfParts[1][0].SetInt64(30)
+	tx := channel.Transaction{State: conflicting, Sigs: make([]wallet.Sig, 2)}
+	for i, acc := range []wallet.Account{prop.Account, bobHandler.acc} {
+		tx.Sigs[i], err = channel.Sign(acc, bobVirtual.Params(), conflicting)
+		require.NoError(err)
+	}
+	bobLedger := bobVirtual.Parent()
+	bobLedger.machMtx.Lock()
+	err = bobLedger.proposeVirtualSettlement(ctx, virtualChannelTx{Params: bobVirtual.Params(), Tx: tx, Idx: bobVirtual.Idx()})
+	bobLedger.machMtx.Unlock()
+	require.Error(err)
+	assert.Contains(t, err.Error(), "rejected")
+
+	// settling with the same final state succeeds
+	require.NoError(bobVirtual.Settle(ctx))
+	assertBals(t, bobLedger.State(), 105, 95)
+}
+
+type (
+	// acceptAllHandler accepts all ledger and virtual channel proposals and
+	// all updates of the new channels.
+	acceptAllHandler struct {
+		t      *testing.T
+		acc    wallet.Account
+		parent *Channel
+		chans  chan *Channel
+	}
+
+	nopFunder      struct{}
+	nopAdjudicator struct{}
+)
+
+func (h *acceptAllHandler) Handle(_ *ChannelProposalReq, res *ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := res.Accept(ctx, ProposalAcc{Participant: h.acc})
+	h.handleChannel(ch, err)
+}
+
+func (h *acceptAllHandler) HandleVirtual(_ *VirtualChannelProposalReq, res *VirtualProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := res.Accept(ctx, VirtualProposalAcc{Participant: h.acc, Parent: h.parent})
+	h.handleChannel(ch, err)
+}
+
+func (h *acceptAllHandler) handleChannel(ch *Channel, err error) {
+	if !assert.NoError(h.t, err) {
+		return
+	}
+	go ch.ListenUpdates(acceptAllUpdateHandler(h.t))
+	h.chans <- ch
+}
+
+func acceptAllUpdateHandler(t *testing.T) UpdateHandler {
+	return UpdateHandlerFunc(func(_ ChannelUpdate, res *UpdateResponder) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		assert.NoError(t, res.Accept(ctx))
+	})
+}
+
+func (nopFunder) Fund(context.Context, channel.FundingReq) error { return nil }
+
+func (nopAdjudicator) Register(_ context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	return &channel.Registered{ID: req.Params.ID(), Idx: req.Idx, Version: req.Tx.Version}, nil
+}
+
+func (nopAdjudicator) Withdraw(context.Context, channel.AdjudicatorReq) error { return nil }
+
+func (nopAdjudicator) SubscribeRegistered(context.Context, *channel.Params) (channel.RegisteredSubscription, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newPaymentProposal(rng *rand.Rand, asset channel.Asset, proposer, proposee peer.Address, bal0, bal1 int64) *ChannelProposal {
+	return &ChannelProposal{
+		ChallengeDuration: 10,
+		NonceShare:        newRandomNonceShare(rng),
+		Account:           wallettest.NewRandomAccount(rng),
+		AppDef:            payment.AppDef(),
+		InitData:          new(payment.NoData),
+		InitBals: &channel.Allocation{
+			Assets:  []channel.Asset{asset},
+			OfParts: [][]channel.Bal{{big.NewInt(bal0)}, {big.NewInt(bal1)}},
+		},
+		PeerAddrs: []peer.Address{proposer, proposee},
+	}
+}
+
+func newTwoPartyState(rng *rand.Rand, asset channel.Asset, bal0, bal1 int64) *channel.State {
+	params := channeltest.NewRandomParams(rng, channeltest.NewRandomApp(rng).Def())
+	return &channel.State{
+		ID:  params.ID(),
+		App: params.App,
+		Allocation: channel.Allocation{
+			Assets:  []channel.Asset{asset},
+			OfParts: [][]channel.Bal{{big.NewInt(bal0)}, {big.NewInt(bal1)}},
+		},
+		Data: channeltest.NewRandomData(rng),
+	}
+}
+
+func assertBals(t *testing.T, state *channel.State, bal0, bal1 int64) {
+	assert.Zero(t, state.OfParts[0][0].Cmp(big.NewInt(bal0)), "bal[0]: %v != %v", state.OfParts[0][0], bal0)
+	assert.Zero(t, state.OfParts[1][0].Cmp(big.NewInt(bal1)), "bal[1]: %v != %v", state.OfParts[1][0], bal1)
+}
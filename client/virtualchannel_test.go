@@ -0,0 +1,302 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/apps/payment"
+	"perun.network/go-perun/channel"
+	channeltest "perun.network/go-perun/channel/test"
+	"perun.network/go-perun/client"
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestVirtualChannel(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0x7e57))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	s := setupVirtualTestLedgers(ctx, t, rng, true)
+	defer func() { assert.NoError(t, s.Close()) }()
+	alice, bob, ingrid := s.alice, s.bob, s.ingrid
+	addrs, asset, bobHandler := s.addrs, s.asset, s.bobHandler
+	aliceLedger, ingridAliceLedger := s.aliceLedger, s.ingridAliceLedger
+	bobLedger, ingridBobLedger := s.bobLedger, s.ingridBobLedger
+
+	// open virtual channel
+	aliceVirtual, err := alice.ProposeVirtualChannel(ctx, &client.VirtualChannelProposal{
+		ChannelProposal: *newTestProposal(rng, asset, addrs[alice], addrs[bob], 10, 20),
+		Intermediary:    addrs[ingrid],
+		Parent:          aliceLedger,
+	})
+	require.NoError(err)
+	var bobVirtual *client.Channel
+	select {
+	case bobVirtual = <-bobHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected virtual channel at Bob")
+	}
+	assert.True(t, aliceVirtual.IsVirtual())
+	assert.True(t, bobVirtual.IsVirtual())
+	assert.Same(t, bobLedger, bobVirtual.Parent())
+	assertLedgerBals(t, aliceLedger.State(), 90, 80, 30)
+	assertLedgerBals(t, bobLedger.State(), 80, 90, 30)
+
+	// Alice pays Bob 5 in the virtual channel and finalizes it.
+	state := aliceVirtual.State().Clone()
+	state.OfParts[0][0].SetInt64(5)
+	state.OfParts[1][0].SetInt64(25)
+	state.IsFinal = true
+	state.Version++
+	require.NoError(aliceVirtual.Update(ctx, client.ChannelUpdate{State: state, ActorIdx: aliceVirtual.Idx()}))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, ch := range []*client.Channel{aliceVirtual, bobVirtual} {
+		go func(ch *client.Channel) {
+			defer wg.Done()
+			assert.NoError(t, ch.Settle(ctx))
+		}(ch)
+	}
+	wg.Wait()
+
+	assertLedgerBals(t, aliceLedger.State(), 95, 105, 0)
+	assertLedgerBals(t, bobLedger.State(), 105, 95, 0)
+	assertLedgerBals(t, ingridAliceLedger.State(), 95, 105, 0)
+	assertLedgerBals(t, ingridBobLedger.State(), 105, 95, 0)
+}
+
+func TestVirtualChannel_NoIntermediary(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7e58))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Ingrid doesn't act as intermediary, so she rejects the funding proposals.
+	s := setupVirtualTestLedgers(ctx, t, rng, false)
+	defer func() { assert.NoError(t, s.Close()) }()
+	s.bobHandler.errs = make(chan error, 1)
+
+	_, err := s.alice.ProposeVirtualChannel(ctx, &client.VirtualChannelProposal{
+		ChannelProposal: *newTestProposal(rng, s.asset, s.addrs[s.alice], s.addrs[s.bob], 10, 20),
+		Intermediary:    s.addrs[s.ingrid],
+		Parent:          s.aliceLedger,
+	})
+	assert.Error(t, err)
+	select {
+	case err := <-s.bobHandler.errs:
+		assert.Error(t, err)
+	case <-ctx.Done():
+		t.Fatal("expected funding error at Bob")
+	}
+	assertLedgerBals(t, s.aliceLedger.State(), 100, 100, 0)
+	assertLedgerBals(t, s.bobLedger.State(), 100, 100, 0)
+}
+
+// virtualTestSetup consists of the clients Alice, Bob and Ingrid, where Alice
+// and Bob have a ledger channel with Ingrid each.
+type virtualTestSetup struct {
+	alice, bob, ingrid *client.Client
+	addrs              map[*client.Client]peer.Address
+	asset              channel.Asset
+	bobHandler         *virtualPropHandler
+	ingridHandler      *virtualPropHandler
+
+	aliceLedger, ingridAliceLedger *client.Channel
+	bobLedger, ingridBobLedger     *client.Channel
+}
+
+// setupVirtualTestLedgers starts the clients of a virtualTestSetup and opens
+// the ledger channels with balances 100/100. If intermediary is set, Ingrid
+// acts as intermediary for virtual channels. Bob's proposal handler uses his
+// ledger channel as parent of virtual channels.
+func setupVirtualTestLedgers(ctx context.Context, t *testing.T, rng *rand.Rand, intermediary bool) *virtualTestSetup {
+	var hub peertest.ConnHub
+	s := &virtualTestSetup{
+		addrs: make(map[*client.Client]peer.Address),
+		asset: channeltest.NewRandomAsset(rng),
+	}
+
+	newClient := func(name string) (*client.Client, *virtualPropHandler) {
+		id := wallettest.NewRandomAccount(rng)
+		h := &virtualPropHandler{
+			t:     t,
+			acc:   wallettest.NewRandomAccount(rng),
+			chans: make(chan *client.Channel, 2),
+		}
+		c := client.New(id, hub.NewDialer(), h,
+			&logFunder{log.WithField("role", name)},
+			&logAdjudicator{log.WithField("role", name)})
+		s.addrs[c] = id.Address()
+		go c.Listen(hub.NewListener(id.Address()))
+		return c, h
+	}
+	s.alice, _ = newClient("Alice")
+	s.bob, s.bobHandler = newClient("Bob")
+	s.ingrid, s.ingridHandler = newClient("Ingrid")
+	if intermediary {
+		s.ingrid.EnableVirtualChannelIntermediary()
+	}
+
+	openLedger := func(c *client.Client) (*client.Channel, *client.Channel) {
+		ch, err := c.ProposeChannel(ctx, newTestProposal(rng, s.asset, s.addrs[c], s.addrs[s.ingrid], 100, 100))
+		require.NoError(t, err)
+		go ch.ListenUpdates(acceptAllUpdates{t})
+		select {
+		case ich := <-s.ingridHandler.chans:
+			return ch, ich
+		case <-ctx.Done():
+			t.Fatal("expected ledger channel at Ingrid")
+			return nil, nil
+		}
+	}
+	s.aliceLedger, s.ingridAliceLedger = openLedger(s.alice)
+	s.bobLedger, s.ingridBobLedger = openLedger(s.bob)
+	s.bobHandler.parent = s.bobLedger
+	return s
+}
+
+// Close closes all clients of the setup.
+func (s *virtualTestSetup) Close() error {
+	for _, c := range []*client.Client{s.alice, s.bob, s.ingrid} {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// virtualPropHandler accepts all ledger, virtual and sub-channel proposals and
+// starts the update handler of the new channels.
+type virtualPropHandler struct {
+	t      *testing.T
+	acc    wallet.Account
+	parent *client.Channel
+	chans  chan *client.Channel
+	// noListen disables starting the update handler on new channels.
+	noListen bool
+	// errs receives the errors of accepting proposals. If nil, the errors fail
+	// the test.
+	errs chan error
+}
+
+func (h *virtualPropHandler) Handle(_ *client.ChannelProposalReq, res *client.ProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := res.Accept(ctx, client.ProposalAcc{Participant: h.acc})
+	h.handleChannel(ch, err)
+}
+
+func (h *virtualPropHandler) HandleVirtual(_ *client.VirtualChannelProposalReq, res *client.VirtualProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := res.Accept(ctx, client.VirtualProposalAcc{Participant: h.acc, Parent: h.parent})
+	h.handleChannel(ch, err)
+}
+
+func (h *virtualPropHandler) HandleSub(_ *client.SubChannelProposalReq, res *client.SubProposalResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ch, err := res.Accept(ctx, client.SubProposalAcc{Participant: h.acc})
+	h.handleChannel(ch, err)
+}
+
+func (h *virtualPropHandler) handleChannel(ch *client.Channel, err error) {
+	if h.errs != nil && err != nil {
+		h.errs <- err
+		return
+	}
+	if !assert.NoError(h.t, err) {
+		return
+	}
+	if !h.noListen {
+		go ch.ListenUpdates(acceptAllUpdates{h.t})
+	}
+	h.chans <- ch
+}
+
+type acceptAllUpdates struct {
+	t *testing.T
+}
+
+func (h acceptAllUpdates) Handle(_ client.ChannelUpdate, res *client.UpdateResponder) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(h.t, res.Accept(ctx))
+}
+
+func newTestProposal(rng *rand.Rand, asset channel.Asset, proposer, proposee peer.Address, bal0, bal1 int64) *client.ChannelProposal {
+	return &client.ChannelProposal{
+		ChallengeDuration: 10,
+		NonceShare:        newRandomNonceShare(rng),
+		Account:           wallettest.NewRandomAccount(rng),
+		AppDef:            payment.AppDef(),
+		InitData:          new(payment.NoData),
+		InitBals: &channel.Allocation{
+			Assets:  []channel.Asset{asset},
+			OfParts: [][]channel.Bal{{big.NewInt(bal0)}, {big.NewInt(bal1)}},
+		},
+		PeerAddrs: []peer.Address{proposer, proposee},
+	}
+}
+
+// setupTwoPartyChannel starts the clients Alice and Bob on the given hub and
+// opens a ledger channel with balances 100/100 between them. Bob accepts the
+// proposal with bobHandler. Alice uses aliceAdj as adjudicator, or a
+// logAdjudicator if it is nil. The caller is responsible for closing both
+// clients.
+func setupTwoPartyChannel(
+	ctx context.Context,
+	t *testing.T,
+	rng *rand.Rand,
+	hub *peertest.ConnHub,
+	bobHandler *virtualPropHandler,
+	aliceAdj channel.Adjudicator,
+) (alice, bob *client.Client, aliceCh, bobCh *client.Channel) {
+	if aliceAdj == nil {
+		aliceAdj = &logAdjudicator{log.WithField("role", "Alice")}
+	}
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	aliceHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice = client.New(aliceID, hub.NewDialer(), aliceHandler,
+		&logFunder{log.WithField("role", "Alice")}, aliceAdj)
+	bob = client.New(bobID, hub.NewDialer(), bobHandler,
+		&logFunder{log.WithField("role", "Bob")}, &logAdjudicator{log.WithField("role", "Bob")})
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	prop := newTestProposal(rng, channeltest.NewRandomAsset(rng), aliceID.Address(), bobID.Address(), 100, 100)
+	aliceCh, err := alice.ProposeChannel(ctx, prop)
+	require.NoError(t, err)
+	select {
+	case bobCh = <-bobHandler.chans:
+	case <-ctx.Done():
+		t.Fatal("expected channel at Bob")
+	}
+	return alice, bob, aliceCh, bobCh
+}
+
+func assertLedgerBals(t *testing.T, state *channel.State, bal0, bal1, locked int64) {
+	assert.Zero(t, state.OfParts[0][0].Cmp(big.NewInt(bal0)), "bal[0]: %v != %v", state.OfParts[0][0], bal0)
+	assert.Zero(t, state.OfParts[1][0].Cmp(big.NewInt(bal1)), "bal[1]: %v != %v", state.OfParts[1][0], bal1)
+	var sumLocked int64
+	for _, sub := range state.Locked {
+		sumLocked += sub.Bals[0].Int64()
+	}
+	assert.Equal(t, locked, sumLocked)
+}
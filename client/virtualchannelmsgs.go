@@ -0,0 +1,180 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"io"
+	"log"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+	"perun.network/go-perun/wire/msg"
+)
+
+func init() {
+	msg.RegisterDecoder(msg.VirtualChannelProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m VirtualChannelProposalReq
+			return &m, m.Decode(r)
+		})
+	msg.RegisterDecoder(msg.VirtualChannelFundingProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m msgVirtualChannelFundingProposal
+			return &m, m.Decode(r)
+		})
+	msg.RegisterDecoder(msg.VirtualChannelSettlementProposal,
+		func(r io.Reader) (msg.Msg, error) {
+			var m msgVirtualChannelSettlementProposal
+			return &m, m.Decode(r)
+		})
+}
+
+type (
+	// VirtualChannelProposalReq is the wire message of a virtual channel
+	// proposal. It is a ChannelProposalReq that additionally names the
+	// intermediary with whom both peers have a ledger channel. It is answered
+	// with a ChannelProposalAcc or ChannelProposalRej.
+	VirtualChannelProposalReq struct {
+		ChannelProposalReq
+		// Intermediary is the Perun address of the common intermediary.
+		Intermediary wallet.Address
+	}
+
+	// virtualChannelTx is a transaction of a virtual channel together with the
+	// virtual channel's parameters, so that the intermediary can verify the
+	// signatures. Idx is the sender's index in the virtual channel.
+	virtualChannelTx struct {
+		Params *channel.Params
+		Tx     channel.Transaction
+		Idx    channel.Index
+	}
+
+	// msgVirtualChannelFundingProposal is the update proposal of a ledger
+	// channel that locks the sender's and the intermediary's funds for a virtual
+	// channel. It carries the fully signed initial state of the virtual channel.
+	msgVirtualChannelFundingProposal struct {
+		msgChannelUpdate
+		Initial virtualChannelTx
+	}
+
+	// msgVirtualChannelSettlementProposal is the update proposal of a ledger
+	// channel that unlocks the funds of a virtual channel and distributes them
+	// according to the fully signed final state of the virtual channel.
+	msgVirtualChannelSettlementProposal struct {
+		msgChannelUpdate
+		Final virtualChannelTx
+	}
+)
+
+var (
+	_ channelUpdateReqMsg = (*msgVirtualChannelFundingProposal)(nil)
+	_ channelUpdateReqMsg = (*msgVirtualChannelSettlementProposal)(nil)
+)
+
+// Type returns msg.VirtualChannelProposal.
+func (VirtualChannelProposalReq) Type() msg.Type {
+	return msg.VirtualChannelProposal
+}
+
+// Encode encodes the VirtualChannelProposalReq into an io.Writer.
+func (c VirtualChannelProposalReq) Encode(w io.Writer) error {
+	if err := c.ChannelProposalReq.Encode(w); err != nil {
+		return err
+	}
+	return errors.WithMessage(c.Intermediary.Encode(w), "intermediary encoding")
+}
+
+// Decode decodes a VirtualChannelProposalReq from an io.Reader.
+func (c *VirtualChannelProposalReq) Decode(r io.Reader) (err error) {
+	if err := c.ChannelProposalReq.Decode(r); err != nil {
+		return err
+	}
+	c.Intermediary, err = wallet.DecodeAddress(r)
+	return errors.WithMessage(err, "intermediary decoding")
+}
+
+// SessID calculates the SessionID of a VirtualChannelProposalReq. It commits
+// to the underlying ChannelProposalReq and the intermediary.
+func (c VirtualChannelProposalReq) SessID() (sid SessionID) {
+	hasher := sha3.New256()
+	if err := wire.Encode(hasher, c.ChannelProposalReq.SessID(), c.Intermediary); err != nil {
+		log.Panicf("session ID encoding: %v", err)
+	}
+
+	copy(sid[:], hasher.Sum(nil))
+	return
+}
+
+// Type returns this message's type: VirtualChannelFundingProposal
+func (*msgVirtualChannelFundingProposal) Type() msg.Type {
+	return msg.VirtualChannelFundingProposal
+}
+
+// Type returns this message's type: VirtualChannelSettlementProposal
+func (*msgVirtualChannelSettlementProposal) Type() msg.Type {
+	return msg.VirtualChannelSettlementProposal
+}
+
+func (m msgVirtualChannelFundingProposal) Encode(w io.Writer) error {
+	if err := m.msgChannelUpdate.Encode(w); err != nil {
+		return err
+	}
+	return m.Initial.Encode(w)
+}
+
+func (m *msgVirtualChannelFundingProposal) Decode(r io.Reader) error {
+	if err := m.msgChannelUpdate.Decode(r); err != nil {
+		return err
+	}
+	return m.Initial.Decode(r)
+}
+
+func (m msgVirtualChannelSettlementProposal) Encode(w io.Writer) error {
+	if err := m.msgChannelUpdate.Encode(w); err != nil {
+		return err
+	}
+	return m.Final.Encode(w)
+}
+
+func (m *msgVirtualChannelSettlementProposal) Decode(r io.Reader) error {
+	if err := m.msgChannelUpdate.Decode(r); err != nil {
+		return err
+	}
+	return m.Final.Decode(r)
+}
+
+func (t virtualChannelTx) Encode(w io.Writer) error {
+	if len(t.Params.Parts) != len(t.Tx.Sigs) {
+		return errors.New("number of signatures doesn't match number of participants")
+	}
+	if err := wire.Encode(w, t.Params, t.Tx.State); err != nil {
+		return err
+	}
+	for i, sig := range t.Tx.Sigs {
+		if err := wire.Encode(w, sig); err != nil {
+			return errors.WithMessagef(err, "encoding signature %d", i)
+		}
+	}
+	return wire.Encode(w, t.Idx)
+}
+
+func (t *virtualChannelTx) Decode(r io.Reader) (err error) {
+	t.Params, t.Tx.State = new(channel.Params), new(channel.State)
+	if err := wire.Decode(r, t.Params, t.Tx.State); err != nil {
+		return err
+	}
+	t.Tx.Sigs = make([]wallet.Sig, len(t.Params.Parts))
+	for i := range t.Tx.Sigs {
+		if t.Tx.Sigs[i], err = wallet.DecodeSig(r); err != nil {
+			return errors.WithMessagef(err, "decoding signature %d", i)
+		}
+	}
+	return wire.Decode(r, &t.Idx)
+}
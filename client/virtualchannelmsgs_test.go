@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"math/rand"
+	"testing"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/channel/test"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestVirtualChannelProposalReqSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x71127a1))
+	for i := 0; i < 4; i++ {
+		req := newRandomValidChannelProposalReq(rng, 2)
+		req.InitBals = test.NewRandomAllocation(rng, 2)
+		m := &VirtualChannelProposalReq{
+			ChannelProposalReq: *req,
+			Intermediary:       wallettest.NewRandomAddress(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func TestVirtualChannelFundingProposalSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xf0d1))
+	for i := 0; i < 4; i++ {
+		m := &msgVirtualChannelFundingProposal{
+			msgChannelUpdate: *newRandomMsgChannelUpdate(rng),
+			Initial:          *newRandomVirtualChannelTx(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func TestVirtualChannelSettlementProposalSerialization(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5e771e))
+	for i := 0; i < 4; i++ {
+		m := &msgVirtualChannelSettlementProposal{
+			msgChannelUpdate: *newRandomMsgChannelUpdate(rng),
+			Final:            *newRandomVirtualChannelTx(rng),
+		}
+		msg.TestMsg(t, m)
+	}
+}
+
+func newRandomMsgChannelUpdate(rng *rand.Rand) *msgChannelUpdate {
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	return &msgChannelUpdate{
+		ChannelUpdate: ChannelUpdate{
+			State:    test.NewRandomState(rng, params),
+			ActorIdx: uint16(rng.Int31n(int32(len(params.Parts)))),
+		},
+		Sig: newRandomSig(rng),
+	}
+}
+
+func newRandomVirtualChannelTx(rng *rand.Rand) *virtualChannelTx {
+	params := test.NewRandomParams(rng, test.NewRandomApp(rng).Def())
+	sigs := make([]wallet.Sig, len(params.Parts))
+	for i := range sigs {
+		sigs[i] = newRandomSig(rng)
+	}
+	return &virtualChannelTx{
+		Params: params,
+		Tx: channel.Transaction{
+			State: test.NewRandomState(rng, params),
+			Sigs:  sigs,
+		},
+		Idx: channel.Index(rng.Int31n(int32(len(params.Parts)))),
+	}
+}
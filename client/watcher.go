@@ -0,0 +1,295 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/log"
+	perunsync "perun.network/go-perun/pkg/sync"
+)
+
+type (
+	// A Watcher watches the adjudicator for Registered events of all channels
+	// that are added to it with Watch. If a registered state is outdated, the
+	// Watcher refutes it by registering the latest signed state of the channel,
+	// depending on its RefutePolicy.
+	//
+	// The Watcher is safe for concurrent use.
+	Watcher struct {
+		perunsync.Closer
+
+		adjudicator channel.Adjudicator
+		handler     WatchHandler
+		log         log.Logger
+
+		mtx     sync.Mutex // protects policy and watched
+		policy  RefutePolicy
+		watched map[channel.ID]*watchedChannel
+	}
+
+	// watchedChannel is the entry of a watched channel. Its cancel function
+	// stops watching the channel.
+	watchedChannel struct {
+		cancel context.CancelFunc
+	}
+
+	// A WatchHandler is notified by the Watcher about on-chain events of the
+	// watched channels. Its methods are called from the go routine of the
+	// watched channel, so they should not block for too long.
+	WatchHandler interface {
+		// HandleRegistered is called for every Registered event of the channel,
+		// before a possible refutation.
+		HandleRegistered(*Channel, *channel.Registered)
+
+		// HandleRefuted is called after the Watcher tried to refute the
+		// registration reg by registering the latest state of the channel. If
+		// successful, refutation is the resulting Registered event and err is
+		// nil.
+		HandleRefuted(ch *Channel, reg, refutation *channel.Registered, err error)
+	}
+
+	// A RefutePolicy decides whether the Watcher should refute the given
+	// registration by registering the latest state of the channel.
+	RefutePolicy func(*Channel, *channel.Registered) bool
+)
+
+var (
+	// RefuteOutdated is the default RefutePolicy of a Watcher. It refutes all
+	// registrations of states older than the current state of the channel.
+	RefuteOutdated RefutePolicy = func(ch *Channel, reg *channel.Registered) bool {
+		return reg.Version < ch.State().Version
+	}
+
+	// RefuteNever is a RefutePolicy that never refutes registrations. Use it if
+	// the WatchHandler should only be notified about registrations.
+	RefuteNever RefutePolicy = func(*Channel, *channel.Registered) bool {
+		return false
+	}
+)
+
+// NewWatcher creates a new Watcher that subscribes to the events of the given
+// adjudicator and reports them to the handler. The RefutePolicy is initially
+// set to RefuteOutdated.
+//
+// If any argument is nil, NewWatcher panics.
+func NewWatcher(adjudicator channel.Adjudicator, handler WatchHandler) *Watcher {
+	if adjudicator == nil {
+		log.Panic("adjudicator must not be nil")
+	}
+	if handler == nil {
+		log.Panic("watch handler must not be nil")
+	}
+
+	w := &Watcher{
+		adjudicator: adjudicator,
+		handler:     handler,
+		log:         log.WithField("role", "watcher"),
+		policy:      RefuteOutdated,
+		watched:     make(map[channel.ID]*watchedChannel),
+	}
+	w.OnClose(w.unwatchAll)
+	return w
+}
+
+// SetRefutePolicy sets the policy that decides whether a registration is
+// refuted. It applies to all future Registered events.
+func (w *Watcher) SetRefutePolicy(p RefutePolicy) {
+	if p == nil {
+		w.log.Panic("refute policy must not be nil")
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.policy = p
+}
+
+// Watch starts watching the adjudicator for Registered events of the given
+// ledger channel. It returns an error if the channel is a virtual or
+// sub-channel, already watched or the subscription fails. Virtual and
+// sub-channels are not registered on-chain, their funds are secured by watching
+// their parent ledger channel.
+//
+// If the adjudicator is a channel.EventSubscriber, the channel is unwatched
+// automatically once it is concluded.
+func (w *Watcher) Watch(ch *Channel) error {
+	if ch.Parent() != nil {
+		return errors.New("virtual and sub-channels cannot be watched, watch the parent channel instead")
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.IsClosed() {
+		return errors.New("watcher closed")
+	}
+	if _, ok := w.watched[ch.ID()]; ok {
+		return errors.New("channel already watched")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sub, err := w.subscribe(ctx, ch.Params())
+	if err != nil {
+		cancel()
+		return errors.WithMessage(err, "subscribing to Registered events")
+	}
+	entry := &watchedChannel{cancel: cancel}
+	w.watched[ch.ID()] = entry
+
+	go w.watch(ctx, entry, ch, sub)
+	return nil
+}
+
+// WatchAll watches all ledger channels of chans that are not watched yet, e.g.,
+// all channels of a client, see Client.Channels. Virtual and sub-channels are
+// skipped. It returns the number of newly watched channels and stops at the
+// first channel that cannot be watched.
+func (w *Watcher) WatchAll(chans []*Channel) (int, error) {
+	n := 0
+	for _, ch := range chans {
+		if ch.Parent() != nil || w.isWatched(ch.ID()) {
+			continue
+		}
+		if err := w.Watch(ch); err != nil {
+			return n, errors.WithMessagef(err, "watching channel %x", ch.ID())
+		}
+		n++
+	}
+	return n, nil
+}
+
+// isWatched returns whether the channel with the given ID is watched.
+func (w *Watcher) isWatched(id channel.ID) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	_, ok := w.watched[id]
+	return ok
+}
+
+// subscribe subscribes to the Registered events of the channel. If the
+// adjudicator is a channel.EventSubscriber, its events are used instead, so
+// that the subscription ends when the channel is concluded.
+func (w *Watcher) subscribe(ctx context.Context, params *channel.Params) (channel.RegisteredSubscription, error) {
+	es, ok := w.adjudicator.(channel.EventSubscriber)
+	if !ok {
+		return w.adjudicator.SubscribeRegistered(ctx, params)
+	}
+	sub, err := es.SubscribeEvents(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return registeredEvents{sub}, nil
+}
+
+// registeredEvents adapts an AdjudicatorSubscription to a
+// RegisteredSubscription. It ends at the first ConcludedEvent.
+type registeredEvents struct {
+	channel.AdjudicatorSubscription
+}
+
+func (r registeredEvents) Next() *channel.Registered {
+	switch ev := r.AdjudicatorSubscription.Next().(type) {
+	case *channel.RegisteredEvent:
+		return &channel.Registered{ID: ev.ID, Version: ev.Version, Timeout: ev.Timeout}
+	case *channel.ProgressedEvent:
+		return &channel.Registered{ID: ev.ID, Version: ev.Version, Timeout: ev.Timeout, Progressed: true}
+	default: // ConcludedEvent or end of subscription
+		return nil
+	}
+}
+
+// Unwatch stops watching the channel with the given ID. It returns false if
+// the channel wasn't watched.
+func (w *Watcher) Unwatch(id channel.ID) bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	entry, ok := w.watched[id]
+	if ok {
+		entry.cancel()
+		delete(w.watched, id)
+	}
+	return ok
+}
+
+// unwatchAll stops watching all channels. It is called when the Watcher is
+// closed.
+func (w *Watcher) unwatchAll() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	for id, entry := range w.watched {
+		entry.cancel()
+		delete(w.watched, id)
+	}
+}
+
+// removeWatched removes the channel with the given ID from the watched
+// channels if it is still watched with the given entry, i.e., it wasn't
+// unwatched and watched again in the meantime.
+func (w *Watcher) removeWatched(id channel.ID, entry *watchedChannel) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	entry.cancel()
+	if w.watched[id] == entry {
+		delete(w.watched, id)
+	}
+}
+
+// watch handles all events of the subscription until it is closed. Afterwards,
+// the channel is removed from the watched channels, unless it was already
+// unwatched. entry is the channel's entry, whose cancel function cancels ctx.
+func (w *Watcher) watch(
+	ctx context.Context,
+	entry *watchedChannel,
+	ch *Channel,
+	sub channel.RegisteredSubscription,
+) {
+	log := w.log.WithField(log.ChannelField, ch.ID())
+	defer func() {
+		if err := sub.Close(); err != nil {
+			log.Warnf("closing subscription: %v", err)
+		}
+		w.removeWatched(ch.ID(), entry)
+	}()
+
+	for reg := sub.Next(); reg != nil; reg = sub.Next() {
+		log.Debugf("Registered event for version %d", reg.Version)
+		w.handleRegistered(ctx, ch, reg)
+	}
+	if err := sub.Err(); err != nil && ctx.Err() == nil {
+		log.Errorf("subscription error: %v", err)
+	}
+}
+
+// handleRegistered notifies the handler about the registration and refutes
+// it if the policy says so. Progressed states cannot be refuted, so the policy
+// is not consulted for them.
+func (w *Watcher) handleRegistered(ctx context.Context, ch *Channel, reg *channel.Registered) {
+	w.handler.HandleRegistered(ch, reg)
+	if reg.Progressed {
+		w.log.WithField(log.ChannelField, ch.ID()).Debugf("State progressed to version %d", reg.Version)
+		return
+	}
+
+	w.mtx.Lock()
+	policy := w.policy
+	w.mtx.Unlock()
+	if ch.Phase() == channel.Settled || !policy(ch, reg) {
+		return
+	}
+
+	req := ch.adjudicatorReq()
+	w.log.WithField(log.ChannelField, ch.ID()).Infof(
+		"Refuting registered version %d with version %d", reg.Version, req.Tx.Version)
+	refutation, err := w.adjudicator.Register(ctx, req)
+	w.handler.HandleRefuted(ch, reg, refutation, errors.WithMessage(err, "registering latest state"))
+}
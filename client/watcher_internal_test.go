@@ -0,0 +1,29 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"perun.network/go-perun/channel"
+)
+
+func TestWatcher_Watch_Virtual(t *testing.T) {
+	w := NewWatcher(new(nopAdjudicator), new(nopWatchHandler))
+	defer w.Close()
+
+	virtual := &Channel{parent: new(Channel)}
+	assert.Error(t, w.Watch(virtual))
+	assert.Empty(t, w.watched)
+}
+
+type nopWatchHandler struct{}
+
+func (nopWatchHandler) HandleRegistered(*Channel, *channel.Registered) {}
+
+func (nopWatchHandler) HandleRefuted(*Channel, *channel.Registered, *channel.Registered, error) {}
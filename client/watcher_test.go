@@ -0,0 +1,248 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package client_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/channel"
+	"perun.network/go-perun/client"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestWatcher(t *testing.T) {
+	require := require.New(t)
+	rng := rand.New(rand.NewSource(0x3a7c8))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// open a channel and update it twice
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice, bob, ch, _ := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+	pay(ctx, t, ch, 10)
+	pay(ctx, t, ch, 10)
+
+	adj := newWatchAdjudicator()
+	handler := &recordingWatchHandler{
+		registered: make(chan *channel.Registered, 2),
+		refuted:    make(chan *channel.Registered, 2),
+	}
+	w := client.NewWatcher(adj, handler)
+	defer w.Close()
+	require.NoError(w.Watch(ch))
+	assert.Error(t, w.Watch(ch), "watching twice")
+	n, err := w.WatchAll(alice.Channels())
+	require.NoError(err)
+	assert.Zero(t, n, "WatchAll skips watched channels")
+
+	awaitReg := func(regs <-chan *channel.Registered) *channel.Registered {
+		select {
+		case reg := <-regs:
+			return reg
+		case <-ctx.Done():
+			t.Fatal("expected event")
+			return nil
+		}
+	}
+
+	// an outdated registration is refuted with the latest state
+	adj.events <- &channel.Registered{ID: ch.ID(), Idx: 1, Version: 1}
+	assert.Equal(t, uint64(1), awaitReg(handler.registered).Version)
+	refutation := awaitReg(handler.refuted)
+	assert.Equal(t, uint64(2), refutation.Version)
+	req := <-adj.registered
+	assert.Equal(t, ch.State().Version, req.Tx.Version)
+
+	// the latest state is not refuted
+	adj.events <- &channel.Registered{ID: ch.ID(), Idx: 0, Version: 2}
+	assert.Equal(t, uint64(2), awaitReg(handler.registered).Version)
+
+	// a progressed state is not refuted, even if it looks outdated
+	adj.events <- &channel.Registered{ID: ch.ID(), Idx: 1, Version: 1, Progressed: true}
+	assert.Equal(t, uint64(1), awaitReg(handler.registered).Version)
+	select {
+	case <-handler.refuted:
+		t.Error("unexpected refutation")
+	case <-adj.registered:
+		t.Error("unexpected registration")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// no refutation with RefuteNever
+	w.SetRefutePolicy(client.RefuteNever)
+	adj.events <- &channel.Registered{ID: ch.ID(), Idx: 1, Version: 0}
+	assert.Equal(t, uint64(0), awaitReg(handler.registered).Version)
+	select {
+	case <-handler.refuted:
+		t.Error("unexpected refutation")
+	case <-adj.registered:
+		t.Error("unexpected registration")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the channel can be watched again after its subscription ended
+	close(adj.events)
+	adj.events = make(chan *channel.Registered)
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.NoError(t, w.Watch(ch))
+	})
+
+	assert.True(t, w.Unwatch(ch.ID()))
+	assert.False(t, w.Unwatch(ch.ID()))
+}
+
+func TestWatcher_EventSubscriber(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x3a7c9))
+	var hub peertest.ConnHub
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	bobHandler := &virtualPropHandler{t: t, acc: wallettest.NewRandomAccount(rng), chans: make(chan *client.Channel, 1)}
+	alice, bob, ch, _ := setupTwoPartyChannel(ctx, t, rng, &hub, bobHandler, nil)
+	defer func() {
+		assert.NoError(t, alice.Close())
+		assert.NoError(t, bob.Close())
+	}()
+	pay(ctx, t, ch, 10)
+
+	adj := &eventAdjudicator{watchAdjudicator: newWatchAdjudicator(), events: make(chan channel.AdjudicatorEvent)}
+	handler := &recordingWatchHandler{
+		registered: make(chan *channel.Registered, 2),
+		refuted:    make(chan *channel.Registered, 2),
+	}
+	w := client.NewWatcher(adj, handler)
+	defer w.Close()
+	require.NoError(t, w.Watch(ch))
+
+	awaitReg := func(regs <-chan *channel.Registered) *channel.Registered {
+		select {
+		case reg := <-regs:
+			return reg
+		case <-ctx.Done():
+			t.Fatal("expected event")
+			return nil
+		}
+	}
+
+	// an outdated registration is refuted
+	adj.events <- &channel.RegisteredEvent{ID: ch.ID(), Version: 0}
+	assert.Equal(t, uint64(0), awaitReg(handler.registered).Version)
+	assert.Equal(t, uint64(1), awaitReg(handler.refuted).Version)
+	<-adj.registered
+
+	// progressions are reported, but not refuted
+	adj.events <- &channel.ProgressedEvent{ID: ch.ID(), Version: 0}
+	reg := awaitReg(handler.registered)
+	assert.True(t, reg.Progressed)
+
+	// the channel is unwatched after its conclusion
+	adj.events <- &channel.ConcludedEvent{ID: ch.ID(), Version: 1}
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.NoError(t, w.Watch(ch))
+	})
+}
+
+type (
+	// eventAdjudicator is a watchAdjudicator that is also an EventSubscriber,
+	// whose events are sent on events.
+	eventAdjudicator struct {
+		*watchAdjudicator
+		events chan channel.AdjudicatorEvent
+	}
+
+	eventSub struct {
+		ctx    context.Context
+		events <-chan channel.AdjudicatorEvent
+	}
+
+	// watchAdjudicator is an Adjudicator whose Registered events are sent on
+	// events. Register calls are reported on registered.
+	watchAdjudicator struct {
+		events     chan *channel.Registered
+		registered chan channel.AdjudicatorReq
+	}
+
+	watchSub struct {
+		ctx    context.Context
+		events <-chan *channel.Registered
+	}
+
+	recordingWatchHandler struct {
+		registered chan *channel.Registered
+		refuted    chan *channel.Registered
+	}
+)
+
+func newWatchAdjudicator() *watchAdjudicator {
+	return &watchAdjudicator{
+		events:     make(chan *channel.Registered),
+		registered: make(chan channel.AdjudicatorReq, 1),
+	}
+}
+
+func (a *watchAdjudicator) Register(_ context.Context, req channel.AdjudicatorReq) (*channel.Registered, error) {
+	a.registered <- req
+	return &channel.Registered{ID: req.Params.ID(), Idx: req.Idx, Version: req.Tx.Version}, nil
+}
+
+func (a *watchAdjudicator) Withdraw(context.Context, channel.AdjudicatorReq) error {
+	return nil
+}
+
+func (a *watchAdjudicator) SubscribeRegistered(ctx context.Context, _ *channel.Params) (channel.RegisteredSubscription, error) {
+	return &watchSub{ctx: ctx, events: a.events}, nil
+}
+
+func (s *watchSub) Next() *channel.Registered {
+	select {
+	case reg := <-s.events:
+		return reg
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+func (s *watchSub) Err() error   { return s.ctx.Err() }
+func (s *watchSub) Close() error { return nil }
+
+func (a *eventAdjudicator) SubscribeEvents(ctx context.Context, _ *channel.Params) (channel.AdjudicatorSubscription, error) {
+	return &eventSub{ctx: ctx, events: a.events}, nil
+}
+
+func (s *eventSub) Next() channel.AdjudicatorEvent {
+	select {
+	case ev := <-s.events:
+		return ev
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+func (s *eventSub) Err() error   { return s.ctx.Err() }
+func (s *eventSub) Close() error { return nil }
+
+func (h *recordingWatchHandler) HandleRegistered(_ *client.Channel, reg *channel.Registered) {
+	h.registered <- reg
+}
+
+func (h *recordingWatchHandler) HandleRefuted(_ *client.Channel, _, refutation *channel.Registered, err error) {
+	if err == nil {
+		h.refuted <- refutation
+	}
+}
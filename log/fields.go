@@ -0,0 +1,22 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package log
+
+// The keys of the fields that go-perun adds to its log entries. Operators can
+// filter by them to trace a single channel's protocol run, e.g., all entries
+// whose ChannelField is the channel's ID.
+const (
+	// IDField is the own Perun address of the client.
+	IDField = "id"
+	// PeerField is the Perun address of the peer that a protocol runs with.
+	PeerField = "peer"
+	// ChannelField is the ID of the channel.
+	ChannelField = "channel"
+	// PhaseField is the phase of the channel's state machine.
+	PhaseField = "phase"
+	// VersionField is the version of the channel state that is updated.
+	VersionField = "version"
+)
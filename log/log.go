@@ -10,7 +10,8 @@
 // It mimics the interface of logrus, which is go-perun's logger of choice
 // It is also possible to pass a simpler logger like the standard library's log
 // logger by converting it to a perun logger. Use the Fieldify and Levellify
-// factories for that.
+// factories for that. Adapters for logrus and zap loggers are provided by the
+// subpackages logrus and zap.
 package log // import "perun.network/go-perun/log"
 
 import "log"
@@ -0,0 +1,91 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package zap provides an adapter of the zap logger to the logger interface
+// of go-perun.
+package zap // import "perun.network/go-perun/log/zap"
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"perun.network/go-perun/log"
+)
+
+// Logger wraps a zap sugared logger. Since zap has no trace level, trace
+// messages are logged at the debug level. Print messages are logged at the
+// info level.
+type Logger struct {
+	*zap.SugaredLogger
+}
+
+var _ log.Logger = (*Logger)(nil)
+
+// FromZap creates a logger from a zap.Logger.
+func FromZap(l *zap.Logger) *Logger {
+	return &Logger{l.Sugar()}
+}
+
+// Printf calls Infof on the zap logger.
+func (l *Logger) Printf(format string, args ...interface{}) { l.Infof(format, args...) }
+
+// Print calls Info on the zap logger.
+func (l *Logger) Print(args ...interface{}) { l.Info(args...) }
+
+// Println calls Info on the zap logger.
+func (l *Logger) Println(args ...interface{}) { l.Info(sprintln(args...)) }
+
+// Tracef calls Debugf on the zap logger.
+func (l *Logger) Tracef(format string, args ...interface{}) { l.Debugf(format, args...) }
+
+// Trace calls Debug on the zap logger.
+func (l *Logger) Trace(args ...interface{}) { l.Debug(args...) }
+
+// Traceln calls Debug on the zap logger.
+func (l *Logger) Traceln(args ...interface{}) { l.Debug(sprintln(args...)) }
+
+// Debugln calls Debug on the zap logger.
+func (l *Logger) Debugln(args ...interface{}) { l.Debug(sprintln(args...)) }
+
+// Infoln calls Info on the zap logger.
+func (l *Logger) Infoln(args ...interface{}) { l.Info(sprintln(args...)) }
+
+// Warnln calls Warn on the zap logger.
+func (l *Logger) Warnln(args ...interface{}) { l.Warn(sprintln(args...)) }
+
+// Errorln calls Error on the zap logger.
+func (l *Logger) Errorln(args ...interface{}) { l.Error(sprintln(args...)) }
+
+// Panicln calls Panic on the zap logger.
+func (l *Logger) Panicln(args ...interface{}) { l.Panic(sprintln(args...)) }
+
+// Fatalln calls Fatal on the zap logger.
+func (l *Logger) Fatalln(args ...interface{}) { l.Fatal(sprintln(args...)) }
+
+// WithField calls With on the zap logger.
+func (l *Logger) WithField(key string, value interface{}) log.Logger {
+	return &Logger{l.With(key, value)}
+}
+
+// WithFields calls With on the zap logger with all fields.
+func (l *Logger) WithFields(fields log.Fields) log.Logger {
+	kvs := make([]interface{}, 0, 2*len(fields))
+	for k, v := range fields {
+		kvs = append(kvs, k, v)
+	}
+	return &Logger{l.With(kvs...)}
+}
+
+// WithError calls With on the zap logger with the field "error".
+func (l *Logger) WithError(err error) log.Logger {
+	return &Logger{l.With(zap.Error(err))}
+}
+
+// sprintln formats the arguments like fmt.Sprintln, without the newline.
+func sprintln(args ...interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package zap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZap(t *testing.T) {
+	a := assert.New(t)
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := FromZap(zap.New(core))
+
+	logger.Println("Anton", "Ausdemhaus")
+	entry := logs.TakeAll()[0]
+	a.Equal(zapcore.InfoLevel, entry.Level)
+	a.Equal("Anton Ausdemhaus", entry.Message)
+
+	logger.Tracef("Bertha %s", "Bremsweg")
+	entry = logs.TakeAll()[0]
+	a.Equal(zapcore.DebugLevel, entry.Level, "trace is logged as debug")
+	a.Equal("Bertha Bremsweg", entry.Message)
+
+	logger.WithField("field", 123456).Errorln("Christian Chaos")
+	entry = logs.TakeAll()[0]
+	a.Equal(zapcore.ErrorLevel, entry.Level)
+	a.Equal(map[string]interface{}{"field": int64(123456)}, entry.ContextMap())
+
+	fields := map[string]interface{}{"mars": "249", "jupiter": "816"}
+	logger.WithFields(fields).Warn("Doris Day")
+	a.Equal(fields, logs.TakeAll()[0].ContextMap())
+
+	logger.WithError(errors.New("error-message")).Info("Emil Eisenbart")
+	a.Equal(map[string]interface{}{"error": "error-message"}, logs.TakeAll()[0].ContextMap())
+}
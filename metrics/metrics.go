@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package metrics defines the metrics that the client and peer layers of
+// go-perun record. Like the logger, the metrics are set globally with Set,
+// e.g., to the Prometheus metrics of the subpackage prometheus. By default, no
+// metrics are recorded.
+package metrics // import "perun.network/go-perun/metrics"
+
+import "time"
+
+// Metrics records the events of the client and peer layers.
+// Implementations must be thread-safe.
+type Metrics interface {
+	// ChannelOpened is called when a funded or restored channel is added to a
+	// client.
+	ChannelOpened()
+	// ChannelClosed is called when an opened channel is closed.
+	ChannelClosed()
+
+	// ProposalAccepted is called when a peer accepted our channel proposal or
+	// we accepted the proposal of a peer.
+	ProposalAccepted()
+	// ProposalRejected is called when a peer rejected our channel proposal or
+	// we rejected the proposal of a peer.
+	ProposalRejected()
+
+	// UpdateRoundTrip is called with the time between sending a channel
+	// update and receiving the response of the peer.
+	UpdateRoundTrip(time.Duration)
+	// Funded is called with the duration of a successful channel funding.
+	Funded(time.Duration)
+
+	// EncodeError is called when a message could not be sent over a peer
+	// connection.
+	EncodeError()
+	// DecodeError is called when a message could not be received over a peer
+	// connection, unless the connection was closed.
+	DecodeError()
+
+	// Reconnected is called when a peer connection is set up with an address
+	// that we were connected to before.
+	Reconnected()
+}
+
+// metrics is the framework's Metrics. It must not be set directly but through
+// Set.
+var metrics Metrics = none{}
+
+// Set sets the framework's Metrics. It must be called before the client is
+// constructed. Set accepts nil and then disables metrics.
+func Set(m Metrics) {
+	if m == nil {
+		metrics = none{}
+		return
+	}
+	metrics = m
+}
+
+// Get returns the currently set Metrics.
+func Get() Metrics {
+	return metrics
+}
+
+// ChannelOpened calls ChannelOpened on the global Metrics.
+func ChannelOpened() { metrics.ChannelOpened() }
+
+// ChannelClosed calls ChannelClosed on the global Metrics.
+func ChannelClosed() { metrics.ChannelClosed() }
+
+// ProposalAccepted calls ProposalAccepted on the global Metrics.
+func ProposalAccepted() { metrics.ProposalAccepted() }
+
+// ProposalRejected calls ProposalRejected on the global Metrics.
+func ProposalRejected() { metrics.ProposalRejected() }
+
+// UpdateRoundTrip calls UpdateRoundTrip on the global Metrics.
+func UpdateRoundTrip(d time.Duration) { metrics.UpdateRoundTrip(d) }
+
+// Funded calls Funded on the global Metrics.
+func Funded(d time.Duration) { metrics.Funded(d) }
+
+// EncodeError calls EncodeError on the global Metrics.
+func EncodeError() { metrics.EncodeError() }
+
+// DecodeError calls DecodeError on the global Metrics.
+func DecodeError() { metrics.DecodeError() }
+
+// Reconnected calls Reconnected on the global Metrics.
+func Reconnected() { metrics.Reconnected() }
+
+// none is the Metrics that records nothing.
+type none struct{}
+
+func (none) ChannelOpened()                {}
+func (none) ChannelClosed()                {}
+func (none) ProposalAccepted()             {}
+func (none) ProposalRejected()             {}
+func (none) UpdateRoundTrip(time.Duration) {}
+func (none) Funded(time.Duration)          {}
+func (none) EncodeError()                  {}
+func (none) DecodeError()                  {}
+func (none) Reconnected()                  {}
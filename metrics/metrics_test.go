@@ -0,0 +1,35 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingMetrics struct {
+	none
+	opened int
+}
+
+func (m *countingMetrics) ChannelOpened() { m.opened++ }
+
+func TestSet(t *testing.T) {
+	defer Set(nil)
+	assert.Equal(t, none{}, Get(), "no metrics by default")
+
+	m := new(countingMetrics)
+	Set(m)
+	assert.Same(t, m, Get())
+	ChannelOpened()
+	UpdateRoundTrip(time.Second) // recorded by none
+	assert.Equal(t, 1, m.opened)
+
+	Set(nil)
+	assert.Equal(t, none{}, Get())
+}
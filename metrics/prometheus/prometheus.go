@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package prometheus implements the metrics of go-perun with Prometheus
+// collectors.
+package prometheus // import "perun.network/go-perun/metrics/prometheus"
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/metrics"
+)
+
+// namespace is the namespace of all collectors.
+const namespace = "perun"
+
+// Metrics records the metrics of go-perun in Prometheus collectors.
+type Metrics struct {
+	openChannels    prometheus.Gauge
+	proposals       *prometheus.CounterVec // by result
+	updateRoundTrip prometheus.Histogram
+	fundingDuration prometheus.Histogram
+	wireErrors      *prometheus.CounterVec // by operation
+	reconnects      prometheus.Counter
+}
+
+var _ metrics.Metrics = (*Metrics)(nil)
+
+// New creates the collectors and registers them on the given registry.
+//
+// If the registry is nil, New panics.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	if reg == nil {
+		log.Panic("registry must not be nil")
+	}
+
+	m := &Metrics{
+		openChannels: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "client", Name: "open_channels",
+			Help: "Number of open channels.",
+		}),
+		proposals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "client", Name: "proposals_total",
+			Help: "Number of channel proposals that were accepted or rejected, by us or our peers.",
+		}, []string{"result"}),
+		updateRoundTrip: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "client", Name: "update_round_trip_seconds",
+			Help:    "Time between sending a channel update and receiving the response.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		fundingDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "client", Name: "funding_duration_seconds",
+			Help:    "Duration of successful channel fundings.",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 12), // up to 17 minutes
+		}),
+		wireErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "peer", Name: "wire_errors_total",
+			Help: "Number of messages that could not be encoded or decoded.",
+		}, []string{"op"}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "peer", Name: "reconnects_total",
+			Help: "Number of connections to previously connected peers.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.openChannels, m.proposals, m.updateRoundTrip,
+		m.fundingDuration, m.wireErrors, m.reconnects,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, errors.Wrap(err, "registering collector")
+		}
+	}
+	return m, nil
+}
+
+// Set creates the metrics on the given registry and sets them as the
+// framework's metrics.
+func Set(reg prometheus.Registerer) error {
+	m, err := New(reg)
+	if err != nil {
+		return err
+	}
+	metrics.Set(m)
+	return nil
+}
+
+// ChannelOpened increments the number of open channels.
+func (m *Metrics) ChannelOpened() { m.openChannels.Inc() }
+
+// ChannelClosed decrements the number of open channels.
+func (m *Metrics) ChannelClosed() { m.openChannels.Dec() }
+
+// ProposalAccepted counts an accepted proposal.
+func (m *Metrics) ProposalAccepted() { m.proposals.WithLabelValues("accepted").Inc() }
+
+// ProposalRejected counts a rejected proposal.
+func (m *Metrics) ProposalRejected() { m.proposals.WithLabelValues("rejected").Inc() }
+
+// UpdateRoundTrip observes the round-trip time of an update.
+func (m *Metrics) UpdateRoundTrip(d time.Duration) { m.updateRoundTrip.Observe(d.Seconds()) }
+
+// Funded observes the duration of a funding.
+func (m *Metrics) Funded(d time.Duration) { m.fundingDuration.Observe(d.Seconds()) }
+
+// EncodeError counts an encoding error.
+func (m *Metrics) EncodeError() { m.wireErrors.WithLabelValues("encode").Inc() }
+
+// DecodeError counts a decoding error.
+func (m *Metrics) DecodeError() { m.wireErrors.WithLabelValues("decode").Inc() }
+
+// Reconnected counts a reconnection.
+func (m *Metrics) Reconnected() { m.reconnects.Inc() }
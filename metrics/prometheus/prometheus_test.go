@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	assert.Panics(t, func() { New(nil) })
+
+	reg := prometheus.NewPedanticRegistry()
+	m, err := New(reg)
+	require.NoError(t, err)
+	_, err = New(reg)
+	assert.Error(t, err, "collectors registered twice")
+
+	m.ChannelOpened()
+	m.ChannelOpened()
+	m.ChannelClosed()
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.openChannels))
+
+	m.ProposalAccepted()
+	m.ProposalRejected()
+	m.ProposalRejected()
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.proposals.WithLabelValues("accepted")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.proposals.WithLabelValues("rejected")))
+
+	m.EncodeError()
+	m.DecodeError()
+	m.Reconnected()
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.wireErrors.WithLabelValues("encode")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.wireErrors.WithLabelValues("decode")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.reconnects))
+
+	m.UpdateRoundTrip(10 * time.Millisecond)
+	m.Funded(2 * time.Second)
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	counts := make(map[string]uint64)
+	for _, f := range families {
+		if h := f.GetMetric()[0].GetHistogram(); h != nil {
+			counts[f.GetName()] = h.GetSampleCount()
+		}
+	}
+	assert.Equal(t, map[string]uint64{
+		"perun_client_update_round_trip_seconds": 1,
+		"perun_client_funding_duration_seconds":  1,
+	}, counts)
+}
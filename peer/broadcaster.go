@@ -28,13 +28,25 @@ type Broadcaster struct {
 // Otherwise, the returned error contains an array of all individual errors
 // that occurred.
 func (b *Broadcaster) Send(ctx context.Context, m wire.Msg) error {
+	return b.send(ctx, m, (*Peer).Send)
+}
+
+// SendReliable is like Send, but sends the message to each recipient with
+// Peer.SendReliable.
+func (b *Broadcaster) SendReliable(ctx context.Context, m wire.Msg) error {
+	return b.send(ctx, m, (*Peer).SendReliable)
+}
+
+// send sends the message to all recipients in parallel with the given send
+// function.
+func (b *Broadcaster) send(ctx context.Context, m wire.Msg, send func(*Peer, context.Context, wire.Msg) error) error {
 	gather := make(chan sendError, len(b.peers))
 	// Send all messages in parallel.
 	for i, p := range b.peers {
 		go func(i int, p *Peer) {
 			gather <- sendError{
 				index: i,
-				err:   p.Send(ctx, m),
+				err:   send(p, ctx, m),
 			}
 		}(i, p)
 	}
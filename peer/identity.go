@@ -6,6 +6,7 @@
 package peer
 
 import (
+	"bytes"
 	"context"
 	"io"
 
@@ -13,6 +14,7 @@ import (
 
 	"perun.network/go-perun/pkg/test"
 	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
 	"perun.network/go-perun/wire/msg"
 )
 
@@ -30,44 +32,80 @@ func init() {
 type Identity = wallet.Account
 
 // ExchangeAddrs exchanges Perun addresses of peers. It's the initial protocol
-// that is run when a new peer connection is established. It returns the address
-// of the peer on the other end of the connection. If the supplied context times
-// out before the protocol finishes, closes the connection.
+// that is run when a new peer connection is established. Both peers send their
+// address together with a signature on it, which is verified against the
+// received address. It returns the verified address of the peer on the other
+// end of the connection. If the supplied context times out before the protocol
+// finishes, closes the connection.
 //
-// In the future, ExchangeAddrs will be replaced by Authenticate to run a proper
-// authentication protocol. The protocol will then exchange Perun addresses and
-// establish authenticity.
+// The signature does not cover a challenge of the peer yet, so it does not
+// protect against replayed AuthResponseMsgs. A Security on the transport
+// protects against them, since the peer then has to claim the address that
+// the Security authenticated, see AuthenticatedConn.
+//
+// ExchangeAddrs announces no capabilities, see Handshake.
 func ExchangeAddrs(ctx context.Context, id Identity, conn Conn) (Address, error) {
+	addr, _, err := Handshake(ctx, id, 0, conn)
+	return addr, err
+}
+
+// Handshake is like ExchangeAddrs, but additionally announces our capabilities
+// caps to the peer. It returns the capabilities that both we and the peer
+// support. A peer of another wire protocol version is reported as an
+// msg.IncompatibleVersionError.
+func Handshake(ctx context.Context, id Identity, caps msg.Capabilities, conn Conn) (Address, msg.Capabilities, error) {
+	authMsg, err := NewAuthResponseMsg(id)
+	if err != nil {
+		conn.Close()
+		return nil, 0, errors.WithMessage(err, "creating AuthResponse")
+	}
+	authMsg.(*AuthResponseMsg).Capabilities = caps
+
 	var addr Address
-	var err error
+	var peerCaps msg.Capabilities
 	ok := test.TerminatesCtx(ctx, func() {
 		sent := make(chan error, 1)
-		go func() { sent <- conn.Send(NewAuthResponseMsg(id)) }()
+		go func() { sent <- conn.Send(authMsg) }()
 
 		var m msg.Msg
 		if m, err = conn.Recv(); err != nil {
 			err = errors.WithMessage(err, "Failed to receive message")
 		} else if addrM, ok := m.(*AuthResponseMsg); !ok {
 			err = errors.Errorf("Expected AuthResponse wire msg, got %v", m.Type())
+		} else if err = addrM.verify(); err != nil {
+			err = errors.WithMessage(err, "verifying AuthResponse")
 		} else {
 			err = <-sent // Wait until the message was sent.
-			addr = addrM.Address
+			addr, peerCaps = addrM.Address, addrM.Capabilities
 		}
 	})
 
 	if !ok {
 		conn.Close()
-		return nil, ctx.Err()
+		return nil, 0, ctx.Err()
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if auth, ok := conn.(AuthenticatedConn); ok {
+		if peerAddr := auth.PeerAddress(); peerAddr == nil || !peerAddr.Equals(addr) {
+			conn.Close()
+			return nil, 0, errors.Errorf("peer claimed address %v, but was authenticated as %v", addr, peerAddr)
+		}
 	}
 
-	return addr, err
+	return addr, caps.Intersect(peerCaps), nil
 }
 
 var _ msg.Msg = (*AuthResponseMsg)(nil)
 
 // AuthResponseMsg is the response message in the peer authentication protocol.
+// It contains the sender's address and its signature on the encoded address,
+// as well as the sender's capabilities.
 type AuthResponseMsg struct {
-	Address Address
+	Address      Address
+	Sig          wallet.Sig
+	Capabilities msg.Capabilities
 }
 
 // Type returns msg.AuthResponse.
@@ -77,18 +115,53 @@ func (m *AuthResponseMsg) Type() msg.Type {
 
 // Encode encodes this AuthResponseMsg into an io.Writer.
 func (m *AuthResponseMsg) Encode(w io.Writer) error {
-	return m.Address.Encode(w)
+	return wire.Encode(w, m.Address, m.Sig, uint32(m.Capabilities))
 }
 
 // Decode decodes an AuthResponseMsg from an io.Reader.
 func (m *AuthResponseMsg) Decode(r io.Reader) (err error) {
-	m.Address, err = wallet.DecodeAddress(r)
-	return
+	if m.Address, err = wallet.DecodeAddress(r); err != nil {
+		return errors.WithMessage(err, "decoding address")
+	}
+	if m.Sig, err = wallet.DecodeSig(r); err != nil {
+		return errors.WithMessage(err, "decoding signature")
+	}
+	return errors.WithMessage(wire.Decode(r, (*uint32)(&m.Capabilities)), "decoding capabilities")
+}
+
+// verify checks that the signature was made by the sender's address.
+func (m *AuthResponseMsg) verify() error {
+	enc, err := encodeAuthAddr(m.Address)
+	if err != nil {
+		return err
+	}
+	if ok, err := wallet.VerifySignature(enc, m.Sig, m.Address); err != nil {
+		return errors.WithMessage(err, "verifying signature")
+	} else if !ok {
+		return errors.New("invalid signature")
+	}
+	return nil
 }
 
-// NewAuthResponseMsg creates an authentication response message.
-// In the future, it will also take an authentication challenge message as
-// additional argument.
-func NewAuthResponseMsg(id Identity) msg.Msg {
-	return &AuthResponseMsg{id.Address()}
+// NewAuthResponseMsg creates an authentication response message, which is
+// signed by the identity.
+func NewAuthResponseMsg(id Identity) (msg.Msg, error) {
+	enc, err := encodeAuthAddr(id.Address())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := id.SignData(enc)
+	if err != nil {
+		return nil, errors.WithMessage(err, "signing address")
+	}
+	return &AuthResponseMsg{Address: id.Address(), Sig: sig}, nil
+}
+
+// encodeAuthAddr encodes the address that is signed in an AuthResponseMsg.
+func encodeAuthAddr(addr Address) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := addr.Encode(&buf); err != nil {
+		return nil, errors.WithMessage(err, "encoding address")
+	}
+	return buf.Bytes(), nil
 }
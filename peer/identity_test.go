@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"perun.network/go-perun/pkg/test"
 	wallettest "perun.network/go-perun/wallet/test"
@@ -20,7 +21,9 @@ import (
 
 func TestAuthResponseMsg(t *testing.T) {
 	rng := rand.New(rand.NewSource(1337))
-	msg.TestMsg(t, NewAuthResponseMsg(wallettest.NewRandomAccount(rng)))
+	m, err := NewAuthResponseMsg(wallettest.NewRandomAccount(rng))
+	require.NoError(t, err)
+	msg.TestMsg(t, m)
 }
 
 func TestExchangeAddrs_ConnFail(t *testing.T) {
@@ -56,6 +59,57 @@ func TestExchangeAddrs_Success(t *testing.T) {
 	wg.Wait()
 }
 
+func TestHandshake_Capabilities(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xca95))
+	conn0, conn1 := newPipeConnPair()
+	defer conn0.Close()
+	defer conn1.Close()
+	const capOther msg.Capabilities = 1 << 8
+	account0, account1 := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	caps1 := make(chan msg.Capabilities, 1)
+	go func() {
+		_, caps, err := Handshake(context.Background(), account1, msg.CapProposalAbort, conn1)
+		assert.NoError(t, err)
+		caps1 <- caps
+	}()
+
+	addr, caps0, err := Handshake(context.Background(), account0, msg.CapProposalAbort|capOther, conn0)
+	require.NoError(t, err)
+	assert.True(t, addr.Equals(account1.Address()))
+	assert.Equal(t, msg.CapProposalAbort, caps0, "only common capabilities")
+	assert.Equal(t, msg.CapProposalAbort, <-caps1, "only common capabilities")
+}
+
+func TestHandshake_AuthenticatedConn(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa0c))
+	account0, account1 := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	for _, tt := range []struct {
+		name string
+		addr Address
+		ok   bool
+	}{
+		{"authenticated", account1.Address(), true},
+		{"impersonated", wallettest.NewRandomAddress(rng), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn0, conn1 := newPipeConnPair()
+			defer conn0.Close()
+			defer conn1.Close()
+			go Handshake(context.Background(), account1, 0, conn1)
+
+			addr, _, err := Handshake(context.Background(), account0, 0, NewAuthenticatedConn(conn0, tt.addr))
+			if tt.ok {
+				require.NoError(t, err)
+				assert.True(t, addr.Equals(account1.Address()))
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
 func TestExchangeAddrs_Timeout(t *testing.T) {
 	rng := rand.New(rand.NewSource(0xDDDDDeDe))
 	a, _ := newPipeConnPair()
@@ -79,3 +133,18 @@ func TestExchangeAddrs_BogusMsg(t *testing.T) {
 	assert.Error(t, err, "ExchangeAddrs should error when peer sends a non-AuthResponseMsg")
 	assert.Nil(t, addr)
 }
+
+func TestExchangeAddrs_BogusSig(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xb055))
+	acc, signer := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+	m, err := NewAuthResponseMsg(signer)
+	require.NoError(t, err)
+	// Claim the address of another account.
+	m.(*AuthResponseMsg).Address = wallettest.NewRandomAddress(rng)
+	conn := newMockConn(nil)
+	conn.recvQueue <- m
+	addr, err := ExchangeAddrs(context.Background(), acc, conn)
+
+	assert.Error(t, err, "ExchangeAddrs should error when the signature is invalid")
+	assert.Nil(t, addr)
+}
@@ -8,6 +8,10 @@ package peer
 import (
 	"io"
 
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/metrics"
+	"perun.network/go-perun/pkg/sync/atomic"
 	wire "perun.network/go-perun/wire/msg"
 )
 
@@ -15,7 +19,8 @@ var _ Conn = (*ioConn)(nil)
 
 // IoConn is a connection that communicates its messages over an io stream.
 type ioConn struct {
-	conn io.ReadWriteCloser
+	conn   io.ReadWriteCloser
+	closed atomic.Bool // whether Close was called, so that errors are expected
 }
 
 // NewIoConn creates a peer message connection from an io stream.
@@ -27,6 +32,9 @@ func NewIoConn(conn io.ReadWriteCloser) Conn {
 
 func (c *ioConn) Send(m wire.Msg) error {
 	if err := wire.Encode(m, c.conn); err != nil {
+		if !c.closed.IsSet() {
+			metrics.EncodeError()
+		}
 		c.conn.Close()
 		return err
 	}
@@ -36,6 +44,10 @@ func (c *ioConn) Send(m wire.Msg) error {
 func (c *ioConn) Recv() (wire.Msg, error) {
 	m, err := wire.Decode(c.conn)
 	if err != nil {
+		// A closed connection is no decoding error.
+		if !c.closed.IsSet() && errors.Cause(err) != io.EOF {
+			metrics.DecodeError()
+		}
 		c.conn.Close()
 		return nil, err
 	}
@@ -43,5 +55,6 @@ func (c *ioConn) Recv() (wire.Msg, error) {
 }
 
 func (c *ioConn) Close() error {
+	c.closed.Set()
 	return c.conn.Close()
 }
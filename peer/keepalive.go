@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"perun.network/go-perun/log"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+// keepAlive pings the peer every interval once it exists. If the peer misses
+// maxMissed pongs in a row, it is marked as unreachable and closed. A ping
+// that cannot be sent within the interval also closes the peer.
+func (p *Peer) keepAlive(interval time.Duration, maxMissed int) {
+	if !p.waitExists(nil) {
+		return
+	}
+	log := log.WithField(log.PeerField, p.PerunAddress)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var pinged int64 // time of the last ping in Unix nanoseconds, 0 if none
+	missed := 0
+	for {
+		if pinged != 0 {
+			if atomic.LoadInt64(&p.lastPong) < pinged {
+				missed++
+			} else {
+				missed = 0
+			}
+		}
+		if missed >= maxMissed {
+			log.Warnf("peer missed %d pongs, closing unreachable peer", missed)
+			p.unreachable.Set()
+			p.Close()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		pinged = time.Now().UnixNano()
+		err := p.Send(ctx, wire.NewPingMsg())
+		cancel()
+		if err != nil {
+			log.Debugf("sending ping: %v", err)
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.Closed():
+			return
+		}
+	}
+}
+
+// pong answers the ping of the peer.
+func (p *Peer) pong() {
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+	if err := p.Send(ctx, wire.NewPongMsg()); err != nil {
+		log.WithField(log.PeerField, p.PerunAddress).Debugf("sending pong: %v", err)
+	}
+}
+
+// Unreachable returns whether the peer was closed because it did not answer
+// the pings of the keepalive, see Registry.SetKeepAlive.
+func (p *Peer) Unreachable() bool {
+	return p.unreachable.IsSet()
+}
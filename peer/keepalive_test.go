@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+const keepAliveInterval = 10 * time.Millisecond
+
+func TestPeer_keepAlive_Unreachable(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x1a1e))
+	var pings int32
+	p := newPeer(wallettest.NewRandomAddress(rng), newMockConn(func(m wire.Msg) {
+		if _, ok := m.(*wire.PingMsg); ok {
+			atomic.AddInt32(&pings, 1)
+		}
+	}), nil)
+
+	go p.keepAlive(keepAliveInterval, 3)
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.True(t, p.IsClosed())
+	})
+	assert.True(t, p.Unreachable())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&pings))
+}
+
+func TestPeer_keepAlive_Alive(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xa11fe))
+	p := newPeer(nil, nil, nil)
+	// The peer answers every ping immediately.
+	p.create(newMockConn(func(m wire.Msg) {
+		atomic.StoreInt64(&p.lastPong, time.Now().UnixNano())
+	}), 0)
+	p.PerunAddress = wallettest.NewRandomAddress(rng)
+	defer p.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.keepAlive(keepAliveInterval, 1)
+	}()
+	time.Sleep(10 * keepAliveInterval)
+	assert.False(t, p.IsClosed())
+	assert.NoError(t, p.Close())
+	<-done
+	assert.False(t, p.Unreachable())
+}
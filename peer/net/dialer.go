@@ -18,12 +18,18 @@ import (
 )
 
 // Dialer is a simple lookup-table based dialer that can dial known peers.
-// New peer addresses can be added via Register().
+// New peer addresses can be added via Register(). The endpoints of unknown
+// peers are resolved by the Resolver set via SetResolver(), if any. Dialed
+// connections are secured by the Security set via SetSecurity(), if any.
 type Dialer struct {
-	mutex   sync.RWMutex            // Protects peers.
-	peers   map[peer.Address]string // Known peer addresses.
-	dialer  net.Dialer              // Used to dial connections.
-	network string                  // The socket type.
+	mutex    sync.RWMutex      // Protects resolver and security.
+	peers    *peer.AddressBook // Known peer addresses.
+	resolver peer.Resolver     // Resolves unknown peer addresses.
+	security peer.Security     // Secures dialed connections, if set.
+	dialer   net.Dialer        // Used to dial connections.
+	network  string            // The socket type.
+	// dial dials a registered host. Defaults to dialing the plain socket.
+	dial func(ctx context.Context, host string) (net.Conn, error)
 
 	pkgsync.Closer
 }
@@ -35,11 +41,15 @@ var _ peer.Dialer = (*Dialer)(nil)
 // timeouts may still apply even when no timeout is selected. The network string
 // controls the type of connection that the dialer can dial.
 func NewDialer(network string, defaultTimeout time.Duration) *Dialer {
-	return &Dialer{
-		peers:   make(map[peer.Address]string),
+	d := &Dialer{
+		peers:   peer.NewAddressBook(),
 		dialer:  net.Dialer{Timeout: defaultTimeout},
 		network: network,
 	}
+	d.dial = func(ctx context.Context, host string) (net.Conn, error) {
+		return d.dialer.DialContext(ctx, d.network, host)
+	}
+	return d
 }
 
 // NewTCPDialer is a short-hand version of NewDialer for creating TCP dialers.
@@ -53,11 +63,24 @@ func NewUnixDialer(defaultTimeout time.Duration) *Dialer {
 }
 
 func (d *Dialer) get(addr peer.Address) (string, bool) {
-	d.mutex.RLock()
-	defer d.mutex.RUnlock()
+	return d.peers.Lookup(addr)
+}
+
+// resolve returns the registered endpoint of the peer or, if it is not
+// registered, asks the resolver.
+func (d *Dialer) resolve(ctx context.Context, addr peer.Address) (string, error) {
+	if host, ok := d.get(addr); ok {
+		return host, nil
+	}
 
-	host, ok := d.peers[addr]
-	return host, ok
+	d.mutex.RLock()
+	resolver := d.resolver
+	d.mutex.RUnlock()
+	if resolver == nil {
+		return "", errors.New("peer not found")
+	}
+	host, err := resolver.Resolve(ctx, addr)
+	return host, errors.WithMessage(err, "resolving peer")
 }
 
 // Dial implements peer.Dialer.Dial().
@@ -65,11 +88,6 @@ func (d *Dialer) Dial(ctx context.Context, addr peer.Address) (peer.Conn, error)
 	done := make(chan struct{})
 	defer close(done)
 
-	host, ok := d.get(addr)
-	if !ok {
-		return nil, errors.New("peer not found")
-	}
-
 	// To combine the provided context with the Dialer's Closer as specified by
 	// the Dialer interface, we have to use some goroutine trickery.
 	wrappedCtx, cancel := context.WithCancel(ctx)
@@ -82,18 +100,43 @@ func (d *Dialer) Dial(ctx context.Context, addr peer.Address) (peer.Conn, error)
 		}
 	}()
 
-	conn, err := d.dialer.DialContext(wrappedCtx, d.network, host)
+	host, err := d.resolve(wrappedCtx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := d.dial(wrappedCtx, host)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to dial peer")
 	}
 
-	return peer.NewIoConn(conn), nil
+	d.mutex.RLock()
+	security := d.security
+	d.mutex.RUnlock()
+	pconn, err := secureClient(wrappedCtx, security, conn, addr)
+	return pconn, errors.WithMessage(err, "securing connection")
 }
 
 // Register registers a network address for a peer address.
 func (d *Dialer) Register(addr peer.Address, address string) {
+	d.peers.Register(addr, address)
+}
+
+// SetResolver sets the resolver of the network addresses of peers that are
+// not registered. The resolved addresses must have the format of registered
+// addresses, e.g., WebSocket URLs for a WebSocket dialer.
+func (d *Dialer) SetResolver(r peer.Resolver) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.resolver = r
+}
+
+// SetSecurity sets the Security that secures all dialed connections. The
+// listeners of the peers have to use the same Security.
+func (d *Dialer) SetSecurity(s peer.Security) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 
-	d.peers[addr] = address
+	d.security = s
 }
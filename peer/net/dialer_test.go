@@ -15,6 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/peer"
 	"perun.network/go-perun/pkg/test"
 	"perun.network/go-perun/wire/msg"
 )
@@ -104,6 +105,27 @@ func TestDialer_Dial(t *testing.T) {
 		})
 	})
 
+	t.Run("resolved address", func(t *testing.T) {
+		resolvedAddr := wallet.NewRandomAddress(rng)
+		book := peer.NewAddressBook()
+		book.Register(resolvedAddr, lhost)
+		d.SetResolver(book)
+		defer d.SetResolver(nil)
+
+		go func() {
+			conn, err := l.Accept()
+			if assert.NoError(t, err) {
+				conn.Close()
+			}
+		}()
+		test.AssertTerminates(t, timeout, func() {
+			conn, err := d.Dial(context.Background(), resolvedAddr)
+			assert.NoError(t, err)
+			require.NotNil(t, conn)
+			conn.Close()
+		})
+	})
+
 	t.Run("unknown address", func(t *testing.T) {
 		test.AssertTerminates(t, timeout, func() {
 			unkownAddr := wallet.NewRandomAddress(rng)
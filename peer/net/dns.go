@@ -0,0 +1,74 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/peer"
+)
+
+const (
+	// dnsRecordPrefix marks the TXT records that contain Perun endpoints.
+	dnsRecordPrefix = "perun="
+	// maxDNSLabel is the maximal length of a DNS label.
+	maxDNSLabel = 63
+)
+
+// DNSResolver is a peer.Resolver that looks up the endpoints of peers in DNS
+// TXT records. The record of a peer is stored at the hex-encoded bytes of its
+// address below the domain of the resolver, e.g.,
+// "0123abcd.peers.example.com", and has the form "perun=<endpoint>". Encoded
+// addresses that are longer than a DNS label are split into labels of 63
+// characters. Other TXT records at the same name are ignored.
+type DNSResolver struct {
+	domain    string
+	lookupTXT func(ctx context.Context, name string) ([]string, error)
+}
+
+var _ peer.Resolver = (*DNSResolver)(nil)
+
+// NewDNSResolver creates a DNSResolver for the given domain that uses the
+// default resolver of the net package.
+func NewDNSResolver(domain string) *DNSResolver {
+	return &DNSResolver{
+		domain:    strings.TrimSuffix(domain, "."),
+		lookupTXT: net.DefaultResolver.LookupTXT,
+	}
+}
+
+// Resolve looks up the endpoint of the peer.
+func (r *DNSResolver) Resolve(ctx context.Context, addr peer.Address) (string, error) {
+	name := r.name(addr)
+	records, err := r.lookupTXT(ctx, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "looking up %s", name)
+	}
+	for _, record := range records {
+		if strings.HasPrefix(record, dnsRecordPrefix) {
+			return strings.TrimPrefix(record, dnsRecordPrefix), nil
+		}
+	}
+	return "", errors.Errorf("no Perun endpoint at %s", name)
+}
+
+// name returns the DNS name of the record of the peer. Since DNS labels are
+// limited to 63 characters, longer hex-encoded addresses are split into
+// several labels.
+func (r *DNSResolver) name(addr peer.Address) string {
+	enc := hex.EncodeToString(addr.Bytes())
+	var labels []string
+	for len(enc) > maxDNSLabel {
+		labels = append(labels, enc[:maxDNSLabel])
+		enc = enc[maxDNSLabel:]
+	}
+	return strings.Join(append(labels, enc, r.domain), ".")
+}
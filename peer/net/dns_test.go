@@ -0,0 +1,56 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"encoding/hex"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/sim/wallet"
+)
+
+func TestDNSResolver(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xd25))
+	addr, unknown := wallet.NewRandomAddress(rng), wallet.NewRandomAddress(rng)
+	r := NewDNSResolver("peers.example.com.")
+	name := r.name(addr)
+	records := map[string][]string{
+		name: {"v=spf1 -all", "perun=ws://example.com:8080/perun"},
+	}
+	r.lookupTXT = func(_ context.Context, name string) ([]string, error) {
+		if rs, ok := records[name]; ok {
+			return rs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	// The sim addresses are longer than a DNS label.
+	require.True(t, strings.HasSuffix(name, ".peers.example.com"), "trailing dot is trimmed")
+	labels := strings.Split(strings.TrimSuffix(name, ".peers.example.com"), ".")
+	require.True(t, len(labels) > 1)
+	assert.Equal(t, hex.EncodeToString(addr.Bytes()), strings.Join(labels, ""))
+	for _, label := range labels {
+		assert.True(t, len(label) <= maxDNSLabel)
+	}
+
+	endpoint, err := r.Resolve(context.Background(), addr)
+	require.NoError(t, err)
+	assert.Equal(t, "ws://example.com:8080/perun", endpoint)
+
+	_, err = r.Resolve(context.Background(), unknown)
+	assert.Error(t, err)
+
+	records[r.name(unknown)] = []string{"unrelated"}
+	_, err = r.Resolve(context.Background(), unknown)
+	assert.Error(t, err, "no Perun record")
+}
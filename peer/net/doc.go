@@ -4,5 +4,8 @@
 // the LICENSE file.
 
 // Package net contains a Dialer and Listener implementation for connecting
-// peers over TCP, UDP, and Unix sockets.
+// peers over TCP, UDP, and Unix sockets, as well as over WebSockets. The
+// endpoints of peers can be registered at the Dialer or resolved, e.g., via
+// DNS. Peers behind NATs can connect through a RelayServer. Connections can
+// be encrypted and authenticated with a NoiseSecurity.
 package net // import "perun.network/go-perun/peer/net"
@@ -7,15 +7,20 @@ package net
 
 import (
 	"net"
+	"sync"
 
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/peer"
 )
 
-// Listener is a TCP implementation of the peer.Listener interface.
+// Listener is a TCP implementation of the peer.Listener interface. Accepted
+// connections are secured by the Security set via SetSecurity(), if any.
 type Listener struct {
 	net.Listener
+
+	mutex    sync.RWMutex  // Protects security.
+	security peer.Security // Secures accepted connections, if set.
 }
 
 var _ peer.Listener = (*Listener)(nil)
@@ -48,5 +53,16 @@ func (l *Listener) Accept() (peer.Conn, error) {
 		return nil, errors.Wrap(err, "accept failed")
 	}
 
-	return peer.NewIoConn(conn), nil
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return secureServer(l.security, conn), nil
+}
+
+// SetSecurity sets the Security that secures all accepted connections. The
+// dialers of the peers have to use the same Security.
+func (l *Listener) SetSecurity(s peer.Security) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.security = s
 }
@@ -0,0 +1,154 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+)
+
+// The relay protocol lets two peers that are both behind NATs connect through
+// a public RelayServer. Every relayed connection is a separate TCP connection
+// of each peer to the relay, which the relay splices together after the
+// following exchange of frames:
+//
+// A RelayListener opens a control connection and sends relayListen with its
+// Perun address. The relay answers with relayChallenge, a random nonce, which
+// the listener signs with its Perun identity in relayProof, so that nobody
+// else can listen for its address. The relay confirms with relayOK.
+//
+// A RelayDialer opens a connection and sends relayDial with the address of
+// the listener. The relay sends relayIncoming with a random session ID over the
+// control connection of the listener, who opens a new connection and sends
+// relayAccept with the session ID. The relay sends relayOK on both
+// connections and splices them.
+//
+// The peers then run a handshake over the spliced connection with ephemeral
+// X25519 keys, which they sign together with both addresses with their Perun
+// identities, and encrypt all wire messages end-to-end, see secureConn. So the
+// relay is untrusted: It can only drop connections, but cannot read or forge
+// messages.
+const (
+	relayListen byte = iota + 1
+	relayChallenge
+	relayProof
+	relayDial
+	relayIncoming
+	relayAccept
+	relayOK
+	relayError
+	relayHello
+	relayAuth
+)
+
+const (
+	// maxRelayFrame is the maximal payload size of a relay protocol frame.
+	maxRelayFrame = 1 << 12
+	// relayTimeout is the timeout of relay protocol exchanges that are not
+	// bound by a context, e.g., of the relay server.
+	relayTimeout = 10 * time.Second
+	// relaySessionLen is the length of session IDs.
+	relaySessionLen = 16
+	// relayNonceLen is the length of the challenge nonces.
+	relayNonceLen = 32
+)
+
+// relayListenPrefix is prepended to the challenge nonce that a listener signs,
+// so that the signature cannot be used for anything else.
+var relayListenPrefix = []byte("perun relay listen")
+
+// writeFrame writes a relay protocol frame with the given operation and
+// payload.
+func writeFrame(w io.Writer, op byte, payload []byte) error {
+	if len(payload) > maxRelayFrame {
+		return errors.Errorf("relay frame payload too large: %d", len(payload))
+	}
+	frame := make([]byte, 3, 3+len(payload))
+	frame[0] = op
+	binary.BigEndian.PutUint16(frame[1:], uint16(len(payload)))
+	_, err := w.Write(append(frame, payload...))
+	return errors.Wrap(err, "writing relay frame")
+}
+
+// readFrame reads a relay protocol frame.
+func readFrame(r io.Reader) (op byte, payload []byte, err error) {
+	var header [3]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, errors.Wrap(err, "reading relay frame")
+	}
+	n := binary.BigEndian.Uint16(header[1:])
+	if n > maxRelayFrame {
+		return 0, nil, errors.Errorf("relay frame payload too large: %d", n)
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, errors.Wrap(err, "reading relay frame payload")
+	}
+	return header[0], payload, nil
+}
+
+// expectFrame reads a frame and checks that it has the expected operation.
+// A relayError frame is returned as error.
+func expectFrame(r io.Reader, expected byte) ([]byte, error) {
+	op, payload, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	} else if op == relayError {
+		return nil, errors.Errorf("relay error: %s", payload)
+	} else if op != expected {
+		return nil, errors.Errorf("unexpected relay frame %d, expected %d", op, expected)
+	}
+	return payload, nil
+}
+
+// encodeAddr encodes a Perun address for a relay frame.
+func encodeAddr(addr peer.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	err := addr.Encode(&buf)
+	return buf.Bytes(), errors.WithMessage(err, "encoding address")
+}
+
+// decodeAddr decodes a Perun address from a relay frame payload.
+func decodeAddr(payload []byte) (peer.Address, error) {
+	addr, err := wallet.DecodeAddress(bytes.NewReader(payload))
+	return addr, errors.WithMessage(err, "decoding address")
+}
+
+// listenChallenge returns the data that a listener signs to prove that it
+// owns its address.
+func listenChallenge(nonce []byte) []byte {
+	return append(append([]byte(nil), relayListenPrefix...), nonce...)
+}
+
+// withContext sets the deadline of ctx on conn and closes conn if ctx is done
+// before the returned function is called. The returned function resets the
+// deadline.
+func withContext(ctx context.Context, conn net.Conn) (done func()) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		conn.SetDeadline(time.Time{})
+	}
+}
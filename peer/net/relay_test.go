@@ -0,0 +1,250 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wire/msg"
+)
+
+const relayTestTimeout = time.Second
+
+func TestRelay(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7e1a))
+	alice, bob := wallet.NewRandomAccount(rng), wallet.NewRandomAccount(rng)
+	server := newTestRelayServer(t)
+	defer server.Close()
+	relay := server.Addr().String()
+
+	l, err := NewRelayListener(bob, relay, relayTestTimeout)
+	require.NoError(t, err)
+	defer l.Close()
+	d := NewRelayDialer(alice, relay, relayTestTimeout)
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), relayTestTimeout)
+	defer cancel()
+	accepted := make(chan peer.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		assert.NoError(t, err)
+		accepted <- conn
+	}()
+	dialed, err := d.Dial(ctx, bob.Address())
+	require.NoError(t, err)
+	defer dialed.Close()
+	var bobConn peer.Conn
+	select {
+	case bobConn = <-accepted:
+		require.NotNil(t, bobConn)
+		defer bobConn.Close()
+	case <-ctx.Done():
+		t.Fatal("relayed connection not accepted")
+	}
+
+	// Messages are exchanged in both directions.
+	ping := msg.NewPingMsg()
+	require.NoError(t, dialed.Send(ping))
+	m, err := bobConn.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, ping, m)
+	pong := msg.NewPongMsg()
+	require.NoError(t, bobConn.Send(pong))
+	m, err = dialed.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, pong, m)
+
+	t.Run("not listening", func(t *testing.T) {
+		_, err := d.Dial(ctx, wallet.NewRandomAddress(rng))
+		assert.Error(t, err)
+	})
+
+	t.Run("closed listener", func(t *testing.T) {
+		require.NoError(t, l.Close())
+		_, err := l.Accept()
+		assert.Error(t, err)
+	})
+}
+
+func TestRelayListener_Proof(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9f00f))
+	bob := wallet.NewRandomAccount(rng)
+	server := newTestRelayServer(t)
+	defer server.Close()
+
+	// Mallory listens for Bob's address without his key.
+	acc := wallet.NewRandomAccount(rng)
+	mallory := &impersonator{Account: &acc, addr: bob.Address()}
+	_, err := NewRelayListener(mallory, server.Addr().String(), relayTestTimeout)
+	assert.Error(t, err)
+}
+
+func TestSecureHandshake(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ec))
+	alice, bob := wallet.NewRandomAccount(rng), wallet.NewRandomAccount(rng)
+
+	t.Run("encrypted", func(t *testing.T) {
+		a, b := net.Pipe()
+		tap := &tapConn{Conn: a}
+		aliceConn, bobConn := handshakePair(t, tap, b, alice, bob, bob.Address())
+		defer aliceConn.Close()
+		defer bobConn.Close()
+
+		secret := []byte("a message that only bob may read")
+		go func() {
+			_, err := aliceConn.Write(secret)
+			assert.NoError(t, err)
+		}()
+		buf := make([]byte, len(secret))
+		_, err := bobConn.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, secret, buf)
+		assert.False(t, bytes.Contains(tap.written(), secret), "relay must not see plaintext")
+	})
+
+	t.Run("wrong peer", func(t *testing.T) {
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+		errs := make(chan error, 1)
+		go func() {
+			_, _, err := secureHandshake(b, bob, false, nil)
+			errs <- err
+		}()
+		_, _, err := secureHandshake(a, alice, true, wallet.NewRandomAddress(rng))
+		assert.Error(t, err)
+		a.Close()
+		<-errs
+	})
+
+	t.Run("misbinding", func(t *testing.T) {
+		// Mallory relays the ephemeral keys between Alice and Bob, but tells
+		// Bob that the initiator is Mallory, so that Bob would attribute
+		// Alice's data to Mallory.
+		mallory := wallet.NewRandomAccount(rng)
+		a, m1 := net.Pipe()
+		m2, b := net.Pipe()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			secureHandshake(b, bob, false, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			hello, err := expectFrame(m1, relayHello)
+			if err != nil {
+				return
+			}
+			addr, err := encodeAddr(mallory.Address())
+			require.NoError(t, err)
+			if writeFrame(m2, relayHello, append(hello[:32:32], addr...)) != nil {
+				return
+			}
+			for _, op := range []byte{relayHello, relayAuth} {
+				payload, err := expectFrame(m2, op)
+				if err != nil || writeFrame(m1, op, payload) != nil {
+					return
+				}
+			}
+		}()
+
+		_, _, err := secureHandshake(a, alice, true, bob.Address())
+		// Bob's signature names Mallory as initiator.
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid handshake signature")
+		for _, c := range []net.Conn{a, m1, m2, b} {
+			c.Close()
+		}
+		wg.Wait()
+	})
+
+	t.Run("tampered record", func(t *testing.T) {
+		a, b := net.Pipe()
+		aliceConn, bobConn := handshakePair(t, a, b, alice, bob, bob.Address())
+		defer bobConn.Close()
+
+		// Alice's record is modified in transit.
+		go func() {
+			record := make([]byte, 4, 64)
+			record = aliceConn.send.Seal(record, nonce(aliceConn.sendNonce), []byte("hello"), nil)
+			record[len(record)-1] ^= 1
+			record[3] = byte(len(record) - 4)
+			aliceConn.Conn.Write(record)
+		}()
+		_, err := bobConn.Read(make([]byte, 5))
+		assert.Error(t, err)
+	})
+}
+
+// newTestRelayServer starts a relay server on a random local port.
+func newTestRelayServer(t *testing.T) *RelayServer {
+	server, err := NewRelayServer("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { assert.NoError(t, server.Serve()) }()
+	return server
+}
+
+// handshakePair runs the handshake of alice and bob on both ends of a pipe.
+func handshakePair(t *testing.T, a, b net.Conn, alice, bob peer.Identity, expected peer.Address) (*secureConn, *secureConn) {
+	var wg sync.WaitGroup
+	var bobConn *secureConn
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		var err error
+		var addr peer.Address
+		bobConn, addr, err = secureHandshake(b, bob, false, nil)
+		assert.NoError(t, err)
+		assert.True(t, addr.Equals(alice.Address()))
+	}()
+	aliceConn, addr, err := secureHandshake(a, alice, true, expected)
+	require.NoError(t, err)
+	assert.True(t, addr.Equals(bob.Address()))
+	wg.Wait()
+	require.NotNil(t, bobConn)
+	return aliceConn, bobConn
+}
+
+// impersonator claims another address than that of its account.
+type impersonator struct {
+	*wallet.Account
+	addr peer.Address
+}
+
+func (i *impersonator) Address() peer.Address { return i.addr }
+
+// tapConn records all data that is written to the connection.
+type tapConn struct {
+	net.Conn
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (c *tapConn) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	c.buf.Write(p)
+	c.mutex.Unlock()
+	return c.Conn.Write(p)
+}
+
+func (c *tapConn) written() []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]byte(nil), c.buf.Bytes()...)
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	pkgsync "perun.network/go-perun/pkg/sync"
+)
+
+// RelayDialer dials peers through a RelayServer. The connections are
+// end-to-end encrypted and the dialed peer has to prove that it owns the
+// dialed address.
+type RelayDialer struct {
+	id     peer.Identity
+	relay  string     // Address of the relay server.
+	dialer net.Dialer // Used to dial the relay server.
+
+	pkgsync.Closer
+}
+
+var _ peer.Dialer = (*RelayDialer)(nil)
+
+// NewRelayDialer creates a dialer that connects to peers via the relay server
+// at the given TCP address. The identity is used for the end-to-end
+// encryption. The default timeout works like that of NewDialer.
+//
+// If the identity is nil, NewRelayDialer panics.
+func NewRelayDialer(id peer.Identity, relay string, defaultTimeout time.Duration) *RelayDialer {
+	if id == nil {
+		log.Panic("identity must not be nil")
+	}
+	return &RelayDialer{id: id, relay: relay, dialer: net.Dialer{Timeout: defaultTimeout}}
+}
+
+// Dial implements peer.Dialer.Dial().
+func (d *RelayDialer) Dial(ctx context.Context, addr peer.Address) (peer.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-d.Closed():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.relay)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial relay")
+	}
+	done := withContext(ctx, conn)
+	enc, err := encodeAddr(addr)
+	if err == nil {
+		err = writeFrame(conn, relayDial, enc)
+	}
+	if err == nil {
+		_, err = expectFrame(conn, relayOK)
+	}
+	var sconn *secureConn
+	if err == nil {
+		sconn, _, err = secureHandshake(conn, d.id, true, addr)
+	}
+	done()
+	if err != nil {
+		conn.Close()
+		return nil, errors.WithMessage(err, "relaying connection")
+	}
+	return peer.NewAuthenticatedConn(peer.NewIoConn(sconn), addr), nil
+}
+
+// RelayListener accepts connections from RelayDialers through a RelayServer.
+// It keeps a control connection to the relay, over which it is notified of
+// incoming connections.
+type RelayListener struct {
+	id      peer.Identity
+	relay   string
+	dialer  net.Dialer
+	control net.Conn
+	conns   chan peer.Conn // Accepted connections.
+
+	pkgsync.Closer
+}
+
+var _ peer.Listener = (*RelayListener)(nil)
+
+// NewRelayListener registers the identity at the relay server at the given
+// TCP address and accepts the connections that are relayed to it. The timeout
+// is used for the registration and for the setup of every connection.
+//
+// If the identity is nil or the timeout not positive, NewRelayListener panics.
+func NewRelayListener(id peer.Identity, relay string, timeout time.Duration) (*RelayListener, error) {
+	if id == nil {
+		log.Panic("identity must not be nil")
+	} else if timeout <= 0 {
+		log.Panic("timeout must be positive")
+	}
+	l := &RelayListener{
+		id:     id,
+		relay:  relay,
+		dialer: net.Dialer{Timeout: timeout},
+		conns:  make(chan peer.Conn),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	control, err := l.dialer.DialContext(ctx, "tcp", relay)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial relay")
+	}
+	done := withContext(ctx, control)
+	err = l.register(control)
+	done()
+	if err != nil {
+		control.Close()
+		return nil, errors.WithMessage(err, "registering at relay")
+	}
+
+	l.control = control
+	l.OnClose(func() { control.Close() })
+	go l.serve()
+	return l, nil
+}
+
+// register proves the ownership of the listener's address to the relay.
+func (l *RelayListener) register(conn net.Conn) error {
+	enc, err := encodeAddr(l.id.Address())
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(conn, relayListen, enc); err != nil {
+		return err
+	}
+	nonce, err := expectFrame(conn, relayChallenge)
+	if err != nil {
+		return err
+	}
+	sig, err := l.id.SignData(listenChallenge(nonce))
+	if err != nil {
+		return errors.WithMessage(err, "signing challenge")
+	}
+	if err := writeFrame(conn, relayProof, sig); err != nil {
+		return err
+	}
+	_, err = expectFrame(conn, relayOK)
+	return err
+}
+
+// serve accepts the incoming connections that the relay announces on the
+// control connection. If the control connection fails, the listener is
+// closed.
+func (l *RelayListener) serve() {
+	defer l.Close()
+	for {
+		session, err := expectFrame(l.control, relayIncoming)
+		if err != nil {
+			if !l.IsClosed() {
+				log.Errorf("relay control connection to '%s' failed: %v", l.relay, err)
+			}
+			return
+		}
+		go func() {
+			conn, err := l.accept(session)
+			if err != nil {
+				log.Debugf("accepting relayed connection: %v", err)
+				return
+			}
+			select {
+			case l.conns <- conn:
+			case <-l.Closed():
+				conn.Close()
+			}
+		}()
+	}
+}
+
+// accept opens the connection of the session to the relay and runs the
+// end-to-end handshake.
+func (l *RelayListener) accept(session []byte) (peer.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.dialer.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-l.Closed():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	conn, err := l.dialer.DialContext(ctx, "tcp", l.relay)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to dial relay")
+	}
+	done := withContext(ctx, conn)
+	err = writeFrame(conn, relayAccept, session)
+	if err == nil {
+		_, err = expectFrame(conn, relayOK)
+	}
+	var sconn *secureConn
+	var addr peer.Address
+	if err == nil {
+		sconn, addr, err = secureHandshake(conn, l.id, false, nil)
+	}
+	done()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return peer.NewAuthenticatedConn(peer.NewIoConn(sconn), addr), nil
+}
+
+// Accept implements peer.Listener.Accept().
+func (l *RelayListener) Accept() (peer.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.Closed():
+		return nil, errors.New("accept failed: listener closed")
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"crypto/rand"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	pkgsync "perun.network/go-perun/pkg/sync"
+	"perun.network/go-perun/wallet"
+)
+
+// RelayServer is a public relay that connects RelayDialers to RelayListeners,
+// see the relay protocol. It only forwards the end-to-end encrypted data of
+// the peers.
+type RelayServer struct {
+	listener net.Listener
+
+	mutex     sync.Mutex
+	listeners map[string]*relayControl // control connections by address
+	sessions  map[string]chan net.Conn // pending dials by session ID
+	conns     map[net.Conn]struct{}    // all open connections
+
+	pkgsync.Closer
+}
+
+// relayControl is the control connection of a RelayListener.
+type relayControl struct {
+	mutex sync.Mutex // Protects writes to conn.
+	conn  net.Conn
+}
+
+// NewRelayServer creates a relay server that listens on the given address. It
+// has to be started with Serve.
+func NewRelayServer(network string, address string) (*RelayServer, error) {
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"failed to create listener for '%s'", address)
+	}
+
+	s := &RelayServer{
+		listener:  l,
+		listeners: make(map[string]*relayControl),
+		sessions:  make(map[string]chan net.Conn),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	s.OnClose(func() {
+		s.listener.Close()
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+	})
+	return s, nil
+}
+
+// Addr returns the address that the relay server listens on.
+func (s *RelayServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve accepts connections until the server is closed. It returns nil if
+// the server was closed and the accept error otherwise.
+func (s *RelayServer) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if s.IsClosed() {
+				return nil
+			}
+			return errors.Wrap(err, "accepting relay connection")
+		}
+		if !s.track(conn) {
+			return nil
+		}
+		go func() {
+			if err := s.handle(conn); err != nil {
+				log.Debugf("relay connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// track adds conn to the open connections. If the server is closed, it
+// closes conn and returns false.
+func (s *RelayServer) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.IsClosed() {
+		conn.Close()
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// release closes conn and removes it from the open connections.
+func (s *RelayServer) release(conn net.Conn) {
+	conn.Close()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+}
+
+// handle handles the first frame of a new connection. Accepted connections
+// are released by the dialing connection.
+func (s *RelayServer) handle(conn net.Conn) error {
+	conn.SetDeadline(time.Now().Add(relayTimeout))
+	op, payload, err := readFrame(conn)
+	if err != nil {
+		s.release(conn)
+		return err
+	}
+
+	switch op {
+	case relayListen:
+		defer s.release(conn)
+		return s.handleListen(conn, payload)
+	case relayDial:
+		defer s.release(conn)
+		return s.handleDial(conn, payload)
+	case relayAccept:
+		return s.handleAccept(conn, payload)
+	default:
+		defer s.release(conn)
+		writeFrame(conn, relayError, []byte("unexpected frame"))
+		return errors.Errorf("unexpected relay frame %d", op)
+	}
+}
+
+// handleListen verifies that the listener owns the address and keeps its
+// control connection until it is closed.
+func (s *RelayServer) handleListen(conn net.Conn, payload []byte) error {
+	addr, err := decodeAddr(payload)
+	if err != nil {
+		writeFrame(conn, relayError, []byte("invalid address"))
+		return err
+	}
+	nonce := make([]byte, relayNonceLen)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.Wrap(err, "generating challenge")
+	}
+	if err := writeFrame(conn, relayChallenge, nonce); err != nil {
+		return err
+	}
+	sig, err := expectFrame(conn, relayProof)
+	if err != nil {
+		return err
+	}
+	if ok, err := wallet.VerifySignature(listenChallenge(nonce), sig, addr); err != nil || !ok {
+		writeFrame(conn, relayError, []byte("invalid proof"))
+		return errors.Errorf("invalid listen proof of %v", addr)
+	}
+
+	// The newest control connection of an address replaces older ones.
+	key := string(addr.Bytes())
+	control := &relayControl{conn: conn}
+	s.mutex.Lock()
+	if old, ok := s.listeners[key]; ok {
+		old.conn.Close()
+	}
+	s.listeners[key] = control
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if s.listeners[key] == control {
+			delete(s.listeners, key)
+		}
+	}()
+
+	conn.SetDeadline(time.Time{})
+	if err := control.write(relayOK, nil); err != nil {
+		return err
+	}
+	// Listeners do not send anything else, so this returns when the control
+	// connection is closed.
+	_, _, err = readFrame(conn)
+	return err
+}
+
+// handleDial asks the listener of the address to accept a new connection and
+// splices it with the dialing connection.
+func (s *RelayServer) handleDial(conn net.Conn, payload []byte) error {
+	addr, err := decodeAddr(payload)
+	if err != nil {
+		writeFrame(conn, relayError, []byte("invalid address"))
+		return err
+	}
+	s.mutex.Lock()
+	control, ok := s.listeners[string(addr.Bytes())]
+	s.mutex.Unlock()
+	if !ok {
+		writeFrame(conn, relayError, []byte("peer not listening"))
+		return errors.Errorf("peer %v not listening", addr)
+	}
+
+	session := make([]byte, relaySessionLen)
+	if _, err := io.ReadFull(rand.Reader, session); err != nil {
+		return errors.Wrap(err, "generating session ID")
+	}
+	accepted := make(chan net.Conn, 1)
+	s.mutex.Lock()
+	s.sessions[string(session)] = accepted
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.sessions, string(session))
+	}()
+
+	if err := control.write(relayIncoming, session); err != nil {
+		writeFrame(conn, relayError, []byte("peer not reachable"))
+		return err
+	}
+	var peerConn net.Conn
+	select {
+	case peerConn = <-accepted:
+	case <-time.After(relayTimeout):
+		writeFrame(conn, relayError, []byte("peer did not accept"))
+		return errors.Errorf("peer %v did not accept", addr)
+	case <-s.Closed():
+		return errors.New("relay server closed")
+	}
+	defer s.release(peerConn)
+
+	if err := writeFrame(conn, relayOK, nil); err != nil {
+		return err
+	}
+	if err := writeFrame(peerConn, relayOK, nil); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+	peerConn.SetDeadline(time.Time{})
+	splice(conn, peerConn)
+	return nil
+}
+
+// handleAccept hands the accepting connection of a listener to the pending
+// dial of the session.
+func (s *RelayServer) handleAccept(conn net.Conn, session []byte) error {
+	s.mutex.Lock()
+	accepted, ok := s.sessions[string(session)]
+	delete(s.sessions, string(session))
+	s.mutex.Unlock()
+	if !ok {
+		writeFrame(conn, relayError, []byte("unknown session"))
+		s.release(conn)
+		return errors.New("unknown session")
+	}
+	accepted <- conn // buffered and only written once
+	return nil
+}
+
+// write writes a frame to the control connection.
+func (c *relayControl) write(op byte, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return writeFrame(c.conn, op, payload)
+}
+
+// splice copies data between both connections until one of them is closed.
+func splice(a, b net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+		b.Close()
+		close(done)
+	}()
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+	<-done
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wire"
+)
+
+const (
+	// maxRecord is the maximal plaintext size of an encrypted record.
+	maxRecord = 1 << 14
+	// relayHandshakeInfo is the HKDF info of the record keys.
+	relayHandshakeInfo = "perun relay keys"
+)
+
+// relayAuthPrefix is prepended to the handshake transcript that the peers
+// sign, so that the signature cannot be used for anything else.
+var relayAuthPrefix = []byte("perun relay handshake")
+
+// secureConn encrypts and authenticates all data on the underlying connection
+// with ChaCha20-Poly1305. Each direction has its own key and a counter nonce,
+// so that records cannot be replayed, reordered or reflected.
+type secureConn struct {
+	net.Conn
+
+	rmu       sync.Mutex
+	recv      cipher.AEAD
+	recvNonce uint64
+	rbuf      []byte // unread plaintext of the last record
+
+	wmu       sync.Mutex
+	send      cipher.AEAD
+	sendNonce uint64
+}
+
+// secureHandshake runs the end-to-end handshake on conn. Both peers send
+// ephemeral X25519 keys with their addresses and sign the keys and both
+// addresses with their Perun identities. Since each signature also names the
+// peer that the signer sees, a man in the middle cannot make a peer believe
+// that it talks to someone else, e.g., by relaying the keys and replacing the
+// address and signature of the initiator with its own. The initiator checks
+// that the responder has the expected address. It returns the encrypted
+// connection and the address of the peer.
+func secureHandshake(conn net.Conn, id peer.Identity, initiator bool, expected peer.Address) (*secureConn, peer.Address, error) {
+	var priv, pub [32]byte
+	if _, err := io.ReadFull(rand.Reader, priv[:]); err != nil {
+		return nil, nil, errors.Wrap(err, "generating ephemeral key")
+	}
+	curve25519.ScalarBaseMult(&pub, &priv)
+	addr, err := encodeAddr(id.Address())
+	if err != nil {
+		return nil, nil, err
+	}
+	hello := append(pub[:], addr...)
+
+	// The initiator sends its hello first and the responder authenticates
+	// first, so that the peers never write at the same time.
+	var peerPub [32]byte
+	var transcript []byte // hellos of the initiator and responder
+	var peerAddr peer.Address
+	if initiator {
+		if err := writeFrame(conn, relayHello, hello); err != nil {
+			return nil, nil, err
+		}
+		peerHello, err := recvHello(conn, &peerPub, &peerAddr)
+		if err != nil {
+			return nil, nil, err
+		} else if expected != nil && !peerAddr.Equals(expected) {
+			return nil, nil, errors.Errorf("relayed peer has address %v, expected %v", peerAddr, expected)
+		}
+		transcript = handshakeTranscript(hello, peerHello)
+		if err := recvAuth(conn, false, transcript, peerAddr); err != nil {
+			return nil, nil, err
+		}
+		if err := sendAuth(conn, id, true, transcript); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		peerHello, err := recvHello(conn, &peerPub, &peerAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := writeFrame(conn, relayHello, hello); err != nil {
+			return nil, nil, err
+		}
+		transcript = handshakeTranscript(peerHello, hello)
+		if err := sendAuth(conn, id, false, transcript); err != nil {
+			return nil, nil, err
+		}
+		if err := recvAuth(conn, true, transcript, peerAddr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var shared [32]byte
+	curve25519.ScalarMult(&shared, &priv, &peerPub)
+	if subtle.ConstantTimeCompare(shared[:], make([]byte, len(shared))) == 1 {
+		return nil, nil, errors.New("invalid ephemeral key")
+	}
+
+	keys := hkdf.New(sha256.New, shared[:], transcript, []byte(relayHandshakeInfo))
+	var initKey, respKey [chacha20poly1305.KeySize]byte
+	if _, err := io.ReadFull(keys, initKey[:]); err != nil {
+		return nil, nil, errors.Wrap(err, "deriving keys")
+	}
+	if _, err := io.ReadFull(keys, respKey[:]); err != nil {
+		return nil, nil, errors.Wrap(err, "deriving keys")
+	}
+	if !initiator {
+		initKey, respKey = respKey, initKey
+	}
+	send, _ := chacha20poly1305.New(initKey[:]) // only fails for wrong key sizes
+	recv, _ := chacha20poly1305.New(respKey[:])
+	return &secureConn{Conn: conn, send: send, recv: recv}, peerAddr, nil
+}
+
+// recvHello receives the hello of the peer, which is its ephemeral key
+// followed by its encoded address. It returns the payload of the hello.
+func recvHello(conn net.Conn, key *[32]byte, addr *peer.Address) ([]byte, error) {
+	payload, err := expectFrame(conn, relayHello)
+	if err != nil {
+		return nil, err
+	} else if len(payload) <= len(key) {
+		return nil, errors.New("invalid hello length")
+	}
+	copy(key[:], payload)
+	if *addr, err = decodeAddr(payload[len(key):]); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// handshakeTranscript returns the transcript of the handshake, which contains
+// the ephemeral keys and addresses of both peers. The hellos are
+// length-prefixed, since addresses may have different lengths.
+func handshakeTranscript(initHello, respHello []byte) []byte {
+	var transcript []byte
+	for _, hello := range [][]byte{initHello, respHello} {
+		var n [2]byte
+		binary.BigEndian.PutUint16(n[:], uint16(len(hello)))
+		transcript = append(append(transcript, n[:]...), hello...)
+	}
+	return transcript
+}
+
+// authData returns the data that the initiator or responder signs.
+func authData(initiator bool, transcript []byte) []byte {
+	role := byte(0)
+	if initiator {
+		role = 1
+	}
+	data := append(append([]byte(nil), relayAuthPrefix...), role)
+	return append(data, transcript...)
+}
+
+// sendAuth sends the signature of the identity on the transcript.
+func sendAuth(conn net.Conn, id peer.Identity, initiator bool, transcript []byte) error {
+	sig, err := id.SignData(authData(initiator, transcript))
+	if err != nil {
+		return errors.WithMessage(err, "signing handshake")
+	}
+	var buf bytes.Buffer
+	if err := wire.Encode(&buf, sig); err != nil {
+		return errors.WithMessage(err, "encoding handshake authentication")
+	}
+	return writeFrame(conn, relayAuth, buf.Bytes())
+}
+
+// recvAuth receives the signature of the peer on the transcript and verifies
+// it against the address from the peer's hello.
+func recvAuth(conn net.Conn, initiator bool, transcript []byte, addr peer.Address) error {
+	payload, err := expectFrame(conn, relayAuth)
+	if err != nil {
+		return err
+	}
+	sig, err := wallet.DecodeSig(bytes.NewReader(payload))
+	if err != nil {
+		return errors.WithMessage(err, "decoding signature")
+	}
+	if ok, err := wallet.VerifySignature(authData(initiator, transcript), sig, addr); err != nil {
+		return errors.WithMessage(err, "verifying handshake signature")
+	} else if !ok {
+		return errors.New("invalid handshake signature")
+	}
+	return nil
+}
+
+// Read reads decrypted data. It fails if a record was tampered with.
+func (c *secureConn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if len(c.rbuf) == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(c.Conn, header[:]); err != nil {
+			return 0, err
+		}
+		n := binary.BigEndian.Uint32(header[:])
+		if n > maxRecord+uint32(c.recv.Overhead()) {
+			return 0, errors.Errorf("record too large: %d", n)
+		}
+		record := make([]byte, n)
+		if _, err := io.ReadFull(c.Conn, record); err != nil {
+			return 0, err
+		}
+		plain, err := c.recv.Open(record[:0], nonce(c.recvNonce), record, nil)
+		if err != nil {
+			return 0, errors.Wrap(err, "decrypting record")
+		}
+		c.recvNonce++
+		c.rbuf = plain
+	}
+	n := copy(p, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return n, nil
+}
+
+// Write encrypts and writes the data in records of at most maxRecord bytes.
+func (c *secureConn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxRecord {
+			chunk = chunk[:maxRecord]
+		}
+		record := make([]byte, 4, 4+len(chunk)+c.send.Overhead())
+		record = c.send.Seal(record, nonce(c.sendNonce), chunk, nil)
+		binary.BigEndian.PutUint32(record, uint32(len(record)-4))
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+		c.sendNonce++
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// nonce returns the record nonce of the given counter.
+func nonce(counter uint64) []byte {
+	n := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(n, counter)
+	return n
+}
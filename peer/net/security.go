@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+)
+
+// securityTimeout is the timeout of the Security handshake of incoming
+// connections.
+const securityTimeout = 10 * time.Second
+
+// NoiseSecurity is a peer.Security that encrypts and authenticates
+// connections like the Noise XX pattern: Both peers exchange ephemeral X25519
+// keys and encrypt all data with ChaCha20-Poly1305 under keys derived from
+// them. Since Perun identities are signing keys and not Diffie-Hellman keys,
+// the peers authenticate by signing the handshake transcript, which contains
+// the ephemeral keys and the addresses of both peers, with their identities
+// instead of mixing in static keys. This is the same handshake
+// that relayed connections use, see secureConn.
+type NoiseSecurity struct {
+	id peer.Identity
+}
+
+var _ peer.Security = (*NoiseSecurity)(nil)
+
+// NewNoiseSecurity creates a NoiseSecurity that authenticates as the given
+// identity.
+//
+// If the identity is nil, NewNoiseSecurity panics.
+func NewNoiseSecurity(id peer.Identity) *NoiseSecurity {
+	if id == nil {
+		log.Panic("identity must not be nil")
+	}
+	return &NoiseSecurity{id: id}
+}
+
+// Client implements peer.Security.Client().
+func (s *NoiseSecurity) Client(ctx context.Context, conn net.Conn, expected peer.Address) (net.Conn, error) {
+	done := withContext(ctx, conn)
+	sconn, _, err := secureHandshake(conn, s.id, true, expected)
+	done()
+	return sconn, errors.WithMessage(err, "secure handshake")
+}
+
+// Server implements peer.Security.Server().
+func (s *NoiseSecurity) Server(ctx context.Context, conn net.Conn) (net.Conn, peer.Address, error) {
+	done := withContext(ctx, conn)
+	sconn, addr, err := secureHandshake(conn, s.id, false, nil)
+	done()
+	return sconn, addr, errors.WithMessage(err, "secure handshake")
+}
+
+// secureClient secures an outgoing connection to addr with the Security, if
+// any.
+func secureClient(ctx context.Context, sec peer.Security, conn net.Conn, addr peer.Address) (peer.Conn, error) {
+	if sec == nil {
+		return peer.NewIoConn(conn), nil
+	}
+	sconn, err := sec.Client(ctx, conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return peer.NewAuthenticatedConn(peer.NewIoConn(sconn), addr), nil
+}
+
+// secureServer secures an incoming connection with the Security, if any.
+// The handshake is run on the first Send or Recv, so that slow peers do not
+// block Accept().
+func secureServer(sec peer.Security, conn net.Conn) peer.Conn {
+	if sec == nil {
+		return peer.NewIoConn(conn)
+	}
+	s := &serverConn{raw: conn, sec: sec}
+	return &serverPeerConn{Conn: peer.NewIoConn(s), s: s}
+}
+
+// serverConn runs the server side of a Security handshake on its first Read
+// or Write, like a tls.Conn.
+type serverConn struct {
+	raw  net.Conn
+	sec  peer.Security
+	once sync.Once
+	conn net.Conn     // Secured connection.
+	addr peer.Address // Authenticated address of the peer.
+	err  error        // Handshake error.
+}
+
+func (c *serverConn) handshake() error {
+	c.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), securityTimeout)
+		defer cancel()
+		if c.conn, c.addr, c.err = c.sec.Server(ctx, c.raw); c.err != nil {
+			c.raw.Close()
+		}
+	})
+	return c.err
+}
+
+func (c *serverConn) Read(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.conn.Read(p)
+}
+
+func (c *serverConn) Write(p []byte) (int, error) {
+	if err := c.handshake(); err != nil {
+		return 0, err
+	}
+	return c.conn.Write(p)
+}
+
+func (c *serverConn) Close() error {
+	return c.raw.Close()
+}
+
+// serverPeerConn is an incoming peer.AuthenticatedConn.
+type serverPeerConn struct {
+	peer.Conn
+	s *serverConn
+}
+
+func (c *serverPeerConn) PeerAddress() peer.Address {
+	if c.s.handshake() != nil {
+		return nil
+	}
+	return c.s.addr
+}
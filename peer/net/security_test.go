@@ -0,0 +1,113 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestNoiseSecurity(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5ec0))
+	alice, bob := wallet.NewRandomAccount(rng), wallet.NewRandomAccount(rng)
+	timeout := time.Second
+	lhost := "127.0.0.1:7358"
+
+	l, err := NewTCPListener(lhost)
+	require.NoError(t, err)
+	defer l.Close()
+	l.SetSecurity(NewNoiseSecurity(bob))
+
+	d := NewTCPDialer(timeout)
+	defer d.Close()
+	d.SetSecurity(NewNoiseSecurity(alice))
+	d.Register(bob.Address(), lhost)
+
+	t.Run("happy", func(t *testing.T) {
+		ping := msg.NewPingMsg()
+		accepted := make(chan error, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				accepted <- err
+				return
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			addr, err := peer.ExchangeAddrs(ctx, bob, conn)
+			if err == nil {
+				assert.True(t, addr.Equals(alice.Address()))
+				assert.True(t, conn.(peer.AuthenticatedConn).PeerAddress().Equals(alice.Address()))
+				err = conn.Send(ping)
+			}
+			accepted <- err
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		conn, err := d.Dial(ctx, bob.Address())
+		require.NoError(t, err)
+		defer conn.Close()
+		addr, err := peer.ExchangeAddrs(ctx, alice, conn)
+		require.NoError(t, err)
+		assert.True(t, addr.Equals(bob.Address()))
+		m, err := conn.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, ping, m)
+		assert.NoError(t, <-accepted)
+	})
+
+	t.Run("wrong peer", func(t *testing.T) {
+		// The listener authenticates as Bob, but Alice expects Carol there.
+		carol := wallet.NewRandomAddress(rng)
+		d.Register(carol, lhost)
+		go func() {
+			if conn, err := l.Accept(); err == nil {
+				conn.Recv()
+				conn.Close()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		conn, err := d.Dial(ctx, carol)
+		assert.Error(t, err)
+		assert.Nil(t, conn)
+	})
+
+	t.Run("plaintext listener", func(t *testing.T) {
+		l.SetSecurity(nil)
+		defer l.SetSecurity(NewNoiseSecurity(bob))
+		go func() {
+			if conn, err := l.Accept(); err == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				peer.ExchangeAddrs(ctx, bob, conn)
+				conn.Close()
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		conn, err := d.Dial(ctx, bob.Address())
+		assert.Error(t, err)
+		assert.Nil(t, conn)
+	})
+}
+
+func TestNewNoiseSecurity(t *testing.T) {
+	assert.Panics(t, func() { NewNoiseSecurity(nil) })
+}
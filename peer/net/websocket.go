@@ -0,0 +1,175 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/websocket"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/peer"
+	pkgsync "perun.network/go-perun/pkg/sync"
+)
+
+// wsOrigin is the origin that the WebSocket dialer sends in its handshakes.
+// The WebSocketListener does not check origins.
+const wsOrigin = "http://localhost/"
+
+// NewWebSocketDialer creates a new dialer for WebSocket connections. Peers
+// have to be registered with their WebSocket URL, including the port, e.g.,
+// "ws://example.com:8080/perun". URLs with the "wss" scheme are dialed over
+// TLS.
+func NewWebSocketDialer(defaultTimeout time.Duration) *Dialer {
+	d := NewDialer("tcp", defaultTimeout)
+	d.dial = func(ctx context.Context, url string) (net.Conn, error) {
+		config, err := websocket.NewConfig(url, wsOrigin)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing WebSocket URL")
+		}
+		conn, err := d.dialer.DialContext(ctx, d.network, config.Location.Host)
+		if err != nil {
+			return nil, err
+		}
+		ws, err := wsHandshake(ctx, conn, config)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return ws, nil
+	}
+	return d
+}
+
+// wsHandshake performs the client side of the WebSocket handshake on conn.
+// The handshake is aborted when the context's deadline passes.
+func wsHandshake(ctx context.Context, conn net.Conn, config *websocket.Config) (*websocket.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, errors.Wrap(err, "setting handshake deadline")
+		}
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if config.Location.Scheme == "wss" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: config.Location.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, errors.Wrap(err, "TLS handshake")
+		}
+		conn = tlsConn
+	}
+
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		return nil, errors.Wrap(err, "WebSocket handshake")
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+// WebSocketListener is a WebSocket implementation of the peer.Listener
+// interface. It serves the WebSocket endpoint over HTTP.
+type WebSocketListener struct {
+	server *http.Server
+	conns  chan *wsConn // Accepted connections.
+
+	mutex    sync.RWMutex  // Protects security.
+	security peer.Security // Secures accepted connections, if set.
+
+	pkgsync.Closer
+}
+
+var _ peer.Listener = (*WebSocketListener)(nil)
+
+// wsConn is a server side WebSocket connection. The HTTP server closes the
+// connection as soon as the handler returns, so the handler waits until the
+// connection is closed by the peer layer.
+type wsConn struct {
+	*websocket.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewWebSocketListener creates a new listener that accepts WebSocket
+// connections on the given address and HTTP path.
+func NewWebSocketListener(address string, path string) (*WebSocketListener, error) {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"failed to create listener for '%s'", address)
+	}
+
+	wl := &WebSocketListener{conns: make(chan *wsConn)}
+	mux := http.NewServeMux()
+	mux.Handle(path, websocket.Server{Handler: wl.handle})
+	wl.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := wl.server.Serve(l); err != http.ErrServerClosed {
+			log.Errorf("WebSocket server on '%s' stopped: %v", address, err)
+			wl.Close()
+		}
+	}()
+	return wl, nil
+}
+
+// handle hands a new WebSocket connection to Accept and waits until it is
+// closed.
+func (l *WebSocketListener) handle(ws *websocket.Conn) {
+	ws.PayloadType = websocket.BinaryFrame
+	conn := &wsConn{Conn: ws, closed: make(chan struct{})}
+	select {
+	case l.conns <- conn:
+	case <-l.Closed():
+		return
+	}
+	<-conn.closed
+}
+
+// Accept waits for the next incoming WebSocket connection.
+func (l *WebSocketListener) Accept() (peer.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		l.mutex.RLock()
+		defer l.mutex.RUnlock()
+		return secureServer(l.security, conn), nil
+	case <-l.Closed():
+		return nil, errors.New("accept failed: listener closed")
+	}
+}
+
+// SetSecurity sets the Security that secures all accepted connections, see
+// Listener.SetSecurity().
+func (l *WebSocketListener) SetSecurity(s peer.Security) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.security = s
+}
+
+// Close stops the HTTP server and aborts any ongoing Accept() call. Already
+// accepted connections stay open.
+func (l *WebSocketListener) Close() error {
+	if err := l.Closer.Close(); err != nil {
+		return err
+	}
+	return errors.Wrap(l.server.Close(), "closing server")
+}
+
+// Close closes the WebSocket connection and releases its handler.
+func (c *wsConn) Close() (err error) {
+	c.closeOnce.Do(func() {
+		err = c.Conn.Close()
+		close(c.closed)
+	})
+	return
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/backend/sim/wallet"
+	"perun.network/go-perun/pkg/test"
+	"perun.network/go-perun/wire/msg"
+)
+
+func TestWebSocket(t *testing.T) {
+	timeout := 500 * time.Millisecond
+	rng := rand.New(rand.NewSource(0x3eb50c7))
+	lhost := "127.0.0.1:7358"
+	laddr := wallet.NewRandomAddress(rng)
+
+	l, err := NewWebSocketListener(lhost, "/perun")
+	require.NoError(t, err)
+	defer l.Close()
+
+	d := NewWebSocketDialer(timeout)
+	d.Register(laddr, "ws://"+lhost+"/perun")
+	defer d.Close()
+
+	t.Run("happy", func(t *testing.T) {
+		ping, pong := msg.NewPingMsg(), msg.NewPongMsg()
+		ct := test.NewConcurrent(t)
+		go ct.Stage("accept", func(rt require.TestingT) {
+			conn, err := l.Accept()
+			assert.NoError(t, err)
+			require.NotNil(rt, conn)
+
+			rm, err := conn.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, ping, rm)
+			assert.NoError(t, conn.Send(pong))
+			assert.NoError(t, conn.Close())
+		})
+
+		ct.Stage("dial", func(rt require.TestingT) {
+			test.AssertTerminates(t, timeout, func() {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				defer cancel()
+				conn, err := d.Dial(ctx, laddr)
+				assert.NoError(t, err)
+				require.NotNil(rt, conn)
+
+				assert.NoError(t, conn.Send(ping))
+				rm, err := conn.Recv()
+				assert.NoError(t, err)
+				assert.Equal(t, pong, rm)
+				_, err = conn.Recv()
+				assert.Error(t, err, "receiving on connection closed by peer")
+			})
+		})
+
+		ct.Wait("dial", "accept")
+	})
+
+	t.Run("wrong path", func(t *testing.T) {
+		wrongAddr := wallet.NewRandomAddress(rng)
+		d.Register(wrongAddr, "ws://"+lhost+"/wrong")
+		test.AssertTerminates(t, timeout, func() {
+			conn, err := d.Dial(context.Background(), wrongAddr)
+			assert.Nil(t, conn)
+			assert.Error(t, err)
+		})
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		invalidAddr := wallet.NewRandomAddress(rng)
+		d.Register(invalidAddr, "no url")
+		conn, err := d.Dial(context.Background(), invalidAddr)
+		assert.Nil(t, conn)
+		assert.Error(t, err)
+	})
+}
+
+func TestWebSocketListener_Close(t *testing.T) {
+	timeout := 100 * time.Millisecond
+	l, err := NewWebSocketListener("127.0.0.1:7359", "/")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(timeout / 2)
+		assert.NoError(t, l.Close())
+	}()
+	test.AssertTerminates(t, timeout, func() {
+		conn, err := l.Accept()
+		assert.Nil(t, conn)
+		assert.Error(t, err)
+	})
+	assert.Error(t, l.Close(), "second close must result in error")
+}
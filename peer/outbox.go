@@ -0,0 +1,273 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/log"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+const (
+	// resendTimeout is the timeout for retransmitting a single message.
+	resendTimeout = 10 * time.Second
+	// keyNextSeq is the outbox key of the next sequence number. It is
+	// persisted, so that sequence numbers are not reused after a restart and
+	// recipients can drop retransmissions by their sequence number.
+	keyNextSeq = "next"
+	// maxReceivedSeqs is the number of sequence numbers of received Reliable
+	// messages that are remembered per peer to drop retransmissions.
+	maxReceivedSeqs = 1024
+)
+
+// An Outbox persists outgoing messages in a database until the peer
+// acknowledged them. Unacknowledged messages are retransmitted whenever the
+// peer connects, also after a restart, so messages are delivered at least
+// once. Recipients drop retransmissions of the recent messages that they
+// already received, but retransmitted messages may arrive after newer ones.
+//
+// An Outbox has to be set on a Registry with SetOutbox, so that it is notified
+// about acknowledgements and reconnections, and is used by Peer.SendReliable.
+// A Registry only announces wire.CapReliable if it has an Outbox. Messages to
+// peers that don't support wire.CapReliable are sent once without
+// persistence.
+type Outbox struct {
+	mtx sync.Mutex // protects db writes and seq
+	db  db.Database
+	seq uint64 // next sequence number
+	log log.Logger
+}
+
+// NewOutbox creates an Outbox that persists messages in the given database,
+// which should not be used for anything else. Messages that are still in the
+// database, e.g., from before a restart, are retransmitted.
+//
+// If the database is nil, NewOutbox panics.
+func NewOutbox(database db.Database) (*Outbox, error) {
+	if database == nil {
+		log.Panic("database must not be nil")
+	}
+
+	o := &Outbox{db: database, log: log.WithField("role", "outbox")}
+	it := database.NewIterator()
+	for it.Next() {
+		if it.Key() == keyNextSeq {
+			next, err := strconv.ParseUint(it.Value(), 10, 64)
+			if err != nil {
+				it.Close()
+				return nil, errors.Wrap(err, "parsing next sequence number")
+			}
+			if next > o.seq {
+				o.seq = next
+			}
+			continue
+		}
+		seq, err := parseSeq(it.Key())
+		if err != nil {
+			it.Close()
+			return nil, err
+		}
+		if seq >= o.seq {
+			o.seq = seq + 1
+		}
+	}
+	return o, errors.Wrap(it.Close(), "iterating outbox")
+}
+
+// Send persists the message and sends it to the peer. If sending fails, the
+// message is retransmitted when the peer connects again, so an error only
+// indicates that the message was not persisted or not delivered yet.
+func (o *Outbox) Send(ctx context.Context, p *Peer, m wire.Msg) error {
+	seq, err := o.put(p.PerunAddress, m)
+	if err != nil {
+		return errors.WithMessage(err, "persisting message")
+	}
+	return o.transmit(ctx, p, seq, m)
+}
+
+// transmit sends the persisted message with the given sequence number to the
+// peer. It is removed from the outbox when the peer acknowledges it, or
+// directly after it was sent if the peer doesn't support acknowledgements.
+func (o *Outbox) transmit(ctx context.Context, p *Peer, seq uint64, m wire.Msg) error {
+	if !p.waitExists(ctx) {
+		return errors.New("peer not connected")
+	}
+	if p.Capabilities().Has(wire.CapReliable) {
+		return p.Send(ctx, &wire.ReliableMsg{Seq: seq, Msg: m})
+	}
+
+	if err := p.Send(ctx, m); err != nil {
+		return err
+	}
+	o.ack(p.PerunAddress, seq)
+	return nil
+}
+
+// resend retransmits all unacknowledged messages to the peer once it exists.
+// It is called by the Registry for every new peer.
+func (o *Outbox) resend(p *Peer) {
+	if !p.waitExists(nil) {
+		return
+	}
+	log := o.log.WithField(log.PeerField, p.PerunAddress)
+	pending, err := o.pending(p.PerunAddress)
+	if err != nil {
+		log.Errorf("reading pending messages: %v", err)
+		return
+	}
+
+	for _, pm := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), resendTimeout)
+		err := o.transmit(ctx, p, pm.seq, pm.msg)
+		cancel()
+		if err != nil {
+			log.Warnf("retransmitting message %d: %v", pm.seq, err)
+			return
+		}
+	}
+}
+
+// ack removes the acknowledged message from the outbox.
+func (o *Outbox) ack(addr Address, seq uint64) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	key := msgKey(addr, seq)
+	if has, err := o.db.Has(key); err != nil || !has {
+		// Acknowledgements of retransmitted messages may be received twice.
+		return
+	}
+	if err := o.db.Delete(key); err != nil {
+		o.log.WithField(log.PeerField, addr).Errorf("deleting acknowledged message %d: %v", seq, err)
+	}
+}
+
+// put persists the message to the peer and returns its sequence number.
+func (o *Outbox) put(addr Address, m wire.Msg) (uint64, error) {
+	var buf bytes.Buffer
+	if err := wire.Encode(m, &buf); err != nil {
+		return 0, errors.WithMessage(err, "encoding message")
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	seq := o.seq
+	b := o.db.NewBatch()
+	if err := b.PutBytes(msgKey(addr, seq), buf.Bytes()); err != nil {
+		return 0, errors.WithMessage(err, "putting message")
+	}
+	if err := b.Put(keyNextSeq, strconv.FormatUint(seq+1, 10)); err != nil {
+		return 0, errors.WithMessage(err, "putting next sequence number")
+	}
+	if err := b.Apply(); err != nil {
+		return 0, errors.WithMessage(err, "applying batch")
+	}
+	o.seq++
+	return seq, nil
+}
+
+// SendReliable sends the message with the Outbox of the peer's Registry, so
+// that it is retransmitted until the peer acknowledges it, see Outbox.Send. If
+// the Registry has no Outbox, the message is sent like with Send.
+func (p *Peer) SendReliable(ctx context.Context, m wire.Msg) error {
+	if p.outbox == nil {
+		return p.Send(ctx, m)
+	}
+	return p.outbox.Send(ctx, p, m)
+}
+
+// receivedSeqs remembers the sequence numbers of the Reliable messages that
+// were received from a peer, so that retransmissions are dropped. Only the
+// last maxReceivedSeqs sequence numbers are remembered. It is shared by all
+// connections to the peer.
+type receivedSeqs struct {
+	mtx   sync.Mutex
+	seqs  map[uint64]struct{}
+	order []uint64 // seqs in the order of receipt
+}
+
+func newReceivedSeqs() *receivedSeqs {
+	return &receivedSeqs{seqs: make(map[uint64]struct{})}
+}
+
+// add adds the sequence number and returns whether it is new. If s is nil,
+// all sequence numbers are new.
+func (s *receivedSeqs) add(seq uint64) bool {
+	if s == nil {
+		return true
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.seqs[seq]; ok {
+		return false
+	}
+	if len(s.order) >= maxReceivedSeqs {
+		delete(s.seqs, s.order[0])
+		s.order = s.order[1:]
+	}
+	s.seqs[seq] = struct{}{}
+	s.order = append(s.order, seq)
+	return true
+}
+
+// pendingMsg is an unacknowledged message of the outbox.
+type pendingMsg struct {
+	seq uint64
+	msg wire.Msg
+}
+
+// pending returns the unacknowledged messages to the peer, ordered by their
+// sequence numbers.
+func (o *Outbox) pending(addr Address) (msgs []pendingMsg, err error) {
+	it := o.db.NewIteratorWithPrefix(peerPrefix(addr))
+	defer func() {
+		if cerr := it.Close(); cerr != nil && err == nil {
+			err = errors.Wrap(cerr, "iterating outbox")
+		}
+	}()
+
+	for it.Next() {
+		seq, err := parseSeq(it.Key())
+		if err != nil {
+			return nil, err
+		}
+		m, err := wire.Decode(bytes.NewReader(it.ValueBytes()))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "decoding message %d", seq)
+		}
+		msgs = append(msgs, pendingMsg{seq: seq, msg: m})
+	}
+	return msgs, nil
+}
+
+// peerPrefix returns the key prefix of the messages to the peer.
+func peerPrefix(addr Address) string {
+	return fmt.Sprintf("%x:", addr.Bytes())
+}
+
+// msgKey returns the key of the message to the peer with the given sequence
+// number. The sequence number is padded, so that the keys are ordered by it.
+func msgKey(addr Address, seq uint64) string {
+	return fmt.Sprintf("%s%020d", peerPrefix(addr), seq)
+}
+
+// parseSeq parses the sequence number of a message key.
+func parseSeq(key string) (uint64, error) {
+	i := strings.LastIndexByte(key, ':')
+	seq, err := strconv.ParseUint(key[i+1:], 10, 64)
+	return seq, errors.Wrapf(err, "invalid outbox key %q", key)
+}
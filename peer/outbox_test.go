@@ -0,0 +1,148 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/db"
+	"perun.network/go-perun/db/memorydb"
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	"perun.network/go-perun/pkg/test"
+	wallettest "perun.network/go-perun/wallet/test"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+func TestOutbox(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x0b0c))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	// Bob receives all messages in recv.
+	recv := peer.NewReceiver()
+	defer recv.Close()
+	bob := peer.NewRegistry(bobID, func(p *peer.Peer) {
+		require.NoError(t, p.Subscribe(recv, wire.OfType(wire.Ping)))
+	}, nil)
+	defer bob.Close()
+	bobOutbox, err := peer.NewOutbox(memorydb.NewDatabase())
+	require.NoError(t, err)
+	bob.SetOutbox(bobOutbox)
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	database := memorydb.NewDatabase()
+	newAlice := func() (*peer.Registry, *peer.Outbox) {
+		outbox, err := peer.NewOutbox(database)
+		require.NoError(t, err)
+		alice := peer.NewRegistry(aliceID, func(*peer.Peer) {}, hub.NewDialer())
+		alice.SetOutbox(outbox)
+		return alice, outbox
+	}
+	awaitPing := func() {
+		_, m := recv.Next(ctx)
+		require.IsType(t, &wire.PingMsg{}, m)
+	}
+
+	// A sent message is removed from the outbox once it is acknowledged.
+	alice, outbox := newAlice()
+	p, err := alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	require.True(t, p.Capabilities().Has(wire.CapReliable))
+	require.NoError(t, p.SendReliable(ctx, wire.NewPingMsg()))
+	awaitPing()
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.Zero(t, numMsgs(database))
+	})
+
+	// A message that could not be sent is retransmitted after a restart.
+	require.NoError(t, alice.Close())
+	assert.Error(t, outbox.Send(ctx, p, wire.NewPingMsg()))
+	assert.Equal(t, 1, numMsgs(database))
+	alice, _ = newAlice()
+	_, err = alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	awaitPing()
+	test.Within100ms.Eventually(t, func(t test.T) {
+		assert.Zero(t, numMsgs(database))
+	})
+
+	// Sequence numbers are not reused after a restart with an empty outbox, so
+	// that bob doesn't drop new messages as retransmissions.
+	require.NoError(t, alice.Close())
+	alice, outbox = newAlice()
+	defer alice.Close()
+	p, err = alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	require.NoError(t, outbox.Send(ctx, p, wire.NewPingMsg()))
+	awaitPing()
+}
+
+func TestPeer_ReliableDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xd0b1e))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	recv := peer.NewReceiver()
+	defer recv.Close()
+	bob := peer.NewRegistry(bobID, func(p *peer.Peer) {
+		require.NoError(t, p.Subscribe(recv, wire.OfType(wire.Ping)))
+	}, nil)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+	alice := peer.NewRegistry(aliceID, func(*peer.Peer) {}, hub.NewDialer())
+	defer alice.Close()
+
+	// Without an outbox, CapReliable is not announced.
+	p, err := alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	assert.False(t, p.Capabilities().Has(wire.CapReliable))
+
+	// A retransmitted message is only delivered once.
+	for _, seq := range []uint64{1, 1, 2} {
+		require.NoError(t, p.Send(ctx, &wire.ReliableMsg{Seq: seq, Msg: wire.NewPingMsg()}))
+	}
+	for i := 0; i < 2; i++ {
+		_, m := recv.Next(ctx)
+		require.IsType(t, &wire.PingMsg{}, m)
+	}
+	nextCtx, nextCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer nextCancel()
+	_, m := recv.Next(nextCtx)
+	assert.Nil(t, m, "retransmission delivered")
+}
+
+func TestNewOutbox(t *testing.T) {
+	assert.Panics(t, func() { peer.NewOutbox(nil) })
+
+	database := memorydb.NewDatabase()
+	require.NoError(t, database.Put("invalid", ""))
+	_, err := peer.NewOutbox(database)
+	assert.Error(t, err)
+}
+
+// numMsgs returns the number of messages in the outbox database, which are
+// all keys except the next sequence number.
+func numMsgs(database db.Database) (n int) {
+	it := database.NewIterator()
+	defer it.Close()
+	for it.Next() {
+		if it.Key() != "next" {
+			n++
+		}
+	}
+	return n
+}
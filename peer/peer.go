@@ -8,11 +8,14 @@ package peer // import "perun.network/go-perun/peer"
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"perun.network/go-perun/log"
 	"perun.network/go-perun/pkg/sync"
+	perunatomic "perun.network/go-perun/pkg/sync/atomic"
 	wire "perun.network/go-perun/wire/msg"
 )
 
@@ -30,14 +33,24 @@ import (
 // exists in an unfinished state, and all its operations will block until it is
 // dialed or closed.
 type Peer struct {
+	// lastPong is the time of the last received pong in Unix nanoseconds. It
+	// is accessed atomically, so it comes first for 64-bit alignment.
+	lastPong int64
+
 	PerunAddress Address // The peer's perun address.
 
-	conn Conn // The peer's connection.
+	conn   Conn              // The peer's connection.
+	caps   wire.Capabilities // The capabilities negotiated with the peer.
+	outbox *Outbox           // The outbox of the registry, may be nil.
+	tracer Tracer            // The tracer of the registry, may be nil.
+	// received drops retransmitted Reliable messages, may be nil.
+	received *receivedSeqs
 
 	creating sync.Mutex // Prevent races when concurrently creating the peer.
 	sending  sync.Mutex // Blocks multiple Send calls.
 
-	created chan struct{} // Indicates whether a peer has been created yet.
+	created     chan struct{}    // Indicates whether a peer has been created yet.
+	unreachable perunatomic.Bool // Whether the keepalive closed the peer.
 
 	producer
 }
@@ -54,25 +67,63 @@ func (p *Peer) recvLoop() {
 	for {
 		m, err := p.conn.Recv()
 		if err != nil {
-			log.Debugf("ending recvLoop on closed connection of peer %v", p.PerunAddress)
+			log.WithField(log.PeerField, p.PerunAddress).Debug("ending recvLoop on closed connection")
 			p.Close() // Ignore double close.
 			return
 		}
-		// Broadcast the received message to all interested subscribers.
-		p.produce(m, p)
+		p.trace(m, false)
+
+		switch m := m.(type) {
+		case *wire.ReliableMsg:
+			// Acknowledge asynchronously, so that receiving is not blocked.
+			// Retransmissions are acknowledged again, since the first
+			// acknowledgement may have been lost, but not delivered again.
+			go p.ack(m.Seq)
+			if !p.received.add(m.Seq) {
+				log.WithField(log.PeerField, p.PerunAddress).Debugf("dropping retransmitted message %d", m.Seq)
+				continue
+			}
+			p.produce(m.Msg, p)
+		case *wire.AckMsg:
+			if p.outbox != nil {
+				p.outbox.ack(p.PerunAddress, m.Seq)
+			}
+		case *wire.PingMsg:
+			go p.pong()
+			p.produce(m, p)
+		case *wire.PongMsg:
+			atomic.StoreInt64(&p.lastPong, time.Now().UnixNano())
+			p.produce(m, p)
+		default:
+			// Broadcast the received message to all interested subscribers.
+			p.produce(m, p)
+		}
+	}
+}
+
+// ackTimeout is the timeout for sending an acknowledgement.
+const ackTimeout = 10 * time.Second
+
+// ack acknowledges the receipt of the Reliable message with the given sequence
+// number. If the acknowledgement is lost, the message is retransmitted.
+func (p *Peer) ack(seq uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), ackTimeout)
+	defer cancel()
+	if err := p.Send(ctx, &wire.AckMsg{Seq: seq}); err != nil {
+		log.WithField(log.PeerField, p.PerunAddress).Debugf("acknowledging message %d: %v", seq, err)
 	}
 }
 
 // create finishes a peer that does not yet have a connection.
 // This is needed in the registry when a peer is still being dialed, but
 // already registered. This wakes up all operations that were started on the
-// unfinished peer object.
-func (p *Peer) create(conn Conn) {
+// unfinished peer object. caps are the capabilities negotiated on conn.
+func (p *Peer) create(conn Conn, caps wire.Capabilities) {
 	p.creating.Lock()
 	defer p.creating.Unlock()
 
 	if p.conn == nil {
-		p.conn = conn
+		p.conn, p.caps = conn, caps
 		close(p.created)
 	} else {
 		conn.Close()
@@ -133,7 +184,11 @@ func (p *Peer) Send(ctx context.Context, m wire.Msg) error {
 	// Asynchronously send, because we cannot abort Conn.Send().
 	go func() {
 		defer p.sending.Unlock()
-		sent <- p.conn.Send(m)
+		err := p.conn.Send(m)
+		if err == nil {
+			p.trace(m, true)
+		}
+		sent <- err
 	}()
 
 	// Return as soon as the sending finishes, times out, or peer is closed.
@@ -183,6 +238,13 @@ func newPeer(addr Address, conn Conn, _ Dialer) *Peer {
 	return p
 }
 
+// Capabilities returns the capabilities that both we and the peer support, as
+// negotiated when the connection was established. It must only be called on
+// existing peers, e.g., on peers returned by Registry.Get.
+func (p *Peer) Capabilities() wire.Capabilities {
+	return p.caps
+}
+
 // String returns the peer's address string
 func (p *Peer) String() string {
 	return p.PerunAddress.String()
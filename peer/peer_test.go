@@ -187,7 +187,7 @@ func TestPeer_create(t *testing.T) {
 	assert.False(t, p.exists(), "peer must not yet exist")
 
 	conn := newMockConn(nil)
-	p.create(conn)
+	p.create(conn, 0)
 
 	assert.True(t, p.exists(), "peer must exist")
 
@@ -195,7 +195,7 @@ func TestPeer_create(t *testing.T) {
 		"Peer.create() on nonexisting peers must not close the new connection")
 
 	conn2 := newMockConn(nil)
-	p.create(conn2)
+	p.create(conn2, 0)
 	assert.True(t, conn2.closed.IsSet(),
 		"Peer.create() on existing peers must close the new connection")
 }
@@ -8,6 +8,7 @@ package peer
 import (
 	"context"
 	stdsync "sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -62,6 +63,21 @@ func (p *producer) Cache(ctx context.Context, predicate msg.Predicate) {
 	p.cache.Cache(ctx, predicate)
 }
 
+// CacheFor is like Cache, but the cached messages expire after ttl, see
+// msg.Cache.CacheFor. They also expire when the producer is closed.
+func (p *producer) CacheFor(ctx context.Context, predicate msg.Predicate, ttl time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	if !p.OnCloseAlways(cancel) {
+		cancel()
+		return
+	}
+
+	p.cache.CacheFor(ctx, predicate, ttl)
+}
+
 // Subscribe adds a receiver to the subscriptions.
 // If the receiver was already subscribed, Subscribe panics.
 // If the peer is closed, Subscribe returns an error.
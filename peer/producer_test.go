@@ -175,3 +175,27 @@ func TestProducer_caching(t *testing.T) {
 	prod.cache.Put(ping0, nil)
 	assert.Zero(prod.cache.Size(), "Cache on closed producer should not enable caching")
 }
+
+// TestProducer_CacheFor tests that expiring cached messages are replayed to
+// new subscribers and do not make Close fail.
+func TestProducer_CacheFor(t *testing.T) {
+	isPing := func(m wire.Msg) bool { return m.Type() == wire.Ping }
+	prod := makeProducer()
+	prod.CacheFor(context.Background(), isPing, time.Minute)
+
+	ping, peer := wire.NewPingMsg(), &Peer{}
+	prod.produce(ping, peer)
+	prod.produce(wire.NewPingMsg(), peer)
+	assert.Equal(t, 2, prod.cache.Size())
+
+	rec := NewReceiver()
+	require.NoError(t, prod.Subscribe(rec, func(m wire.Msg) bool { return m == ping }))
+	test.AssertTerminates(t, timeout, func() {
+		recpeer, recmsg := rec.Next(context.Background())
+		assert.Same(t, recpeer, peer)
+		assert.Same(t, recmsg, ping)
+	})
+
+	assert.NoError(t, prod.Close(), "expiring messages should not make Close fail")
+	assert.Zero(t, prod.cache.Size())
+}
@@ -13,20 +13,33 @@ import (
 
 	"github.com/pkg/errors"
 	"perun.network/go-perun/log"
+	"perun.network/go-perun/metrics"
 	perunsync "perun.network/go-perun/pkg/sync"
+	wire "perun.network/go-perun/wire/msg"
 )
 
 // Registry is a peer Registry.
 // It should not be used manually, but only internally by the client.
 type Registry struct {
 	mutex sync.RWMutex
-	peers []*Peer  // The list of all of the registry's peers.
-	id    Identity // The identity of the node.
+	peers []*Peer // The list of all of the registry's peers.
+	// connected contains the addresses of all peers that we were connected to,
+	// so that reconnections can be counted.
+	connected map[string]struct{}
+	id        Identity // The identity of the node.
 
 	exchangeAddrsTimeout int64
+	caps                 uint32 // wire.Capabilities announced to peers
+	keepAliveInterval    int64  // time.Duration between pings, 0 if disabled
+	keepAliveMaxMissed   int32  // number of missed pongs until a peer is closed
 
 	dialer    Dialer      // Used for dialing peers (and later: repairing).
 	subscribe func(*Peer) // Sets up peer subscriptions.
+	outbox    *Outbox     // Retransmits unacknowledged messages, may be nil.
+	tracer    Tracer      // Records all messages of all peers, may be nil.
+	// received contains the sequence numbers of the Reliable messages that
+	// were received from each peer, keyed by the address bytes.
+	received map[string]*receivedSeqs
 
 	log log.Logger
 	perunsync.Closer
@@ -42,10 +55,12 @@ func NewRegistry(id Identity, subscribe func(*Peer), dialer Dialer) *Registry {
 		id:        id,
 		subscribe: subscribe,
 		dialer:    dialer,
+		connected: make(map[string]struct{}),
+		received:  make(map[string]*receivedSeqs),
 
 		exchangeAddrsTimeout: int64(defaultExchangeAddrsTimeout),
 
-		log: log.WithField("id", id.Address()),
+		log: log.WithField(log.IDField, id.Address()),
 	}
 }
 
@@ -54,6 +69,59 @@ func (r *Registry) SetExchangeAddrsTimeout(d time.Duration) {
 	atomic.StoreInt64(&r.exchangeAddrsTimeout, int64(d))
 }
 
+// SetKeepAlive atomically sets the keepalive of new peers. If the interval is
+// positive, peers are pinged every interval and closed as unreachable when
+// they miss maxMissed pongs in a row. The client then emits a PeerDisconnected
+// event. An interval of 0 disables the keepalive, which is the default.
+//
+// If maxMissed is not positive while the keepalive is enabled, SetKeepAlive
+// panics.
+func (r *Registry) SetKeepAlive(interval time.Duration, maxMissed int) {
+	if interval > 0 && maxMissed <= 0 {
+		log.Panic("maxMissed must be positive")
+	}
+	atomic.StoreInt32(&r.keepAliveMaxMissed, int32(maxMissed))
+	atomic.StoreInt64(&r.keepAliveInterval, int64(interval))
+}
+
+// SetCapabilities atomically sets the capabilities that are announced to new
+// peers.
+func (r *Registry) SetCapabilities(caps wire.Capabilities) {
+	atomic.StoreUint32(&r.caps, uint32(caps))
+}
+
+// capabilities atomically loads the capabilities that are announced to peers.
+// CapReliable is only announced if the registry has an Outbox, since the peers
+// then send Reliable messages, which need to be acknowledged.
+func (r *Registry) capabilities() wire.Capabilities {
+	caps := wire.Capabilities(atomic.LoadUint32(&r.caps))
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.outbox != nil {
+		caps |= wire.CapReliable
+	}
+	return caps
+}
+
+// SetOutbox sets the Outbox that is notified about the acknowledgements of
+// all peers, retransmits its unacknowledged messages to every new peer and is
+// used by Peer.SendReliable. It should be set before any peer is added to the
+// registry.
+func (r *Registry) SetOutbox(o *Outbox) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.outbox = o
+}
+
+// SetTracer sets the Tracer that records all messages that are sent to or
+// received from new peers. It should be set before any peer is added to the
+// registry. A nil tracer disables tracing, which is the default.
+func (r *Registry) SetTracer(t Tracer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.tracer = t
+}
+
 // Close closes the registry's dialer and all its peers.
 func (r *Registry) Close() (err error) {
 	if err = r.Closer.Close(); err != nil {
@@ -114,23 +182,36 @@ func (r *Registry) setupConn(conn Conn) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	var peerAddr Address
-	var err error
-	if peerAddr, err = ExchangeAddrs(ctx, r.id, conn); err != nil {
+	peerAddr, caps, err := Handshake(ctx, r.id, r.capabilities(), conn)
+	if err != nil {
 		conn.Close()
 		return errors.WithMessage(err, "could not authenticate peer")
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	if peer, _ := r.find(peerAddr); peer == nil {
-		r.addPeer(peerAddr, conn)
-	} else {
-		peer.create(conn)
+	peer, _ := r.find(peerAddr)
+	if peer == nil {
+		peer = r.addPeer(peerAddr, nil)
 	}
+	peer.create(conn, caps)
+	r.markConnected(peerAddr)
 	return nil
 }
 
+// markConnected records that a connection to the peer was set up and counts
+// the reconnection if the peer was connected before.
+// markConnected is not thread safe and is assumed to be called from a method
+// which has the r.mutex lock.
+func (r *Registry) markConnected(addr Address) {
+	key := string(addr.Bytes())
+	if _, ok := r.connected[key]; ok {
+		metrics.Reconnected()
+		return
+	}
+	r.connected[key] = struct{}{}
+}
+
 // find looks up a peer via its Perun address.
 // If found, returns the peer and its index, otherwise returns a nil peer.
 // find is not thread safe and is assumed to be called from a method which has
@@ -171,7 +252,7 @@ func (r *Registry) prune() {
 // object can be used already, but it will block until the peer is finished or
 // closed. If the registry is already closed, returns a closed peer.
 func (r *Registry) Get(ctx context.Context, addr Address) (*Peer, error) {
-	log := r.log.WithField("peer", addr)
+	log := r.log.WithField(log.PeerField, addr)
 	log.Trace("Registry.Get")
 	r.mutex.Lock()
 	if p, i := r.find(addr); i != -1 {
@@ -197,6 +278,34 @@ func (r *Registry) Get(ctx context.Context, addr Address) (*Peer, error) {
 	return peer, nil
 }
 
+// Await looks up the peer via its perun address like Get, but does not dial
+// it. If the peer does not exist yet, creates a placeholder peer and waits
+// until the peer connects to us. If ctx is done before, the placeholder peer
+// is closed.
+//
+// Await can be used when two peers have to connect to each other, which fails
+// if both dial at the same time: The connection that each peer dialed is
+// closed by the other peer, which already uses the incoming connection.
+func (r *Registry) Await(ctx context.Context, addr Address) (*Peer, error) {
+	log := r.log.WithField(log.PeerField, addr)
+	log.Trace("Registry.Await")
+	r.mutex.Lock()
+	p, i := r.find(addr)
+	if i == -1 {
+		log.Trace("Registry.Await: peer not found, waiting for it to connect...")
+		p = r.addPeer(addr, nil)
+	}
+	r.mutex.Unlock()
+
+	if !p.waitExists(ctx) {
+		if i == -1 && !p.exists() {
+			p.Close()
+		}
+		return nil, errors.New("peer did not connect in time")
+	}
+	return p, nil
+}
+
 func (r *Registry) authenticatedDial(ctx context.Context, peer *Peer, addr Address) error {
 	conn, err := r.dialer.Dial(ctx, addr)
 
@@ -209,7 +318,7 @@ func (r *Registry) authenticatedDial(ctx context.Context, peer *Peer, addr Addre
 		return errors.WithMessage(err, "failed to dial")
 	}
 
-	a, err := ExchangeAddrs(ctx, r.id, conn)
+	a, caps, err := Handshake(ctx, r.id, r.capabilities(), conn)
 	if err != nil || !a.Equals(addr) {
 		conn.Close()
 		if !peer.exists() {
@@ -222,7 +331,10 @@ func (r *Registry) authenticatedDial(ctx context.Context, peer *Peer, addr Addre
 		return nil
 	}
 
-	peer.create(conn)
+	peer.create(conn, caps)
+	r.mutex.Lock()
+	r.markConnected(addr)
+	r.mutex.Unlock()
 	return nil
 }
 
@@ -252,14 +364,27 @@ func (r *Registry) Has(addr Address) bool {
 // addPeer is not thread safe and is assumed to be called from a method which has
 // the r.mutex lock.
 func (r *Registry) addPeer(addr Address, conn Conn) *Peer {
-	r.log.WithField("peer", addr).Trace("Registry.addPeer")
+	r.log.WithField(log.PeerField, addr).Trace("Registry.addPeer")
 	// Create and register a new peer.
 	peer := newPeer(addr, conn, r.dialer)
+	peer.outbox = r.outbox
+	peer.tracer = r.tracer
+	key := string(addr.Bytes())
+	if r.received[key] == nil {
+		r.received[key] = newReceivedSeqs()
+	}
+	peer.received = r.received[key]
 	r.peers = append(r.peers, peer)
 	// Setup the peer's subscriptions.
 	r.subscribe(peer)
 	// Start receiving messages.
 	go peer.recvLoop()
+	if interval := time.Duration(atomic.LoadInt64(&r.keepAliveInterval)); interval > 0 {
+		go peer.keepAlive(interval, int(atomic.LoadInt32(&r.keepAliveMaxMissed)))
+	}
+	if r.outbox != nil {
+		go r.outbox.resend(peer)
+	}
 
 	return peer
 }
@@ -19,6 +19,7 @@ import (
 	"perun.network/go-perun/pkg/sync"
 	"perun.network/go-perun/pkg/test"
 	wallettest "perun.network/go-perun/wallet/test"
+	wire "perun.network/go-perun/wire/msg"
 )
 
 var timeout = 100 * time.Millisecond
@@ -130,3 +131,33 @@ func TestRegistry_Get_Multiple(t *testing.T) {
 	assert.True(sync.IsAlreadyClosedError(listener.Close()))
 	test.AssertTerminates(t, timeout, func() { <-done })
 }
+
+// Peers answer pings automatically, so that the keepalive keeps them open.
+func TestRegistry_KeepAlive(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x9119))
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	pongs := peer.NewReceiver()
+	defer pongs.Close()
+	alice := peer.NewRegistry(aliceID, func(p *peer.Peer) {
+		require.NoError(t, p.Subscribe(pongs, wire.OfType(wire.Pong)))
+	}, hub.NewDialer())
+	defer alice.Close()
+	alice.SetKeepAlive(10*time.Millisecond, 1)
+	bob := peer.NewRegistry(bobID, func(*peer.Peer) {}, nil)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+	assert.Panics(t, func() { alice.SetKeepAlive(time.Second, 0) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p, err := alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, m := pongs.Next(ctx)
+		require.IsType(t, &wire.PongMsg{}, m)
+	}
+	assert.False(t, p.IsClosed())
+	assert.False(t, p.Unreachable())
+}
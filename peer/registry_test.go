@@ -176,6 +176,51 @@ func TestRegistry_Get(t *testing.T) {
 	})
 }
 
+func TestRegistry_Await(t *testing.T) {
+	t.Parallel()
+	rng := rand.New(rand.NewSource(0xA3A17))
+	id := wallettest.NewRandomAccount(rng)
+	peerID := wallettest.NewRandomAccount(rng)
+	peerAddr := peerID.Address()
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+
+		dialer := newMockDialer()
+		r := NewRegistry(id, func(*Peer) {}, dialer)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		p, err := r.Await(ctx, peerAddr)
+		assert.Error(t, err)
+		assert.Nil(t, p)
+		assert.False(t, r.Has(peerAddr), "placeholder peer must be removed")
+	})
+
+	t.Run("peer connects", func(t *testing.T) {
+		t.Parallel()
+
+		dialer := newMockDialer()
+		dialer.Close() // Await must not dial.
+		r := NewRegistry(id, func(*Peer) {}, dialer)
+		a, b := newPipeConnPair()
+		go func() {
+			<-time.After(timeout / 2)
+			go ExchangeAddrs(context.Background(), peerID, b)
+			r.setupConn(a)
+		}()
+
+		ct := test.NewConcurrent(t)
+		test.AssertTerminates(t, timeout, func() {
+			ct.Stage("terminates", func(t require.TestingT) {
+				p, err := r.Await(context.Background(), peerAddr)
+				require.NoError(t, err)
+				require.True(t, p.exists())
+			})
+		})
+		ct.Wait("terminates")
+	})
+}
+
 func TestRegistry_authenticatedDial(t *testing.T) {
 	t.Parallel()
 	rng := rand.New(rand.NewSource(0xb0baFEDD))
@@ -188,7 +233,7 @@ func TestRegistry_authenticatedDial(t *testing.T) {
 
 	t.Run("dial fail, existing peer", func(t *testing.T) {
 		p := newPeer(nil, nil, nil)
-		p.create(newMockConn(nil))
+		p.create(newMockConn(nil), 0)
 		go d.put(nil)
 		test.AssertTerminates(t, timeout, func() {
 			err := r.authenticatedDial(context.Background(), p, wallettest.NewRandomAddress(rng))
@@ -356,7 +401,7 @@ func TestRegistry_addPeer_Subscribe(t *testing.T) {
 	r := NewRegistry(wallettest.NewRandomAccount(rng), func(*Peer) { called = true }, nil)
 
 	assert.False(t, called, "subscription must not have been called yet")
-	r.addPeer(nil, nil)
+	r.addPeer(wallettest.NewRandomAddress(rng), nil)
 	assert.True(t, called, "subscription must have been called")
 }
 
@@ -0,0 +1,93 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A Resolver resolves the network endpoint of a peer from its Perun address,
+// e.g., from a static configuration, from DNS or from an on-chain registry
+// contract. The format of the endpoint depends on the Dialer that uses it,
+// e.g., "host:port" for TCP.
+type Resolver interface {
+	// Resolve returns the endpoint of the peer with the given address. It
+	// returns an error if the endpoint is unknown or the context is done.
+	Resolve(ctx context.Context, addr Address) (string, error)
+}
+
+// Resolvers is a Resolver that asks all contained resolvers in order and
+// returns the first endpoint that is found.
+type Resolvers []Resolver
+
+var _ Resolver = Resolvers(nil)
+
+// Resolve returns the endpoint of the first resolver that knows the peer. If
+// no resolver knows it, the errors of all resolvers are returned.
+func (rs Resolvers) Resolve(ctx context.Context, addr Address) (string, error) {
+	var errs []string
+	for i, r := range rs {
+		endpoint, err := r.Resolve(ctx, addr)
+		if err == nil {
+			return endpoint, nil
+		} else if ctx.Err() != nil {
+			return "", errors.WithMessagef(ctx.Err(), "resolving peer %v", addr)
+		}
+		errs = append(errs, fmt.Sprintf("resolver %d: %v", i, err))
+	}
+	return "", errors.Errorf("no endpoint of peer %v [%s]", addr, strings.Join(errs, "; "))
+}
+
+// AddressBook is a Resolver with a static table of endpoints. It is safe for
+// concurrent use.
+type AddressBook struct {
+	mutex     sync.RWMutex
+	endpoints map[string]string // endpoints by the bytes of the address
+}
+
+var _ Resolver = (*AddressBook)(nil)
+
+// NewAddressBook creates an empty AddressBook.
+func NewAddressBook() *AddressBook {
+	return &AddressBook{endpoints: make(map[string]string)}
+}
+
+// Register sets the endpoint of the peer with the given address. It replaces
+// a previously registered endpoint.
+func (b *AddressBook) Register(addr Address, endpoint string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.endpoints[string(addr.Bytes())] = endpoint
+}
+
+// Remove removes the endpoint of the peer with the given address.
+func (b *AddressBook) Remove(addr Address) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.endpoints, string(addr.Bytes()))
+}
+
+// Lookup returns the endpoint of the peer with the given address and whether
+// it is known.
+func (b *AddressBook) Lookup(addr Address) (string, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	endpoint, ok := b.endpoints[string(addr.Bytes())]
+	return endpoint, ok
+}
+
+// Resolve returns the registered endpoint of the peer.
+func (b *AddressBook) Resolve(_ context.Context, addr Address) (string, error) {
+	if endpoint, ok := b.Lookup(addr); ok {
+		return endpoint, nil
+	}
+	return "", errors.Errorf("peer %v not in address book", addr)
+}
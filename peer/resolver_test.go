@@ -0,0 +1,68 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/peer"
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestAddressBook(t *testing.T) {
+	rng := rand.New(rand.NewSource(0xb00c))
+	addr := wallettest.NewRandomAddress(rng)
+	book := peer.NewAddressBook()
+	ctx := context.Background()
+
+	_, err := book.Resolve(ctx, addr)
+	assert.Error(t, err, "unknown peer")
+
+	book.Register(addr, "host:1")
+	// Another instance of the same address resolves, too.
+	same, err := wallet.DecodeAddress(bytes.NewReader(addr.Bytes()))
+	require.NoError(t, err)
+	endpoint, err := book.Resolve(ctx, same)
+	require.NoError(t, err)
+	assert.Equal(t, "host:1", endpoint)
+
+	book.Register(addr, "host:2")
+	endpoint, _ = book.Resolve(ctx, addr)
+	assert.Equal(t, "host:2", endpoint)
+
+	book.Remove(addr)
+	_, ok := book.Lookup(addr)
+	assert.False(t, ok)
+}
+
+func TestResolvers(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7e501))
+	a, b, unknown := wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng), wallettest.NewRandomAddress(rng)
+	first, second := peer.NewAddressBook(), peer.NewAddressBook()
+	first.Register(a, "first-a")
+	second.Register(a, "second-a")
+	second.Register(b, "second-b")
+	rs := peer.Resolvers{first, second}
+	ctx := context.Background()
+
+	endpoint, err := rs.Resolve(ctx, a)
+	require.NoError(t, err)
+	assert.Equal(t, "first-a", endpoint, "first resolver takes precedence")
+	endpoint, err = rs.Resolve(ctx, b)
+	require.NoError(t, err)
+	assert.Equal(t, "second-b", endpoint)
+
+	_, err = rs.Resolve(ctx, unknown)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "resolver 1")
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"context"
+	"net"
+)
+
+// Security secures the byte stream of a connection before any wire message
+// is sent over it, e.g., by encrypting it and authenticating the Perun
+// addresses of the peers. A Security can be set on the dialers and listeners
+// of the transports, which send plaintext if none is set. Both peers have to
+// use the same Security.
+type Security interface {
+	// Client secures an outgoing connection. It fails if the peer cannot
+	// prove that it owns the expected address.
+	Client(ctx context.Context, conn net.Conn, expected Address) (net.Conn, error)
+	// Server secures an incoming connection and returns the authenticated
+	// address of the dialing peer.
+	Server(ctx context.Context, conn net.Conn) (net.Conn, Address, error)
+}
+
+// AuthenticatedConn is a connection on which the transport already
+// authenticated the address of the peer, e.g., via a Security. The address
+// exchange fails if the peer claims another address.
+type AuthenticatedConn interface {
+	Conn
+	// PeerAddress returns the authenticated address of the peer. It is only
+	// valid after the first message was received.
+	PeerAddress() Address
+}
+
+type authenticatedConn struct {
+	Conn
+	addr Address
+}
+
+// NewAuthenticatedConn marks a connection as authenticated to the peer with
+// the given address.
+func NewAuthenticatedConn(conn Conn, addr Address) AuthenticatedConn {
+	return &authenticatedConn{Conn: conn, addr: addr}
+}
+
+func (c *authenticatedConn) PeerAddress() Address {
+	return c.addr
+}
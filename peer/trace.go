@@ -0,0 +1,118 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	wire "perun.network/go-perun/wire/msg"
+)
+
+type (
+	// A Tracer records every message that is sent to or received from a peer,
+	// e.g., to debug the protocols or to attach a protocol transcript to a bug
+	// report. It is set with Registry.SetTracer.
+	Tracer interface {
+		// Trace is called for every message after it was sent or received. It is
+		// called concurrently for all peers, and synchronously while sending or
+		// receiving, so it should not block.
+		Trace(TraceRecord)
+	}
+
+	// A TraceRecord is a message that was sent to or received from a peer.
+	TraceRecord struct {
+		Time time.Time
+		Peer Address
+		Sent bool // whether the message was sent, otherwise it was received
+		Msg  wire.Msg
+	}
+
+	// TracerFunc is an adapter to use an ordinary function as a Tracer.
+	TracerFunc func(TraceRecord)
+
+	// A JSONTranscript is a Tracer that writes a transcript of all messages to
+	// a writer, one JSON object per line, see NewJSONTranscript.
+	JSONTranscript struct {
+		mutex sync.Mutex
+		enc   *json.Encoder
+		err   error
+	}
+
+	// transcriptLine is a line of a JSONTranscript.
+	transcriptLine struct {
+		Time    time.Time       `json:"time"`
+		Peer    string          `json:"peer"`
+		Dir     string          `json:"dir"`
+		Type    string          `json:"type"`
+		Content json.RawMessage `json:"content"`
+		Raw     []byte          `json:"raw"`
+	}
+)
+
+// Trace calls f(r).
+func (f TracerFunc) Trace(r TraceRecord) {
+	f(r)
+}
+
+// NewJSONTranscript creates a Tracer that writes a transcript of all messages
+// to w. Each message is written as a JSON object on its own line with the
+// fields time, peer, dir ("sent" or "recv"), type, content and raw. content is
+// the decoded message as JSON, or as a string if the message cannot be
+// marshaled. raw is the base64 encoding of the message's wire encoding, so that
+// the message can be reproduced with wire.Decode.
+func NewJSONTranscript(w io.Writer) *JSONTranscript {
+	return &JSONTranscript{enc: json.NewEncoder(w)}
+}
+
+// Trace writes the record to the transcript. If writing fails, the transcript
+// stops and the error is returned by Err.
+func (t *JSONTranscript) Trace(r TraceRecord) {
+	line := transcriptLine{
+		Time: r.Time,
+		Peer: r.Peer.String(),
+		Dir:  "recv",
+		Type: r.Msg.Type().String(),
+	}
+	if r.Sent {
+		line.Dir = "sent"
+	}
+	var raw bytes.Buffer
+	if err := wire.Encode(r.Msg, &raw); err == nil {
+		line.Raw = raw.Bytes()
+	}
+	content, err := json.Marshal(r.Msg)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprintf("%+v", r.Msg))
+	}
+	line.Content = content
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.err == nil {
+		t.err = errors.Wrap(t.enc.Encode(line), "writing transcript")
+	}
+}
+
+// Err returns the error that stopped the transcript, if any.
+func (t *JSONTranscript) Err() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.err
+}
+
+// trace records the message m, if the peer has a tracer.
+func (p *Peer) trace(m wire.Msg, sent bool) {
+	if p.tracer != nil {
+		p.tracer.Trace(TraceRecord{Time: time.Now(), Peer: p.PerunAddress, Sent: sent, Msg: m})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package peer_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"perun.network/go-perun/peer"
+	peertest "perun.network/go-perun/peer/test"
+	wallettest "perun.network/go-perun/wallet/test"
+	wire "perun.network/go-perun/wire/msg"
+)
+
+func TestRegistry_SetTracer(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x7ace))
+	var hub peertest.ConnHub
+	aliceID, bobID := wallettest.NewRandomAccount(rng), wallettest.NewRandomAccount(rng)
+
+	var buf bytes.Buffer
+	transcript := peer.NewJSONTranscript(&buf)
+	records := make(chan peer.TraceRecord, 10)
+	alice := peer.NewRegistry(aliceID, func(*peer.Peer) {}, hub.NewDialer())
+	defer alice.Close()
+	alice.SetTracer(peer.TracerFunc(func(r peer.TraceRecord) {
+		transcript.Trace(r)
+		records <- r
+	}))
+	bob := peer.NewRegistry(bobID, func(*peer.Peer) {}, nil)
+	defer bob.Close()
+	go bob.Listen(hub.NewListener(bobID.Address()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p, err := alice.Get(ctx, bobID.Address())
+	require.NoError(t, err)
+	ping := wire.NewPingMsg()
+	require.NoError(t, p.Send(ctx, ping))
+
+	// Bob answers the ping with a pong.
+	for _, sent := range []bool{true, false} {
+		select {
+		case r := <-records:
+			assert.Equal(t, sent, r.Sent)
+			assert.True(t, r.Peer.Equals(bobID.Address()))
+			assert.False(t, r.Time.IsZero())
+			if sent {
+				assert.Same(t, ping, r.Msg)
+			} else {
+				assert.IsType(t, &wire.PongMsg{}, r.Msg)
+			}
+		case <-ctx.Done():
+			t.Fatal("expected trace record")
+		}
+	}
+
+	require.NoError(t, transcript.Err())
+	lines := bufio.NewScanner(&buf)
+	for i, dir := range []string{"sent", "recv"} {
+		require.True(t, lines.Scan(), "transcript line %d", i)
+		var line struct {
+			Time    time.Time
+			Peer    string
+			Dir     string
+			Type    string
+			Content json.RawMessage
+			Raw     []byte
+		}
+		require.NoError(t, json.Unmarshal(lines.Bytes(), &line))
+		assert.Equal(t, dir, line.Dir)
+		assert.Equal(t, bobID.Address().String(), line.Peer)
+		assert.NotEmpty(t, line.Content)
+		m, err := wire.Decode(bytes.NewReader(line.Raw))
+		require.NoError(t, err)
+		assert.Equal(t, m.Type().String(), line.Type)
+	}
+	assert.False(t, lines.Scan(), "unexpected transcript line")
+}
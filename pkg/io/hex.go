@@ -0,0 +1,54 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package io
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HexBytes returns the 0x-prefixed hex encoding of b.
+func HexBytes(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// ParseHexBytes parses the hex encoding s, which may be 0x-prefixed.
+func ParseHexBytes(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	return b, errors.Wrap(err, "parsing hex")
+}
+
+// EncodeHex returns the 0x-prefixed hex encoding of the encoding of e. It is
+// used to embed backend-specific objects, like addresses and assets, in text
+// formats like JSON.
+func EncodeHex(e Encoder) (string, error) {
+	var buf bytes.Buffer
+	if err := e.Encode(&buf); err != nil {
+		return "", err
+	}
+	return HexBytes(buf.Bytes()), nil
+}
+
+// DecodeHex parses the hex encoding s, see EncodeHex, and decodes it with
+// decode. It fails if decode does not consume all bytes.
+func DecodeHex(s string, decode func(io.Reader) error) error {
+	b, err := ParseHexBytes(s)
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(b)
+	if err := decode(r); err != nil {
+		return err
+	}
+	if r.Len() != 0 {
+		return errors.Errorf("%d trailing bytes", r.Len())
+	}
+	return nil
+}
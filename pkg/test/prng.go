@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Namer is a subset of the testing.T functionality needed by Prng().
+type Namer interface {
+	Name() string
+}
+
+// Prng returns a pseudo random number generator that is seeded with the name
+// of the test, so that every test (and sub-test) gets its own reproducible
+// sequence of random data.
+func Prng(t Namer) *rand.Rand {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(t.Name())) // writing to a hash never fails
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namer string
+
+func (n namer) Name() string { return string(n) }
+
+func TestPrng(t *testing.T) {
+	assert.Equal(t, Prng(t).Int63(), Prng(t).Int63(), "same test")
+	assert.NotEqual(t, Prng(namer("a")).Int63(), Prng(namer("b")).Int63(), "different tests")
+}
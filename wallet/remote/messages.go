@@ -0,0 +1,61 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package remote
+
+import "github.com/golang/protobuf/proto"
+
+// The messages of the Signer service, see signer.proto.
+type (
+	empty struct{}
+
+	addressRequest struct {
+		Address []byte `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	}
+
+	signRequest struct {
+		Address []byte `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+		Data    []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	}
+
+	signBatchRequest struct {
+		Requests []*signRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	}
+
+	signResponse struct {
+		Sig   []byte `protobuf:"bytes,1,opt,name=sig,proto3" json:"sig,omitempty"`
+		Error string `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	}
+
+	signBatchResponse struct {
+		Responses []*signResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	}
+)
+
+// The methods required by proto.Message.
+
+func (m *empty) Reset()         { *m = empty{} }
+func (m *empty) String() string { return proto.CompactTextString(m) }
+func (*empty) ProtoMessage()    {}
+
+func (m *addressRequest) Reset()         { *m = addressRequest{} }
+func (m *addressRequest) String() string { return proto.CompactTextString(m) }
+func (*addressRequest) ProtoMessage()    {}
+
+func (m *signRequest) Reset()         { *m = signRequest{} }
+func (m *signRequest) String() string { return proto.CompactTextString(m) }
+func (*signRequest) ProtoMessage()    {}
+
+func (m *signBatchRequest) Reset()         { *m = signBatchRequest{} }
+func (m *signBatchRequest) String() string { return proto.CompactTextString(m) }
+func (*signBatchRequest) ProtoMessage()    {}
+
+func (m *signResponse) Reset()         { *m = signResponse{} }
+func (m *signResponse) String() string { return proto.CompactTextString(m) }
+func (*signResponse) ProtoMessage()    {}
+
+func (m *signBatchResponse) Reset()         { *m = signBatchResponse{} }
+func (m *signBatchResponse) String() string { return proto.CompactTextString(m) }
+func (*signBatchResponse) ProtoMessage()    {}
@@ -0,0 +1,256 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+// Package remote provides a wallet whose accounts sign on a remote signer
+// daemon, so that the keys never need to be in the process of the channel
+// client. The client and the daemon communicate over gRPC with mutually
+// authenticated TLS, see signer.proto for the service definition. Server
+// implements the service on the side of the daemon.
+package remote // import "perun.network/go-perun/wallet/remote"
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"perun.network/go-perun/log"
+	perunsync "perun.network/go-perun/pkg/sync"
+	"perun.network/go-perun/wallet"
+)
+
+const (
+	// rpcTimeout is the timeout of a single request to the daemon. It is long,
+	// since the daemon might wait for confirmations by the user.
+	rpcTimeout = time.Minute
+	// batchDelay is the time that signing requests are collected before they
+	// are sent to the daemon in a single batch.
+	batchDelay = 5 * time.Millisecond
+	// maxBatchSize is the maximal number of signing requests in a batch.
+	maxBatchSize = 64
+)
+
+type (
+	// Wallet is a wallet.Wallet whose accounts are held by a remote signer
+	// daemon. Concurrent signing requests are sent to the daemon in batches.
+	Wallet struct {
+		perunsync.Closer
+		conn *grpc.ClientConn
+		jobs chan *signJob
+		log  log.Logger
+
+		mtx    sync.Mutex
+		usages map[string]int // usage counters of the accounts
+	}
+
+	// Account is an account of a remote Wallet.
+	Account struct {
+		addr   wallet.Address
+		wallet *Wallet
+	}
+
+	// signJob is a signing request that waits for its batch to be sent.
+	signJob struct {
+		req    *signRequest
+		result chan signResult
+	}
+
+	signResult struct {
+		sig wallet.Sig
+		err error
+	}
+)
+
+var (
+	_ wallet.Wallet  = (*Wallet)(nil)
+	_ wallet.Account = (*Account)(nil)
+)
+
+// Dial connects to the signer daemon at the given target. The TLS
+// configuration has to contain the client certificate, since the daemon
+// authenticates its clients, and the certificate authorities that the
+// daemon's certificate is verified against. Dial blocks until the connection
+// is established or the context is done.
+//
+// If the TLS configuration is nil, Dial panics.
+func Dial(ctx context.Context, target string, tlsConfig *tls.Config) (*Wallet, error) {
+	if tlsConfig == nil {
+		log.Panic("TLS configuration must not be nil")
+	}
+
+	conn, err := grpc.DialContext(ctx, target,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithBlock())
+	if err != nil {
+		return nil, errors.Wrapf(err, "dialing signer %s", target)
+	}
+
+	w := &Wallet{
+		conn:   conn,
+		jobs:   make(chan *signJob),
+		log:    log.WithField("signer", target),
+		usages: make(map[string]int),
+	}
+	w.OnCloseAlways(func() {
+		if err := conn.Close(); err != nil {
+			w.log.Warnf("closing connection: %v", err)
+		}
+	})
+	go w.batchJobs()
+	return w, nil
+}
+
+// Unlock unlocks the account with the given address on the daemon.
+func (w *Wallet) Unlock(addr wallet.Address) (wallet.Account, error) {
+	if err := w.invokeAddr("Unlock", addr); err != nil {
+		return nil, err
+	}
+	return &Account{addr: addr, wallet: w}, nil
+}
+
+// LockAll locks all accounts on the daemon.
+func (w *Wallet) LockAll() error {
+	return w.invoke("LockAll", new(empty), new(empty))
+}
+
+// IncrementUsage increments the usage counter of the account on the daemon.
+// Errors are logged.
+func (w *Wallet) IncrementUsage(addr wallet.Address) {
+	w.mtx.Lock()
+	w.usages[addr.String()]++
+	w.mtx.Unlock()
+
+	if err := w.invokeAddr("IncrementUsage", addr); err != nil {
+		w.log.Errorf("incrementing usage of %v: %v", addr, err)
+	}
+}
+
+// DecrementUsage decrements the usage counter of the account on the daemon,
+// which locks the account if it is not used anymore. Errors are logged.
+func (w *Wallet) DecrementUsage(addr wallet.Address) {
+	w.mtx.Lock()
+	key := addr.String()
+	if w.usages[key] == 0 {
+		w.mtx.Unlock()
+		log.Panicf("account %v not in use", addr)
+	}
+	if w.usages[key]--; w.usages[key] == 0 {
+		delete(w.usages, key)
+	}
+	w.mtx.Unlock()
+
+	if err := w.invokeAddr("DecrementUsage", addr); err != nil {
+		w.log.Errorf("decrementing usage of %v: %v", addr, err)
+	}
+}
+
+// invokeAddr calls the method of the daemon with an addressRequest.
+func (w *Wallet) invokeAddr(method string, addr wallet.Address) error {
+	b, err := encodeAddress(addr)
+	if err != nil {
+		return err
+	}
+	return w.invoke(method, &addressRequest{Address: b}, new(empty))
+}
+
+// invoke calls the method of the daemon.
+func (w *Wallet) invoke(method string, req, resp proto.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	return errors.Wrapf(w.conn.Invoke(ctx, fullMethod(method), req, resp), "calling %s", method)
+}
+
+// sign queues the signing request for the next batch and waits for the
+// signature.
+func (w *Wallet) sign(addr wallet.Address, data []byte) (wallet.Sig, error) {
+	b, err := encodeAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	job := &signJob{
+		req:    &signRequest{Address: b, Data: data},
+		result: make(chan signResult, 1),
+	}
+
+	select {
+	case w.jobs <- job:
+	case <-w.Closed():
+		return nil, errors.New("wallet closed")
+	}
+	select {
+	case res := <-job.result:
+		return res.sig, res.err
+	case <-w.Closed():
+		return nil, errors.New("wallet closed")
+	}
+}
+
+// batchJobs collects the signing jobs that arrive within batchDelay after a
+// first job and sends them in a single batch, until the wallet is closed.
+func (w *Wallet) batchJobs() {
+	for {
+		var batch []*signJob
+		select {
+		case job := <-w.jobs:
+			batch = append(batch, job)
+		case <-w.Closed():
+			return
+		}
+
+		timer := time.NewTimer(batchDelay)
+	collect:
+		for len(batch) < maxBatchSize {
+			select {
+			case job := <-w.jobs:
+				batch = append(batch, job)
+			case <-timer.C:
+				break collect
+			case <-w.Closed():
+				timer.Stop()
+				return
+			}
+		}
+		timer.Stop()
+		w.signBatch(batch)
+	}
+}
+
+// signBatch sends the batch to the daemon and distributes the results.
+func (w *Wallet) signBatch(batch []*signJob) {
+	req := &signBatchRequest{Requests: make([]*signRequest, len(batch))}
+	for i, job := range batch {
+		req.Requests[i] = job.req
+	}
+	resp := new(signBatchResponse)
+	err := w.invoke("SignBatch", req, resp)
+	if err == nil && len(resp.Responses) != len(batch) {
+		err = errors.Errorf("received %d signatures for %d requests", len(resp.Responses), len(batch))
+	}
+
+	for i, job := range batch {
+		if err != nil {
+			job.result <- signResult{err: err}
+		} else if r := resp.Responses[i]; r.Error != "" {
+			job.result <- signResult{err: errors.New(r.Error)}
+		} else {
+			job.result <- signResult{sig: r.Sig}
+		}
+	}
+}
+
+// Address returns the address of the account.
+func (a *Account) Address() wallet.Address {
+	return a.addr
+}
+
+// SignData signs the data on the daemon.
+func (a *Account) SignData(data []byte) ([]byte, error) {
+	return a.wallet.sign(a.addr, data)
+}
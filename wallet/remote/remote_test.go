@@ -0,0 +1,191 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package remote_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/wallet"
+	"perun.network/go-perun/wallet/remote"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestWallet(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x5e7e))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	acc := wallettest.NewRandomAccount(rng)
+	local := &localWallet{accs: map[string]wallet.Account{acc.Address().String(): acc}}
+	ca := newCA(t)
+
+	// Count the batches that the daemon receives.
+	var mtx sync.Mutex
+	var batches int
+	countBatches := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if info.FullMethod == "/perun.wallet.remote.Signer/SignBatch" {
+			mtx.Lock()
+			batches++
+			mtx.Unlock()
+		}
+		return handler(ctx, req)
+	}
+	serverCfg := &tls.Config{
+		Certificates: []tls.Certificate{ca.newCert(t, "signer")},
+		ClientCAs:    ca.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	gs := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverCfg)), grpc.UnaryInterceptor(countBatches))
+	defer gs.Stop()
+	assert.Panics(t, func() { remote.NewServer(nil) })
+	remote.NewServer(local).Register(gs)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go gs.Serve(lis)
+
+	assert.Panics(t, func() { remote.Dial(ctx, lis.Addr().String(), nil) })
+	w, err := remote.Dial(ctx, lis.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{ca.newCert(t, "client")},
+		RootCAs:      ca.pool,
+		ServerName:   "signer",
+	})
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = w.Unlock(wallettest.NewRandomAddress(rng))
+	assert.Error(t, err, "unknown account")
+	remoteAcc, err := w.Unlock(acc.Address())
+	require.NoError(t, err)
+	assert.True(t, remoteAcc.Address().Equals(acc.Address()))
+
+	// Concurrent signing requests are batched.
+	const numSigs = 16
+	var wg sync.WaitGroup
+	wg.Add(numSigs)
+	for i := 0; i < numSigs; i++ {
+		data := []byte{byte(i)}
+		go func() {
+			defer wg.Done()
+			sig, err := remoteAcc.SignData(data)
+			if !assert.NoError(t, err) {
+				return
+			}
+			valid, err := wallet.VerifySignature(data, sig, acc.Address())
+			assert.NoError(t, err)
+			assert.True(t, valid)
+		}()
+	}
+	wg.Wait()
+	mtx.Lock()
+	assert.Less(t, batches, numSigs)
+	mtx.Unlock()
+
+	// The account is locked when it is not used anymore.
+	w.IncrementUsage(acc.Address())
+	w.DecrementUsage(acc.Address())
+	assert.Panics(t, func() { w.DecrementUsage(acc.Address()) })
+	_, err = remoteAcc.SignData([]byte("locked"))
+	assert.Error(t, err)
+
+	_, err = w.Unlock(acc.Address())
+	require.NoError(t, err)
+	require.NoError(t, w.LockAll())
+	_, err = remoteAcc.SignData([]byte("locked"))
+	assert.Error(t, err)
+
+	// Clients without a certificate are rejected.
+	dialCtx, dialCancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer dialCancel()
+	unauth, err := remote.Dial(dialCtx, lis.Addr().String(), &tls.Config{RootCAs: ca.pool, ServerName: "signer"})
+	if err == nil {
+		_, err = unauth.Unlock(acc.Address())
+		unauth.Close()
+	}
+	assert.Error(t, err)
+}
+
+type (
+	// localWallet is the wallet of the signer daemon.
+	localWallet struct {
+		accs map[string]wallet.Account
+	}
+
+	// ca is a certificate authority that issues TLS certificates.
+	ca struct {
+		cert *x509.Certificate
+		key  *ecdsa.PrivateKey
+		pool *x509.CertPool
+	}
+)
+
+func (w *localWallet) Unlock(addr wallet.Address) (wallet.Account, error) {
+	acc, ok := w.accs[addr.String()]
+	if !ok {
+		return nil, errors.New("unknown account")
+	}
+	return acc, nil
+}
+
+func (w *localWallet) LockAll() error                { return nil }
+func (w *localWallet) IncrementUsage(wallet.Address) {}
+func (w *localWallet) DecrementUsage(wallet.Address) {}
+
+func newCA(t *testing.T) *ca {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &ca{cert: cert, key: key, pool: pool}
+}
+
+// newCert issues a certificate for servers and clients with the given name.
+func (c *ca) newCert(t *testing.T, name string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, c.cert, &key.PublicKey, c.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
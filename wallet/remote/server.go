@@ -0,0 +1,233 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wallet"
+)
+
+// serviceName is the full name of the Signer service, see signer.proto.
+const serviceName = "perun.wallet.remote.Signer"
+
+// signerServer is implemented by the handlers of the Signer service.
+type signerServer interface {
+	unlock(context.Context, *addressRequest) (*empty, error)
+	lockAll(context.Context, *empty) (*empty, error)
+	incrementUsage(context.Context, *addressRequest) (*empty, error)
+	decrementUsage(context.Context, *addressRequest) (*empty, error)
+	signBatch(context.Context, *signBatchRequest) (*signBatchResponse, error)
+}
+
+// signerServiceDesc describes the Signer service, replacing the generated
+// code of signer.proto.
+var signerServiceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*signerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		method("Unlock", func() proto.Message { return new(addressRequest) },
+			func(s signerServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.unlock(ctx, req.(*addressRequest))
+			}),
+		method("LockAll", func() proto.Message { return new(empty) },
+			func(s signerServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.lockAll(ctx, req.(*empty))
+			}),
+		method("IncrementUsage", func() proto.Message { return new(addressRequest) },
+			func(s signerServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.incrementUsage(ctx, req.(*addressRequest))
+			}),
+		method("DecrementUsage", func() proto.Message { return new(addressRequest) },
+			func(s signerServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.decrementUsage(ctx, req.(*addressRequest))
+			}),
+		method("SignBatch", func() proto.Message { return new(signBatchRequest) },
+			func(s signerServer, ctx context.Context, req proto.Message) (proto.Message, error) {
+				return s.signBatch(ctx, req.(*signBatchRequest))
+			}),
+	},
+	Metadata: "signer.proto",
+}
+
+// method creates the description of a unary method of the Signer service. The
+// request is created by newReq and handled by handle.
+func method(
+	name string,
+	newReq func() proto.Message,
+	handle func(signerServer, context.Context, proto.Message) (proto.Message, error),
+) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return handle(srv.(signerServer), ctx, req.(proto.Message))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod(name)}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// fullMethod returns the full name of the method of the Signer service.
+func fullMethod(name string) string {
+	return "/" + serviceName + "/" + name
+}
+
+// Server implements the Signer service for a signer daemon by forwarding all
+// requests to a local wallet. Only accounts that were unlocked by a client
+// can sign. The daemon should only accept TLS connections of authenticated
+// clients, e.g., using tls.RequireAndVerifyClientCert.
+type Server struct {
+	wallet wallet.Wallet
+
+	mtx    sync.Mutex
+	accs   map[string]wallet.Account // unlocked accounts
+	usages map[string]int            // usage counters of the accounts
+}
+
+var _ signerServer = (*Server)(nil)
+
+// NewServer creates a Server that forwards requests to the wallet.
+//
+// If the wallet is nil, NewServer panics.
+func NewServer(w wallet.Wallet) *Server {
+	if w == nil {
+		log.Panic("wallet must not be nil")
+	}
+	return &Server{
+		wallet: w,
+		accs:   make(map[string]wallet.Account),
+		usages: make(map[string]int),
+	}
+}
+
+// Register registers the Signer service at the gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	gs.RegisterService(&signerServiceDesc, s)
+}
+
+func (s *Server) unlock(_ context.Context, req *addressRequest) (*empty, error) {
+	addr, err := decodeAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := s.wallet.Unlock(addr)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unlocking %v", addr)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.accs[addr.String()] = acc
+	return new(empty), nil
+}
+
+func (s *Server) lockAll(context.Context, *empty) (*empty, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if err := s.wallet.LockAll(); err != nil {
+		return nil, errors.WithMessage(err, "locking accounts")
+	}
+	s.accs = make(map[string]wallet.Account)
+	return new(empty), nil
+}
+
+func (s *Server) incrementUsage(_ context.Context, req *addressRequest) (*empty, error) {
+	addr, err := decodeAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.wallet.IncrementUsage(addr)
+	s.usages[addr.String()]++
+	return new(empty), nil
+}
+
+// decrementUsage decrements the usage counter of the account. In contrast to
+// Wallet.DecrementUsage, it returns an error if the account is not in use, so
+// that clients cannot crash the daemon.
+func (s *Server) decrementUsage(_ context.Context, req *addressRequest) (*empty, error) {
+	addr, err := decodeAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	key := addr.String()
+	if s.usages[key] == 0 {
+		return nil, errors.Errorf("account %v not in use", addr)
+	}
+	s.wallet.DecrementUsage(addr)
+	if s.usages[key]--; s.usages[key] == 0 {
+		// The wallet locked the account.
+		delete(s.usages, key)
+		delete(s.accs, key)
+	}
+	return new(empty), nil
+}
+
+// signBatch signs the requests one after another. Failed requests don't
+// affect the others.
+func (s *Server) signBatch(_ context.Context, req *signBatchRequest) (*signBatchResponse, error) {
+	resp := &signBatchResponse{Responses: make([]*signResponse, len(req.Requests))}
+	for i, r := range req.Requests {
+		sig, err := s.sign(r)
+		resp.Responses[i] = &signResponse{Sig: sig}
+		if err != nil {
+			resp.Responses[i].Error = err.Error()
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) sign(req *signRequest) (wallet.Sig, error) {
+	addr, err := decodeAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mtx.Lock()
+	acc, ok := s.accs[addr.String()]
+	s.mtx.Unlock()
+	if !ok {
+		return nil, errors.Errorf("account %v not unlocked", addr)
+	}
+	return acc.SignData(req.Data)
+}
+
+// encodeAddress encodes the address for a request.
+func encodeAddress(addr wallet.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := addr.Encode(&buf); err != nil {
+		return nil, errors.WithMessage(err, "encoding address")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeAddress decodes the address of a request.
+func decodeAddress(b []byte) (wallet.Address, error) {
+	addr, err := wallet.DecodeAddress(bytes.NewReader(b))
+	return addr, errors.WithMessage(err, "decoding address")
+}
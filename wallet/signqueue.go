@@ -0,0 +1,151 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/pkg/sync"
+)
+
+type (
+	// A SignRequest is a request to sign data with an account.
+	SignRequest struct {
+		Account Address
+		Data    []byte
+	}
+
+	// A Confirmer asks the user to confirm a signing request, e.g., on the
+	// display of a hardware wallet. Confirm returns nil if the user confirmed
+	// the request and an error otherwise. It should return when the context
+	// is done.
+	Confirmer interface {
+		Confirm(context.Context, SignRequest) error
+	}
+
+	// ConfirmerFunc is an adapter type to allow the use of functions as
+	// Confirmers. ConfirmerFunc(f) is a Confirmer that calls f.
+	ConfirmerFunc func(context.Context, SignRequest) error
+
+	// A SigningQueue is an Account that queues the signing requests to another
+	// account and processes them one after another. Every request has to be
+	// confirmed by its Confirmer before it is passed to the account. This way,
+	// signers that can only handle a single request at a time or that require
+	// user interaction, like hardware wallets, can be used as channel
+	// participants.
+	SigningQueue struct {
+		sync.Closer
+		acc     Account
+		confirm Confirmer
+		reqs    chan *signJob
+	}
+
+	// signJob is a queued signing request and the channel to which its result
+	// is sent.
+	signJob struct {
+		ctx    context.Context
+		req    SignRequest
+		result chan signResult
+	}
+
+	signResult struct {
+		sig Sig
+		err error
+	}
+)
+
+// Confirm calls f(ctx, req).
+func (f ConfirmerFunc) Confirm(ctx context.Context, req SignRequest) error {
+	return f(ctx, req)
+}
+
+var _ Account = (*SigningQueue)(nil)
+
+// NewSigningQueue creates a SigningQueue for the account and starts processing
+// requests. The queue has to be closed when it is not needed anymore.
+//
+// If acc or confirm is nil, NewSigningQueue panics.
+func NewSigningQueue(acc Account, confirm Confirmer) *SigningQueue {
+	if acc == nil || confirm == nil {
+		log.Panic("account and confirmer must not be nil")
+	}
+
+	q := &SigningQueue{
+		acc:     acc,
+		confirm: confirm,
+		reqs:    make(chan *signJob),
+	}
+	go q.process()
+	return q
+}
+
+// Address returns the address of the queue's account.
+func (q *SigningQueue) Address() Address {
+	return q.acc.Address()
+}
+
+// SignData queues a signing request and waits for its signature. It is the
+// same as Sign without a timeout.
+func (q *SigningQueue) SignData(data []byte) ([]byte, error) {
+	return q.Sign(context.Background(), data)
+}
+
+// Sign queues a signing request and waits until it is confirmed and signed.
+// It returns an error if the request was declined, signing failed, the queue
+// is closed or the context is done before the request was processed.
+func (q *SigningQueue) Sign(ctx context.Context, data []byte) (Sig, error) {
+	job := &signJob{
+		ctx:    ctx,
+		req:    SignRequest{Account: q.acc.Address(), Data: data},
+		result: make(chan signResult, 1),
+	}
+
+	select {
+	case q.reqs <- job:
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "queueing signing request")
+	case <-q.Closed():
+		return nil, errors.New("signing queue closed")
+	}
+
+	select {
+	case res := <-job.result:
+		return res.sig, res.err
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "waiting for signature")
+	case <-q.Closed():
+		return nil, errors.New("signing queue closed")
+	}
+}
+
+// process processes the queued requests one after another until the queue is
+// closed.
+func (q *SigningQueue) process() {
+	for {
+		select {
+		case job := <-q.reqs:
+			job.result <- q.handle(job)
+		case <-q.Closed():
+			return
+		}
+	}
+}
+
+// handle confirms and signs a single request. Requests whose context is done
+// already are skipped.
+func (q *SigningQueue) handle(job *signJob) signResult {
+	if err := job.ctx.Err(); err != nil {
+		return signResult{err: err}
+	}
+	if err := q.confirm.Confirm(job.ctx, job.req); err != nil {
+		return signResult{err: errors.WithMessage(err, "signing request not confirmed")}
+	}
+	sig, err := q.acc.SignData(job.req.Data)
+	return signResult{sig: sig, err: errors.WithMessage(err, "signing data")}
+}
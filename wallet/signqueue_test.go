@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "perun.network/go-perun/backend/sim" // backend init
+	"perun.network/go-perun/wallet"
+	wallettest "perun.network/go-perun/wallet/test"
+)
+
+func TestSigningQueue(t *testing.T) {
+	rng := rand.New(rand.NewSource(0x516e))
+	acc := wallettest.NewRandomAccount(rng)
+	data := []byte("data to sign")
+	assert.Panics(t, func() { wallet.NewSigningQueue(nil, wallet.ConfirmerFunc(nil)) })
+	assert.Panics(t, func() { wallet.NewSigningQueue(acc, nil) })
+
+	requests := make(chan wallet.SignRequest)
+	confirmations := make(chan error)
+	q := wallet.NewSigningQueue(acc, wallet.ConfirmerFunc(func(ctx context.Context, req wallet.SignRequest) error {
+		requests <- req
+		return <-confirmations
+	}))
+	defer q.Close()
+	assert.True(t, q.Address().Equals(acc.Address()))
+
+	// A confirmed request is signed by the account.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sig, err := q.SignData(data)
+		assert.NoError(t, err)
+		valid, err := wallet.VerifySignature(data, sig, acc.Address())
+		assert.NoError(t, err)
+		assert.True(t, valid)
+	}()
+	req := <-requests
+	assert.True(t, req.Account.Equals(acc.Address()))
+	assert.Equal(t, data, req.Data)
+	confirmations <- nil
+	<-done
+
+	// A declined request fails.
+	go func() { <-requests; confirmations <- errors.New("declined") }()
+	_, err := q.SignData(data)
+	assert.Error(t, err)
+
+	// A request times out while another one is processed.
+	go q.SignData(data)
+	<-requests
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = q.Sign(ctx, data)
+	assert.Error(t, err)
+
+	// Waiting requests fail when the queue is closed.
+	require.NoError(t, q.Close())
+	confirmations <- nil
+	_, err = q.SignData(data)
+	assert.Error(t, err)
+}
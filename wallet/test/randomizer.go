@@ -41,3 +41,23 @@ func NewRandomAddress(rng *rand.Rand) wallet.Address {
 func NewRandomAccount(rng *rand.Rand) wallet.Account {
 	return randomizer.NewRandomAccount(rng)
 }
+
+// NewRandomAddresses returns n new random addresses.
+func NewRandomAddresses(rng *rand.Rand, n int) []wallet.Address {
+	addrs := make([]wallet.Address, n)
+	for i := range addrs {
+		addrs[i] = NewRandomAddress(rng)
+	}
+	return addrs
+}
+
+// NewRandomAccounts returns n new random accounts and their addresses.
+func NewRandomAccounts(rng *rand.Rand, n int) ([]wallet.Account, []wallet.Address) {
+	accs := make([]wallet.Account, n)
+	addrs := make([]wallet.Address, n)
+	for i := range accs {
+		accs[i] = NewRandomAccount(rng)
+		addrs[i] = accs[i].Address()
+	}
+	return accs, addrs
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package wallet
+
+// A Wallet manages the lifecycle of its accounts. An account has to be
+// unlocked before it can sign. Every user of an account, e.g., a channel,
+// increments the account's usage while it needs the account and decrements it
+// afterwards, so that the wallet can lock accounts that are not used anymore.
+type Wallet interface {
+	// Unlock unlocks the account with the given address and returns it. It
+	// returns an error if the wallet doesn't hold the account or if it cannot
+	// be unlocked.
+	Unlock(Address) (Account, error)
+
+	// LockAll locks all accounts, regardless of their usage.
+	LockAll() error
+
+	// IncrementUsage increments the usage counter of the account with the
+	// given address.
+	IncrementUsage(Address)
+
+	// DecrementUsage decrements the usage counter of the account with the
+	// given address. If it reaches zero, the account is locked. It panics if
+	// the counter is already zero.
+	DecrementUsage(Address)
+}
@@ -27,7 +27,7 @@ type BigInt struct {
 func (b *BigInt) Decode(reader io.Reader) error {
 	// Read length
 	var lengthData = make([]byte, 1)
-	if _, err := reader.Read(lengthData); err != nil {
+	if _, err := io.ReadFull(reader, lengthData); err != nil {
 		return errors.Wrap(err, "failed to decode length of big.Int")
 	}
 
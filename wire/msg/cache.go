@@ -5,15 +5,33 @@
 
 package msg
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"perun.network/go-perun/log"
+)
+
+// MaxExpiringMsgs is the maximum number of messages that a Cache holds for
+// predicates of CacheFor. If it is exceeded, the oldest of them is dropped.
+const MaxExpiringMsgs = 256
 
 type (
 	// Cache is a message cache. The default value is a valid empty cache.
 	Cache struct {
-		msgs  []WithAnnex
+		msgs  []cachedMsg
 		preds []ctxPredicate
 	}
 
+	// cachedMsg is a cached message. If it was only cached by predicates of
+	// CacheFor, it expires at the given time or when ctx is done. Otherwise,
+	// ctx is nil.
+	cachedMsg struct {
+		WithAnnex
+		ctx     context.Context
+		expires time.Time
+	}
+
 	// WithAnnex is a tuple of a message together with some arbitrary additional
 	// data (Annex)
 	WithAnnex struct {
@@ -27,6 +45,7 @@ type (
 	ctxPredicate struct {
 		ctx context.Context
 		p   Predicate
+		ttl time.Duration // 0 if the messages don't expire
 	}
 
 	// A Cacher has the Cache method to enable caching of messages.
@@ -36,9 +55,21 @@ type (
 	}
 )
 
-// Cache is a message cache. The default value is a valid empty cache.
+// Cache enables the caching of messages that match the predicate p while ctx
+// is not done. The cached messages stay in the cache until they are retrieved
+// with Get.
 func (c *Cache) Cache(ctx context.Context, p Predicate) {
-	c.preds = append(c.preds, ctxPredicate{ctx, p})
+	c.preds = append(c.preds, ctxPredicate{ctx, p, 0})
+}
+
+// CacheFor is like Cache, but messages that only match predicates of CacheFor
+// expire: They are removed from the cache after ttl or when ctx is done,
+// whichever happens first. At most MaxExpiringMsgs of them are held.
+func (c *Cache) CacheFor(ctx context.Context, p Predicate, ttl time.Duration) {
+	if ttl <= 0 {
+		log.Panic("ttl must be positive")
+	}
+	c.preds = append(c.preds, ctxPredicate{ctx, p, ttl})
 }
 
 // Put puts the message into the cache if it matches any active prediacte.
@@ -46,7 +77,8 @@ func (c *Cache) Cache(ctx context.Context, p Predicate) {
 func (c *Cache) Put(m Msg, a interface{}) bool {
 	// we filter the predicates for non-active and lazily remove them
 	preds := c.preds[:0]
-	any := false
+	var permanent bool
+	var expiring ctxPredicate // matching CacheFor predicate with largest ttl
 	for _, p := range c.preds {
 		select {
 		case <-p.ctx.Done():
@@ -55,26 +87,41 @@ func (c *Cache) Put(m Msg, a interface{}) bool {
 			preds = append(preds, p)
 		}
 
-		any = any || p.p(m)
-	}
-
-	if any {
-		c.msgs = append(c.msgs, WithAnnex{m, a})
+		if !p.p(m) {
+			continue
+		}
+		if p.ttl == 0 {
+			permanent = true
+		} else if p.ttl > expiring.ttl {
+			expiring = p
+		}
 	}
 
 	c.preds = preds
-	return any
+
+	if !permanent && expiring.ttl == 0 {
+		return false
+	}
+	cached := cachedMsg{WithAnnex: WithAnnex{m, a}}
+	if !permanent {
+		c.prune()
+		c.limitExpiring()
+		cached.ctx, cached.expires = expiring.ctx, time.Now().Add(expiring.ttl)
+	}
+	c.msgs = append(c.msgs, cached)
+	return true
 }
 
 // Get retrieves all messages from the cache that match the predicate. They are
 // removed from the Cache.
 func (c *Cache) Get(p Predicate) []WithAnnex {
+	c.prune()
 	msgs := c.msgs[:0]
 	// Usually, Get is called with the assumption to match at least one message
 	matches := make([]WithAnnex, 0, 1)
 	for _, m := range c.msgs {
 		if p(m.Msg) {
-			matches = append(matches, m)
+			matches = append(matches, m.WithAnnex)
 		} else {
 			msgs = append(msgs, m)
 		}
@@ -91,5 +138,37 @@ func (c *Cache) Flush() {
 
 // Size returns the number of messages held in the message cache.
 func (c *Cache) Size() int {
+	c.prune()
 	return len(c.msgs)
 }
+
+// prune removes all expired messages.
+func (c *Cache) prune() {
+	now := time.Now()
+	msgs := c.msgs[:0]
+	for _, m := range c.msgs {
+		if m.ctx != nil && (now.After(m.expires) || m.ctx.Err() != nil) {
+			continue
+		}
+		msgs = append(msgs, m)
+	}
+	c.msgs = msgs
+}
+
+// limitExpiring drops the oldest expiring message if there are already
+// MaxExpiringMsgs of them, so that another one can be added.
+func (c *Cache) limitExpiring() {
+	var n int
+	oldest := -1
+	for i, m := range c.msgs {
+		if m.ctx != nil {
+			if oldest == -1 {
+				oldest = i
+			}
+			n++
+		}
+	}
+	if n >= MaxExpiringMsgs {
+		c.msgs = append(c.msgs[:oldest], c.msgs[oldest+1:]...)
+	}
+}
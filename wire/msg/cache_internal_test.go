@@ -8,6 +8,7 @@ package msg
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -60,3 +61,41 @@ func TestCache(t *testing.T) {
 	assert.Equal(0, c.Size())
 	assert.False(c.Put(ping0, a0), "flushed cache should not hold any predicates")
 }
+
+func TestCache_CacheFor(t *testing.T) {
+	assert := assert.New(t)
+
+	var c Cache
+	isPing := func(m Msg) bool { return m.Type() == Ping }
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.CacheFor(ctx, isPing, 50*time.Millisecond)
+
+	assert.True(c.Put(NewPingMsg(), nil))
+	assert.Equal(1, c.Size())
+	<-time.After(60 * time.Millisecond)
+	assert.Equal(0, c.Size(), "message should have expired")
+
+	assert.True(c.Put(NewPingMsg(), nil))
+	cancel()
+	assert.Equal(0, c.Size(), "message should expire with its context")
+
+	// A permanent predicate keeps the message.
+	c.CacheFor(context.Background(), isPing, time.Nanosecond)
+	c.Cache(context.Background(), isPing)
+	assert.True(c.Put(NewPingMsg(), nil))
+	<-time.After(time.Millisecond)
+	assert.Equal(1, c.Size())
+	c.Flush()
+
+	c.CacheFor(context.Background(), isPing, time.Minute)
+	first := NewPingMsg()
+	assert.True(c.Put(first, nil))
+	for i := 1; i <= MaxExpiringMsgs; i++ {
+		assert.True(c.Put(NewPingMsg(), nil))
+	}
+	assert.Equal(MaxExpiringMsgs, c.Size())
+	assert.Len(c.Get(func(m Msg) bool { return m == first }), 0, "oldest message should be dropped")
+
+	assert.Panics(func() { c.CacheFor(context.Background(), isPing, 0) })
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+// Capabilities is a set of optional features of the wire protocol. Peers
+// announce their capabilities when they connect and only use the features that
+// both of them support, so that peers of different versions stay compatible.
+type Capabilities uint32
+
+// Enumeration of the optional features of the Perun wire protocol. New
+// capabilities must only be appended.
+const (
+	// CapProposalAbort indicates support of ChannelProposalAbort messages.
+	CapProposalAbort Capabilities = 1 << iota
+	// CapReliable indicates support of Reliable and Ack messages, see
+	// peer.Outbox.
+	CapReliable
+	// CapMultiParty indicates support of channel proposals with more than two
+	// participants and of ChannelProposalParts messages.
+	CapMultiParty
+)
+
+// Has returns whether c contains all capabilities of other.
+func (c Capabilities) Has(other Capabilities) bool {
+	return c&other == other
+}
+
+// Intersect returns the capabilities contained in both c and other.
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	return c & other
+}
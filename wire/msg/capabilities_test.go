@@ -0,0 +1,25 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	const capOther Capabilities = 1 << 31
+	caps := CapProposalAbort | capOther
+
+	assert.True(t, caps.Has(CapProposalAbort))
+	assert.True(t, caps.Has(caps))
+	assert.False(t, CapProposalAbort.Has(caps))
+	assert.True(t, Capabilities(0).Has(0))
+
+	assert.Equal(t, CapProposalAbort, caps.Intersect(CapProposalAbort))
+	assert.Equal(t, Capabilities(0), capOther.Intersect(CapProposalAbort))
+}
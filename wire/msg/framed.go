@@ -0,0 +1,108 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxMsgSize is the maximal size of a message of the FramedSerializer,
+// unless configured otherwise.
+const DefaultMaxMsgSize = maxMsgSize
+
+// FramedSerializer is a Serializer that sends each message in a frame, which
+// is prefixed by its length as big-endian uint32. The frame contains the
+// protocol version, message type and payload, like the BinarySerializer.
+//
+// Since the length of a message is known before it is read, messages that are
+// larger than MaxSize are rejected before any memory is allocated for them,
+// and a message is never read beyond its frame. This makes the
+// FramedSerializer suited for streaming reads from untrusted sockets.
+type FramedSerializer struct {
+	// MaxSize is the maximal size of a frame, excluding the length prefix. If
+	// it is 0, DefaultMaxMsgSize is used. Encode also fails for larger
+	// messages, so both ends of a connection should use the same MaxSize.
+	MaxSize uint32
+}
+
+// A MsgTooLargeError is returned if the size of a message exceeds the maximal
+// size of the Serializer.
+type MsgTooLargeError struct {
+	Size, MaxSize uint64
+}
+
+func (e *MsgTooLargeError) Error() string {
+	return fmt.Sprintf("message size %d exceeds maximum %d", e.Size, e.MaxSize)
+}
+
+func newMsgTooLargeError(size, maxSize uint64) error {
+	return errors.WithStack(&MsgTooLargeError{Size: size, MaxSize: maxSize})
+}
+
+// IsMsgTooLargeError returns true if the error was a MsgTooLargeError.
+func IsMsgTooLargeError(err error) bool {
+	_, ok := errors.Cause(err).(*MsgTooLargeError)
+	return ok
+}
+
+// maxSize returns the configured maximal frame size.
+func (s FramedSerializer) maxSize() uint32 {
+	if s.MaxSize == 0 {
+		return DefaultMaxMsgSize
+	}
+	return s.MaxSize
+}
+
+// Encode writes the length-prefixed frame of the message. It fails without
+// writing anything if the message exceeds MaxSize.
+func (s FramedSerializer) Encode(msg Msg, w io.Writer) error {
+	var frame bytes.Buffer
+	frame.Write(make([]byte, 4)) // length prefix, set below
+	if err := (BinarySerializer{}).Encode(msg, &frame); err != nil {
+		return err
+	}
+
+	size := frame.Len() - 4
+	if uint64(size) > uint64(s.maxSize()) {
+		return newMsgTooLargeError(uint64(size), uint64(s.maxSize()))
+	}
+	binary.BigEndian.PutUint32(frame.Bytes(), uint32(size))
+	if _, err := w.Write(frame.Bytes()); err != nil {
+		return errors.Wrap(err, "writing frame")
+	}
+	return nil
+}
+
+// Decode reads the next frame and decodes its message. Frames that exceed
+// MaxSize are rejected after reading the length prefix.
+func (s FramedSerializer) Decode(r io.Reader) (Msg, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, errors.Wrap(err, "reading frame size")
+	}
+	size := binary.BigEndian.Uint32(prefix[:])
+	if size > s.maxSize() {
+		return nil, newMsgTooLargeError(uint64(size), uint64(s.maxSize()))
+	}
+
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errors.Wrap(err, "reading frame")
+	}
+	frame := bytes.NewReader(buf)
+	m, err := BinarySerializer{}.Decode(markInner(r, frame))
+	if err != nil {
+		return nil, err
+	} else if frame.Len() != 0 {
+		return nil, errors.Errorf("%d trailing bytes in frame of %v", frame.Len(), m.Type())
+	}
+	return m, nil
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramedSerializer_Frame(t *testing.T) {
+	var buf bytes.Buffer
+	ping := NewPingMsg()
+	require.NoError(t, FramedSerializer{}.Encode(ping, &buf))
+
+	var native bytes.Buffer
+	require.NoError(t, BinarySerializer{}.Encode(ping, &native))
+	assert.Equal(t, uint32(native.Len()), binary.BigEndian.Uint32(buf.Bytes()))
+	assert.Equal(t, native.Bytes(), buf.Bytes()[4:])
+
+	// Two frames can be read from one stream.
+	pong := NewPongMsg()
+	require.NoError(t, FramedSerializer{}.Encode(pong, &buf))
+	m, err := FramedSerializer{}.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, ping, m)
+	m, err = FramedSerializer{}.Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, pong, m)
+}
+
+func TestFramedSerializer_MaxSize(t *testing.T) {
+	s := FramedSerializer{MaxSize: 8}
+	var buf bytes.Buffer
+	err := s.Encode(NewPingMsg(), &buf)
+	assert.True(t, IsMsgTooLargeError(err), "encoding oversized message: %v", err)
+	assert.Zero(t, buf.Len(), "nothing should be written")
+
+	// The size is checked before the frame is read.
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], 9)
+	_, err = s.Decode(bytes.NewReader(prefix[:]))
+	assert.True(t, IsMsgTooLargeError(err), "decoding oversized frame: %v", err)
+	binary.BigEndian.PutUint32(prefix[:], DefaultMaxMsgSize+1)
+	_, err = FramedSerializer{}.Decode(bytes.NewReader(prefix[:]))
+	assert.True(t, IsMsgTooLargeError(err), "default maximum")
+}
+
+func TestFramedSerializer_Decode(t *testing.T) {
+	var native bytes.Buffer
+	require.NoError(t, BinarySerializer{}.Encode(NewPingMsg(), &native))
+	frame := func(body []byte, size int) *bytes.Reader {
+		var prefix [4]byte
+		binary.BigEndian.PutUint32(prefix[:], uint32(size))
+		return bytes.NewReader(append(prefix[:], body...))
+	}
+
+	_, err := FramedSerializer{}.Decode(frame(native.Bytes(), native.Len()+1))
+	assert.Error(t, err, "truncated frame")
+	_, err = FramedSerializer{}.Decode(frame(append(native.Bytes(), 0), native.Len()+1))
+	assert.Error(t, err, "trailing bytes")
+	_, err = FramedSerializer{}.Decode(frame(native.Bytes()[:native.Len()-1], native.Len()-1))
+	assert.Error(t, err, "message exceeding its frame")
+}
@@ -15,7 +15,6 @@ import (
 	"github.com/pkg/errors"
 
 	perunio "perun.network/go-perun/pkg/io"
-	"perun.network/go-perun/wire"
 )
 
 // Msg is the top-level abstraction for all messages sent between perun
@@ -27,23 +26,28 @@ type Msg interface {
 	perunio.Encoder
 }
 
-// Encode encodes a message into an io.Writer.
-func Encode(msg Msg, w io.Writer) (err error) {
-	// Encode the message type and payload
-	return wire.Encode(w, byte(msg.Type()), msg)
+// ProtocolVersion is the version of the Perun wire protocol. It is sent in the
+// envelope of every message, so that messages of incompatible peers are
+// detected before their payload is decoded. It has to be increased with every
+// incompatible change of the wire encoding. Optional features are negotiated
+// as Capabilities instead.
+const ProtocolVersion uint8 = 1
+
+// An IncompatibleVersionError indicates that a message of another version of
+// the wire protocol was received.
+type IncompatibleVersionError struct {
+	Version uint8 // The protocol version of the received message.
 }
 
-// Decode decodes a message from an io.Reader.
-func Decode(r io.Reader) (Msg, error) {
-	var t Type
-	if err := wire.Decode(r, (*byte)(&t)); err != nil {
-		return nil, errors.WithMessage(err, "failed to decode message Type")
-	}
+func (e IncompatibleVersionError) Error() string {
+	return fmt.Sprintf("incompatible wire protocol version %d, expected %d", e.Version, ProtocolVersion)
+}
 
-	if !t.Valid() {
-		return nil, errors.Errorf("wire: no decoder known for message Type): %v", t)
-	}
-	return decoders[t](r)
+// IsIncompatibleVersionError checks whether an error is an
+// IncompatibleVersionError.
+func IsIncompatibleVersionError(err error) bool {
+	_, ok := errors.Cause(err).(*IncompatibleVersionError)
+	return ok
 }
 
 var decoders = make(map[Type]func(io.Reader) (Msg, error))
@@ -87,19 +91,41 @@ const (
 	ChannelUpdate
 	ChannelUpdateAcc
 	ChannelUpdateRej
+	VirtualChannelProposal
+	VirtualChannelFundingProposal
+	VirtualChannelSettlementProposal
+	ChannelSync
+	SubChannelProposal
+	SubChannelFundingProposal
+	SubChannelSettlementProposal
+	ChannelProposalAbort
+	Reliable
+	Ack
+	ChannelProposalParts
 	LastType // upper bound on the message types of the Perun wire protocol
 )
 
 var typeNames = map[Type]string{
-	Ping:               "Ping",
-	Pong:               "Pong",
-	AuthResponse:       "AuthResponse",
-	ChannelProposal:    "ChannelProposal",
-	ChannelProposalAcc: "ChannelProposalAcc",
-	ChannelProposalRej: "ChannelProposalRej",
-	ChannelUpdate:      "ChannelUpdate",
-	ChannelUpdateAcc:   "ChannelUpdateAcc",
-	ChannelUpdateRej:   "ChannelUpdateRej",
+	Ping:                             "Ping",
+	Pong:                             "Pong",
+	AuthResponse:                     "AuthResponse",
+	ChannelProposal:                  "ChannelProposal",
+	ChannelProposalAcc:               "ChannelProposalAcc",
+	ChannelProposalRej:               "ChannelProposalRej",
+	ChannelUpdate:                    "ChannelUpdate",
+	ChannelUpdateAcc:                 "ChannelUpdateAcc",
+	ChannelUpdateRej:                 "ChannelUpdateRej",
+	VirtualChannelProposal:           "VirtualChannelProposal",
+	VirtualChannelFundingProposal:    "VirtualChannelFundingProposal",
+	VirtualChannelSettlementProposal: "VirtualChannelSettlementProposal",
+	ChannelSync:                      "ChannelSync",
+	SubChannelProposal:               "SubChannelProposal",
+	SubChannelFundingProposal:        "SubChannelFundingProposal",
+	SubChannelSettlementProposal:     "SubChannelSettlementProposal",
+	ChannelProposalAbort:             "ChannelProposalAbort",
+	Reliable:                         "Reliable",
+	Ack:                              "Ack",
+	ChannelProposalParts:             "ChannelProposalParts",
 }
 
 // String returns the name of a message type if it is valid and name known
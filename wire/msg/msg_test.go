@@ -6,11 +6,13 @@
 package msg
 
 import (
+	"bytes"
 	"io"
 	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"perun.network/go-perun/pkg/test"
 )
@@ -47,3 +49,14 @@ func TestRegisterExternalDecoder(t *testing.T) {
 		"registration of internal type should fail",
 	)
 }
+
+func TestDecode_IncompatibleVersion(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Encode(NewPingMsg(), &buf))
+	enc := buf.Bytes()
+	assert.Equal(t, ProtocolVersion, enc[0], "envelope should start with the protocol version")
+
+	enc[0] = ProtocolVersion + 1
+	_, err := Decode(bytes.NewReader(enc))
+	assert.True(t, IsIncompatibleVersionError(err), "decoding should fail with version error: %v", err)
+}
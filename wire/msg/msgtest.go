@@ -12,9 +12,22 @@ import (
 	"perun.network/go-perun/pkg/io/test"
 )
 
-type serializerMsg struct {
-	Msg Msg
-}
+type (
+	serializerMsg struct {
+		Msg Msg
+	}
+
+	// protoSerializerMsg is like serializerMsg, but uses the ProtoSerializer.
+	protoSerializerMsg struct {
+		Msg Msg
+	}
+
+	// framedSerializerMsg is like serializerMsg, but uses the
+	// FramedSerializer.
+	framedSerializerMsg struct {
+		Msg Msg
+	}
+)
 
 func (msg *serializerMsg) Encode(writer io.Writer) error {
 	return Encode(msg.Msg, writer)
@@ -25,7 +38,26 @@ func (msg *serializerMsg) Decode(reader io.Reader) (err error) {
 	return err
 }
 
-// TestMsg performs generic tests on a wire.Msg object
+func (msg *protoSerializerMsg) Encode(writer io.Writer) error {
+	return ProtoSerializer{}.Encode(msg.Msg, writer)
+}
+
+func (msg *protoSerializerMsg) Decode(reader io.Reader) (err error) {
+	msg.Msg, err = ProtoSerializer{}.Decode(reader)
+	return err
+}
+
+func (msg *framedSerializerMsg) Encode(writer io.Writer) error {
+	return FramedSerializer{}.Encode(msg.Msg, writer)
+}
+
+func (msg *framedSerializerMsg) Decode(reader io.Reader) (err error) {
+	msg.Msg, err = FramedSerializer{}.Decode(reader)
+	return err
+}
+
+// TestMsg performs generic tests on a wire.Msg object, using the global, the
+// protobuf and the framed Serializer.
 func TestMsg(t *testing.T, msg Msg) {
-	test.GenericSerializerTest(t, &serializerMsg{msg})
+	test.GenericSerializerTest(t, &serializerMsg{msg}, &protoSerializerMsg{msg}, &framedSerializerMsg{msg})
 }
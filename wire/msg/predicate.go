@@ -0,0 +1,18 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+// OfType returns a Predicate that matches all messages of the given types.
+func OfType(types ...Type) Predicate {
+	return func(m Msg) bool {
+		for _, t := range types {
+			if m.Type() == t {
+				return true
+			}
+		}
+		return false
+	}
+}
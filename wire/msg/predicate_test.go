@@ -0,0 +1,21 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOfType(t *testing.T) {
+	ping, pong := NewPingMsg(), NewPongMsg()
+
+	assert.False(t, OfType()(ping))
+	assert.True(t, OfType(Ping)(ping))
+	assert.False(t, OfType(Ping)(pong))
+	assert.True(t, OfType(Ping, Pong)(pong))
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+// maxProtoEnvelopeSize is the maximal size of a received protobuf envelope, so
+// that a peer cannot make us allocate arbitrary amounts of memory.
+const maxProtoEnvelopeSize = 1 << 24
+
+// ProtoSerializer is a Serializer that sends each message in a protobuf
+// Envelope, prefixed by its varint-encoded length. This is the delimited
+// format of most protobuf libraries, so that implementations in other
+// languages can use generated code for the envelope:
+//
+//	syntax = "proto3";
+//	message Envelope {
+//	    uint32 version = 1; // ProtocolVersion
+//	    uint32 type = 2;    // Type of the message
+//	    bytes payload = 3;  // Encoding of the message
+//	}
+//
+// The payload still contains the native encoding of the message, as written by
+// its Encode method.
+type ProtoSerializer struct{}
+
+// Envelope is the protobuf envelope of the ProtoSerializer.
+type Envelope struct {
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Type    uint32 `protobuf:"varint,2,opt,name=type,proto3" json:"type,omitempty"`
+	Payload []byte `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+// Reset resets the envelope, as required by proto.Message.
+func (e *Envelope) Reset() { *e = Envelope{} }
+
+// String returns the text format of the envelope, as required by
+// proto.Message.
+func (e *Envelope) String() string { return proto.CompactTextString(e) }
+
+// ProtoMessage marks Envelope as a proto.Message.
+func (*Envelope) ProtoMessage() {}
+
+// Encode writes the length-prefixed protobuf envelope of the message.
+func (ProtoSerializer) Encode(msg Msg, w io.Writer) error {
+	var payload bytes.Buffer
+	if err := msg.Encode(&payload); err != nil {
+		return errors.WithMessagef(err, "encoding payload of %v", msg.Type())
+	}
+	env, err := proto.Marshal(&Envelope{
+		Version: uint32(ProtocolVersion),
+		Type:    uint32(msg.Type()),
+		Payload: payload.Bytes(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshalling envelope")
+	}
+	if _, err := w.Write(append(proto.EncodeVarint(uint64(len(env))), env...)); err != nil {
+		return errors.Wrap(err, "writing envelope")
+	}
+	return nil
+}
+
+// Decode reads the next length-prefixed protobuf envelope and decodes its
+// message.
+func (ProtoSerializer) Decode(r io.Reader) (Msg, error) {
+	size, err := binary.ReadUvarint(byteReader{r})
+	if err != nil {
+		return nil, errors.Wrap(err, "reading envelope size")
+	} else if size > maxProtoEnvelopeSize {
+		return nil, newMsgTooLargeError(size, maxProtoEnvelopeSize)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, errors.Wrap(err, "reading envelope")
+	}
+
+	var env Envelope
+	if err := proto.Unmarshal(buf, &env); err != nil {
+		return nil, errors.Wrap(err, "unmarshalling envelope")
+	}
+	if env.Version != uint32(ProtocolVersion) {
+		return nil, errors.WithStack(&IncompatibleVersionError{Version: uint8(env.Version)})
+	}
+	if env.Type > 0xff {
+		return nil, errors.Errorf("invalid message type %d", env.Type)
+	}
+
+	payload := bytes.NewReader(env.Payload)
+	m, err := decodePayload(Type(env.Type), markInner(r, payload))
+	if err != nil {
+		return nil, err
+	} else if payload.Len() != 0 {
+		return nil, errors.Errorf("%d trailing bytes in payload of %v", payload.Len(), m.Type())
+	}
+	return m, nil
+}
+
+// byteReader reads single bytes from a reader, so that no more than the
+// varint is consumed from the stream.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
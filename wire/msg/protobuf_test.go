@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtoSerializer_Envelope(t *testing.T) {
+	var buf bytes.Buffer
+	ping := NewPingMsg()
+	require.NoError(t, ProtoSerializer{}.Encode(ping, &buf))
+
+	// The envelope can be read by any protobuf implementation.
+	size, n := proto.DecodeVarint(buf.Bytes())
+	require.Equal(t, buf.Len(), n+int(size))
+	var env Envelope
+	require.NoError(t, proto.Unmarshal(buf.Bytes()[n:], &env))
+	assert.Equal(t, uint32(ProtocolVersion), env.Version)
+	assert.Equal(t, uint32(Ping), env.Type)
+
+	var payload bytes.Buffer
+	require.NoError(t, ping.Encode(&payload))
+	assert.Equal(t, payload.Bytes(), env.Payload)
+}
+
+func TestProtoSerializer_Decode(t *testing.T) {
+	encode := func(env *Envelope) *bytes.Reader {
+		enc, err := proto.Marshal(env)
+		require.NoError(t, err)
+		return bytes.NewReader(append(proto.EncodeVarint(uint64(len(enc))), enc...))
+	}
+	var payload bytes.Buffer
+	require.NoError(t, NewPingMsg().Encode(&payload))
+
+	_, err := ProtoSerializer{}.Decode(encode(&Envelope{Version: uint32(ProtocolVersion) + 1, Type: uint32(Ping), Payload: payload.Bytes()}))
+	assert.True(t, IsIncompatibleVersionError(err), "other version: %v", err)
+
+	_, err = ProtoSerializer{}.Decode(encode(&Envelope{Version: uint32(ProtocolVersion), Type: 0x100, Payload: payload.Bytes()}))
+	assert.Error(t, err, "invalid type")
+
+	_, err = ProtoSerializer{}.Decode(encode(&Envelope{Version: uint32(ProtocolVersion), Type: uint32(Ping), Payload: append(payload.Bytes(), 0)}))
+	assert.Error(t, err, "trailing payload")
+
+	_, err = ProtoSerializer{}.Decode(bytes.NewReader(proto.EncodeVarint(maxProtoEnvelopeSize + 1)))
+	assert.Error(t, err, "oversized envelope")
+}
+
+func TestSetSerializer(t *testing.T) {
+	assert.Panics(t, func() { SetSerializer(nil) })
+}
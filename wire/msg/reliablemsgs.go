@@ -0,0 +1,90 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/wire"
+)
+
+func init() {
+	RegisterDecoder(Reliable, func(r io.Reader) (Msg, error) { var m ReliableMsg; return &m, m.Decode(r) })
+	RegisterDecoder(Ack, func(r io.Reader) (Msg, error) { var m AckMsg; return &m, m.Decode(r) })
+}
+
+// ReliableMsg wraps a message that is sent with at-least-once delivery. The
+// recipient has to acknowledge it with an AckMsg of the same sequence number,
+// otherwise it is retransmitted.
+type ReliableMsg struct {
+	Seq uint64 // Sequence number, unique among the unacknowledged messages.
+	Msg Msg    // The wrapped message.
+}
+
+// Type returns Reliable.
+func (*ReliableMsg) Type() Type {
+	return Reliable
+}
+
+// Encode encodes the sequence number and the wrapped message, including its
+// envelope.
+func (m *ReliableMsg) Encode(w io.Writer) error {
+	if err := wire.Encode(w, m.Seq); err != nil {
+		return err
+	}
+	return errors.WithMessage(Encode(m.Msg, w), "encoding wrapped message")
+}
+
+// Decode decodes the sequence number and the wrapped message. The wrapped
+// message must not be a ReliableMsg itself.
+func (m *ReliableMsg) Decode(r io.Reader) (err error) {
+	if _, inner := r.(*innerReader); inner {
+		return errors.New("nested reliable message")
+	}
+	if err := wire.Decode(r, &m.Seq); err != nil {
+		return err
+	}
+	m.Msg, err = Decode(&innerReader{r})
+	return errors.WithMessage(err, "decoding wrapped message")
+}
+
+// innerReader is the reader of a message wrapped in a ReliableMsg. It marks
+// the wrapped message, so that peers cannot nest ReliableMsgs arbitrarily deep
+// to exhaust our stack.
+type innerReader struct {
+	io.Reader
+}
+
+// markInner returns the payload reader of a message that is read from r. It is
+// marked as innerReader if r is one.
+func markInner(r, payload io.Reader) io.Reader {
+	if _, inner := r.(*innerReader); inner {
+		return &innerReader{payload}
+	}
+	return payload
+}
+
+// AckMsg acknowledges the receipt of the ReliableMsg with sequence number Seq.
+type AckMsg struct {
+	Seq uint64
+}
+
+// Type returns Ack.
+func (*AckMsg) Type() Type {
+	return Ack
+}
+
+// Encode encodes the sequence number.
+func (m *AckMsg) Encode(w io.Writer) error {
+	return wire.Encode(w, m.Seq)
+}
+
+// Decode decodes the sequence number.
+func (m *AckMsg) Decode(r io.Reader) error {
+	return wire.Decode(r, &m.Seq)
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReliableMsg(t *testing.T) {
+	TestMsg(t, &ReliableMsg{Seq: 42, Msg: NewPingMsg()})
+}
+
+func TestReliableMsg_Nested(t *testing.T) {
+	var buf bytes.Buffer
+	nested := &ReliableMsg{Seq: 1, Msg: &ReliableMsg{Seq: 2, Msg: NewPingMsg()}}
+	require.NoError(t, Encode(nested, &buf))
+	_, err := Decode(&buf)
+	assert.Error(t, err)
+}
+
+func TestAckMsg(t *testing.T) {
+	TestMsg(t, &AckMsg{Seq: 42})
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Chair of Applied Cryptography, Technische Universität
+// Darmstadt, Germany. All rights reserved. This file is part of go-perun. Use
+// of this source code is governed by a MIT-style license that can be found in
+// the LICENSE file.
+
+package msg
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+
+	"perun.network/go-perun/log"
+	"perun.network/go-perun/wire"
+)
+
+// A Serializer defines the format in which messages are sent over the wire.
+// Both ends of a connection must use the same Serializer.
+type Serializer interface {
+	// Encode should encode the message, including its envelope, into the
+	// writer.
+	Encode(Msg, io.Writer) error
+	// Decode should decode the next message from the reader. It should return
+	// an IncompatibleVersionError if the message is of another protocol
+	// version.
+	Decode(io.Reader) (Msg, error)
+}
+
+// BinarySerializer is the native binary wire format of Perun. It is the
+// default Serializer.
+type BinarySerializer struct{}
+
+// maxMsgSize is the maximal size of a message received with the
+// BinarySerializer, so that a peer cannot make us decode arbitrary amounts of
+// data.
+const maxMsgSize = 1 << 24
+
+// serializer is the global Serializer. It must not be set directly but through
+// SetSerializer.
+var serializer Serializer = BinarySerializer{}
+
+// serializerSet records whether the global Serializer was set already.
+var serializerSet bool
+
+// SetSerializer sets the global Serializer that is used by Encode and Decode,
+// and thus by all peer connections. It must be called before the client is
+// constructed and can only be called once. Panics if s is nil.
+func SetSerializer(s Serializer) {
+	if s == nil {
+		log.Panic("serializer must not be nil")
+	}
+	if serializerSet {
+		log.Panic("serializer already set")
+	}
+	serializer, serializerSet = s, true
+}
+
+// Encode encodes a message into an io.Writer, using the global Serializer.
+func Encode(msg Msg, w io.Writer) error {
+	return serializer.Encode(msg, w)
+}
+
+// Decode decodes a message from an io.Reader, using the global Serializer. It
+// returns an IncompatibleVersionError if the message is of another protocol
+// version.
+func Decode(r io.Reader) (Msg, error) {
+	return serializer.Decode(r)
+}
+
+// Encode encodes the protocol version, message type and payload.
+func (BinarySerializer) Encode(msg Msg, w io.Writer) error {
+	return wire.Encode(w, ProtocolVersion, byte(msg.Type()), msg)
+}
+
+// Decode decodes the protocol version, message type and payload. At most
+// maxMsgSize bytes are read for a message.
+func (BinarySerializer) Decode(r io.Reader) (Msg, error) {
+	if _, inner := r.(*innerReader); !inner {
+		// Inner messages are already limited by the wrapping message.
+		r = io.LimitReader(r, maxMsgSize)
+	}
+	var version uint8
+	if err := wire.Decode(r, &version); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode protocol version")
+	}
+	if version != ProtocolVersion {
+		return nil, errors.WithStack(&IncompatibleVersionError{Version: version})
+	}
+
+	var t Type
+	if err := wire.Decode(r, (*byte)(&t)); err != nil {
+		return nil, errors.WithMessage(err, "failed to decode message Type")
+	}
+	return decodePayload(t, r)
+}
+
+// decodePayload decodes the payload of a message of type t.
+func decodePayload(t Type, r io.Reader) (Msg, error) {
+	if !t.Valid() {
+		return nil, errors.Errorf("wire: no decoder known for message Type): %v", t)
+	}
+	return decoders[t](r)
+}
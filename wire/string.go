@@ -8,6 +8,7 @@ package wire
 import (
 	"encoding/binary"
 	"io"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -40,11 +41,12 @@ func decodeString(r io.Reader, s *string) error {
 		return errors.Wrap(err, "failed to read string length")
 	}
 
-	buf := make([]byte, l)
-	_, err := io.ReadFull(r, buf)
-	if err != nil {
+	// Read into a growing buffer, so that we only allocate as much memory as
+	// was actually received.
+	var buf strings.Builder
+	if _, err := io.CopyN(&buf, r, int64(l)); err != nil {
 		return errors.Wrap(err, "failed to read string")
 	}
-	*s = string(buf)
+	*s = buf.String()
 	return nil
 }